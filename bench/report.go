@@ -0,0 +1,96 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// The latencies of one kind of operation
+type OpStats struct {
+	Op     string
+	Count  int // operations that succeeded
+	Errors int // operations that failed
+	Mean   time.Duration
+	P50    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+// Report summarizes a run of a workload
+type Report struct {
+	Workload   Workload
+	Elapsed    time.Duration
+	Operations int     // operations that succeeded
+	Errors     int     // operations that failed
+	Throughput float64 // operations that succeeded per second
+	Ops        []OpStats
+}
+
+func newReport(w *Workload, elapsed time.Duration, recorders []*recorder) *Report {
+	report := &Report{Workload: *w, Elapsed: elapsed}
+
+	for _, op := range Ops {
+		var latencies []time.Duration
+		stats := OpStats{Op: op}
+		for _, rec := range recorders {
+			latencies = append(latencies, rec.latencies[op]...)
+			stats.Errors += rec.errors[op]
+		}
+		if len(latencies) == 0 && stats.Errors == 0 {
+			continue
+		}
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats.Count = len(latencies)
+		if stats.Count > 0 {
+			var total time.Duration
+			for _, l := range latencies {
+				total += l
+			}
+			stats.Mean = total / time.Duration(stats.Count)
+			stats.P50 = percentile(latencies, 50)
+			stats.P95 = percentile(latencies, 95)
+			stats.P99 = percentile(latencies, 99)
+			stats.Max = latencies[stats.Count-1]
+		}
+
+		report.Operations += stats.Count
+		report.Errors += stats.Errors
+		report.Ops = append(report.Ops, stats)
+	}
+
+	if elapsed > 0 {
+		report.Throughput = float64(report.Operations) / elapsed.Seconds()
+	}
+	return report
+}
+
+// Returns the latency below which p percent of the sorted latencies fall
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p+99)/100 - 1
+	return sorted[max(i, 0)]
+}
+
+// Writes the report as a table, one row per kind of operation
+func (r *Report) Write(w io.Writer) error {
+	fmt.Fprintf(w, "records=%d groups=%d index=%t workers=%d mix=%s seed=%d\n",
+		r.Workload.Records, r.Workload.Groups, r.Workload.Index, r.Workload.Workers, r.Workload.Mix, r.Workload.Seed)
+	fmt.Fprintf(w, "%d operations, %d errors in %s, %.1f ops/s\n\n",
+		r.Operations, r.Errors, r.Elapsed.Round(time.Millisecond), r.Throughput)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "op\tcount\terrors\tmean\tp50\tp95\tp99\tmax\t")
+	for _, s := range r.Ops {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t\n",
+			s.Op, s.Count, s.Errors, round(s.Mean), round(s.P50), round(s.P95), round(s.P99), round(s.Max))
+	}
+	return tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,129 @@
+package bench
+
+import (
+	"centauri/db"
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Runs the workload's operations on tables created by Setup and reports
+// how long they took. Operations that fail, e.g. because a lock wait
+// timed out, are counted as errors without stopping the run. The run
+// ends early if ctx is done.
+func Run(ctx context.Context, d *db.DB, w *Workload) (*Report, error) {
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+
+	if w.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Duration)
+		defer cancel()
+	}
+
+	// Operations are handed out from a shared counter so the total
+	// doesn't depend on how fast each worker is
+	var issued atomic.Int64
+	next := func() bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		return w.Operations <= 0 || issued.Add(1) <= int64(w.Operations)
+	}
+
+	results := make([]*recorder, w.Workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for i := range results {
+		results[i] = newRecorder()
+		wg.Add(1)
+		go func(rec *recorder, seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for next() {
+				op := pick(rnd, w.Mix)
+				opStart := time.Now()
+				err := runOp(d, w, op, rnd)
+				rec.add(op, time.Since(opStart), err)
+			}
+		}(results[i], w.Seed+int64(i))
+	}
+	wg.Wait()
+
+	return newReport(w, time.Since(start), results), nil
+}
+
+// Picks an operation with a probability proportional to its weight
+func pick(rnd *rand.Rand, mix Mix) string {
+	total := 0
+	for _, op := range Ops {
+		total += mix[op]
+	}
+
+	n := rnd.Intn(total)
+	for _, op := range Ops {
+		if n < mix[op] {
+			return op
+		}
+		n -= mix[op]
+	}
+	return Ops[len(Ops)-1]
+}
+
+// Runs a single operation with random arguments
+func runOp(d *db.DB, w *Workload, op string, rnd *rand.Rand) error {
+	switch op {
+	case OP_POINT:
+		return query(d, fmt.Sprintf("select id, balance from bench_accounts where id = %d", rnd.Intn(w.Records)))
+	case OP_RANGE:
+		return query(d, fmt.Sprintf("select id, balance from bench_accounts where grp = %d", rnd.Intn(w.Groups)))
+	case OP_UPDATE:
+		_, err := d.Exec(fmt.Sprintf("update bench_accounts set balance = %d where id = %d",
+			rnd.Intn(10000), rnd.Intn(w.Records)))
+		return err
+	case OP_JOIN:
+		return query(d, fmt.Sprintf("select id, label from bench_accounts, bench_groups where grp = gid and gid = %d",
+			rnd.Intn(w.Groups)))
+	}
+	return fmt.Errorf("%w: unknown operation %q", ErrInvalidWorkload, op)
+}
+
+// Runs a query and reads all of its records
+func query(d *db.DB, sql string) error {
+	rows, err := d.Query(sql)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Collects the latencies measured by one worker
+type recorder struct {
+	latencies map[string][]time.Duration
+	errors    map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+	}
+}
+
+func (r *recorder) add(op string, latency time.Duration, err error) {
+	if err != nil {
+		r.errors[op]++
+		return
+	}
+	r.latencies[op] = append(r.latencies[op], latency)
+}
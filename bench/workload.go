@@ -0,0 +1,193 @@
+// Package bench generates synthetic workloads against an embedded
+// database and reports their latency and throughput, so the effect of
+// changes to the buffer manager, lock table or optimizer can be measured
+// the same way every time.
+//
+//	d, err := db.Open("benchdb", nil)
+//	if err != nil { ... }
+//	defer d.Close()
+//
+//	w := bench.DefaultWorkload()
+//	if err := bench.Setup(d, w); err != nil { ... }
+//
+//	report, err := bench.Run(context.Background(), d, w)
+//	if err != nil { ... }
+//	report.Write(os.Stdout)
+//
+// Setup creates two tables: bench_accounts, holding Records accounts
+// spread over Groups groups, and bench_groups. Run then executes a mix of
+// operations on them from several workers at once. Operations are picked
+// from a random generator seeded with Seed, so a workload issues the same
+// statements every time it runs.
+package bench
+
+import (
+	"centauri/db"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of operations a workload mixes
+const (
+	OP_POINT  = "point"  // reads one account by id
+	OP_RANGE  = "range"  // reads every account of a group
+	OP_UPDATE = "update" // changes the balance of one account
+	OP_JOIN   = "join"   // joins the accounts of a group with the group
+)
+
+// The operations in the order they're reported
+var Ops = []string{OP_POINT, OP_RANGE, OP_UPDATE, OP_JOIN}
+
+// The number of records inserted per transaction by Setup
+const LOAD_BATCH = 500
+
+var ErrInvalidWorkload = errors.New("invalid workload")
+
+// Mix holds the relative weight of each kind of operation
+type Mix map[string]int
+
+// Parses a mix written as comma separated op=weight pairs,
+// e.g. "point=70,range=10,update=15,join=5"
+func ParseMix(s string) (Mix, error) {
+	mix := make(Mix)
+	for _, part := range strings.Split(s, ",") {
+		op, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: expected op=weight, got %q", ErrInvalidWorkload, part)
+		}
+		n, err := strconv.Atoi(weight)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: invalid weight %q for %s", ErrInvalidWorkload, weight, op)
+		}
+		mix[op] = n
+	}
+	return mix, nil
+}
+
+func (m Mix) String() string {
+	parts := make([]string, 0, len(m))
+	for _, op := range Ops {
+		if weight, ok := m[op]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%d", op, weight))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Workload describes the data a benchmark runs on and the operations it runs
+type Workload struct {
+	Records    int           // accounts in bench_accounts
+	Groups     int           // groups the accounts are spread over
+	Index      bool          // index the columns the operations select on
+	Mix        Mix           // relative weight of each operation
+	Workers    int           // operations running at once
+	Operations int           // operations to run in total, 0 to run until Duration
+	Duration   time.Duration // the longest the run may take, 0 for no limit
+	Seed       int64         // seeds the choice of operations and their arguments
+}
+
+// Returns a read-mostly workload over 10000 accounts
+func DefaultWorkload() *Workload {
+	return &Workload{
+		Records:    10000,
+		Groups:     100,
+		Index:      true,
+		Mix:        Mix{OP_POINT: 70, OP_RANGE: 10, OP_UPDATE: 15, OP_JOIN: 5},
+		Workers:    4,
+		Operations: 10000,
+		Seed:       1,
+	}
+}
+
+// Checks that the workload can be run
+func (w *Workload) Validate() error {
+	if w.Records <= 0 || w.Groups <= 0 {
+		return fmt.Errorf("%w: records and groups must be positive", ErrInvalidWorkload)
+	}
+	if w.Workers <= 0 {
+		return fmt.Errorf("%w: workers must be positive", ErrInvalidWorkload)
+	}
+	if w.Operations <= 0 && w.Duration <= 0 {
+		return fmt.Errorf("%w: operations or duration must be set", ErrInvalidWorkload)
+	}
+
+	total := 0
+	for op, weight := range w.Mix {
+		if !isOp(op) {
+			return fmt.Errorf("%w: unknown operation %q", ErrInvalidWorkload, op)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("%w: the mix holds no operation", ErrInvalidWorkload)
+	}
+	return nil
+}
+
+func isOp(op string) bool {
+	for _, known := range Ops {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Creates the tables of the workload and loads them. The database must
+// not hold the tables yet.
+func Setup(d *db.DB, w *Workload) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+
+	statements := []string{
+		"create table bench_accounts (id int, grp int, balance int, name varchar(16))",
+		"create table bench_groups (gid int, label varchar(16))",
+	}
+	if w.Index {
+		statements = append(statements,
+			"create index bench_acct_id on bench_accounts (id)",
+			"create index bench_acct_grp on bench_accounts (grp)",
+			"create index bench_grp_gid on bench_groups (gid)",
+		)
+	}
+	for _, stmt := range statements {
+		if _, err := d.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create schema: %w", err)
+		}
+	}
+
+	err := load(d, w.Groups, func(i int) string {
+		return fmt.Sprintf("insert into bench_groups (gid, label) values (%d, 'group %d')", i, i)
+	})
+	if err != nil {
+		return err
+	}
+	return load(d, w.Records, func(i int) string {
+		return fmt.Sprintf("insert into bench_accounts (id, grp, balance, name) values (%d, %d, %d, 'account %d')",
+			i, i%w.Groups, 1000, i)
+	})
+}
+
+// Runs n inserts, LOAD_BATCH per transaction
+func load(d *db.DB, n int, insert func(i int) string) error {
+	for start := 0; start < n; start += LOAD_BATCH {
+		t, err := d.Begin()
+		if err != nil {
+			return err
+		}
+		for i := start; i < min(start+LOAD_BATCH, n); i++ {
+			// A failed statement rolls the transaction back
+			if _, err := t.Exec(insert(i)); err != nil {
+				return fmt.Errorf("failed to load records: %w", err)
+			}
+		}
+		if err := t.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+// Command centauri-bench runs a synthetic workload against a database
+// and reports the latency and throughput of its operations.
+//
+//	centauri-bench -dir benchdb -setup
+//	centauri-bench -dir benchdb -ops 50000 -workers 8 -mix point=50,update=50
+//
+// The tables are created and loaded with -setup, after which the same
+// database can be benchmarked any number of times.
+package main
+
+import (
+	"centauri/bench"
+	"centauri/db"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+func main() {
+	defaults := bench.DefaultWorkload()
+
+	dir := flag.String("dir", "benchdb", "directory holding the database files")
+	setup := flag.Bool("setup", false, "create and load the tables before running")
+	setupOnly := flag.Bool("setup-only", false, "create and load the tables without running")
+	records := flag.Int("records", defaults.Records, "accounts to load")
+	groups := flag.Int("groups", defaults.Groups, "groups the accounts are spread over")
+	noIndex := flag.Bool("no-index", false, "don't index the tables")
+	mix := flag.String("mix", defaults.Mix.String(), "relative weights of the operations")
+	workers := flag.Int("workers", defaults.Workers, "operations running at once")
+	ops := flag.Int("ops", defaults.Operations, "operations to run, 0 to run until -duration")
+	duration := flag.Duration("duration", 0, "the longest the run may take, 0 for no limit")
+	seed := flag.Int64("seed", defaults.Seed, "seed of the random operations")
+	blockSize := flag.Int("block-size", db.DefaultOptions().BlockSize, "size of a disk block in bytes")
+	buffers := flag.Int("buffers", db.DefaultOptions().BufferSize, "number of buffers in the buffer pool")
+	flag.Parse()
+
+	w := &bench.Workload{
+		Records:    *records,
+		Groups:     *groups,
+		Index:      !*noIndex,
+		Workers:    *workers,
+		Operations: *ops,
+		Duration:   *duration,
+		Seed:       *seed,
+	}
+	var err error
+	if w.Mix, err = bench.ParseMix(*mix); err != nil {
+		fail("%v", err)
+	}
+	if err := w.Validate(); err != nil {
+		fail("%v", err)
+	}
+
+	// The engine reports its progress on standard output,
+	// which must only hold the report
+	out := os.Stdout
+	os.Stdout = os.Stderr
+
+	d, err := db.Open(*dir, &db.Options{BlockSize: *blockSize, BufferSize: *buffers})
+	if err != nil {
+		fail("failed to open database: %v", err)
+	}
+	defer d.Close()
+
+	if *setup || *setupOnly {
+		if err := bench.Setup(d, w); err != nil {
+			d.Close()
+			fail("setup failed: %v", err)
+		}
+		if *setupOnly {
+			return
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := bench.Run(ctx, d, w)
+	if err != nil {
+		d.Close()
+		fail("run failed: %v", err)
+	}
+	report.Write(out)
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,247 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Control keys understood by the line editor
+const (
+	KEY_CTRL_A    = 0x01
+	KEY_CTRL_B    = 0x02
+	KEY_CTRL_C    = 0x03
+	KEY_CTRL_D    = 0x04
+	KEY_CTRL_E    = 0x05
+	KEY_CTRL_F    = 0x06
+	KEY_CTRL_K    = 0x0b
+	KEY_CTRL_U    = 0x15
+	KEY_ENTER     = 0x0d
+	KEY_NEWLINE   = 0x0a
+	KEY_ESCAPE    = 0x1b
+	KEY_BACKSPACE = 0x7f
+	KEY_CTRL_H    = 0x08
+)
+
+// Edits lines on a terminal in raw mode, supporting cursor movement,
+// deletion and history recall with the arrow keys
+type lineEditor struct {
+	fd       int
+	in       *bufio.Reader
+	out      io.Writer
+	original syscall.Termios
+	raw      bool
+	history  history
+}
+
+func newLineEditor(in *os.File, out io.Writer, h history) (*lineEditor, error) {
+	fd := int(in.Fd())
+
+	var termios syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, &termios); err != nil {
+		return nil, err
+	}
+
+	return &lineEditor{
+		fd:       fd,
+		in:       bufio.NewReader(in),
+		out:      out,
+		original: termios,
+		history:  h,
+	}, nil
+}
+
+func ioctl(fd int, request uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Switches the terminal to raw mode so every key press is read immediately
+// and is not echoed. Output processing is left on so '\n' still starts a new line.
+func (le *lineEditor) enableRaw() error {
+	raw := le.original
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.BRKINT | syscall.INPCK | syscall.ISTRIP
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	if err := ioctl(le.fd, syscall.TCSETS, &raw); err != nil {
+		return err
+	}
+	le.raw = true
+	return nil
+}
+
+func (le *lineEditor) disableRaw() error {
+	if !le.raw {
+		return nil
+	}
+	le.raw = false
+	return ioctl(le.fd, syscall.TCSETS, &le.original)
+}
+
+func (le *lineEditor) ReadLine(prompt string) (string, error) {
+	// Raw mode is only enabled while a line is being edited, so that
+	// output printed while executing a statement is unaffected
+	if err := le.enableRaw(); err != nil {
+		return "", err
+	}
+	defer le.disableRaw()
+
+	line := []rune{}
+	pos := 0
+	histPos := len(le.history.lines)
+	pending := "" // line being typed before moving through the history
+
+	fmt.Fprint(le.out, prompt)
+
+	for {
+		r, _, err := le.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case KEY_ENTER, KEY_NEWLINE:
+			fmt.Fprint(le.out, "\r\n")
+			return string(line), nil
+
+		case KEY_CTRL_C:
+			fmt.Fprint(le.out, "^C\r\n")
+			return "", ErrInterrupted
+
+		case KEY_CTRL_D:
+			if len(line) == 0 {
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+
+		case KEY_BACKSPACE, KEY_CTRL_H:
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+
+		case KEY_CTRL_A:
+			pos = 0
+
+		case KEY_CTRL_E:
+			pos = len(line)
+
+		case KEY_CTRL_B:
+			pos = max(pos-1, 0)
+
+		case KEY_CTRL_F:
+			pos = min(pos+1, len(line))
+
+		case KEY_CTRL_K:
+			line = line[:pos]
+
+		case KEY_CTRL_U:
+			line = line[pos:]
+			pos = 0
+
+		case KEY_ESCAPE:
+			switch le.readEscape() {
+			case 'A': // up
+				if histPos > 0 {
+					if histPos == len(le.history.lines) {
+						pending = string(line)
+					}
+					histPos--
+					line = []rune(le.history.lines[histPos])
+					pos = len(line)
+				}
+			case 'B': // down
+				if histPos < len(le.history.lines) {
+					histPos++
+					if histPos == len(le.history.lines) {
+						line = []rune(pending)
+					} else {
+						line = []rune(le.history.lines[histPos])
+					}
+					pos = len(line)
+				}
+			case 'C': // right
+				pos = min(pos+1, len(line))
+			case 'D': // left
+				pos = max(pos-1, 0)
+			case 'H': // home
+				pos = 0
+			case 'F': // end
+				pos = len(line)
+			case '3': // delete
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+
+		default:
+			if r < 0x20 {
+				// Ignore the remaining control characters
+				continue
+			}
+			line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+			pos++
+		}
+
+		le.refresh(prompt, line, pos)
+	}
+}
+
+// Reads the rest of an escape sequence and returns its final byte,
+// or '3' for the delete key (ESC [ 3 ~)
+func (le *lineEditor) readEscape() byte {
+	b, err := le.in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return 0
+	}
+
+	b, err = le.in.ReadByte()
+	if err != nil {
+		return 0
+	}
+	if b >= '0' && b <= '9' {
+		// Sequences such as ESC [ 3 ~ end with a tilde
+		code := b
+		for b != '~' {
+			if b, err = le.in.ReadByte(); err != nil {
+				return 0
+			}
+		}
+		switch code {
+		case '1', '7':
+			return 'H'
+		case '4', '8':
+			return 'F'
+		}
+		return code
+	}
+	return b
+}
+
+// Redraws the prompt and line and moves the cursor to pos
+func (le *lineEditor) refresh(prompt string, line []rune, pos int) {
+	fmt.Fprintf(le.out, "\r%s%s\x1b[K", prompt, string(line))
+	if back := len(line) - pos; back > 0 {
+		fmt.Fprintf(le.out, "\x1b[%dD", back)
+	}
+}
+
+func (le *lineEditor) AddHistory(line string) {
+	le.history.add(line)
+}
+
+func (le *lineEditor) Close() error {
+	return le.disableRaw()
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// Line editing is only implemented for Linux terminals,
+// elsewhere lines are read without editing support
+func newLineEditor(in *os.File, out io.Writer, h history) (LineReader, error) {
+	return nil, errors.New("line editing not supported")
+}
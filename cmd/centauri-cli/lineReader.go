@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Returned by ReadLine when the user presses Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// The number of lines kept in the history
+const MAX_HISTORY = 500
+
+// Reads lines of input from the user
+type LineReader interface {
+	// Prints the prompt and reads a line without its trailing newline.
+	// Returns io.EOF when the input ends.
+	ReadLine(prompt string) (string, error)
+
+	// Adds a line to the history available when editing later lines
+	AddHistory(line string)
+
+	// Restores the terminal
+	Close() error
+}
+
+// Returns a line editor if in is a terminal that can be put into raw mode,
+// or a plain reader otherwise, e.g. when statements are piped in.
+// The editor recalls the lines kept in historyFile by earlier sessions
+// and adds the new ones to it; an empty name keeps no history file.
+func NewLineReader(in *os.File, out io.Writer, historyFile string) LineReader {
+	if editor, err := newLineEditor(in, out, loadHistory(historyFile)); err == nil {
+		return editor
+	}
+	return &plainReader{in: bufio.NewReader(in), out: out}
+}
+
+// Reads lines without any editing support
+type plainReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (pr *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(pr.out, prompt)
+
+	line, err := pr.in.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (pr *plainReader) AddHistory(line string) {}
+
+func (pr *plainReader) Close() error {
+	return nil
+}
+
+// Keeps previously entered lines for recall with the arrow keys
+type history struct {
+	lines []string
+	file  string // file the lines are appended to, empty for none
+}
+
+// Reads the last MAX_HISTORY lines of the history file, if it exists
+func loadHistory(file string) history {
+	h := history{file: file}
+	if file == "" {
+		return h
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return h
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			h.lines = append(h.lines, line)
+		}
+	}
+	if len(h.lines) > MAX_HISTORY {
+		h.lines = h.lines[len(h.lines)-MAX_HISTORY:]
+	}
+	return h
+}
+
+// Adds a line to the history and the history file. A statement that
+// spans several lines is kept as a single line.
+func (h *history) add(line string) {
+	line = strings.Join(strings.Fields(line), " ")
+	if line == "" || (len(h.lines) > 0 && h.lines[len(h.lines)-1] == line) {
+		return
+	}
+	h.lines = append(h.lines, line)
+	if len(h.lines) > MAX_HISTORY {
+		h.lines = h.lines[1:]
+	}
+
+	// The history file is a convenience, so failing to write it isn't an error
+	if h.file != "" {
+		if f, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+			fmt.Fprintln(f, line)
+			f.Close()
+		}
+	}
+}
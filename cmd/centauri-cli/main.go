@@ -0,0 +1,37 @@
+package main
+
+import (
+	"centauri/internal/app/server"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	dir := flag.String("dir", "centauridb", "directory holding the database files")
+	historyFile := flag.String("history", defaultHistoryFile(), "file keeping the lines entered, empty for none")
+	flag.Parse()
+
+	db, err := server.NewCentauriDB(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open database: %v\n", err)
+		os.Exit(1)
+	}
+
+	repl := NewRepl(db, NewLineReader(os.Stdin, os.Stdout, *historyFile), os.Stdout)
+	if err := repl.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Returns the history file in the user's home directory,
+// or none if the home directory is unknown
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".centauri_history")
+}
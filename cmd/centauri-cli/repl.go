@@ -0,0 +1,387 @@
+package main
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/server"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PROMPT              = "centauri> "
+	CONTINUATION_PROMPT = "       -> "
+)
+
+// Reads statements from the user, executes them against
+// the database and prints their results
+type Repl struct {
+	db      *server.CentauriDB
+	session *server.Session
+	lr      LineReader
+	out     io.Writer
+	timing  bool
+}
+
+func NewRepl(db *server.CentauriDB, lr LineReader, out io.Writer) *Repl {
+	return &Repl{
+		db:      db,
+		session: server.NewSession(db),
+		lr:      lr,
+		out:     out,
+		timing:  true,
+	}
+}
+
+// Runs until the user quits or the input ends.
+// A statement may span several lines and ends with ';'.
+// Lines starting with '\' are meta commands and are only
+// accepted at the start of a statement.
+func (r *Repl) Run() error {
+	defer r.lr.Close()
+	defer r.session.Close()
+
+	fmt.Fprintln(r.out, `Type \? for help, \q to quit.`)
+
+	var stmt strings.Builder
+	for {
+		prompt := PROMPT
+		if stmt.Len() > 0 {
+			prompt = CONTINUATION_PROMPT
+		}
+
+		line, err := r.lr.ReadLine(prompt)
+		if errors.Is(err, ErrInterrupted) {
+			// Discard the statement being typed
+			stmt.Reset()
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(r.out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		if stmt.Len() == 0 && strings.HasPrefix(trimmed, `\`) {
+			r.lr.AddHistory(trimmed)
+			if quit := r.metaCommand(trimmed); quit {
+				return nil
+			}
+			continue
+		}
+
+		if stmt.Len() > 0 {
+			stmt.WriteString("\n")
+		}
+		stmt.WriteString(line)
+
+		// Run every complete statement on the line; anything after
+		// the last ';' starts the next statement
+		for {
+			cmd, rest, complete := splitStatement(stmt.String())
+			if !complete {
+				break
+			}
+			r.lr.AddHistory(strings.TrimSpace(cmd) + ";")
+			r.execute(cmd)
+
+			stmt.Reset()
+			if strings.TrimSpace(rest) != "" {
+				stmt.WriteString(rest)
+			}
+		}
+	}
+}
+
+// Splits off the first statement terminated by a ';' outside of a quoted string.
+// complete is false if the text doesn't contain a terminated statement yet.
+func splitStatement(text string) (stmt string, rest string, complete bool) {
+	inQuote := false
+	for i, c := range text {
+		switch {
+		case c == '\'':
+			inQuote = !inQuote
+		case c == ';' && !inQuote:
+			return text[:i], text[i+1:], true
+		}
+	}
+	return text, "", false
+}
+
+// Executes a single statement in the session and prints the result
+func (r *Repl) execute(cmd string) {
+	if strings.TrimSpace(cmd) == "" {
+		return
+	}
+
+	stop := r.cancelOnInterrupt()
+	start := time.Now()
+	err := r.run(cmd)
+	elapsed := time.Since(start)
+	stop()
+
+	if err != nil {
+		fmt.Fprintf(r.out, "ERROR: %v\n", err)
+	}
+	if r.timing {
+		fmt.Fprintf(r.out, "Time: %.3f ms\n", float64(elapsed.Microseconds())/1000)
+	}
+}
+
+// Cancels the running statement when the user presses ctrl-C, instead of
+// quitting. Returns a function that stops listening for the interrupt.
+func (r *Repl) cancelOnInterrupt() func() {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-interrupts:
+			r.session.Cancel()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(interrupts)
+		close(done)
+	}
+}
+
+func (r *Repl) run(cmd string) error {
+	return r.session.Execute(cmd, func(result *server.Result) error {
+		switch {
+		case result.Plan != nil:
+			r.printPlan(result.Plan)
+		case server.IsSessionCmd(cmd):
+			fmt.Fprintln(r.out, sessionCmdTag(cmd))
+		default:
+			fmt.Fprintf(r.out, "%d %s affected\n", result.Count, plural(result.Count, "row", "rows"))
+		}
+		return nil
+	})
+}
+
+// Returns what's printed once a session command without a result
+// succeeds: its leading keywords, e.g. SET or CREATE DATABASE
+func sessionCmdTag(cmd string) string {
+	words := strings.Fields(strings.ToUpper(cmd))
+	if len(words) > 1 && words[0] == "CREATE" {
+		return "CREATE " + strings.TrimSuffix(words[1], ";")
+	}
+	return strings.TrimSuffix(words[0], ";")
+}
+
+// Prints the records of a query plan as a table
+func (r *Repl) printPlan(p interfaces.Plan) {
+	scan := p.Open()
+	defer scan.Close()
+
+	sch := scan.Schema()
+	fields := sch.Fields()
+
+	t := NewTable(fields...)
+	for i, fieldName := range fields {
+		if sch.DataType(fieldName) == schema.INTEGER || sch.DataType(fieldName) == schema.BIGINT || sch.DataType(fieldName) == schema.FLOAT {
+			t.AlignRight(i)
+		}
+	}
+
+	for scan.Next() {
+		row := make([]string, len(fields))
+		for i, fieldName := range fields {
+			switch {
+			case scan.GetVal(fieldName).IsNull():
+				row[i] = "NULL"
+			case sch.DataType(fieldName) == schema.INTEGER:
+				row[i] = strconv.Itoa(scan.GetInt(fieldName))
+			case sch.DataType(fieldName) == schema.BIGINT || sch.DataType(fieldName) == schema.FLOAT || sch.DataType(fieldName).IsTemporal() || sch.DataType(fieldName) == schema.BOOLEAN:
+				row[i] = scan.GetVal(fieldName).String()
+			default:
+				row[i] = scan.GetString(fieldName)
+			}
+		}
+		t.AddRow(row...)
+	}
+
+	t.Print(r.out)
+	fmt.Fprintf(r.out, "(%d %s)\n", t.Len(), plural(t.Len(), "row", "rows"))
+}
+
+// Executes a meta command. Returns true if the user asked to quit.
+func (r *Repl) metaCommand(line string) bool {
+	args := strings.Fields(line)
+
+	switch args[0] {
+	case `\q`:
+		return true
+	case `\?`, `\h`:
+		r.printHelp()
+	case `\timing`:
+		if len(args) > 1 {
+			r.timing = strings.EqualFold(args[1], "on")
+		} else {
+			r.timing = !r.timing
+		}
+		fmt.Fprintf(r.out, "Timing is %s.\n", onOff(r.timing))
+	case `\dt`, `\dv`, `\di`, `\d`:
+		if err := r.describe(args); err != nil {
+			fmt.Fprintf(r.out, "ERROR: %v\n", err)
+		}
+	default:
+		fmt.Fprintf(r.out, "Invalid command %s. Try \\? for help.\n", args[0])
+	}
+	return false
+}
+
+// Prints catalog information for the \d family of meta commands,
+// about the database selected with USE
+func (r *Repl) describe(args []string) error {
+	db := r.session.Database()
+	tx := db.NewTx()
+	defer tx.Commit()
+
+	mdm := db.MdMgr()
+
+	switch args[0] {
+	case `\dt`:
+		t := NewTable("name")
+		for _, tableName := range mdm.TableNames(tx) {
+			t.AddRow(tableName)
+		}
+		t.Print(r.out)
+
+	case `\dv`:
+		t := NewTable("name", "definition")
+		for _, viewName := range mdm.ViewNames(tx) {
+			t.AddRow(viewName, mdm.GetViewDef(viewName, tx))
+		}
+		t.Print(r.out)
+
+	case `\di`:
+		t := NewTable("name", "table", "fields")
+		for _, tableName := range mdm.TableNames(tx) {
+			for _, ii := range sortedIndexes(mdm.GetIndexInfo(tableName, tx)) {
+				t.AddRow(ii.name, tableName, strings.Join(ii.fields, ", "))
+			}
+		}
+		t.Print(r.out)
+
+	case `\d`:
+		if len(args) < 2 {
+			return fmt.Errorf(`usage: \d <table>`)
+		}
+
+		tableName := strings.ToLower(args[1])
+		layout, err := mdm.GetLayout(tableName, tx)
+		if err != nil {
+			return err
+		}
+
+		sch := layout.Schema()
+		t := NewTable("column", "type")
+		for _, fieldName := range sch.Fields() {
+			typ := typeName(sch, fieldName)
+			if sch.NotNull(fieldName) {
+				typ += " not null"
+			}
+			if value := sch.Default(fieldName); value != "" {
+				typ += " default " + value
+			}
+			if sch.AutoIncrement(fieldName) {
+				typ += " auto_increment"
+			}
+			if expr := sch.Generated(fieldName); expr != "" {
+				kind := "stored"
+				if sch.IsVirtual(fieldName) {
+					kind = "virtual"
+				}
+				typ += fmt.Sprintf(" as (%s) %s", expr, kind)
+			}
+			t.AddRow(fieldName, typ)
+		}
+		t.Print(r.out)
+
+		if p := layout.Options().Partitioning; p != nil {
+			fmt.Fprintf(r.out, "Partitioned: %s\n", p)
+		}
+
+		indexes := sortedIndexes(mdm.GetIndexInfo(tableName, tx))
+		if len(indexes) > 0 {
+			fmt.Fprintln(r.out, "Indexes:")
+			for _, ii := range indexes {
+				fmt.Fprintf(r.out, "    %s (%s)\n", ii.name, strings.Join(ii.fields, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Repl) printHelp() {
+	fmt.Fprint(r.out, `Statements end with ';' and may span several lines.
+
+Meta commands:
+  \dt              list tables
+  \dv              list views
+  \di              list indexes
+  \d <table>       describe a table
+  \timing [on|off] toggle display of statement execution time
+  \?               show this help
+  \q               quit
+`)
+}
+
+type indexDesc struct {
+	name   string
+	fields []string
+}
+
+// Returns the indexes of a table ordered by name
+func sortedIndexes(indexes map[string]metadata.IndexInfo) []indexDesc {
+	descs := make([]indexDesc, 0, len(indexes))
+	for _, ii := range indexes {
+		descs = append(descs, indexDesc{name: ii.IndexName(), fields: ii.FieldNames()})
+	}
+	sort.Slice(descs, func(i, j int) bool {
+		return descs[i].name < descs[j].name
+	})
+	return descs
+}
+
+// Returns the SQL type of a field as written in CREATE TABLE
+func typeName(sch *schema.Schema, fieldName string) string {
+	if fieldType := sch.DataType(fieldName); fieldType != schema.VARCHAR {
+		return fieldType.String()
+	}
+	return fmt.Sprintf("varchar(%d)", sch.Length(fieldName))
+}
+
+func plural(n int, singular string, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
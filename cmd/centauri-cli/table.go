@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Collects rows and prints them as an aligned text table:
+//
+//	 id | name
+//	----+-------
+//	  1 | alice
+type Table struct {
+	headers []string
+	rows    [][]string
+	right   []bool // columns aligned to the right, e.g. numbers
+}
+
+func NewTable(headers ...string) *Table {
+	return &Table{
+		headers: headers,
+		right:   make([]bool, len(headers)),
+	}
+}
+
+// Aligns the values of the column to the right
+func (t *Table) AlignRight(col int) {
+	t.right[col] = true
+}
+
+func (t *Table) AddRow(values ...string) {
+	t.rows = append(t.rows, values)
+}
+
+// Returns the number of rows in the table
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+func (t *Table) Print(w io.Writer) {
+	widths := make([]int, len(t.headers))
+	for i, header := range t.headers {
+		widths[i] = utf8.RuneCountInString(header)
+	}
+	for _, row := range t.rows {
+		for i, val := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(val))
+		}
+	}
+
+	// Headers are always centered
+	cells := make([]string, len(t.headers))
+	for i, header := range t.headers {
+		padding := widths[i] - utf8.RuneCountInString(header)
+		cells[i] = strings.Repeat(" ", padding/2) + header + strings.Repeat(" ", padding-padding/2)
+	}
+	fmt.Fprintf(w, " %s\n", strings.Join(cells, " | "))
+
+	separators := make([]string, len(widths))
+	for i, width := range widths {
+		separators[i] = strings.Repeat("-", width+2)
+	}
+	fmt.Fprintln(w, strings.Join(separators, "+"))
+
+	for _, row := range t.rows {
+		for i, val := range row {
+			padding := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(val))
+			if t.right[i] {
+				cells[i] = padding + val
+			} else {
+				cells[i] = val + padding
+			}
+		}
+		fmt.Fprintf(w, " %s\n", strings.Join(cells, " | "))
+	}
+}
@@ -0,0 +1,73 @@
+// Command centauri-dump writes the schema and records of a database
+// as SQL statements that recreate it.
+//
+//	centauri-dump -dir data > backup.sql
+//	centauri-dump -dir data -format copy -copy-dir csv -o backup.sql
+package main
+
+import (
+	"centauri/db"
+	"centauri/dump"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	dir := flag.String("dir", "centauridb", "directory holding the database files")
+	output := flag.String("o", "", "file to write the dump to, standard output if empty")
+	format := flag.String("format", dump.FORMAT_INSERT, "how records are written: insert or copy")
+	copyDir := flag.String("copy-dir", ".", "directory the CSV files are written to with -format copy")
+	tables := flag.String("tables", "", "comma separated tables and views to dump, all if empty")
+	schemaOnly := flag.Bool("schema-only", false, "dump only the CREATE statements")
+	dataOnly := flag.Bool("data-only", false, "dump only the records")
+	flag.Parse()
+
+	if _, err := os.Stat(*dir); err != nil {
+		fail("cannot open database: %v", err)
+	}
+
+	var out io.Writer = os.Stdout
+	// The engine reports its progress on standard output,
+	// which must only hold the dump
+	os.Stdout = os.Stderr
+
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fail("%v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	opts := &dump.Options{
+		Format:     strings.ToLower(*format),
+		CopyDir:    *copyDir,
+		SchemaOnly: *schemaOnly,
+		DataOnly:   *dataOnly,
+	}
+	if *tables != "" {
+		for _, name := range strings.Split(*tables, ",") {
+			opts.Tables = append(opts.Tables, strings.TrimSpace(name))
+		}
+	}
+
+	d, err := db.Open(*dir, nil)
+	if err != nil {
+		fail("failed to open database: %v", err)
+	}
+	defer d.Close()
+
+	if err := dump.Dump(d, out, opts); err != nil {
+		d.Close()
+		fail("dump failed: %v", err)
+	}
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
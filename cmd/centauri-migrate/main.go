@@ -0,0 +1,55 @@
+// Command centauri-migrate applies the pending SQL migrations of a
+// directory to a database.
+//
+//	centauri-migrate -dir data -migrations migrations
+//	centauri-migrate -dir data -migrations migrations -dry-run
+//
+// Migrations are files named <version>_<name>.sql, see package migrate.
+package main
+
+import (
+	"centauri/db"
+	"centauri/migrate"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	dir := flag.String("dir", "centauridb", "directory holding the database files")
+	migrations := flag.String("migrations", "migrations", "directory holding the migration files")
+	dryRun := flag.Bool("dry-run", false, "list the pending migrations without applying them")
+	flag.Parse()
+
+	out := os.Stdout
+	// The engine reports its progress on standard output,
+	// which must only hold the migrations applied
+	os.Stdout = os.Stderr
+
+	d, err := db.Open(*dir, nil)
+	if err != nil {
+		fail("failed to open database: %v", err)
+	}
+	defer d.Close()
+
+	applied, err := migrate.Up(d, os.DirFS(*migrations), &migrate.Options{DryRun: *dryRun})
+	verb := "applied"
+	if *dryRun {
+		verb = "pending"
+	}
+	for _, m := range applied {
+		fmt.Fprintf(out, "%s %d_%s\n", verb, m.Version, m.Name)
+	}
+	if err != nil {
+		d.Close()
+		fail("%v", err)
+	}
+	if len(applied) == 0 {
+		fmt.Fprintln(out, "no pending migrations")
+	}
+}
+
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
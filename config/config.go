@@ -1,11 +1,314 @@
 package config
 
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default settings, used for anything not set in the file or the environment
+const (
+	DEFAULT_DATA_DIR         = "centauridb"
+	DEFAULT_LOG_FILE         = "centauridb.log"
+	DEFAULT_BLOCK_SIZE       = 400
+	DEFAULT_BUFFER_COUNT     = 8
+	DEFAULT_LOCK_TIMEOUT     = 10 * time.Second
+	DEFAULT_PIN_TIMEOUT      = 10 * time.Second
+	DEFAULT_SHUTDOWN_TIMEOUT = 30 * time.Second
+	DEFAULT_ADDR             = ":7432"
+	DEFAULT_HTTP_ADDR        = ":7480"
+	DEFAULT_ADMIN_USER       = "admin"
+	DEFAULT_STANDBY_POLL     = time.Second
+	DEFAULT_RETENTION        = 24 * time.Hour
+	DEFAULT_STATS_REFRESH    = time.Minute
+)
+
+// Fsync policies
+const (
+	FSYNC_ALWAYS = "always" // sync every block written to disk
+	FSYNC_NEVER  = "never"  // leave flushing to the operating system
+)
+
+// The environment variable naming the configuration file
+const CONFIG_ENV = "CENTAURI_CONFIG"
+
 // Config holds all configuration for the application
 type Config struct {
-	// Add configuration fields as needed
+	DataDir         string        // directory holding the database files
+	LogDir          string        // directory holding the log file, the data directory if empty
+	LogFile         string        // name of the log file
+	BlockSize       int           // size of a disk block in bytes
+	BufferCount     int           // number of buffers in the buffer pool
+	LockTimeout     time.Duration // how long a transaction waits for a lock before aborting
+	PinTimeout      time.Duration // how long a transaction waits for a free buffer before aborting
+	ShutdownTimeout time.Duration // how long shutdown waits for active transactions to finish
+	Fsync           string        // FSYNC_ALWAYS or FSYNC_NEVER
+	Addr            string        // address of the TCP server
+	HTTPAddr        string        // address of the HTTP server
+	AdminUser       string        // name of the superuser created with a new user catalog
+	AdminPassword   string        // password of that superuser; no superuser is created if empty
+	StandbyLogDir   string        // directory the primary's log is shipped to; runs as a read-only standby if set
+	StandbyPoll     time.Duration // how often a standby looks for new log records
+	AuditFile       string        // file every executed statement is appended to, no audit log if empty
+	SlowQueryTime   time.Duration // statements running at least this long are logged, none if 0
+	SlowQueryFile   string        // file slow statements are appended to, standard output if empty
+	TempQuota       int           // most bytes the temp tables of a statement may take, no limit if 0
+	Retention       time.Duration // how far back queries may read the history of the database, no limit if 0
+	StatsRefresh    time.Duration // how often the statistics of modified tables are recalculated in the background, never if 0
+}
+
+// Returns the default configuration
+func Default() *Config {
+	return &Config{
+		DataDir:         DEFAULT_DATA_DIR,
+		LogFile:         DEFAULT_LOG_FILE,
+		BlockSize:       DEFAULT_BLOCK_SIZE,
+		BufferCount:     DEFAULT_BUFFER_COUNT,
+		LockTimeout:     DEFAULT_LOCK_TIMEOUT,
+		PinTimeout:      DEFAULT_PIN_TIMEOUT,
+		ShutdownTimeout: DEFAULT_SHUTDOWN_TIMEOUT,
+		Fsync:           FSYNC_ALWAYS,
+		Addr:            DEFAULT_ADDR,
+		HTTPAddr:        DEFAULT_HTTP_ADDR,
+		AdminUser:       DEFAULT_ADMIN_USER,
+		StandbyPoll:     DEFAULT_STANDBY_POLL,
+		Retention:       DEFAULT_RETENTION,
+		StatsRefresh:    DEFAULT_STATS_REFRESH,
+	}
+}
+
+// Describes a setting by its key in the configuration file,
+// its environment variable and how to apply a value
+type setting struct {
+	key   string
+	env   string
+	apply func(c *Config, value string) error
+}
+
+var settings = []setting{
+	{"data_dir", "CENTAURI_DATA_DIR", func(c *Config, v string) error { c.DataDir = v; return nil }},
+	{"log_dir", "CENTAURI_LOG_DIR", func(c *Config, v string) error { c.LogDir = v; return nil }},
+	{"log_file", "CENTAURI_LOG_FILE", func(c *Config, v string) error { c.LogFile = v; return nil }},
+	{"block_size", "CENTAURI_BLOCK_SIZE", func(c *Config, v string) error { return parseInt(v, &c.BlockSize) }},
+	{"buffer_count", "CENTAURI_BUFFER_COUNT", func(c *Config, v string) error { return parseInt(v, &c.BufferCount) }},
+	{"lock_timeout", "CENTAURI_LOCK_TIMEOUT", func(c *Config, v string) error { return parseDuration(v, &c.LockTimeout) }},
+	{"pin_timeout", "CENTAURI_PIN_TIMEOUT", func(c *Config, v string) error { return parseDuration(v, &c.PinTimeout) }},
+	{"shutdown_timeout", "CENTAURI_SHUTDOWN_TIMEOUT", func(c *Config, v string) error { return parseDuration(v, &c.ShutdownTimeout) }},
+	{"fsync", "CENTAURI_FSYNC", func(c *Config, v string) error { c.Fsync = strings.ToLower(v); return nil }},
+	{"server.addr", "CENTAURI_ADDR", func(c *Config, v string) error { c.Addr = v; return nil }},
+	{"server.http_addr", "CENTAURI_HTTP_ADDR", func(c *Config, v string) error { c.HTTPAddr = v; return nil }},
+	{"auth.admin_user", "CENTAURI_ADMIN_USER", func(c *Config, v string) error { c.AdminUser = v; return nil }},
+	{"auth.admin_password", "CENTAURI_ADMIN_PASSWORD", func(c *Config, v string) error { c.AdminPassword = v; return nil }},
+	{"standby.log_dir", "CENTAURI_STANDBY_LOG_DIR", func(c *Config, v string) error { c.StandbyLogDir = v; return nil }},
+	{"audit.file", "CENTAURI_AUDIT_FILE", func(c *Config, v string) error { c.AuditFile = v; return nil }},
+	{"slow_query.threshold", "CENTAURI_SLOW_QUERY_THRESHOLD", func(c *Config, v string) error { return parseDuration(v, &c.SlowQueryTime) }},
+	{"slow_query.file", "CENTAURI_SLOW_QUERY_FILE", func(c *Config, v string) error { c.SlowQueryFile = v; return nil }},
+	{"temp_quota", "CENTAURI_TEMP_QUOTA", func(c *Config, v string) error { return parseInt(v, &c.TempQuota) }},
+	{"standby.poll", "CENTAURI_STANDBY_POLL", func(c *Config, v string) error { return parseDuration(v, &c.StandbyPoll) }},
+	{"history_retention", "CENTAURI_HISTORY_RETENTION", func(c *Config, v string) error { return parseDuration(v, &c.Retention) }},
+	{"stats.refresh_interval", "CENTAURI_STATS_REFRESH_INTERVAL", func(c *Config, v string) error { return parseDuration(v, &c.StatsRefresh) }},
+}
+
+// Load loads configuration from environment or files.
+// Settings are taken from the defaults, then the file at path (if path isn't empty),
+// then the CENTAURI_* environment variables, each overriding the previous.
+func Load(path string) (*Config, error) {
+	c := Default()
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open config file: %w", err)
+		}
+		defer f.Close()
+
+		if err := c.parse(bufio.NewScanner(f)); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if err := c.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Parses a configuration file in a subset of TOML: key = value pairs,
+// [section] headers, and comments starting with '#'. Values are quoted
+// strings, integers or durations such as "5s".
+//
+//	data_dir = "/var/lib/centauri"
+//	block_size = 4096
+//	lock_timeout = "5s"
+//
+//	[server]
+//	addr = ":7432"
+func (c *Config) parse(scanner *bufio.Scanner) error {
+	section := ""
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return fmt.Errorf("line %d: invalid section header %q", lineNum, line)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", lineNum)
+		}
+
+		key = strings.TrimSpace(key)
+		if section != "" {
+			key = section + "." + key
+		}
+
+		value, err := unquote(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		if err := c.set(key, value); err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Applies the settings found in the environment
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	for _, s := range settings {
+		if value, ok := lookup(s.env); ok {
+			if err := s.apply(c, value); err != nil {
+				return fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) set(key string, value string) error {
+	for _, s := range settings {
+		if s.key == key {
+			return s.apply(c, value)
+		}
+	}
+	return fmt.Errorf("unknown setting %q", key)
+}
+
+// Checks that the settings are usable
+func (c *Config) Validate() error {
+	if c.DataDir == "" {
+		return fmt.Errorf("data_dir must not be empty")
+	}
+	if c.LogFile == "" {
+		return fmt.Errorf("log_file must not be empty")
+	}
+	if c.BlockSize <= 0 {
+		return fmt.Errorf("block_size must be positive, got %d", c.BlockSize)
+	}
+	if c.BufferCount <= 0 {
+		return fmt.Errorf("buffer_count must be positive, got %d", c.BufferCount)
+	}
+	if c.LockTimeout <= 0 || c.PinTimeout <= 0 || c.ShutdownTimeout <= 0 {
+		return fmt.Errorf("lock_timeout, pin_timeout and shutdown_timeout must be positive")
+	}
+	if c.Fsync != FSYNC_ALWAYS && c.Fsync != FSYNC_NEVER {
+		return fmt.Errorf("fsync must be %q or %q, got %q", FSYNC_ALWAYS, FSYNC_NEVER, c.Fsync)
+	}
+	if c.AdminPassword != "" && c.AdminUser == "" {
+		return fmt.Errorf("admin_user must not be empty when admin_password is set")
+	}
+	if c.SlowQueryTime < 0 {
+		return fmt.Errorf("slow_query threshold must not be negative")
+	}
+	if c.TempQuota < 0 {
+		return fmt.Errorf("temp_quota must not be negative, got %d", c.TempQuota)
+	}
+	if c.Retention < 0 {
+		return fmt.Errorf("history_retention must not be negative")
+	}
+	if c.StatsRefresh < 0 {
+		return fmt.Errorf("stats refresh_interval must not be negative")
+	}
+	if c.StandbyPoll <= 0 {
+		return fmt.Errorf("standby poll must be positive")
+	}
+	if c.StandbyLogDir != "" && c.StandbyLogDir == c.LogDirectory() {
+		return fmt.Errorf("standby log_dir must not be the standby's own log directory")
+	}
+	return nil
+}
+
+// Returns the directory holding the log file
+func (c *Config) LogDirectory() string {
+	if c.LogDir == "" {
+		return c.DataDir
+	}
+	return c.LogDir
+}
+
+// Removes a trailing comment, ignoring '#' inside quoted strings
+func stripComment(line string) string {
+	var quote rune
+	for i, ch := range line {
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(value string) (string, error) {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1], nil
+	}
+	if strings.HasPrefix(value, `"`) {
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", value)
+		}
+		return s, nil
+	}
+	return value, nil
+}
+
+func parseInt(value string, dst *int) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", value)
+	}
+	*dst = n
+	return nil
 }
 
-// Load loads configuration from environment or files
-func Load() (*Config, error) {
-	return &Config{}, nil
+func parseDuration(value string, dst *time.Duration) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", value)
+	}
+	*dst = d
+	return nil
 }
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"centauri/internal/app/plan"
+	"centauri/internal/app/types"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+	"time"
+)
+
+// Inserts rows into the fields of a table in its own transaction and
+// returns the number of rows inserted. See Tx.BulkInsert.
+func (d *DB) BulkInsert(tableName string, fields []string, rows iter.Seq[[]any]) (int, error) {
+	t, err := d.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := t.BulkInsert(tableName, fields, rows)
+	if err != nil {
+		return 0, err
+	}
+	return count, t.Commit()
+}
+
+// Inserts rows into the fields of a table and returns the number of rows
+// inserted. Each row holds a value for each field, an int for INT fields,
+// a string for VARCHAR fields or nil for a null. If fields is empty, the rows hold a value for
+// each field of the table in order.
+//
+// The rows are written through a single table scan that logs only the slots
+// they take, and their index entries are inserted together once all rows are
+// written, which makes this much faster than an INSERT statement per row.
+// If a row can't be inserted, the transaction is rolled back.
+func (t *Tx) BulkInsert(tableName string, fields []string, rows iter.Seq[[]any]) (count int, err error) {
+	if err := t.check(); err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Rollback()
+			count = 0
+			err = fmt.Errorf("bulk insert failed: %w", panicError(r))
+		}
+	}()
+
+	next, stop := iter.Pull(rows)
+	defer stop()
+
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = strings.ToLower(field)
+	}
+
+	source := func() ([]*types.Constant, error) {
+		row, ok := next()
+		if !ok {
+			return nil, io.EOF
+		}
+		return rowValues(row)
+	}
+
+	opts := plan.BulkOptions{BatchSize: 0, Logging: true}
+	loader := plan.NewBulkLoader(t.db.cdb.MdMgr(), nil)
+	count, err = loader.Load(strings.ToLower(tableName), names, source, opts, t.tx)
+	if err != nil {
+		t.Rollback()
+		return 0, err
+	}
+	return count, nil
+}
+
+// Converts the values of a row to constants. An int too large for 32 bits
+// is a 64-bit integer, a float64 a float, a []byte is stored like a string of
+// its bytes, or is null if nil, and a time.Time is a timestamp.
+func rowValues(row []any) ([]*types.Constant, error) {
+	vals := make([]*types.Constant, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			vals[i] = types.NewConstantNull()
+		case int:
+			vals[i] = types.NewConstantInteger(int64(v))
+		case int64:
+			vals[i] = types.NewConstantLong(v)
+		case float64:
+			vals[i] = types.NewConstantFloat(v)
+		case string:
+			vals[i] = types.NewConstantString(v)
+		case []byte:
+			if v == nil {
+				vals[i] = types.NewConstantNull()
+			} else {
+				vals[i] = types.NewConstantString(string(v))
+			}
+		case bool:
+			vals[i] = types.NewConstantBool(v)
+		case time.Time:
+			vals[i] = types.NewConstantTime(v)
+		default:
+			return nil, fmt.Errorf("unsupported value %v of type %T", v, v)
+		}
+	}
+	return vals, nil
+}
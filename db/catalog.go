@@ -0,0 +1,65 @@
+package db
+
+import (
+	"centauri/internal/app/server"
+	"fmt"
+)
+
+// Catalog descriptions returned by ListTables, DescribeTable and ServerVersion.
+// They are the same types the wire protocol sends to network clients.
+type (
+	VersionInfo    = server.VersionInfo
+	TableInfo      = server.TableInfo
+	ColumnInfo     = server.ColumnInfo
+	IndexDesc      = server.IndexDesc
+	ConstraintDesc = server.ConstraintDesc
+	TableDesc      = server.TableDesc
+)
+
+// Kinds of relations listed by ListTables
+const (
+	KIND_TABLE = server.KIND_TABLE
+	KIND_VIEW  = server.KIND_VIEW
+)
+
+// Returns the versions of the server, its catalog format and wire protocol
+func (d *DB) ServerVersion() VersionInfo {
+	return d.cdb.ServerVersion()
+}
+
+// Returns the tables followed by the views of the database
+func (d *DB) ListTables() ([]TableInfo, error) {
+	var tables []TableInfo
+	err := d.inTx(func(t *Tx) error {
+		tables = d.cdb.ListTables(t.tx)
+		return nil
+	})
+	return tables, err
+}
+
+// Describes the columns, indexes and constraints of a table, or the columns of a view
+func (d *DB) DescribeTable(tableName string) (TableDesc, error) {
+	var desc TableDesc
+	err := d.inTx(func(t *Tx) (err error) {
+		desc, err = d.cdb.DescribeTable(tableName, t.tx)
+		return err
+	})
+	return desc, err
+}
+
+// Runs fn in a transaction of its own, which is committed once fn returns
+func (d *DB) inTx(fn func(t *Tx) error) (err error) {
+	t, err := d.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("catalog lookup failed: %w", panicError(r))
+		}
+		t.Commit()
+	}()
+
+	return fn(t)
+}
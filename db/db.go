@@ -0,0 +1,179 @@
+// Package db is the high-level API for embedding CentauriDB in a Go program.
+//
+//	d, err := db.Open("data", nil)
+//	if err != nil { ... }
+//	defer d.Close()
+//
+//	d.Exec("create table students (id int, name varchar(20))")
+//	d.Exec("insert into students (id, name) values (1, 'alice')")
+//
+//	rows, err := d.Query("select id, name from students")
+//	if err != nil { ... }
+//	defer rows.Close()
+//	for rows.Next() {
+//		row := rows.Row()
+//		fmt.Println(row.Int("id"), row.String("name"))
+//	}
+//
+// Exec and Query run each statement in its own transaction. Use Begin
+// to run several statements in a single transaction. Prepare parses a
+// statement once, with ? standing for values given when it is run:
+//
+//	stmt, err := d.Prepare("insert into students (id, name) values (?, ?)")
+//	stmt.Exec(2, "bob")
+package db
+
+import (
+	"centauri/config"
+	"centauri/internal/app/server"
+	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrClosed   = errors.New("database is closed")
+	ErrTxDone   = errors.New("transaction has already been committed or rolled back")
+	ErrRowsOpen = errors.New("rows are still open")
+)
+
+// Configures how a database is opened
+type Options struct {
+	BlockSize  int           // size of a disk block in bytes
+	BufferSize int           // number of buffers in the buffer pool
+	Retention  time.Duration // how far back queries may read the history with AS OF, no limit if 0
+	// How long a transaction waits for a lock before aborting, unless it sets
+	// its own with Tx.SetLockTimeout. tx.MaxWaitTime if 0.
+	LockTimeout time.Duration
+}
+
+// Returns the options used when Open is called with nil options
+func DefaultOptions() *Options {
+	return &Options{
+		BlockSize:  server.BLOCK_SIZE,
+		BufferSize: server.BUFFER_SIZE,
+		Retention:  config.DEFAULT_RETENTION,
+	}
+}
+
+// An open database. It is safe for concurrent use by multiple goroutines.
+type DB struct {
+	cdb    *server.CentauriDB
+	mu     sync.Mutex
+	closed bool
+}
+
+// Opens the database stored in dir, creating it if it doesn't exist.
+// An existing database is recovered before it is returned.
+func Open(dir string, opts *Options) (*DB, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	if opts.BlockSize <= 0 || opts.BufferSize <= 0 {
+		return nil, fmt.Errorf("invalid options: block size and buffer size must be positive")
+	}
+	if opts.Retention < 0 || opts.LockTimeout < 0 {
+		return nil, fmt.Errorf("invalid options: retention and lock timeout must not be negative")
+	}
+
+	cdb, err := server.OpenCentauriDB(dir, opts.BlockSize, opts.BufferSize)
+	if err != nil {
+		return nil, err
+	}
+	cdb.LogMgr().SetRetention(opts.Retention)
+	if opts.LockTimeout > 0 {
+		tx.SetLockTimeoutFor(cdb.FileMgr(), opts.LockTimeout)
+	}
+	return &DB{cdb: cdb}, nil
+}
+
+// Returns the log sequence number of the last change to the database.
+// A query with AS OF and the number reads the database as it is now,
+// even after later changes.
+func (d *DB) LSN() int {
+	return d.cdb.LogMgr().LatestLSN()
+}
+
+// Executes a statement other than a query in its own transaction
+// and returns the number of affected records
+func (d *DB) Exec(sql string) (int, error) {
+	t, err := d.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := t.Exec(sql)
+	if err != nil {
+		return 0, err
+	}
+	return count, t.Commit()
+}
+
+// Executes a query in its own transaction, which is committed
+// when the returned rows are closed
+func (d *DB) Query(sql string) (*Rows, error) {
+	t, err := d.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.Query(sql)
+	if err != nil {
+		return nil, err
+	}
+	rows.commitOnClose = true
+	return rows, nil
+}
+
+// Starts a transaction
+func (d *DB) Begin() (*Tx, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, ErrClosed
+	}
+	t, err := d.cdb.BeginTx()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{db: d, tx: t}, nil
+}
+
+// Copies the database to dir, which must not exist yet, while other
+// goroutines keep using it. Restore turns the copy into a database again.
+func (d *DB) Backup(dir string) error {
+	d.mu.Lock()
+	closed := d.closed
+	d.mu.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+	return d.cdb.Backup(dir)
+}
+
+// Restores a backup taken with DB.Backup to dir, which must be empty
+// or not exist, leaving the database as it was when the backup finished.
+// The options must have the block size of the backed up database.
+func Restore(backupDir string, dir string, opts *Options) error {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	return server.Restore(backupDir, dir, opts.BlockSize, opts.BufferSize)
+}
+
+// Closes the database. Transactions that are still running
+// must be committed or rolled back first.
+func (d *DB) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	return d.cdb.Close()
+}
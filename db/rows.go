@@ -0,0 +1,281 @@
+package db
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record/schema"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// The type of a column's values
+type ColumnType int
+
+const (
+	INT       ColumnType = ColumnType(schema.INTEGER)
+	BIGINT    ColumnType = ColumnType(schema.BIGINT)
+	FLOAT     ColumnType = ColumnType(schema.FLOAT)
+	VARCHAR   ColumnType = ColumnType(schema.VARCHAR)
+	DATE      ColumnType = ColumnType(schema.DATE)
+	TIMESTAMP ColumnType = ColumnType(schema.TIMESTAMP)
+	BOOLEAN   ColumnType = ColumnType(schema.BOOLEAN)
+	BLOB      ColumnType = ColumnType(schema.BLOB)
+)
+
+func (ct ColumnType) String() string {
+	switch ct {
+	case INT:
+		return "int"
+	case VARCHAR:
+		return "varchar"
+	case BIGINT:
+		return "bigint"
+	case FLOAT:
+		return "float"
+	case DATE:
+		return "date"
+	case TIMESTAMP:
+		return "timestamp"
+	case BOOLEAN:
+		return "boolean"
+	case BLOB:
+		return "blob"
+	}
+	return fmt.Sprintf("ColumnType(%d)", int(ct))
+}
+
+// Describes a column of a query result
+type Column struct {
+	Name     string
+	Type     ColumnType
+	Length   int // maximum length of varchar values
+	Nullable bool
+}
+
+// A single record of a query result.
+// Values are int for INT columns, int64 for BIGINT columns, float64 for FLOAT
+// columns, bool for BOOLEAN columns, []byte for BLOB columns and string for
+// VARCHAR, DATE and TIMESTAMP columns, or nil for nulls.
+// Dates are written as "2006-01-02" and timestamps as "2006-01-02 15:04:05", in UTC.
+type Row struct {
+	columns []Column
+	values  []any
+}
+
+// Returns the values of the row in column order
+func (r Row) Values() []any {
+	return r.values
+}
+
+// Returns the value of the named column, or nil if there is no such column
+func (r Row) Value(name string) any {
+	for i, col := range r.columns {
+		if col.Name == name {
+			return r.values[i]
+		}
+	}
+	return nil
+}
+
+// Returns the value of an INT column, or 0 if it isn't one
+func (r Row) Int(name string) int {
+	val, _ := r.Value(name).(int)
+	return val
+}
+
+// Returns the value of a VARCHAR column, or "" if it isn't one
+func (r Row) String(name string) string {
+	val, _ := r.Value(name).(string)
+	return val
+}
+
+// The result of a query. Rows must be closed once they're no longer needed,
+// which releases the scan and, for DB.Query, commits the transaction.
+type Rows struct {
+	t             *Tx
+	scan          interfaces.Scan
+	columns       []Column
+	current       Row
+	err           error
+	closed        bool
+	commitOnClose bool
+}
+
+func newRows(t *Tx, p interfaces.Plan) *Rows {
+	scan := p.Open()
+
+	columns := make([]Column, 0)
+	for _, col := range scan.Schema().Columns() {
+		columns = append(columns, Column{
+			Name:     col.Name,
+			Type:     ColumnType(col.Type),
+			Length:   col.Length,
+			Nullable: col.Nullable,
+		})
+	}
+
+	return &Rows{
+		t:       t,
+		scan:    scan,
+		columns: columns,
+	}
+}
+
+// Returns the columns of the result
+func (rs *Rows) Columns() []Column {
+	return rs.columns
+}
+
+// Advances to the next row, returning false when there are no more rows
+// or an error occurred. The rows are closed once they are exhausted.
+func (rs *Rows) Next() (ok bool) {
+	if rs.closed {
+		return false
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			rs.err = fmt.Errorf("reading rows failed: %w", panicError(r))
+			rs.Close()
+			ok = false
+		}
+	}()
+
+	if !rs.scan.Next() {
+		rs.Close()
+		return false
+	}
+
+	values := make([]any, len(rs.columns))
+	for i, col := range rs.columns {
+		values[i] = plan.FieldValue(rs.scan, col.Name, schema.FieldType(col.Type))
+	}
+	rs.current = Row{columns: rs.columns, values: values}
+	return true
+}
+
+// Returns the row the rows are positioned on
+func (rs *Rows) Row() Row {
+	return rs.current
+}
+
+// Copies the values of the current row into dest, which must hold
+// one *int, *float64, *string or *any for each column
+func (rs *Rows) Scan(dest ...any) error {
+	if len(dest) != len(rs.columns) {
+		return fmt.Errorf("expected %d destinations, got %d", len(rs.columns), len(dest))
+	}
+
+	for i, d := range dest {
+		val := rs.current.values[i]
+
+		switch d := d.(type) {
+		case *any:
+			*d = val
+		case *int:
+			v, ok := val.(int)
+			if !ok {
+				return fmt.Errorf("cannot scan column %s into *int", rs.columns[i].Name)
+			}
+			*d = v
+		case *float64:
+			v, ok := val.(float64)
+			if !ok {
+				return fmt.Errorf("cannot scan column %s into *float64", rs.columns[i].Name)
+			}
+			*d = v
+		case *string:
+			v, ok := val.(string)
+			if !ok {
+				return fmt.Errorf("cannot scan column %s into *string", rs.columns[i].Name)
+			}
+			*d = v
+		default:
+			return fmt.Errorf("unsupported destination type %T", d)
+		}
+	}
+	return nil
+}
+
+// Returns an iterator over the remaining rows, for use with range.
+// Check Err after the loop to tell whether all rows were read.
+func (rs *Rows) All() iter.Seq[Row] {
+	return func(yield func(Row) bool) {
+		defer rs.Close()
+		for rs.Next() {
+			if !yield(rs.current) {
+				return
+			}
+		}
+	}
+}
+
+// Returns the error, if any, that ended the iteration
+func (rs *Rows) Err() error {
+	return rs.err
+}
+
+// Closes the rows. For rows returned by DB.Query this also
+// commits the query's transaction.
+func (rs *Rows) Close() error {
+	if rs.closed {
+		return nil
+	}
+	rs.closed = true
+	rs.scan.Close()
+
+	if rs.t.rows == rs {
+		rs.t.rows = nil
+	}
+	if rs.commitOnClose {
+		if rs.err != nil {
+			return rs.t.Rollback()
+		}
+		return rs.t.Commit()
+	}
+	return nil
+}
+
+// Writes query results in some output format, see NewCSVWriter and NewJSONWriter.
+// Values passed to WriteRow are int for INT columns and string for VARCHAR columns.
+type ResultWriter = plan.ResultWriter
+
+// Describes a column passed to ResultWriter.WriteHeader
+type ResultColumn = plan.ResultColumn
+
+// Returns a ResultWriter producing CSV with a header line
+func NewCSVWriter(w io.Writer) ResultWriter {
+	return plan.NewCSVResultWriter(w)
+}
+
+// Returns a ResultWriter producing a JSON array of objects
+func NewJSONWriter(w io.Writer) ResultWriter {
+	return plan.NewJSONResultWriter(w)
+}
+
+// Writes the remaining rows to rw and closes the rows.
+// Returns the number of rows written.
+func (rs *Rows) Export(rw ResultWriter) (int, error) {
+	defer rs.Close()
+
+	columns := make([]ResultColumn, len(rs.columns))
+	for i, col := range rs.columns {
+		columns[i] = ResultColumn{Name: col.Name, Type: schema.FieldType(col.Type)}
+	}
+	if err := rw.WriteHeader(columns); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for rs.Next() {
+		if err := rw.WriteRow(rs.current.values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if rs.err != nil {
+		return count, rs.err
+	}
+	return count, rw.Close()
+}
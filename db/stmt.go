@@ -0,0 +1,103 @@
+package db
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/plan"
+)
+
+// A statement prepared by DB.Prepare or Tx.Prepare. It is parsed once and
+// can then be run any number of times, with a value for each ? placeholder
+// of its parameters, so values never need to be written into the text of
+// a statement:
+//
+//	stmt, err := d.Prepare("select name from students where id = ?")
+//	if err != nil { ... }
+//	rows, err := stmt.Query(1)
+//
+// The values are an int for an INT field, a string for a VARCHAR field
+// or nil for a null. Only queries, INSERT, UPDATE and DELETE statements
+// may have parameters.
+type Stmt struct {
+	db *DB
+	tx *Tx // the transaction the statement runs in, nil to run each execution in its own
+	ps *plan.PreparedStatement
+}
+
+// Prepares a statement that runs in its own transaction each time
+func (d *DB) Prepare(sql string) (*Stmt, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, ErrClosed
+	}
+	ps, err := d.cdb.Planner().Prepare(sql)
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{db: d, ps: ps}, nil
+}
+
+// Prepares a statement that runs in the transaction
+func (t *Tx) Prepare(sql string) (*Stmt, error) {
+	if t.done {
+		return nil, ErrTxDone
+	}
+	ps, err := t.db.cdb.Planner().Prepare(sql)
+	if err != nil {
+		return nil, err
+	}
+	return &Stmt{db: t.db, tx: t, ps: ps}, nil
+}
+
+// Returns the number of parameters the statement takes a value for
+func (s *Stmt) NumInput() int {
+	return s.ps.NumParams()
+}
+
+// Runs a statement other than a query with the values of its parameters
+// and returns the number of affected records
+func (s *Stmt) Exec(args ...any) (int, error) {
+	params, err := rowValues(args)
+	if err != nil {
+		return 0, err
+	}
+
+	t := s.tx
+	if t == nil {
+		if t, err = s.db.Begin(); err != nil {
+			return 0, err
+		}
+	}
+	count, err := t.exec(func() (int, error) {
+		return s.db.cdb.Planner().ExecutePreparedAs(s.ps, params, nil, t.tx)
+	})
+	if err != nil || s.tx != nil {
+		return count, err
+	}
+	return count, t.Commit()
+}
+
+// Runs a query with the values of its parameters. Outside of a
+// transaction, the query runs in its own, committed when the rows are closed.
+func (s *Stmt) Query(args ...any) (*Rows, error) {
+	params, err := rowValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	t := s.tx
+	if t == nil {
+		if t, err = s.db.Begin(); err != nil {
+			return nil, err
+		}
+	}
+	rows, err := t.query(func() (interfaces.Plan, error) {
+		return s.db.cdb.Planner().CreatePreparedPlanAs(s.ps, params, nil, t.tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	rows.commitOnClose = s.tx == nil
+	return rows, nil
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/tx"
+	"fmt"
+	"time"
+)
+
+// A transaction started by DB.Begin. A transaction must end with
+// a call to Commit or Rollback and is not safe for concurrent use.
+type Tx struct {
+	db   *DB
+	tx   *tx.Transaction
+	rows *Rows // rows currently open in this transaction
+	done bool
+}
+
+// Executes a statement other than a query and returns the number of affected records.
+// If the statement fails, the transaction is rolled back.
+func (t *Tx) Exec(sql string) (count int, err error) {
+	return t.exec(func() (int, error) {
+		return t.db.cdb.Planner().ExecuteUpdate(sql, t.tx)
+	})
+}
+
+// Runs an update command, rolling back the transaction if it fails
+func (t *Tx) exec(run func() (int, error)) (count int, err error) {
+	if err := t.check(); err != nil {
+		return 0, err
+	}
+
+	// The planners panic on some invalid input,
+	// which is reported as an error instead
+	defer func() {
+		if r := recover(); r != nil {
+			t.Rollback()
+			err = fmt.Errorf("statement failed: %w", panicError(r))
+		}
+	}()
+
+	count, err = run()
+	if err != nil {
+		t.Rollback()
+		return 0, err
+	}
+	return count, nil
+}
+
+// Returns the first AUTO_INCREMENT value generated by the last insert
+// of the transaction that generated one, or 0 if none did
+func (t *Tx) LastInsertID() int64 {
+	return t.tx.LastInsertID()
+}
+
+// Executes a query, SHOW TABLES or DESCRIBE. The rows must be closed
+// before the next statement runs in this transaction. If planning fails,
+// the transaction is rolled back.
+func (t *Tx) Query(sql string) (rows *Rows, err error) {
+	return t.query(func() (interfaces.Plan, error) {
+		return t.db.cdb.CreateQueryPlan(sql, t.tx)
+	})
+}
+
+// Plans a query and opens its rows, rolling back the transaction if planning fails
+func (t *Tx) query(plan func() (interfaces.Plan, error)) (rows *Rows, err error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Rollback()
+			rows = nil
+			err = fmt.Errorf("statement failed: %w", panicError(r))
+		}
+	}()
+
+	p, err := plan()
+	if err != nil {
+		t.Rollback()
+		return nil, err
+	}
+
+	t.rows = newRows(t, p)
+	return t.rows, nil
+}
+
+// A lock timeout for Tx.SetLockTimeout that makes statements fail with
+// tx.ErrLockNotAvailable rather than wait for another transaction's locks
+const NOWAIT = tx.NOWAIT
+
+// Sets how long the statements of the transaction wait for a lock before
+// failing, or NOWAIT for not waiting at all
+func (t *Tx) SetLockTimeout(timeout time.Duration) error {
+	if t.done {
+		return ErrTxDone
+	}
+	t.tx.SetLockTimeout(timeout)
+	return nil
+}
+
+// Commits the transaction, closing any open rows
+func (t *Tx) Commit() error {
+	if t.done {
+		return ErrTxDone
+	}
+	t.closeRows()
+	t.done = true
+	t.tx.Commit()
+	return nil
+}
+
+// Rolls back the transaction, closing any open rows
+func (t *Tx) Rollback() error {
+	if t.done {
+		return ErrTxDone
+	}
+	t.closeRows()
+	t.done = true
+	t.tx.Rollback()
+	return nil
+}
+
+func (t *Tx) check() error {
+	if t.done {
+		return ErrTxDone
+	}
+	if t.rows != nil {
+		return ErrRowsOpen
+	}
+	return nil
+}
+
+func (t *Tx) closeRows() {
+	if t.rows != nil {
+		rows := t.rows
+		t.rows = nil
+		rows.commitOnClose = false
+		rows.Close()
+	}
+}
+
+// Returns the error a recovered panic holds, so that errors such as
+// tx.DeadlockError can be told apart
+func panicError(r any) error {
+	if err, ok := r.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", r)
+}
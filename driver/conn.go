@@ -0,0 +1,497 @@
+package driver
+
+import (
+	"bufio"
+	"centauri/internal/app/server"
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// A failure to talk to the server, after which the connection is broken
+type connError struct {
+	err error
+}
+
+func (e *connError) Error() string {
+	return "connection failed: " + e.err.Error()
+}
+
+func (e *connError) Unwrap() error {
+	return e.err
+}
+
+// A connection to the server, logged in as the configured user.
+// database/sql uses a connection from one goroutine at a time.
+type conn struct {
+	cfg       *Config
+	nc        net.Conn
+	r         *bufio.Reader
+	w         *bufio.Writer
+	sessionID int
+	cancelKey int
+	session   []string // SET and USE statements run so far, replayed when redialing
+	inTx      bool     // a transaction started with BEGIN is open on the server
+	broken    bool
+	lastUsed  time.Time
+}
+
+// Connects and logs in, then restores the session's database and settings
+func (c *conn) dial(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: c.cfg.ConnectTimeout}
+	nc, err := dialer.DialContext(ctx, "tcp", c.cfg.Addr)
+	if err != nil {
+		return &connError{err: err}
+	}
+
+	c.nc = nc
+	c.r = bufio.NewReader(nc)
+	c.w = bufio.NewWriter(nc)
+	c.broken = false
+
+	// Logging in must not hang on a server that accepts but doesn't answer
+	nc.SetDeadline(time.Now().Add(c.cfg.ConnectTimeout))
+	if err := c.login(); err != nil {
+		c.close()
+		return err
+	}
+	nc.SetDeadline(time.Time{})
+
+	var restore []string
+	if c.cfg.Database != "" {
+		restore = append(restore, "use "+c.cfg.Database)
+	}
+	for _, cmd := range append(restore, c.session...) {
+		if _, err := c.exec(cmd); err != nil {
+			c.close()
+			return fmt.Errorf("failed to restore session with %q: %w", cmd, err)
+		}
+	}
+
+	c.lastUsed = time.Now()
+	return nil
+}
+
+func (c *conn) login() error {
+	if err := c.send(server.MSG_AUTH, server.EncodeAuth(c.cfg.User, c.cfg.Password)); err != nil {
+		return err
+	}
+	msgType, payload, err := c.read()
+	if err != nil {
+		return err
+	}
+
+	switch msgType {
+	case server.MSG_AUTH_OK:
+		c.sessionID, c.cancelKey, err = server.DecodeAuthOK(payload)
+		if err != nil {
+			return c.fail(err)
+		}
+		return nil
+	case server.MSG_ERROR:
+		return &Error{Message: string(payload)}
+	default:
+		return c.fail(fmt.Errorf("unexpected message %q", msgType))
+	}
+}
+
+// Drops a broken connection and dials a new one
+func (c *conn) redial(ctx context.Context) error {
+	c.close()
+	return c.dial(ctx)
+}
+
+// Marks the connection as broken after a failure to talk to the server
+func (c *conn) fail(err error) error {
+	c.broken = true
+	c.close()
+	return &connError{err: err}
+}
+
+func (c *conn) close() {
+	if c.nc != nil {
+		c.nc.Close()
+		c.nc = nil
+	}
+}
+
+func (c *conn) send(msgType byte, payload []byte) error {
+	if c.nc == nil {
+		c.broken = true
+		return &connError{err: net.ErrClosed}
+	}
+	if err := server.WriteMessage(c.w, msgType, payload); err != nil {
+		return c.fail(err)
+	}
+	if err := c.w.Flush(); err != nil {
+		return c.fail(err)
+	}
+	return nil
+}
+
+func (c *conn) read() (byte, []byte, error) {
+	msgType, payload, err := server.ReadMessage(c.r)
+	if err != nil {
+		return 0, nil, c.fail(err)
+	}
+	return msgType, payload, nil
+}
+
+// The result of a statement other than a query
+type result struct {
+	rowsAffected int64
+	lastInsertID int64
+}
+
+// Returns the first AUTO_INCREMENT value generated by the last insert
+// of the session that generated one, 0 if none did
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// Runs a statement, skipping the records of a query, and returns
+// the number of affected records and the last insert id
+func (c *conn) exec(cmd string) (result, error) {
+	if err := c.send(server.MSG_QUERY, []byte(cmd)); err != nil {
+		return result{}, err
+	}
+	for {
+		msgType, payload, err := c.read()
+		if err != nil {
+			return result{}, err
+		}
+
+		switch msgType {
+		case server.MSG_ROW_DESCRIPTION, server.MSG_DATA_ROW:
+		case server.MSG_COMMAND_COMPLETE:
+			count, lastInsertID, err := server.DecodeCommandComplete(payload)
+			if err != nil {
+				return result{}, c.fail(err)
+			}
+			return result{rowsAffected: int64(count), lastInsertID: lastInsertID}, nil
+		case server.MSG_ERROR:
+			return result{}, &Error{Message: string(payload)}
+		default:
+			return result{}, c.fail(fmt.Errorf("unexpected message %q", msgType))
+		}
+	}
+}
+
+// Runs a statement and returns its records. The first message after the
+// row description is read before returning, so a statement that fails
+// before producing a record can still be retried.
+func (c *conn) query(cmd string) (*rows, error) {
+	if err := c.send(server.MSG_QUERY, []byte(cmd)); err != nil {
+		return nil, err
+	}
+
+	msgType, payload, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	rs := &rows{c: c}
+	switch msgType {
+	case server.MSG_ROW_DESCRIPTION:
+		if rs.cols, err = server.DecodeRowDescription(payload); err != nil {
+			return nil, c.fail(err)
+		}
+	case server.MSG_COMMAND_COMPLETE:
+		// Not a query, so there are no records
+		rs.done = true
+		return rs, nil
+	case server.MSG_ERROR:
+		return nil, &Error{Message: string(payload)}
+	default:
+		return nil, c.fail(fmt.Errorf("unexpected message %q", msgType))
+	}
+
+	if rs.nextType, rs.nextPayload, err = c.read(); err != nil {
+		return nil, err
+	}
+	if rs.nextType == server.MSG_ERROR {
+		return nil, &Error{Message: string(rs.nextPayload)}
+	}
+	return rs, nil
+}
+
+// Runs fn, running it again if it fails on a transient error and the
+// statement is read-only. A broken connection is redialed first.
+// Inside a transaction fn runs once: the transaction is gone once the
+// connection breaks and a failed statement may have rolled it back.
+func (c *conn) retry(ctx context.Context, readOnly bool, fn func() error) error {
+	if c.inTx {
+		return c.run(ctx, fn)
+	}
+	for attempt := 0; ; attempt++ {
+		var err error
+		if c.broken || c.nc == nil {
+			err = c.redial(ctx)
+		}
+		if err == nil {
+			err = c.run(ctx, fn)
+		}
+		if err == nil || !readOnly || !transient(err) || attempt >= c.cfg.Retries {
+			return err
+		}
+
+		backoff := c.cfg.RetryBackoff << attempt
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// Runs fn, cancelling the statement on the server if ctx is done first
+func (c *conn) run(ctx context.Context, fn func() error) error {
+	stop := context.AfterFunc(ctx, c.cancel)
+	defer stop()
+
+	err := fn()
+	c.lastUsed = time.Now()
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Asks the server to cancel the running statement, over a connection of
+// its own as the protocol requires
+func (c *conn) cancel() {
+	nc, err := net.DialTimeout("tcp", c.cfg.Addr, c.cfg.ConnectTimeout)
+	if err != nil {
+		return
+	}
+	defer nc.Close()
+	server.WriteMessage(nc, server.MSG_CANCEL, server.EncodeCancel(c.sessionID, c.cancelKey))
+}
+
+// Reports whether a statement only reads, so it can be retried safely
+func readOnly(cmd string) bool {
+	fields := strings.Fields(cmd)
+	return len(fields) > 0 && (strings.EqualFold(fields[0], "select") || strings.EqualFold(fields[0], "show") ||
+		strings.EqualFold(fields[0], "explain") || strings.EqualFold(fields[0], "describe") || strings.EqualFold(fields[0], "desc"))
+}
+
+// Reports whether a statement changes the state of the session,
+// which must be restored when the connection is redialed
+func changesSession(cmd string) bool {
+	fields := strings.Fields(cmd)
+	return len(fields) > 0 && (strings.EqualFold(fields[0], "set") || strings.EqualFold(fields[0], "use"))
+}
+
+// Reports whether a statement starts a transaction or ends one
+func txControl(cmd string) (begins bool, ends bool) {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return false, false
+	}
+	begins = strings.EqualFold(fields[0], "begin") || strings.EqualFold(fields[0], "start")
+	ends = strings.EqualFold(fields[0], "commit") || strings.EqualFold(fields[0], "rollback")
+	return begins, ends
+}
+
+// Keeps track of the transaction a statement started or ended.
+// A statement ending it does so even if it fails, and a connection
+// that broke has lost it.
+func (c *conn) trackTx(cmd string, err error) {
+	begins, ends := txControl(cmd)
+	switch {
+	case c.broken || ends:
+		c.inTx = false
+	case begins && err == nil:
+		c.inTx = true
+	}
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Result, error) {
+	if len(args) > 0 {
+		return nil, ErrArgsNotSupported
+	}
+
+	var res result
+	err := c.retry(ctx, readOnly(query), func() (err error) {
+		res, err = c.exec(query)
+		return err
+	})
+	c.trackTx(query, err)
+	if err != nil {
+		return nil, err
+	}
+
+	if changesSession(query) {
+		c.session = append(c.session, query)
+	}
+	return res, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	if len(args) > 0 {
+		return nil, ErrArgsNotSupported
+	}
+
+	var rs *rows
+	err := c.retry(ctx, readOnly(query), func() (err error) {
+		rs, err = c.query(query)
+		return err
+	})
+	c.trackTx(query, err)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rest of the records are read after QueryContext returns
+	if !rs.done {
+		rs.stop = context.AfterFunc(ctx, c.cancel)
+	}
+	return rs, nil
+}
+
+// Checks that the server still answers on the connection
+func (c *conn) Ping(ctx context.Context) error {
+	if c.broken || c.nc == nil {
+		return sqldriver.ErrBadConn
+	}
+
+	err := c.run(ctx, func() error {
+		if err := c.send(server.MSG_METADATA, server.EncodeMetadataRequest(server.METADATA_SERVER_VERSION, "")); err != nil {
+			return err
+		}
+		msgType, payload, err := c.read()
+		if err != nil {
+			return err
+		}
+		if msgType == server.MSG_ERROR {
+			return &Error{Message: string(payload)}
+		}
+		return nil
+	})
+	if c.broken {
+		return sqldriver.ErrBadConn
+	}
+	return err
+}
+
+// Called by the pool before a connection is reused. A connection that
+// has been idle for longer than the health check interval is pinged, so
+// one the server has closed is dropped rather than handed out. A
+// transaction left open with a BEGIN statement is rolled back.
+func (c *conn) ResetSession(ctx context.Context) error {
+	if c.broken || c.nc == nil {
+		return sqldriver.ErrBadConn
+	}
+	if c.inTx {
+		if _, err := c.ExecContext(ctx, "rollback", nil); err != nil {
+			return sqldriver.ErrBadConn
+		}
+	}
+	if c.cfg.HealthCheck > 0 && time.Since(c.lastUsed) >= c.cfg.HealthCheck {
+		if err := c.Ping(ctx); err != nil {
+			return sqldriver.ErrBadConn
+		}
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	return !c.broken && c.nc != nil
+}
+
+func (c *conn) Prepare(query string) (sqldriver.Stmt, error) {
+	return &stmt{c: c, query: query}, nil
+}
+
+func (c *conn) Begin() (sqldriver.Tx, error) {
+	return c.BeginTx(context.Background(), sqldriver.TxOptions{})
+}
+
+// Starts a transaction on the server. It may write, and runs at read
+// committed, repeatable read, snapshot or serializable isolation. The default
+// level is the session's, which is serializable unless it was SET.
+func (c *conn) BeginTx(ctx context.Context, opts sqldriver.TxOptions) (sqldriver.Tx, error) {
+	begin := "begin"
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault:
+	case sql.LevelReadCommitted:
+		begin += " isolation level read committed"
+	case sql.LevelRepeatableRead:
+		begin += " isolation level repeatable read"
+	case sql.LevelSnapshot:
+		begin += " isolation level snapshot"
+	case sql.LevelSerializable:
+		begin += " isolation level serializable"
+	default:
+		return nil, ErrTxOptionsNotSupported
+	}
+	if opts.ReadOnly {
+		return nil, ErrTxOptionsNotSupported
+	}
+	if _, err := c.ExecContext(ctx, begin, nil); err != nil {
+		return nil, err
+	}
+	return &connTx{c: c}, nil
+}
+
+func (c *conn) Close() error {
+	if c.nc == nil {
+		return nil
+	}
+	c.send(server.MSG_TERMINATE, nil)
+	c.close()
+	return nil
+}
+
+// A transaction started with BEGIN on the connection
+type connTx struct {
+	c *conn
+}
+
+func (t *connTx) Commit() error {
+	return t.end("commit")
+}
+
+func (t *connTx) Rollback() error {
+	return t.end("rollback")
+}
+
+// Sends the statement ending the transaction, unless the connection
+// broke and the transaction went with it
+func (t *connTx) end(cmd string) error {
+	if !t.c.inTx {
+		return ErrTxAborted
+	}
+	_, err := t.c.ExecContext(context.Background(), cmd, nil)
+	return err
+}
+
+// A statement prepared on the client only, sent again on every execution
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return 0
+}
+
+func (s *stmt) Exec(args []sqldriver.Value) (sqldriver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, nil)
+}
+
+func (s *stmt) Query(args []sqldriver.Value) (sqldriver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, nil)
+}
@@ -0,0 +1,86 @@
+package driver
+
+import (
+	"centauri/internal/app/server"
+	sqldriver "database/sql/driver"
+	"fmt"
+	"io"
+	"time"
+)
+
+// The records of a query, read from the connection as they're needed
+type rows struct {
+	c           *conn
+	cols        []server.ColumnDesc
+	nextType    byte // message read ahead by the query, 0 once it's consumed
+	nextPayload []byte
+	done        bool
+	stop        func() bool // stops waiting for the query's context
+}
+
+func (rs *rows) Columns() []string {
+	names := make([]string, len(rs.cols))
+	for i, col := range rs.cols {
+		names[i] = col.Name
+	}
+	return names
+}
+
+func (rs *rows) Next(dest []sqldriver.Value) error {
+	if rs.done {
+		return io.EOF
+	}
+
+	msgType, payload, err := rs.nextType, rs.nextPayload, error(nil)
+	if msgType != 0 {
+		rs.nextType, rs.nextPayload = 0, nil
+	} else if msgType, payload, err = rs.c.read(); err != nil {
+		rs.finish()
+		return err
+	}
+
+	switch msgType {
+	case server.MSG_DATA_ROW:
+		values, err := server.DecodeDataRow(payload)
+		if err != nil {
+			rs.finish()
+			return rs.c.fail(err)
+		}
+		for i, val := range values {
+			if n, ok := val.(int); ok {
+				dest[i] = int64(n)
+			} else {
+				dest[i] = val
+			}
+		}
+		return nil
+	case server.MSG_COMMAND_COMPLETE:
+		rs.finish()
+		return io.EOF
+	case server.MSG_ERROR:
+		rs.finish()
+		return &Error{Message: string(payload)}
+	default:
+		rs.finish()
+		return rs.c.fail(fmt.Errorf("unexpected message %q", msgType))
+	}
+}
+
+// Reads the records that haven't been read, so the connection can
+// be used for the next statement
+func (rs *rows) Close() error {
+	for !rs.done {
+		if err := rs.Next(make([]sqldriver.Value, len(rs.cols))); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (rs *rows) finish() {
+	rs.done = true
+	rs.c.lastUsed = time.Now()
+	if rs.stop != nil {
+		rs.stop()
+	}
+}
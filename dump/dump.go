@@ -0,0 +1,253 @@
+// Package dump exports the schema and data of a database as SQL statements
+// that recreate it when run against an empty database, e.g. with
+// centauri-cli. It's used for logical backups and for moving data to
+// other systems.
+//
+//	d, err := db.Open("data", nil)
+//	if err != nil { ... }
+//	defer d.Close()
+//
+//	err = dump.Dump(d, os.Stdout, nil)
+//
+// Tables are created first, then filled, then indexed, so the indexes are
+// built once rather than updated for every record. Views come last.
+package dump
+
+import (
+	"centauri/db"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Ways the records of a table are written
+const (
+	FORMAT_INSERT = "insert" // an INSERT statement per record
+	FORMAT_COPY   = "copy"   // a CSV file per table, loaded with COPY FROM
+)
+
+// Configures what Dump writes
+type Options struct {
+	Format     string   // FORMAT_INSERT or FORMAT_COPY
+	CopyDir    string   // directory the CSV files are written to with FORMAT_COPY
+	Tables     []string // tables and views to dump, all of them if empty
+	SchemaOnly bool     // leaves out the records
+	DataOnly   bool     // leaves out the CREATE statements
+}
+
+// Returns the options used when Dump is called with nil options
+func DefaultOptions() *Options {
+	return &Options{
+		Format:  FORMAT_INSERT,
+		CopyDir: ".",
+	}
+}
+
+// Writes the statements recreating the database to w.
+// The records are read in a single transaction.
+func Dump(d *db.DB, w io.Writer, opts *Options) error {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	if opts.Format != FORMAT_INSERT && opts.Format != FORMAT_COPY {
+		return fmt.Errorf("unsupported format %q", opts.Format)
+	}
+	if opts.SchemaOnly && opts.DataOnly {
+		return fmt.Errorf("schema only and data only can't be combined")
+	}
+
+	tables, views, err := describe(d, opts.Tables)
+	if err != nil {
+		return err
+	}
+
+	version := d.ServerVersion()
+	fmt.Fprintf(w, "-- CentauriDB dump\n-- server version %s, catalog version %d\n\n", version.Server, version.Catalog)
+
+	if !opts.DataOnly {
+		for _, desc := range tables {
+			fmt.Fprintf(w, "%s;\n", CreateTable(desc))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if !opts.SchemaOnly {
+		t, err := d.Begin()
+		if err != nil {
+			return err
+		}
+		defer t.Commit()
+
+		for _, desc := range tables {
+			if err := dumpRecords(t, w, desc, opts); err != nil {
+				return fmt.Errorf("failed to dump %s: %w", desc.Name, err)
+			}
+		}
+	}
+
+	if !opts.DataOnly {
+		for _, desc := range tables {
+			for _, idx := range desc.Indexes {
+				unique := ""
+				if idx.Unique {
+					unique = "unique "
+				}
+				using := ""
+				if idx.Type == "btree" {
+					using = " using btree"
+				}
+				fmt.Fprintf(w, "create %sindex %s on %s (%s)%s;\n", unique, idx.Name, desc.Name, strings.Join(idx.Fields, ", "), using)
+			}
+		}
+		for _, desc := range views {
+			fmt.Fprintf(w, "create view %s as %s;\n", desc.Name, desc.Definition)
+		}
+	}
+	return nil
+}
+
+// Describes the tables and views to dump, in the order they were created
+func describe(d *db.DB, names []string) (tables []db.TableDesc, views []db.TableDesc, err error) {
+	if len(names) == 0 {
+		infos, err := d.ListTables()
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, info := range infos {
+			names = append(names, info.Name)
+		}
+	}
+
+	for _, name := range names {
+		desc, err := d.DescribeTable(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if desc.Kind == db.KIND_VIEW {
+			views = append(views, desc)
+		} else {
+			tables = append(tables, desc)
+		}
+	}
+	return tables, views, nil
+}
+
+// Returns the CREATE TABLE statement of a table
+func CreateTable(desc db.TableDesc) string {
+	cols := make([]string, len(desc.Columns))
+	for i, col := range desc.Columns {
+		cols[i] = col.Name + " " + columnType(col)
+		if col.NotNull {
+			cols[i] += " not null"
+		}
+		if col.Default != "" {
+			cols[i] += " default " + col.Default
+		}
+		if col.AutoIncrement {
+			cols[i] += " auto_increment"
+		}
+		if col.Generated != "" {
+			cols[i] += fmt.Sprintf(" as (%s) %s", col.Generated, generatedKind(col))
+		}
+	}
+	stmt := fmt.Sprintf("create table %s (%s)", desc.Name, strings.Join(cols, ", "))
+	if desc.Partitions != "" {
+		stmt += " " + desc.Partitions
+	}
+	return stmt
+}
+
+// Returns the SQL type of a column as written in CREATE TABLE
+func columnType(col db.ColumnInfo) string {
+	if db.ColumnType(col.Type) == db.VARCHAR {
+		if col.Collation != "" {
+			return fmt.Sprintf("varchar(%d) collate %s", col.Length, Quote(col.Collation))
+		}
+		return fmt.Sprintf("varchar(%d)", col.Length)
+	}
+	return db.ColumnType(col.Type).String()
+}
+
+func generatedKind(col db.ColumnInfo) string {
+	if col.Stored {
+		return "stored"
+	}
+	return "virtual"
+}
+
+// Writes the statements that load the records of a table.
+// Generated columns are left out, they are computed again as the records load.
+func dumpRecords(t *db.Tx, w io.Writer, desc db.TableDesc, opts *Options) error {
+	var fields []string
+	for _, col := range desc.Columns {
+		if col.Generated == "" {
+			fields = append(fields, col.Name)
+		}
+	}
+	fieldList := strings.Join(fields, ", ")
+
+	rows, err := t.Query(fmt.Sprintf("select %s from %s", fieldList, desc.Name))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if opts.Format == FORMAT_COPY {
+		path := filepath.Join(opts.CopyDir, desc.Name+".csv")
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := rows.Export(db.NewCSVWriter(f)); err != nil {
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "copy %s (%s) from %s with (header=on);\n\n", desc.Name, fieldList, Quote(path))
+		return nil
+	}
+
+	count := 0
+	for rows.Next() {
+		values := rows.Row().Values()
+		literals := make([]string, len(values))
+		for i, val := range values {
+			literals[i] = Literal(val)
+		}
+		fmt.Fprintf(w, "insert into %s (%s) values (%s);\n", desc.Name, fieldList, strings.Join(literals, ", "))
+		count++
+	}
+	if count > 0 {
+		fmt.Fprintln(w)
+	}
+	return rows.Err()
+}
+
+// Returns a value as an SQL literal
+func Literal(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return "NULL"
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return Quote(v)
+	case []byte:
+		return Quote(string(v))
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// Returns s as a quoted string literal, doubling the quotes inside it
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
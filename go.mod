@@ -2,8 +2,9 @@ module centauri
 
 go 1.23.2
 
+require golang.org/x/text v0.23.0
+
 require (
 	github.com/stretchr/testify v1.10.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
-	golang.org/x/text v0.23.0 // indirect
 )
@@ -1,19 +1,97 @@
 package app
 
-import "fmt"
+import (
+	"centauri/config"
+	"centauri/internal/app/server"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const DB_DIR = config.DEFAULT_DATA_DIR
 
 // App represents the main application structure
 type App struct {
-	// Add fields as needed
+	cfg *config.Config
 }
 
-// New creates a new instance of App
-func New() *App {
-	return &App{}
+// New creates a new instance of App with the given configuration,
+// or the default configuration if cfg is nil
+func New(cfg *config.Config) *App {
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	return &App{cfg: cfg}
 }
 
-// Run starts the application
+// Run opens the database, recovering it if it already exists, and
+// serves clients over TCP and HTTP until either server fails or the
+// process receives SIGINT or SIGTERM. It then shuts down gracefully.
 func (a *App) Run() error {
-	fmt.Println("Good to go")
-	return nil
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return a.RunContext(ctx)
+}
+
+// RunContext is like Run, but shuts down when ctx is done instead of on a signal
+func (a *App) RunContext(ctx context.Context) error {
+	// Opening the database runs recovery before any client is served
+	db, err := server.OpenCentauriDBFromConfig(a.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	errs := make(chan error, 2)
+
+	srv := server.NewTCPServer(db, a.cfg.Addr)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	httpSrv := server.NewHTTPServer(db, a.cfg.HTTPAddr)
+	go func() {
+		errs <- httpSrv.ListenAndServe()
+	}()
+
+	fmt.Printf("Listening on %s (HTTP on %s)\n", a.cfg.Addr, a.cfg.HTTPAddr)
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down...")
+	case runErr = <-errs:
+	}
+
+	if err := a.shutdown(db, srv, httpSrv); err != nil && runErr == nil {
+		runErr = err
+	}
+	return runErr
+}
+
+// Stops the servers and shuts the database down, giving the
+// transactions still running until the shutdown timeout to finish
+func (a *App) shutdown(db *server.CentauriDB, srv *server.TCPServer, httpSrv *server.HTTPServer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+
+	// Stop accepting connections first; in-flight requests are allowed to finish
+	if err := httpSrv.Shutdown(ctx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, fmt.Errorf("http server: %w", err))
+	}
+	if err := srv.Close(); err != nil && !errors.Is(err, server.ErrServerClosed) {
+		errs = append(errs, fmt.Errorf("tcp server: %w", err))
+	}
+
+	if err := db.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("database: %w", err))
+	}
+	return errors.Join(errs...)
 }
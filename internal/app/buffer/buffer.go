@@ -69,10 +69,14 @@ func (b *Buffer) ModifyingTx() int {
 
 // Reads the contents of the specified block into
 // to the contents of the buffer. If the buffer was dirty, then its previous
-// contents are first written to disk.
+// contents are first written to disk. The page is resized if the file of
+// the block has blocks of another size than the previous one.
 func (b *Buffer) AssignToBlock(block *file.BlockID) {
 	b.Flush()
 	b.block = block
+	if size := b.fm.FileBlockSize(block.FileName()); len(b.contents.Contents()) != size {
+		b.contents = file.NewPage(size)
+	}
 	b.fm.Read(block, b.contents)
 	b.pins = 0
 }
@@ -42,6 +42,13 @@ func NewBufferManager(fm *file.FileManager, lm *log.LogManager, numBuffs int) *B
 	return bm
 }
 
+// Sets how long Pin waits for a buffer to become available before aborting
+func (bm *BufferManager) SetMaxWaitTime(timeout time.Duration) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.maxWaitTime = timeout
+}
+
 // Returns the number of available(i.e, unpinned buffers)
 func (bm *BufferManager) Available() int {
 	bm.mu.Lock()
@@ -62,6 +69,30 @@ func (bm *BufferManager) FlushAll(txNum int) {
 	}
 }
 
+// Flushes every dirty buffer, whichever transaction modified it
+func (bm *BufferManager) FlushAllBuffers() {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	for _, buffer := range bm.bufferPool {
+		buffer.Flush()
+	}
+}
+
+// Unassigns the unpinned buffers holding blocks of the specified file,
+// dropping their changes without writing them, e.g. before the file is removed
+func (bm *BufferManager) Discard(filename string) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+
+	for _, buffer := range bm.bufferPool {
+		if b := buffer.Block(); b != nil && b.FileName() == filename && !buffer.IsPinned() {
+			buffer.block = nil
+			buffer.txnum = -1
+		}
+	}
+}
+
 // unpins the specified data buffer
 // If it`s pin count goes to zero, then notify any waiting threads
 func (bm *BufferManager) Unpin(buff *Buffer) {
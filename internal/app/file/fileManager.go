@@ -2,17 +2,25 @@ package file
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 )
 
+// A file whose blocks don't have the database block size carries its block
+// size in its name, after the separator, as in orders@800.tbl, so that the
+// size is known before the catalog is read, e.g. during recovery.
+const BLOCK_SIZE_SEPARATOR = "@"
+
 type FileManager struct {
 	dbDirectory string              // Directory where database files are stored
 	blockSize   int                 // Size of each block in bytes
 	isNew       bool                // Indicates if database is new
 	openFiles   map[string]*os.File // Cache of open files for quick access
+	syncWrites  bool                // Whether each write is synced to disk
 	mu          sync.Mutex          // Mutex for thread safety
 }
 
@@ -23,6 +31,7 @@ func NewFileManager(dbDirectory string, blockSize int) (*FileManager, error) {
 		dbDirectory: dbDirectory,
 		blockSize:   blockSize,
 		openFiles:   make(map[string]*os.File),
+		syncWrites:  true,
 	}
 
 	// Check if database is new
@@ -39,19 +48,23 @@ func NewFileManager(dbDirectory string, blockSize int) (*FileManager, error) {
 		return nil, fmt.Errorf("%s is not a directory", dbDirectory)
 	}
 
-	// Clean up temporary files if directory exists
+	// Clean up temporary files if directory exists. A directory holding
+	// no other files has no database yet, so it is new too.
 	if !fm.isNew {
 		entries, err := os.ReadDir(dbDirectory)
 		if err != nil {
 			return nil, fmt.Errorf("cannot read directory: %w", err)
 		}
 
+		fm.isNew = true
 		for _, entry := range entries {
-			if strings.HasPrefix(entry.Name(), "temp") {
-				path := filepath.Join(dbDirectory, entry.Name())
-				if err := os.Remove(path); err != nil {
-					return nil, fmt.Errorf("cannot remove temporary file %s: %w", path, err)
-				}
+			if !IsTemp(entry.Name()) {
+				fm.isNew = false
+				continue
+			}
+			path := filepath.Join(dbDirectory, entry.Name())
+			if err := os.Remove(path); err != nil {
+				return nil, fmt.Errorf("cannot remove temporary file %s: %w", path, err)
 			}
 		}
 	}
@@ -59,6 +72,39 @@ func NewFileManager(dbDirectory string, blockSize int) (*FileManager, error) {
 	return fm, nil
 }
 
+// Reports whether the file belongs to a temp table. Temp files are
+// removed whenever the database is opened.
+func IsTemp(filename string) bool {
+	return strings.HasPrefix(filename, "temp")
+}
+
+// Returns the name of a file with the base name and extension whose blocks
+// have the specified size, 0 meaning the database block size
+func SizedFileName(base string, ext string, blockSize int) string {
+	if blockSize == 0 {
+		return base + ext
+	}
+	return base + BLOCK_SIZE_SEPARATOR + strconv.Itoa(blockSize) + ext
+}
+
+// Returns the base name of a file, without its extension and block size
+func BaseName(filename string) string {
+	base, _, _ := strings.Cut(strings.TrimSuffix(filename, filepath.Ext(filename)), BLOCK_SIZE_SEPARATOR)
+	return base
+}
+
+// Returns the size of the blocks of a file, which is the database block
+// size unless the name of the file says otherwise, see SizedFileName
+func (fm *FileManager) FileBlockSize(filename string) int {
+	stem := strings.TrimSuffix(filename, filepath.Ext(filename))
+	if i := strings.LastIndex(stem, BLOCK_SIZE_SEPARATOR); i >= 0 {
+		if size, err := strconv.Atoi(stem[i+1:]); err == nil && size > 0 {
+			return size
+		}
+	}
+	return fm.blockSize
+}
+
 // Read a block from disk into a page
 func (fm *FileManager) Read(blk *BlockID, p *Page) error {
 	// Acquire lock for thread safety when accessing shared resources
@@ -66,6 +112,11 @@ func (fm *FileManager) Read(blk *BlockID, p *Page) error {
 	// Release lock when method returns
 	defer fm.mu.Unlock()
 
+	return fm.read(blk, p)
+}
+
+// Reads a block into a page. The caller must hold the lock.
+func (fm *FileManager) read(blk *BlockID, p *Page) error {
 	// Get or create file handle for the given filename
 	file, err := fm.getFile(blk.FileName())
 	if err != nil {
@@ -74,7 +125,8 @@ func (fm *FileManager) Read(blk *BlockID, p *Page) error {
 
 	// Calculate offset in bytes where block starts
 	// offset = block number * block size
-	offset := int64(blk.Number()) * int64(fm.blockSize)
+	blockSize := fm.FileBlockSize(blk.FileName())
+	offset := int64(blk.Number()) * int64(blockSize)
 	if _, err := file.Seek(offset, 0); err != nil {
 		return fmt.Errorf("cannot seek to position: %w", err)
 	}
@@ -87,13 +139,49 @@ func (fm *FileManager) Read(blk *BlockID, p *Page) error {
 
 	// Verify complete block was read
 	// Number of bytes read should match block size
-	if n != fm.blockSize {
-		return fmt.Errorf("partial read for block %v: got %d bytes, expected %d", blk, n, fm.blockSize)
+	if n != blockSize {
+		return fmt.Errorf("partial read for block %v: got %d bytes, expected %d", blk, n, blockSize)
 	}
 
 	return nil
 }
 
+// Copies the blocks of an existing file to w. Each block is read under the
+// lock, so one being written at the same time is copied as it was either
+// before or after the write. Blocks appended during the copy may be left
+// out. Fails with os.ErrNotExist if the file doesn't exist or is removed
+// during the copy.
+func (fm *FileManager) CopyFile(filename string, w io.Writer) error {
+	page := NewPage(fm.FileBlockSize(filename))
+	for blkNum := 0; ; blkNum++ {
+		ok, err := fm.readExisting(NewBlockID(filename, blkNum), page)
+		if err != nil || !ok {
+			return err
+		}
+		if _, err := w.Write(page.contents); err != nil {
+			return fmt.Errorf("cannot copy block %d of %s: %w", blkNum, filename, err)
+		}
+	}
+}
+
+// Reads a block of a file without creating the file if it doesn't exist.
+// Returns false if the file ends before the block.
+func (fm *FileManager) readExisting(blk *BlockID, p *Page) (bool, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	if _, ok := fm.openFiles[blk.FileName()]; !ok {
+		if _, err := os.Stat(filepath.Join(fm.dbDirectory, blk.FileName())); err != nil {
+			return false, fmt.Errorf("cannot copy file %s: %w", blk.FileName(), err)
+		}
+	}
+	length, err := fm.Length(blk.FileName())
+	if err != nil || blk.Number() >= length {
+		return false, err
+	}
+	return true, fm.read(blk, p)
+}
+
 // Writes a page to a block on disk
 func (fm *FileManager) Write(blk *BlockID, p *Page) error {
 	fm.mu.Lock()
@@ -104,7 +192,8 @@ func (fm *FileManager) Write(blk *BlockID, p *Page) error {
 		return fmt.Errorf("cannot get file: %w", err)
 	}
 
-	offset := int64(blk.Number()) * int64(fm.blockSize)
+	blockSize := fm.FileBlockSize(blk.FileName())
+	offset := int64(blk.Number()) * int64(blockSize)
 	if _, err := file.Seek(offset, 0); err != nil {
 		return fmt.Errorf("cannot seek to position: %w", err)
 	}
@@ -114,13 +203,15 @@ func (fm *FileManager) Write(blk *BlockID, p *Page) error {
 		return fmt.Errorf("cannot write block %v: %w", blk, err)
 	}
 
-	if n != fm.blockSize {
-		return fmt.Errorf("partial write for block %v: wrote %d bytes, expected %d", blk, n, fm.blockSize)
+	if n != blockSize {
+		return fmt.Errorf("partial write for block %v: wrote %d bytes, expected %d", blk, n, blockSize)
 	}
 
 	// Ensure written data is flushed from OS buffers to disk
-	if err := file.Sync(); err != nil {
-		return fmt.Errorf("cannot sync file: %w", err)
+	if fm.syncWrites {
+		if err := file.Sync(); err != nil {
+			return fmt.Errorf("cannot sync file: %w", err)
+		}
 	}
 
 	return nil
@@ -140,14 +231,15 @@ func (fm *FileManager) Append(filename string) (*BlockID, error) {
 	blk := &BlockID{filename: filename, blockNumber: length}
 
 	// Create empty block
-	emptyData := make([]byte, fm.blockSize)
+	blockSize := fm.FileBlockSize(filename)
+	emptyData := make([]byte, blockSize)
 
 	file, err := fm.getFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get file: %w", err)
 	}
 
-	offset := int64(blk.Number()) * int64(fm.blockSize)
+	offset := int64(blk.Number()) * int64(blockSize)
 	if _, err := file.Seek(offset, 0); err != nil {
 		return nil, fmt.Errorf("cannot seek to position: %w", err)
 	}
@@ -157,8 +249,8 @@ func (fm *FileManager) Append(filename string) (*BlockID, error) {
 		return nil, fmt.Errorf("cannot append block %v: %w", blk, err)
 	}
 
-	if n != fm.blockSize {
-		return nil, fmt.Errorf("partial write for block %v: wrote %d bytes, expected %d", blk, n, fm.blockSize)
+	if n != blockSize {
+		return nil, fmt.Errorf("partial write for block %v: wrote %d bytes, expected %d", blk, n, blockSize)
 	}
 
 	// Ensure data is flushed to disk
@@ -181,7 +273,7 @@ func (fm *FileManager) Length(filename string) (int, error) {
 		return 0, fmt.Errorf("cannot stat file %s: %w", filename, err)
 	}
 
-	return int(info.Size()) / fm.blockSize, nil
+	return int(info.Size()) / fm.FileBlockSize(filename), nil
 }
 
 // getFile gets or creates a file for a filename
@@ -202,6 +294,24 @@ func (fm *FileManager) getFile(filename string) (*os.File, error) {
 	return file, nil
 }
 
+// Remove deletes a file, closing it first if it is open.
+// Removing a file that doesn't exist is not an error.
+func (fm *FileManager) Remove(filename string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	if file, ok := fm.openFiles[filename]; ok {
+		file.Close()
+		delete(fm.openFiles, filename)
+	}
+
+	path := filepath.Join(fm.dbDirectory, filename)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove file %s: %w", path, err)
+	}
+	return nil
+}
+
 // Close closes all open files
 func (fm *FileManager) Close() error {
 	fm.mu.Lock()
@@ -217,12 +327,21 @@ func (fm *FileManager) Close() error {
 	return lastErr
 }
 
-// IsNew returns whether the database directory was newly created
+// SetSyncWrites sets whether every block written is synced to disk.
+// Without syncing, writes may be lost if the machine crashes before
+// the operating system flushes them.
+func (fm *FileManager) SetSyncWrites(syncWrites bool) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.syncWrites = syncWrites
+}
+
+// IsNew returns whether the database directory was newly created or empty
 func (fm *FileManager) IsNew() bool {
 	return fm.isNew
 }
 
-// BlockSize returns the block size in bytes
+// BlockSize returns the database block size in bytes, see FileBlockSize
 func (fm *FileManager) BlockSize() int {
 	return fm.blockSize
 }
@@ -2,6 +2,7 @@ package file
 
 import (
 	"encoding/binary"
+	"math"
 	"unicode/utf8"
 )
 
@@ -29,6 +30,16 @@ func (p *Page) GetInt(offset int) int32 {
 	return int32(binary.BigEndian.Uint32(p.contents[offset : offset+4]))
 }
 
+// Retrieves a 64-bit integer from the specified offset
+func (p *Page) GetLong(offset int) int64 {
+	return int64(binary.BigEndian.Uint64(p.contents[offset : offset+8]))
+}
+
+// Retrieves a 64-bit floating-point number from the specified offset
+func (p *Page) GetFloat(offset int) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(p.contents[offset : offset+8]))
+}
+
 // Reads a byte array from specified offset
 // The first 4 bytes at the offset represent the length of the array
 func (p *Page) GetBytes(offset int) []byte {
@@ -58,6 +69,16 @@ func (p *Page) SetInt(offset int, n int32) {
 	binary.BigEndian.PutUint32(p.contents[offset:offset+4], uint32(n))
 }
 
+// Writes a 64-bit integer at the specified offset
+func (p *Page) SetLong(offset int, n int64) {
+	binary.BigEndian.PutUint64(p.contents[offset:offset+8], uint64(n))
+}
+
+// Writes a 64-bit floating-point number at the specified offset
+func (p *Page) SetFloat(offset int, f float64) {
+	binary.BigEndian.PutUint64(p.contents[offset:offset+8], math.Float64bits(f))
+}
+
 // Writes a byte array at specified offset
 // The first 4 bytes at the offset will contain the length of the array
 func (p *Page) SetBytes(offset int, b []byte) {
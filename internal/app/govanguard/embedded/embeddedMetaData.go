@@ -1,6 +1,9 @@
 package embedded
 
-import "centauri/internal/app/record/schema"
+import (
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+)
 
 // Holds metadata information about database schema
 type EmbeddedMetaData struct {
@@ -34,6 +37,10 @@ func (emd *EmbeddedMetaData) GetColumnType(column int) int {
 // Determines the display size for a specific column
 // The size is determined by:
 //   - For INTEGER type: fixed size of 6
+//   - For BIGINT type: fixed size of 20
+//   - For FLOAT type: fixed size of 24
+//   - For BOOLEAN type: the size of "false"
+//   - For DATE and TIMESTAMP types: the size of a timestamp
 //   - For other types: size specified in schema
 //
 // Final size is max of field name length or field data length, plus 1 for padding
@@ -44,6 +51,14 @@ func (emd *EmbeddedMetaData) GetColumnSize(column int) int {
 
 	if fldType == schema.INTEGER {
 		fldLength = 6
+	} else if fldType == schema.BIGINT {
+		fldLength = 20
+	} else if fldType == schema.FLOAT {
+		fldLength = 24
+	} else if fldType == schema.BOOLEAN {
+		fldLength = len("false")
+	} else if fldType.IsTemporal() {
+		fldLength = len(types.TIMESTAMP_LAYOUT)
 	} else {
 		fldLength = emd.sch.Length(fldName)
 	}
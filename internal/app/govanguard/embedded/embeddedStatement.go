@@ -18,10 +18,14 @@ func NewEmbeddedStatement(conn *EmbeddedConnection, planner *plan.Planner) *Embe
 }
 
 // Executes a query and returns a result set
-func (es *EmbeddedStatement) ExecuteQuery(query string) *EmbeddedResultSet {
+func (es *EmbeddedStatement) ExecuteQuery(query string) (*EmbeddedResultSet, error) {
 	tx := es.conn.getTransaction()
-	plan := es.planner.CreateQueryPlan(query, tx)
-	return NewEmbeddedResultSet(plan, es.conn)
+	plan, err := es.planner.CreateQueryPlan(query, tx)
+	if err != nil {
+		es.conn.rollback()
+		return nil, err
+	}
+	return NewEmbeddedResultSet(plan, es.conn), nil
 }
 
 // Executes an update command and returns the number of affected rows
@@ -30,7 +34,11 @@ func (es *EmbeddedStatement) ExecuteUpdate(cmd string) (int, error) {
 	tx := es.conn.getTransaction()
 
 	// Execute the update
-	result := es.planner.ExecuteUpdate(cmd, tx)
+	result, err := es.planner.ExecuteUpdate(cmd, tx)
+	if err != nil {
+		es.conn.rollback()
+		return 0, err
+	}
 
 	// Commit the transaction
 	es.conn.commit()
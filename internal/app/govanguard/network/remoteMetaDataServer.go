@@ -2,6 +2,7 @@ package network
 
 import (
 	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
 	"context"
 	"fmt"
 )
@@ -50,6 +51,14 @@ func (s *RemoteMetaDataServer) GetColumnDisplaySize(ctx context.Context, column
 
 	if fldType == schema.INTEGER {
 		fldLength = 6
+	} else if fldType == schema.BIGINT {
+		fldLength = 20
+	} else if fldType == schema.FLOAT {
+		fldLength = 24
+	} else if fldType == schema.BOOLEAN {
+		fldLength = len("false")
+	} else if fldType.IsTemporal() {
+		fldLength = len(types.TIMESTAMP_LAYOUT)
 	} else {
 		fldLength = s.sch.Length(fldName)
 	}
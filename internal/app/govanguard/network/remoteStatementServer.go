@@ -46,13 +46,21 @@ func (rss *RemoteStatementServer) ExecuteQuery(ctx context.Context, query string
 	}()
 
 	tx := rss.rConn.GetTransaction()
-	plan := rss.planner.CreateQueryPlan(query, tx)
+	plan, err := rss.planner.CreateQueryPlan(query, tx)
+	if err != nil {
+		rss.rConn.Rollback()
+		return nil, err
+	}
 	return NewRemoteSetServer(plan, rss.rConn)
 }
 
 func (rss *RemoteStatementServer) ExecuteUpdate(ctx context.Context, cmd string) (int, error) {
 	tx := rss.rConn.GetTransaction()
-	result := rss.planner.ExecuteUpdate(cmd, tx)
+	result, err := rss.planner.ExecuteUpdate(cmd, tx)
+	if err != nil {
+		rss.rConn.Rollback()
+		return 0, err
+	}
 	rss.rConn.Commit()
 
 	return result, nil
@@ -6,6 +6,7 @@ import (
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
 	"centauri/internal/app/types"
+	"math"
 )
 
 // Represents common functionality for B-tree directory and leaf pages
@@ -63,6 +64,16 @@ func (p *BTPage) IsFull() bool {
 	return p.slotPos(p.GetNumRecs()+1) >= p.tx.BlockSize()
 }
 
+// Returns the number of records the page can hold without being full,
+// which leaves room for the record that makes it split
+func (p *BTPage) capacity() int {
+	n := 0
+	for p.slotPos(n+2) < p.tx.BlockSize() {
+		n++
+	}
+	return n
+}
+
 // Divides the page at the specified position by creating a new page.
 // and transferring records starting at splitPos to the new page.
 // This is a critical operation for B-tree growth.
@@ -111,6 +122,8 @@ func (p *BTPage) AppendNew(flag int) *file.BlockID {
 	p.tx.Pin(&block)
 	// Initialize the block with the specified flag
 	p.Format(&block, flag)
+	// Unpin it again, callers open the block as a page of its own
+	p.tx.Unpin(&block)
 
 	return &block
 }
@@ -118,7 +131,8 @@ func (p *BTPage) AppendNew(flag int) *file.BlockID {
 // Initializes a block with the specified flag, setting record count to 0 and creating empty
 // records throughtout the page. This prepares a newly created block for use in the B-tree.
 func (p *BTPage) Format(block *file.BlockID, flag int) {
-	p.tx.SetInt(*block, 0, flag, false)
+	// The flag is logged so the block can be rebuilt from the log
+	p.tx.SetInt(*block, 0, flag, true)
 	p.tx.SetInt(*block, 4, 0, false)
 	recSize := p.layout.SlotSize()
 
@@ -136,9 +150,12 @@ func (p *BTPage) makeDefaultRecord(block *file.BlockID, pos int) {
 		offset := p.layout.Offset(fieldName)
 
 		// Init the field based on its type
-		if p.layout.Schema().DataType(fieldName) == schema.INTEGER {
-			// Init integer fields to 0
+		if fieldType := p.layout.Schema().DataType(fieldName); fieldType == schema.INTEGER || fieldType == schema.BOOLEAN {
+			// Init integer and boolean fields to 0
 			p.tx.SetInt(*block, pos+offset, 0, false)
+		} else if fieldType == schema.BIGINT || fieldType == schema.FLOAT || fieldType.IsTemporal() {
+			// Init bigints and floats to 0, and dates and timestamps to the epoch
+			p.tx.SetLong(*block, pos+offset, 0, false)
 		} else {
 			// Init string fields to empty string
 			p.tx.SetString(*block, pos+offset, "", false)
@@ -231,12 +248,26 @@ func (p *BTPage) getString(slot int, fldName string) string {
 // It handles type conversion based on the field's defined type in the schema.
 func (p *BTPage) getVal(slot int, fldName string) *types.Constant {
 	fieldType := p.layout.Schema().DataType(fldName)
+	if p.isNull(slot, fldName) {
+		return types.NewConstantNull()
+	}
 
 	// Retrieve and convert the value based on its type
 	if fieldType == schema.INTEGER {
 		return types.NewConstantInt(p.getInt(slot, fldName))
+	} else if fieldType == schema.BIGINT {
+		stored, _ := p.tx.GetLong(*p.currentBlock, p.fldPos(slot, fldName))
+		return types.NewConstantLong(stored)
+	} else if fieldType == schema.FLOAT {
+		stored, _ := p.tx.GetLong(*p.currentBlock, p.fldPos(slot, fldName))
+		return types.NewConstantFloat(math.Float64frombits(uint64(stored)))
+	} else if fieldType.IsTemporal() {
+		stored, _ := p.tx.GetLong(*p.currentBlock, p.fldPos(slot, fldName))
+		return record.TimeVal(fieldType, stored)
+	} else if fieldType == schema.BOOLEAN {
+		return types.NewConstantBool(p.getInt(slot, fldName) != 0)
 	} else {
-		return types.NewConstantString(p.getString(slot, fldName))
+		return record.StringVal(p.layout.Schema(), fldName, p.getString(slot, fldName))
 	}
 }
 
@@ -256,14 +287,56 @@ func (p *BTPage) setString(slot int, fldName string, val string) {
 
 // Stores a Constant value in a specific field in the record at the given slot.
 // It handles type conversion based on the field's defined type in the schema.
+// A null is stored as the zero value of the type with its bit set in the
+// null bitmap of the record, as in the records of tables.
 func (p *BTPage) setVal(slot int, fldName string, val *types.Constant) {
 	fieldType := p.layout.Schema().DataType(fldName)
 
-	if fieldType == schema.INTEGER {
+	switch {
+	case val.IsNull() && (fieldType == schema.INTEGER || fieldType == schema.BOOLEAN):
+		p.setInt(slot, fldName, 0)
+	case val.IsNull() && (fieldType == schema.BIGINT || fieldType == schema.FLOAT || fieldType.IsTemporal()):
+		p.tx.SetLong(*p.currentBlock, p.fldPos(slot, fldName), 0, true)
+	case val.IsNull():
+		p.setString(slot, fldName, "")
+	case fieldType == schema.INTEGER:
 		p.setInt(slot, fldName, *val.AsInt())
-	} else {
+	case fieldType == schema.BIGINT:
+		n, _ := val.AsInteger()
+		p.tx.SetLong(*p.currentBlock, p.fldPos(slot, fldName), n, true)
+	case fieldType == schema.FLOAT:
+		f, _ := val.AsNumber()
+		p.tx.SetLong(*p.currentBlock, p.fldPos(slot, fldName), int64(math.Float64bits(f)), true)
+	case fieldType == schema.BOOLEAN:
+		p.setInt(slot, fldName, record.BoolInt(*val.AsBool()))
+	case fieldType.IsTemporal():
+		t, _ := record.ToTime(fieldType, val)
+		p.tx.SetLong(*p.currentBlock, p.fldPos(slot, fldName), *t.AsLong(), true)
+	default:
 		p.setString(slot, fldName, *val.AsString())
 	}
+	p.setNull(slot, fldName, val.IsNull())
+}
+
+// Reports whether a field of the record at the given slot is null
+func (p *BTPage) isNull(slot int, fldName string) bool {
+	bit := p.layout.NullBit(fldName)
+	if bit < 0 {
+		return false
+	}
+	bitmap, _ := p.tx.GetInt(*p.currentBlock, p.slotPos(slot)+record.NULL_BITMAP_OFFSET)
+	return bitmap&(1<<bit) != 0
+}
+
+// Sets or clears the bit of a field in the null bitmap of the record at the given slot
+func (p *BTPage) setNull(slot int, fldName string, null bool) {
+	bit := p.layout.NullBit(fldName)
+	if bit < 0 || p.isNull(slot, fldName) == null {
+		return
+	}
+	pos := p.slotPos(slot) + record.NULL_BITMAP_OFFSET
+	bitmap, _ := p.tx.GetInt(*p.currentBlock, pos)
+	p.tx.SetInt(*p.currentBlock, pos, int(bitmap^(1<<bit)), true)
 }
 
 // Updates the record count stored in the page header.
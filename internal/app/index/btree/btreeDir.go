@@ -183,17 +183,23 @@ func (d *BTreeDir) insertEntry(e *DirEntry) *DirEntry {
 // This method implements a critical part of the B-tree search algorithmn:
 // given a key, it finds the appropriate branch to follow in the directory.
 func (d *BTreeDir) findChildBlock(searchKey *types.Constant) *file.BlockID {
+	// Get the block number of the child at determined slot
+	blockNum := d.contents.GetChildNum(childSlot(d.contents, searchKey))
+
+	return file.NewBlockID(d.fileName, blockNum)
+}
+
+// Returns the slot of the directory page whose child holds the search key
+func childSlot(contents *BTPage, searchKey *types.Constant) int {
 	// Find the largest slot whose key is less than or equal to the search key
-	slot := d.contents.FindSlotBefore(searchKey)
+	slot := contents.FindSlotBefore(searchKey)
 
 	// If the next key exactly matches the search key, use that slot instead
 	// This ensures we follow the correct path when the key exists in the tree
-	if slot+1 < d.contents.GetNumRecs() && d.contents.GetDataVal(slot+1).Equals(searchKey) {
+	if slot+1 < contents.GetNumRecs() && contents.GetDataVal(slot+1).Equals(searchKey) {
 		slot++
 	}
 
-	// Get the block number of the child at determined slot
-	blockNum := d.contents.GetChildNum(slot)
-
-	return file.NewBlockID(d.fileName, blockNum)
+	// A key below every entry, such as null, belongs to the first child
+	return max(slot, 0)
 }
@@ -2,11 +2,13 @@ package btree
 
 import (
 	"centauri/internal/app/file"
+	"centauri/internal/app/index"
 	"centauri/internal/app/record"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
 	"centauri/internal/app/types"
 	"math"
+	"slices"
 )
 
 // Implements the Index interface using a B-tree structure.
@@ -25,7 +27,7 @@ import (
 // Leaf Layer:
 //   - Stored in a file named "{idxname}leaf"
 //   - Contains actual index entries (key-RID pairs)
-//   - Leaf pages are linked for efficient range queries
+//   - Leaf pages are read in key order through the directory for range queries
 //   - All data records are stored at the leaf level
 //
 // This two layer approach provides a balanced, scalable structure that grows
@@ -52,6 +54,7 @@ type BTreeIndex struct {
 	leafLayout *record.Layout
 	leaftbl    string // name of the leaf table file
 	leaf       *BTreeLeaf
+	rng        *btreeRange // The range positioned by BeforeFirstRange, nil after BeforeFirst
 	rootBlock  *file.BlockID
 }
 
@@ -106,6 +109,14 @@ func NewBTreeIndex(tx *tx.Transaction, idxname string, leafLayout *record.Layout
 		fieldType := dirsch.DataType("dataval")
 		if fieldType == schema.INTEGER {
 			minval = types.NewConstantInt(math.MinInt32)
+		} else if fieldType == schema.BIGINT {
+			minval = types.NewConstantLong(math.MinInt64)
+		} else if fieldType == schema.FLOAT {
+			minval = types.NewConstantFloat(math.Inf(-1))
+		} else if fieldType.IsTemporal() {
+			minval = record.TimeVal(fieldType, math.MinInt64/types.SECONDS_PER_DAY)
+		} else if fieldType == schema.BOOLEAN {
+			minval = types.NewConstantBool(false)
 		} else {
 			minval = types.NewConstantString("")
 		}
@@ -140,15 +151,32 @@ func (idx *BTreeIndex) BeforeFirst(searchKey *types.Constant) {
 	idx.leaf = NewBtreeLeaf(idx.tx, leafBlock, idx.leafLayout, searchKey)
 }
 
-// Moves to the next leaf entry matching the search key specified in the most recent beforeFirst call.
+// Positions the index before the first entry of the range, see index.RangeIndex.
+// The directory is searched for the leaf of the low bound, or the first leaf if
+// there is none, and Next moves on from leaf to leaf until a key is past the
+// high bound. A range read in reverse starts from the leaf of the high bound
+// instead, or the last leaf, and ends at the low bound.
+func (idx *BTreeIndex) BeforeFirstRange(rng index.KeyRange) {
+	idx.Close()
+	idx.rng = newBTreeRange(idx, rng)
+}
+
+// Moves to the next leaf entry matching the search key specified in the most recent beforeFirst call,
+// or to the next entry of the range specified in the most recent BeforeFirstRange call.
 // This allows iterating through all entries with a particular key.
 func (idx *BTreeIndex) Next() bool {
+	if idx.rng != nil {
+		return idx.rng.next()
+	}
 	return idx.leaf.Next()
 }
 
 // Returns the RID from the current leaf entry.
 // This RID points to the actual data record in the database that is index by the current entry.
 func (idx *BTreeIndex) GetDataRid() *types.RID {
+	if idx.rng != nil {
+		return idx.rng.dataRid()
+	}
 	return idx.leaf.GetDataRid()
 }
 
@@ -191,12 +219,100 @@ func (idx *BTreeIndex) Delete(dataval *types.Constant, datarid *types.RID) {
 	idx.leaf.Close()
 }
 
+// Returns the smallest key in the index that isn't null, or nil if there is none.
+// It descends the leftmost edge of the tree, moving right only past leaves
+// emptied by deletions.
+func (idx *BTreeIndex) MinKey() *types.Constant {
+	return idx.edgeKey(idx.rootBlock, true)
+}
+
+// Returns the largest key in the index that isn't null, or nil if there is none.
+// It descends the rightmost edge of the tree, moving left only past leaves
+// emptied by deletions.
+func (idx *BTreeIndex) MaxKey() *types.Constant {
+	return idx.edgeKey(idx.rootBlock, false)
+}
+
+// Returns the first or last key below the specified directory block
+func (idx *BTreeIndex) edgeKey(block *file.BlockID, first bool) *types.Constant {
+	dir := NewBTPage(idx.tx, block, idx.dirLayout)
+	level := dir.GetFlag()
+	children := make([]int, dir.GetNumRecs())
+	for i := range children {
+		children[i] = dir.GetChildNum(i)
+	}
+	dir.Close()
+
+	if !first {
+		slices.Reverse(children)
+	}
+
+	for _, child := range children {
+		var key *types.Constant
+		if level > 0 {
+			key = idx.edgeKey(file.NewBlockID(block.FileName(), child), first)
+		} else {
+			key = idx.leafEdgeKey(file.NewBlockID(idx.leaftbl, child), first)
+		}
+		if key != nil {
+			return key
+		}
+	}
+	return nil
+}
+
+// Returns the first or last key of a leaf that isn't null, or nil if the
+// leaf has none. Nulls sort first, so only the first keys can be null.
+// The overflow blocks of a leaf only hold copies of its first key,
+// so they never need to be read.
+func (idx *BTreeIndex) leafEdgeKey(block *file.BlockID, first bool) *types.Constant {
+	leaf := NewBTPage(idx.tx, block, idx.leafLayout)
+	defer leaf.Close()
+
+	numRecs := leaf.GetNumRecs()
+	if !first {
+		if numRecs == 0 || leaf.GetDataVal(numRecs-1).IsNull() {
+			return nil
+		}
+		return leaf.GetDataVal(numRecs - 1)
+	}
+	for slot := 0; slot < numRecs; slot++ {
+		if key := leaf.GetDataVal(slot); !key.IsNull() {
+			return key
+		}
+	}
+	return nil
+}
+
 // Releases resources by closing the current leaf page if it's open.
 func (idx *BTreeIndex) Close() {
 	if idx.leaf != nil {
 		idx.leaf.Close()
 		idx.leaf = nil
 	}
+	if idx.rng != nil {
+		idx.rng.close()
+		idx.rng = nil
+	}
+}
+
+// Returns the number of entries in the index. Every block of the leaf
+// file is a leaf or an overflow block, so their records are counted.
+func (idx *BTreeIndex) NumEntries() int {
+	size, _ := idx.tx.Size(idx.leaftbl)
+	count := 0
+	for blockNum := 0; blockNum < size; blockNum++ {
+		page := NewBTPage(idx.tx, file.NewBlockID(idx.leaftbl, blockNum), idx.leafLayout)
+		count += page.GetNumRecs()
+		page.Close()
+	}
+	return count
+}
+
+// Returns the names of the files storing the B-tree index of the name:
+// its leaf file and its directory file
+func FileNames(idxName string) []string {
+	return []string{idxName + "leaf", idxName + "dir"}
 }
 
 // Estimates the number of block accesses required to find all index records with a
@@ -205,6 +321,25 @@ func (idx *BTreeIndex) Close() {
 //   - numblocks: The number of blocks in the B-tree directory
 //   - rpb: The number of records (entries) per block
 func SearchCost(numBlocks int, rpb int) int {
-	// Cost is 1 (for the leaf access) plus the height of the directory tree
-	return 1 + int(math.Log(float64(numBlocks))/math.Log(float64(rpb)))
+	// Cost is 1 (for the leaf access) plus the height of the directory tree,
+	// the number of times the blocks divide by rpb. It is counted with integers,
+	// as the ratio of logarithms falls just short of whole heights.
+	height := 0
+	for n := numBlocks; rpb > 1 && n >= rpb; n /= rpb {
+		height++
+	}
+	return 1 + height
+}
+
+// Returns the number of levels of the tree: the levels of the directory,
+// the lowest of which is level 0, and the level of the leaves
+func (idx *BTreeIndex) Height() int {
+	root := NewBTPage(idx.tx, idx.rootBlock, idx.dirLayout)
+	defer root.Close()
+	return root.GetFlag() + 2
+}
+
+// Estimates the cost of a search in this index, see SearchCost
+func (idx *BTreeIndex) SearchCost(numBlocks int, rpb int) int {
+	return SearchCost(numBlocks, rpb)
 }
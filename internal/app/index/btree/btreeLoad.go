@@ -0,0 +1,95 @@
+package btree
+
+import (
+	"centauri/internal/app/file"
+	"centauri/internal/app/types"
+)
+
+// Adds entries sorted by key to an empty index, see index.BulkIndex.
+// The leaves are filled one after the other, and the directory is then
+// built over them level by level, instead of searching it for each entry.
+// The pages are left as inserts would leave them: with room for one more
+// record, the entries of a key in a single leaf, and the copies of a key
+// filling a leaf moved to overflow blocks. If the index isn't empty, the
+// entries are inserted one by one.
+func (idx *BTreeIndex) Load(entries func(yield func(*types.Constant, *types.RID) bool)) {
+	idx.Close()
+	if !idx.isEmpty() {
+		for key, rid := range entries {
+			idx.Insert(key, rid)
+		}
+		return
+	}
+
+	leaf := NewBTPage(idx.tx, file.NewBlockID(idx.leaftbl, 0), idx.leafLayout)
+	var leaves []*DirEntry // The leaves after the first one
+	for key, rid := range entries {
+		slot := leaf.GetNumRecs()
+		leaf.InsertLeaf(slot, key, rid)
+		if !leaf.IsFull() {
+			continue
+		}
+
+		if leaf.GetDataVal(0).Equals(key) {
+			// Every entry has the key, so all but the first one overflow
+			overflow := leaf.Split(1, leaf.GetFlag())
+			leaf.SetFlag(overflow.Number())
+			continue
+		}
+
+		// The entries of the last key start the next leaf
+		for leaf.GetDataVal(slot - 1).Equals(key) {
+			slot--
+		}
+		next := leaf.Split(slot, -1)
+		leaf.Close()
+		leaf = NewBTPage(idx.tx, next, idx.leafLayout)
+		leaves = append(leaves, NewDirEntry(key, next.Number()))
+	}
+	leaf.Close()
+
+	idx.loadDirectory(leaves)
+}
+
+// Builds the directory over the leaves after the first one. Each level
+// is filled with the entries of the level below, until they fit in the
+// root, which keeps its first entry, the one of the first leaf.
+func (idx *BTreeIndex) loadDirectory(leaves []*DirEntry) {
+	root := NewBTPage(idx.tx, idx.rootBlock, idx.dirLayout)
+	defer root.Close()
+
+	entries := append([]*DirEntry{NewDirEntry(root.GetDataVal(0), root.GetChildNum(0))}, leaves...)
+	capacity := root.capacity()
+	level := 0
+	for len(entries) > capacity {
+		var parents []*DirEntry
+		for start := 0; start < len(entries); start += capacity {
+			block := root.AppendNew(level)
+			page := NewBTPage(idx.tx, block, idx.dirLayout)
+			for i, e := range entries[start:min(start+capacity, len(entries))] {
+				page.InsertDir(i, e.DataVal(), e.BlockNumber())
+			}
+			page.Close()
+			parents = append(parents, NewDirEntry(entries[start].DataVal(), block.Number()))
+		}
+		entries = parents
+		level++
+	}
+
+	root.Format(idx.rootBlock, level)
+	for i, e := range entries {
+		root.InsertDir(i, e.DataVal(), e.BlockNumber())
+	}
+}
+
+// Reports whether the index has no entries and has never been split
+func (idx *BTreeIndex) isEmpty() bool {
+	leafBlocks, _ := idx.tx.Size(idx.leaftbl)
+	dirBlocks, _ := idx.tx.Size(idx.rootBlock.FileName())
+	if leafBlocks != 1 || dirBlocks != 1 {
+		return false
+	}
+	leaf := NewBTPage(idx.tx, file.NewBlockID(idx.leaftbl, 0), idx.leafLayout)
+	defer leaf.Close()
+	return leaf.GetNumRecs() == 0 && leaf.GetFlag() < 0
+}
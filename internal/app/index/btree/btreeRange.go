@@ -0,0 +1,330 @@
+package btree
+
+import (
+	"centauri/internal/app/file"
+	"centauri/internal/app/index"
+	"centauri/internal/app/types"
+)
+
+// Moves through the leaf entries of a B-tree index whose keys lie in a range,
+// in key order or in reverse. Leaf blocks aren't linked to each other, so the
+// range keeps the directory entry it followed at each level. The leaf after
+// the current one is the child of the next entry of the lowest directory page
+// that has one, reached by following the first entries down from there, and
+// the leaf before it is found the same way through the previous entries and
+// the last ones.
+// The overflow blocks of a leaf hold copies of a single key, and are read
+// where that key falls among the entries of the leaf.
+type btreeRange struct {
+	idx       *BTreeIndex
+	rng       index.KeyRange
+	path      []dirSlot // The directory entry followed at each level, from the root down
+	leaf      *BTPage   // The leaf being read, nil once the range is done
+	slot      int       // The current slot of the leaf
+	chainSlot int       // The slot of the leaf the overflow blocks are read before, -1 if there are none left
+	chain     *BTPage   // The overflow block being read, if any
+	chainPos  int       // The current slot of the overflow block
+}
+
+// A directory entry followed by a range
+type dirSlot struct {
+	block int
+	slot  int
+}
+
+// Creates a range positioned before its first entry, which is its
+// last one if it is read in reverse
+func newBTreeRange(idx *BTreeIndex, rng index.KeyRange) *btreeRange {
+	r := &btreeRange{idx: idx, rng: rng}
+	if r.reverse() {
+		r.openLeaf(r.descend(0, idx.rootBlock.Number(), rng.High))
+		return r
+	}
+
+	r.openLeaf(r.descend(0, idx.rootBlock.Number(), rng.Low))
+	if rng.Low != nil {
+		// Skip the entries before the first occurence of the low bound
+		r.slot = r.leaf.FindSlotBefore(rng.Low)
+	}
+	return r
+}
+
+// Reports whether the range is read in reverse. The null keys are
+// equal to each other, so they are always read forwards.
+func (r *btreeRange) reverse() bool {
+	return r.rng.Descending && !r.rng.Nulls
+}
+
+// Moves to the next entry of the range. Returns false if there is none.
+func (r *btreeRange) next() bool {
+	if r.reverse() {
+		return r.prev()
+	}
+	for r.leaf != nil {
+		if r.chain != nil {
+			r.chainPos++
+			if r.chainPos < r.chain.GetNumRecs() {
+				if r.inRange(r.chain.GetDataVal(r.chainPos)) {
+					return true
+				}
+				continue
+			}
+
+			// Move to the next overflow block, or back to the leaf
+			flag := r.chain.GetFlag()
+			r.chain.Close()
+			r.chain = nil
+			if flag >= 0 {
+				r.openChain(flag)
+			}
+			continue
+		}
+
+		r.slot++
+		if r.slot == r.chainSlot {
+			// The slot is read after the overflow blocks
+			r.chainSlot = -1
+			r.slot--
+			r.openChain(r.leaf.GetFlag())
+			continue
+		}
+		if r.slot < r.leaf.GetNumRecs() {
+			if r.inRange(r.leaf.GetDataVal(r.slot)) {
+				return true
+			}
+			continue
+		}
+
+		block, ok := r.nextLeaf()
+		r.leaf.Close()
+		r.leaf = nil
+		if ok {
+			r.openLeaf(block)
+		}
+	}
+	return false
+}
+
+// Moves to the previous entry of a range read in reverse.
+// Returns false if there is none.
+func (r *btreeRange) prev() bool {
+	for r.leaf != nil {
+		if r.chain != nil {
+			r.chainPos++
+			if r.chainPos < r.chain.GetNumRecs() {
+				if r.inRange(r.chain.GetDataVal(r.chainPos)) {
+					return true
+				}
+				continue
+			}
+
+			flag := r.chain.GetFlag()
+			r.chain.Close()
+			r.chain = nil
+			if flag >= 0 {
+				r.openChain(flag)
+			}
+			continue
+		}
+
+		if r.slot == r.chainSlot {
+			// The overflow blocks hold copies of a key below the current
+			// slot's, so they are read before the slots under it
+			r.chainSlot = -1
+			r.openChain(r.leaf.GetFlag())
+			continue
+		}
+		r.slot--
+		if r.slot >= 0 {
+			if r.inRange(r.leaf.GetDataVal(r.slot)) {
+				return true
+			}
+			continue
+		}
+
+		block, ok := r.prevLeaf()
+		r.leaf.Close()
+		r.leaf = nil
+		if ok {
+			r.openLeaf(block)
+		}
+	}
+	return false
+}
+
+// Returns the RID of the current entry
+func (r *btreeRange) dataRid() *types.RID {
+	if r.chain != nil {
+		return r.chain.GetDataRid(r.chainPos)
+	}
+	return r.leaf.GetDataRid(r.slot)
+}
+
+// Releases the pages the range is reading
+func (r *btreeRange) close() {
+	if r.chain != nil {
+		r.chain.Close()
+		r.chain = nil
+	}
+	if r.leaf != nil {
+		r.leaf.Close()
+		r.leaf = nil
+	}
+}
+
+// Reports whether the key is in the range, closing the range
+// once the key is past its end
+func (r *btreeRange) inRange(key *types.Constant) bool {
+	if r.rng.Nulls || key.IsNull() {
+		// Null keys sort first, so the null keys end at the first other one
+		if r.rng.Nulls && !key.IsNull() {
+			r.close()
+		}
+		return r.rng.Nulls && key.IsNull()
+	}
+	if r.reverse() {
+		if r.rng.Low != nil && r.below(key) {
+			r.close()
+			return false
+		}
+		return r.rng.High == nil || !r.past(key)
+	}
+	if r.rng.High != nil && r.past(key) {
+		r.close()
+		return false
+	}
+	return r.rng.Low == nil || !r.below(key)
+}
+
+// Reports whether the key is above the high bound of the range
+func (r *btreeRange) past(key *types.Constant) bool {
+	cmp := key.CompareTo(r.rng.High)
+	return cmp > 0 || (cmp == 0 && !r.rng.HighInclusive)
+}
+
+// Reports whether the key is below the low bound of the range
+func (r *btreeRange) below(key *types.Constant) bool {
+	cmp := key.CompareTo(r.rng.Low)
+	return cmp < 0 || (cmp == 0 && !r.rng.LowInclusive)
+}
+
+// Descends the directory from the page at the given depth of the path to
+// a leaf, following the entry of the search key at each level, or if it is
+// nil the first entry, or the last one for a range read in reverse.
+// Returns the block number of the leaf.
+func (r *btreeRange) descend(depth int, block int, searchKey *types.Constant) int {
+	r.path = r.path[:depth]
+	for {
+		dir := NewBTPage(r.idx.tx, file.NewBlockID(r.idx.rootBlock.FileName(), block), r.idx.dirLayout)
+		slot := 0
+		if searchKey != nil {
+			slot = childSlot(dir, searchKey)
+		} else if r.reverse() {
+			slot = dir.GetNumRecs() - 1
+		}
+		r.path = append(r.path, dirSlot{block: block, slot: slot})
+		child := dir.GetChildNum(slot)
+		level := dir.GetFlag()
+		dir.Close()
+
+		if level == 0 {
+			return child
+		}
+		block = child
+	}
+}
+
+// Finds the leaf after the current one. Returns false if there is none,
+// or if its keys are all past the end of the range.
+func (r *btreeRange) nextLeaf() (int, bool) {
+	for depth := len(r.path) - 1; depth >= 0; depth-- {
+		pos := r.path[depth]
+		dir := NewBTPage(r.idx.tx, file.NewBlockID(r.idx.rootBlock.FileName(), pos.block), r.idx.dirLayout)
+		slot := pos.slot + 1
+		if slot >= dir.GetNumRecs() {
+			dir.Close()
+			continue
+		}
+
+		// The keys below an entry are at least its key
+		if !r.rng.Nulls && r.rng.High != nil && r.past(dir.GetDataVal(slot)) {
+			dir.Close()
+			return 0, false
+		}
+		child := dir.GetChildNum(slot)
+		level := dir.GetFlag()
+		dir.Close()
+
+		r.path[depth].slot = slot
+		if level == 0 {
+			r.path = r.path[:depth+1]
+			return child, true
+		}
+		return r.descend(depth+1, child, nil), true
+	}
+	return 0, false
+}
+
+// Finds the leaf before the current one. Returns false if there is none,
+// or if its keys are all below the start of the range.
+func (r *btreeRange) prevLeaf() (int, bool) {
+	for depth := len(r.path) - 1; depth >= 0; depth-- {
+		pos := r.path[depth]
+		if pos.slot == 0 {
+			continue
+		}
+		dir := NewBTPage(r.idx.tx, file.NewBlockID(r.idx.rootBlock.FileName(), pos.block), r.idx.dirLayout)
+
+		// The keys below an entry are less than the key of the next one
+		if r.rng.Low != nil && dir.GetDataVal(pos.slot).CompareTo(r.rng.Low) <= 0 {
+			dir.Close()
+			return 0, false
+		}
+		slot := pos.slot - 1
+		child := dir.GetChildNum(slot)
+		level := dir.GetFlag()
+		dir.Close()
+
+		r.path[depth].slot = slot
+		if level == 0 {
+			r.path = r.path[:depth+1]
+			return child, true
+		}
+		return r.descend(depth+1, child, nil), true
+	}
+	return 0, false
+}
+
+// Opens a leaf before its first entry, or after its last one
+// for a range read in reverse. If the leaf has overflow blocks,
+// finds the slot they are read before: the first one past their key.
+func (r *btreeRange) openLeaf(block int) {
+	r.leaf = NewBTPage(r.idx.tx, file.NewBlockID(r.idx.leaftbl, block), r.idx.leafLayout)
+	r.slot = -1
+	if r.reverse() {
+		r.slot = r.leaf.GetNumRecs()
+	}
+	r.chainSlot = -1
+
+	flag := r.leaf.GetFlag()
+	if flag < 0 {
+		return
+	}
+	overflow := NewBTPage(r.idx.tx, file.NewBlockID(r.idx.leaftbl, flag), r.idx.leafLayout)
+	defer overflow.Close()
+
+	r.chainSlot = 0
+	if overflow.GetNumRecs() == 0 {
+		return
+	}
+	key := overflow.GetDataVal(0)
+	for r.chainSlot < r.leaf.GetNumRecs() && r.leaf.GetDataVal(r.chainSlot).CompareTo(key) <= 0 {
+		r.chainSlot++
+	}
+}
+
+// Opens an overflow block before its first entry
+func (r *btreeRange) openChain(block int) {
+	r.chain = NewBTPage(r.idx.tx, file.NewBlockID(r.idx.leaftbl, block), r.idx.leafLayout)
+	r.chainPos = -1
+}
@@ -5,6 +5,7 @@ import (
 	"centauri/internal/app/record"
 	"centauri/internal/app/tx"
 	"centauri/internal/app/types"
+	"strconv"
 )
 
 const NUM_BUCKETS = 100 // Number of hash buckets used in the hash index
@@ -32,9 +33,11 @@ func NewHashIndex(tx *tx.Transaction, idxName string, layout *record.Layout) ind
 // It determines the appropriate bucket based on the search key's hash value.
 func (hi *HashIndex) BeforeFirst(searchKey *types.Constant) {
 	hi.close()
+	// Keys that are equal under the collation of the field hash alike
+	searchKey = searchKey.Collate(types.CollationNamed(hi.layout.Schema().Collation("dataval")))
 	hi.searchKey = searchKey
 	bucket := searchKey.HashCode() % NUM_BUCKETS
-	tableName := hi.idxName + string(bucket)
+	tableName := BucketTableName(hi.idxName, int(bucket))
 	hi.ts = record.NewTableScan(hi.tx, tableName, hi.layout)
 }
 
@@ -42,7 +45,7 @@ func (hi *HashIndex) BeforeFirst(searchKey *types.Constant) {
 // returns true if there is such a record, false otherwise.
 func (hi *HashIndex) Next() bool {
 	for hi.ts.Next() {
-		if hi.ts.GetVal("dataval") == hi.searchKey {
+		if hi.ts.GetVal("dataval").Equals(hi.searchKey) {
 			return true
 		}
 	}
@@ -82,13 +85,23 @@ func (hi *HashIndex) Delete(val *types.Constant, rid *types.RID) {
 	// Search for matching record
 	for hi.Next() {
 		// If found matching RID, delete the record
-		if hi.GetDataRid() == rid {
+		if hi.GetDataRid().Equals(rid) {
 			hi.ts.Delete()
 			return
 		}
 	}
 }
 
+// Returns the name of the table holding the records of the specified bucket of an index.
+func BucketTableName(idxName string, bucket int) string {
+	return idxName + strconv.Itoa(bucket)
+}
+
+// Releases the resources held by the index
+func (hi *HashIndex) Close() {
+	hi.close()
+}
+
 // Closes the current table scan if one exists.
 // This is typically called before starting a new scan operation.
 func (hi *HashIndex) close() {
@@ -103,3 +116,8 @@ func (hi *HashIndex) close() {
 func SearchCost(numBlocks int, rpb int) int {
 	return numBlocks / NUM_BUCKETS
 }
+
+// Estimates the cost of a search in this index, see SearchCost
+func (hi *HashIndex) SearchCost(numBlocks int, rpb int) int {
+	return SearchCost(numBlocks, rpb)
+}
@@ -2,8 +2,13 @@ package index
 
 import (
 	"centauri/internal/app/types"
+	"errors"
 )
 
+// Returned when a change would give a unique index two entries
+// with the same key
+var ErrUniqueViolation = errors.New("duplicate key violates unique index")
+
 // Defines Operations for database index management
 type Index interface {
 	// Positions the index cursor before the first entry
@@ -31,3 +36,72 @@ type Index interface {
 	// Releases any resources associated with the index
 	Close()
 }
+
+// Implemented by indexes that can estimate the cost of a search
+// from the size of the index, for the planner to compare access paths
+type SearchCoster interface {
+	// Returns the number of block accesses needed to find the entries
+	// of a search key in an index of numBlocks blocks, each holding
+	// rpb index records
+	SearchCost(numBlocks int, rpb int) int
+}
+
+// Implemented by indexes that keep their keys in order, such as B-trees,
+// which can find their smallest and largest keys without a scan
+type OrderedIndex interface {
+	Index
+
+	// Returns the smallest key in the index, or nil if it is empty
+	MinKey() *types.Constant
+
+	// Returns the largest key in the index, or nil if it is empty
+	MaxKey() *types.Constant
+}
+
+// Implemented by indexes organized as a tree, such as B-trees
+type TreeIndex interface {
+	Index
+
+	// Returns the number of levels of the tree, counting the leaves
+	Height() int
+}
+
+// Implemented by indexes that can find the entries whose keys lie
+// in a range, such as B-trees
+type RangeIndex interface {
+	OrderedIndex
+
+	// Positions the index cursor before the first entry of the range.
+	// Next then moves through the entries of the range in key order,
+	// or in reverse if the range is descending.
+	BeforeFirstRange(rng KeyRange)
+}
+
+// Implemented by indexes that can be built at once from their entries
+// sorted by key, which is faster than inserting them one by one
+type BulkIndex interface {
+	Index
+
+	// Adds the entries to the index, which must be empty. The entries
+	// are yielded in key order, null keys first.
+	Load(entries func(yield func(*types.Constant, *types.RID) bool))
+}
+
+// A range of keys to look up in a RangeIndex. A nil bound leaves its end
+// of the range open, and the inclusive flags tell whether keys equal to
+// the bounds are in the range. Null keys are in no range, but can be
+// looked up on their own.
+type KeyRange struct {
+	Low           *types.Constant // nil if the range has no lower bound
+	High          *types.Constant // nil if the range has no upper bound
+	LowInclusive  bool
+	HighInclusive bool
+	Descending    bool // Whether the keys are read from the largest down
+	Nulls         bool // Whether the null keys are looked up instead of the bounds
+}
+
+// The structures of indexes
+const (
+	INDEX_HASH  = "hash"
+	INDEX_BTREE = "btree"
+)
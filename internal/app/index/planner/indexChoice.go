@@ -0,0 +1,131 @@
+package planner
+
+import (
+	"centauri/internal/app/index"
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/query"
+	"centauri/internal/app/types"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Creates an index select plan over the table plan if the predicate equates
+// an indexed field with a constant, or restricts it to a list of constants,
+// as IN and BETWEEN can. A B-tree index can also look up the range that
+// comparisons with constants restrict its field to, as in "age > 30".
+// Of several such indexes, the one expected to cost the fewest block
+// accesses is used. Looking up a list of constants or a range is only
+// chosen if it costs less than scanning the table. Returns nil if no index applies.
+func MakeIndexSelect(tp interfaces.Plan, pred *query.Predicate, indexes map[string]metadata.IndexInfo) interfaces.Plan {
+	// Visit the indexes in a fixed order so that ties are broken the same way each time
+	fieldNames := make([]string, 0, len(indexes))
+	for fieldName := range indexes {
+		fieldNames = append(fieldNames, fieldName)
+	}
+	sort.Strings(fieldNames)
+
+	var best interfaces.Plan
+	for _, fieldName := range fieldNames {
+		ii := indexes[fieldName]
+		// Composite keys aren't stored by the index structures
+		if ii.IsComposite() {
+			continue
+		}
+
+		var candidates []interfaces.Plan
+		if val := pred.EquatesWithConstant(fieldName); val != nil {
+			candidates = append(candidates, NewIndexSelectPlan(tp, &ii, *val))
+		} else {
+			if vals := pred.MatchesConstants(fieldName); vals != nil {
+				candidates = append(candidates, NewIndexListSelectPlan(tp, &ii, vals))
+			}
+			low, high, lowInclusive, highInclusive := pred.Bounds(fieldName)
+			if ii.IndexType() == index.INDEX_BTREE && (low != nil || high != nil) {
+				candidates = append(candidates, NewIndexRangeSelectPlan(tp, &ii, index.KeyRange{
+					Low:           low,
+					High:          high,
+					LowInclusive:  lowInclusive,
+					HighInclusive: highInclusive,
+				}))
+			}
+			candidates = slices.DeleteFunc(candidates, func(p interfaces.Plan) bool {
+				return p.BlocksAccessed() >= tp.BlocksAccessed()
+			})
+		}
+
+		for _, p := range candidates {
+			if best == nil || p.BlocksAccessed() < best.BlocksAccessed() {
+				best = p
+			}
+		}
+	}
+	return best
+}
+
+// Creates a plan selecting the records of the table plan in the order of a
+// field, through a B-tree index on it, so that they need no sort. Only the
+// range the predicate restricts the field to is read. Returns nil if the
+// field has no such index.
+func MakeIndexOrderedSelect(tp interfaces.Plan, pred *query.Predicate, indexes map[string]metadata.IndexInfo, fieldName string, descending bool, nullsFirst bool) interfaces.Plan {
+	ii, ok := indexes[fieldName]
+	if !ok || ii.IsComposite() || ii.IndexType() != index.INDEX_BTREE {
+		return nil
+	}
+
+	rng := index.KeyRange{Descending: descending}
+	rng.Low, rng.High, rng.LowInclusive, rng.HighInclusive = pred.Bounds(fieldName)
+	return NewIndexOrderedSelectPlan(tp, &ii, rng, nullsFirst)
+}
+
+// Estimates the records whose key lies in the range. Between integers it
+// is the share of the keys from the smallest to the largest that the range
+// covers, and for other keys a third of the records for each bound.
+func rangeRecords(ii *metadata.IndexInfo, rng index.KeyRange) int {
+	idx := ii.Open().(index.RangeIndex)
+	minKey, maxKey := idx.MinKey(), idx.MaxKey()
+	idx.Close()
+	if minKey == nil {
+		return 0
+	}
+
+	records := float64(ii.TableRecords())
+	first, okFirst := minKey.AsInteger()
+	last, okLast := maxKey.AsInteger()
+	low, okLow := integerBound(rng.Low, rng.LowInclusive, 1)
+	high, okHigh := integerBound(rng.High, rng.HighInclusive, -1)
+	if !okFirst || !okLast || !okLow || !okHigh {
+		for _, bound := range []*types.Constant{rng.Low, rng.High} {
+			if bound != nil {
+				records /= query.RANGE_REDUCTION_FACTOR
+			}
+		}
+		return int(math.Ceil(records))
+	}
+
+	if rng.Low == nil {
+		low = float64(first)
+	}
+	if rng.High == nil {
+		high = float64(last)
+	}
+	covered := min(high, float64(last)) - max(low, float64(first)) + 1
+	return int(math.Ceil(records * max(0, covered) / (float64(last) - float64(first) + 1)))
+}
+
+// Returns the integer nearest to a bound that lies in its range, step being
+// the direction into the range. Returns false if the bound is not an integer.
+func integerBound(bound *types.Constant, inclusive bool, step int) (float64, bool) {
+	if bound == nil {
+		return 0, true
+	}
+	n, ok := bound.AsInteger()
+	if !ok {
+		return 0, false
+	}
+	if !inclusive {
+		return float64(n) + float64(step), true
+	}
+	return float64(n), true
+}
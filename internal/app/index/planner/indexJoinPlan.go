@@ -6,12 +6,12 @@ import (
 	"centauri/internal/app/metadata"
 	"centauri/internal/app/record"
 	"centauri/internal/app/record/schema"
+	"fmt"
 )
 
 // Represents a plan node for index join operations.
 // It corresponds to the indexjoin relational algebra operator.
 type IndexJoinPlan struct {
-	interfaces.Plan
 	p1        interfaces.Plan
 	p2        interfaces.Plan
 	ii        *metadata.IndexInfo
@@ -70,3 +70,11 @@ func (ijp *IndexJoinPlan) DistinctValues(fldName string) int {
 func (ijp *IndexJoinPlan) Schema() *schema.Schema {
 	return ijp.schema
 }
+
+func (ijp *IndexJoinPlan) Describe() string {
+	return fmt.Sprintf("index join %s on %s", ijp.ii.IndexName(), ijp.joinField)
+}
+
+func (ijp *IndexJoinPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{ijp.p1, ijp.p2}
+}
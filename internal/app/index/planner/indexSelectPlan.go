@@ -1,28 +1,81 @@
 package planner
 
 import (
+	"centauri/internal/app/index"
 	"centauri/internal/app/index/query"
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/metadata"
 	"centauri/internal/app/record"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
+	"fmt"
+	"strings"
 )
 
 // Represents a plan node for index selection operations.
 // It corresponds to the indexselect relational algebra operator.
+// It selects the records whose key is one of a list of values, usually
+// a single one, or lies in a range of keys of a B-tree index.
 type IndexSelectPlan struct {
-	interfaces.Plan
-	p   interfaces.Plan
-	ii  *metadata.IndexInfo
-	val types.Constant
+	p       interfaces.Plan
+	ii      *metadata.IndexInfo
+	vals    []types.Constant
+	rngs    []index.KeyRange // The ranges of keys selected instead of values, if any
+	records int              // The estimated records in the ranges
 }
 
 func NewIndexSelectPlan(p interfaces.Plan, ii *metadata.IndexInfo, val types.Constant) interfaces.Plan {
 	return &IndexSelectPlan{
-		p:   p,
-		ii:  ii,
-		val: val,
+		p:    p,
+		ii:   ii,
+		vals: []types.Constant{val},
+	}
+}
+
+// Creates a plan selecting the records whose key is any of the values,
+// looking up each in turn. The values must be distinct.
+func NewIndexListSelectPlan(p interfaces.Plan, ii *metadata.IndexInfo, vals []*types.Constant) interfaces.Plan {
+	isp := &IndexSelectPlan{
+		p:  p,
+		ii: ii,
+	}
+	for _, val := range vals {
+		isp.vals = append(isp.vals, *val)
+	}
+	return isp
+}
+
+// Creates a plan selecting the records whose key lies in the range, in key
+// order. The index must be a B-tree index, whose smallest and largest keys
+// are read to estimate the records in the range.
+func NewIndexRangeSelectPlan(p interfaces.Plan, ii *metadata.IndexInfo, rng index.KeyRange) interfaces.Plan {
+	return &IndexSelectPlan{
+		p:       p,
+		ii:      ii,
+		rngs:    []index.KeyRange{rng},
+		records: rangeRecords(ii, rng),
+	}
+}
+
+// Creates a plan selecting the records whose key lies in the range in the
+// order of their keys, or in reverse if the range is descending, so that
+// they need no sort. A range open at both ends selects every record, those
+// with null keys first or last. The index must be a B-tree index.
+func NewIndexOrderedSelectPlan(p interfaces.Plan, ii *metadata.IndexInfo, rng index.KeyRange, nullsFirst bool) interfaces.Plan {
+	if rng.Low != nil || rng.High != nil {
+		return NewIndexRangeSelectPlan(p, ii, rng)
+	}
+
+	nulls := index.KeyRange{Nulls: true}
+	rngs := []index.KeyRange{rng, nulls}
+	if nullsFirst {
+		rngs = []index.KeyRange{nulls, rng}
+	}
+	return &IndexSelectPlan{
+		p:       p,
+		ii:      ii,
+		rngs:    rngs,
+		records: ii.TableRecords(),
 	}
 }
 
@@ -36,26 +89,86 @@ func (isp *IndexSelectPlan) Open() interfaces.Scan {
 
 	idx := isp.ii.Open()
 
-	return query.NewIndexSelectScan(ts, idx, isp.val)
+	if len(isp.rngs) > 0 {
+		return query.NewIndexRangeSelectScan(ts, idx.(index.RangeIndex), isp.rngs)
+	}
+	return query.NewIndexListSelectScan(ts, idx, isp.vals)
 }
 
 // The number of block accesses to compute the index selection, which
-// is the same as the index traversal cost plus the number of matching
-// data records.
+// is the same as the index traversal cost for each value plus the number
+// of matching data records. A range is found by a single traversal.
 func (isp *IndexSelectPlan) BlocksAccessed() int {
-	return isp.ii.BlocksAccessed() + isp.RecordsOutput()
+	if len(isp.rngs) > 0 {
+		return isp.ii.BlocksAccessed() + isp.RecordsOutput()
+	}
+	return isp.ii.BlocksAccessed()*len(isp.vals) + isp.RecordsOutput()
 }
 
 // Estimates the number of output records in the index selection,
-// which is the same as the number of search key values for the index.
+// which is the same as the number of search key values for the index
+// for each value, or the records estimated to lie in the range.
 func (isp *IndexSelectPlan) RecordsOutput() int {
-	return isp.ii.RecordsOutput()
+	if len(isp.rngs) > 0 {
+		return isp.records
+	}
+	return isp.ii.RecordsOutput() * len(isp.vals)
 }
 
+// Estimates the distinct values of a field in the selected records,
+// which can't outnumber the records
 func (isp *IndexSelectPlan) DistinctValues(fldName string) int {
-	return isp.ii.DistinctValues(fldName)
+	if len(isp.rngs) > 0 {
+		return min(isp.p.DistinctValues(fldName), max(isp.RecordsOutput(), 1))
+	}
+	return min(isp.ii.DistinctValues(fldName), max(isp.RecordsOutput(), 1))
 }
 
 func (isp *IndexSelectPlan) Schema() *schema.Schema {
 	return isp.p.Schema()
 }
+
+func (isp *IndexSelectPlan) Describe() string {
+	if len(isp.rngs) > 0 {
+		rng := isp.rngs[0]
+		if rng.Nulls {
+			rng = isp.rngs[1]
+		}
+		order := ""
+		if rng.Descending {
+			order = " descending"
+		}
+		if rng.Low == nil && rng.High == nil {
+			return fmt.Sprintf("index scan %s%s", isp.ii.IndexName(), order)
+		}
+
+		var bounds []string
+		if rng.Low != nil {
+			op := ">"
+			if rng.LowInclusive {
+				op = ">="
+			}
+			bounds = append(bounds, op+" "+rng.Low.String())
+		}
+		if rng.High != nil {
+			op := "<"
+			if rng.HighInclusive {
+				op = "<="
+			}
+			bounds = append(bounds, op+" "+rng.High.String())
+		}
+		return fmt.Sprintf("index select %s %s%s", isp.ii.IndexName(), strings.Join(bounds, " and "), order)
+	}
+	if len(isp.vals) == 1 {
+		return fmt.Sprintf("index select %s = %s", isp.ii.IndexName(), isp.vals[0].String())
+	}
+	vals := make([]string, len(isp.vals))
+	for i, val := range isp.vals {
+		vals[i] = val.String()
+	}
+	return fmt.Sprintf("index select %s in (%s)", isp.ii.IndexName(), strings.Join(vals, ", "))
+}
+
+func (isp *IndexSelectPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{isp.p}
+}
@@ -1,17 +1,24 @@
 package planner
 
 import (
+	"centauri/internal/app/index"
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/metadata"
 	"centauri/internal/app/parse"
 	"centauri/internal/app/plan"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record"
 	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
 )
 
 // Modification of the basic update update planner that dispatches each update statement to the corresponding index planner.
 type IndexUpdatePlanner struct {
 	plan.UpdatePlanner
-	mdm *metadata.MetaDataManager
+	mdm   *metadata.MetaDataManager
+	newTx func() *tx.Transaction // starts the transactions of batched bulk loads
 }
 
 func NewIndexUpdatePlanner(mdm *metadata.MetaDataManager) *IndexUpdatePlanner {
@@ -20,76 +27,219 @@ func NewIndexUpdatePlanner(mdm *metadata.MetaDataManager) *IndexUpdatePlanner {
 	}
 }
 
-// Performs an INSERT operation by:
+// Sets the function used to start a transaction for each batch of a bulk load.
+// Without it, COPY loads all records in the transaction of the statement.
+func (iup *IndexUpdatePlanner) SetTxFactory(newTx func() *tx.Transaction) {
+	iup.newTx = newTx
+}
+
+// Performs an INSERT operation by, for each record of the VALUES list:
 // 1. Creating a new record in the base table
 // 2. Updating all relevant indexes for the new record
-func (iup *IndexUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx *tx.Transaction) int {
+// The first AUTO_INCREMENT value generated is recorded as the last insert id
+// of the transaction. Returns the number of records inserted.
+// If a record has the key of another in a unique index, the records
+// inserted before it are removed again and index.ErrUniqueViolation is returned.
+func (iup *IndexUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx *tx.Transaction) (int, error) {
 	// Get the target table name from the insert operation
 	tableName := data.TableName()
 
 	// Create a plan for accessing the target table
-	p := plan.NewTablePlan(tx, tableName, iup.mdm)
+	p, err := plan.NewTablePlan(tx, tableName, iup.mdm)
+	if err != nil {
+		return 0, err
+	}
 
-	// Open the table scan in update mode and insert a new blank record
-	s := p.Open().(interfaces.UpdateScan)
-	s.Insert()           // Create space for new record
-	rid, _ := s.GetRID() // Get the Record ID of the new record
+	fields := data.Fields()
+	layout := p.(*plan.TablePlan).Layout()
+	if err := plan.CheckAssignable(layout, fields); err != nil {
+		return 0, err
+	}
 
 	// Retrieve all indexes defined on this table
 	indexes := iup.mdm.GetIndexInfo(tableName, tx)
 
-	// Get fields and values to insert
-	fields := data.Fields()
-	values := data.Values()
+	// The records are inserted in the transaction of the statement,
+	// so a failing one undoes the others when it rolls back. A transaction
+	// that goes on after a unique index turned a record down doesn't roll
+	// back, so the others are removed here.
+	count := 0
+	var firstID int64
+	var rids []*types.RID
+	for _, values := range data.Rows() {
+		if len(fields) != len(values) {
+			return count, fmt.Errorf("field count (%d) does not match values count (%d)", len(fields), len(values))
+		}
+		id, rid, err := iup.insertRow(p.(*plan.TablePlan), tableName, fields, values, indexes, tx)
+		if errors.Is(err, index.ErrUniqueViolation) {
+			if undoErr := undoInserts(p.(*plan.TablePlan), tableName, rids, indexes); undoErr != nil {
+				return count, undoErr
+			}
+			return 0, err
+		}
+		if err != nil {
+			return count, err
+		}
+		rids = append(rids, rid)
+		if firstID == 0 {
+			firstID = id
+		}
+		count++
+	}
+	if firstID != 0 {
+		tx.SetLastInsertID(firstID)
+	}
 
-	if len(fields) != len(values) {
-		panic("field/value count mismatch in insert operation")
+	iup.mdm.AdjustRowCount(tableName, count, tx)
+	iup.mdm.RecordModification(tableName, count)
+
+	return count, nil
+}
+
+// Inserts a record with the values of the fields into the table,
+// along with its entries in the indexes. The fields left out take their defaults.
+// If a unique index already has the key of the record for another one, the
+// record is removed again and index.ErrUniqueViolation is returned.
+// Returns the AUTO_INCREMENT value generated for the record, 0 if none was,
+// and the RID of the record.
+func (iup *IndexUpdatePlanner) insertRow(p *plan.TablePlan, tableName string, fields []string, values []*types.Constant, indexes map[string]metadata.IndexInfo, tx *tx.Transaction) (int64, *types.RID, error) {
+	layout := p.Layout()
+	fields, values, id, err := plan.ApplyAutoIncrement(iup.mdm, tableName, layout, fields, values, tx)
+	if err != nil {
+		return 0, nil, err
+	}
+	fields, values, err = plan.ApplyDefaults(layout, fields, values)
+	if err != nil {
+		return 0, nil, err
 	}
 
-	// Process each field in the insert operation
+	// The record of a partitioned table goes in the partition its key routes to
+	tp, err := p.Route(fields, values)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Open the table scan in update mode and insert a new blank record
+	s, err := plan.OpenUpdateScan(tp, tableName)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer s.Close()
+
+	// Create space for new record
+	if err := s.Insert(); err != nil {
+		return 0, nil, err
+	}
+	// Get the Record ID of the new record
+	rid, err := s.GetRID()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Set the value of each field in the insert operation
 	for i, fieldName := range fields {
-		// Get the next value from the iterator
-		val := values[i]
+		if err := s.SetVal(fieldName, values[i]); err != nil {
+			return 0, nil, err
+		}
+	}
 
-		// Set the value in the actual record
-		s.SetVal(fieldName, val)
+	// Compute the stored generated fields
+	generated, err := plan.SetGeneratedFields(s, layout, nil)
+	if err != nil {
+		return 0, nil, err
+	}
 
-		// Update index if exists for this child
-		if ii, exists := indexes[fieldName]; exists {
-			idx := ii.Open()
-			idx.Insert(val, rid)
-			idx.Close()
+	// Index the fields that have an index, once the unique ones are known
+	// to accept the record
+	var indexed []metadata.IndexInfo
+	for _, fieldNames := range [][]string{fields, generated} {
+		for _, fieldName := range fieldNames {
+			if ii, exists := indexes[fieldName]; exists && !ii.IsComposite() {
+				indexed = append(indexed, ii)
+			}
+		}
+	}
+	for _, ii := range indexed {
+		idx := ii.Open()
+		err := plan.CheckUnique(&ii, idx, s.GetVal(ii.FieldNames()[0]), rid)
+		idx.Close()
+		if err != nil {
+			if delErr := s.Delete(); delErr != nil {
+				return 0, nil, delErr
+			}
+			return 0, nil, err
 		}
 	}
+	for _, ii := range indexed {
+		idx := ii.Open()
+		idx.Insert(s.GetVal(ii.FieldNames()[0]), rid)
+		idx.Close()
+	}
+	return id, rid, nil
+}
 
-	s.Close()
+// Removes the records at the RIDs, which an INSERT statement added to the
+// table before a unique index turned one of its records down, along with
+// their index entries
+func undoInserts(tp *plan.TablePlan, tableName string, rids []*types.RID, indexes map[string]metadata.IndexInfo) error {
+	if len(rids) == 0 {
+		return nil
+	}
+	s, err := plan.OpenUpdateScan(tp, tableName)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
 
-	return 1
+	for _, rid := range rids {
+		if err := s.MoveToRID(rid); err != nil {
+			return err
+		}
+		for fldName, ii := range indexes {
+			if ii.IsComposite() {
+				continue
+			}
+			idx := ii.Open()
+			idx.Delete(s.GetVal(fldName), rid)
+			idx.Close()
+		}
+		if err := s.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Performs a DELETE operation by:
-// 1. Finding all matching records using the provided predicate
+// 1. Finding all matching records using the provided predicate,
+// through an index when one applies
 // 2. Removing each record's entries from all indexes
 // 3. Deleting the actual records
-func (iup *IndexUpdatePlanner) ExecuteDelete(data *parse.DeleteData, tx *tx.Transaction) int {
+func (iup *IndexUpdatePlanner) ExecuteDelete(data *parse.DeleteData, tx *tx.Transaction) (int, error) {
 	tableName := data.TableName()
 
-	p := plan.NewTablePlan(tx, tableName, iup.mdm)
-	p = plan.NewSelectPlan(p, data.Pred())
+	p, err := plan.NewTablePlan(tx, tableName, iup.mdm)
+	if err != nil {
+		return 0, err
+	}
 
 	// Retrieve all indexes defined on the table
 	indexes := iup.mdm.GetIndexInfo(tableName, tx)
 
-	s := p.Open().(interfaces.UpdateScan)
-	count := 0
-
-	// Process each matching record
-	for s.Next() {
+	count, err := forEachMatch(p.(*plan.TablePlan), tableName, data.Pred(), indexes, func(s interfaces.UpdateScan) error {
 		// Get the record's identifier
-		rid, _ := s.GetRID()
+		rid, err := s.GetRID()
+		if err != nil {
+			return err
+		}
 
 		// Remove this record from all indexes
 		for fldName, ii := range indexes {
+			// Composite keys aren't stored by the index structures
+			if ii.IsComposite() {
+				continue
+			}
+
 			// Get the field value from the record
 			val := s.GetVal(fldName)
 
@@ -100,66 +250,239 @@ func (iup *IndexUpdatePlanner) ExecuteDelete(data *parse.DeleteData, tx *tx.Tran
 		}
 
 		// Delete the actual record
-		s.Delete()
-		count++
-	}
+		return s.Delete()
+	})
 
-	s.Close()
+	iup.mdm.AdjustRowCount(tableName, -count, tx)
+	iup.mdm.RecordModification(tableName, count)
 
-	return count
+	return count, err
 }
 
 // Performs an UPDATE operation by:
-//  1. Finding all matching records using the provided predicate
+//  1. Finding all matching records using the provided predicate,
+//     through an index when one applies
 //  2. For each record:
-//     a. Updating the target field value
-//     b. Updating the corresponding index (if exists)
-//     by removing old entry and adding new entry
-func (iup *IndexUpdatePlanner) ExecuteModify(data *parse.ModifyData, tx *tx.Transaction) int {
+//     a. Updating the target field value and the stored fields generated from it
+//     b. Updating the corresponding indexes (if exist)
+//     by removing old entries and adding new entries
+//
+// If a record would get the key of another in a unique index, the records
+// updated before it get their values back and index.ErrUniqueViolation is returned.
+func (iup *IndexUpdatePlanner) ExecuteModify(data *parse.ModifyData, tx *tx.Transaction) (int, error) {
 	tableName := data.TableName()
 	fieldName := data.TargetField()
+	p, err := plan.NewTablePlan(tx, tableName, iup.mdm)
+	if err != nil {
+		return 0, err
+	}
+	tp := p.(*plan.TablePlan)
+	layout := tp.Layout()
+	changed := []string{fieldName}
+	if err := plan.CheckAssignable(layout, changed); err != nil {
+		return 0, err
+	}
+	indexes := iup.mdm.GetIndexInfo(tableName, tx)
 
-	// Create a plan for the base table and apply the selection predicate
-	p := plan.NewTablePlan(tx, tableName, iup.mdm)
-	p = plan.NewSelectPlan(p, data.Pred())
+	// Open the indexes on the field being modified and on the stored fields computed from it
+	idxs := make(map[string]index.Index)
+	for _, fldName := range append(changed, plan.StoredGeneratedFields(layout, changed)...) {
+		if ii, ok := indexes[fldName]; ok && !ii.IsComposite() {
+			idx := ii.Open()
+			defer idx.Close()
+			idxs[fldName] = idx
+		}
+	}
 
-	// Check if there's an index on the field being modified
-	indexes := iup.mdm.GetIndexInfo(tableName, tx)
-	ii := indexes[fieldName]
-	idx := ii.Open()
+	// Records whose partition key changes move to another partition.
+	// Partitioned tables have no indexes to update.
+	movesPartition := tp.PartitionKey() == fieldName
+	mover := plan.NewPartitionMover(tp)
 
-	// Open the scan in update mode
-	s := p.Open().(interfaces.UpdateScan)
-	count := 0
+	// The previous values of the records updated so far, to put back
+	// if a unique index turns a record down
+	var undo []fieldChange
 
-	// Process each matching record
-	for s.Next() {
+	count, err := forEachMatch(tp, tableName, data.Pred(), indexes, func(s interfaces.UpdateScan) error {
 		// Evaluate the new value expression in the context of current record
 		newVal := data.NewValue().Evaluate(s)
+		if err := plan.CheckNotNull(layout.Schema(), fieldName, newVal); err != nil {
+			return err
+		}
+		oldKey := s.GetVal(fieldName)
+
+		if movesPartition {
+			if err := s.SetVal(fieldName, newVal); err != nil {
+				return err
+			}
+			if _, err := plan.SetGeneratedFields(s, layout, changed); err != nil {
+				return err
+			}
+			return mover.Check(s, oldKey)
+		}
+
+		var rid *types.RID
+		if len(idxs) > 0 {
+			if rid, err = s.GetRID(); err != nil {
+				return err
+			}
+		}
+		if err := setIndexedField(s, rid, layout, fieldName, newVal, indexes, idxs); err != nil {
+			return err
+		}
+		if len(idxs) > 0 {
+			undo = append(undo, fieldChange{rid: rid, oldVal: oldKey})
+		}
+		return nil
+	})
+
+	if errors.Is(err, index.ErrUniqueViolation) {
+		if undoErr := undoModify(tp, tableName, layout, fieldName, undo, indexes, idxs); undoErr != nil {
+			return count, undoErr
+		}
+		return 0, err
+	}
+	if err == nil {
+		err = mover.Finish()
+	}
+
+	iup.mdm.RecordModification(tableName, count)
 
-		// Get the old value before modification
-		oldVal := s.GetVal(fieldName)
+	return count, err
+}
+
+// The value a field of a record had before an UPDATE changed it
+type fieldChange struct {
+	rid    *types.RID
+	oldVal *types.Constant
+}
+
+// Sets the field of the record the scan is on to val, along with the stored
+// fields generated from it, and moves the entries of the record at rid in
+// the indexes idxs to their new keys. If a unique one already has a new key
+// for another record, the record is left as it was and
+// index.ErrUniqueViolation is returned.
+func setIndexedField(s interfaces.UpdateScan, rid *types.RID, layout *record.Layout, fieldName string, val *types.Constant, indexes map[string]metadata.IndexInfo, idxs map[string]index.Index) error {
+	changed := []string{fieldName}
+	oldVal := s.GetVal(fieldName)
+
+	// Get the old keys before modification
+	oldKeys := make(map[string]*types.Constant, len(idxs))
+	for fldName := range idxs {
+		oldKeys[fldName] = s.GetVal(fldName)
+	}
 
-		// Update the actual record
-		s.SetVal(data.TargetField(), newVal)
+	// Update the actual record
+	if err := s.SetVal(fieldName, val); err != nil {
+		return err
+	}
+	if _, err := plan.SetGeneratedFields(s, layout, changed); err != nil {
+		return err
+	}
 
-		// If there's an index on this field, update it
-		if idx != nil {
-			rid, _ := s.GetRID()
-			// Remove the old index entry and add new one
-			idx.Delete(oldVal, rid)
-			idx.Insert(newVal, rid)
+	for fldName, idx := range idxs {
+		ii := indexes[fldName]
+		if err := plan.CheckUnique(&ii, idx, s.GetVal(fldName), rid); err != nil {
+			if err := s.SetVal(fieldName, oldVal); err != nil {
+				return err
+			}
+			if _, err := plan.SetGeneratedFields(s, layout, changed); err != nil {
+				return err
+			}
+			return err
 		}
-		count++
 	}
 
-	if idx != nil {
-		idx.Close()
+	// Remove the old index entries and add new ones
+	for fldName, idx := range idxs {
+		idx.Delete(oldKeys[fldName], rid)
+		idx.Insert(s.GetVal(fldName), rid)
+	}
+	return nil
+}
+
+// Puts back the previous values of the records an UPDATE statement changed
+// before a unique index turned one of them down, latest first, along with
+// their index entries
+func undoModify(tp *plan.TablePlan, tableName string, layout *record.Layout, fieldName string, changes []fieldChange, indexes map[string]metadata.IndexInfo, idxs map[string]index.Index) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	s, err := plan.OpenUpdateScan(tp, tableName)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	for i := len(changes) - 1; i >= 0; i-- {
+		if err := s.MoveToRID(changes[i].rid); err != nil {
+			return err
+		}
+		if err := setIndexedField(s, changes[i].rid, layout, fieldName, changes[i].oldVal, indexes, idxs); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
+// Calls fn with the scan positioned at each record of the table that
+// satisfies the predicate, and returns the number of records fn handled.
+// The records are looked up through an index when the predicate equates
+// an indexed field with a constant or restricts it to a list of constants,
+// and found by scanning the table otherwise.
+// The records an index finds are collected before fn is called, since the
+// changes fn makes to the index entries would otherwise disturb the lookup.
+// The partitions of a partitioned table that can't hold matching records aren't scanned.
+func forEachMatch(tp *plan.TablePlan, tableName string, pred *query.Predicate, indexes map[string]metadata.IndexInfo, fn func(s interfaces.UpdateScan) error) (int, error) {
+	p := MakeIndexSelect(tp, pred, indexes)
+	if p == nil {
+		s, err := plan.OpenUpdateScan(plan.NewSelectPlan(tp.Prune(pred), pred), tableName)
+		if err != nil {
+			return 0, err
+		}
+		defer s.Close()
+
+		count := 0
+		for s.Next() {
+			if err := fn(s); err != nil {
+				return count, err
+			}
+			count++
+		}
+		return count, nil
+	}
+
+	// The index selects on one term; the rest of the predicate still applies
+	s, err := plan.OpenUpdateScan(plan.NewSelectPlan(p, pred), tableName)
+	if err != nil {
+		return 0, err
+	}
+	var rids []*types.RID
+	for s.Next() {
+		rid, err := s.GetRID()
+		if err != nil {
+			s.Close()
+			return 0, err
+		}
+		rids = append(rids, rid)
+	}
 	s.Close()
 
-	return count
+	ts, err := plan.OpenUpdateScan(tp, tableName)
+	if err != nil {
+		return 0, err
+	}
+	defer ts.Close()
+
+	for i, rid := range rids {
+		if err := ts.MoveToRID(rid); err != nil {
+			return i, err
+		}
+		if err := fn(ts); err != nil {
+			return i, err
+		}
+	}
+	return len(rids), nil
 }
 
 // Creates a new table in the database.
@@ -168,9 +491,35 @@ func (iup *IndexUpdatePlanner) ExecuteModify(data *parse.ModifyData, tx *tx.Tran
 // 2. Updates the metadata catalog
 // Returns:
 //   - 0 on successful creation
-func (iup *IndexUpdatePlanner) ExecuteCreateTable(data *parse.CreateTableData, tx *tx.Transaction) int {
-	iup.mdm.CreateTable(data.TableName(), data.NewSchema(), tx)
-	return 0
+//   - metadata.ErrTableExists if a table or view of the name exists
+func (iup *IndexUpdatePlanner) ExecuteCreateTable(data *parse.CreateTableData, tx *tx.Transaction) (int, error) {
+	options, err := record.ParseStorageOptions(data.Options())
+	if err != nil {
+		return 0, err
+	}
+	if err := options.Validate(tx.BlockSize()); err != nil {
+		return 0, err
+	}
+	if err := record.ValidateCollations(data.NewSchema()); err != nil {
+		return 0, err
+	}
+	if err := plan.ValidateGenerated(data.NewSchema()); err != nil {
+		return 0, err
+	}
+	if err := plan.ValidateFieldOptions(data.NewSchema()); err != nil {
+		return 0, err
+	}
+	options.Partitioning = plan.NewPartitioning(data.Partitioning())
+	if options.Partitioning != nil {
+		if err := options.Partitioning.Validate(data.NewSchema()); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := iup.mdm.CreateTableWithOptions(data.TableName(), data.NewSchema(), options, tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
 }
 
 // Creates a new view in the database.
@@ -179,13 +528,135 @@ func (iup *IndexUpdatePlanner) ExecuteCreateTable(data *parse.CreateTableData, t
 // 2. Updates the metadata catalog
 // Returns:
 //   - 0 on successful creation
-func (iup *IndexUpdatePlanner) ExecuteCreateView(data *parse.CreateViewData, tx *tx.Transaction) int {
-	iup.mdm.CreateView(data.ViewName(), data.ViewDef(), tx)
-	return 0
+//   - metadata.ErrTableExists if a table or view of the name exists
+func (iup *IndexUpdatePlanner) ExecuteCreateView(data *parse.CreateViewData, tx *tx.Transaction) (int, error) {
+	if err := iup.mdm.CreateView(data.ViewName(), data.ViewDef(), data.Tables(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Creates a new index on a table field and adds the entries of the
+// records the table already has, see plan.BuildIndex.
+// Returns metadata.ErrTableNotFound if the table doesn't exist, and
+// index.ErrUniqueViolation if the index is unique and two records have the
+// same value, in which case the index is removed again.
+func (iup *IndexUpdatePlanner) ExecuteCreateIndex(data *parse.CreateIndexData, tx *tx.Transaction) (int, error) {
+	layout, err := iup.mdm.GetLayout(data.TableName(), tx)
+	if err != nil {
+		return 0, err
+	}
+	if err := plan.CheckIndexable(layout, data.FieldName()); err != nil {
+		return 0, err
+	}
+	if err := iup.mdm.CreateIndex(data.IndexName(), data.TableName(), data.FieldName(), data.IndexType(), data.IsUnique(), tx); err != nil {
+		return 0, err
+	}
+	for _, ii := range iup.mdm.GetIndexInfo(data.TableName(), tx) {
+		if ii.IndexName() == data.IndexName() {
+			if _, err := plan.BuildIndex(tx, data.TableName(), &ii, iup.mdm); err != nil {
+				iup.mdm.DropIndex(data.IndexName(), tx)
+				return 0, err
+			}
+		}
+	}
+	return 0, nil
+}
+
+// Removes a table, its records and the files storing them.
+// Returns metadata.ErrTableNotFound if the table doesn't exist, and
+// metadata.ErrDependentObjects if views or indexes depend on it without CASCADE.
+func (iup *IndexUpdatePlanner) ExecuteDropTable(data *parse.DropTableData, tx *tx.Transaction) (int, error) {
+	if err := iup.mdm.DropTable(data.TableName(), data.Cascade(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Removes an index and the files storing it.
+// Returns metadata.ErrIndexNotFound if the index doesn't exist.
+func (iup *IndexUpdatePlanner) ExecuteDropIndex(data *parse.DropIndexData, tx *tx.Transaction) (int, error) {
+	if err := iup.mdm.DropIndex(data.IndexName(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Removes a view.
+// Returns metadata.ErrTableNotFound if the view doesn't exist, and
+// metadata.ErrDependentObjects if other views are defined on it without CASCADE.
+func (iup *IndexUpdatePlanner) ExecuteDropView(data *parse.DropViewData, tx *tx.Transaction) (int, error) {
+	if err := iup.mdm.DropView(data.ViewName(), data.Cascade(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Adds a field to a table. The field is null in the existing records,
+// unless it is a stored generated field, which is computed for them.
+// Returns metadata.ErrTableNotFound if the table doesn't exist, and
+// metadata.ErrFieldExists if it already has a field of the name.
+func (iup *IndexUpdatePlanner) ExecuteAddColumn(data *parse.AddColumnData, tx *tx.Transaction) (int, error) {
+	layout, err := iup.mdm.GetLayout(data.TableName(), tx)
+	if err != nil {
+		return 0, err
+	}
+	if err := plan.ValidateAddedField(layout, data.Field()); err != nil {
+		return 0, err
+	}
+	if err := iup.mdm.AddColumn(data.TableName(), data.Field(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Removes a field from a table along with its values.
+// Returns metadata.ErrFieldNotFound if the table has no such field, and
+// metadata.ErrDependentObjects if an index, view or generated field uses it.
+func (iup *IndexUpdatePlanner) ExecuteDropColumn(data *parse.DropColumnData, tx *tx.Transaction) (int, error) {
+	if err := iup.mdm.DropColumn(data.TableName(), data.FieldName(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
 }
 
-// Creates a new index on a table field
-func (iup *IndexUpdatePlanner) ExecuteCreateIndex(data *parse.CreateIndexData, tx *tx.Transaction) int {
-	iup.mdm.CreateIndex(data.IndexName(), data.TableName(), data.FieldName(), tx)
-	return 0
+// Loads the records of a CSV file into a table, inserting the
+// index entries of each batch after its records are loaded.
+func (iup *IndexUpdatePlanner) ExecuteCopy(data *parse.CopyData, tx *tx.Transaction) (int, error) {
+	return plan.NewBulkLoader(iup.mdm, iup.newTx).Copy(data, tx)
+}
+
+// Creates a new user.
+// Returns metadata.ErrUserExists if a user with the name already exists.
+func (iup *IndexUpdatePlanner) ExecuteCreateUser(data *parse.CreateUserData, tx *tx.Transaction) (int, error) {
+	if err := iup.mdm.CreateUser(data.UserName(), data.Password(), data.Superuser(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Changes the password of a user.
+// Returns metadata.ErrUserNotFound if the user doesn't exist.
+func (iup *IndexUpdatePlanner) ExecuteAlterUser(data *parse.AlterUserData, tx *tx.Transaction) (int, error) {
+	if err := iup.mdm.AlterUserPassword(data.UserName(), data.Password(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Gives a user privileges on a table or view.
+// Returns metadata.ErrUserNotFound or metadata.ErrTableNotFound if either doesn't exist.
+func (iup *IndexUpdatePlanner) ExecuteGrant(data *parse.GrantData, tx *tx.Transaction) (int, error) {
+	if err := iup.mdm.Grant(data.UserName(), data.TableName(), data.Privileges(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Takes privileges on a table or view away from a user.
+func (iup *IndexUpdatePlanner) ExecuteRevoke(data *parse.RevokeData, tx *tx.Transaction) (int, error) {
+	if err := iup.mdm.Revoke(data.UserName(), data.TableName(), data.Privileges(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
 }
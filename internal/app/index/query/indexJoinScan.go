@@ -4,6 +4,7 @@ import (
 	"centauri/internal/app/index"
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
 )
 
@@ -14,6 +15,8 @@ type IndexJoinScan struct {
 	idx       index.Index
 	joinField string
 	rhs       *record.TableScan
+	lhsDone   bool // whether the LHS scan has no more records
+	nullKey   bool // whether the join value of the LHS record is null, which joins no record
 }
 
 func NewIndexJoinScan(lhs interfaces.Scan, idx index.Index, joinField string, rhs *record.TableScan) *IndexJoinScan {
@@ -32,25 +35,29 @@ func NewIndexJoinScan(lhs interfaces.Scan, idx index.Index, joinField string, rh
 // Positions the LHS scan at its first record and the index before the first join value.
 func (ijs *IndexJoinScan) BeforeFirst() {
 	ijs.lhs.BeforeFirst()
-	ijs.lhs.Next()
-	ijs.resetIndex()
+	ijs.lhsDone = !ijs.lhs.Next()
+	if !ijs.lhsDone {
+		ijs.resetIndex()
+	}
 }
 
 // Moves the scan to the next record.
 // Returns false if there are no more records to scan.
 func (ijs *IndexJoinScan) Next() bool {
 
-	for {
-		if ijs.idx.Next() {
+	for !ijs.lhsDone {
+		if !ijs.nullKey && ijs.idx.Next() {
 			ijs.rhs.MoveToRID(ijs.idx.GetDataRid())
 			return true
 		}
 		if !ijs.lhs.Next() {
+			ijs.lhsDone = true
 			return false
 		}
 
 		ijs.resetIndex()
 	}
+	return false
 }
 
 // Returns the integer value of the specified field.
@@ -79,6 +86,14 @@ func (ijs *IndexJoinScan) HasField(fldName string) bool {
 	return ijs.rhs.HasField(fldName) || ijs.lhs.HasField(fldName)
 }
 
+// Returns the fields of the LHS scan followed by those of the indexed table
+func (ijs *IndexJoinScan) Schema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddAll(ijs.lhs.Schema())
+	sch.AddAll(ijs.rhs.Schema())
+	return sch
+}
+
 func (ijs *IndexJoinScan) Close() {
 	ijs.lhs.Close()
 	ijs.idx.Close()
@@ -87,5 +102,8 @@ func (ijs *IndexJoinScan) Close() {
 
 func (ijs *IndexJoinScan) resetIndex() {
 	searchKey := ijs.lhs.GetVal(ijs.joinField)
-	ijs.idx.BeforeFirst(searchKey)
+	ijs.nullKey = searchKey.IsNull()
+	if !ijs.nullKey {
+		ijs.idx.BeforeFirst(searchKey)
+	}
 }
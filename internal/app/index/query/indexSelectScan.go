@@ -4,45 +4,95 @@ import (
 	"centauri/internal/app/index"
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
 )
 
 // Represents a scan for index selection operations.
-// It implements the scan interface for indexed selection queries
+// It implements the scan interface for indexed selection queries, and the
+// update scan interface so that update commands can change the records it finds
 type IndexSelectScan struct {
-	interfaces.Scan
-	ts  *record.TableScan
-	idx index.Index
-	val types.Constant
+	interfaces.UpdateScan
+	ts      *record.TableScan
+	idx     index.Index
+	vals    []types.Constant // The selection constants, looked up in turn
+	current int              // The constant or range the index is positioned on
+	rngs    []index.KeyRange // The ranges of keys looked up in turn instead of constants, if any
 }
 
-func NewIndexSelectScan(ts *record.TableScan, idx index.Index, val types.Constant) interfaces.Scan {
+func NewIndexSelectScan(ts *record.TableScan, idx index.Index, val types.Constant) interfaces.UpdateScan {
+	return NewIndexListSelectScan(ts, idx, []types.Constant{val})
+}
+
+// Creates a scan of the records whose key is any of the selection
+// constants, which must be distinct
+func NewIndexListSelectScan(ts *record.TableScan, idx index.Index, vals []types.Constant) interfaces.UpdateScan {
+	scan := &IndexSelectScan{
+		ts:   ts,
+		idx:  idx,
+		vals: vals,
+	}
+
+	scan.BeforeFirst()
+	return scan
+}
+
+// Creates a scan of the records whose key lies in any of the ranges,
+// looked up in turn in the order of their keys, or in reverse for
+// descending ranges
+func NewIndexRangeSelectScan(ts *record.TableScan, idx index.RangeIndex, rngs []index.KeyRange) interfaces.UpdateScan {
 	scan := &IndexSelectScan{
-		ts:  ts,
-		idx: idx,
-		val: val,
+		ts:   ts,
+		idx:  idx,
+		rngs: rngs,
 	}
 
 	scan.BeforeFirst()
 	return scan
 }
 
-// Positions the scan before the first record, which means positioning the index before the first instance of the selection constant.
+// Positions the scan before the first record, which means positioning the index before the first instance of the first selection constant,
+// or before the first key of the first range.
 func (iss *IndexSelectScan) BeforeFirst() {
-	iss.idx.BeforeFirst(&iss.val)
+	iss.current = 0
+	if len(iss.rngs) > 0 {
+		iss.idx.(index.RangeIndex).BeforeFirstRange(iss.rngs[0])
+	} else if len(iss.vals) > 0 {
+		iss.idx.BeforeFirst(&iss.vals[0])
+	}
 }
 
 // Moves to the next record, which means moving the index to the next
-// record satisfying the selection constant. Returns false if there are
-// no more such index records. If successful, moves the table scan to the
-// corresponding data record.
+// record satisfying the selection constant, or the first record of the
+// next constant. Returns false if there are no more such index records.
+// Ranges are moved through the same way.
+// If successful, moves the table scan to the corresponding data record.
 func (iss *IndexSelectScan) Next() bool {
-	ok := iss.idx.Next()
-	if ok {
-		rid := iss.idx.GetDataRid()
-		iss.ts.MoveToRID(rid)
+	if len(iss.rngs) > 0 {
+		for iss.current < len(iss.rngs) {
+			if iss.idx.Next() {
+				iss.ts.MoveToRID(iss.idx.GetDataRid())
+				return true
+			}
+			iss.current++
+			if iss.current < len(iss.rngs) {
+				iss.idx.(index.RangeIndex).BeforeFirstRange(iss.rngs[iss.current])
+			}
+		}
+		return false
+	}
+	for iss.current < len(iss.vals) {
+		if iss.idx.Next() {
+			rid := iss.idx.GetDataRid()
+			iss.ts.MoveToRID(rid)
+			return true
+		}
+		iss.current++
+		if iss.current < len(iss.vals) {
+			iss.idx.BeforeFirst(&iss.vals[iss.current])
+		}
 	}
-	return ok
+	return false
 }
 
 // Returns the integer value of the specified field from the current data record.
@@ -62,8 +112,43 @@ func (iss *IndexSelectScan) HasField(fldName string) bool {
 	return iss.ts.HasField(fldName)
 }
 
+func (iss *IndexSelectScan) Schema() *schema.Schema {
+	return iss.ts.Schema()
+}
+
 // Closes the scan by closing both the index and the table scan.
 func (iss *IndexSelectScan) Close() {
 	iss.idx.Close()
 	iss.ts.Close()
 }
+
+// Changes the value of the specified field in the current data record.
+// The index is left as it is; keeping it up to date is up to the caller.
+func (iss *IndexSelectScan) SetVal(fldName string, val *types.Constant) error {
+	return iss.ts.SetVal(fldName, val)
+}
+
+func (iss *IndexSelectScan) SetInt(fldName string, val int) error {
+	return iss.ts.SetInt(fldName, val)
+}
+
+func (iss *IndexSelectScan) SetString(fldName string, val string) error {
+	return iss.ts.SetString(fldName, val)
+}
+
+func (iss *IndexSelectScan) Insert() error {
+	return iss.ts.Insert()
+}
+
+// Deletes the current data record. Its index entries are left for the caller to remove.
+func (iss *IndexSelectScan) Delete() error {
+	return iss.ts.Delete()
+}
+
+func (iss *IndexSelectScan) GetRID() (*types.RID, error) {
+	return iss.ts.GetRID()
+}
+
+func (iss *IndexSelectScan) MoveToRID(rid *types.RID) error {
+	return iss.ts.MoveToRID(rid)
+}
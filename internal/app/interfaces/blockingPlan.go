@@ -0,0 +1,9 @@
+package interfaces
+
+// BlockingPlan is implemented by plans that read all of their input before
+// they output their first record, such as sorts and materializations.
+// Reading only a few records of such a plan costs as much as reading them all.
+type BlockingPlan interface {
+	// Marks the plan as blocking
+	Blocking()
+}
@@ -13,18 +13,8 @@ type Plan interface {
 	// that can be used to iterate over the result set.
 	Open() Scan
 
-	// Returns the estimated number of disk blocks that need to be read when executing
-	// this plan. This is crucial for cost-based query optimization decisions.
-	BlocksAccessed() int
-
-	// Returns the number of records that this plan will produce. This helps in determining
-	//  the size of result sets and making optimization decisions for subsequent operations.
-	RecordsOutput() int
-
-	// Returns the estimated number of distinct values that will appear in the specified field
-	//  within the records produced by this plan. This is valuable for selectivity estimation
-	// and join optimization.
-	DistinctValues(fieldName string) int
+	// Estimates the cost and output of the plan
+	PlanStats
 
 	// Returns the Schema object describing the structure of the records that this plan produces.
 	// This includes information about field names, types, and constraints.
@@ -0,0 +1,11 @@
+package interfaces
+
+// PlanNode is implemented by plans that can describe themselves, so a
+// tree of plans can be printed, e.g. to show how a slow query ran.
+type PlanNode interface {
+	// Returns a one-line description of the operator, such as "select a = 1"
+	Describe() string
+
+	// Returns the plans the operator reads its records from
+	Children() []Plan
+}
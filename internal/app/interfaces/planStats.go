@@ -0,0 +1,18 @@
+package interfaces
+
+// PlanStats holds the estimates a plan gives the optimizer to compare it with
+// other plans for the same records, without running any of them.
+type PlanStats interface {
+	// Returns the estimated number of disk blocks that need to be read when executing
+	// this plan. This is crucial for cost-based query optimization decisions.
+	BlocksAccessed() int
+
+	// Returns the number of records that this plan will produce. This helps in determining
+	//  the size of result sets and making optimization decisions for subsequent operations.
+	RecordsOutput() int
+
+	// Returns the estimated number of distinct values that will appear in the specified field
+	//  within the records produced by this plan. This is valuable for selectivity estimation
+	// and join optimization.
+	DistinctValues(fieldName string) int
+}
@@ -1,6 +1,9 @@
 package interfaces
 
-import "centauri/internal/app/types"
+import (
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+)
 
 // Scan defines the interface that will be implemented by each query scan.
 // Each relational algebra operator(selection, projection, join, etc.) has its
@@ -9,7 +12,7 @@ import "centauri/internal/app/types"
 // This interface provide methods to:
 // - Navigate through records (beforeFirst, next)
 // - Access Field values (getInt, getString, getVal)
-// - Query metadata (hasField)
+// - Query metadata (hasField, schema)
 // - Manage resources
 //
 // This is the foundational interface for the query processing engine,
@@ -40,6 +43,10 @@ type Scan interface {
 	// Checks if the scan contains the specified field
 	HasField(fieldName string) bool
 
+	// Returns the schema of the records the scan produces: the name,
+	// type and nullability of each field, in output order
+	Schema() *schema.Schema
+
 	// Releases any resourves held by this scan
 	// This includes:
 	//  - Closing any subscans
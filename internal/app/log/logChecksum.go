@@ -0,0 +1,107 @@
+package log
+
+import (
+	"centauri/internal/app/file"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Returned when a log record doesn't match its checksum, or its size runs
+// past its block, e.g. because writing the block was torn by a crash
+var ErrCorruptLogRecord = errors.New("corrupt log record")
+
+// Set in the size of a record followed by a checksum. Records written
+// by older versions have none, and their size never has it set.
+const CHECKSUM_FLAG = 1 << 31
+
+// The bytes a record takes in its block besides its contents: its size and checksum
+const RECORD_OVERHEAD = 8
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Writes a record at position pos of a log page: its size, its contents,
+// then the checksum of both
+func writeRecord(p *file.Page, pos int, rec []byte) {
+	buf := p.Contents()
+	end := pos + 4 + len(rec)
+	binary.BigEndian.PutUint32(buf[pos:], uint32(len(rec))|CHECKSUM_FLAG)
+	copy(buf[pos+4:end], rec)
+	binary.BigEndian.PutUint32(buf[end:], crc32.Checksum(buf[pos:end], crcTable))
+}
+
+// Reads the record at position pos of a log page, checking its checksum,
+// and returns it with the number of bytes it takes in the page
+func readRecord(p *file.Page, pos int) ([]byte, int, error) {
+	buf := p.Contents()
+	if pos < 4 || pos+4 > len(buf) {
+		return nil, 0, fmt.Errorf("%w: position %d is outside the block", ErrCorruptLogRecord, pos)
+	}
+
+	size := binary.BigEndian.Uint32(buf[pos:])
+	checked := size&CHECKSUM_FLAG != 0
+	size &^= CHECKSUM_FLAG
+
+	end := pos + 4 + int(size)
+	taken := 4 + int(size)
+	if checked {
+		taken += 4
+	}
+	if pos+taken > len(buf) {
+		return nil, 0, fmt.Errorf("%w: record of %d bytes at position %d runs past the block", ErrCorruptLogRecord, size, pos)
+	}
+	if checked && binary.BigEndian.Uint32(buf[end:]) != crc32.Checksum(buf[pos:end], crcTable) {
+		return nil, 0, fmt.Errorf("%w: checksum mismatch at position %d", ErrCorruptLogRecord, pos)
+	}
+
+	rec := make([]byte, size)
+	copy(rec, buf[pos+4:end])
+	return rec, taken, nil
+}
+
+// Returns the position of the newest intact record of the last block of a
+// log. A crash while the block was written can tear the records appended
+// last; the log ends before them, so the position of the records written
+// before them is returned, or the end of the block if there are none. A
+// damaged record followed by newer intact ones is left to readRecord to
+// report, as it can't be the result of a torn write.
+func intactBoundary(p *file.Page) int {
+	buf := p.Contents()
+	boundary := int(p.GetInt(0))
+	if boundary < 4 || boundary == len(buf) {
+		// Nothing has been written to the block yet
+		return len(buf)
+	}
+	from := 4
+	if boundary < len(buf) {
+		if _, _, err := readRecord(p, boundary); err == nil {
+			return boundary
+		}
+		from = boundary + 1
+	}
+
+	for pos := from; pos < len(buf); pos++ {
+		if intactUpToEnd(p, pos) {
+			return pos
+		}
+	}
+	return len(buf)
+}
+
+// Checks if the records from position pos to the end of a log page are
+// intact and carry checksums
+func intactUpToEnd(p *file.Page, pos int) bool {
+	buf := p.Contents()
+	for pos < len(buf) {
+		if pos+4 > len(buf) || binary.BigEndian.Uint32(buf[pos:])&CHECKSUM_FLAG == 0 {
+			return false
+		}
+		_, size, err := readRecord(p, pos)
+		if err != nil {
+			return false
+		}
+		pos += size
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package log
+
+import (
+	"centauri/internal/app/file"
+	"fmt"
+)
+
+// LogForwardIterator iterates over the records of a log in the order they
+// were written, oldest first, starting at a given LSN. Unlike LogReader it
+// reads a log that its LogManager has flushed, up to the block that was
+// the last one when the iterator was created.
+type LogForwardIterator struct {
+	fm        *file.FileManager
+	logfile   string
+	lastBlock int      // last block of the log to read
+	block     int      // block the current records were read from
+	records   [][]byte // records of the current block, oldest first
+	lsns      []int    // LSN of each record of the current block
+	index     int      // index of the record Next returns next
+	lsn       int      // LSN of the record last returned by Next
+	err       error    // error reading the next block, returned by Next
+}
+
+// Creates an iterator over the records of the log up to block lastBlock,
+// starting at the first record whose LSN is at least fromLSN
+func NewLogForwardIterator(fm *file.FileManager, logfile string, lastBlock int, fromLSN int) (*LogForwardIterator, error) {
+	block, _ := LSNLocation(max(fromLSN, 0), fm.BlockSize())
+	fi := &LogForwardIterator{
+		fm:        fm,
+		logfile:   logfile,
+		lastBlock: lastBlock,
+	}
+	if err := fi.load(min(block, lastBlock)); err != nil {
+		return nil, err
+	}
+	for fi.index < len(fi.lsns) && fi.lsns[fi.index] < fromLSN {
+		fi.index++
+	}
+	fi.advance()
+	return fi, nil
+}
+
+// Checks if there are more records to read
+func (fi *LogForwardIterator) HasNext() bool {
+	return fi.err != nil || fi.index < len(fi.records)
+}
+
+// Returns the next record of the log and advances the iterator
+func (fi *LogForwardIterator) Next() ([]byte, error) {
+	if fi.err != nil {
+		return nil, fi.err
+	}
+	if fi.index >= len(fi.records) {
+		return nil, fmt.Errorf("no more records in log %s", fi.logfile)
+	}
+
+	rec := fi.records[fi.index]
+	fi.lsn = fi.lsns[fi.index]
+	fi.index++
+	fi.advance()
+	return rec, nil
+}
+
+// Returns the LSN of the record last returned by Next
+func (fi *LogForwardIterator) LSN() int {
+	return fi.lsn
+}
+
+// Moves on to the next block holding records once the current one is read
+func (fi *LogForwardIterator) advance() {
+	for fi.index >= len(fi.records) && fi.block < fi.lastBlock {
+		if err := fi.load(fi.block + 1); err != nil {
+			fi.err = err
+			return
+		}
+	}
+}
+
+// Reads the records of a block
+func (fi *LogForwardIterator) load(blockNum int) error {
+	block := file.NewBlockID(fi.logfile, blockNum)
+	records, positions, err := readBlockRecords(fi.fm, block)
+	if err != nil {
+		return err
+	}
+
+	fi.block = blockNum
+	fi.records = records
+	fi.lsns = make([]int, len(positions))
+	for i, pos := range positions {
+		fi.lsns[i] = LSNAt(blockNum, pos, fi.fm.BlockSize())
+	}
+	fi.index = 0
+	return nil
+}
+
+// Reads the records of a log block, oldest first, with the position of each
+// in the block. The log manager fills a block from its end towards its
+// start, so they're read newest first and reversed.
+func readBlockRecords(fm *file.FileManager, block *file.BlockID) ([][]byte, []int, error) {
+	page := file.NewPage(fm.BlockSize())
+	if err := fm.Read(block, page); err != nil {
+		return nil, nil, fmt.Errorf("error reading block %v: %w", block, err)
+	}
+
+	// A block whose boundary hasn't been written yet holds no records
+	boundary := int(page.GetInt(0))
+	if boundary < 4 {
+		boundary = fm.BlockSize()
+	}
+
+	var records [][]byte
+	var positions []int
+	for pos := boundary; pos < fm.BlockSize(); {
+		rec, size, err := readRecord(page, pos)
+		if err != nil {
+			return nil, nil, fmt.Errorf("log block %v: %w", block, err)
+		}
+		records = append(records, rec)
+		positions = append(positions, pos)
+		pos += size
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+		positions[i], positions[j] = positions[j], positions[i]
+	}
+	return records, positions, nil
+}
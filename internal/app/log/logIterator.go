@@ -12,9 +12,11 @@ type LogIterator struct {
 	page         *file.Page
 	currentPos   int
 	boundary     int
+	lsn          int // LSN of the record last returned by Next
 }
 
-// NewLogIterator creates a new iterator for log records
+// NewLogIterator creates a new iterator for log records, starting
+// at the newest intact record of blk, the last block of the log
 func NewLogIterator(fm *file.FileManager, blk *file.BlockID) *LogIterator {
 	iter := &LogIterator{
 		fm:           fm,
@@ -29,8 +31,8 @@ func NewLogIterator(fm *file.FileManager, blk *file.BlockID) *LogIterator {
 		return nil
 	}
 
-	// Set the boundary and current position
-	iter.boundary = int(iter.page.GetInt(0))
+	// Set the boundary and current position, past any records torn by a crash
+	iter.boundary = intactBoundary(iter.page)
 	iter.currentPos = iter.boundary
 
 	return iter
@@ -59,14 +61,22 @@ func (li *LogIterator) Next() ([]byte, error) {
 	}
 
 	// Get the record bytes at the current position in the page
-	rec := li.page.GetBytes(li.currentPos)
-	// Advance the position by 4 (integer size) plus the length of the record
-	// The 4 bytes represent the record length prefix
-	li.currentPos += 4 + len(rec)
+	rec, size, err := readRecord(li.page, li.currentPos)
+	if err != nil {
+		return nil, fmt.Errorf("log block %v: %w", li.currentBlock, err)
+	}
+	li.lsn = LSNAt(li.currentBlock.Number(), li.currentPos, li.fm.BlockSize())
+	// Advance the position past the record, its length prefix and checksum
+	li.currentPos += size
 	// Return the record bytes and nil error
 	return rec, nil
 }
 
+// Returns the LSN of the record last returned by Next
+func (li *LogIterator) LSN() int {
+	return li.lsn
+}
+
 // Moves the iterator to the specified block and initializes the reading position.
 // It reads the block contents into the page buffer and sets up boundary and current position
 // for reading records from the block.
@@ -77,6 +87,8 @@ func (li *LogIterator) moveToBlock(block *file.BlockID) error {
 		return fmt.Errorf("error reading block %v: %w", block, err)
 	}
 
+	li.currentBlock = block
+
 	// Get the boundary value from the first integer (4 bytes) in the page
 	// This boundary marks the position where the last record ends
 	li.boundary = int(li.page.GetInt(0))
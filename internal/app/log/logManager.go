@@ -4,10 +4,14 @@ import (
 	"centauri/internal/app/file"
 	"fmt"
 	"sync"
+	"time"
 )
 
 // LogManager manages the system log
-// Handles writing and reading of log records with recovery support
+// Handles writing and reading of log records with recovery support.
+// The log sequence number (LSN) of a record is derived from where it is
+// stored, so LSNs grow with every record appended and keep identifying
+// the same records after the log is reopened.
 type LogManager struct {
 	fm           *file.FileManager // file manager reference
 	logfile      string            // name of log file
@@ -15,6 +19,7 @@ type LogManager struct {
 	currentBlock *file.BlockID     // current block being written
 	latestLSN    int               // Latest log sequence number
 	lastSavedLSN int               // Last saved log sequence number
+	retention    time.Duration     // how far back the history in the log may be read, no limit if 0
 	mu           sync.Mutex        // mutex for thread safety
 }
 
@@ -48,8 +53,15 @@ func NewLogManager(fm *file.FileManager, logfile string) (*LogManager, error) {
 		if err := fm.Read(logManager.currentBlock, logManager.logpage); err != nil {
 			return nil, fmt.Errorf("error reading last block: %w", err)
 		}
+		// Records torn by a crash are overwritten by the ones appended next
+		logManager.logpage.SetInt(0, int32(intactBoundary(logManager.logpage)))
 	}
 
+	// Records up to the boundary of the last block are already on disk
+	boundary := int(logManager.logpage.GetInt(0))
+	logManager.latestLSN = LSNAt(logManager.currentBlock.Number(), boundary, fm.BlockSize())
+	logManager.lastSavedLSN = logManager.latestLSN
+
 	return logManager, nil
 }
 
@@ -59,9 +71,9 @@ func (lm *LogManager) Append(logrec []byte) (int, error) {
 	lm.mu.Lock()
 	defer lm.mu.Unlock()
 
-	boundary := int(lm.logpage.GetInt(0)) // current write position
-	recsize := len(logrec)                // size of new record
-	bytesneeded := recsize + 4            // total space needed (record + size)
+	boundary := int(lm.logpage.GetInt(0))    // current write position
+	recsize := len(logrec)                   // size of new record
+	bytesneeded := recsize + RECORD_OVERHEAD // total space needed (record + size + checksum)
 
 	// Check if record fits in current block
 	if boundary-bytesneeded < 4 {
@@ -79,14 +91,58 @@ func (lm *LogManager) Append(logrec []byte) (int, error) {
 		boundary = int(lm.logpage.GetInt(0))
 	}
 
-	recpos := boundary - bytesneeded    // calculate write position
-	lm.logpage.SetBytes(recpos, logrec) // write record
-	lm.logpage.SetInt(0, int32(recpos)) // Update record boundary
+	recpos := boundary - bytesneeded        // calculate write position
+	writeRecord(lm.logpage, recpos, logrec) // write record
+	lm.logpage.SetInt(0, int32(recpos))     // Update record boundary
 
-	lm.latestLSN++
+	lm.latestLSN = LSNAt(lm.currentBlock.Number(), recpos, lm.fm.BlockSize())
 	return lm.latestLSN, nil
 }
 
+// Returns the LSN of the record stored at position pos of a log block.
+// A block is filled from its end towards its start, so later records of
+// a block are stored at lower positions.
+func LSNAt(block int, pos int, blockSize int) int {
+	return block*blockSize + blockSize - pos
+}
+
+// Returns the block holding the record with the LSN and the record's
+// position in it, the inverse of LSNAt
+func LSNLocation(lsn int, blockSize int) (int, int) {
+	if lsn <= 0 {
+		return 0, blockSize
+	}
+	block := (lsn - 1) / blockSize
+	return block, block*blockSize + blockSize - lsn
+}
+
+// Returns the LSN of the last record appended to the log
+func (lm *LogManager) LatestLSN() int {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	return lm.latestLSN
+}
+
+// Returns the size of a log block, which bounds the size of a log record
+func (lm *LogManager) BlockSize() int {
+	return lm.fm.BlockSize()
+}
+
+// Sets how far back the history in the log may be read, e.g. by queries
+// of past states of the database. A retention of 0 sets no limit.
+func (lm *LogManager) SetRetention(retention time.Duration) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	lm.retention = retention
+}
+
+// Returns how far back the history in the log may be read, 0 if there's no limit
+func (lm *LogManager) Retention() time.Duration {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	return lm.retention
+}
+
 // appendNewBlock creates and initializes a new log block
 func (lm *LogManager) appendNewBlock() (*file.BlockID, error) {
 	// Append new block to log file
@@ -128,6 +184,28 @@ func (lm *LogManager) Iterator() (*LogIterator, error) {
 	return NewLogIterator(lm.fm, lm.currentBlock), nil
 }
 
+// Returns an iterator over the log records oldest first, starting at
+// the first record whose LSN is at least fromLSN
+func (lm *LogManager) ForwardIterator(fromLSN int) (*LogForwardIterator, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if err := lm.flush(); err != nil {
+		return nil, fmt.Errorf("error flushing log: %w", err)
+	}
+
+	return NewLogForwardIterator(lm.fm, lm.logfile, lm.currentBlock.Number(), fromLSN)
+}
+
+// Close writes the current log page to disk. The log
+// must not be appended to afterwards.
+func (lm *LogManager) Close() error {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	return lm.flush()
+}
+
 // flush writes the current log page to disk
 func (lm *LogManager) flush() error {
 	if err := lm.fm.Write(lm.currentBlock, lm.logpage); err != nil {
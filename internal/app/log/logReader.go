@@ -0,0 +1,86 @@
+package log
+
+import (
+	"centauri/internal/app/file"
+	"fmt"
+)
+
+// Position of a record in a log file: the block holding it and
+// the number of records written to that block before it
+type LogPosition struct {
+	Block int
+	Index int
+}
+
+// LogReader reads the records of a log file in the order they were
+// written, oldest first. Unlike LogIterator it can be used on a log that
+// another LogManager, possibly in another process, keeps appending to:
+// once it has read every record, Next returns the ones appended since.
+type LogReader struct {
+	fm      *file.FileManager
+	logfile string
+	pos     LogPosition // position of the next record to return
+	records [][]byte    // records of the current block, oldest first
+	loaded  bool
+}
+
+// NewLogReader creates a reader that starts at the given position
+func NewLogReader(fm *file.FileManager, logfile string, pos LogPosition) *LogReader {
+	return &LogReader{
+		fm:      fm,
+		logfile: logfile,
+		pos:     pos,
+	}
+}
+
+// Returns the position of the record Next returns next. Reading can be
+// resumed from it by a new reader, e.g. after a restart.
+func (lr *LogReader) Position() LogPosition {
+	return lr.pos
+}
+
+// Returns the next record of the log, or nil if every record
+// written so far has been read
+func (lr *LogReader) Next() ([]byte, error) {
+	for {
+		if lr.loaded && lr.pos.Index < len(lr.records) {
+			rec := lr.records[lr.pos.Index]
+			lr.pos.Index++
+			return rec, nil
+		}
+
+		// The block may have grown since it was read, and
+		// only the last block of the log can grow
+		size, err := lr.fm.Length(lr.logfile)
+		if err != nil {
+			return nil, fmt.Errorf("error checking log size: %w", err)
+		}
+		if lr.pos.Block >= size {
+			return nil, nil
+		}
+
+		if err := lr.load(); err != nil {
+			return nil, err
+		}
+		if lr.pos.Index < len(lr.records) {
+			continue
+		}
+
+		if lr.pos.Block+1 >= size {
+			return nil, nil
+		}
+		lr.pos = LogPosition{Block: lr.pos.Block + 1}
+		lr.loaded = false
+	}
+}
+
+// Reads the records of the current block
+func (lr *LogReader) load() error {
+	records, _, err := readBlockRecords(lr.fm, file.NewBlockID(lr.logfile, lr.pos.Block))
+	if err != nil {
+		return err
+	}
+	lr.records = records
+	lr.loaded = true
+	return nil
+}
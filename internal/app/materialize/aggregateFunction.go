@@ -12,8 +12,11 @@ type AggregateFunction interface {
 	ProcessFirst(s interfaces.Scan)
 	// Updates the aggregation state with subsequent rows.
 	ProcessNext(s interfaces.Scan)
-	// Returns the name of the field being aggregated.
+	// Returns the name of the field holding the aggregated value.
 	FieldName() string
+	// Returns the name of the field being aggregated, or "" if the function
+	// counts records or values rather than returning one of the field's values.
+	SourceField() string
 	// Returns the current aggregated value as a Constant type.
-	value() *types.Constant
+	Value() *types.Constant
 }
@@ -0,0 +1,47 @@
+package materialize
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/types"
+)
+
+// Implements the approx_count_distinct aggregation function.
+// It estimates the number of distinct values of a field in a group with a
+// HyperLogLog sketch, in constant space rather than by sorting the values.
+type ApproxCountDistinctFunction struct {
+	fieldName string
+	sketch    *types.HyperLogLog
+}
+
+func NewApproxCountDistinctFn(fieldName string) *ApproxCountDistinctFunction {
+	return &ApproxCountDistinctFunction{
+		fieldName: fieldName,
+	}
+}
+
+// Starts a new sketch holding the field value in the current record.
+// This is called for the first record in the group.
+func (a *ApproxCountDistinctFunction) ProcessFirst(s interfaces.Scan) {
+	a.sketch = types.NewHyperLogLog()
+	a.ProcessNext(s)
+}
+
+// Nulls aren't values, so they aren't counted
+func (a *ApproxCountDistinctFunction) ProcessNext(s interfaces.Scan) {
+	if val := s.GetVal(a.fieldName); !val.IsNull() {
+		a.sketch.Add(val)
+	}
+}
+
+func (a *ApproxCountDistinctFunction) FieldName() string {
+	return "approx_count_distinctof" + a.fieldName
+}
+
+// Returns "", since the estimate is an integer whatever the field's type
+func (a *ApproxCountDistinctFunction) SourceField() string {
+	return ""
+}
+
+func (a *ApproxCountDistinctFunction) Value() *types.Constant {
+	return types.NewConstantInt(a.sketch.Estimate())
+}
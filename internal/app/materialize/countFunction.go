@@ -0,0 +1,37 @@
+package materialize
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/types"
+)
+
+// Implements the count(*) aggregation function.
+// It counts the records in a group
+type CountFunction struct {
+	count int
+}
+
+func NewCountFn() *CountFunction {
+	return &CountFunction{}
+}
+
+// Starts a new count at one, for the first record in the group.
+func (c *CountFunction) ProcessFirst(s interfaces.Scan) {
+	c.count = 1
+}
+
+func (c *CountFunction) ProcessNext(s interfaces.Scan) {
+	c.count++
+}
+
+func (c *CountFunction) FieldName() string {
+	return "count"
+}
+
+func (c *CountFunction) SourceField() string {
+	return ""
+}
+
+func (c *CountFunction) Value() *types.Constant {
+	return types.NewConstantInt(c.count)
+}
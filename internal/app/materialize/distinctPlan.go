@@ -0,0 +1,49 @@
+package materialize
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+)
+
+// Removes the duplicate records of an underlying plan, as a plain UNION
+// does. The records are sorted on all their fields, so that duplicates are
+// next to each other, and each is output once.
+type DistinctPlan struct {
+	p interfaces.Plan
+}
+
+func NewDistinctPlan(tx *tx.Transaction, p interfaces.Plan) *DistinctPlan {
+	return &DistinctPlan{
+		p: NewSortPlan(tx, p, AscAll(p.Schema().Fields())),
+	}
+}
+
+func (dp *DistinctPlan) Open() interfaces.Scan {
+	return NewDistinctScan(dp.p.Open())
+}
+
+func (dp *DistinctPlan) BlocksAccessed() int {
+	return dp.p.BlocksAccessed()
+}
+
+// Assumes no records are duplicates, as there is no estimate of how many are
+func (dp *DistinctPlan) RecordsOutput() int {
+	return dp.p.RecordsOutput()
+}
+
+func (dp *DistinctPlan) DistinctValues(fieldName string) int {
+	return dp.p.DistinctValues(fieldName)
+}
+
+func (dp *DistinctPlan) Schema() *schema.Schema {
+	return dp.p.Schema()
+}
+
+func (dp *DistinctPlan) Describe() string {
+	return "distinct"
+}
+
+func (dp *DistinctPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{dp.p}
+}
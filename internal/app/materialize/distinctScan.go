@@ -0,0 +1,60 @@
+package materialize
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+)
+
+// Implements the scan interface for removing duplicates from a sorted
+// scan. A record equal to the one before it is skipped; nulls equal each other.
+type DistinctScan struct {
+	s    interfaces.Scan
+	prev *GroupValue // The values of the current record, nil before the first
+}
+
+func NewDistinctScan(s interfaces.Scan) *DistinctScan {
+	return &DistinctScan{s: s}
+}
+
+// Positions the scan before the first record
+func (ds *DistinctScan) BeforeFirst() {
+	ds.s.BeforeFirst()
+	ds.prev = nil
+}
+
+// Advances to the next record differing from the current one
+func (ds *DistinctScan) Next() bool {
+	for ds.s.Next() {
+		val := NewGroupValue(ds.s, ds.s.Schema().Fields())
+		if ds.prev == nil || !ds.prev.Equals(val) {
+			ds.prev = val
+			return true
+		}
+	}
+	return false
+}
+
+func (ds *DistinctScan) GetInt(fieldName string) int {
+	return ds.s.GetInt(fieldName)
+}
+
+func (ds *DistinctScan) GetString(fieldName string) string {
+	return ds.s.GetString(fieldName)
+}
+
+func (ds *DistinctScan) GetVal(fieldName string) *types.Constant {
+	return ds.s.GetVal(fieldName)
+}
+
+func (ds *DistinctScan) HasField(fieldName string) bool {
+	return ds.s.HasField(fieldName)
+}
+
+func (ds *DistinctScan) Schema() *schema.Schema {
+	return ds.s.Schema()
+}
+
+func (ds *DistinctScan) Close() {
+	ds.s.Close()
+}
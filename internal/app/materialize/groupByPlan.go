@@ -4,6 +4,8 @@ import (
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
+	"fmt"
+	"strings"
 )
 
 // Represents a plan for the groupBy operator
@@ -16,26 +18,41 @@ type GroupByPlan struct {
 }
 
 func NewGroupPlan(tx *tx.Transaction, p interfaces.Plan, groupFields []string, aggFns []AggregateFunction) *GroupByPlan {
-	// Create a sort plan to ensure records are group properly
-	sortedPlan := newSortPlan(tx, p, groupFields)
-	// Init schema for the output
-	sch := schema.NewSchema()
-
-	for _, fieldName := range groupFields {
-		sch.Add(fieldName, p.Schema())
+	// Create a sort plan to ensure records are group properly.
+	// Without grouping fields all records form one group, in any order.
+	sortedPlan := p
+	if len(groupFields) > 0 {
+		sortedPlan = NewSortPlan(tx, p, AscAll(groupFields))
 	}
-	for _, fn := range aggFns {
-		sch.AddIntField(fn.FieldName())
-	}
-
 	return &GroupByPlan{
 		p:           sortedPlan,
 		groupFields: groupFields,
 		aggFns:      aggFns,
-		sch:         sch,
+		sch:         groupBySchema(p.Schema(), groupFields, aggFns),
 	}
 }
 
+// Returns the schema of the groups: the grouping fields, then a field
+// for each aggregation function
+func groupBySchema(src *schema.Schema, groupFields []string, aggFns []AggregateFunction) *schema.Schema {
+	sch := schema.NewSchema()
+
+	for _, fieldName := range groupFields {
+		sch.Add(fieldName, src)
+	}
+	for _, fn := range aggFns {
+		// The min or max of a field has the type of the field, a count or estimate is an integer
+		srcField := fn.SourceField()
+		if srcField == "" {
+			sch.AddIntField(fn.FieldName())
+			continue
+		}
+		sch.AddField(fn.FieldName(), src.DataType(srcField), src.Length(srcField))
+		sch.SetCollation(fn.FieldName(), src.Collation(srcField))
+	}
+	return sch
+}
+
 // Opens the plan and returns a Scan object to iterate over the result.
 // It returns a GroupByScan that processes the underlying sorted records
 func (g *GroupByPlan) Open() interfaces.Scan {
@@ -72,3 +89,22 @@ func (g *GroupByPlan) DistinctValues(fieldName string) int {
 func (g *GroupByPlan) Schema() *schema.Schema {
 	return g.sch
 }
+
+func (g *GroupByPlan) Describe() string {
+	aggs := make([]string, len(g.aggFns))
+	for i, fn := range g.aggFns {
+		aggs[i] = fn.FieldName()
+	}
+	if len(g.groupFields) == 0 {
+		return "aggregate " + strings.Join(aggs, ", ")
+	}
+	return fmt.Sprintf("group by %s computing %s", strings.Join(g.groupFields, ", "), strings.Join(aggs, ", "))
+}
+
+func (g *GroupByPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{g.p}
+}
+
+// Each group is output once all of its records are read, and the input
+// is sorted or read whole before the first one
+func (g *GroupByPlan) Blocking() {}
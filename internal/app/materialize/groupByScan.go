@@ -2,6 +2,7 @@ package materialize
 
 import (
 	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
 )
 
@@ -93,19 +94,31 @@ func (gbs *GroupByScan) GetVal(fieldName string) *types.Constant {
 	// Check if it's an aggregation field
 	for _, fn := range gbs.aggFns {
 		if fn.FieldName() == fieldName {
-			return fn.value()
+			return fn.Value()
 		}
 	}
 
 	panic("field" + fieldName + "not found")
 }
 
+// Returns the value of an integer field, 0 if it is null
 func (gbs *GroupByScan) GetInt(fieldName string) int {
-	return *gbs.GetVal(fieldName).AsInt()
+	if val := gbs.GetVal(fieldName); !val.IsNull() {
+		return *val.AsInt()
+	}
+	return 0
 }
 
+// Returns the value of a string field, "" if it is null
 func (gbs *GroupByScan) GetString(fieldName string) string {
-	return *gbs.GetVal(fieldName).AsString()
+	if val := gbs.GetVal(fieldName); !val.IsNull() {
+		return *val.AsString()
+	}
+	return ""
+}
+
+func (gbs *GroupByScan) Schema() *schema.Schema {
+	return groupBySchema(gbs.s.Schema(), gbs.groupFields, gbs.aggFns)
 }
 
 // Returns true if the specified field is either a grouping field or created by an aggregation fn.
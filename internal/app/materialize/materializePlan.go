@@ -14,11 +14,25 @@ import (
 // Key characterstics:
 // - Creates a physical copy of the source data into the temp table
 // - Useful for expensive subqueries that are referenced multiple times
+// - Shares the temp table between the scans open on it, e.g. for a CTE used twice
+// - Drops the temp table when the last of those scans closes
 // - Implements the Plan interface for integration with query execution
 type MaterializePlan struct {
 	interfaces.Plan
-	srcPlan interfaces.Plan
-	tx      *tx.Transaction
+	srcPlan   interfaces.Plan
+	tx        *tx.Transaction
+	temp      *TempTable // the materialized records, nil while no scan is open
+	consumers int        // number of open scans reading temp
+	stats     MaterializeStats
+}
+
+// Counts what a MaterializePlan wrote to temp tables and how often its
+// results were read
+type MaterializeStats struct {
+	Builds  int // times the source was materialized
+	Reuses  int // scans that read an already materialized temp table
+	Records int // records written to temp tables
+	Blocks  int // blocks written to temp tables
 }
 
 func NewMaterializePlan(tx *tx.Transaction, srcPlan interfaces.Plan) interfaces.Plan {
@@ -28,11 +42,24 @@ func NewMaterializePlan(tx *tx.Transaction, srcPlan interfaces.Plan) interfaces.
 	}
 }
 
-// Executes the materialization by:
+// Returns a scan over the materialized results, positioned at the beginning.
+// The first scan materializes the source, the scans opened while it is still
+// open share its temp table.
+func (mp *MaterializePlan) Open() interfaces.Scan {
+	if mp.temp == nil {
+		mp.temp = mp.materialize()
+	} else {
+		mp.stats.Reuses++
+	}
+	mp.consumers++
+
+	return &materializedScan{TableScan: mp.temp.Open(), mp: mp}
+}
+
+// Materializes the source by:
 // 1. Creating a temp table with the same schema as the source
 // 2. Copying all records from the source to the temp table
-// 3. Returning a scan positioned at the beginning of the materialized result
-func (mp *MaterializePlan) Open() interfaces.Scan {
+func (mp *MaterializePlan) materialize() *TempTable {
 	sch := mp.srcPlan.Schema()
 
 	// Create temp table to hold materialized results
@@ -46,13 +73,47 @@ func (mp *MaterializePlan) Open() interfaces.Scan {
 		for _, fieldName := range sch.Fields() {
 			dest.SetVal(fieldName, src.GetVal(fieldName))
 		}
+		mp.stats.Records++
 	}
 
 	src.Close()
-	// Reset position to beginning before returning
-	dest.BeforeFirst()
+	dest.Close()
+
+	blocks, _ := mp.tx.Size(record.TableFile(temp.TableName(), temp.GetLayout()))
+	mp.stats.Blocks += blocks
+	mp.stats.Builds++
+	return temp
+}
 
-	return dest
+// Called when a scan on the plan closes. The last one drops the temp table,
+// a later Open materializes the source again.
+func (mp *MaterializePlan) release() {
+	mp.consumers--
+	if mp.consumers == 0 {
+		mp.temp.Drop()
+		mp.temp = nil
+	}
+}
+
+// Returns what the plan has written to temp tables so far
+func (mp *MaterializePlan) Stats() MaterializeStats {
+	return mp.stats
+}
+
+// A scan over the temp table of a MaterializePlan
+type materializedScan struct {
+	*record.TableScan
+	mp     *MaterializePlan
+	closed bool
+}
+
+// Closes the scan, dropping the temp table if no other scan reads it
+func (ms *materializedScan) Close() {
+	ms.TableScan.Close()
+	if !ms.closed {
+		ms.closed = true
+		ms.mp.release()
+	}
 }
 
 // Estimates the number of block accesses required to materialize and read the
@@ -84,3 +145,14 @@ func (mp *MaterializePlan) DistinctValues(fieldName string) int {
 func (mp *MaterializePlan) Schema() *schema.Schema {
 	return mp.srcPlan.Schema()
 }
+
+func (mp *MaterializePlan) Describe() string {
+	return "materialize"
+}
+
+func (mp *MaterializePlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{mp.srcPlan}
+}
+
+// The input is copied to a temporary table before the first record is output
+func (mp *MaterializePlan) Blocking() {}
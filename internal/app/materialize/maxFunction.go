@@ -24,10 +24,14 @@ func (m *MaxFunction) ProcessFirst(s interfaces.Scan) {
 	m.val = s.GetVal(m.fieldName)
 }
 
+// Nulls are skipped, so the maximum is null only if every value is
 func (m *MaxFunction) ProcessNext(s interfaces.Scan) {
 	newVal := s.GetVal(m.fieldName)
+	if newVal.IsNull() {
+		return
+	}
 	// CompareTo returns > 0 if newVal > m.val
-	if newVal.CompareTo(m.val) > 0 {
+	if m.val.IsNull() || newVal.CompareTo(m.val) > 0 {
 		m.val = newVal
 	}
 }
@@ -36,6 +40,10 @@ func (m *MaxFunction) FieldName() string {
 	return "maxof" + m.fieldName
 }
 
+func (m *MaxFunction) SourceField() string {
+	return m.fieldName
+}
+
 func (m *MaxFunction) Value() *types.Constant {
 	return m.val
 }
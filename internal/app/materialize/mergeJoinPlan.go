@@ -4,6 +4,7 @@ import (
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
+	"fmt"
 	"math"
 )
 
@@ -18,13 +19,10 @@ type MergeJoinPlan struct {
 }
 
 func NewMergeJoinPlan(tx *tx.Transaction, p1 interfaces.Plan, p2 interfaces.Plan, fldname1 string, fldName2 string) *MergeJoinPlan {
-	// Create sort list for first plan with single field
-	sortList1 := []string{fldname1}
-	sortedP1 := newSortPlan(tx, p1, sortList1)
-
-	// Create sort list for second plan with single field
-	sortList2 := []string{fldName2}
-	sortedP2 := newSortPlan(tx, p2, sortList2)
+	// Sort both plans on their join field, in the ascending
+	// order the merge join scan walks them in
+	sortedP1 := NewSortPlan(tx, p1, []SortSpec{Asc(fldname1)})
+	sortedP2 := NewSortPlan(tx, p2, []SortSpec{Asc(fldName2)})
 
 	// Create the merged schema
 	sch := schema.NewSchema()
@@ -72,3 +70,14 @@ func (m *MergeJoinPlan) DistinctValues(fldname string) int {
 func (m *MergeJoinPlan) Schema() *schema.Schema {
 	return m.sch
 }
+
+func (m *MergeJoinPlan) Describe() string {
+	return fmt.Sprintf("merge join %s = %s", m.fldName1, m.fldName2)
+}
+
+func (m *MergeJoinPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{m.p1, m.p2}
+}
+
+// Both inputs are sorted before the first record is output
+func (m *MergeJoinPlan) Blocking() {}
@@ -2,6 +2,7 @@ package materialize
 
 import (
 	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
 )
 
@@ -70,6 +71,9 @@ func (m *MergeJoinScan) Next() bool {
 		} else if cmp > 0 {
 			// s2's value is smaller, advance it
 			hasMore2 = m.s2.Next()
+		} else if v1.IsNull() {
+			// Nulls sort together but join no record
+			hasMore1 = m.s1.Next()
 		} else {
 			// Found a match
 			m.s2.SavePosition()
@@ -106,3 +110,11 @@ func (m *MergeJoinScan) GetVal(fldname string) *types.Constant {
 func (m *MergeJoinScan) HasField(fldname string) bool {
 	return m.s1.HasField(fldname) || m.s2.HasField(fldname)
 }
+
+// Returns the fields of the LHS scan followed by those of the RHS scan
+func (m *MergeJoinScan) Schema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddAll(m.s1.Schema())
+	sch.AddAll(m.s2.Schema())
+	return sch
+}
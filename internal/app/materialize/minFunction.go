@@ -0,0 +1,49 @@
+package materialize
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/types"
+)
+
+// Implements the min aggregation function.
+// It keeps track of the minimum value seen for a specified field
+type MinFunction struct {
+	fieldName string
+	val       *types.Constant
+}
+
+func NewMinFn(fieldName string) *MinFunction {
+	return &MinFunction{
+		fieldName: fieldName,
+	}
+}
+
+// Starts a new minimum to be the field value in the current record.
+// This is called for the first record in the group.
+func (m *MinFunction) ProcessFirst(s interfaces.Scan) {
+	m.val = s.GetVal(m.fieldName)
+}
+
+// Nulls are skipped, so the minimum is null only if every value is
+func (m *MinFunction) ProcessNext(s interfaces.Scan) {
+	newVal := s.GetVal(m.fieldName)
+	if newVal.IsNull() {
+		return
+	}
+	// CompareTo returns < 0 if newVal < m.val
+	if m.val.IsNull() || newVal.CompareTo(m.val) < 0 {
+		m.val = newVal
+	}
+}
+
+func (m *MinFunction) FieldName() string {
+	return "minof" + m.fieldName
+}
+
+func (m *MinFunction) SourceField() string {
+	return m.fieldName
+}
+
+func (m *MinFunction) Value() *types.Constant {
+	return m.val
+}
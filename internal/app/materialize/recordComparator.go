@@ -1,40 +1,148 @@
 package materialize
 
-import "centauri/internal/app/interfaces"
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/types"
+	"strings"
+)
+
+// Where null values are placed by a sort
+const (
+	NULLS_DEFAULT = iota // last when ascending, first when descending
+	NULLS_FIRST
+	NULLS_LAST
+)
+
+// Specifies how a field orders records, as in ORDER BY name DESC NULLS LAST
+type SortSpec struct {
+	Field      string
+	Descending bool
+	Nulls      int // NULLS_DEFAULT, NULLS_FIRST or NULLS_LAST
+}
+
+// Returns a spec ordering the field in ascending order
+func Asc(field string) SortSpec {
+	return SortSpec{Field: field}
+}
+
+// Returns a spec ordering the field in descending order
+func Desc(field string) SortSpec {
+	return SortSpec{Field: field, Descending: true}
+}
+
+// Returns specs ordering the fields in ascending order
+func AscAll(fields []string) []SortSpec {
+	specs := make([]SortSpec, len(fields))
+	for i, field := range fields {
+		specs[i] = Asc(field)
+	}
+	return specs
+}
+
+// Reports whether nulls come before the other values
+func (s SortSpec) NullsFirst() bool {
+	if s.Nulls == NULLS_DEFAULT {
+		return s.Descending
+	}
+	return s.Nulls == NULLS_FIRST
+}
+
+// Compares two values of the field in the order of the spec.
+// A nil value stands for null.
+func (s SortSpec) Compare(val1, val2 *types.Constant) int {
+	if val1.IsNull() || val2.IsNull() {
+		// Nulls are equal to each other and placed apart from the
+		// other values, whatever the direction
+		if val1.IsNull() == val2.IsNull() {
+			return 0
+		}
+		if val1.IsNull() == s.NullsFirst() {
+			return -1
+		}
+		return 1
+	}
+
+	result := val1.CompareTo(val2)
+	if s.Descending {
+		return -result
+	}
+	return result
+}
+
+func (s SortSpec) String() string {
+	var sb strings.Builder
+	sb.WriteString(s.Field)
+	if s.Descending {
+		sb.WriteString(" desc")
+	}
+	switch s.Nulls {
+	case NULLS_FIRST:
+		sb.WriteString(" nulls first")
+	case NULLS_LAST:
+		sb.WriteString(" nulls last")
+	}
+	return sb.String()
+}
 
 // Implments comparison of database records for sorting operations.
 // It compares records based on a specified list of fields in priority order.
 // Key characterstics:
 // - Compares records from two Scan instances field-by-field
 // - Supports multi-field sorting (primary, secondary, etc. keys)
+// - Each field is sorted in its own direction, with nulls first or last
 // - Returns ordering according to first non-equal field comparison
 // - Implements consistent ordering for stable sorting
 type RecordComparator struct {
-	fields []string
+	specs []SortSpec
 }
 
-func NewRecordComparator(fields []string) *RecordComparator {
+func NewRecordComparator(specs []SortSpec) *RecordComparator {
 	return &RecordComparator{
-		fields: fields,
+		specs: specs,
 	}
 }
 
-// Compares the current records of two scans according to the field list.
+// Returns the sort specs in priority order
+func (rc *RecordComparator) Specs() []SortSpec {
+	return rc.specs
+}
+
+// Compares the current records of two scans according to the sort specs.
 // The comparison follows these rules:
 // 1. Fields are evaluated in the order specified during construction
-// 2. For each field, the corresponding values are compared
+// 2. For each field, the corresponding values are compared in the field's direction
 // 3. The first non-zero comparison result determines the overall ordering
 // 4. If all fields compare equal, return 0
 func (rc *RecordComparator) Compare(s1, s2 interfaces.Scan) int {
-	for _, fieldName := range rc.fields {
-		val1 := s1.GetVal(fieldName)
-		val2 := s2.GetVal(fieldName)
+	for _, spec := range rc.specs {
+		val1 := s1.GetVal(spec.Field)
+		val2 := s2.GetVal(spec.Field)
 
 		// Compare the two field values
-		result := val1.CompareTo(val2)
+		result := spec.Compare(val1, val2)
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// Compares two records held in memory as maps from field names to values,
+// by the same rules as Compare. A missing value is null.
+func (rc *RecordComparator) CompareRows(r1, r2 map[string]*types.Constant) int {
+	for _, spec := range rc.specs {
+		result := spec.Compare(r1[spec.Field], r2[spec.Field])
 		if result != 0 {
 			return result
 		}
 	}
 	return 0
 }
+
+func (rc *RecordComparator) String() string {
+	specs := make([]string, len(rc.specs))
+	for i, spec := range rc.specs {
+		specs[i] = spec.String()
+	}
+	return strings.Join(specs, ", ")
+}
@@ -5,12 +5,14 @@ import (
 	"centauri/internal/app/record"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"sort"
 )
 
 // Implements a query plan that sorts the results of an underlying query.
 // It uses an external merge-sort algorithmn that:
-// 1. Splits the input into sorted runs
-// 2. Merges runs in iterations until only 1 or 2 runs remain
+// 1. Splits the input into runs sorted in memory, each filling the available buffers
+// 2. Merges runs in iterations until they fit in the buffer pool
 // 3. Returns a SortScan that can merge the final runs on demand
 type SortPlan struct {
 	interfaces.Plan
@@ -20,18 +22,19 @@ type SortPlan struct {
 	comp *RecordComparator
 }
 
-func newSortPlan(tx *tx.Transaction, p interfaces.Plan, sortFields []string) *SortPlan {
+// Creates a plan that sorts the records of p by the specs, in priority order
+func NewSortPlan(tx *tx.Transaction, p interfaces.Plan, specs []SortSpec) *SortPlan {
 	return &SortPlan{
 		tx:   tx,
 		p:    p,
 		sch:  p.Schema(),
-		comp: NewRecordComparator(sortFields),
+		comp: NewRecordComparator(specs),
 	}
 }
 
 // Executes the sort operation using an external merge-sort algorithmn:
 // 1. Splits input into sorted runs (each in a temp table)
-// 2. Repeatedly merges until 1-2 remain
+// 2. Merges groups of runs while there are more than can be open at once
 // 3. Returns a SortScan that merges the final runs
 func (sp *SortPlan) Open() interfaces.Scan {
 	// Open source Scan and split into initial sorted runs
@@ -39,13 +42,15 @@ func (sp *SortPlan) Open() interfaces.Scan {
 	runs := sp.SplitIntoRuns(src)
 	src.Close()
 
-	// Merge runs in iterations until we have 1-2 runs left
-	for len(runs) > 2 {
-		runs = sp.doMergeIteration(runs)
+	// Merge runs in iterations until the final merge can read them all at once
+	fanIn := sp.mergeFanIn()
+	for len(runs) > fanIn {
+		sp.tx.CheckCancelled()
+		runs = sp.doMergeIteration(runs, fanIn)
 	}
 
 	// Returns a scan that will merge the final runs
-	return NewSortScan(runs, sp.comp)
+	return NewSortScan(runs, sp.sch, sp.comp)
 }
 
 // Estimates the number of blocks needed to store the sorted results.
@@ -71,99 +76,103 @@ func (sp *SortPlan) Schema() *schema.Schema {
 }
 
 // Divides the input scan into sorted runs stored in temp tables.
-// Each run contains records in sorted order, with new runs starting when the
-// sort order would be violated.
+// Records are read into memory as many as fit in the available buffers,
+// sorted there and written out as a run, so each run is several blocks long.
 func (sp *SortPlan) SplitIntoRuns(src interfaces.Scan) []*TempTable {
 	var runs []*TempTable
+	capacity := sp.runCapacity()
 	src.BeforeFirst()
 
-	// Return empty if no records
-	if !src.Next() {
-		return runs
+	rows := make([]map[string]*types.Constant, 0, capacity)
+	for src.Next() {
+		row := make(map[string]*types.Constant, len(sp.sch.Fields()))
+		for _, fieldName := range sp.sch.Fields() {
+			row[fieldName] = src.GetVal(fieldName)
+		}
+		rows = append(rows, row)
+
+		if len(rows) == capacity {
+			runs = append(runs, sp.writeRun(rows))
+			rows = rows[:0]
+		}
 	}
 
-	// Create first run
-	currentTemp := NewTempTable(sp.tx, sp.sch)
-	runs = append(runs, currentTemp)
-	currentScan := currentTemp.Open()
+	// Write the last, partly filled run
+	if len(rows) > 0 {
+		runs = append(runs, sp.writeRun(rows))
+	}
+	return runs
+}
 
-	// Process all records
-	for {
-		// Copy current record to current run
-		sp.copyRecord(src, currentScan)
+// Returns how many records a run holds: a block's worth for each buffer
+// available, keeping one for the scan writing the run
+func (sp *SortPlan) runCapacity() int {
+	slotSize := record.NewLayout(sp.sch).SlotSize()
+	perBlock := max(1, sp.tx.BlockSize()/slotSize)
+	buffers := max(1, sp.tx.AvailableBuffers()-1)
+	return perBlock * buffers
+}
 
-		// Check if next record belongs in this run
-		if !src.Next() {
-			break
-		}
+// Sorts the records in memory and writes them to a new run.
+// Records that compare equal keep their order.
+func (sp *SortPlan) writeRun(rows []map[string]*types.Constant) *TempTable {
+	sp.tx.CheckCancelled()
+	sort.SliceStable(rows, func(i, j int) bool {
+		return sp.comp.CompareRows(rows[i], rows[j]) < 0
+	})
 
-		if sp.comp.Compare(src, currentScan) < 0 {
-			// Start new run
-			currentScan.Close()
-			currentTemp = NewTempTable(sp.tx, sp.sch)
-			runs = append(runs, currentTemp)
-			currentScan = currentTemp.Open()
+	run := NewTempTable(sp.tx, sp.sch)
+	dest := run.Open()
+	defer dest.Close()
+
+	for _, row := range rows {
+		dest.Insert()
+		for _, fieldName := range sp.sch.Fields() {
+			dest.SetVal(fieldName, row[fieldName])
 		}
 	}
-
-	currentScan.Close()
-	return runs
+	return run
 }
 
 // Performs one merge iteration on a list of runs.
-// It merges adjacent pairs of runs until 1-2 remain
-func (sp *SortPlan) doMergeIteration(runs []*TempTable) []*TempTable {
+// It merges groups of up to fanIn adjacent runs into one run each.
+func (sp *SortPlan) doMergeIteration(runs []*TempTable, fanIn int) []*TempTable {
 	var result []*TempTable
-	// Merge adjacent pairs
-	for len(runs) > 1 {
-		p1 := runs[0]
-		p2 := runs[1]
-		runs = runs[2:]
-		result = append(result, sp.mergeTwoRuns(p1, p2))
-	}
-
-	// Add last run if odd number
-	if len(runs) == 1 {
-		result = append(result, runs[0])
+	for len(runs) > 0 {
+		n := min(fanIn, len(runs))
+		if n == 1 {
+			result = append(result, runs[0])
+		} else {
+			result = append(result, sp.mergeRuns(runs[:n]))
+		}
+		runs = runs[n:]
 	}
 
 	return result
 }
 
-// Merges two sorted runs into a single sorted run.
-func (sp *SortPlan) mergeTwoRuns(p1, p2 *TempTable) *TempTable {
-	src1 := p1.Open()
-	src2 := p2.Open()
-	defer src1.Close()
-	defer src2.Close()
+// Merges sorted runs into a single sorted run.
+func (sp *SortPlan) mergeRuns(runs []*TempTable) *TempTable {
+	src := NewSortScan(runs, sp.sch, sp.comp)
+	defer src.Close()
 
 	result := NewTempTable(sp.tx, sp.sch)
 	dest := result.Open()
 	defer dest.Close()
 
-	hasMore1 := src1.Next()
-	hasMore2 := src2.Next()
-
-	// Merge while both runs have records
-	for hasMore1 && hasMore2 {
-		if sp.comp.Compare(src1, src2) < 0 {
-			hasMore1 = sp.copyRecord(src1, dest)
-		} else {
-			hasMore2 = sp.copyRecord(src2, dest)
-		}
-	}
-
-	// Copy remaining records from whichever runs has them
-	for hasMore1 {
-		hasMore1 = sp.copyRecord(src1, dest)
-	}
-	for hasMore2 {
-		hasMore2 = sp.copyRecord(src2, dest)
+	for hasMore := src.Next(); hasMore; {
+		hasMore = sp.copyRecord(src, dest)
 	}
 
 	return result
 }
 
+// Returns how many runs can be merged at once. Each open run pins a buffer,
+// and one more is needed for the run being written.
+func (sp *SortPlan) mergeFanIn() int {
+	return max(2, sp.tx.AvailableBuffers()-1)
+}
+
 // Copies a record from source to destination scan
 func (sp *SortPlan) copyRecord(src interfaces.Scan, dest *record.TableScan) bool {
 	dest.Insert()
@@ -173,3 +182,14 @@ func (sp *SortPlan) copyRecord(src interfaces.Scan, dest *record.TableScan) bool
 
 	return src.Next()
 }
+
+func (sp *SortPlan) Describe() string {
+	return "sort " + sp.comp.String()
+}
+
+func (sp *SortPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{sp.p}
+}
+
+// A sort reads all of its input before it outputs the first record
+func (sp *SortPlan) Blocking() {}
@@ -3,153 +3,184 @@ package materialize
 import (
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
+	"container/heap"
 )
 
-// Implements a merge sort algorithmn over 1 or 2 sorted runs.
+// Implements a merge sort algorithmn over any number of sorted runs.
 // It produces a sorted view of records by comparing and merging records from
 // the underlying scans according to the specified comparator.
 // Key Characterstics:
-// - Merges 1 or 2 sorted input streams
+// - Merges N sorted input streams with a heap, in O(log N) per record
 // - Maintains sort order via RecordComparator
+// - Records that compare equal come from the earlier run first
 // - Supports position saving/restoration
 // - Implements the Scan interface for transparent usage
 type SortScan struct {
 	interfaces.Scan
-	s1            *record.TableScan // First sorted input scan
-	s2            *record.TableScan // Second sorted input scan
-	currentScan   *record.TableScan // Currently active scan (s1 or s2)
-	comp          *RecordComparator // Comparator for determining sort order
-	hasMore1      bool
-	hasMore2      bool
-	savedPosition []*types.RID // Saved positions for restoration
-}
-
-func NewSortScan(runs []*TempTable, comp *RecordComparator) interfaces.Scan {
-	// Initialize first run
-	s1 := runs[0].Open()
-	hasMore1 := s1.Next()
-
-	// Initialize second run if present
-	var s2 *record.TableScan
-	var hasMore2 bool
-
-	if len(runs) > 1 {
-		s2 = runs[1].Open()
-		hasMore2 = s2.Next()
+	scans         []*record.TableScan // Sorted input scans, one per run
+	sch           *schema.Schema      // Schema of the sorted records, known even without runs
+	heap          *runHeap            // Runs with records left, other than the current one
+	current       int                 // Index of the scan holding the current record, -1 if none
+	comp          *RecordComparator   // Comparator for determining sort order
+	savedPosition *sortPosition       // Saved position for restoration
+}
+
+// The position of a SortScan: the record each run is at, and which of
+// the runs have records left
+type sortPosition struct {
+	rids    []*types.RID
+	hasMore []bool
+	current int
+}
+
+func NewSortScan(runs []*TempTable, sch *schema.Schema, comp *RecordComparator) interfaces.Scan {
+	scans := make([]*record.TableScan, len(runs))
+	for i, run := range runs {
+		scans[i] = run.Open()
 	}
 
-	return &SortScan{
-		s1:       s1,
-		s2:       s2,
-		comp:     comp,
-		hasMore1: hasMore1,
-		hasMore2: hasMore2,
+	ss := &SortScan{
+		scans: scans,
+		sch:   sch,
+		comp:  comp,
 	}
+	ss.BeforeFirst()
+	return ss
 }
 
 // Resets the scan to its initial state:
 // 1. Clears current scan position
-// 2. Resets both input scans to their first records
+// 2. Resets every input scan to its first record
 // 3. Prepares for new merge iteration
 func (ss *SortScan) BeforeFirst() {
-	ss.currentScan = nil
-	ss.s1.BeforeFirst()
-	ss.hasMore1 = ss.s1.Next()
-	if ss.s2 != nil {
-		ss.s2.BeforeFirst()
-		ss.hasMore2 = ss.s2.Next()
+	ss.current = -1
+	ss.heap = &runHeap{ss: ss}
+	for i, s := range ss.scans {
+		s.BeforeFirst()
+		if s.Next() {
+			ss.heap.runs = append(ss.heap.runs, i)
+		}
 	}
+	heap.Init(ss.heap)
 }
 
 // Advances to the next record in sorted order by:
-// 1. Advancing the current scan if set
-// 2. Selecting the next smallest record bw both scans
+// 1. Advancing the current scan and putting it back on the heap if it has more records
+// 2. Taking the scan with the smallest record off the heap
 // 3. Setting the chosen scan as current
 func (ss *SortScan) Next() bool {
 	// Advance the current scan if set
-	if ss.currentScan != nil {
-		if ss.currentScan == ss.s1 {
-			ss.hasMore1 = ss.s1.Next()
-		} else if ss.currentScan == ss.s2 {
-			ss.hasMore2 = ss.s2.Next()
-		}
+	if ss.current >= 0 && ss.scans[ss.current].Next() {
+		heap.Push(ss.heap, ss.current)
 	}
 
-	// Determine next record source
-	if !ss.hasMore1 && !ss.hasMore2 {
+	if ss.heap.Len() == 0 {
+		ss.current = -1
 		return false // No more records
-	} else if ss.hasMore1 && ss.hasMore2 {
-		// Both have records - compare to find smaller
-		if ss.comp.Compare(ss.s1, ss.s2) < 0 {
-			ss.currentScan = ss.s1
-		} else {
-			ss.currentScan = ss.s2
-		}
-	} else if ss.hasMore1 {
-		ss.currentScan = ss.s1
-	} else if ss.hasMore2 {
-		ss.currentScan = ss.s2
 	}
 
+	ss.current = heap.Pop(ss.heap).(int)
 	return true
 }
 
-// Closes both underlying scans
+// Closes every underlying scan
 func (ss *SortScan) Close() {
-	ss.s1.Close()
-	if ss.s2 != nil {
-		ss.s2.Close()
+	for _, s := range ss.scans {
+		s.Close()
 	}
 }
 
 func (ss *SortScan) GetVal(fldname string) *types.Constant {
-	return ss.currentScan.GetVal(fldname)
+	return ss.scans[ss.current].GetVal(fldname)
 }
 
 func (ss *SortScan) GetInt(fldname string) int {
-	return ss.currentScan.GetInt(fldname)
+	return ss.scans[ss.current].GetInt(fldname)
 }
 
 func (ss *SortScan) GetString(fldname string) string {
-	return ss.currentScan.GetString(fldname)
+	return ss.scans[ss.current].GetString(fldname)
 }
 
 func (ss *SortScan) HasField(fldname string) bool {
-	return ss.currentScan.HasField(fldname)
+	return ss.sch.HasField(fldname)
 }
 
-// Captures the current positions of both scans for later restoration.
+func (ss *SortScan) Schema() *schema.Schema {
+	return ss.sch
+}
+
+// Captures the current positions of all scans for later restoration.
 // Useful for nested loop operations that need to reset their state.
 func (ss *SortScan) SavePosition() {
-	rid1 := ss.s1.GetRID()
-	var rid2 *types.RID
-	if ss.s2 != nil {
-		rid2 = ss.s2.GetRID()
+	pos := &sortPosition{
+		rids:    make([]*types.RID, len(ss.scans)),
+		hasMore: make([]bool, len(ss.scans)),
+		current: ss.current,
+	}
+	for i, s := range ss.scans {
+		pos.rids[i], _ = s.GetRID()
+	}
+	for _, i := range ss.heap.runs {
+		pos.hasMore[i] = true
 	}
 
-	ss.savedPosition = []*types.RID{rid1, rid2}
+	ss.savedPosition = pos
 }
 
-// Resets both scans to their previously saved positions.
+// Resets all scans to their previously saved positions, making the record
+// that was current then current again.
 // Must be preceded by a call to SavePosition()
 func (ss *SortScan) RestorePosition() {
-	if ss.savedPosition == nil {
+	pos := ss.savedPosition
+	if pos == nil {
 		panic("no saved position")
 	}
 
-	rid1 := ss.savedPosition[0]
-	rid2 := ss.savedPosition[1]
-
-	ss.s1.MoveToRID(rid1)
-	if ss.s2 != nil && rid2 != nil {
-		ss.s2.MoveToRID(rid2)
+	ss.current = pos.current
+	ss.heap = &runHeap{ss: ss}
+	for i, s := range ss.scans {
+		if i == pos.current || pos.hasMore[i] {
+			s.MoveToRID(pos.rids[i])
+		}
+		if pos.hasMore[i] {
+			ss.heap.runs = append(ss.heap.runs, i)
+		}
 	}
+	heap.Init(ss.heap)
+}
+
+// Orders the runs of a SortScan by their current records, the earlier
+// run first when they compare equal. Implements heap.Interface.
+type runHeap struct {
+	ss   *SortScan
+	runs []int // Indexes into ss.scans
+}
+
+func (h *runHeap) Len() int {
+	return len(h.runs)
+}
 
-	// Reset current scan tracking
-	ss.currentScan = nil
-	ss.hasMore1 = true
-	if ss.s2 != nil {
-		ss.hasMore2 = true
+func (h *runHeap) Less(i, j int) bool {
+	a, b := h.runs[i], h.runs[j]
+	if result := h.ss.comp.Compare(h.ss.scans[a], h.ss.scans[b]); result != 0 {
+		return result < 0
 	}
+	return a < b
+}
+
+func (h *runHeap) Swap(i, j int) {
+	h.runs[i], h.runs[j] = h.runs[j], h.runs[i]
+}
+
+func (h *runHeap) Push(x any) {
+	h.runs = append(h.runs, x.(int))
+}
+
+func (h *runHeap) Pop() any {
+	last := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return last
 }
@@ -28,17 +28,59 @@ var nextTableNum int64
 var nameMutex sync.Mutex
 
 func NewTempTable(tx *tx.Transaction, sch *schema.Schema) *TempTable {
+	// A record wider than a block, e.g. one of a table with larger blocks,
+	// gets blocks of its size. The changes to temp tables aren't logged,
+	// so they needn't fit in a log block.
+	layout := record.NewLayout(sch)
+	if layout.SlotSize() > tx.BlockSize() {
+		options := layout.Options()
+		options.BlockSize = layout.SlotSize()
+		layout.SetOptions(options)
+	}
+
+	return &TempTable{
+		tx:        tx,
+		tableName: generateTableName(),
+		layout:    layout,
+	}
+}
+
+// Creates a temp table whose records are laid out as in layout,
+// e.g. one holding copies of the blocks of a table
+func NewTempTableWithLayout(tx *tx.Transaction, layout *record.Layout) *TempTable {
 	return &TempTable{
 		tx:        tx,
 		tableName: generateTableName(),
-		layout:    record.NewLayout(sch),
+		layout:    layout,
 	}
 }
 
 // Creates and return an UpdateScan for accessing the temp table.
 // The scan provides both read and write capabilities.
+// The blocks it appends count towards the statement's temp space quota.
+// Its changes aren't logged, temp tables are never rolled back or recovered.
 func (tt *TempTable) Open() *record.TableScan {
-	return record.NewTableScan(tt.tx, tt.tableName, tt.layout)
+	filename := record.TableFile(tt.tableName, tt.layout)
+	blockSize := int64(tt.tx.FileBlockSize(filename))
+
+	// A scan on an empty table appends its first block right away
+	size, _ := tt.tx.Size(filename)
+	ts := record.NewTableScan(tt.tx, tt.tableName, tt.layout)
+	if size == 0 {
+		tt.tx.AddTempSpace(blockSize)
+	}
+
+	ts.SetLogging(false)
+	ts.OnNewBlock(func() { tt.tx.AddTempSpace(blockSize) })
+	return ts
+}
+
+// Removes the temp table's files. Its scans must be closed.
+func (tt *TempTable) Drop() error {
+	if err := tt.tx.RemoveFile(record.TableFile(tt.tableName, tt.layout)); err != nil {
+		return err
+	}
+	return tt.tx.RemoveFile(record.OverflowFile(tt.tableName))
 }
 
 // Returns the system-generated name of this temp table.
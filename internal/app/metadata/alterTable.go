@@ -0,0 +1,239 @@
+package metadata
+
+import (
+	"centauri/internal/app/file"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Adds the field of a single field schema to a table. The records of the
+// table are rewritten to make room for it, the field taking its default in
+// each of them, or being null if it has none, or computed from the other
+// fields if it is a stored generated field.
+// Fails with ErrTableNotFound if the table doesn't exist, with
+// ErrFieldExists if it already has a field of the name, and with
+// record.ErrNotNullable if it is declared NOT NULL without a default
+// and the table has records.
+func (mm *MetaDataManager) AddColumn(tableName string, field *schema.Schema, tx *tx.Transaction) error {
+	oldLayout, err := mm.tm.GetLayout(tableName, tx)
+	if err != nil {
+		return err
+	}
+	fieldName := field.Fields()[0]
+	if oldLayout.Schema().HasField(fieldName) {
+		return fmt.Errorf("%w: %s already has a field %s", ErrFieldExists, tableName, fieldName)
+	}
+	if len(fieldName) > MAX_NAME {
+		return fmt.Errorf("field name %s is longer than %d characters", fieldName, MAX_NAME)
+	}
+
+	sch := schema.NewSchema()
+	for _, f := range oldLayout.Schema().Fields() {
+		addField(sch, f, oldLayout.Schema())
+	}
+	addField(sch, fieldName, field)
+
+	// The existing records have no value for the field, so it must be able to hold a null
+	if !field.IsVirtual(fieldName) && field.Generated(fieldName) == "" {
+		newLayout := record.NewLayout(storedSchema(sch))
+		if newLayout.NullBit(fieldName) < 0 {
+			return fmt.Errorf("%w: %s would be past the first %d fields", record.ErrNotNullable, fieldName, record.MAX_NULLABLE_FIELDS)
+		}
+	}
+
+	return mm.alterTable(tableName, oldLayout, sch, tx)
+}
+
+// Removes a field from a table, rewriting its records without the values of it.
+// Fails with ErrTableNotFound if the table doesn't exist, with ErrFieldNotFound
+// if it has no field of the name, and with ErrDependentObjects if an index,
+// a view or a generated field uses the field. The partition key of a table and
+// its only field can't be dropped either.
+func (mm *MetaDataManager) DropColumn(tableName string, fieldName string, tx *tx.Transaction) error {
+	oldLayout, err := mm.tm.GetLayout(tableName, tx)
+	if err != nil {
+		return err
+	}
+	oldSchema := oldLayout.Schema()
+	if !oldSchema.HasField(fieldName) {
+		return fmt.Errorf("%w: %s has no field %s", ErrFieldNotFound, tableName, fieldName)
+	}
+	if len(oldSchema.Fields()) == 1 {
+		return fmt.Errorf("%s is the only field of %s and can't be dropped", fieldName, tableName)
+	}
+	if p := oldLayout.Options().Partitioning; p != nil && p.Field == fieldName {
+		return fmt.Errorf("%w: %s is the partition key of %s", record.ErrPartitioned, fieldName, tableName)
+	}
+
+	var deps []string
+	for _, ii := range mm.im.GetIndexInfo(tableName, tx) {
+		if slices.Contains(ii.FieldNames(), fieldName) {
+			deps = append(deps, "index "+ii.IndexName())
+		}
+	}
+	for _, v := range mm.vm.ViewsOn(tableName, tx) {
+		if viewReads(mm.vm.GetViewDef(v, tx), fieldName) {
+			deps = append(deps, "view "+v)
+		}
+	}
+	for _, f := range oldSchema.Fields() {
+		if g := oldLayout.Generator(f); g != nil && slices.Contains(g.Fields(), fieldName) {
+			deps = append(deps, "generated field "+f)
+		}
+	}
+	if len(deps) > 0 {
+		slices.Sort(deps)
+		return fmt.Errorf("%w: %s is used by %s", ErrDependentObjects, fieldName, strings.Join(deps, ", "))
+	}
+
+	sch := schema.NewSchema()
+	for _, f := range oldSchema.Fields() {
+		if f != fieldName {
+			addField(sch, f, oldSchema)
+		}
+	}
+	return mm.alterTable(tableName, oldLayout, sch, tx)
+}
+
+// Adds a field of another schema to the schema, along with its collation,
+// its options and the expression computing it if it is generated
+func addField(sch *schema.Schema, fieldName string, from *schema.Schema) {
+	sch.Add(fieldName, from)
+	sch.SetNotNull(fieldName, from.NotNull(fieldName))
+	sch.SetDefault(fieldName, from.Default(fieldName))
+	sch.SetAutoIncrement(fieldName, from.AutoIncrement(fieldName))
+	if expr := from.Generated(fieldName); expr != "" {
+		sch.SetGenerated(fieldName, expr, !from.IsVirtual(fieldName))
+	}
+}
+
+// Reports whether the definition of a view reads the field. Views name
+// the fields of the tables they read without qualifying them, so any
+// mention of the field counts.
+func viewReads(viewDef string, fieldName string) bool {
+	data, err := parse.NewParser(viewDef).Query()
+	if err != nil {
+		// A view that can't be parsed can't be checked either
+		return true
+	}
+	return slices.Contains(data.ReadFields(), fieldName)
+}
+
+// Changes the schema of a table from that of oldLayout to sch.
+// The catalogs are updated first, so that the new layout can be read back
+// from them. The records of each of the table's storage tables are then read,
+// deleted along with their index entries, and inserted again in the new
+// layout, keeping the values of the stored fields the layouts share. Since
+// the slot size may change, the blocks are emptied using the new layout in
+// between. Everything is logged, so rolling back restores the table.
+func (mm *MetaDataManager) alterTable(tableName string, oldLayout *record.Layout, sch *schema.Schema, tx *tx.Transaction) error {
+	indexes := mm.im.GetIndexInfo(tableName, tx)
+	tables := record.StorageTables(tableName, oldLayout)
+
+	rows := make(map[string][]map[string]*types.Constant)
+	for _, table := range tables {
+		ts := record.NewTableScan(tx, table, oldLayout)
+		for ts.Next() {
+			row := make(map[string]*types.Constant)
+			for _, fieldName := range oldLayout.Schema().Fields() {
+				if oldLayout.Offset(fieldName) >= 0 {
+					row[fieldName] = ts.GetVal(fieldName)
+				}
+			}
+			rows[table] = append(rows[table], row)
+		}
+		ts.Close()
+
+		mm.deleteStoredRecords(table, oldLayout, indexes, tx)
+	}
+
+	mm.tm.AlterTable(tableName, sch, oldLayout.Options(), tx)
+	newLayout, err := mm.tm.GetLayout(tableName, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		filename := record.TableFile(table, newLayout)
+		size, err := tx.Size(filename)
+		if err != nil {
+			return err
+		}
+		for blkNum := 0; blkNum < size; blkNum++ {
+			rp := record.NewRecordPage(tx, file.NewBlockID(filename, blkNum), newLayout)
+			rp.Reformat()
+			tx.Unpin(rp.Block())
+		}
+
+		if err := mm.insertRecords(table, newLayout, rows[table], indexes, tx); err != nil {
+			return err
+		}
+	}
+
+	mm.sm.Forget(tableName)
+	return nil
+}
+
+// Inserts the records into the storage table along with their index entries.
+// The stored fields a record has no value for take their default, or are
+// null unless generated. Fails with record.ErrNotNullable if a field
+// declared NOT NULL would be null.
+func (mm *MetaDataManager) insertRecords(table string, layout *record.Layout, rows []map[string]*types.Constant, indexes map[string]IndexInfo, tx *tx.Transaction) error {
+	sch := layout.Schema()
+	ts := record.NewTableScan(tx, table, layout)
+	defer ts.Close()
+
+	for _, row := range rows {
+		ts.Insert()
+		for _, fieldName := range sch.Fields() {
+			if layout.Offset(fieldName) < 0 || layout.Generator(fieldName) != nil {
+				continue
+			}
+			val, ok := row[fieldName]
+			if !ok {
+				val = layout.Default(fieldName)
+			}
+			if val == nil {
+				val = types.NewConstantNull()
+			}
+			if val.IsNull() && sch.NotNull(fieldName) {
+				return fmt.Errorf("%w: %s is declared NOT NULL and has no value in the records of %s", record.ErrNotNullable, fieldName, table)
+			}
+			if err := ts.SetVal(fieldName, val); err != nil {
+				return err
+			}
+		}
+		// Stored generated fields keep their values, and new ones are computed
+		for _, fieldName := range sch.Fields() {
+			g := layout.Generator(fieldName)
+			if g == nil || layout.Computed(fieldName) != nil {
+				continue
+			}
+			val, ok := row[fieldName]
+			if !ok {
+				val = g.Evaluate(ts)
+			}
+			if err := ts.SetVal(fieldName, val); err != nil {
+				return err
+			}
+		}
+
+		rid, _ := ts.GetRID()
+		for _, ii := range indexes {
+			// Composite keys aren't stored by the index structures
+			if ii.IsComposite() {
+				continue
+			}
+			idx := ii.Open()
+			idx.Insert(ts.GetVal(ii.FieldNames()[0]), rid)
+			idx.Close()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,464 @@
+package metadata
+
+import (
+	"centauri/internal/app/file"
+	"centauri/internal/app/index"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"fmt"
+)
+
+// The catalog format written by this version of the database.
+// Bump it and append a migration to catalogMigrations whenever the layout
+// of a catalog table changes.
+const CATALOG_VERSION = 13
+
+// The bootstrap table holding the catalog version.
+// It isn't registered in tblcat, so it can be read before the rest of the
+// catalog is known to be in the current format.
+const CATALOG_VERSION_TABLE = "catver"
+
+// Upgrades the catalog from version-1 to version.
+type catalogMigration struct {
+	version     int
+	description string
+	apply       func(tm *TableManager, tx *tx.Transaction) error
+}
+
+// The migrations in the order they must be applied
+var catalogMigrations = []catalogMigration{
+	{
+		version:     1,
+		description: "add row counts and storage options to tblcat, add viewdep",
+		apply:       migrateToV1,
+	},
+	{
+		version:     2,
+		description: "store an ordered field list per index in idxcat",
+		apply:       migrateToV2,
+	},
+	{
+		version:     3,
+		description: "add usercat",
+		apply:       migrateToV3,
+	},
+	{
+		version:     4,
+		description: "add privcat",
+		apply:       migrateToV4,
+	},
+	{
+		version:     5,
+		description: "record the record format of each table in tblcat",
+		apply:       migrateToV5,
+	},
+	{
+		version:     6,
+		description: "add collcat",
+		apply:       migrateToV6,
+	},
+	{
+		version:     7,
+		description: "add gencat",
+		apply:       migrateToV7,
+	},
+	{
+		version:     8,
+		description: "add partcat",
+		apply:       migrateToV8,
+	},
+	{
+		version:     9,
+		description: "record the NOT NULL and DEFAULT options of each field in fldcat",
+		apply:       migrateToV9,
+	},
+	{
+		version:     10,
+		description: "add seqcat",
+		apply:       migrateToV10,
+	},
+	{
+		version:     11,
+		description: "record the structure of each index in idxcat",
+		apply:       migrateToV11,
+	},
+	{
+		version:     12,
+		description: "record whether each index is unique in idxcat",
+		apply:       migrateToV12,
+	},
+	{
+		version:     13,
+		description: "add statcat and idxstatcat",
+		apply:       migrateToV13,
+	},
+}
+
+// Returns the layout of the bootstrap table
+func catalogVersionLayout() *record.Layout {
+	sch := schema.NewSchema()
+	sch.AddIntField("version")
+	return record.NewLayout(sch)
+}
+
+// Reads the catalog version from the bootstrap record.
+// Databases created before the catalog was versioned have no bootstrap record
+// and are reported as version 0.
+func readCatalogVersion(tx *tx.Transaction) (int, error) {
+	size, err := tx.Size(CATALOG_VERSION_TABLE + ".tbl")
+	if err != nil {
+		return 0, err
+	}
+	if size == 0 {
+		return 0, nil
+	}
+
+	ts := record.NewTableScan(tx, CATALOG_VERSION_TABLE, catalogVersionLayout())
+	defer ts.Close()
+
+	if !ts.Next() {
+		return 0, nil
+	}
+	return ts.GetInt("version"), nil
+}
+
+// Writes the catalog version into the bootstrap record, creating it if needed
+func writeCatalogVersion(version int, tx *tx.Transaction) {
+	ts := record.NewTableScan(tx, CATALOG_VERSION_TABLE, catalogVersionLayout())
+	defer ts.Close()
+
+	if !ts.Next() {
+		ts.Insert()
+	}
+	ts.SetInt("version", version)
+}
+
+// Brings the catalog of an existing database up to CATALOG_VERSION by applying
+// each pending migration in order. The version is recorded after every step, so
+// a database is never left claiming a version whose migration didn't complete.
+func migrateCatalog(tm *TableManager, tx *tx.Transaction) error {
+	current, err := readCatalogVersion(tx)
+	if err != nil {
+		return fmt.Errorf("failed to read catalog version: %w", err)
+	}
+
+	if current > CATALOG_VERSION {
+		return fmt.Errorf("catalog version %d is newer than the supported version %d", current, CATALOG_VERSION)
+	}
+
+	// The migrations before version 9 read and write fldcat in the layout
+	// it had until then
+	if current < 9 {
+		tm.fcatLayout = record.NewLayout(legacyFieldCatalogSchema())
+	}
+
+	for _, m := range catalogMigrations {
+		if m.version <= current {
+			continue
+		}
+		if err := m.apply(tm, tx); err != nil {
+			return fmt.Errorf("catalog migration to version %d (%s) failed: %w", m.version, m.description, err)
+		}
+		writeCatalogVersion(m.version, tx)
+	}
+
+	return nil
+}
+
+// Version 1 added the numrecs, fillfactor, blocksize and compression fields to
+// tblcat and the viewdep catalog. The tblcat records are rewritten with the new
+// layout, the row counts of the user tables are computed, and the dependencies
+// of the existing views are recovered from their definitions.
+func migrateToV1(tm *TableManager, tx *tx.Transaction) error {
+	// The original table catalog only held the table name and slot size
+	oldSchema := schema.NewSchema()
+	oldSchema.AddStringField("tblname", MAX_NAME)
+	oldSchema.AddIntField("slotsize")
+
+	defaults := record.DefaultStorageOptions()
+	var tables []string
+
+	err := rewriteCatalogTable(tm, "tblcat", record.NewLayout(oldSchema), tm.tcatLayout, func(row map[string]any) {
+		tables = append(tables, row["tblname"].(string))
+		row["numrecs"] = UNTRACKED_ROWS
+		row["fillfactor"] = defaults.FillFactor
+		row["blocksize"] = defaults.BlockSize
+		row["compression"] = 0
+		row["format"] = record.FORMAT_FIXED
+	}, tx)
+	if err != nil {
+		return err
+	}
+
+	// Count the records of the user tables, now that their layouts can be read
+	for _, tableName := range tables {
+		if catalogTables[tableName] {
+			continue
+		}
+
+		layout, err := tm.GetLayout(tableName, tx)
+		if err != nil {
+			return err
+		}
+
+		tm.setRowCount(tableName, countRecords(tableName, layout, tx), tx)
+	}
+
+	// Create the view dependency catalog and fill it from the existing view definitions
+	tm.CreateTable("viewdep", viewDepSchema(), tx)
+
+	vcatLayout, err := tm.GetLayout("viewcat", tx)
+	if err != nil {
+		return err
+	}
+
+	views := make(map[string]string)
+	vcat := record.NewTableScan(tx, "viewcat", vcatLayout)
+	for vcat.Next() {
+		views[vcat.GetString("viewname")] = vcat.GetString("viewdef")
+	}
+	vcat.Close()
+
+	vdepLayout := record.NewLayout(viewDepSchema())
+	deps := record.NewTableScan(tx, "viewdep", vdepLayout)
+	for viewName, viewDef := range views {
+		qd, err := parse.NewParser(viewDef).Query()
+		if err != nil {
+			deps.Close()
+			return fmt.Errorf("view %s: %w", viewName, err)
+		}
+		for _, tableName := range qd.ReadTables() {
+			deps.Insert()
+			deps.SetString("viewname", viewName)
+			deps.SetString("tablename", tableName)
+		}
+	}
+	deps.Close()
+
+	return nil
+}
+
+// Version 2 replaced the single fieldname of idxcat with an ordered,
+// comma-separated field list so that composite indexes can be described.
+func migrateToV2(tm *TableManager, tx *tx.Transaction) error {
+	oldSchema := schema.NewSchema()
+	oldSchema.AddStringField("indexname", MAX_NAME)
+	oldSchema.AddStringField("tablename", MAX_NAME)
+	oldSchema.AddStringField("fieldname", MAX_NAME)
+
+	return rewriteCatalogTable(tm, "idxcat", record.NewLayout(oldSchema), record.NewLayout(indexCatalogSchema()), func(row map[string]any) {
+		row["fieldlist"] = row["fieldname"]
+		row["indextype"] = index.INDEX_HASH
+		row["isunique"] = 0
+	}, tx)
+}
+
+// Version 3 added the user catalog
+func migrateToV3(tm *TableManager, tx *tx.Transaction) error {
+	tm.CreateTable("usercat", userCatalogSchema(), tx)
+	return nil
+}
+
+// Version 4 added the privilege catalog. Existing tables start out with no
+// privileges granted, so only superusers can use them until GRANT is run.
+func migrateToV4(tm *TableManager, tx *tx.Transaction) error {
+	tm.CreateTable("privcat", privilegeCatalogSchema(), tx)
+	return nil
+}
+
+// Tables created before the variable format are all in the fixed format
+func migrateToV5(tm *TableManager, tx *tx.Transaction) error {
+	// A catalog migrated from version 0 already got the format column
+	if hasCatalogField(tm, "tblcat", "format", tx) {
+		return nil
+	}
+
+	oldSchema := schema.NewSchema()
+	oldSchema.AddStringField("tblname", MAX_NAME)
+	oldSchema.AddIntField("slotsize")
+	oldSchema.AddIntField("numrecs")
+	oldSchema.AddIntField("fillfactor")
+	oldSchema.AddIntField("blocksize")
+	oldSchema.AddIntField("compression")
+
+	return rewriteCatalogTable(tm, "tblcat", record.NewLayout(oldSchema), tm.tcatLayout, func(row map[string]any) {
+		row["format"] = record.FORMAT_FIXED
+	}, tx)
+}
+
+func migrateToV6(tm *TableManager, tx *tx.Transaction) error {
+	tm.CreateTable("collcat", collationCatalogSchema(), tx)
+	return nil
+}
+
+func migrateToV7(tm *TableManager, tx *tx.Transaction) error {
+	tm.CreateTable("gencat", generatedCatalogSchema(), tx)
+	return nil
+}
+
+func migrateToV8(tm *TableManager, tx *tx.Transaction) error {
+	tm.CreateTable("partcat", partitionCatalogSchema(), tx)
+	return nil
+}
+
+// Fields had no options before version 9. The field catalog is rewritten
+// with room for them, and the layout of the table manager replaced.
+func migrateToV9(tm *TableManager, tx *tx.Transaction) error {
+	oldLayout := tm.fcatLayout
+	tm.fcatLayout = record.NewLayout(fieldCatalogSchema())
+
+	return rewriteCatalogTable(tm, "fldcat", oldLayout, tm.fcatLayout, func(row map[string]any) {
+		row["notnull"] = 0
+		row["defaultval"] = ""
+	}, tx)
+}
+
+func migrateToV10(tm *TableManager, tx *tx.Transaction) error {
+	tm.CreateTable("seqcat", sequenceCatalogSchema(), tx)
+	return nil
+}
+
+// Every index was a hash index before version 11
+func migrateToV11(tm *TableManager, tx *tx.Transaction) error {
+	// A catalog migrated from version 1 or earlier already got the column
+	if hasCatalogField(tm, "idxcat", "indextype", tx) {
+		return nil
+	}
+
+	oldSchema := schema.NewSchema()
+	oldSchema.AddStringField("indexname", MAX_NAME)
+	oldSchema.AddStringField("tablename", MAX_NAME)
+	oldSchema.AddStringField("fieldlist", MAX_FIELDLIST)
+
+	return rewriteCatalogTable(tm, "idxcat", record.NewLayout(oldSchema), record.NewLayout(indexCatalogSchema()), func(row map[string]any) {
+		row["indextype"] = index.INDEX_HASH
+		row["isunique"] = 0
+	}, tx)
+}
+
+// Every index allowed duplicate keys before version 12
+func migrateToV12(tm *TableManager, tx *tx.Transaction) error {
+	// A catalog migrated from version 10 or earlier already got the column
+	if hasCatalogField(tm, "idxcat", "isunique", tx) {
+		return nil
+	}
+
+	oldSchema := schema.NewSchema()
+	oldSchema.AddStringField("indexname", MAX_NAME)
+	oldSchema.AddStringField("tablename", MAX_NAME)
+	oldSchema.AddStringField("fieldlist", MAX_FIELDLIST)
+	oldSchema.AddStringField("indextype", MAX_INDEX_TYPE)
+
+	return rewriteCatalogTable(tm, "idxcat", record.NewLayout(oldSchema), record.NewLayout(indexCatalogSchema()), func(row map[string]any) {
+		row["isunique"] = 0
+	}, tx)
+}
+
+func migrateToV13(tm *TableManager, tx *tx.Transaction) error {
+	tm.CreateTable("statcat", statCatalogSchema(), tx)
+	tm.CreateTable("idxstatcat", indexStatCatalogSchema(), tx)
+	return nil
+}
+
+// Returns the schema of the field catalog before version 9
+func legacyFieldCatalogSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddStringField("tblname", MAX_NAME)
+	sch.AddStringField("fldname", MAX_NAME)
+	sch.AddIntField("type")
+	sch.AddIntField("length")
+	sch.AddIntField("offset")
+	return sch
+}
+
+// Checks whether the field catalog lists the field for the table
+func hasCatalogField(tm *TableManager, tablename string, fieldname string, tx *tx.Transaction) bool {
+	fcat := record.NewTableScan(tx, "fldcat", tm.fcatLayout)
+	defer fcat.Close()
+
+	for fcat.Next() {
+		if fcat.GetString("tblname") == tablename && fcat.GetString("fldname") == fieldname {
+			return true
+		}
+	}
+	return false
+}
+
+// Rewrites every record of a catalog table from oldLayout to newLayout.
+// Each record is read into a map of field values and passed to convert, which
+// fills in the values of the new fields. Since the slot size may change, the
+// blocks are emptied using the new layout before the records are inserted again.
+// Finally the table's slot size and field entries in the catalog are updated.
+func rewriteCatalogTable(tm *TableManager, tablename string, oldLayout *record.Layout, newLayout *record.Layout, convert func(row map[string]any), tx *tx.Transaction) error {
+	oldSchema := oldLayout.Schema()
+	newSchema := newLayout.Schema()
+
+	var rows []map[string]any
+	ts := record.NewTableScan(tx, tablename, oldLayout)
+	for ts.Next() {
+		row := make(map[string]any)
+		for _, fieldname := range oldSchema.Fields() {
+			if oldSchema.DataType(fieldname) == schema.INTEGER {
+				row[fieldname] = ts.GetInt(fieldname)
+			} else {
+				row[fieldname] = ts.GetString(fieldname)
+			}
+		}
+		convert(row)
+		rows = append(rows, row)
+	}
+	ts.Close()
+
+	filename := record.TableFile(tablename, newLayout)
+	size, err := tx.Size(filename)
+	if err != nil {
+		return err
+	}
+	for blkNum := 0; blkNum < size; blkNum++ {
+		rp := record.NewRecordPage(tx, file.NewBlockID(filename, blkNum), newLayout)
+		rp.Reformat()
+		tx.Unpin(rp.Block())
+	}
+
+	ts = record.NewTableScan(tx, tablename, newLayout)
+	for _, row := range rows {
+		ts.Insert()
+		for _, fieldname := range newSchema.Fields() {
+			switch val := row[fieldname].(type) {
+			case int:
+				ts.SetInt(fieldname, val)
+			case string:
+				ts.SetString(fieldname, val)
+			}
+		}
+	}
+	ts.Close()
+
+	// Record the new slot size
+	tcat := record.NewTableScan(tx, "tblcat", tm.tcatLayout)
+	for tcat.Next() {
+		if tcat.GetString("tblname") == tablename {
+			tcat.SetInt("slotsize", newLayout.SlotSize())
+			break
+		}
+	}
+	tcat.Close()
+
+	// Replace the field catalog entries of the table
+	deleteMatching(tx, "fldcat", tm.fcatLayout, "tblname", tablename)
+
+	fcat := record.NewTableScan(tx, "fldcat", tm.fcatLayout)
+	for _, fieldname := range newSchema.Fields() {
+		fcat.Insert()
+		fcat.SetString("tblname", tablename)
+		fcat.SetString("fldname", fieldname)
+		fcat.SetInt("type", int(newSchema.DataType(fieldname)))
+		fcat.SetInt("length", newSchema.Length(fieldname))
+		fcat.SetInt("offset", newLayout.Offset(fieldname))
+	}
+	fcat.Close()
+
+	return nil
+}
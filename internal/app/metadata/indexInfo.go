@@ -2,10 +2,12 @@ package metadata
 
 import (
 	"centauri/internal/app/index"
+	"centauri/internal/app/index/btree"
 	"centauri/internal/app/index/hash"
 	"centauri/internal/app/record"
 	sch "centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
+	"fmt"
 )
 
 // The information about an index.
@@ -13,18 +15,34 @@ import (
 // of using the index, and to obtain the layout of the index records/
 type IndexInfo struct {
 	idxName     string
-	fldName     string
+	fldName     string   // The leading indexed field
+	fldNames    []string // All indexed fields, in key order
+	indexType   string   // index.INDEX_HASH or index.INDEX_BTREE
+	unique      bool     // Whether no two records may have the same key
+	height      int      // The height of a B-tree found by ANALYZE, 0 if unknown
 	tx          *tx.Transaction
 	tableSchema *sch.Schema
 	idxLayout   *record.Layout
 	si          *StatInfo
 }
 
-func NewIndexInfo(idxName string, fldName string, tableSchema *sch.Schema, tx *tx.Transaction, si *StatInfo) *IndexInfo {
+func NewIndexInfo(idxName string, fldName string, indexType string, tableSchema *sch.Schema, tx *tx.Transaction, si *StatInfo) *IndexInfo {
+	return NewCompositeIndexInfo(idxName, []string{fldName}, indexType, false, tableSchema, tx, si)
+}
+
+// Creates the information about an index whose key consists of the
+// specified fields, in order. An index of no type is a hash index.
+func NewCompositeIndexInfo(idxName string, fldNames []string, indexType string, unique bool, tableSchema *sch.Schema, tx *tx.Transaction, si *StatInfo) *IndexInfo {
+	if indexType == "" {
+		indexType = index.INDEX_HASH
+	}
 
 	ii := &IndexInfo{
 		idxName:     idxName,
-		fldName:     fldName,
+		fldName:     fldNames[0],
+		fldNames:    fldNames,
+		indexType:   indexType,
+		unique:      unique,
 		tx:          tx,
 		tableSchema: tableSchema,
 		si:          si,
@@ -35,10 +53,13 @@ func NewIndexInfo(idxName string, fldName string, tableSchema *sch.Schema, tx *t
 	return ii
 }
 
-// Open creates and returns a new HashIndex instance for this index.
-// It initializes the index using the transaction, index name and layout
-// stored in the IndexInfo struct.
+// Open creates and returns a new HashIndex or BTreeIndex instance for this index,
+// depending on its type. It initializes the index using the transaction,
+// index name and layout stored in the IndexInfo struct.
 func (ii *IndexInfo) Open() index.Index {
+	if ii.indexType == index.INDEX_BTREE {
+		return btree.NewBTreeIndex(ii.tx, ii.idxName, ii.idxLayout)
+	}
 	return hash.NewHashIndex(ii.tx, ii.idxName, ii.idxLayout)
 }
 
@@ -67,7 +88,18 @@ func (ii *IndexInfo) BlocksAccessed() int {
 	// - Division by rpb gives us the number of blocks these records occupy
 	numBlocks := ii.si.RecordsOutput() / rpb
 
-	return hash.SearchCost(numBlocks, rpb)
+	// An analyzed B-tree is searched through one block per level
+	if ii.height > 0 {
+		return ii.height
+	}
+
+	// The cost of the search depends on the structure of the index
+	idx := ii.Open()
+	defer idx.Close()
+	if coster, ok := idx.(index.SearchCoster); ok {
+		return coster.SearchCost(numBlocks, rpb)
+	}
+	return numBlocks
 }
 
 // Estimates the number of records that will be retrieved by a
@@ -77,7 +109,16 @@ func (ii *IndexInfo) BlocksAccessed() int {
 // - Dividing by the number of distinct values in the indexed field
 // This gives us the average number of records per distinct value
 func (ii *IndexInfo) RecordsOutput() int {
-	return ii.si.RecordsOutput() / ii.si.DistinctValues(ii.fldName)
+	// A unique index has at most one record per key
+	if ii.unique {
+		return min(ii.si.RecordsOutput(), 1)
+	}
+	// For a composite key, assume the fields are independent
+	distinct := 1
+	for _, fldName := range ii.fldNames {
+		distinct *= ii.si.DistinctValues(fldName)
+	}
+	return ii.si.RecordsOutput() / max(distinct, 1)
 }
 
 // Returns the number of distinct values for a specified field in the index.
@@ -85,12 +126,42 @@ func (ii *IndexInfo) RecordsOutput() int {
 //   - 1 if the field is the indexed field (assuming unique index)
 //   - Number of distinct values for other fields
 func (ii *IndexInfo) DistinctValues(fname string) int {
-	if ii.fldName == fname {
+	if ii.fldName == fname && !ii.IsComposite() {
 		return 1
 	}
 	return ii.si.DistinctValues(fname)
 }
 
+// Returns the name of the index
+func (ii *IndexInfo) IndexName() string {
+	return ii.idxName
+}
+
+// Returns the indexed fields in key order
+func (ii *IndexInfo) FieldNames() []string {
+	return ii.fldNames
+}
+
+// Returns the structure of the index, index.INDEX_HASH or index.INDEX_BTREE
+func (ii *IndexInfo) IndexType() string {
+	return ii.indexType
+}
+
+// Checks whether no two records may have the same key
+func (ii *IndexInfo) IsUnique() bool {
+	return ii.unique
+}
+
+// Returns the number of records in the indexed table
+func (ii *IndexInfo) TableRecords() int {
+	return ii.si.RecordsOutput()
+}
+
+// Checks whether the index key consists of more than one field
+func (ii *IndexInfo) IsComposite() bool {
+	return len(ii.fldNames) > 1
+}
+
 // Creates the physical layout for the index records.
 func (ii *IndexInfo) createIdxLayout() *record.Layout {
 	// Create new schema for index records
@@ -99,13 +170,25 @@ func (ii *IndexInfo) createIdxLayout() *record.Layout {
 	schema.AddIntField("block") // Block number of the record
 	schema.AddIntField("id")    // Record ID within the block
 
-	// Add field for indexed value based on its type
-	if ii.tableSchema.DataType(ii.fldName) == sch.INTEGER {
-		schema.AddIntField("dataval") // For integer values
-	} else {
-		// For string values, use the same length as original field
-		fldLen := ii.tableSchema.Length(ii.fldName)
-		schema.AddStringField("dataval", fldLen)
+	// Add a field for each indexed value based on its type.
+	// A single-field key is stored in "dataval", the fields of a
+	// composite key in "dataval0", "dataval1", ...
+	for i, fldName := range ii.fldNames {
+		valName := "dataval"
+		if ii.IsComposite() {
+			valName = fmt.Sprintf("dataval%d", i)
+		}
+
+		if fieldType := ii.tableSchema.DataType(fldName); fieldType == sch.INTEGER {
+			schema.AddIntField(valName) // For integer values
+		} else if fieldType == sch.BIGINT || fieldType == sch.FLOAT || fieldType.IsTemporal() || fieldType == sch.BOOLEAN {
+			schema.AddField(valName, fieldType, 0) // For bigints, floats, dates, timestamps and booleans
+		} else {
+			// For string values, use the same length as original field
+			fldLen := ii.tableSchema.Length(fldName)
+			schema.AddStringField(valName, fldLen)
+			schema.SetCollation(valName, ii.tableSchema.Collation(fldName))
+		}
 	}
 
 	return record.NewLayout(schema)
@@ -1,11 +1,20 @@
 package metadata
 
 import (
+	"centauri/internal/app/index"
 	"centauri/internal/app/record"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
+	"fmt"
+	"strings"
 )
 
+// The maximum length of the comma-separated field list of an index
+const MAX_FIELDLIST = 64
+
+// The maximum length of the name of an index structure
+const MAX_INDEX_TYPE = 8
+
 // Handles the creation  and management of indexes in the database.
 // It maintains a catalog of all indexes (idxcat) and provides methods to:
 // - Create new indexes
@@ -22,18 +31,16 @@ type IndexManager struct {
 // For new databases, it creates the index catalog table.
 // For existing databases, it loads the existing catalog.
 func NewIndexManager(isNew bool, tm *TableManager, sm *StatManager, tx *tx.Transaction) *IndexManager {
+	idxSchema := indexCatalogSchema()
+
 	if isNew {
-		schema := schema.NewSchema()
-		schema.AddStringField("indexname", MAX_NAME)
-		schema.AddStringField("tablename", MAX_NAME)
-		schema.AddStringField("fieldname", MAX_NAME)
-		tm.CreateTable("idxcat", schema, tx)
+		tm.CreateTable("idxcat", idxSchema, tx)
 	}
 
 	return &IndexManager{
 		tm:     tm,
 		sm:     sm,
-		layout: tm.GetLayout("idxcat", tx),
+		layout: record.NewLayout(idxSchema),
 	}
 }
 
@@ -41,18 +48,46 @@ func NewIndexManager(isNew bool, tm *TableManager, sm *StatManager, tx *tx.Trans
 // This method adds a record to the idxcat table with information about:
 // - The name of the index
 // - The table being indexed
-// - The field being indexed
-func (im *IndexManager) CreateIndex(idxName string, tableName string, fieldName string, tx *tx.Transaction) {
+// - The fields being indexed, in key order
+// - The structure of the index, index.INDEX_HASH or index.INDEX_BTREE
+// - Whether the index is unique
+func (im *IndexManager) CreateIndex(idxName string, tableName string, fieldNames []string, indexType string, unique bool, tx *tx.Transaction) error {
+	if len(fieldNames) == 0 {
+		return fmt.Errorf("index %s has no fields", idxName)
+	}
+	if indexType != index.INDEX_HASH && indexType != index.INDEX_BTREE {
+		return fmt.Errorf("unknown index type %s", indexType)
+	}
+	// B-tree pages store a single key
+	if indexType == index.INDEX_BTREE && len(fieldNames) > 1 {
+		return fmt.Errorf("B-tree index %s can't have more than one field", idxName)
+	}
+
+	fieldList := strings.Join(fieldNames, ",")
+	if len(fieldList) > MAX_FIELDLIST {
+		return fmt.Errorf("field list of index %s exceeds %d characters", idxName, MAX_FIELDLIST)
+	}
+
 	ts := record.NewTableScan(tx, "idxcat", im.layout)
 	ts.Insert()
 	ts.SetString("indexname", idxName)
 	ts.SetString("tablename", tableName)
-	ts.SetString("fieldname", fieldName)
+	ts.SetString("fieldlist", fieldList)
+	ts.SetString("indextype", indexType)
+	isUnique := 0
+	if unique {
+		isUnique = 1
+	}
+	ts.SetInt("isunique", isUnique)
 	ts.Close()
+
+	return nil
 }
 
 // Retrieves information about all indexes on a specified table.
 // It scans the index catalog and creates IndexInfo objects for each index found.
+// Single-field indexes are keyed by their field name; composite indexes by
+// their comma-separated field list, e.g. "lastname,firstname".
 func (im *IndexManager) GetIndexInfo(tableName string, tx *tx.Transaction) map[string]IndexInfo {
 	result := make(map[string]IndexInfo)
 	ts := record.NewTableScan(tx, "idxcat", im.layout)
@@ -63,19 +98,83 @@ func (im *IndexManager) GetIndexInfo(tableName string, tx *tx.Transaction) map[s
 		if ts.GetString("tablename") == tableName {
 			// Get index details
 			idxName := ts.GetString("indexname")
-			fldName := ts.GetString("fieldname")
+			fieldList := ts.GetString("fieldlist")
+			indexType := ts.GetString("indextype")
+			unique := ts.GetInt("isunique") == 1
 
 			// Get table information
-			tableLayout := im.tm.GetLayout(tableName, tx)
+			tableLayout, err := im.tm.GetLayout(tableName, tx)
+			if err != nil {
+				// The index refers to a table that no longer exists
+				continue
+			}
 			tableStat := im.sm.GetStatInfo(tableName, tableLayout, tx)
 
 			// Create index information object
-			indexInfo := *NewIndexInfo(idxName, fldName, tableLayout.Schema(), tx, &tableStat)
+			fldNames := strings.Split(fieldList, ",")
+			indexInfo := *NewCompositeIndexInfo(idxName, fldNames, indexType, unique, tableLayout.Schema(), tx, &tableStat)
+			indexInfo.height = im.sm.IndexHeight(idxName)
 
-			// Store in result map, keyed by field name
-			result[fldName] = indexInfo
+			// Store in result map, keyed by field list
+			result[fieldList] = indexInfo
 		}
 	}
 	ts.Close()
 	return result
 }
+
+// Returns the names of all indexes defined on the specified table
+func (im *IndexManager) IndexesOn(tableName string, tx *tx.Transaction) []string {
+	ts := record.NewTableScan(tx, "idxcat", im.layout)
+	defer ts.Close()
+
+	indexes := []string{}
+	for ts.Next() {
+		if ts.GetString("tablename") == tableName {
+			indexes = append(indexes, ts.GetString("indexname"))
+		}
+	}
+	return indexes
+}
+
+// Removes the index from the index catalog
+func (im *IndexManager) DropIndex(idxName string, tx *tx.Transaction) {
+	deleteMatching(tx, "idxcat", im.layout, "indexname", idxName)
+}
+
+// Returns the schema of the index catalog
+func indexCatalogSchema() *schema.Schema {
+	idxSchema := schema.NewSchema()
+	idxSchema.AddStringField("indexname", MAX_NAME)
+	idxSchema.AddStringField("tablename", MAX_NAME)
+	idxSchema.AddStringField("fieldlist", MAX_FIELDLIST) // indexed fields, comma-separated
+	idxSchema.AddStringField("indextype", MAX_INDEX_TYPE)
+	idxSchema.AddIntField("isunique") // 1 if no two records may have the same key
+	return idxSchema
+}
+
+// Returns the name of the table the index is defined on
+func (im *IndexManager) TableOf(idxName string, tx *tx.Transaction) (string, bool) {
+	ts := record.NewTableScan(tx, "idxcat", im.layout)
+	defer ts.Close()
+
+	for ts.Next() {
+		if ts.GetString("indexname") == idxName {
+			return ts.GetString("tablename"), true
+		}
+	}
+	return "", false
+}
+
+// Returns the structure of the index, index.INDEX_HASH or index.INDEX_BTREE
+func (im *IndexManager) TypeOf(idxName string, tx *tx.Transaction) string {
+	ts := record.NewTableScan(tx, "idxcat", im.layout)
+	defer ts.Close()
+
+	for ts.Next() {
+		if ts.GetString("indexname") == idxName {
+			return ts.GetString("indextype")
+		}
+	}
+	return ""
+}
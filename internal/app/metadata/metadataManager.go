@@ -1,9 +1,30 @@
 package metadata
 
 import (
+	"centauri/internal/app/index"
+	"centauri/internal/app/index/btree"
+	"centauri/internal/app/index/hash"
 	"centauri/internal/app/record"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// Returned when a statement refers to a table that isn't in the catalog
+	ErrTableNotFound = errors.New("table not found")
+	// Returned when dropping a table that views or indexes still depend on
+	ErrDependentObjects = errors.New("table has dependent objects")
+	// Returned when creating a table or view under a name already in use
+	ErrTableExists = errors.New("table already exists")
+	// Returned when a statement refers to an index that isn't in the catalog
+	ErrIndexNotFound = errors.New("index not found")
+	// Returned when adding a field to a table that already has one of the name
+	ErrFieldExists = errors.New("field already exists")
+	// Returned when a statement refers to a field that the table doesn't have
+	ErrFieldNotFound = errors.New("field not found")
 )
 
 // MetaDataManager manages database metadata including tables, views, statistics and indexes.
@@ -14,46 +35,433 @@ type MetaDataManager struct {
 	vm *ViewManager
 	sm *StatManager
 	im *IndexManager
+	um *UserManager
+	pm *PrivilegeManager
 }
 
-func NewMetaDataManager(isNew bool, tx *tx.Transaction) *MetaDataManager {
+// Creates the metadata manager. For a new database the catalog tables are
+// created; for an existing one the catalog is first migrated to the current
+// format if it was written by an older version.
+func NewMetaDataManager(isNew bool, tx *tx.Transaction) (*MetaDataManager, error) {
 	tm := NewTableManager(isNew, tx)
+	if isNew {
+		writeCatalogVersion(CATALOG_VERSION, tx)
+	} else if err := migrateCatalog(tm, tx); err != nil {
+		return nil, err
+	}
+
 	vm := NewViewManager(isNew, tm, tx)
 	sm := NewStatManager(tm, tx)
 	im := NewIndexManager(isNew, tm, sm, tx)
+	um := NewUserManager(isNew, tm, tx)
+	pm := NewPrivilegeManager(isNew, tm, tx)
 
 	return &MetaDataManager{
 		tm: tm,
 		vm: vm,
 		sm: sm,
 		im: im,
-	}
+		um: um,
+		pm: pm,
+	}, nil
 }
 
 func (mm *MetaDataManager) CreateTable(tableName string, schema *schema.Schema, tx *tx.Transaction) {
 	mm.tm.CreateTable(tableName, schema, tx)
 }
 
-func (mm *MetaDataManager) GetLayout(tableName string, tx *tx.Transaction) *record.Layout {
+// Creates a table with the specified storage options.
+// Fails with ErrTableExists if a table or view of the name exists, and
+// if the table or a field has a name too long for the catalog.
+func (mm *MetaDataManager) CreateTableWithOptions(tableName string, schema *schema.Schema, options record.StorageOptions, tx *tx.Transaction) error {
+	if err := mm.checkNewName(tableName, tx); err != nil {
+		return err
+	}
+	for _, fieldName := range schema.Fields() {
+		if len(fieldName) > MAX_NAME {
+			return fmt.Errorf("field name %s is longer than %d characters", fieldName, MAX_NAME)
+		}
+	}
+	if p := options.Partitioning; p != nil {
+		for _, part := range p.Partitions {
+			if len(part.Name) > MAX_NAME {
+				return fmt.Errorf("partition name %s is longer than %d characters", part.Name, MAX_NAME)
+			}
+		}
+	}
+
+	mm.tm.CreateTableWithOptions(tableName, schema, options, tx)
+	return nil
+}
+
+// Returns the layout of the table, or ErrTableNotFound if it doesn't exist.
+func (mm *MetaDataManager) GetLayout(tableName string, tx *tx.Transaction) (*record.Layout, error) {
 	return mm.tm.GetLayout(tableName, tx)
 }
 
-func (mm *MetaDataManager) CreateView(viewName string, viewDef string, tx *tx.Transaction) {
-	mm.vm.CreateView(viewName, viewDef, tx)
+// Returns the next value of the sequence of an AUTO_INCREMENT field and
+// advances it in the transaction. Returns false if the field has none.
+func (mm *MetaDataManager) NextSequenceValue(tableName string, fieldName string, tx *tx.Transaction) (int64, bool) {
+	return mm.tm.qm.NextValue(tableName, fieldName, tx)
+}
+
+// Moves the sequence of an AUTO_INCREMENT field past a value inserted explicitly
+func (mm *MetaDataManager) ObserveSequenceValue(tableName string, fieldName string, val int64, tx *tx.Transaction) {
+	mm.tm.qm.Observe(tableName, fieldName, val, tx)
+}
+
+// Creates a view. tables lists the tables (or views) the definition reads from,
+// which are recorded so that dropping one of them can detect the dependency.
+// Fails with ErrTableExists if a table or view of the name exists, and if
+// the name or definition is too long for the catalog.
+func (mm *MetaDataManager) CreateView(viewName string, viewDef string, tables []string, tx *tx.Transaction) error {
+	if err := mm.checkNewName(viewName, tx); err != nil {
+		return err
+	}
+	if len(viewDef) > MAX_VIEWDEF {
+		return fmt.Errorf("definition of view %s is longer than %d characters", viewName, MAX_VIEWDEF)
+	}
+
+	mm.vm.CreateView(viewName, viewDef, tables, tx)
+	return nil
+}
+
+// Checks that a new table or view can be registered under the name
+func (mm *MetaDataManager) checkNewName(name string, tx *tx.Transaction) error {
+	if len(name) > MAX_NAME {
+		return fmt.Errorf("name %s is longer than %d characters", name, MAX_NAME)
+	}
+	if _, err := mm.tm.GetLayout(name, tx); err == nil {
+		return fmt.Errorf("%w: %s", ErrTableExists, name)
+	}
+	if mm.vm.GetViewDef(name, tx) != "" {
+		return fmt.Errorf("%w: view %s", ErrTableExists, name)
+	}
+	return nil
+}
+
+// Returns the names of the user tables, excluding the catalog tables.
+func (mm *MetaDataManager) TableNames(tx *tx.Transaction) []string {
+	return mm.tm.TableNames(tx)
+}
+
+// Returns the names of all views.
+func (mm *MetaDataManager) ViewNames(tx *tx.Transaction) []string {
+	return mm.vm.ViewNames(tx)
 }
 
 func (mm *MetaDataManager) GetViewDef(viewName string, tx *tx.Transaction) string {
 	return mm.vm.GetViewDef(viewName, tx)
 }
 
-func (mm *MetaDataManager) CreateIndex(idxName string, tableName string, fieldName string, tx *tx.Transaction) {
-	mm.im.CreateIndex(idxName, tableName, fieldName, tx)
+// Creates an index on a field, with the structure of the index type,
+// index.INDEX_HASH or index.INDEX_BTREE. The update planners keep
+// a unique index from getting two entries with the same key.
+func (mm *MetaDataManager) CreateIndex(idxName string, tableName string, fieldName string, indexType string, unique bool, tx *tx.Transaction) error {
+	return mm.im.CreateIndex(idxName, tableName, []string{fieldName}, indexType, unique, tx)
+}
+
+// Creates an index whose key consists of the specified fields, in order.
+// The index structures only store single-field keys, so composite indexes
+// are recorded in the catalog but not yet maintained by the update planners.
+func (mm *MetaDataManager) CreateCompositeIndex(idxName string, tableName string, fieldNames []string, tx *tx.Transaction) error {
+	return mm.im.CreateIndex(idxName, tableName, fieldNames, index.INDEX_HASH, false, tx)
 }
 
 func (mm *MetaDataManager) GetIndexInfo(tableName string, tx *tx.Transaction) map[string]IndexInfo {
 	return mm.im.GetIndexInfo(tableName, tx)
 }
 
+// Gathers the statistics of a table, see StatManager.Analyze, along with
+// the height of each of its B-tree indexes.
+// Fails with ErrTableNotFound if there is no table of the name.
+func (mm *MetaDataManager) Analyze(tableName string, tx *tx.Transaction) error {
+	layout, err := mm.tm.GetLayout(tableName, tx)
+	if err != nil {
+		return err
+	}
+	mm.sm.Analyze(tableName, layout, tx)
+
+	heights := make(map[string]int)
+	for _, ii := range mm.im.GetIndexInfo(tableName, tx) {
+		idx := ii.Open()
+		if tree, ok := idx.(index.TreeIndex); ok {
+			heights[ii.IndexName()] = tree.Height()
+		}
+		idx.Close()
+	}
+	mm.sm.SetIndexHeights(tableName, heights, tx)
+	return nil
+}
+
 func (mm *MetaDataManager) GetStatInfo(tableName string, layout *record.Layout, tx *tx.Transaction) StatInfo {
 	return mm.sm.GetStatInfo(tableName, layout, tx)
 }
+
+// Records that count records of the table were inserted, deleted or modified,
+// so its statistics can be refreshed once they become stale.
+func (mm *MetaDataManager) RecordModification(tableName string, count int) {
+	mm.sm.RecordModification(tableName, count)
+}
+
+// Returns the number of records in the table as maintained in the catalog,
+// or UNTRACKED_ROWS if the table's rows aren't counted.
+func (mm *MetaDataManager) RowCount(tableName string, tx *tx.Transaction) int {
+	return mm.tm.RowCount(tableName, tx)
+}
+
+// Adds delta to the catalog row count of the table.
+// Called by the update planners after inserting or deleting records.
+func (mm *MetaDataManager) AdjustRowCount(tableName string, delta int, tx *tx.Transaction) {
+	mm.tm.AdjustRowCount(tableName, delta, tx)
+}
+
+// Writes the row count changes of committed transactions into the table catalog
+func (mm *MetaDataManager) SaveRowCounts(tx *tx.Transaction) {
+	mm.tm.SaveRowCounts(tx)
+}
+
+// Makes RowCount report every table as untracked, for a standby, where the
+// counts lag behind those the primary keeps in memory
+func (mm *MetaDataManager) IgnoreRowCounts() {
+	mm.tm.rows.ignored = true
+}
+
+// Recounts the records of every table whose rows are counted
+func (mm *MetaDataManager) RecountRows(tx *tx.Transaction) {
+	mm.tm.RecountRows(tx)
+}
+
+// Adds a user with the given password, or fails with ErrUserExists.
+func (mm *MetaDataManager) CreateUser(userName string, password string, superuser bool, tx *tx.Transaction) error {
+	return mm.um.CreateUser(userName, password, superuser, tx)
+}
+
+// Changes the password of a user, or fails with ErrUserNotFound.
+func (mm *MetaDataManager) AlterUserPassword(userName string, password string, tx *tx.Transaction) error {
+	return mm.um.AlterPassword(userName, password, tx)
+}
+
+// Checks a user's password, failing with ErrAuthFailed if it doesn't match.
+func (mm *MetaDataManager) Authenticate(userName string, password string, tx *tx.Transaction) (UserInfo, error) {
+	return mm.um.Authenticate(userName, password, tx)
+}
+
+func (mm *MetaDataManager) GetUser(userName string, tx *tx.Transaction) (UserInfo, bool) {
+	return mm.um.GetUser(userName, tx)
+}
+
+// Returns the names of all users.
+func (mm *MetaDataManager) UserNames(tx *tx.Transaction) []string {
+	return mm.um.UserNames(tx)
+}
+
+// Gives a user privileges on a table or view.
+// Fails with ErrUserNotFound or ErrTableNotFound if either doesn't exist.
+func (mm *MetaDataManager) Grant(userName string, tableName string, privileges []string, tx *tx.Transaction) error {
+	if err := mm.checkPrivilegeTarget(userName, tableName, tx); err != nil {
+		return err
+	}
+	return mm.pm.Grant(userName, tableName, privileges, tx)
+}
+
+// Takes privileges on a table or view away from a user.
+func (mm *MetaDataManager) Revoke(userName string, tableName string, privileges []string, tx *tx.Transaction) error {
+	if err := mm.checkPrivilegeTarget(userName, tableName, tx); err != nil {
+		return err
+	}
+	return mm.pm.Revoke(userName, tableName, privileges, tx)
+}
+
+// Returns true if the user may use the privilege on the table or view.
+// Superusers hold every privilege.
+func (mm *MetaDataManager) HasPrivilege(user UserInfo, tableName string, privilege string, tx *tx.Transaction) bool {
+	return user.Superuser || mm.pm.HasPrivilege(user.Name, tableName, privilege, tx)
+}
+
+func (mm *MetaDataManager) checkPrivilegeTarget(userName string, tableName string, tx *tx.Transaction) error {
+	if _, ok := mm.um.GetUser(userName, tx); !ok {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, userName)
+	}
+	if !mm.tm.TableExists(tableName, tx) && mm.vm.GetViewDef(tableName, tx) == "" {
+		return fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
+	}
+	return nil
+}
+
+// Returns the statistics manager, e.g. to configure background refreshes.
+func (mm *MetaDataManager) StatMgr() *StatManager {
+	return mm.sm
+}
+
+// Returns the views and indexes that depend on the specified table.
+func (mm *MetaDataManager) Dependents(tableName string, tx *tx.Transaction) (views []string, indexes []string) {
+	return mm.vm.ViewsOn(tableName, tx), mm.im.IndexesOn(tableName, tx)
+}
+
+// Removes a table from the database along with all of its records.
+// If views or indexes depend on the table, the drop fails with ErrDependentObjects
+// unless cascade is set, in which case the dependent views (and views built on them)
+// and the table's indexes are dropped as well.
+func (mm *MetaDataManager) DropTable(tableName string, cascade bool, tx *tx.Transaction) error {
+	if !mm.tm.TableExists(tableName, tx) {
+		return fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
+	}
+	layout, err := mm.tm.GetLayout(tableName, tx)
+	if err != nil {
+		return err
+	}
+
+	views, indexes := mm.Dependents(tableName, tx)
+	if !cascade && (len(views) > 0 || len(indexes) > 0) {
+		var deps []string
+		for _, v := range views {
+			deps = append(deps, "view "+v)
+		}
+		for _, idx := range indexes {
+			deps = append(deps, "index "+idx)
+		}
+		return fmt.Errorf("%w: %s is used by %s", ErrDependentObjects, tableName, strings.Join(deps, ", "))
+	}
+
+	dropped := make(map[string]bool)
+	for _, v := range views {
+		mm.dropViewCascade(v, dropped, tx)
+	}
+	mm.pm.RevokeAll(tableName, tx)
+
+	// Delete the records while the index metadata still exists, so the
+	// index entries are removed along with them
+	mm.deleteAllRecords(tableName, tx)
+
+	for _, idx := range indexes {
+		mm.dropIndex(idx, tx)
+	}
+	for _, table := range record.StorageTables(tableName, layout) {
+		removeOnCommit(table, record.TableFile(table, layout), tx)
+	}
+	mm.tm.DropTable(tableName, tx)
+	mm.sm.dropTableStats(tableName, tx)
+	mm.sm.Forget(tableName)
+
+	return nil
+}
+
+// Removes an index from the database along with its entries.
+// Fails with ErrIndexNotFound if there is no index of the name.
+func (mm *MetaDataManager) DropIndex(idxName string, tx *tx.Transaction) error {
+	tableName, ok := mm.im.TableOf(idxName, tx)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrIndexNotFound, idxName)
+	}
+	mm.dropIndex(idxName, tx)
+	mm.sm.Forget(tableName)
+	return nil
+}
+
+// Removes the index from the catalog and its bucket or B-tree files once the transaction commits
+func (mm *MetaDataManager) dropIndex(idxName string, tx *tx.Transaction) {
+	indexType := mm.im.TypeOf(idxName, tx)
+	mm.im.DropIndex(idxName, tx)
+	mm.sm.dropIndexStats(idxName, tx)
+	if indexType == index.INDEX_BTREE {
+		tx.OnCommit(func() {
+			for _, filename := range btree.FileNames(idxName) {
+				if err := tx.RemoveFile(filename); err != nil {
+					fmt.Printf("warning: %v\n", err)
+				}
+			}
+		})
+		return
+	}
+	for bucket := 0; bucket < hash.NUM_BUCKETS; bucket++ {
+		bucketTable := hash.BucketTableName(idxName, bucket)
+		removeOnCommit(bucketTable, bucketTable+".tbl", tx)
+	}
+}
+
+// Returns the name of the table an index is defined on
+func (mm *MetaDataManager) IndexTable(idxName string, tx *tx.Transaction) (string, bool) {
+	return mm.im.TableOf(idxName, tx)
+}
+
+// Removes a view from the database.
+// If other views are defined on it, the drop fails with ErrDependentObjects
+// unless cascade is set, in which case they are dropped as well.
+// Fails with ErrTableNotFound if there is no view of the name.
+func (mm *MetaDataManager) DropView(viewName string, cascade bool, tx *tx.Transaction) error {
+	if mm.vm.GetViewDef(viewName, tx) == "" {
+		return fmt.Errorf("%w: view %s", ErrTableNotFound, viewName)
+	}
+
+	views := mm.vm.ViewsOn(viewName, tx)
+	if !cascade && len(views) > 0 {
+		return fmt.Errorf("%w: %s is used by view %s", ErrDependentObjects, viewName, strings.Join(views, ", view "))
+	}
+	mm.dropViewCascade(viewName, make(map[string]bool), tx)
+	return nil
+}
+
+// Removes the files storing a table and its blobs once the transaction
+// commits, reclaiming their space. Until then the files stay, so that a
+// rollback can restore the records deleted from them.
+func removeOnCommit(tableName string, tableFile string, tx *tx.Transaction) {
+	tx.OnCommit(func() {
+		for _, filename := range []string{tableFile, record.OverflowFile(tableName)} {
+			if err := tx.RemoveFile(filename); err != nil {
+				fmt.Printf("warning: %v\n", err)
+			}
+		}
+	})
+}
+
+// Drops a view and, recursively, every view defined on top of it.
+func (mm *MetaDataManager) dropViewCascade(viewName string, dropped map[string]bool, tx *tx.Transaction) {
+	if dropped[viewName] {
+		return
+	}
+	dropped[viewName] = true
+
+	for _, v := range mm.vm.ViewsOn(viewName, tx) {
+		mm.dropViewCascade(v, dropped, tx)
+	}
+	mm.vm.DropView(viewName, tx)
+	mm.pm.RevokeAll(viewName, tx)
+}
+
+// Deletes every record of the table and removes the matching entries from its indexes.
+func (mm *MetaDataManager) deleteAllRecords(tableName string, tx *tx.Transaction) {
+	layout, err := mm.tm.GetLayout(tableName, tx)
+	if err != nil {
+		return
+	}
+	indexes := mm.im.GetIndexInfo(tableName, tx)
+
+	for _, table := range record.StorageTables(tableName, layout) {
+		mm.deleteStoredRecords(table, layout, indexes, tx)
+	}
+}
+
+// Deletes the records stored under the name and their index entries
+func (mm *MetaDataManager) deleteStoredRecords(table string, layout *record.Layout, indexes map[string]IndexInfo, tx *tx.Transaction) {
+	ts := record.NewTableScan(tx, table, layout)
+	defer ts.Close()
+
+	for ts.Next() {
+		rid, _ := ts.GetRID()
+		for _, ii := range indexes {
+			// Composite keys aren't stored by the index structures
+			if ii.IsComposite() {
+				continue
+			}
+
+			fldName := ii.FieldNames()[0]
+			val := ts.GetVal(fldName)
+
+			idx := ii.Open()
+			idx.Delete(val, rid)
+			idx.Close()
+		}
+		ts.Delete()
+	}
+}
@@ -0,0 +1,54 @@
+package metadata
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+const (
+	SALT_LENGTH         = 16    // length of a password salt in bytes
+	PASSWORD_ITERATIONS = 10000 // rounds of PBKDF2 used to hash a new password
+)
+
+// Returns a new random salt, hex encoded
+func newSalt() (string, error) {
+	salt := make([]byte, SALT_LENGTH)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(salt), nil
+}
+
+// Hashes a password with PBKDF2-HMAC-SHA256, returning the hex encoded key.
+// A single block of output is derived, which is as long as a SHA-256 sum.
+func hashPassword(password string, salt string, iterations int) string {
+	mac := hmac.New(sha256.New, []byte(password))
+
+	// U1 = HMAC(password, salt || INT(1))
+	mac.Write([]byte(salt))
+	binary.Write(mac, binary.BigEndian, uint32(1))
+	u := mac.Sum(nil)
+
+	key := make([]byte, len(u))
+	copy(key, u)
+
+	// Ui = HMAC(password, Ui-1), and the key is U1 ^ U2 ^ ... ^ Un
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return hex.EncodeToString(key)
+}
+
+// Checks a password against a stored hash in constant time
+func checkPassword(password string, salt string, iterations int, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashPassword(password, salt, iterations)), []byte(hash)) == 1
+}
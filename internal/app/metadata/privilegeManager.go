@@ -0,0 +1,154 @@
+package metadata
+
+import (
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+)
+
+// Privileges that can be granted on a table or view
+const (
+	PRIV_SELECT = "select" // read its records
+	PRIV_INSERT = "insert" // add records, including with COPY FROM
+	PRIV_UPDATE = "update" // change records
+	PRIV_DELETE = "delete" // remove records
+	PRIV_ALTER  = "alter"  // DDL on the table, such as creating indexes on it
+	PRIV_ALL    = "all"    // shorthand for all of the above
+)
+
+// The longest privilege name stored in privcat
+const MAX_PRIVILEGE_NAME = 10
+
+// Returned when granting or revoking a privilege that doesn't exist
+var ErrUnknownPrivilege = errors.New("unknown privilege")
+
+// The privileges PRIV_ALL stands for
+var AllPrivileges = []string{PRIV_SELECT, PRIV_INSERT, PRIV_UPDATE, PRIV_DELETE, PRIV_ALTER}
+
+// Handles the privileges users hold on tables and views.
+// Each privilege a user holds on a table is a record of the
+// privcat catalog table. Superusers hold every privilege
+// implicitly and have no records.
+type PrivilegeManager struct {
+	pcatLayout *record.Layout // layout for the privilege catalog
+}
+
+// Creates a new privilege manager instance
+func NewPrivilegeManager(isNew bool, tableMgr *TableManager, tx *tx.Transaction) *PrivilegeManager {
+	pm := &PrivilegeManager{
+		pcatLayout: record.NewLayout(privilegeCatalogSchema()),
+	}
+
+	if isNew {
+		tableMgr.CreateTable("privcat", privilegeCatalogSchema(), tx)
+	}
+	return pm
+}
+
+// Returns the schema of the privilege catalog
+func privilegeCatalogSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddStringField("username", MAX_NAME)
+	sch.AddStringField("tablename", MAX_NAME)
+	sch.AddStringField("privilege", MAX_PRIVILEGE_NAME)
+	return sch
+}
+
+// Expands PRIV_ALL and checks that every privilege is known
+func expandPrivileges(privileges []string) ([]string, error) {
+	expanded := []string{}
+	for _, priv := range privileges {
+		if priv == PRIV_ALL {
+			expanded = append(expanded, AllPrivileges...)
+			continue
+		}
+		if !isPrivilege(priv) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPrivilege, priv)
+		}
+		expanded = append(expanded, priv)
+	}
+	return expanded, nil
+}
+
+func isPrivilege(priv string) bool {
+	for _, p := range AllPrivileges {
+		if p == priv {
+			return true
+		}
+	}
+	return false
+}
+
+// Gives a user privileges on a table. Privileges
+// the user already holds are left as they are.
+func (pm *PrivilegeManager) Grant(userName string, tableName string, privileges []string, tx *tx.Transaction) error {
+	privileges, err := expandPrivileges(privileges)
+	if err != nil {
+		return err
+	}
+
+	for _, priv := range privileges {
+		if pm.HasPrivilege(userName, tableName, priv, tx) {
+			continue
+		}
+
+		ts := record.NewTableScan(tx, "privcat", pm.pcatLayout)
+		ts.Insert()
+		ts.SetString("username", userName)
+		ts.SetString("tablename", tableName)
+		ts.SetString("privilege", priv)
+		ts.Close()
+	}
+	return nil
+}
+
+// Takes privileges on a table away from a user.
+// Privileges the user doesn't hold are ignored.
+func (pm *PrivilegeManager) Revoke(userName string, tableName string, privileges []string, tx *tx.Transaction) error {
+	privileges, err := expandPrivileges(privileges)
+	if err != nil {
+		return err
+	}
+
+	revoked := make(map[string]bool)
+	for _, priv := range privileges {
+		revoked[priv] = true
+	}
+
+	ts := record.NewTableScan(tx, "privcat", pm.pcatLayout)
+	defer ts.Close()
+
+	for ts.Next() {
+		if ts.GetString("username") == userName && ts.GetString("tablename") == tableName && revoked[ts.GetString("privilege")] {
+			ts.Delete()
+		}
+	}
+	return nil
+}
+
+// Returns true if the user was granted the privilege on the table
+func (pm *PrivilegeManager) HasPrivilege(userName string, tableName string, privilege string, tx *tx.Transaction) bool {
+	ts := record.NewTableScan(tx, "privcat", pm.pcatLayout)
+	defer ts.Close()
+
+	for ts.Next() {
+		if ts.GetString("username") == userName && ts.GetString("tablename") == tableName && ts.GetString("privilege") == privilege {
+			return true
+		}
+	}
+	return false
+}
+
+// Removes every privilege held on a table, e.g. when the table is dropped
+func (pm *PrivilegeManager) RevokeAll(tableName string, tx *tx.Transaction) {
+	ts := record.NewTableScan(tx, "privcat", pm.pcatLayout)
+	defer ts.Close()
+
+	for ts.Next() {
+		if ts.GetString("tablename") == tableName {
+			ts.Delete()
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package metadata
+
+import (
+	"centauri/internal/app/index"
+	"centauri/internal/app/index/btree"
+	"centauri/internal/app/index/hash"
+	"centauri/internal/app/record"
+	"centauri/internal/app/tx"
+	"fmt"
+)
+
+// Name of the system view listing the size of every table and index,
+// e.g. SELECT relname, blocks, bytes FROM relation_sizes WHERE relname = 'students'
+const RELATION_SIZES_VIEW = "relation_sizes"
+
+// Kinds of relations reported by RelationSize
+const (
+	RELATION_TABLE = "table"
+	RELATION_INDEX = "index"
+)
+
+// Describes the storage used by a table or an index
+type RelationSize struct {
+	Name   string // Name of the table or index
+	Kind   string // RELATION_TABLE or RELATION_INDEX
+	Blocks int    // Number of blocks in the relation's files
+	Rows   int    // Number of records (index entries for an index)
+	Bytes  int    // Number of bytes in the relation's files
+}
+
+// Returns the size of the specified table.
+// Returns ErrTableNotFound if the table doesn't exist.
+func (mm *MetaDataManager) TableSize(tableName string, tx *tx.Transaction) (RelationSize, error) {
+	layout, err := mm.tm.GetLayout(tableName, tx)
+	if err != nil {
+		return RelationSize{}, err
+	}
+
+	blocks, err := storedBlocks(tableName, layout, tx)
+	if err != nil {
+		return RelationSize{}, err
+	}
+
+	rows := mm.tm.RowCount(tableName, tx)
+	if rows == UNTRACKED_ROWS {
+		rows = 0
+		for _, table := range record.StorageTables(tableName, layout) {
+			rows += countRecords(table, layout, tx)
+		}
+	}
+
+	return RelationSize{
+		Name:   tableName,
+		Kind:   RELATION_TABLE,
+		Blocks: blocks,
+		Rows:   rows,
+		Bytes:  blocks * tx.FileBlockSize(record.TableFile(tableName, layout)),
+	}, nil
+}
+
+// Returns the size of the specified index, summed over all of its bucket files.
+// Returns ErrIndexNotFound if the index doesn't exist.
+func (mm *MetaDataManager) IndexSize(idxName string, tx *tx.Transaction) (RelationSize, error) {
+	tableName, ok := mm.im.TableOf(idxName, tx)
+	if !ok {
+		return RelationSize{}, fmt.Errorf("%w: %s", ErrIndexNotFound, idxName)
+	}
+
+	for _, ii := range mm.im.GetIndexInfo(tableName, tx) {
+		if ii.IndexName() == idxName {
+			return indexSize(&ii, tx)
+		}
+	}
+	return RelationSize{}, fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
+}
+
+// Returns the sizes of all tables, including the catalog tables, followed by all indexes.
+func (mm *MetaDataManager) RelationSizes(tx *tx.Transaction) ([]RelationSize, error) {
+	var tables []string
+	tcat := record.NewTableScan(tx, "tblcat", mm.tm.tcatLayout)
+	for tcat.Next() {
+		tables = append(tables, tcat.GetString("tblname"))
+	}
+	tcat.Close()
+
+	var sizes []RelationSize
+	var indexes []RelationSize
+
+	for _, tableName := range tables {
+		size, err := mm.TableSize(tableName, tx)
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, size)
+
+		for _, ii := range mm.im.GetIndexInfo(tableName, tx) {
+			size, err := indexSize(&ii, tx)
+			if err != nil {
+				return nil, err
+			}
+			indexes = append(indexes, size)
+		}
+	}
+
+	return append(sizes, indexes...), nil
+}
+
+// Calculates the size of an index from its bucket files, or its leaf and
+// directory files for a B-tree. Buckets that were never written to have no
+// file and are skipped, so that reporting doesn't create them.
+func indexSize(ii *IndexInfo, tx *tx.Transaction) (RelationSize, error) {
+	size := RelationSize{Name: ii.IndexName(), Kind: RELATION_INDEX}
+
+	if ii.IndexType() == index.INDEX_BTREE {
+		for _, filename := range btree.FileNames(ii.IndexName()) {
+			blocks, err := tx.Size(filename)
+			if err != nil {
+				return size, err
+			}
+			size.Blocks += blocks
+		}
+		if size.Blocks > 0 {
+			idx := ii.Open().(*btree.BTreeIndex)
+			size.Rows = idx.NumEntries()
+			idx.Close()
+		}
+		size.Bytes = size.Blocks * tx.BlockSize()
+		return size, nil
+	}
+
+	for bucket := 0; bucket < hash.NUM_BUCKETS; bucket++ {
+		bucketTable := hash.BucketTableName(ii.IndexName(), bucket)
+
+		blocks, err := tx.Size(bucketTable + ".tbl")
+		if err != nil {
+			return size, err
+		}
+		if blocks == 0 {
+			continue
+		}
+
+		size.Blocks += blocks
+		size.Rows += countRecords(bucketTable, ii.idxLayout, tx)
+	}
+
+	size.Bytes = size.Blocks * tx.BlockSize()
+	return size, nil
+}
+
+// Counts the records of a table by scanning it
+func countRecords(tableName string, layout *record.Layout, tx *tx.Transaction) int {
+	ts := record.NewTableScan(tx, tableName, layout)
+	defer ts.Close()
+
+	count := 0
+	for ts.Next() {
+		count++
+	}
+	return count
+}
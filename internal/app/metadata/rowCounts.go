@@ -0,0 +1,140 @@
+package metadata
+
+import (
+	"centauri/internal/app/record"
+	"centauri/internal/app/tx"
+	"sync"
+)
+
+// Keeps the row count changes of inserts and deletes in memory, so that
+// writers don't have to lock the table catalog record of the table they
+// change. The count stored in tblcat plus the committed changes is the
+// row count; the changes are folded into tblcat by SaveRowCounts.
+type rowCounts struct {
+	mu        sync.Mutex
+	committed map[string]int                   // changes of committed transactions not yet in tblcat
+	running   map[*tx.Transaction]*txRowCounts // changes of running transactions
+	ignored   bool                             // whether the counts are reported as untracked, see IgnoreRowCounts
+}
+
+// The row count changes of a running transaction
+type txRowCounts struct {
+	deltas map[string]int
+	reset  map[string]bool // tables whose stored count the transaction overwrote
+}
+
+func newRowCounts() *rowCounts {
+	return &rowCounts{
+		committed: make(map[string]int),
+		running:   make(map[*tx.Transaction]*txRowCounts),
+	}
+}
+
+// Returns the changes of the transaction, registering it on first use.
+// Must be called with rc.mu held.
+func (rc *rowCounts) changes(tx *tx.Transaction) *txRowCounts {
+	changes := rc.running[tx]
+	if changes == nil {
+		changes = &txRowCounts{deltas: make(map[string]int), reset: make(map[string]bool)}
+		rc.running[tx] = changes
+		tx.OnCommit(func() { rc.commit(tx) })
+		tx.OnFinish(func() { rc.forget(tx) })
+	}
+	return changes
+}
+
+// Records that the transaction inserted or deleted delta records
+func (rc *rowCounts) adjust(tablename string, delta int, tx *tx.Transaction) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.changes(tx).deltas[tablename] += delta
+}
+
+// Records that the transaction overwrote the stored count of the table,
+// so that the changes made before it no longer apply
+func (rc *rowCounts) reset(tablename string, tx *tx.Transaction) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	changes := rc.changes(tx)
+	changes.reset[tablename] = true
+	delete(changes.deltas, tablename)
+}
+
+// Returns the change to add to the stored count of the table, as seen by the transaction
+func (rc *rowCounts) delta(tablename string, tx *tx.Transaction) int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	changes := rc.running[tx]
+	if changes == nil {
+		return rc.committed[tablename]
+	}
+	if changes.reset[tablename] {
+		return changes.deltas[tablename]
+	}
+	return rc.committed[tablename] + changes.deltas[tablename]
+}
+
+func (rc *rowCounts) commit(tx *tx.Transaction) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	changes := rc.running[tx]
+	for tablename := range changes.reset {
+		delete(rc.committed, tablename)
+	}
+	for tablename, delta := range changes.deltas {
+		rc.committed[tablename] += delta
+	}
+	delete(rc.running, tx)
+}
+
+func (rc *rowCounts) forget(tx *tx.Transaction) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	delete(rc.running, tx)
+}
+
+// Writes the committed row count changes into the table catalog.
+// Called with no other transaction running, before a checkpoint.
+func (tm *TableManager) SaveRowCounts(tx *tx.Transaction) {
+	rc := tm.rows
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.committed) == 0 {
+		return
+	}
+
+	tcat := record.NewTableScan(tx, "tblcat", tm.tcatLayout)
+	defer tcat.Close()
+	for tcat.Next() {
+		tablename := tcat.GetString("tblname")
+		delta, ok := rc.committed[tablename]
+		if !ok || delta == 0 {
+			continue
+		}
+		if count := tcat.GetInt("numrecs"); count != UNTRACKED_ROWS {
+			tcat.SetInt("numrecs", max(count+delta, 0))
+		}
+	}
+
+	// Changes of tables dropped since are discarded too
+	clear(rc.committed)
+}
+
+// Recounts the records of every table whose rows are counted, for when the
+// row count changes made since the last checkpoint were lost in a crash
+func (tm *TableManager) RecountRows(tx *tx.Transaction) {
+	for _, tablename := range tm.TableNames(tx) {
+		if tm.storedRowCount(tablename, tx) == UNTRACKED_ROWS {
+			continue
+		}
+		layout, err := tm.GetLayout(tablename, tx)
+		if err != nil {
+			continue
+		}
+		count := 0
+		for _, table := range record.StorageTables(tablename, layout) {
+			count += countRecords(table, layout, tx)
+		}
+		tm.setRowCount(tablename, count, tx)
+	}
+}
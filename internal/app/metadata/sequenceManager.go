@@ -0,0 +1,106 @@
+package metadata
+
+import (
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+)
+
+// The first value of a new sequence
+const SEQUENCE_START = 1
+
+// Handles the sequences generating the values of AUTO_INCREMENT fields.
+// Each sequence is a record of the seqcat catalog table holding the next
+// value of its field. The record is updated in the transaction of the insert
+// taking the value, so the change is logged like any other: it survives a
+// crash once the transaction commits and is undone if it rolls back.
+// The transaction holds the lock on the sequence until it ends.
+type SequenceManager struct {
+	scatLayout *record.Layout // layout for the sequence catalog
+}
+
+// Creates a new sequence manager instance
+func NewSequenceManager() *SequenceManager {
+	return &SequenceManager{
+		scatLayout: record.NewLayout(sequenceCatalogSchema()),
+	}
+}
+
+// Returns the schema of the sequence catalog (seqcat), which holds
+// the next value of every AUTO_INCREMENT field
+func sequenceCatalogSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddStringField("tblname", MAX_NAME)
+	sch.AddStringField("fldname", MAX_NAME)
+	sch.AddLongField("nextval")
+	return sch
+}
+
+// Creates the sequence of a field, starting at next
+func (qm *SequenceManager) CreateSequence(tableName string, fieldName string, next int64, tx *tx.Transaction) {
+	scat := record.NewTableScan(tx, "seqcat", qm.scatLayout)
+	defer scat.Close()
+
+	scat.Insert()
+	scat.SetString("tblname", tableName)
+	scat.SetString("fldname", fieldName)
+	scat.SetVal("nextval", types.NewConstantLong(next))
+}
+
+// Returns the next value of the sequence of a field and advances it.
+// Returns false if the field has no sequence.
+func (qm *SequenceManager) NextValue(tableName string, fieldName string, tx *tx.Transaction) (int64, bool) {
+	scat := record.NewTableScan(tx, "seqcat", qm.scatLayout)
+	defer scat.Close()
+
+	if !qm.find(scat, tableName, fieldName) {
+		return 0, false
+	}
+	next, _ := scat.GetVal("nextval").AsInteger()
+	scat.SetVal("nextval", types.NewConstantLong(next+1))
+	return next, true
+}
+
+// Moves the sequence of a field past a value inserted explicitly,
+// so that the values it generates later don't repeat it
+func (qm *SequenceManager) Observe(tableName string, fieldName string, val int64, tx *tx.Transaction) {
+	scat := record.NewTableScan(tx, "seqcat", qm.scatLayout)
+	defer scat.Close()
+
+	if !qm.find(scat, tableName, fieldName) {
+		return
+	}
+	if next, _ := scat.GetVal("nextval").AsInteger(); val >= next {
+		scat.SetVal("nextval", types.NewConstantLong(val+1))
+	}
+}
+
+// Returns the next value of each sequence of a table, by field name
+func (qm *SequenceManager) Sequences(tableName string, tx *tx.Transaction) map[string]int64 {
+	scat := record.NewTableScan(tx, "seqcat", qm.scatLayout)
+	defer scat.Close()
+
+	sequences := make(map[string]int64)
+	for scat.Next() {
+		if scat.GetString("tblname") == tableName {
+			sequences[scat.GetString("fldname")], _ = scat.GetVal("nextval").AsInteger()
+		}
+	}
+	return sequences
+}
+
+// Removes the sequences of a table
+func (qm *SequenceManager) DropSequences(tableName string, tx *tx.Transaction) {
+	deleteMatching(tx, "seqcat", qm.scatLayout, "tblname", tableName)
+}
+
+// Positions the scan on the sequence of a field
+func (qm *SequenceManager) find(scat *record.TableScan, tableName string, fieldName string) bool {
+	for scat.Next() {
+		if scat.GetString("tblname") == tableName && scat.GetString("fldname") == fieldName {
+			return true
+		}
+	}
+	return false
+}
@@ -5,6 +5,7 @@ package metadata
 type StatInfo struct {
 	numBlocks int
 	numRecs   int
+	distinct  map[string]int // estimated distinct values per field, nil if not sketched
 }
 
 func NewStatInfo(numBlocks int, numRecs int) *StatInfo {
@@ -22,7 +23,12 @@ func (si *StatInfo) RecordsOutput() int {
 	return si.numRecs
 }
 
-// This is wrong
+// Returns the estimated number of distinct values of the field.
+// It comes from a sketch of the field's values when the table has been
+// sketched, and otherwise is guessed to be a third of the records.
 func (si *StatInfo) DistinctValues(fieldname string) int {
+	if n, ok := si.distinct[fieldname]; ok {
+		return max(1, min(n, si.numRecs))
+	}
 	return 1 + (si.numRecs / 3)
 }
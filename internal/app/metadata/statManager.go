@@ -2,51 +2,382 @@ package metadata
 
 import (
 	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"fmt"
+	"maps"
 	"sync"
+	"time"
 )
 
+// Number of GetStatInfo calls after which all statistics are recalculated.
+const STATS_REFRESH_CALLS = 100
+
+// Number of modifications (inserts, deletes, updates) a table can receive
+// before its statistics are considered stale and recalculated.
+const STATS_MODIFICATION_THRESHOLD = 100
+
+// The distinct values of a table's fields are sketched again once the number
+// of modifications it received reaches 1/DISTINCT_REFRESH_FRACTION of its
+// records, and at least the modification threshold.
+const DISTINCT_REFRESH_FRACTION = 10
+
 // Maintains statistics about the tables in the database.
 // It provides thread-safe access to table statistics and automatically
 // refreshes them periodically.
+// The statistics gathered by ANALYZE are also stored in the catalog: the
+// distinct values of the fields of each table in statcat, and the height
+// of each B-tree index in idxstatcat. They are read back when the database
+// is opened, so the distinct values don't have to be estimated again.
 type StatManager struct {
 	tm         *TableManager
 	tableStats map[string]StatInfo
 	numCalls   int
-	mu         sync.Mutex
+	modCounts  map[string]int // Modifications per table since its last refresh
+	threshold  int
+
+	// Estimated distinct values per field of each table, and the number of
+	// modifications the table received since they were estimated. They are
+	// kept across refreshes since estimating them scans the whole table.
+	distinct     map[string]map[string]int
+	distinctMods map[string]int
+
+	heights map[string]int // The height of each analyzed B-tree index
+
+	scatLayout *record.Layout // layout for the statistics catalog
+	icatLayout *record.Layout // layout for the index statistics catalog
+
+	stop chan struct{}
+	done chan struct{}
+	mu   sync.Mutex
 }
 
 func NewStatManager(tm *TableManager, tx *tx.Transaction) *StatManager {
 	sm := &StatManager{
 		tm:         tm,
 		tableStats: make(map[string]StatInfo),
+		modCounts:  make(map[string]int),
+		threshold:  STATS_MODIFICATION_THRESHOLD,
+
+		distinct:     make(map[string]map[string]int),
+		distinctMods: make(map[string]int),
+
+		heights:    make(map[string]int),
+		scatLayout: record.NewLayout(statCatalogSchema()),
+		icatLayout: record.NewLayout(indexStatCatalogSchema()),
 	}
 
+	sm.loadAnalyzed(tx)
 	sm.refreshStatistics(tx) // Initial load of statistics
 	return sm
 }
 
+// Returns the schema of the statistics catalog (statcat), which holds the
+// distinct values of each field of the analyzed tables, along with the
+// number of records the table had when it was analyzed
+func statCatalogSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddStringField("tblname", MAX_NAME)
+	sch.AddStringField("fldname", MAX_NAME)
+	sch.AddIntField("numrecs")
+	sch.AddIntField("distinctvals")
+	return sch
+}
+
+// Returns the schema of the index statistics catalog (idxstatcat),
+// which holds the height of each analyzed B-tree index
+func indexStatCatalogSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddStringField("indexname", MAX_NAME)
+	sch.AddStringField("tblname", MAX_NAME)
+	sch.AddIntField("height")
+	return sch
+}
+
+// Reads the statistics stored by ANALYZE. The records a table gained or
+// lost since it was analyzed count as modifications, so that the distinct
+// values of a table that changed a lot are estimated again.
+func (sm *StatManager) loadAnalyzed(tx *tx.Transaction) {
+	scat := record.NewTableScan(tx, "statcat", sm.scatLayout)
+	for scat.Next() {
+		tableName := scat.GetString("tblname")
+		if sm.distinct[tableName] == nil {
+			sm.distinct[tableName] = make(map[string]int)
+			if rows := sm.tm.RowCount(tableName, tx); rows != UNTRACKED_ROWS {
+				sm.distinctMods[tableName] = abs(rows - scat.GetInt("numrecs"))
+			}
+		}
+		sm.distinct[tableName][scat.GetString("fldname")] = scat.GetInt("distinctvals")
+	}
+	scat.Close()
+
+	icat := record.NewTableScan(tx, "idxstatcat", sm.icatLayout)
+	for icat.Next() {
+		sm.heights[icat.GetString("indexname")] = icat.GetInt("height")
+	}
+	icat.Close()
+}
+
+// Scans the table to count its records and blocks, and to estimate the
+// distinct values of its fields, and stores the distinct values in the
+// catalog. A row count in the catalog that drifted from the records of
+// the table is corrected.
+// The B-tree indexes of the table are measured by the metadata manager,
+// see SetIndexHeights.
+func (sm *StatManager) Analyze(tablename string, layout *record.Layout, tx *tx.Transaction) StatInfo {
+	numBlocks, numRecs, distinct := scanTable(tablename, layout, tx)
+
+	if rows := sm.tm.RowCount(tablename, tx); rows != UNTRACKED_ROWS && rows != numRecs {
+		sm.tm.setRowCount(tablename, numRecs, tx)
+	}
+
+	deleteMatching(tx, "statcat", sm.scatLayout, "tblname", tablename)
+	scat := record.NewTableScan(tx, "statcat", sm.scatLayout)
+	for _, fieldName := range layout.Schema().Fields() {
+		scat.Insert()
+		scat.SetString("tblname", tablename)
+		scat.SetString("fldname", fieldName)
+		scat.SetInt("numrecs", numRecs)
+		scat.SetInt("distinctvals", distinct[fieldName])
+	}
+	scat.Close()
+
+	si := *NewStatInfo(numBlocks, numRecs)
+	si.distinct = distinct
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.tableStats[tablename] = si
+	sm.distinct[tablename] = distinct
+	delete(sm.modCounts, tablename)
+	delete(sm.distinctMods, tablename)
+	return si
+}
+
+// Stores the heights of the B-tree indexes of the table, keyed by index
+// name, replacing those stored before
+func (sm *StatManager) SetIndexHeights(tablename string, heights map[string]int, tx *tx.Transaction) {
+	deleteMatching(tx, "idxstatcat", sm.icatLayout, "tblname", tablename)
+	icat := record.NewTableScan(tx, "idxstatcat", sm.icatLayout)
+	for idxName, height := range heights {
+		icat.Insert()
+		icat.SetString("indexname", idxName)
+		icat.SetString("tblname", tablename)
+		icat.SetInt("height", height)
+	}
+	icat.Close()
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for idxName, height := range heights {
+		sm.heights[idxName] = height
+	}
+}
+
+// Returns the height of a B-tree index found by ANALYZE, 0 if it wasn't analyzed
+func (sm *StatManager) IndexHeight(idxName string) int {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.heights[idxName]
+}
+
+// Removes the stored statistics of an index that is dropped
+func (sm *StatManager) dropIndexStats(idxName string, tx *tx.Transaction) {
+	deleteMatching(tx, "idxstatcat", sm.icatLayout, "indexname", idxName)
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.heights, idxName)
+}
+
+// Removes the stored statistics of a table that is dropped
+func (sm *StatManager) dropTableStats(tablename string, tx *tx.Transaction) {
+	deleteMatching(tx, "statcat", sm.scatLayout, "tblname", tablename)
+	deleteMatching(tx, "idxstatcat", sm.icatLayout, "tblname", tablename)
+}
+
 // Returns statistics for the specified table.
-// If the statistics are not in cache or are stale, they are recalculated
+// If the statistics are not in cache or are stale, they are recalculated,
+// and the distinct values of its fields are estimated if they weren't yet
+// or the table changed too much since. The blocks and records of a table
+// whose rows are counted are always current, however few changes it had.
 func (sm *StatManager) GetStatInfo(tablename string, layout *record.Layout, tx *tx.Transaction) StatInfo {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
 	// Check if statistics need refresh
 	sm.numCalls++
-	if sm.numCalls > 100 {
+	if sm.numCalls > STATS_REFRESH_CALLS {
 		sm.refreshStatistics(tx)
 	}
 
+	// Drop the cached entry if the table has been modified too often since
+	// its statistics were last calculated
+	if sm.modCounts[tablename] >= sm.threshold {
+		delete(sm.tableStats, tablename)
+	}
+
 	// Get or calculate statistics
 	si, exists := sm.tableStats[tablename]
 	if !exists {
 		si = sm.calcTableStats(tablename, layout, tx)
 		sm.tableStats[tablename] = si
+		delete(sm.modCounts, tablename)
+	}
+	if numRecs := sm.tm.RowCount(tablename, tx); numRecs != UNTRACKED_ROWS {
+		if numBlocks, err := storedBlocks(tablename, layout, tx); err == nil {
+			si.numBlocks, si.numRecs = numBlocks, numRecs
+		}
+	}
+
+	if sm.distinctStale(tablename, si.numRecs) {
+		sm.distinct[tablename] = calcDistinctValues(tablename, layout, tx)
+		delete(sm.distinctMods, tablename)
+		si.distinct = sm.distinct[tablename]
+		sm.tableStats[tablename] = si
 	}
 	return si
 }
 
+// Reports whether the distinct values of the table's fields must be estimated
+func (sm *StatManager) distinctStale(tablename string, numRecs int) bool {
+	if _, ok := sm.distinct[tablename]; !ok {
+		return true
+	}
+	return sm.distinctMods[tablename] >= max(sm.threshold, numRecs/DISTINCT_REFRESH_FRACTION)
+}
+
+// Records that count records of the specified table were inserted, deleted or modified.
+// Once the number of modifications reaches the threshold, the table's statistics
+// are recalculated on the next call to GetStatInfo.
+func (sm *StatManager) RecordModification(tablename string, count int) {
+	if count <= 0 {
+		return
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.modCounts[tablename] += count
+	sm.distinctMods[tablename] += count
+}
+
+// Discards the cached statistics and modification count of a table, e.g. after it is dropped.
+func (sm *StatManager) Forget(tablename string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	delete(sm.tableStats, tablename)
+	delete(sm.modCounts, tablename)
+	delete(sm.distinct, tablename)
+	delete(sm.distinctMods, tablename)
+}
+
+// Sets the number of modifications after which a table's statistics are recalculated.
+func (sm *StatManager) SetModificationThreshold(threshold int) {
+	if threshold < 1 {
+		threshold = 1
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.threshold = threshold
+}
+
+// Starts a goroutine that recalculates the statistics of every modified table
+// once per interval. Each refresh runs in its own transaction obtained from newTx,
+// which is committed when the refresh completes. Calling it while a background
+// refresh is already running has no effect.
+func (sm *StatManager) StartBackgroundRefresh(interval time.Duration, newTx func() *tx.Transaction) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.stop != nil {
+		return
+	}
+
+	sm.stop = make(chan struct{})
+	sm.done = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := sm.refreshModifiedTables(newTx); err != nil {
+					fmt.Printf("statistics refresh failed: %v\n", err)
+				}
+			}
+		}
+	}(sm.stop, sm.done)
+}
+
+// Stops the background refresh goroutine and waits for it to exit.
+func (sm *StatManager) StopBackgroundRefresh() {
+	sm.mu.Lock()
+	stop, done := sm.stop, sm.done
+	sm.stop, sm.done = nil, nil
+	sm.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Recalculates statistics for tables that have been modified since their
+// last refresh. The tables are read without holding sm.mu, so that planning
+// doesn't wait for the scans, and the new statistics are stored afterwards.
+func (sm *StatManager) refreshModifiedTables(newTx func() *tx.Transaction) (err error) {
+	sm.mu.Lock()
+	modified := maps.Clone(sm.modCounts)
+	sm.mu.Unlock()
+	if len(modified) == 0 {
+		return nil
+	}
+
+	tx := newTx()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	stats := make(map[string]StatInfo)
+	var dropped []string
+	for tableName := range modified {
+		layout, err := sm.tm.GetLayout(tableName, tx)
+		if err != nil {
+			// The table was dropped since it was modified
+			dropped = append(dropped, tableName)
+			continue
+		}
+		stats[tableName] = sm.countTable(tableName, layout, tx)
+	}
+	tx.Commit()
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	for tableName, si := range stats {
+		si.distinct = sm.distinct[tableName]
+		sm.tableStats[tableName] = si
+
+		// Modifications made during the scans count towards the next refresh
+		if sm.modCounts[tableName] -= modified[tableName]; sm.modCounts[tableName] <= 0 {
+			delete(sm.modCounts, tableName)
+		}
+	}
+	for _, tableName := range dropped {
+		delete(sm.modCounts, tableName)
+		delete(sm.tableStats, tableName)
+		delete(sm.distinct, tableName)
+		delete(sm.distinctMods, tableName)
+	}
+	return nil
+}
+
 // Recalculates statistics for all tables in the database.
 // This is called periodically to ensure statistics remain current.
 func (sm *StatManager) RefreshStatistics(tx *tx.Transaction) {
@@ -59,41 +390,122 @@ func (sm *StatManager) RefreshStatistics(tx *tx.Transaction) {
 func (sm *StatManager) refreshStatistics(tx *tx.Transaction) {
 	// Reset statistics
 	sm.tableStats = make(map[string]StatInfo)
+	sm.modCounts = make(map[string]int)
 	sm.numCalls = 0
 
-	// Get table catalog layout
-	tcatLayout := sm.tm.GetLayout("tblcat", tx)
-
 	// Scan all tables in the catalog
-	ts := record.NewTableScan(tx, "tblcat", tcatLayout)
+	ts := record.NewTableScan(tx, "tblcat", sm.tm.tcatLayout)
 	defer ts.Close()
 
 	for ts.Next() {
 		tableName := ts.GetString("tblname")
-		layout := sm.tm.GetLayout(tableName, tx)
+		layout, err := sm.tm.GetLayout(tableName, tx)
+		if err != nil {
+			continue
+		}
 		stats := sm.calcTableStats(tableName, layout, tx)
 		sm.tableStats[tableName] = stats
 	}
 
 }
 
-// Calculates statistics for a single table
+// Calculates statistics for a single table, with the distinct values last
+// estimated for its fields.
+// Tables with a maintained row count in the catalog are not scanned; their
+// block count is taken from the size of the table file instead.
 func (sm *StatManager) calcTableStats(tablename string, layout *record.Layout, tx *tx.Transaction) StatInfo {
+	si := sm.countTable(tablename, layout, tx)
+	si.distinct = sm.distinct[tablename]
+	return si
+}
+
+// Returns the number of blocks and records of the table
+func (sm *StatManager) countTable(tablename string, layout *record.Layout, tx *tx.Transaction) StatInfo {
+	if numRecs := sm.tm.RowCount(tablename, tx); numRecs != UNTRACKED_ROWS {
+		numBlocks, err := storedBlocks(tablename, layout, tx)
+		if err == nil {
+			return *NewStatInfo(numBlocks, numRecs)
+		}
+	}
+
 	numRecs := 0
 	numBlocks := 0
 
-	// Scan the entire table
-	ts := record.NewTableScan(tx, tablename, layout)
-	defer ts.Close()
-
-	for ts.Next() {
-		numRecs++
-		rid := ts.GetRID()
+	// Scan the entire table, partition by partition
+	for _, table := range record.StorageTables(tablename, layout) {
+		ts := record.NewTableScan(tx, table, layout)
+		blocks := 0
+		for ts.Next() {
+			numRecs++
+			rid, _ := ts.GetRID()
 
-		if rid.BlockNumber()+1 > numBlocks {
-			numBlocks = rid.BlockNumber() + 1
+			if rid.BlockNumber()+1 > blocks {
+				blocks = rid.BlockNumber() + 1
+			}
 		}
+		ts.Close()
+		numBlocks += blocks
 	}
 
 	return *NewStatInfo(numBlocks, numRecs)
 }
+
+// Estimates the number of distinct values of each field of the table in a
+// single scan, adding the values of each field to a HyperLogLog sketch
+func calcDistinctValues(tablename string, layout *record.Layout, tx *tx.Transaction) map[string]int {
+	_, _, distinct := scanTable(tablename, layout, tx)
+	return distinct
+}
+
+// Scans the table, partition by partition, and returns its number of blocks
+// and records, and the estimated distinct values of each of its fields
+func scanTable(tablename string, layout *record.Layout, tx *tx.Transaction) (int, int, map[string]int) {
+	fields := layout.Schema().Fields()
+	sketches := make([]*types.HyperLogLog, len(fields))
+	for i := range sketches {
+		sketches[i] = types.NewHyperLogLog()
+	}
+
+	numBlocks := 0
+	numRecs := 0
+	for _, table := range record.StorageTables(tablename, layout) {
+		ts := record.NewTableScan(tx, table, layout)
+		blocks := 0
+		for ts.Next() {
+			numRecs++
+			rid, _ := ts.GetRID()
+			blocks = max(blocks, rid.BlockNumber()+1)
+			for i, fieldName := range fields {
+				sketches[i].Add(ts.GetVal(fieldName))
+			}
+		}
+		ts.Close()
+		numBlocks += blocks
+	}
+
+	distinct := make(map[string]int, len(fields))
+	for i, fieldName := range fields {
+		distinct[fieldName] = sketches[i].Estimate()
+	}
+	return numBlocks, numRecs, distinct
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Returns the number of blocks in the files of the table, summed over its partitions
+func storedBlocks(tablename string, layout *record.Layout, tx *tx.Transaction) (int, error) {
+	total := 0
+	for _, table := range record.StorageTables(tablename, layout) {
+		blocks, err := tx.Size(record.TableFile(table, layout))
+		if err != nil {
+			return 0, err
+		}
+		total += blocks
+	}
+	return total, nil
+}
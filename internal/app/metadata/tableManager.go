@@ -1,20 +1,59 @@
 package metadata
 
 import (
+	"centauri/internal/app/parse"
 	"centauri/internal/app/record"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"fmt"
 )
 
 // The maximum length for string fields in the catalog table
 const MAX_NAME = 16
 
+// The maximum length of the expression of a generated field
+const MAX_GENERATED_EXPR = 100
+
+// The maximum length of the default value of a field, as written in SQL
+const MAX_DEFAULT = 50
+
+// Row count stored for tables whose records are not counted incrementally
+const UNTRACKED_ROWS = -1
+
+// The file of the table catalog, which every database has
+const TABLE_CATALOG_FILE = "tblcat.tbl"
+
+// The catalog tables are written directly by the metadata managers rather than
+// through the update planners, so their row counts are not maintained.
+var catalogTables = map[string]bool{
+	"tblcat":  true,
+	"fldcat":  true,
+	"viewcat": true,
+	"viewdep": true,
+	"idxcat":  true,
+	"usercat": true,
+	"privcat": true,
+	"collcat": true,
+	"gencat":  true,
+	"partcat": true,
+	"seqcat":  true,
+
+	"statcat":    true,
+	"idxstatcat": true,
+}
+
 // Manages the metadata for database tables
 // It maintains the structure of catalog tables and provides methods
 // for creating and accessing table information
 type TableManager struct {
 	tcatLayout *record.Layout // layout for table catalog
 	fcatLayout *record.Layout // layout for field catalog
+	ccatLayout *record.Layout // layout for collation catalog
+	gcatLayout *record.Layout // layout for generated field catalog
+	pcatLayout *record.Layout // layout for partition catalog
+	qm         *SequenceManager
+	rows       *rowCounts // row count changes not yet in the table catalog
 }
 
 // Initializes a new TableManager
@@ -24,42 +63,139 @@ func NewTableManager(isNew bool, tx *tx.Transaction) *TableManager {
 	tcatSchema := schema.NewSchema()
 	tcatSchema.AddStringField("tblname", MAX_NAME) // table name
 	tcatSchema.AddIntField("slotsize")             // size of each record slot in the table
+	tcatSchema.AddIntField("numrecs")              // number of records currently in the table
+	tcatSchema.AddIntField("fillfactor")           // percentage of each block filled by inserts
+	tcatSchema.AddIntField("blocksize")            // block size of the table file, 0 for the database default
+	tcatSchema.AddIntField("compression")          // 1 if records are compressed, 0 otherwise
+	tcatSchema.AddIntField("format")               // record format, record.FORMAT_FIXED or record.FORMAT_VARIABLE
 	tcatLayout := record.NewLayout(tcatSchema)     // create layout from schema
 
 	// Define schema for the field catalog (fldcat)
 	// This catalog stores information about all the fields in all tables
-	fcatSchema := schema.NewSchema()
-	fcatSchema.AddStringField("tblname", MAX_NAME) // table name, this field belongs to
-	fcatSchema.AddStringField("fldname", MAX_NAME) // field name
-	fcatSchema.AddIntField("type")
-	fcatSchema.AddIntField("length")
-	fcatSchema.AddIntField("offset")
+	fcatSchema := fieldCatalogSchema()
 	fcatLayout := record.NewLayout(fcatSchema)
 
 	// If this is a new database, create the sytem catalog tables
 	tm := &TableManager{
 		tcatLayout: tcatLayout,
 		fcatLayout: fcatLayout,
+		ccatLayout: record.NewLayout(collationCatalogSchema()),
+		gcatLayout: record.NewLayout(generatedCatalogSchema()),
+		pcatLayout: record.NewLayout(partitionCatalogSchema()),
+		rows:       newRowCounts(),
+		qm:         NewSequenceManager(),
 	}
 	if isNew {
 		tm.CreateTable("tblcat", tcatSchema, tx)
 		tm.CreateTable("fldcat", fcatSchema, tx)
+		tm.CreateTable("collcat", collationCatalogSchema(), tx)
+		tm.CreateTable("gencat", generatedCatalogSchema(), tx)
+		tm.CreateTable("partcat", partitionCatalogSchema(), tx)
+		tm.CreateTable("seqcat", sequenceCatalogSchema(), tx)
+		tm.CreateTable("statcat", statCatalogSchema(), tx)
+		tm.CreateTable("idxstatcat", indexStatCatalogSchema(), tx)
 	}
 
 	return tm
 }
 
+// Returns the schema of the field catalog (fldcat), which holds
+// the type, place and options of every field of every table
+func fieldCatalogSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddStringField("tblname", MAX_NAME) // table name, this field belongs to
+	sch.AddStringField("fldname", MAX_NAME) // field name
+	sch.AddIntField("type")
+	sch.AddIntField("length")
+	sch.AddIntField("offset")
+	sch.AddIntField("notnull")                    // 1 if the field was declared NOT NULL
+	sch.AddStringField("defaultval", MAX_DEFAULT) // default value as written in SQL, empty for none
+	return sch
+}
+
+// Returns the schema of the collation catalog (collcat), which holds the
+// collation of every string field that doesn't compare its bytes
+func collationCatalogSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddStringField("tblname", MAX_NAME)
+	sch.AddStringField("fldname", MAX_NAME)
+	sch.AddStringField("collation", MAX_NAME)
+	return sch
+}
+
+// Returns the schema of the generated field catalog (gencat), which holds
+// the expression of every generated field and whether it is stored
+func generatedCatalogSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddStringField("tblname", MAX_NAME)
+	sch.AddStringField("fldname", MAX_NAME)
+	sch.AddStringField("expr", MAX_GENERATED_EXPR)
+	sch.AddIntField("stored") // 1 if the values are stored, 0 if computed when read
+	return sch
+}
+
+// Returns the schema of the partition catalog (partcat), which holds
+// a record for each partition of every partitioned table
+func partitionCatalogSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddStringField("tblname", MAX_NAME)
+	sch.AddStringField("fldname", MAX_NAME) // the partition key
+	sch.AddIntField("method")               // record.PARTITION_RANGE or record.PARTITION_HASH
+	sch.AddIntField("seq")                  // position of the partition, from 0
+	sch.AddStringField("partname", MAX_NAME)
+	sch.AddIntField("bound")    // upper bound of a range partition, number of a hash partition
+	sch.AddIntField("maxvalue") // 1 if a range partition has no upper bound
+	return sch
+}
+
 // Creates a new table in the database and registers it in the catalogs
 func (tm *TableManager) CreateTable(tablename string, schema *schema.Schema, tx *tx.Transaction) {
-	// Create a layout for the new table based on its schema
-	layout := record.NewLayout(schema)
+	tm.CreateTableWithOptions(tablename, schema, record.DefaultStorageOptions(), tx)
+}
+
+// Creates a new table with the specified storage options and registers it in the catalogs
+func (tm *TableManager) CreateTableWithOptions(tablename string, schema *schema.Schema, options record.StorageOptions, tx *tx.Transaction) {
+	// Create a layout for the new table based on its schema.
+	// The catalog tables are read with fixed layouts by the metadata managers.
+	// Virtual fields take no space in the records, so they have no offset.
+	if catalogTables[tablename] {
+		options.Format = record.FORMAT_FIXED
+	}
+	layout := record.NewLayout(storedSchema(schema))
+	if options.Format == record.FORMAT_VARIABLE {
+		layout = record.NewVariableLayout(storedSchema(schema))
+	}
+
+	// A table whose block size is the database's is stored in a file
+	// named after the table alone, see record.TableFile
+	if options.BlockSize == tx.BlockSize() {
+		options.BlockSize = 0
+	}
 
 	// Add an entry for this table in the table catalog
 	tcat := record.NewTableScan(tx, "tblcat", tm.tcatLayout)
 	tcat.Insert()                              // Create a new record
 	tcat.SetString("tblname", tablename)       // Set the table name
 	tcat.SetInt("slotsize", layout.SlotSize()) // Set the slot size
-	tcat.Close()                               // Close the table scan
+
+	// Persist the storage options
+	tcat.SetInt("fillfactor", options.FillFactor)
+	tcat.SetInt("blocksize", options.BlockSize)
+	if options.Compression {
+		tcat.SetInt("compression", 1)
+	} else {
+		tcat.SetInt("compression", 0)
+	}
+	tcat.SetInt("format", options.Format)
+
+	// New tables start out empty; catalog tables aren't tracked
+	if catalogTables[tablename] {
+		tcat.SetInt("numrecs", UNTRACKED_ROWS)
+	} else {
+		tcat.SetInt("numrecs", 0)
+		tm.rows.reset(tablename, tx)
+	}
+	tcat.Close() // Close the table scan
 
 	// Add entries for each field in the field catalog
 	fcat := record.NewTableScan(tx, "fldcat", tm.fcatLayout)
@@ -74,9 +210,126 @@ func (tm *TableManager) CreateTable(tablename string, schema *schema.Schema, tx
 		fcat.SetInt("type", int(schema.DataType(fieldname))) // Data type
 		fcat.SetInt("length", schema.Length(fieldname))      // Field length
 		fcat.SetInt("offset", layout.Offset(fieldname))      // Field offset in record
+
+		// Fields without options leave them empty, as the fields of the
+		// catalog tables do while the catalog is migrated from a layout
+		// of fldcat without room for them
+		if schema.NotNull(fieldname) {
+			fcat.SetInt("notnull", 1)
+		}
+		if value := schema.Default(fieldname); value != "" {
+			fcat.SetString("defaultval", value)
+		}
 	}
 
 	fcat.Close()
+
+	// Add entries for the fields compared by a collation
+	for _, fieldname := range schema.Fields() {
+		collation := schema.Collation(fieldname)
+		if collation == "" || collation == types.COLLATION_BINARY {
+			continue
+		}
+
+		ccat := record.NewTableScan(tx, "collcat", tm.ccatLayout)
+		ccat.Insert()
+		ccat.SetString("tblname", tablename)
+		ccat.SetString("fldname", fieldname)
+		ccat.SetString("collation", collation)
+		ccat.Close()
+	}
+
+	// Add entries for the generated fields
+	for _, fieldname := range schema.Fields() {
+		expr := schema.Generated(fieldname)
+		if expr == "" {
+			continue
+		}
+
+		gcat := record.NewTableScan(tx, "gencat", tm.gcatLayout)
+		gcat.Insert()
+		gcat.SetString("tblname", tablename)
+		gcat.SetString("fldname", fieldname)
+		gcat.SetString("expr", expr)
+		if schema.IsVirtual(fieldname) {
+			gcat.SetInt("stored", 0)
+		} else {
+			gcat.SetInt("stored", 1)
+		}
+		gcat.Close()
+	}
+
+	// Add entries for the partitions
+	if p := options.Partitioning; p != nil {
+		pcat := record.NewTableScan(tx, "partcat", tm.pcatLayout)
+		for i, part := range p.Partitions {
+			pcat.Insert()
+			pcat.SetString("tblname", tablename)
+			pcat.SetString("fldname", p.Field)
+			pcat.SetInt("method", p.Method)
+			pcat.SetInt("seq", i)
+			pcat.SetString("partname", part.Name)
+			pcat.SetInt("bound", part.Bound)
+			if part.MaxValue {
+				pcat.SetInt("maxvalue", 1)
+			} else {
+				pcat.SetInt("maxvalue", 0)
+			}
+		}
+		pcat.Close()
+	}
+
+	// Start the sequences of the AUTO_INCREMENT fields
+	for _, fieldname := range schema.Fields() {
+		if schema.AutoIncrement(fieldname) {
+			tm.qm.CreateSequence(tablename, fieldname, SEQUENCE_START, tx)
+		}
+	}
+}
+
+// Reads the partitions of the table from the partition catalog.
+// Returns nil if the table isn't partitioned.
+func (tm *TableManager) readPartitioning(tablename string, tx *tx.Transaction) *record.Partitioning {
+	pcat := record.NewTableScan(tx, "partcat", tm.pcatLayout)
+	defer pcat.Close()
+
+	var p *record.Partitioning
+	parts := make(map[int]record.Partition)
+	for pcat.Next() {
+		if pcat.GetString("tblname") != tablename {
+			continue
+		}
+		if p == nil {
+			p = &record.Partitioning{Method: pcat.GetInt("method"), Field: pcat.GetString("fldname")}
+		}
+		parts[pcat.GetInt("seq")] = record.Partition{
+			Name:     pcat.GetString("partname"),
+			Bound:    pcat.GetInt("bound"),
+			MaxValue: pcat.GetInt("maxvalue") == 1,
+		}
+	}
+	if p == nil {
+		return nil
+	}
+
+	// Deleted catalog slots are reused, so the records may be out of order
+	p.Partitions = make([]record.Partition, len(parts))
+	for seq, part := range parts {
+		p.Partitions[seq] = part
+	}
+	return p
+}
+
+// Returns the fields of the schema that are stored in the records,
+// leaving out the virtual fields
+func storedSchema(sch *schema.Schema) *schema.Schema {
+	stored := schema.NewSchema()
+	for _, fieldname := range sch.Fields() {
+		if !sch.IsVirtual(fieldname) {
+			stored.Add(fieldname, sch)
+		}
+	}
+	return stored
 }
 
 // Retrieves the layout information for a specified table from the catalog
@@ -84,8 +337,11 @@ func (tm *TableManager) CreateTable(tablename string, schema *schema.Schema, tx
 // Parameters:
 //   - tablename: name of the table whose layout is being retrieved
 //   - tx       : the transaction comtext for database operations
-func (tm *TableManager) GetLayout(tablename string, tx *tx.Transaction) *record.Layout {
+//
+// Returns ErrTableNotFound if the table is not in the table catalog.
+func (tm *TableManager) GetLayout(tablename string, tx *tx.Transaction) (*record.Layout, error) {
 	size := -1 // Initialize the slot size to  -1, will be updated if table is found
+	options := record.DefaultStorageOptions()
 
 	// Open a table scan on the table catalog ("tblcat")
 	// This catalog contains metadata about all the tables in the database
@@ -97,20 +353,32 @@ func (tm *TableManager) GetLayout(tablename string, tx *tx.Transaction) *record.
 		if tcat.GetString("tblname") == tablename {
 			// Extract the slot size for this table and break
 			size = tcat.GetInt("slotsize")
+			options.FillFactor = tcat.GetInt("fillfactor")
+			if blockSize := tcat.GetInt("blocksize"); blockSize != tx.BlockSize() {
+				options.BlockSize = blockSize
+			}
+			options.Compression = tcat.GetInt("compression") == 1
+			options.Format = tcat.GetInt("format")
 			break
 		}
 	}
 	// Close when done
 	tcat.Close()
 
+	if size == -1 {
+		return nil, fmt.Errorf("%w: %s", ErrTableNotFound, tablename)
+	}
+
 	// Create a new schema object to hold field definitions
 	schema := schema.NewSchema()
 	// Create a map to store field offsets
 	offsets := make(map[string]int)
 
 	// Open a table scan on the field catalog
-	// This catalog contains metadata about all the fields in all tables
+	// This catalog contains metadata about all the fields in all tables.
+	// Until the catalog is migrated, fldcat may have no field options.
 	fcat := record.NewTableScan(tx, "fldcat", tm.fcatLayout)
+	hasOptions := tm.fcatLayout.Schema().HasField("notnull")
 
 	// iterate through all records in the field catalog
 	for fcat.Next() {
@@ -122,16 +390,196 @@ func (tm *TableManager) GetLayout(tablename string, tx *tx.Transaction) *record.
 			fieldLen := fcat.GetInt("length")
 			offset := fcat.GetInt("offset")
 
-			offsets[fieldname] = offset
+			// Virtual fields have no offset
+			if offset >= 0 {
+				offsets[fieldname] = offset
+			}
 
 			// Add the field to our schema with its type and length
 			schema.AddField(fieldname, schema.ToFieldType(fieldType), fieldLen)
+			if hasOptions {
+				schema.SetNotNull(fieldname, fcat.GetInt("notnull") == 1)
+				schema.SetDefault(fieldname, fcat.GetString("defaultval"))
+			}
 		}
 	}
 
 	fcat.Close()
 
+	// Databases whose catalog predates collations have no collation catalog
+	if size, _ := tx.Size("collcat.tbl"); size > 0 {
+		ccat := record.NewTableScan(tx, "collcat", tm.ccatLayout)
+		for ccat.Next() {
+			if ccat.GetString("tblname") == tablename {
+				schema.SetCollation(ccat.GetString("fldname"), ccat.GetString("collation"))
+			}
+		}
+		ccat.Close()
+	}
+
+	// Databases whose catalog predates generated fields have no generated field catalog
+	if size, _ := tx.Size("gencat.tbl"); size > 0 {
+		gcat := record.NewTableScan(tx, "gencat", tm.gcatLayout)
+		for gcat.Next() {
+			if gcat.GetString("tblname") == tablename {
+				schema.SetGenerated(gcat.GetString("fldname"), gcat.GetString("expr"), gcat.GetInt("stored") == 1)
+			}
+		}
+		gcat.Close()
+	}
+
+	// Databases whose catalog predates partitioning have no partition catalog
+	if size, _ := tx.Size("partcat.tbl"); size > 0 {
+		options.Partitioning = tm.readPartitioning(tablename, tx)
+	}
+
+	// Databases whose catalog predates sequences have no sequence catalog
+	if size, _ := tx.Size("seqcat.tbl"); size > 0 {
+		for fieldname := range tm.qm.Sequences(tablename, tx) {
+			schema.SetAutoIncrement(fieldname, true)
+		}
+	}
+
 	// Create and return a new layout object with the collected information
 	// This Layout represents the physical structure of the table
-	return record.NewLayoutWithOffsets(schema, offsets, size)
+	layout := record.NewLayoutWithOffsets(schema, offsets, size)
+	layout.SetOptions(options)
+
+	// The expressions of the generated fields and the default values are parsed
+	// each time the layout is read, as the definitions of views are
+	for _, fieldname := range schema.Fields() {
+		if expr := schema.Generated(fieldname); expr != "" {
+			generator, err := parse.NewParser(expr).ValueExpression()
+			if err != nil {
+				return nil, fmt.Errorf("generated field %s: %w", fieldname, err)
+			}
+			layout.SetGenerator(fieldname, generator)
+		}
+		if value := schema.Default(fieldname); value != "" {
+			val, err := parse.NewParser(value).DefaultValue()
+			if err != nil {
+				return nil, fmt.Errorf("default of field %s: %w", fieldname, err)
+			}
+			layout.SetDefault(fieldname, val)
+		}
+	}
+	return layout, nil
+}
+
+// Returns the number of records in the specified table: the count stored in the
+// table catalog plus the changes not yet written to it.
+// Returns UNTRACKED_ROWS if the table doesn't exist or its rows aren't counted.
+func (tm *TableManager) RowCount(tablename string, tx *tx.Transaction) int {
+	count := tm.storedRowCount(tablename, tx)
+	if count == UNTRACKED_ROWS || tm.rows.ignored {
+		return UNTRACKED_ROWS
+	}
+	// Never let the count go negative, even if it had drifted
+	return max(count+tm.rows.delta(tablename, tx), 0)
+}
+
+// Returns the row count stored in the table catalog
+func (tm *TableManager) storedRowCount(tablename string, tx *tx.Transaction) int {
+	count := UNTRACKED_ROWS
+
+	tcat := record.NewTableScan(tx, "tblcat", tm.tcatLayout)
+	for tcat.Next() {
+		if tcat.GetString("tblname") == tablename {
+			count = tcat.GetInt("numrecs")
+			break
+		}
+	}
+	tcat.Close()
+
+	return count
+}
+
+// Adds delta to the row count of the specified table. The change is kept in
+// memory until SaveRowCounts, so that concurrent writers don't contend for
+// the table catalog. Catalog tables aren't counted.
+func (tm *TableManager) AdjustRowCount(tablename string, delta int, tx *tx.Transaction) {
+	if delta == 0 || catalogTables[tablename] {
+		return
+	}
+	tm.rows.adjust(tablename, delta, tx)
+}
+
+// Overwrites the row count of the specified table in the table catalog,
+// discarding the changes made to it before
+func (tm *TableManager) setRowCount(tablename string, count int, tx *tx.Transaction) {
+	tcat := record.NewTableScan(tx, "tblcat", tm.tcatLayout)
+	defer tcat.Close()
+
+	for tcat.Next() {
+		if tcat.GetString("tblname") == tablename {
+			tcat.SetInt("numrecs", count)
+			tm.rows.reset(tablename, tx)
+			return
+		}
+	}
+}
+
+// Checks whether the table is registered in the table catalog
+func (tm *TableManager) TableExists(tablename string, tx *tx.Transaction) bool {
+	tcat := record.NewTableScan(tx, "tblcat", tm.tcatLayout)
+	defer tcat.Close()
+
+	for tcat.Next() {
+		if tcat.GetString("tblname") == tablename {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the names of the user tables in the order they were created.
+// The catalog tables are not included.
+func (tm *TableManager) TableNames(tx *tx.Transaction) []string {
+	tcat := record.NewTableScan(tx, "tblcat", tm.tcatLayout)
+	defer tcat.Close()
+
+	tables := []string{}
+	for tcat.Next() {
+		tablename := tcat.GetString("tblname")
+		if !catalogTables[tablename] {
+			tables = append(tables, tablename)
+		}
+	}
+	return tables
+}
+
+// Replaces the fields of a table in the catalogs with those of the schema,
+// keeping its storage options, row count and the sequences of the
+// AUTO_INCREMENT fields it still has
+func (tm *TableManager) AlterTable(tablename string, schema *schema.Schema, options record.StorageOptions, tx *tx.Transaction) {
+	count := tm.RowCount(tablename, tx)
+	sequences := tm.qm.Sequences(tablename, tx)
+	tm.DropTable(tablename, tx)
+	tm.CreateTableWithOptions(tablename, schema, options, tx)
+	tm.setRowCount(tablename, count, tx)
+	for fieldname, next := range sequences {
+		tm.qm.Observe(tablename, fieldname, next-1, tx)
+	}
+}
+
+// Removes the table and all of its fields from the catalogs
+func (tm *TableManager) DropTable(tablename string, tx *tx.Transaction) {
+	deleteMatching(tx, "tblcat", tm.tcatLayout, "tblname", tablename)
+	deleteMatching(tx, "fldcat", tm.fcatLayout, "tblname", tablename)
+	deleteMatching(tx, "collcat", tm.ccatLayout, "tblname", tablename)
+	deleteMatching(tx, "gencat", tm.gcatLayout, "tblname", tablename)
+	deleteMatching(tx, "partcat", tm.pcatLayout, "tblname", tablename)
+	tm.qm.DropSequences(tablename, tx)
+}
+
+// Deletes every record of a catalog table whose string field matches the given value
+func deleteMatching(tx *tx.Transaction, tablename string, layout *record.Layout, fieldname string, val string) {
+	ts := record.NewTableScan(tx, tablename, layout)
+	defer ts.Close()
+
+	for ts.Next() {
+		if ts.GetString(fieldname) == val {
+			ts.Delete()
+		}
+	}
 }
@@ -0,0 +1,151 @@
+package metadata
+
+import (
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+)
+
+var (
+	// Returned when creating a user that already exists
+	ErrUserExists = errors.New("user already exists")
+	// Returned when a statement refers to a user that isn't in the catalog
+	ErrUserNotFound = errors.New("user not found")
+	// Returned when a user name and password don't match
+	ErrAuthFailed = errors.New("authentication failed")
+)
+
+// Describes a user of the database
+type UserInfo struct {
+	Name      string
+	Superuser bool // superusers may do anything, including managing other users
+}
+
+// Handles the creation of users and the checking of their passwords.
+// Users are kept in the usercat catalog table along with a salted
+// hash of their password; the password itself is never stored.
+type UserManager struct {
+	ucatLayout *record.Layout // layout for the user catalog
+}
+
+// Creates a new user manager instance
+func NewUserManager(isNew bool, tableMgr *TableManager, tx *tx.Transaction) *UserManager {
+	um := &UserManager{
+		ucatLayout: record.NewLayout(userCatalogSchema()),
+	}
+
+	if isNew {
+		tableMgr.CreateTable("usercat", userCatalogSchema(), tx)
+	}
+	return um
+}
+
+// Returns the schema of the user catalog
+func userCatalogSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddStringField("username", MAX_NAME)
+	sch.AddStringField("salt", 2*SALT_LENGTH) // hex encoded
+	sch.AddStringField("hash", 2*32)          // hex encoded PBKDF2 key
+	sch.AddIntField("iterations")             // PBKDF2 rounds the hash was computed with
+	sch.AddIntField("superuser")              // 1 for superusers, 0 otherwise
+	return sch
+}
+
+// Adds a user with the given password
+func (um *UserManager) CreateUser(userName string, password string, superuser bool, tx *tx.Transaction) error {
+	if len(userName) > MAX_NAME {
+		return fmt.Errorf("user name %s is longer than %d characters", userName, MAX_NAME)
+	}
+	if _, ok := um.GetUser(userName, tx); ok {
+		return fmt.Errorf("%w: %s", ErrUserExists, userName)
+	}
+
+	salt, err := newSalt()
+	if err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	ts := record.NewTableScan(tx, "usercat", um.ucatLayout)
+	defer ts.Close()
+
+	ts.Insert()
+	ts.SetString("username", userName)
+	ts.SetString("salt", salt)
+	ts.SetString("hash", hashPassword(password, salt, PASSWORD_ITERATIONS))
+	ts.SetInt("iterations", PASSWORD_ITERATIONS)
+	ts.SetInt("superuser", boolToInt(superuser))
+	return nil
+}
+
+// Changes the password of a user. A new salt is generated as well.
+func (um *UserManager) AlterPassword(userName string, password string, tx *tx.Transaction) error {
+	salt, err := newSalt()
+	if err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	ts := record.NewTableScan(tx, "usercat", um.ucatLayout)
+	defer ts.Close()
+
+	for ts.Next() {
+		if ts.GetString("username") == userName {
+			ts.SetString("salt", salt)
+			ts.SetString("hash", hashPassword(password, salt, PASSWORD_ITERATIONS))
+			ts.SetInt("iterations", PASSWORD_ITERATIONS)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUserNotFound, userName)
+}
+
+// Checks a user's password. Returns the user, or ErrAuthFailed
+// if the user doesn't exist or the password is wrong.
+func (um *UserManager) Authenticate(userName string, password string, tx *tx.Transaction) (UserInfo, error) {
+	ts := record.NewTableScan(tx, "usercat", um.ucatLayout)
+	defer ts.Close()
+
+	for ts.Next() {
+		if ts.GetString("username") != userName {
+			continue
+		}
+		if !checkPassword(password, ts.GetString("salt"), ts.GetInt("iterations"), ts.GetString("hash")) {
+			break
+		}
+		return UserInfo{Name: userName, Superuser: ts.GetInt("superuser") == 1}, nil
+	}
+	return UserInfo{}, ErrAuthFailed
+}
+
+// Returns the user with the given name
+func (um *UserManager) GetUser(userName string, tx *tx.Transaction) (UserInfo, bool) {
+	ts := record.NewTableScan(tx, "usercat", um.ucatLayout)
+	defer ts.Close()
+
+	for ts.Next() {
+		if ts.GetString("username") == userName {
+			return UserInfo{Name: userName, Superuser: ts.GetInt("superuser") == 1}, true
+		}
+	}
+	return UserInfo{}, false
+}
+
+// Returns the names of all users in the order they were created
+func (um *UserManager) UserNames(tx *tx.Transaction) []string {
+	ts := record.NewTableScan(tx, "usercat", um.ucatLayout)
+	defer ts.Close()
+
+	users := []string{}
+	for ts.Next() {
+		users = append(users, ts.GetString("username"))
+	}
+	return users
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
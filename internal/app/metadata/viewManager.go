@@ -9,32 +9,54 @@ import (
 const MAX_VIEWDEF = 100
 
 // Handles the creation, deletion and management of database views.
-// It maintains view definitions in a special system called viewcat.
+// It maintains view definitions in a special system called viewcat,
+// and the tables each view reads from in viewdep.
 type ViewManager struct {
-	tm *TableManager
+	tm         *TableManager
+	vcatLayout *record.Layout // layout for the view catalog
+	vdepLayout *record.Layout // layout for the view dependency catalog
 }
 
 // Creates a new view manager instance
 func NewViewManager(isNew bool, tableMgr *TableManager, tx *tx.Transaction) *ViewManager {
+	viewSchema := schema.NewSchema()
+	viewSchema.AddStringField("viewname", MAX_NAME)
+	viewSchema.AddStringField("viewdef", MAX_VIEWDEF)
+
+	depSchema := viewDepSchema()
+
 	vm := &ViewManager{
-		tm: tableMgr,
+		tm:         tableMgr,
+		vcatLayout: record.NewLayout(viewSchema),
+		vdepLayout: record.NewLayout(depSchema),
 	}
 
 	if isNew {
-		schema := schema.NewSchema()
-		schema.AddStringField("viewname", MAX_NAME)
-		schema.AddStringField("viewdef", MAX_VIEWDEF)
-		tableMgr.CreateTable("viewcat", schema, tx)
+		tableMgr.CreateTable("viewcat", viewSchema, tx)
+		tableMgr.CreateTable("viewdep", depSchema, tx)
 	}
 	return vm
 }
 
-func (vm *ViewManager) CreateView(viewName string, viewdef string, tx *tx.Transaction) {
-	// Get layout of viewcat table
-	layout := vm.tm.GetLayout("viewcat", tx)
+// Registers a new view along with the tables (or views) its definition reads from
+func (vm *ViewManager) CreateView(viewName string, viewdef string, tables []string, tx *tx.Transaction) {
+	vm.createViewDef(viewName, viewdef, tx)
+
+	// Record the dependencies of the view
+	deps := record.NewTableScan(tx, "viewdep", vm.vdepLayout)
+	defer deps.Close()
+
+	for _, tableName := range tables {
+		deps.Insert()
+		deps.SetString("viewname", viewName)
+		deps.SetString("tablename", tableName)
+	}
+}
 
+// Inserts the view definition into viewcat
+func (vm *ViewManager) createViewDef(viewName string, viewdef string, tx *tx.Transaction) {
 	// Start scanning viewcat table
-	ts := record.NewTableScan(tx, "viewcat", layout)
+	ts := record.NewTableScan(tx, "viewcat", vm.vcatLayout)
 	defer ts.Close() // Ensure table scan is closed after operation
 
 	// Insert the view definition
@@ -45,11 +67,8 @@ func (vm *ViewManager) CreateView(viewName string, viewdef string, tx *tx.Transa
 
 // Retrieves the definitionof a specific view
 func (vm *ViewManager) GetViewDef(viewName string, tx *tx.Transaction) string {
-	// Get layout of viewcat table
-	layout := vm.tm.GetLayout("viewcat", tx)
-
 	// Start scanning viewcat table
-	ts := record.NewTableScan(tx, "viewcat", layout)
+	ts := record.NewTableScan(tx, "viewcat", vm.vcatLayout)
 	defer ts.Close()
 
 	// Search for the view
@@ -61,3 +80,44 @@ func (vm *ViewManager) GetViewDef(viewName string, tx *tx.Transaction) string {
 
 	return ""
 }
+
+// Returns the names of the views whose definition reads from the specified table or view
+func (vm *ViewManager) ViewsOn(tableName string, tx *tx.Transaction) []string {
+	ts := record.NewTableScan(tx, "viewdep", vm.vdepLayout)
+	defer ts.Close()
+
+	views := []string{}
+	for ts.Next() {
+		if ts.GetString("tablename") == tableName {
+			views = append(views, ts.GetString("viewname"))
+		}
+	}
+	return views
+}
+
+// Returns the names of all views in the order they were created
+func (vm *ViewManager) ViewNames(tx *tx.Transaction) []string {
+	ts := record.NewTableScan(tx, "viewcat", vm.vcatLayout)
+	defer ts.Close()
+
+	views := []string{}
+	for ts.Next() {
+		views = append(views, ts.GetString("viewname"))
+	}
+	return views
+}
+
+// Removes the view definition and its dependency records from the catalog
+func (vm *ViewManager) DropView(viewName string, tx *tx.Transaction) {
+	deleteMatching(tx, "viewcat", vm.vcatLayout, "viewname", viewName)
+	deleteMatching(tx, "viewdep", vm.vdepLayout, "viewname", viewName)
+}
+
+// Returns the schema of the view dependency catalog,
+// which holds one record per (view, table) pair the view depends on
+func viewDepSchema() *schema.Schema {
+	depSchema := schema.NewSchema()
+	depSchema.AddStringField("viewname", MAX_NAME)
+	depSchema.AddStringField("tablename", MAX_NAME)
+	return depSchema
+}
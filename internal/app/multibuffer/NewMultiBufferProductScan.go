@@ -4,6 +4,7 @@ import (
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/query"
 	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
 	"centauri/internal/app/types"
 )
@@ -24,7 +25,7 @@ type MultibufferProductScan struct {
 }
 
 func NewMultiBufferProductScan(tx *tx.Transaction, lhsscan interfaces.Scan, tableName string, layout *record.Layout) interfaces.Scan {
-	fileName := tableName + ".tbl"
+	fileName := record.TableFile(tableName, layout)
 	size, _ := tx.Size(fileName)
 	mps := &MultibufferProductScan{
 		tx:       tx,
@@ -87,6 +88,14 @@ func (mps *MultibufferProductScan) HasField(fldname string) bool {
 	return mps.prodscan.HasField(fldname)
 }
 
+// Returns the fields of the LHS scan followed by those of the RHS table
+func (mps *MultibufferProductScan) Schema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddAll(mps.lhsscan.Schema())
+	sch.AddAll(mps.layout.Schema())
+	return sch
+}
+
 // Sets up processing for the next chunk. It creates a new ChunkScan for the next chunk
 // It creates a new ChunkScan for the next chunk of blocks from the RHS table, resets the
 // LHS scan to its beginning, and creates a new ProductScan.
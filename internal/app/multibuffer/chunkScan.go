@@ -76,22 +76,59 @@ func (cs *ChunkScan) Next() bool {
 // GetInt implements the query.Scan GetInt method.
 // Returns the integer value at the specified field for the current record.
 func (cs *ChunkScan) GetInt(fldname string) int {
+	if g := cs.layout.Computed(fldname); g != nil {
+		if val := g.Evaluate(cs); val != nil && val.AsInt() != nil {
+			return *val.AsInt()
+		}
+		return 0
+	}
 	return cs.rp.GetInt(cs.currentSlot, fldname)
 }
 
 // GetString implements the query.Scan GetString method.
 // Returns the string value at the specified field for the current record.
 func (cs *ChunkScan) GetString(fldname string) string {
+	if g := cs.layout.Computed(fldname); g != nil {
+		if val := g.Evaluate(cs); val != nil && val.AsString() != nil {
+			return *val.AsString()
+		}
+		return ""
+	}
+	if cs.layout.Schema().DataType(fldname) == schema.BLOB {
+		return string(cs.rp.GetBlob(cs.currentSlot, fldname))
+	}
+	if fieldType := cs.layout.Schema().DataType(fldname); fieldType == schema.BIGINT || fieldType == schema.FLOAT || fieldType.IsTemporal() || fieldType == schema.BOOLEAN {
+		if val := cs.GetVal(fldname); !val.IsNull() {
+			return val.String()
+		}
+		return ""
+	}
 	return cs.rp.GetString(cs.currentSlot, fldname)
 }
 
 // GetVal implements the query.Scan GetVal method.
 // Returns the value at the specified field as a Constant object.
 func (cs *ChunkScan) GetVal(fldname string) *types.Constant {
-	if cs.layout.Schema().DataType(fldname) == schema.INTEGER {
+	if g := cs.layout.Computed(fldname); g != nil {
+		return g.Evaluate(cs)
+	}
+	if cs.rp.IsNull(cs.currentSlot, fldname) {
+		return types.NewConstantNull()
+	}
+	if fieldType := cs.layout.Schema().DataType(fldname); fieldType == schema.INTEGER {
 		return types.NewConstantInt(cs.GetInt(fldname))
+	} else if fieldType == schema.BIGINT {
+		return types.NewConstantLong(cs.rp.GetLong(cs.currentSlot, fldname))
+	} else if fieldType == schema.FLOAT {
+		return types.NewConstantFloat(cs.rp.GetFloat(cs.currentSlot, fldname))
+	} else if fieldType.IsTemporal() {
+		return record.TimeVal(fieldType, cs.rp.GetLong(cs.currentSlot, fldname))
+	} else if fieldType == schema.BOOLEAN {
+		return types.NewConstantBool(cs.GetInt(fldname) != 0)
+	} else if fieldType == schema.BLOB {
+		return types.NewConstantString(cs.GetString(fldname))
 	}
-	return types.NewConstantString(cs.GetString(fldname))
+	return record.StringVal(cs.layout.Schema(), fldname, cs.GetString(fldname))
 }
 
 // HasField implements the query.Scan HasField method.
@@ -100,6 +137,11 @@ func (cs *ChunkScan) HasField(fldname string) bool {
 	return cs.layout.Schema().HasField(fldname)
 }
 
+// Returns the schema of the table the chunk belongs to
+func (cs *ChunkScan) Schema() *schema.Schema {
+	return cs.layout.Schema()
+}
+
 // Updates the current block number, record page reference, and resets the slot position.
 func (cs *ChunkScan) moveToBlock(blockNum int) {
 	cs.currentbnum = blockNum
@@ -114,3 +114,14 @@ func (p *MultibufferProductPlan) copyRecordsFrom(sourcePlan interfaces.Plan) *ma
 
 	return tempTable
 }
+
+func (p *MultibufferProductPlan) Describe() string {
+	return "multibuffer product"
+}
+
+func (p *MultibufferProductPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{p.lhs, p.rhs}
+}
+
+// The right side is copied to a temporary table before the first record is output
+func (p *MultibufferProductPlan) Blocking() {}
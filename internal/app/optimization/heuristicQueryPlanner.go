@@ -2,6 +2,7 @@ package optimization
 
 import (
 	"centauri/internal/app/interfaces"
+	"centauri/internal/app/materialize"
 	"centauri/internal/app/metadata"
 	"centauri/internal/app/parse"
 	"centauri/internal/app/plan"
@@ -26,21 +27,55 @@ func NewHeuristicQueryPlanner(mdm *metadata.MetaDataManager) *HeuristicQueryPlan
 // It uses the following heuristics:
 //   - H1: Choose the smallest table (considering selection predicates) to be first in join order.
 //   - H2: Add the table to the join order which results in the smallest output
-func (h *HeuristicQueryPlanner) CreatePlan(data *parse.QueryData, tx *tx.Transaction) interfaces.Plan {
+func (h *HeuristicQueryPlanner) CreatePlan(data *parse.QueryData, tx *tx.Transaction) (interfaces.Plan, error) {
 	// Clear any previous table planners from prior queries
 	h.tablePlanners = make([]*TablePlanner, 0)
 
+	// Each query of a UNION is planned on its own
+	if len(data.Unions()) > 0 {
+		return plan.CreateUnionPlan(tx, data, h.CreatePlan)
+	}
+
+	// Indexes hold the current keys, so the past is read by scanning the tables
+	if data.AsOf() != nil {
+		return plan.NewBasicQueryPlanner(h.mdm).CreatePlan(data, tx)
+	}
+
+	// System views have no table or indexes to optimize over
+	for _, tableName := range data.Tables() {
+		if tableName == metadata.RELATION_SIZES_VIEW {
+			return plan.NewBasicQueryPlanner(h.mdm).CreatePlan(data, tx)
+		}
+	}
+
 	// Step 1: Create a TablePlanner object for each table mentioned in the query.
 	// Each TablePlanner helps evaluate different access plans for that specific table.
+	// The ON predicates of explicit joins are part of the query's predicate, so
+	// JOIN ... ON is planned the same as the equivalent comma join.
+	pred := data.Pred()
 	for _, tableName := range data.Tables() {
 		// Create a TablePlanner for this table with the query's predicates
-		tp := NewTablePlanner(tableName, data.Pred(), tx, h.mdm)
+		tp, err := NewTablePlanner(tableName, pred, tx, h.mdm)
+		if err != nil {
+			return nil, err
+		}
 		h.tablePlanners = append(h.tablePlanners, tp)
 	}
 
+	// The limit of a query over a single table without aggregates, grouping
+	// or an order is the number of records its select plan needs to output
+	if len(h.tablePlanners) == 1 && len(data.Aggregates()) == 0 && len(data.GroupBy()) == 0 && len(data.OrderBy()) == 0 && data.Limit() != parse.NO_LIMIT {
+		h.tablePlanners[0].SetLimit(data.Limit())
+	}
+
 	// Step 2: Choose the lowest-size table plan to begin the join order
 	// This implements Heuristic H1 - start with smallest table (after applying selection predicates)
-	currentPlan := h.getLowestSelectPlan()
+	// A single table ordered through an index needs no sort
+	currentPlan := h.getOrderedSelectPlan(data, tx)
+	ordered := currentPlan != nil
+	if !ordered {
+		currentPlan = h.getLowestSelectPlan()
+	}
 
 	// Step 3: Repeatedly add a plan to the join order until all tables are processed
 	// This loop builds the query plan by incrementally adding tables
@@ -58,9 +93,27 @@ func (h *HeuristicQueryPlanner) CreatePlan(data *parse.QueryData, tx *tx.Transac
 		}
 	}
 
-	// Step 4: Apply projection on the desired fields and return the final plan
+	// Step 4: Group the records and compute the aggregates, if any, keeping
+	// the groups satisfying the HAVING clause
+	currentPlan, err := plan.AggregatePlan(tx, currentPlan, data, h.mdm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 5: Compute the fields of the select list given by expressions, if any
+	currentPlan = plan.AddExtend(currentPlan, data)
+
+	// Step 6: Sort the records by the ORDER BY clause, if any
+	if !ordered {
+		currentPlan, err = plan.AddOrderBy(tx, currentPlan, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Step 7: Apply projection on the desired fields and the limit, and return the final plan
 	// This ensures only the requested fields are returned in the query result
-	return plan.NewProjectPlan(currentPlan, data.Fields())
+	return plan.AddLimit(plan.NewProjectPlan(currentPlan, data.Fields()), data), nil
 }
 
 // Finds the TablePlanner with the lowest expected record output after applying selection predicates,
@@ -92,6 +145,41 @@ func (h *HeuristicQueryPlanner) getLowestSelectPlan() interfaces.Plan {
 	return bestPlan
 }
 
+// Creates a select plan reading the records of a single table in the order of
+// the query's ORDER BY clause through a B-tree index, if the clause is a field
+// of the table with such an index and reading the records that way costs
+// less than sorting them. With a limit, only the first records are read.
+// The TablePlanner is then removed. Returns nil otherwise.
+func (h *HeuristicQueryPlanner) getOrderedSelectPlan(data *parse.QueryData, tx *tx.Transaction) interfaces.Plan {
+	specs := plan.SortSpecs(data)
+	if len(h.tablePlanners) != 1 || len(specs) != 1 || len(data.Aggregates()) > 0 || len(data.GroupBy()) > 0 {
+		return nil
+	}
+	for _, computed := range data.Computed() {
+		if computed.Name() == specs[0].Field {
+			return nil
+		}
+	}
+
+	tp := h.tablePlanners[0]
+	p := tp.MakeOrderedSelectPlan(specs[0].Field, specs[0].Descending, specs[0].NullsFirst())
+	if p == nil {
+		return nil
+	}
+	cost := p.BlocksAccessed()
+	if data.Limit() != parse.NO_LIMIT {
+		cost = plan.FirstRecordsCost(p, data.Limit())
+	}
+
+	// Sorting reads the selected records, then the sorted ones
+	selectPlan := tp.MakeSelectPlan()
+	if cost >= selectPlan.BlocksAccessed()+materialize.NewSortPlan(tx, selectPlan, specs).BlocksAccessed() {
+		return nil
+	}
+	h.removeTablePlanner(tp)
+	return p
+}
+
 // Find the tablePlanner that, when joined with the current plan,
 func (h *HeuristicQueryPlanner) getLowestJoinPlan(current interfaces.Plan) interfaces.Plan {
 	var bestTP *TablePlanner
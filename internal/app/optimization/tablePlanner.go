@@ -5,11 +5,11 @@ import (
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/metadata"
 	"centauri/internal/app/multibuffer"
+	"centauri/internal/app/parse"
 	"centauri/internal/app/plan"
 	"centauri/internal/app/query"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
-	"fmt"
 )
 
 // Contains methods for planning operations on a single table. It evaluates different access paths for a
@@ -20,10 +20,18 @@ type TablePlanner struct {
 	myschema *schema.Schema
 	indexes  map[string]metadata.IndexInfo
 	tx       *tx.Transaction
+	limit    int // records needed from the select plan, or parse.NO_LIMIT
 }
 
-func NewTablePlanner(tableName string, mypred *query.Predicate, tx *tx.Transaction, mdm *metadata.MetaDataManager) *TablePlanner {
-	tablePlan := plan.NewTablePlan(tx, tableName, mdm).(*plan.TablePlan)
+// Creates a planner for the specified table.
+// Returns metadata.ErrTableNotFound if the table doesn't exist.
+func NewTablePlanner(tableName string, mypred *query.Predicate, tx *tx.Transaction, mdm *metadata.MetaDataManager) (*TablePlanner, error) {
+	p, err := plan.NewTablePlan(tx, tableName, mdm)
+	if err != nil {
+		return nil, err
+	}
+	// Only the partitions that can hold records satisfying the predicate are scanned
+	tablePlan := p.(*plan.TablePlan).Prune(mypred)
 
 	return &TablePlanner{
 		myplan:   tablePlan,
@@ -31,7 +39,15 @@ func NewTablePlanner(tableName string, mypred *query.Predicate, tx *tx.Transacti
 		tx:       tx,
 		myschema: tablePlan.Schema(),
 		indexes:  mdm.GetIndexInfo(tableName, tx),
-	}
+		limit:    parse.NO_LIMIT,
+	}, nil
+}
+
+// Sets the number of records the select plan needs to output. Access paths
+// are then compared by the cost of their first records, so a table scan that
+// soon finds enough of them can win over an index lookup.
+func (tp *TablePlanner) SetLimit(limit int) {
+	tp.limit = limit
 }
 
 // Constructs a select plan for the table.
@@ -42,10 +58,28 @@ func (tp *TablePlanner) MakeSelectPlan() interfaces.Plan {
 	p := tp.makeIndexSelect()
 	// If no applicable index found, use the basic table plan
 	if p == nil {
-		p = tp.myplan
+		return tp.addSelectPred(tp.myplan)
+	}
+	p = tp.addSelectPred(p)
+
+	// With a limit, scanning the table may reach it sooner
+	if tp.limit != parse.NO_LIMIT {
+		scan := tp.addSelectPred(tp.myplan)
+		if plan.FirstRecordsCost(scan, tp.limit) < plan.FirstRecordsCost(p, tp.limit) {
+			return scan
+		}
 	}
+	return p
+}
 
-	// Add any applicable selection predicates
+// Constructs a select plan for the table that outputs its records in the
+// order of the field, through a B-tree index on it. Returns nil if the
+// field has no such index.
+func (tp *TablePlanner) MakeOrderedSelectPlan(fieldName string, descending bool, nullsFirst bool) interfaces.Plan {
+	p := planner.MakeIndexOrderedSelect(tp.myplan, tp.mypred, tp.indexes, fieldName, descending, nullsFirst)
+	if p == nil {
+		return nil
+	}
 	return tp.addSelectPred(p)
 }
 
@@ -76,29 +110,17 @@ func (tp *TablePlanner) MakeJoinPlan(current interfaces.Plan) interfaces.Plan {
 // Constructs a product plan b/w the specified plan and this table.
 // This is used when there are no join conditions or as a fallback when index joins are not possible.
 func (tp *TablePlanner) MakeProductPlan(current interfaces.Plan) interfaces.Plan {
-	//  First add any selection predicates to the table plan
-	p := tp.addSelectPred(tp.myplan)
+	//  First select the table's records, through an index if possible
+	p := tp.MakeSelectPlan()
 
 	return multibuffer.NewMultiBufferProductPlan(tp.tx, current, p)
 }
 
 // Creates an index select plan if there's an index on a field that is used
-// in an equality condition with a constant.
+// in an equality condition with a constant, or restricted to a list of
+// constants by IN or BETWEEN.
 func (tp *TablePlanner) makeIndexSelect() interfaces.Plan {
-	for fieldName := range tp.indexes {
-		val := tp.mypred.EquatesWithConstant(fieldName)
-
-		// If we found an equality condition with a constant
-		if val != nil {
-			ii := tp.indexes[fieldName]
-			fmt.Println("index on", fieldName, "used")
-
-			return planner.NewIndexSelectPlan(tp.myplan, &ii, *val)
-		}
-	}
-
-	// No applicable index found
-	return nil
+	return planner.MakeIndexSelect(tp.myplan, tp.mypred, tp.indexes)
 }
 
 // Creates an index join plan if there's an index on a field in this table that is used in an
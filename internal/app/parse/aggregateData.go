@@ -0,0 +1,52 @@
+package parse
+
+import "strings"
+
+// Aggregate functions that can appear in a select list
+const (
+	AGG_COUNT = "count" // count(*), the number of records
+	AGG_MIN   = "min"
+	AGG_MAX   = "max"
+	// approx_count_distinct(<Field>), an estimate of the number of distinct values
+	AGG_APPROX_COUNT_DISTINCT = "approx_count_distinct"
+)
+
+// Holds an aggregate function of a select list, such as max(age)
+type AggregateData struct {
+	fn        string
+	fieldName string // empty for count(*)
+}
+
+func NewAggregateData(fn string, fieldName string) *AggregateData {
+	return &AggregateData{
+		fn:        strings.ToLower(fn),
+		fieldName: fieldName,
+	}
+}
+
+// Returns the name of the function, one of AGG_COUNT, AGG_MIN, AGG_MAX
+// or AGG_APPROX_COUNT_DISTINCT
+func (ad *AggregateData) Fn() string {
+	return ad.fn
+}
+
+// Returns the field the function aggregates, or "" for count(*)
+func (ad *AggregateData) FieldName() string {
+	return ad.fieldName
+}
+
+// Returns the name of the output field holding the aggregated value,
+// e.g. "maxofage" for max(age) and "count" for count(*)
+func (ad *AggregateData) Name() string {
+	if ad.fieldName == "" {
+		return ad.fn
+	}
+	return ad.fn + "of" + ad.fieldName
+}
+
+func (ad *AggregateData) String() string {
+	if ad.fieldName == "" {
+		return ad.fn + "(*)"
+	}
+	return ad.fn + "(" + ad.fieldName + ")"
+}
@@ -0,0 +1,51 @@
+package parse
+
+import "centauri/internal/app/record/schema"
+
+// Holds the data for the ALTER TABLE ... ADD COLUMN command
+type AddColumnData struct {
+	tableName string
+	field     *schema.Schema
+}
+
+func NewAddColumnData(tableName string, field *schema.Schema) *AddColumnData {
+	return &AddColumnData{
+		tableName: tableName,
+		field:     field,
+	}
+}
+
+func (acd *AddColumnData) TableName() string {
+	return acd.tableName
+}
+
+// Returns the name of the added field
+func (acd *AddColumnData) FieldName() string {
+	return acd.field.Fields()[0]
+}
+
+// Returns a schema holding just the added field
+func (acd *AddColumnData) Field() *schema.Schema {
+	return acd.field
+}
+
+// Holds the data for the ALTER TABLE ... DROP COLUMN command
+type DropColumnData struct {
+	tableName string
+	fieldName string
+}
+
+func NewDropColumnData(tableName string, fieldName string) *DropColumnData {
+	return &DropColumnData{
+		tableName: tableName,
+		fieldName: fieldName,
+	}
+}
+
+func (dcd *DropColumnData) TableName() string {
+	return dcd.tableName
+}
+
+func (dcd *DropColumnData) FieldName() string {
+	return dcd.fieldName
+}
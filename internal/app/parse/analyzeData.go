@@ -0,0 +1,17 @@
+package parse
+
+// Holds the data for the ANALYZE command
+type AnalyzeData struct {
+	tableName string
+}
+
+func NewAnalyzeData(tableName string) *AnalyzeData {
+	return &AnalyzeData{
+		tableName: tableName,
+	}
+}
+
+// Returns the table to analyze, empty for every table
+func (ad *AnalyzeData) TableName() string {
+	return ad.tableName
+}
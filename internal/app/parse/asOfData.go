@@ -0,0 +1,53 @@
+package parse
+
+import (
+	"fmt"
+	"time"
+)
+
+// Layouts a timestamp of an AS OF clause may be written in,
+// the first in local time
+var timestampLayouts = []string{"2006-01-02 15:04:05", time.RFC3339Nano}
+
+// Holds the AS OF clause of a query, the past point in the history of the
+// database whose state it reads: either a log sequence number or a time
+type AsOfData struct {
+	lsn  int // 0 if the point is a time
+	time time.Time
+}
+
+func NewAsOfLSN(lsn int) *AsOfData {
+	return &AsOfData{lsn: lsn}
+}
+
+func NewAsOfTime(t time.Time) *AsOfData {
+	return &AsOfData{time: t}
+}
+
+// Returns the LSN of the point, or 0 if it is a time
+func (ad *AsOfData) LSN() int {
+	return ad.lsn
+}
+
+// Returns the time of the point, unless it is an LSN
+func (ad *AsOfData) Time() time.Time {
+	return ad.time
+}
+
+func (ad *AsOfData) String() string {
+	if ad.lsn > 0 {
+		return fmt.Sprintf("as of %d", ad.lsn)
+	}
+	return fmt.Sprintf("as of timestamp '%s'", ad.time.Format(time.RFC3339Nano))
+}
+
+// Parses the timestamp of an AS OF clause, written either as
+// "2006-01-02 15:04:05" in local time or in RFC 3339 format
+func parseTimestamp(s string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
@@ -0,0 +1,35 @@
+package parse
+
+import "centauri/internal/app/query"
+
+// Holds a field of a select list computed from the fields of each record,
+// such as price * qty AS total
+type ComputedData struct {
+	name string
+	expr *query.Expression
+}
+
+func NewComputedData(name string, expr *query.Expression) *ComputedData {
+	return &ComputedData{
+		name: name,
+		expr: expr,
+	}
+}
+
+// Returns the name of the output field, the alias given with AS or
+// else the text of the expression
+func (cd *ComputedData) Name() string {
+	return cd.name
+}
+
+// Returns the expression computing the field
+func (cd *ComputedData) Expression() *query.Expression {
+	return cd.expr
+}
+
+func (cd *ComputedData) String() string {
+	if cd.name == cd.expr.String() {
+		return cd.name
+	}
+	return cd.expr.String() + " as " + cd.name
+}
@@ -0,0 +1,70 @@
+package parse
+
+// Holds the data for the COPY command
+type CopyData struct {
+	tableName string
+	fields    []string
+	fileName  string
+	options   map[string]string
+}
+
+func NewCopyData(tableName string, fields []string, fileName string, options map[string]string) *CopyData {
+	if options == nil {
+		options = make(map[string]string)
+	}
+	return &CopyData{
+		tableName: tableName,
+		fields:    fields,
+		fileName:  fileName,
+		options:   options,
+	}
+}
+
+func (cd *CopyData) TableName() string {
+	return cd.tableName
+}
+
+// Returns the fields the columns of the file are loaded into,
+// or nil if no field list was given
+func (cd *CopyData) Fields() []string {
+	return cd.fields
+}
+
+func (cd *CopyData) FileName() string {
+	return cd.fileName
+}
+
+// Returns the options specified in the WITH clause
+func (cd *CopyData) Options() map[string]string {
+	return cd.options
+}
+
+// Holds the data for the COPY command that exports the result of a query
+type CopyToData struct {
+	query    *QueryData
+	fileName string
+	format   string
+}
+
+func NewCopyToData(query *QueryData, fileName string, format string) *CopyToData {
+	return &CopyToData{
+		query:    query,
+		fileName: fileName,
+		format:   format,
+	}
+}
+
+// Returns the query whose result is exported
+func (cd *CopyToData) Query() *QueryData {
+	return cd.query
+}
+
+func (cd *CopyToData) FileName() string {
+	return cd.fileName
+}
+
+// Returns the lowercase name of the output format,
+// or "" if none was specified
+func (cd *CopyToData) Format() string {
+	return cd.format
+}
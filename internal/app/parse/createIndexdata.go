@@ -4,13 +4,17 @@ type CreateIndexData struct {
 	idxName   string
 	tableName string
 	fieldName string
+	indexType string // "hash" or "btree"
+	unique    bool
 }
 
-func NewCreateIndexData(idxName string, tableName string, fieldName string) *CreateIndexData {
+func NewCreateIndexData(idxName string, tableName string, fieldName string, indexType string, unique bool) *CreateIndexData {
 	return &CreateIndexData{
 		idxName:   idxName,
 		tableName: tableName,
 		fieldName: fieldName,
+		indexType: indexType,
+		unique:    unique,
 	}
 }
 
@@ -25,3 +29,13 @@ func (cid *CreateIndexData) TableName() string {
 func (cid *CreateIndexData) FieldName() string {
 	return cid.fieldName
 }
+
+// Returns the structure of the index, "hash" unless USING BTREE was given
+func (cid *CreateIndexData) IndexType() string {
+	return cid.indexType
+}
+
+// Reports whether CREATE UNIQUE INDEX was given
+func (cid *CreateIndexData) IsUnique() bool {
+	return cid.unique
+}
@@ -5,14 +5,26 @@ import (
 )
 
 type CreateTableData struct {
-	tableName string
-	schema    *schema.Schema
+	tableName    string
+	schema       *schema.Schema
+	options      map[string]string
+	partitioning *PartitionData
 }
 
 func NewCreateTableData(tableName string, schema *schema.Schema) *CreateTableData {
 	return &CreateTableData{
 		tableName: tableName,
 		schema:    schema,
+		options:   make(map[string]string),
+	}
+}
+
+// Creates the data for a CREATE TABLE statement with a WITH (...) clause
+func NewCreateTableDataWithOptions(tableName string, schema *schema.Schema, options map[string]string) *CreateTableData {
+	return &CreateTableData{
+		tableName: tableName,
+		schema:    schema,
+		options:   options,
 	}
 }
 
@@ -23,3 +35,68 @@ func (cd *CreateTableData) TableName() string {
 func (cd *CreateTableData) NewSchema() *schema.Schema {
 	return cd.schema
 }
+
+// Returns the storage options given in the WITH clause, keyed by option name
+func (cd *CreateTableData) Options() map[string]string {
+	return cd.options
+}
+
+// Returns the PARTITION BY clause, nil if the table isn't partitioned
+func (cd *CreateTableData) Partitioning() *PartitionData {
+	return cd.partitioning
+}
+
+// Sets the PARTITION BY clause of the table
+func (cd *CreateTableData) SetPartitioning(pd *PartitionData) {
+	cd.partitioning = pd
+}
+
+// Methods of a PARTITION BY clause
+const (
+	PARTITION_BY_RANGE = "range"
+	PARTITION_BY_HASH  = "hash"
+)
+
+// A partition listed in a PARTITION BY RANGE clause
+type RangePartition struct {
+	Name     string
+	Bound    int  // the values of the partition are less than the bound
+	MaxValue bool // VALUES LESS THAN MAXVALUE
+}
+
+// The PARTITION BY clause of a CREATE TABLE statement. Range partitioning
+// lists its partitions, hash partitioning gives the number of them.
+type PartitionData struct {
+	method     string
+	field      string
+	partitions []RangePartition
+	count      int
+}
+
+func NewRangePartitionData(field string, partitions []RangePartition) *PartitionData {
+	return &PartitionData{method: PARTITION_BY_RANGE, field: field, partitions: partitions}
+}
+
+func NewHashPartitionData(field string, count int) *PartitionData {
+	return &PartitionData{method: PARTITION_BY_HASH, field: field, count: count}
+}
+
+// Returns PARTITION_BY_RANGE or PARTITION_BY_HASH
+func (pd *PartitionData) Method() string {
+	return pd.method
+}
+
+// Returns the partition key
+func (pd *PartitionData) Field() string {
+	return pd.field
+}
+
+// Returns the partitions of a range partitioning, in the order listed
+func (pd *PartitionData) Partitions() []RangePartition {
+	return pd.partitions
+}
+
+// Returns the number of partitions of a hash partitioning
+func (pd *PartitionData) Count() int {
+	return pd.count
+}
@@ -19,3 +19,13 @@ func (cvd *CreateViewData) ViewName() string {
 func (cvd *CreateViewData) ViewDef() string {
 	return cvd.queryData.String()
 }
+
+// Returns the query defining the view
+func (cvd *CreateViewData) Query() *QueryData {
+	return cvd.queryData
+}
+
+// Returns the tables (or views) referenced by the view definition
+func (cvd *CreateViewData) Tables() []string {
+	return cvd.queryData.ReadTables()
+}
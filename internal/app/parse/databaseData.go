@@ -0,0 +1,39 @@
+package parse
+
+// Holds the data for the CREATE DATABASE command
+type CreateDatabaseData struct {
+	dbName string
+}
+
+func NewCreateDatabaseData(dbName string) *CreateDatabaseData {
+	return &CreateDatabaseData{
+		dbName: dbName,
+	}
+}
+
+func (cd *CreateDatabaseData) DatabaseName() string {
+	return cd.dbName
+}
+
+// Holds the data for the USE command, which selects
+// the database the current session works in
+type UseData struct {
+	dbName string
+}
+
+func NewUseData(dbName string) *UseData {
+	return &UseData{
+		dbName: dbName,
+	}
+}
+
+func (ud *UseData) DatabaseName() string {
+	return ud.dbName
+}
+
+// Holds the data for the SHOW DATABASES command
+type ShowDatabasesData struct{}
+
+func NewShowDatabasesData() *ShowDatabasesData {
+	return &ShowDatabasesData{}
+}
@@ -2,6 +2,7 @@ package parse
 
 import (
 	"centauri/internal/app/query"
+	"centauri/internal/app/types"
 )
 
 type DeleteData struct {
@@ -23,3 +24,9 @@ func (dd *DeleteData) TableName() string {
 func (dd *DeleteData) Pred() *query.Predicate {
 	return dd.pred
 }
+
+// Returns a copy of the delete with each parameter placeholder
+// replaced by the value bound to it
+func (dd *DeleteData) Bind(params []*types.Constant) *DeleteData {
+	return NewDeleteData(dd.tableName, dd.pred.Bind(params))
+}
@@ -0,0 +1,60 @@
+package parse
+
+// Holds the data for the DROP TABLE command
+type DropTableData struct {
+	tableName string
+	cascade   bool
+}
+
+func NewDropTableData(tableName string, cascade bool) *DropTableData {
+	return &DropTableData{
+		tableName: tableName,
+		cascade:   cascade,
+	}
+}
+
+func (dtd *DropTableData) TableName() string {
+	return dtd.tableName
+}
+
+// Reports whether the views and indexes depending on the table are dropped with it
+func (dtd *DropTableData) Cascade() bool {
+	return dtd.cascade
+}
+
+// Holds the data for the DROP INDEX command
+type DropIndexData struct {
+	indexName string
+}
+
+func NewDropIndexData(indexName string) *DropIndexData {
+	return &DropIndexData{
+		indexName: indexName,
+	}
+}
+
+func (did *DropIndexData) IndexName() string {
+	return did.indexName
+}
+
+// Holds the data for the DROP VIEW command
+type DropViewData struct {
+	viewName string
+	cascade  bool
+}
+
+func NewDropViewData(viewName string, cascade bool) *DropViewData {
+	return &DropViewData{
+		viewName: viewName,
+		cascade:  cascade,
+	}
+}
+
+func (dvd *DropViewData) ViewName() string {
+	return dvd.viewName
+}
+
+// Reports whether the views defined on the view are dropped with it
+func (dvd *DropViewData) Cascade() bool {
+	return dvd.cascade
+}
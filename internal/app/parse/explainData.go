@@ -0,0 +1,33 @@
+package parse
+
+// Holds the data for the EXPLAIN command, which shows the plan
+// chosen for a query instead of running it, or with ANALYZE
+// along with what running it took
+type ExplainData struct {
+	query   *QueryData
+	analyze bool
+}
+
+func NewExplainData(query *QueryData, analyze bool) *ExplainData {
+	return &ExplainData{
+		query:   query,
+		analyze: analyze,
+	}
+}
+
+// Returns the query to explain
+func (ed *ExplainData) Query() *QueryData {
+	return ed.query
+}
+
+// Reports whether the query is run, as EXPLAIN ANALYZE does
+func (ed *ExplainData) Analyze() bool {
+	return ed.analyze
+}
+
+func (ed *ExplainData) String() string {
+	if ed.analyze {
+		return "explain analyze " + ed.query.String()
+	}
+	return "explain " + ed.query.String()
+}
@@ -0,0 +1,57 @@
+package parse
+
+// Holds the data for the GRANT command
+type GrantData struct {
+	privileges []string
+	tableName  string
+	userName   string
+}
+
+func NewGrantData(privileges []string, tableName string, userName string) *GrantData {
+	return &GrantData{
+		privileges: privileges,
+		tableName:  tableName,
+		userName:   userName,
+	}
+}
+
+// Returns the privileges granted, or just "all" for ALL PRIVILEGES
+func (gd *GrantData) Privileges() []string {
+	return gd.privileges
+}
+
+func (gd *GrantData) TableName() string {
+	return gd.tableName
+}
+
+func (gd *GrantData) UserName() string {
+	return gd.userName
+}
+
+// Holds the data for the REVOKE command
+type RevokeData struct {
+	privileges []string
+	tableName  string
+	userName   string
+}
+
+func NewRevokeData(privileges []string, tableName string, userName string) *RevokeData {
+	return &RevokeData{
+		privileges: privileges,
+		tableName:  tableName,
+		userName:   userName,
+	}
+}
+
+// Returns the privileges revoked, or just "all" for ALL PRIVILEGES
+func (rd *RevokeData) Privileges() []string {
+	return rd.privileges
+}
+
+func (rd *RevokeData) TableName() string {
+	return rd.tableName
+}
+
+func (rd *RevokeData) UserName() string {
+	return rd.userName
+}
@@ -1,20 +1,27 @@
 package parse
 
 import (
+	"centauri/internal/app/query"
 	"centauri/internal/app/types"
 )
 
 type InsertData struct {
 	tableName string
 	fields    []string
-	values    []*types.Constant
+	rows      [][]*types.Constant
 }
 
 func NewInsertData(tableName string, fields []string, values []*types.Constant) *InsertData {
+	return NewInsertDataWithRows(tableName, fields, [][]*types.Constant{values})
+}
+
+// Creates the data of an insert of several records, each given by a
+// list of values in the order of the fields
+func NewInsertDataWithRows(tableName string, fields []string, rows [][]*types.Constant) *InsertData {
 	return &InsertData{
 		tableName: tableName,
 		fields:    fields,
-		values:    values,
+		rows:      rows,
 	}
 }
 
@@ -26,6 +33,25 @@ func (id *InsertData) Fields() []string {
 	return id.fields
 }
 
+// Returns the values of the first record inserted
 func (id *InsertData) Values() []*types.Constant {
-	return id.values
+	return id.rows[0]
+}
+
+// Returns the values of each record inserted, in the order of the VALUES list
+func (id *InsertData) Rows() [][]*types.Constant {
+	return id.rows
+}
+
+// Returns a copy of the insert with each parameter placeholder
+// replaced by the value bound to it
+func (id *InsertData) Bind(params []*types.Constant) *InsertData {
+	rows := make([][]*types.Constant, len(id.rows))
+	for i, values := range id.rows {
+		rows[i] = make([]*types.Constant, len(values))
+		for j, val := range values {
+			rows[i][j] = query.BindConstant(val, params)
+		}
+	}
+	return NewInsertDataWithRows(id.tableName, id.fields, rows)
 }
@@ -0,0 +1,31 @@
+package parse
+
+import "centauri/internal/app/query"
+
+// Holds a table joined into the FROM clause with JOIN ... ON, along with
+// the predicate its records are matched on
+type JoinData struct {
+	tableName string
+	on        *query.Predicate
+}
+
+func NewJoinData(tableName string, on *query.Predicate) *JoinData {
+	return &JoinData{
+		tableName: tableName,
+		on:        on,
+	}
+}
+
+// Returns the joined table
+func (jd *JoinData) TableName() string {
+	return jd.tableName
+}
+
+// Returns the predicate of the ON clause
+func (jd *JoinData) On() *query.Predicate {
+	return jd.on
+}
+
+func (jd *JoinData) String() string {
+	return "join " + jd.tableName + " on " + jd.on.String()
+}
@@ -1,18 +1,40 @@
 package parse
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"text/scanner"
 	"unicode"
 )
 
+// Returned, wrapped in a SyntaxError, for statements that don't follow the grammar
+var ErrSyntax = errors.New("syntax error")
+
+// A syntax error, at the line and column of the token where it was found.
+// Lines and columns are numbered from 1.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%v at line %d, column %d: %s", ErrSyntax, e.Line, e.Column, e.Msg)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return ErrSyntax
+}
+
 // A Lexical analyzer for SQL Statements.
 // It tokenizes SQL strings into identifiers, keywords, delimiters, and constants.
 type Lexer struct {
 	keywords    map[string]bool // Set of SQL keywords for quick loop
 	currentRune rune            // Current token text
 	scanner     scanner.Scanner // Go's built in scanner for tokenizing
+	input       string          // The statement being tokenized
 }
 
 // Creates a new lexical analyzer for SQL statement s.
@@ -21,7 +43,7 @@ func NewLexer(s string) *Lexer {
 	sc.Init(strings.NewReader(s))
 
 	// Configure scanner
-	sc.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanStrings | scanner.ScanRawStrings
+	sc.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats | scanner.ScanStrings | scanner.ScanRawStrings
 
 	// Allow underscores in identifiers
 	// Make scanner case-sensitive for identifiers
@@ -32,6 +54,7 @@ func NewLexer(s string) *Lexer {
 	lexer := &Lexer{
 		scanner:  sc,
 		keywords: initKeywords(),
+		input:    s,
 	}
 
 	// Read the first token
@@ -44,25 +67,51 @@ func NewLexer(s string) *Lexer {
 // Using a map for O(1) lookup performance
 func initKeywords() map[string]bool {
 	keywords := map[string]bool{
-		"select":  true,
-		"from":    true,
-		"where":   true,
-		"and":     true,
-		"insert":  true,
-		"into":    true,
-		"values":  true,
-		"delete":  true,
-		"update":  true,
-		"set":     true,
-		"create":  true,
-		"table":   true,
-		"int":     true,
-		"varchar": true,
-		"view":    true,
-		"as":      true,
-		"index":   true,
-		"on":      true,
-		"join":    true,
+		"select":     true,
+		"from":       true,
+		"where":      true,
+		"and":        true,
+		"or":         true,
+		"insert":     true,
+		"into":       true,
+		"values":     true,
+		"delete":     true,
+		"update":     true,
+		"set":        true,
+		"create":     true,
+		"drop":       true,
+		"cascade":    true,
+		"table":      true,
+		"int":        true,
+		"varchar":    true,
+		"view":       true,
+		"as":         true,
+		"index":      true,
+		"on":         true,
+		"join":       true,
+		"with":       true,
+		"copy":       true,
+		"to":         true,
+		"show":       true,
+		"user":       true,
+		"alter":      true,
+		"password":   true,
+		"superuser":  true,
+		"grant":      true,
+		"revoke":     true,
+		"all":        true,
+		"privileges": true,
+		"database":   true,
+		"databases":  true,
+		"use":        true,
+		"collate":    true,
+		"limit":      true,
+		"order":      true,
+		"null":       true,
+		"is":         true,
+		"not":        true,
+		"true":       true,
+		"false":      true,
 	}
 	return keywords
 }
@@ -79,6 +128,12 @@ func (l *Lexer) MatchIntConstant() bool {
 	return l.currentRune == scanner.Int
 }
 
+// Returns true if the current token is a number with a fraction or an
+// exponent, such as 1.5 or 2e10.
+func (l *Lexer) MatchFloatConstant() bool {
+	return l.currentRune == scanner.Float
+}
+
 // Returns true if the current token is a string.
 func (l *Lexer) MatchStringConstant() bool {
 	return l.currentRune == scanner.String || l.currentRune == '\''
@@ -94,79 +149,90 @@ func (l *Lexer) MatchId() bool {
 	return l.currentRune == scanner.Ident && !l.keywords[strings.ToLower(l.scanner.TokenText())]
 }
 
+// Returns true if the current token may follow a complete term: the end of
+// the input, a closing parenthesis, a comma or a word such as AND.
+func (l *Lexer) MatchTermEnd() bool {
+	return l.currentRune == scanner.EOF || l.currentRune == ')' || l.currentRune == ',' || l.currentRune == scanner.Ident
+}
+
 // METHOD TO EAT THE CURRENT TOKEN
 
-// Throws an error if the current token is not specified delimitter.
+// Returns an error if the current token is not specified delimitter.
 // Otherwise moves to the next token.
-func (l *Lexer) EatDelim(d rune) {
+func (l *Lexer) EatDelim(d rune) error {
 	if !l.MatchDelim(d) {
-		panic("BadSyntaxException: Expected delimter " + string(d))
+		return l.expected(fmt.Sprintf("%q", d))
 	}
 
 	l.nextToken()
+	return nil
 }
 
-// Throws an error if the current token is not an integer.
+// Returns an error if the current token is not an integer.
 // Otherwise, returns that integer and moves to the next token.
-func (l *Lexer) EatIntConstant() int {
+func (l *Lexer) EatIntConstant() (int, error) {
 	if !l.MatchIntConstant() {
-		panic("BadSyntaxExpection: Expected integer constant")
+		return 0, l.expected("integer constant")
 	}
 
 	// Convert token to integer
 	value, err := strconv.Atoi(l.scanner.TokenText())
 	if err != nil {
-		panic("BadSyntaxException: Invalid integer format")
+		return 0, l.errorf("invalid integer %s", l.scanner.TokenText())
+	}
+
+	l.nextToken()
+	return value, nil
+}
+
+// Returns an error if the current token is not a float.
+// Otherwise, returns that float and moves to the next token.
+func (l *Lexer) EatFloatConstant() (float64, error) {
+	if !l.MatchFloatConstant() {
+		return 0, l.expected("float constant")
+	}
+
+	value, err := strconv.ParseFloat(l.scanner.TokenText(), 64)
+	if err != nil {
+		return 0, l.errorf("invalid float %s", l.scanner.TokenText())
 	}
 
 	l.nextToken()
-	return value
+	return value, nil
 }
 
-// Throws and error if the current token is not a string.
+// Returns an error if the current token is not a string.
 // Otherwise, returns that string and moves to the next token.
-func (l *Lexer) EatStringConstant() string {
+func (l *Lexer) EatStringConstant() (string, error) {
 	if !l.MatchStringConstant() {
-		panic("BadSyntaxException: Expected string constant")
+		return "", l.expected("string constant")
 	}
 
-	// If we see a single quote, we need to handle a string literal
+	// If we see a single quote, we need to handle a string literal.
+	// The scanner only recognizes double quoted strings, so the characters
+	// up to the closing quote are read as they are, which keeps text such
+	// as file paths intact. Two quotes in a row stand for a single quote.
 	if l.currentRune == '\'' {
-		l.nextToken() // Consume the opening quote
+		// Reading characters clears the position of the token
+		pos := l.position()
 		var value strings.Builder
-		isFirstToken := true
 
-		// Keep reading until we find the closing quote
-		for l.currentRune != '\'' {
-			if l.currentRune == scanner.EOF {
-				panic("BadSyntaxException: Unclosed string literal")
+		for {
+			ch := l.scanner.Next()
+			if ch == scanner.EOF {
+				return "", l.errorAt(pos, "unclosed string literal")
 			}
-
-			currentText := l.scanner.TokenText()
-
-			// Handle hyphens
-			if l.currentRune == '-' {
-				value.WriteRune('-')
-				l.nextToken()
-				continue
+			if ch == '\'' {
+				if l.scanner.Peek() != '\'' {
+					break
+				}
+				l.scanner.Next()
 			}
-
-			// Add space between words, but not around hyphens
-			if !isFirstToken && l.currentRune != '-' && value.String() != "" && !strings.HasSuffix(value.String(), "-") {
-				value.WriteString(" ")
-			}
-
-			value.WriteString(currentText)
-			isFirstToken = false
-			l.nextToken()
+			value.WriteRune(ch)
 		}
 
-		result := value.String()
-		// Clean up any potential spacing isssues around hyphens
-		result = strings.ReplaceAll(result, " -", "-")
-		result = strings.ReplaceAll(result, "- ", "-")
 		l.nextToken()
-		return strings.TrimSpace(result)
+		return value.String(), nil
 	}
 
 	// Get the string value and handle quotes
@@ -175,29 +241,68 @@ func (l *Lexer) EatStringConstant() string {
 	value := strings.Trim(tokenText, `'"`) // Remove surrounding quotes
 
 	l.nextToken()
-	return value
+	return value, nil
 }
 
-// Throws an error if the current token is not the specified keyword.
+// Returns an error if the current token is not the specified keyword.
 // Otherwise, moves to the next token.
-func (l *Lexer) EatKeyword(w string) {
+func (l *Lexer) EatKeyword(w string) error {
 	if !l.MatchKeyword(w) {
-		panic("BadSyntaxException: Expected keyword " + w)
+		return l.expected("keyword " + strings.ToUpper(w))
 	}
 
 	l.nextToken()
+	return nil
 }
 
-// Throws an error if the current token is not an identifier.
+// Returns an error if the current token is not an identifier.
 // Otherwise, returns the identifier string and moves to the next token.
-func (l *Lexer) EatId() string {
+func (l *Lexer) EatId() (string, error) {
 	if !l.MatchId() {
-		panic("BadSyntaxException: Expected identifier")
+		return "", l.expected("identifier")
 	}
 
 	value := l.scanner.TokenText()
 	l.nextToken()
-	return value
+	return value, nil
+}
+
+// Returns the position of the current token
+func (l *Lexer) position() scanner.Position {
+	return l.scanner.Position
+}
+
+// Returns a syntax error at the current token
+func (l *Lexer) errorf(format string, args ...any) error {
+	return l.errorAt(l.position(), format, args...)
+}
+
+// Returns a syntax error at a position
+func (l *Lexer) errorAt(pos scanner.Position, format string, args ...any) error {
+	return &SyntaxError{Line: pos.Line, Column: pos.Column, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Returns a syntax error saying what was expected instead of the current token
+func (l *Lexer) expected(what string) error {
+	found := "end of statement"
+	if l.currentRune != scanner.EOF {
+		found = strconv.Quote(l.scanner.TokenText())
+	}
+	return l.errorf("expected %s, found %s", what, found)
+}
+
+// Returns the byte offset of the current token in the statement
+func (l *Lexer) Offset() int {
+	if l.currentRune == scanner.EOF {
+		return len(l.input)
+	}
+	return l.scanner.Position.Offset
+}
+
+// Returns the text of the statement between two offsets, without
+// surrounding white space
+func (l *Lexer) Text(start int, end int) string {
+	return strings.TrimSpace(l.input[start:end])
 }
 
 // Advances the lexer to the next token in the input stream and returns it.
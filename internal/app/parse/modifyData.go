@@ -2,6 +2,7 @@ package parse
 
 import (
 	"centauri/internal/app/query"
+	"centauri/internal/app/types"
 )
 
 type ModifyData struct {
@@ -35,3 +36,9 @@ func (md *ModifyData) NewValue() *query.Expression {
 func (md *ModifyData) Pred() *query.Predicate {
 	return md.pred
 }
+
+// Returns a copy of the update with each parameter placeholder
+// replaced by the value bound to it
+func (md *ModifyData) Bind(params []*types.Constant) *ModifyData {
+	return NewModifyData(md.tableName, md.fieldName, md.newVal.Bind(params), md.pred.Bind(params))
+}
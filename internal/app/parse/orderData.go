@@ -0,0 +1,55 @@
+package parse
+
+import "strings"
+
+// Where an ORDER BY item places null values
+const (
+	NULLS_DEFAULT = iota // last when ascending, first when descending
+	NULLS_FIRST
+	NULLS_LAST
+)
+
+// Holds an item of an ORDER BY clause, such as name DESC NULLS LAST
+type OrderData struct {
+	fieldName  string
+	descending bool
+	nulls      int
+}
+
+func NewOrderData(fieldName string, descending bool, nulls int) *OrderData {
+	return &OrderData{
+		fieldName:  fieldName,
+		descending: descending,
+		nulls:      nulls,
+	}
+}
+
+// Returns the field the records are ordered by
+func (od *OrderData) FieldName() string {
+	return od.fieldName
+}
+
+// Reports whether the records are ordered from the greatest value down
+func (od *OrderData) Descending() bool {
+	return od.descending
+}
+
+// Returns where null values are placed, one of NULLS_DEFAULT, NULLS_FIRST or NULLS_LAST
+func (od *OrderData) Nulls() int {
+	return od.nulls
+}
+
+func (od *OrderData) String() string {
+	var sb strings.Builder
+	sb.WriteString(od.fieldName)
+	if od.descending {
+		sb.WriteString(" desc")
+	}
+	switch od.nulls {
+	case NULLS_FIRST:
+		sb.WriteString(" nulls first")
+	case NULLS_LAST:
+		sb.WriteString(" nulls last")
+	}
+	return sb.String()
+}
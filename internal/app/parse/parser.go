@@ -4,13 +4,22 @@ import (
 	"centauri/internal/app/query"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/scanner"
 )
 
 // Implements a recursive-descent parser for the SQL syntax.
 // It converts SQL strings into structured data objects representing various SQL commands.
 // Example input: "SELECT id, name FROM users WHERE age = 25"
 type Parser struct {
-	lexer *Lexer // The lexical analyzer that breaks input strings into tokens
+	lexer  *Lexer            // The lexical analyzer that breaks input strings into tokens
+	tables []string          // The tables of the statement so far, which may qualify field names
+	outer  []string          // The tables of the statements around a subquery, which may too
+	params int               // The number of parameter placeholders read so far
+	having *[]*AggregateData // Collects the aggregates of the HAVING clause being parsed, nil outside one
 }
 
 // Creates a new parser for the given SQL string.
@@ -20,6 +29,23 @@ func NewParser(s string) *Parser {
 	}
 }
 
+// Returns the number of parameter placeholders in the statement parsed.
+// The values of the parameters are bound when a prepared statement runs.
+func (p *Parser) NumParams() int {
+	return p.params
+}
+
+// Parses a query or an update command, telling them apart by the
+// SELECT a query starts with.
+// Returns a *QueryData for a query and the data of the command otherwise.
+// Corresponds to grammar rule: <Statement> := <Query> | <UpdateCmd>
+func (p *Parser) Statement() (interface{}, error) {
+	if p.lexer.MatchKeyword("select") {
+		return p.Query()
+	}
+	return p.UpdateCmd()
+}
+
 // -------- METHODS FOR PARSING PREDICATES, TERMS, EXPRESSIONS, CONSTANTS AND FIELDS --------
 
 // Parses a database field name (an identifier)
@@ -29,28 +55,82 @@ func NewParser(s string) *Parser {
 //
 //	In "SELECT name FROM users", "name" is a field
 //	In "WHERE age = 25", "age" is a field
-func (p *Parser) Field() string {
+func (p *Parser) Field() (string, error) {
 	return p.lexer.EatId()
 }
 
-// Parses a constant value (string or integer).
+// Parses a constant value (string, integer, float, boolean or NULL), or the placeholder
+// of a parameter, which the parameters are numbered by in order.
 // Returns a Constant struct contaning the value.
-// Corresponds to grammar rule: <Constant> := StrTok | IntTok
+// Corresponds to grammar rule: <Constant> := StrTok | IntTok | FloatTok | TRUE | FALSE | NULL | ?
 // Example: In "WHERE age = 20", "20" is an integer constant.
+// Example: In "WHERE views > 5000000000", "5000000000" is a 64-bit integer constant.
+// Example: In "WHERE price < 9.99", "9.99" is a float constant.
 // Example: In "WHERE name = 'John'", "John" is a string constant.
-func (p *Parser) Constant() *types.Constant {
-	if p.lexer.MatchStringConstant() {
+// Example: In "SET active = TRUE", "TRUE" is a boolean constant.
+// Example: In "SET manager = NULL", "NULL" is the null constant.
+// Example: In "WHERE id = ?", "?" is the placeholder of the first parameter.
+func (p *Parser) Constant() (*types.Constant, error) {
+	if p.lexer.MatchDelim('?') {
+		p.lexer.EatDelim('?')
+		p.params++
+		return types.NewConstantParameter(p.params - 1), nil
+	} else if p.lexer.MatchKeyword("null") {
+		p.lexer.EatKeyword("null")
+		return types.NewConstantNull(), nil
+	} else if p.lexer.MatchKeyword("true") {
+		p.lexer.EatKeyword("true")
+		return types.NewConstantBool(true), nil
+	} else if p.lexer.MatchKeyword("false") {
+		p.lexer.EatKeyword("false")
+		return types.NewConstantBool(false), nil
+	} else if p.lexer.MatchStringConstant() {
 		// If the next token is a string constant, consume and wrap it
-		return types.NewConstantString(p.lexer.EatStringConstant())
+		s, err := p.lexer.EatStringConstant()
+		if err != nil {
+			return nil, err
+		}
+		return types.NewConstantString(s), nil
+	} else if p.lexer.MatchFloatConstant() {
+		f, err := p.lexer.EatFloatConstant()
+		if err != nil {
+			return nil, err
+		}
+		return types.NewConstantFloat(f), nil
 	} else {
 		// Otherwise, assume it's an Integer constant, consume and wrap it
-		return types.NewConstantInt(p.lexer.EatIntConstant())
+		n, err := p.lexer.EatIntConstant()
+		if err != nil {
+			return nil, err
+		}
+		return types.NewConstantInteger(int64(n)), nil
+	}
+}
+
+// Parses a field name, which may be qualified by the name of one of the
+// statement's tables. Field names are unique across the tables of a query,
+// so the qualifier is dropped once it has been checked.
+// Corresponds to grammar rule: <QualifiedField> := [ IdTok . ] <Field>
+// Example: In "ON emp.deptid = dept.did", "emp.deptid" is a qualified field.
+func (p *Parser) QualifiedField() (string, error) {
+	pos := p.lexer.position()
+	name, err := p.Field()
+	if err != nil || !p.lexer.MatchDelim('.') {
+		return name, err
 	}
+	p.lexer.EatDelim('.')
+	if !slices.Contains(p.tables, name) && !slices.Contains(p.outer, name) {
+		return "", p.lexer.errorAt(pos, "unknown table %s", name)
+	}
+	return p.Field()
 }
 
-// Parses an expression, which can be either a field or a constant.
-// Returns an Expression struct containing either a field name or a constant.
-// Corresponds to grammar rule: <Expression> := <Field> | <Constant>
+// Parses an expression, which can be a field, a constant or a subquery
+// outputting a single value.
+// Returns an Expression struct containing a field name, a constant or a subquery.
+// In a HAVING clause, it can also be an aggregate, which reads its output field.
+// NOW() is the time the statement is read at, see query.NewExpressionNow.
+// Corresponds to grammar rule: <Expression> := <Field> | <Constant> | <Subquery> | <Aggregate> | NOW ( )
 // Example:
 //
 //	In "WHERE age = 25":
@@ -58,17 +138,242 @@ func (p *Parser) Constant() *types.Constant {
 //	   - "25" is a constant expression
 //	In "SELECT name FROM users":
 //	   - "name" is field expression
-func (p *Parser) Expression() *query.Expression {
-	if p.lexer.MatchId() {
-		return query.NewExpressionFieldName(p.Field())
+//	In "WHERE age > (SELECT min(age) FROM admins)":
+//	   - "(SELECT min(age) FROM admins)" is a subquery expression
+func (p *Parser) Expression() (*query.Expression, error) {
+	if p.lexer.MatchDelim('(') {
+		subquery, err := p.Subquery(true)
+		if err != nil {
+			return nil, err
+		}
+		return query.NewExpressionSubquery(subquery), nil
+	} else if p.lexer.MatchId() {
+		pos := p.lexer.position()
+		field, err := p.QualifiedField()
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(field, query.FN_NOW) && p.lexer.MatchDelim('(') {
+			return p.FunctionCall(field)
+		}
+		if p.having != nil && p.lexer.MatchDelim('(') {
+			agg, err := p.aggregate(field, pos)
+			if err != nil {
+				return nil, err
+			}
+			*p.having = append(*p.having, agg)
+			return query.NewExpressionAggregate(agg.Name(), agg.String()), nil
+		}
+		return query.NewExpressionFieldName(field), nil
 	} else {
-		return query.NewExpressionVal(p.Constant())
+		c, err := p.Constant()
+		if err != nil {
+			return nil, err
+		}
+		return query.NewExpressionVal(c), nil
+	}
+}
+
+// Parses an expression computing a value from fields and constants with
+// arithmetic operators, function calls and CASE expressions.
+// Multiplication and division bind tighter than addition and subtraction,
+// and operators of the same precedence apply from left to right.
+// Corresponds to grammar rule: <ValueExpression> := <Product> [ (+|-) <Product> ]...
+// Example: In "SET salary = salary * 2 + bonus", "salary * 2 + bonus" is a value expression.
+func (p *Parser) ValueExpression() (*query.Expression, error) {
+	e, err := p.Product()
+	if err != nil {
+		return nil, err
+	}
+	return p.sum(e)
+}
+
+// Parses the rest of a value expression whose first product was just read
+func (p *Parser) sum(e *query.Expression) (*query.Expression, error) {
+	for p.lexer.MatchDelim('+') || p.lexer.MatchDelim('-') {
+		op := '+'
+		if p.lexer.MatchDelim('-') {
+			op = '-'
+		}
+		p.lexer.EatDelim(op)
+		rhs, err := p.Product()
+		if err != nil {
+			return nil, err
+		}
+		e = query.NewExpressionOp(string(op), e, rhs)
+	}
+	return e, nil
+}
+
+// Parses a product or quotient of factors.
+// Corresponds to grammar rule: <Product> := <Factor> [ (*|/) <Factor> ]...
+func (p *Parser) Product() (*query.Expression, error) {
+	e, err := p.Factor()
+	if err != nil {
+		return nil, err
+	}
+	return p.product(e)
+}
+
+// Parses the rest of a product whose first factor was just read
+func (p *Parser) product(e *query.Expression) (*query.Expression, error) {
+	for p.lexer.MatchDelim('*') || p.lexer.MatchDelim('/') {
+		op := '*'
+		if p.lexer.MatchDelim('/') {
+			op = '/'
+		}
+		p.lexer.EatDelim(op)
+		rhs, err := p.Factor()
+		if err != nil {
+			return nil, err
+		}
+		e = query.NewExpressionOp(string(op), e, rhs)
 	}
+	return e, nil
 }
 
-// Parses a term, which is an equality comparison between two expressions.
-// Returns a Term struct representing the equality comparison.
-// Corresponds to grammar rule: <Term> := <Expression> = <Expression>
+// Parses an operand of an arithmetic operator.
+// Corresponds to grammar rule:
+// <Factor> := - <Factor> | ( <ValueExpression> ) | <CaseExpression> | <Function> | <Field> | <Constant>
+func (p *Parser) Factor() (*query.Expression, error) {
+	switch {
+	case p.lexer.MatchDelim('-'):
+		p.lexer.EatDelim('-')
+		operand, err := p.Factor()
+		if err != nil {
+			return nil, err
+		}
+		// A negative number is a constant of its own
+		if c := operand.AsConstant(); c != nil {
+			if n, ok := c.AsInteger(); ok && n != math.MinInt64 {
+				return query.NewExpressionVal(types.NewConstantInteger(-n)), nil
+			}
+			if c.AsFloat() != nil {
+				return query.NewExpressionVal(types.NewConstantFloat(-*c.AsFloat())), nil
+			}
+		}
+		return query.NewExpressionOp("-", operand), nil
+	case p.lexer.MatchDelim('('):
+		p.lexer.EatDelim('(')
+		e, err := p.ValueExpression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lexer.EatDelim(')'); err != nil {
+			return nil, err
+		}
+		return e, nil
+	case p.lexer.MatchKeyword("case"):
+		return p.CaseExpression()
+	case p.lexer.MatchId():
+		name, err := p.Field()
+		if err != nil {
+			return nil, err
+		}
+		if p.lexer.MatchDelim('(') {
+			return p.FunctionCall(name)
+		}
+		return query.NewExpressionFieldName(name), nil
+	default:
+		c, err := p.Constant()
+		if err != nil {
+			return nil, err
+		}
+		return query.NewExpressionVal(c), nil
+	}
+}
+
+// Parses the arguments of a call to the named function, whose name was just read.
+// Corresponds to grammar rule: <Function> := IdTok ( [ <ValueExpression> [ , <ValueExpression> ]... ] )
+// Example: "upper(name)", "concat(first, last)", "now()"
+func (p *Parser) FunctionCall(name string) (*query.Expression, error) {
+	arity, ok := query.LookupFunction(name)
+	if !ok {
+		return nil, p.lexer.errorf("unknown function %s", name)
+	}
+
+	p.lexer.EatDelim('(')
+	var args []*query.Expression
+	for arity > 0 && !p.lexer.MatchDelim(')') {
+		if len(args) > 0 {
+			if err := p.lexer.EatDelim(','); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.ValueExpression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	if len(args) != arity {
+		return nil, p.lexer.errorf("%s takes %d arguments, got %d", name, arity, len(args))
+	}
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(name, query.FN_NOW) {
+		return query.NewExpressionNow(), nil
+	}
+	return query.NewExpressionOp(name, args...), nil
+}
+
+// Parses a CASE expression. Without an ELSE, its value is null when no condition holds.
+// Corresponds to grammar rule:
+// <CaseExpression> := CASE WHEN <Predicate> THEN <ValueExpression> [ WHEN ... ]... [ ELSE <ValueExpression> ] END
+// Example: "CASE WHEN grade = 'A' THEN salary * 2 ELSE salary END"
+func (p *Parser) CaseExpression() (*query.Expression, error) {
+	if err := p.lexer.EatKeyword("case"); err != nil {
+		return nil, err
+	}
+
+	var conds []*query.Predicate
+	var results []*query.Expression
+	for len(conds) == 0 || p.lexer.MatchKeyword("when") {
+		if err := p.lexer.EatKeyword("when"); err != nil {
+			return nil, err
+		}
+		cond, err := p.Predicate()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lexer.EatKeyword("then"); err != nil {
+			return nil, err
+		}
+		result, err := p.ValueExpression()
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+		results = append(results, result)
+	}
+
+	var elseVal *query.Expression
+	if p.lexer.MatchKeyword("else") {
+		p.lexer.EatKeyword("else")
+		e, err := p.ValueExpression()
+		if err != nil {
+			return nil, err
+		}
+		elseVal = e
+	}
+	if err := p.lexer.EatKeyword("end"); err != nil {
+		return nil, err
+	}
+
+	return query.NewExpressionCase(conds, results, elseVal), nil
+}
+
+// Parses a term, which is a comparison between two expressions, a check of
+// whether an expression is null, a test on the records of a subquery, or
+// a match of a list of values, a range or a pattern.
+// Returns a Term struct representing the comparison.
+// Corresponds to grammar rule:
+// <Term> := <Expression> <ComparisonOp> <Expression> | <Expression> IS [ NOT ] NULL
+// | <Expression> [ NOT ] IN <Subquery> | <Expression> [ NOT ] IN ( <ExpressionList> )
+// | <Expression> [ NOT ] BETWEEN <Expression> AND <Expression>
+// | <Expression> [ NOT ] LIKE <Expression> | EXISTS <Subquery> | <Expression>
 // Examples:
 //
 //	 In "WHERE age = 25":
@@ -77,104 +382,760 @@ func (p *Parser) Expression() *query.Expression {
 //	In "WHERE name = 'John'":
 //	     - Left expression: "name" (field)
 //	     - Right expression: "'John'" (constant)
-func (p *Parser) Term() *query.Term {
-	lhs := p.Expression() // Parse the left-hand side expression
-	p.lexer.EatDelim('=') // Consume the equals operator
-	rhs := p.Expression() // Parse the right-hand side expression
+//	In "WHERE salary >= 5000":
+//	     - Operator: ">="
+//	In "WHERE manager IS NOT NULL":
+//	     - Expression: "manager" (field)
+//	In "WHERE dept IN (SELECT id FROM depts WHERE city = 'Oslo')":
+//	     - Expression: "dept" (field)
+//	In "WHERE age BETWEEN 30 AND 40", "WHERE id IN (1, 2, 3)" and "WHERE name LIKE 'Jo%'":
+//	     - Expression: "age", "id" and "name" (fields)
+//	In "WHERE active":
+//	     - Expression: "active" (boolean field), compared with TRUE
+func (p *Parser) Term() (*query.Term, error) {
+	if p.lexer.MatchKeyword("exists") {
+		p.lexer.EatKeyword("exists")
+		subquery, err := p.Subquery(false)
+		if err != nil {
+			return nil, err
+		}
+		return query.NewExistsTerm(subquery), nil
+	}
+
+	lhs, err := p.Expression() // Parse the left-hand side expression
+	if err != nil {
+		return nil, err
+	}
+
+	if p.lexer.MatchKeyword("in") || p.lexer.MatchKeyword("between") || p.lexer.MatchKeyword("like") || p.lexer.MatchKeyword("not") {
+		not := p.lexer.MatchKeyword("not")
+		if not {
+			p.lexer.EatKeyword("not")
+		}
+		term, err := p.matchTerm(lhs)
+		if err != nil {
+			return nil, err
+		}
+		if not {
+			return query.NewNotTerm(query.NewPredicateWithTerm(term)), nil
+		}
+		return term, nil
+	}
+
+	if p.lexer.MatchKeyword("is") {
+		p.lexer.EatKeyword("is")
+		not := p.lexer.MatchKeyword("not")
+		if not {
+			p.lexer.EatKeyword("not")
+		}
+		if err := p.lexer.EatKeyword("null"); err != nil {
+			return nil, err
+		}
+		return query.NewIsNullTerm(lhs, not), nil
+	}
+
+	// A boolean on its own holds when it is true, as "active" does in
+	// "WHERE active AND age > 30"
+	if p.lexer.MatchTermEnd() && (lhs.IsFieldName() || lhs.AsConstant() != nil && lhs.AsConstant().AsBool() != nil) {
+		return query.NewComparisonTerm(lhs, query.OP_EQUALS, query.NewExpressionVal(types.NewConstantBool(true))), nil
+	}
+
+	op, err := p.ComparisonOp() // Parse the comparison operator
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := p.Expression() // Parse the right-hand side expression
+	if err != nil {
+		return nil, err
+	}
+
+	return query.NewComparisonTerm(lhs, op, rhs), nil
+}
+
+// Parses the IN, BETWEEN or LIKE of a term whose left-hand side was just read
+func (p *Parser) matchTerm(lhs *query.Expression) (*query.Term, error) {
+	switch {
+	case p.lexer.MatchKeyword("between"):
+		p.lexer.EatKeyword("between")
+		low, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lexer.EatKeyword("and"); err != nil {
+			return nil, err
+		}
+		high, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		return query.NewBetweenTerm(lhs, low, high), nil
+	case p.lexer.MatchKeyword("like"):
+		p.lexer.EatKeyword("like")
+		pattern, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		return query.NewLikeTerm(lhs, pattern), nil
+	}
+
+	if err := p.lexer.EatKeyword("in"); err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatDelim('('); err != nil {
+		return nil, err
+	}
+	if p.lexer.MatchKeyword("select") {
+		subquery, err := p.subqueryBody(true)
+		if err != nil {
+			return nil, err
+		}
+		return query.NewInTerm(lhs, subquery), nil
+	}
+	values, err := p.ExpressionList()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return nil, err
+	}
+	return query.NewInListTerm(lhs, values), nil
+}
+
+// Parses a comma-separated list of expressions, as in the list of an IN.
+// Corresponds to grammar rule: <ExpressionList> := <Expression> [ , <Expression> ]...
+func (p *Parser) ExpressionList() ([]*query.Expression, error) {
+	var list []*query.Expression
+	for {
+		e, err := p.Expression()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+		if !p.lexer.MatchDelim(',') {
+			return list, nil
+		}
+		p.lexer.EatDelim(',')
+	}
+}
+
+// Parses a query nested in a predicate. Its fields may be qualified by its
+// own tables or by those of the statements around it, whose fields a
+// correlated subquery refers to. A valued subquery's values are compared,
+// see query.Subquery.
+// Corresponds to grammar rule: <Subquery> := ( <Query> )
+func (p *Parser) Subquery(valued bool) (*query.Subquery, error) {
+	if err := p.lexer.EatDelim('('); err != nil {
+		return nil, err
+	}
+	return p.subqueryBody(valued)
+}
+
+// Parses the rest of a subquery whose opening parenthesis was just read
+func (p *Parser) subqueryBody(valued bool) (*query.Subquery, error) {
+	tables, outer, having := p.tables, p.outer, p.having
+	p.outer = append(slices.Clone(outer), tables...)
+	p.having = nil
+	data, err := p.Query()
+	p.tables, p.outer, p.having = tables, outer, having
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return nil, err
+	}
+	return query.NewSubquery(data, valued), nil
+}
 
-	return query.NewTerm(lhs, rhs)
+// Parses the operator comparing the two sides of a term.
+// Corresponds to grammar rule: <ComparisonOp> := = | <> | < | <= | > | >=
+func (p *Parser) ComparisonOp() (string, error) {
+	switch {
+	case p.lexer.MatchDelim('='):
+		p.lexer.EatDelim('=')
+		return query.OP_EQUALS, nil
+	case p.lexer.MatchDelim('<'):
+		p.lexer.EatDelim('<')
+		if p.lexer.MatchDelim('=') {
+			p.lexer.EatDelim('=')
+			return query.OP_LESS_EQUAL, nil
+		}
+		if p.lexer.MatchDelim('>') {
+			p.lexer.EatDelim('>')
+			return query.OP_NOT_EQUALS, nil
+		}
+		return query.OP_LESS, nil
+	case p.lexer.MatchDelim('>'):
+		p.lexer.EatDelim('>')
+		if p.lexer.MatchDelim('=') {
+			p.lexer.EatDelim('=')
+			return query.OP_GREATER_EQUAL, nil
+		}
+		return query.OP_GREATER, nil
+	default:
+		return "", p.lexer.expected("comparison operator")
+	}
 }
 
-// Parses a predicate, which is term optionally followed by "AND"
-// and another predicate. Returns a Predicate struct representing the boolean condition.
-// Corresponds to grammar rule: <Predicate> := <Term> [ AND <Predicate> ]
+// Parses a predicate, which is a conjunction optionally followed by "OR"
+// and further conjunctions. Returns a Predicate struct representing the boolean condition.
+// AND binds tighter than OR, and NOT tighter than both.
+// Corresponds to grammar rule: <Predicate> := <Conjunction> [ OR <Conjunction> ]...
 // Examples:
 //   - Simple predicate: "WHERE age = 25"
 //   - Compound predicate: "WHERE age = 25 AND name = 'John'"
 //   - Multiple conditions: "WHERE age = 25 AND salary > 50000 AND dept = 'IT'"
-func (p *Parser) Predicate() *query.Predicate {
-	pred := query.NewPredicateWithTerm(p.Term()) // Start with a single term
+//   - Alternatives: "WHERE dept = 'IT' OR dept = 'HR' AND salary > 50000"
+func (p *Parser) Predicate() (*query.Predicate, error) {
+	pred, err := p.Conjunction()
+	if err != nil {
+		return nil, err
+	}
+	if !p.lexer.MatchKeyword("or") {
+		return pred, nil
+	}
 
-	if p.lexer.MatchKeyword("and") {
-		// If "AND" follows, consume it and recursively parse another predicate
+	disjuncts := []*query.Predicate{pred}
+	for p.lexer.MatchKeyword("or") {
+		p.lexer.EatKeyword("or")
+		disjunct, err := p.Conjunction()
+		if err != nil {
+			return nil, err
+		}
+		disjuncts = append(disjuncts, disjunct)
+	}
+	return query.NewPredicateWithTerm(query.NewOrTerm(disjuncts...)), nil
+}
+
+// Parses boolean factors joined by "AND".
+// Corresponds to grammar rule: <Conjunction> := <BoolFactor> [ AND <BoolFactor> ]...
+// Example: "age = 25 AND NOT (dept = 'IT')"
+func (p *Parser) Conjunction() (*query.Predicate, error) {
+	pred, err := p.BoolFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.lexer.MatchKeyword("and") {
 		p.lexer.EatKeyword("and")
-		// Combine the cureent predicate with the next one using AND logic
-		pred.ConjoinWith(p.Predicate())
+		next, err := p.BoolFactor()
+		if err != nil {
+			return nil, err
+		}
+		// Combine the current predicate with the next one using AND logic
+		pred.ConjoinWith(next)
 	}
+	return pred, nil
+}
 
-	return pred
+// Parses a term, a negated boolean factor or a parenthesized predicate.
+// Corresponds to grammar rule: <BoolFactor> := <Term> | NOT <BoolFactor> | ( <Predicate> )
+// Examples: "age = 25", "NOT age = 25", "(age = 25 OR age = 30)"
+func (p *Parser) BoolFactor() (*query.Predicate, error) {
+	switch {
+	case p.lexer.MatchKeyword("not"):
+		p.lexer.EatKeyword("not")
+		operand, err := p.BoolFactor()
+		if err != nil {
+			return nil, err
+		}
+		return query.NewPredicateWithTerm(query.NewNotTerm(operand)), nil
+	case p.lexer.MatchDelim('('):
+		p.lexer.EatDelim('(')
+		pred, err := p.Predicate()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lexer.EatDelim(')'); err != nil {
+			return nil, err
+		}
+		return pred, nil
+	default:
+		term, err := p.Term()
+		if err != nil {
+			return nil, err
+		}
+		return query.NewPredicateWithTerm(term), nil
+	}
 }
 
 // -------- METHODS FOR PARSING QUERIES  ----------
 
-// Parses a complete SELECT query with optional WHERE clause.
-// Returns a QueryData struct containing fields, tables, and predicates.
-// Corresponds to grammar rule: <Query> := SELECT <SelectList> FROM <TableList> [ WHERE <Predicate> ]
+// Parses a complete SELECT query with optional AS OF, WHERE, GROUP BY, HAVING, UNION, ORDER BY and LIMIT clauses.
+// Returns a QueryData struct containing fields, tables, predicates, the grouping, the unions, the order and the limit.
+// Corresponds to grammar rule:
+// <Query> := <SelectQuery> [ UNION [ ALL ] <SelectQuery> ]... [ ORDER BY <OrderList> ] [ LIMIT <IntConstant> ]
 // Examples:
 //   - Simple query, "SELECT name, age, FROM employees"
 //   - With WHERE: "SELECT id, salary FROM employees WHERE dept = 'Sales'"
-//   - Multiple tables: "SELECT e.name, d.location FROM employees e, departments d WHERE e.dept_id = d.id"
-func (p *Parser) Query() *QueryData {
+//   - Multiple tables: "SELECT name, location FROM employees, departments WHERE dept_id = id"
+//   - Joined: "SELECT name, location FROM employees JOIN departments ON employees.dept_id = departments.id"
+//   - With LIMIT: "SELECT name FROM employees WHERE dept = 'Sales' LIMIT 10"
+//   - Ordered: "SELECT name, salary FROM employees ORDER BY salary DESC, name"
+//   - Computed: "SELECT name, salary * 12 AS yearly FROM employees ORDER BY yearly"
+//   - Grouped: "SELECT dept, count(*) FROM employees GROUP BY dept HAVING count(*) > 5"
+//   - Of the past: "SELECT name FROM employees AS OF TIMESTAMP '2024-05-01 12:00:00'"
+//   - Combined: "SELECT name FROM employees UNION SELECT name FROM contractors ORDER BY name"
+func (p *Parser) Query() (*QueryData, error) {
+	qd, err := p.selectQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse optional UNION clauses, each combining another query
+	var unions []*UnionData
+	for p.lexer.MatchKeyword("union") {
+		p.lexer.EatKeyword("union")
+		all := p.lexer.MatchKeyword("all")
+		if all {
+			p.lexer.EatKeyword("all")
+		}
+		operand, err := p.selectQuery()
+		if err != nil {
+			return nil, err
+		}
+		unions = append(unions, NewUnionData(operand, all))
+	}
+	qd.SetUnions(unions)
+
+	// Parse optional ORDER BY clause
+	if p.lexer.MatchKeyword("order") {
+		p.lexer.EatKeyword("order")
+		if err := p.lexer.EatKeyword("by"); err != nil {
+			return nil, err
+		}
+		orderBy, err := p.OrderList()
+		if err != nil {
+			return nil, err
+		}
+		qd.SetOrderBy(orderBy)
+	}
+
+	// Parse optional LIMIT clause
+	if p.lexer.MatchKeyword("limit") {
+		p.lexer.EatKeyword("limit")
+		limit, err := p.lexer.EatIntConstant()
+		if err != nil {
+			return nil, err
+		}
+		qd.SetLimit(limit)
+	}
+	return qd, nil
+}
+
+// Parses a SELECT query with its clauses up to HAVING, which is an operand of UNION.
+// Corresponds to grammar rule:
+// <SelectQuery> := SELECT <SelectList> FROM <TableList> [ <AsOf> ] [ WHERE <Predicate> ] [ GROUP BY <GroupList> ] [ <Having> ]
+func (p *Parser) selectQuery() (*QueryData, error) {
 	// Parse SELECT clause
-	p.lexer.EatKeyword("select")
-	fields := p.SelectList()
+	if err := p.lexer.EatKeyword("select"); err != nil {
+		return nil, err
+	}
+	fields, aggregates, computed, err := p.SelectList()
+	if err != nil {
+		return nil, err
+	}
 
 	// Parse FROM clause
-	p.lexer.EatKeyword("from")
-	tables := p.TableList()
+	if err := p.lexer.EatKeyword("from"); err != nil {
+		return nil, err
+	}
+	tables, joins, err := p.TableList()
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse optional AS OF clause
+	var asOf *AsOfData
+	if p.lexer.MatchKeyword("as") {
+		if asOf, err = p.AsOf(); err != nil {
+			return nil, err
+		}
+	}
 
 	// Parse optional WHERE clause
 	pred := query.NewPredicate()
 
 	if p.lexer.MatchKeyword("where") {
 		p.lexer.EatKeyword("where")
-		pred = p.Predicate()
+		if pred, err = p.Predicate(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Parse optional GROUP BY clause
+	var groupBy []string
+	if p.lexer.MatchKeyword("group") {
+		p.lexer.EatKeyword("group")
+		if err := p.lexer.EatKeyword("by"); err != nil {
+			return nil, err
+		}
+		if groupBy, err = p.GroupList(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Parse optional HAVING clause, computing the aggregates it applies
+	// along with those of the select list
+	having := query.NewPredicate()
+	if p.lexer.MatchKeyword("having") {
+		var havingAggregates []*AggregateData
+		if having, havingAggregates, err = p.Having(); err != nil {
+			return nil, err
+		}
+		for _, agg := range havingAggregates {
+			if !slices.ContainsFunc(aggregates, func(a *AggregateData) bool { return a.Name() == agg.Name() }) {
+				aggregates = append(aggregates, agg)
+			}
+		}
+	}
+
+	qd := NewQueryData(fields, tables, pred)
+	qd.SetJoins(joins)
+	qd.SetAggregates(aggregates)
+	qd.SetComputed(computed)
+	qd.SetAsOf(asOf)
+	qd.SetGroupBy(groupBy)
+	qd.SetHaving(having)
+	return qd, nil
+}
+
+// Parses the comma-separated fields of a GROUP BY clause.
+// Corresponds to grammar rule: <GroupList> := <Field> [ , <Field> ]...
+// Example: "GROUP BY dept, title"
+func (p *Parser) GroupList() ([]string, error) {
+	var fields []string
+	for len(fields) == 0 || p.lexer.MatchDelim(',') {
+		if len(fields) > 0 {
+			p.lexer.EatDelim(',')
+		}
+		field, err := p.QualifiedField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// Parses a HAVING clause, whose predicate filters the records of an
+// aggregated query after grouping. Its expressions may apply aggregates,
+// which are returned so that the query computes them.
+// Corresponds to grammar rule: <Having> := HAVING <Predicate>
+// Example: "HAVING count(*) > 5 AND max(salary) < 1000"
+func (p *Parser) Having() (*query.Predicate, []*AggregateData, error) {
+	if err := p.lexer.EatKeyword("having"); err != nil {
+		return nil, nil, err
+	}
+	var aggregates []*AggregateData
+	p.having = &aggregates
+	pred, err := p.Predicate()
+	p.having = nil
+	if err != nil {
+		return nil, nil, err
+	}
+	return pred, aggregates, nil
+}
+
+// Parses an EXPLAIN command, which asks for the plan of a query, and with
+// ANALYZE also runs it to report what it read and the temp space it took.
+// Corresponds to grammar rule: <Explain> := EXPLAIN [ ANALYZE ] <Query>
+func (p *Parser) Explain() (*ExplainData, error) {
+	if err := p.lexer.EatKeyword("explain"); err != nil {
+		return nil, err
+	}
+	analyze := p.lexer.MatchKeyword("analyze")
+	if analyze {
+		p.lexer.EatKeyword("analyze")
+	}
+	qd, err := p.Query()
+	if err != nil {
+		return nil, err
+	}
+	return NewExplainData(qd, analyze), nil
+}
+
+// Parses the comma-separated items of an ORDER BY clause, in priority order.
+// Corresponds to grammar rule: <OrderList> := <OrderItem> [ , <OrderItem> ]...
+func (p *Parser) OrderList() ([]*OrderData, error) {
+	var items []*OrderData
+	for len(items) == 0 || p.lexer.MatchDelim(',') {
+		if len(items) > 0 {
+			p.lexer.EatDelim(',')
+		}
+		item, err := p.OrderItem()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Parses a field of an ORDER BY clause, with its direction and where its nulls go.
+// Fields are in ascending order unless declared DESC.
+// Corresponds to grammar rule: <OrderItem> := <Field> [ ASC | DESC ] [ NULLS ( FIRST | LAST ) ]
+// Example: "salary DESC NULLS LAST"
+func (p *Parser) OrderItem() (*OrderData, error) {
+	field, err := p.Field()
+	if err != nil {
+		return nil, err
 	}
 
-	return NewQueryData(fields, tables, pred)
+	descending := false
+	if p.lexer.MatchKeyword("asc") {
+		p.lexer.EatKeyword("asc")
+	} else if p.lexer.MatchKeyword("desc") {
+		p.lexer.EatKeyword("desc")
+		descending = true
+	}
+
+	nulls := NULLS_DEFAULT
+	if p.lexer.MatchKeyword("nulls") {
+		p.lexer.EatKeyword("nulls")
+		if p.lexer.MatchKeyword("first") {
+			p.lexer.EatKeyword("first")
+			nulls = NULLS_FIRST
+		} else {
+			if err := p.lexer.EatKeyword("last"); err != nil {
+				return nil, err
+			}
+			nulls = NULLS_LAST
+		}
+	}
+	return NewOrderData(field, descending, nulls), nil
+}
+
+// Parses the past point in the history of the database a query reads.
+// Corresponds to grammar rule: <AsOf> := AS OF ( IntConstant | TIMESTAMP StrConstant )
+// Examples:
+//   - Log sequence number: "AS OF 4812"
+//   - Time: "AS OF TIMESTAMP '2024-05-01 12:00:00'"
+func (p *Parser) AsOf() (*AsOfData, error) {
+	if err := p.lexer.EatKeyword("as"); err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("of"); err != nil {
+		return nil, err
+	}
+	pos := p.lexer.position()
+	if !p.lexer.MatchKeyword("timestamp") {
+		lsn, err := p.lexer.EatIntConstant()
+		if err != nil {
+			return nil, err
+		}
+		if lsn <= 0 {
+			return nil, p.lexer.errorAt(pos, "the LSN of AS OF must be positive")
+		}
+		return NewAsOfLSN(lsn), nil
+	}
+
+	p.lexer.EatKeyword("timestamp")
+	pos = p.lexer.position()
+	s, err := p.lexer.EatStringConstant()
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseTimestamp(s)
+	if err != nil {
+		return nil, p.lexer.errorAt(pos, "%v", err)
+	}
+	return NewAsOfTime(t), nil
 }
 
 // Parses a comma-seperated list of fields to be retrieved.
-// Returns a slice of field name strings.
-// Corresponds to grammar rule: <SelectList> := <Field> [ , <SelectList> ]
+// Returns a slice of output field name strings, and the aggregates and
+// computed fields among them.
+// Corresponds to grammar rule: <SelectList> := <SelectItem> [ , <SelectList> ]
 // Examples:
 //   - Single field: "SELECT name FROM employees"
 //   - Multiple fields: "SELECT id, name, salary FROM employees"
+//   - Aggregates: "SELECT count(*), max(salary) FROM employees"
+//   - Computed: "SELECT name, salary * 12 AS yearly FROM employees"
 //   - ALL fields: "SELECT * FROM employees" (handled by lexer as special field)
-func (p *Parser) SelectList() []string {
+func (p *Parser) SelectList() ([]string, []*AggregateData, []*ComputedData, error) {
 	var fields []string
+	var aggregates []*AggregateData
+	var computed []*ComputedData
 
-	fields = append(fields, p.Field()) // Parse the first field
+	// Parse the first item
+	field, agg, cd, err := p.SelectItem()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	fields = append(fields, field)
+	if agg != nil {
+		aggregates = append(aggregates, agg)
+	}
+	if cd != nil {
+		computed = append(computed, cd)
+	}
 
 	if p.lexer.MatchDelim(',') {
 		// If a comma follows, consume it an recursively parse the rest of the list
 		p.lexer.EatDelim(',')
 		// Append all fields from recursive call to current list
-		fields = append(fields, p.SelectList()...)
+		moreFields, moreAggregates, moreComputed, err := p.SelectList()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fields = append(fields, moreFields...)
+		aggregates = append(aggregates, moreAggregates...)
+		computed = append(computed, moreComputed...)
+	}
+
+	return fields, aggregates, computed, nil
+}
+
+// Parses a field, an aggregate function or a computed field of a select list.
+// Returns the name of the output field, and the aggregate or the computed
+// field if it is one. A computed field is named by its alias, or else by the
+// text of its expression; a field given an alias is computed too.
+// Corresponds to grammar rule:
+// <SelectItem> := <Aggregate> | <ValueExpression> [ AS IdTok ]
+// <Aggregate> := count(*) | min(<Field>) | max(<Field>) | approx_count_distinct(<Field>)
+// Examples: "salary", "max(salary)", "price * qty AS total", "upper(name)", "1"
+func (p *Parser) SelectItem() (string, *AggregateData, *ComputedData, error) {
+	var expr *query.Expression
+	if p.lexer.MatchId() && !p.lexer.MatchKeyword("case") {
+		pos := p.lexer.position()
+		name, err := p.Field()
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if p.lexer.MatchDelim('(') {
+			if _, ok := query.LookupFunction(name); !ok {
+				agg, err := p.aggregate(name, pos)
+				if err != nil {
+					return "", nil, nil, err
+				}
+				return agg.Name(), agg, nil, nil
+			}
+			if expr, err = p.FunctionCall(name); err != nil {
+				return "", nil, nil, err
+			}
+		} else {
+			expr = query.NewExpressionFieldName(name)
+		}
+
+		// The field or function call may be the first operand of an expression
+		if expr, err = p.product(expr); err != nil {
+			return "", nil, nil, err
+		}
+		if expr, err = p.sum(expr); err != nil {
+			return "", nil, nil, err
+		}
+	} else {
+		var err error
+		if expr, err = p.ValueExpression(); err != nil {
+			return "", nil, nil, err
+		}
 	}
 
-	return fields
+	name := expr.String()
+	if p.lexer.MatchKeyword("as") {
+		p.lexer.EatKeyword("as")
+		alias, err := p.lexer.EatId()
+		if err != nil {
+			return "", nil, nil, err
+		}
+		name = alias
+	} else if expr.IsFieldName() {
+		return name, nil, nil, nil
+	}
+	cd := NewComputedData(name, expr)
+	return cd.Name(), nil, cd, nil
 }
 
-// Parses a comma-seperated list of table names.
-// Returns a slice of table name strings.
-// Corresponds to grammar rule: <TableList> := IdTok [ , <TableList> ]
+// Parses the argument of a call to the named aggregate function, whose name
+// was just read at pos
+func (p *Parser) aggregate(name string, pos scanner.Position) (*AggregateData, error) {
+	p.lexer.EatDelim('(')
+	var agg *AggregateData
+	switch strings.ToLower(name) {
+	case AGG_COUNT:
+		if err := p.lexer.EatDelim('*'); err != nil {
+			return nil, err
+		}
+		agg = NewAggregateData(AGG_COUNT, "")
+	case AGG_MIN, AGG_MAX, AGG_APPROX_COUNT_DISTINCT:
+		field, err := p.Field()
+		if err != nil {
+			return nil, err
+		}
+		agg = NewAggregateData(name, field)
+	default:
+		return nil, p.lexer.errorAt(pos, "unknown aggregate function %s", name)
+	}
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return nil, err
+	}
+	return agg, nil
+}
+
+// Parses the tables of a FROM clause, separated by commas or joined with JOIN ... ON.
+// Returns the names of all the tables, along with the joins in the order they appear.
+// Only inner joins are supported.
+// Corresponds to grammar rule: <TableList> := IdTok [ , IdTok | [ INNER ] JOIN IdTok ON <Predicate> ]...
 // Examples:
 //   - Single table: "FROM employees"
 //   - Multiple tables: "FROM employees, departments"
-//   - With aliases: "FROM employees e, departments d"
-func (p *Parser) TableList() []string {
-	var tables []string
-	tables = append(tables, p.lexer.EatId()) // Parse the first table name
-
-	if p.lexer.MatchDelim(',') {
-		// If a comma follows, consume it and recursively parse the rest of the list
-		p.lexer.EatDelim(',')
-		// Append all tables from recursive call to the current list
-		tables = append(tables, p.TableList()...)
+//   - Joined: "FROM employees JOIN departments ON employees.dept_id = departments.id"
+func (p *Parser) TableList() ([]string, []*JoinData, error) {
+	// Parse the first table name
+	table, err := p.lexer.EatId()
+	if err != nil {
+		return nil, nil, err
+	}
+	p.tables = []string{table}
+	var joins []*JoinData
+
+	for {
+		switch {
+		case p.lexer.MatchDelim(','):
+			p.lexer.EatDelim(',')
+			table, err := p.lexer.EatId()
+			if err != nil {
+				return nil, nil, err
+			}
+			p.tables = append(p.tables, table)
+		case p.lexer.MatchKeyword("left") || p.lexer.MatchKeyword("right") || p.lexer.MatchKeyword("full"):
+			return nil, nil, p.lexer.errorf("outer joins are not supported")
+		case p.lexer.MatchKeyword("inner") || p.lexer.MatchKeyword("join"):
+			join, err := p.Join()
+			if err != nil {
+				return nil, nil, err
+			}
+			joins = append(joins, join)
+		default:
+			return p.tables, joins, nil
+		}
 	}
+}
 
-	return tables
+// Parses a table joined to the tables before it. The ON predicate may refer
+// to the fields of the joined table and of the tables before it.
+// Corresponds to grammar rule: <Join> := [ INNER ] JOIN IdTok ON <Predicate>
+// Example: "JOIN departments ON dept_id = id"
+func (p *Parser) Join() (*JoinData, error) {
+	if p.lexer.MatchKeyword("inner") {
+		p.lexer.EatKeyword("inner")
+	}
+	if err := p.lexer.EatKeyword("join"); err != nil {
+		return nil, err
+	}
+	table, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	p.tables = append(p.tables, table)
+	if err := p.lexer.EatKeyword("on"); err != nil {
+		return nil, err
+	}
+	on, err := p.Predicate()
+	if err != nil {
+		return nil, err
+	}
+	return NewJoinData(table, on), nil
 }
 
 // -------- METHODS FOR PARSING VARIOUS UPDATE COMMANDS  ----------
@@ -187,18 +1148,53 @@ func (p *Parser) TableList() []string {
 //   - "DELETE FROM users WHERE id = 1" -> DeleteData
 //   - "UPDATE users SET age = 30 WHERE id = 1" -> ModifyData
 //   - "CREATE TABLE users (...)" -> CreateTableData
-func (p *Parser) UpdateCmd() interface{} {
+//   - "COPY users FROM 'users.csv'" -> CopyData
+//   - "COPY (SELECT ...) TO 'users.csv'" -> CopyToData
+//   - "GRANT SELECT ON users TO alice" -> GrantData
+//   - "ALTER TABLE users ADD age INT" -> AddColumnData
+//   - "ANALYZE users" -> AnalyzeData
+func (p *Parser) UpdateCmd() (interface{}, error) {
 	if p.lexer.MatchKeyword("insert") {
 		return p.Insert()
+	} else if p.lexer.MatchKeyword("copy") {
+		return p.Copy()
 	} else if p.lexer.MatchKeyword("delete") {
 		return p.Delete()
 	} else if p.lexer.MatchKeyword("update") {
 		return p.Modify()
+	} else if p.lexer.MatchKeyword("alter") {
+		return p.Alter()
+	} else if p.lexer.MatchKeyword("grant") {
+		return p.Grant()
+	} else if p.lexer.MatchKeyword("revoke") {
+		return p.Revoke()
+	} else if p.lexer.MatchKeyword("drop") {
+		return p.Drop()
+	} else if p.lexer.MatchKeyword("analyze") {
+		return p.Analyze()
 	} else {
 		return p.Create()
 	}
 }
 
+// Parses an ANALYZE command, which gathers the statistics of a table,
+// or of every table if none is given.
+// Corresponds to grammar rule:
+// <Analyze> := ANALYZE [ IdTok ]
+func (p *Parser) Analyze() (*AnalyzeData, error) {
+	if err := p.lexer.EatKeyword("analyze"); err != nil {
+		return nil, err
+	}
+	if !p.lexer.MatchId() {
+		return NewAnalyzeData(""), nil
+	}
+	tableName, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	return NewAnalyzeData(tableName), nil
+}
+
 // Parses CREATE command (TABLE, VIEW, INDEX)
 // Returns appropriate data struct based on the specific create command.
 // Corresponds to grammar rules fpr differnet CREATE statements.
@@ -206,8 +1202,12 @@ func (p *Parser) UpdateCmd() interface{} {
 //   - "CREATE TABLE users (id INT, name VARCHAR(20))"
 //   - "CREATE VIEW active_users AS SELECT * FROM users WHERE status = 'active'"
 //   - "CREATE INDEX idx_user_name On users(name)"
-func (p *Parser) Create() interface{} {
-	p.lexer.EatKeyword("create") // Consume the CREATE keyword
+//   - "CREATE UNIQUE INDEX idx_user_email ON users(email)"
+func (p *Parser) Create() (interface{}, error) {
+	// Consume the CREATE keyword
+	if err := p.lexer.EatKeyword("create"); err != nil {
+		return nil, err
+	}
 
 	if p.lexer.MatchKeyword("table") {
 		// Parse a CREATE TABLE statement
@@ -215,6 +1215,9 @@ func (p *Parser) Create() interface{} {
 	} else if p.lexer.MatchKeyword("view") {
 		// Parse a CREATE VIEW statement
 		return p.CreateView()
+	} else if p.lexer.MatchKeyword("user") {
+		// Parse a CREATE USER statement
+		return p.CreateUser()
 	} else {
 		// Assume it's a CREATE INDEX statement
 		return p.CreateIndex()
@@ -230,11 +1233,17 @@ func (p *Parser) Create() interface{} {
 //   - Simple delete: "DELETE FROM users"
 //   - With condition: "DELETE FROM users WHERE age < 18"
 //   - Multiple conditions: "DELETE FROM users WHERE age < 18 AND status = 'inactive'"
-func (p *Parser) Delete() *DeleteData {
+func (p *Parser) Delete() (*DeleteData, error) {
 	p.lexer.EatKeyword("delete") // Consume DELETE keyword
-	p.lexer.EatKeyword("from")   // Consume FROM keyword
+	if err := p.lexer.EatKeyword("from"); err != nil {
+		return nil, err
+	}
 
-	tableName := p.lexer.EatId() // Parse and store the table name
+	tableName, err := p.lexer.EatId() // Parse and store the table name
+	if err != nil {
+		return nil, err
+	}
+	p.tables = []string{tableName}
 
 	// Initialize an empty predicate (no WHERE clause)
 	pred := query.NewPredicate()
@@ -242,36 +1251,612 @@ func (p *Parser) Delete() *DeleteData {
 	if p.lexer.MatchKeyword("where") {
 		// If WHERE keyword is present, parse the predicate
 		p.lexer.EatKeyword("where")
-		pred = p.Predicate()
+		if pred, err = p.Predicate(); err != nil {
+			return nil, err
+		}
 	}
 
 	// Create and return a DeleteData object
-	return NewDeleteData(tableName, pred)
+	return NewDeleteData(tableName, pred), nil
+}
+
+// -------- METHODS FOR PARSING COPY COMMANDS  ----------
+
+// Parses a COPY command, which either loads a file into a table
+// or exports the result of a query to a file.
+// Returns a CopyData or CopyToData struct respectively.
+// Corresponds to grammar rule: <Copy> := COPY <CopyFrom> | COPY <CopyTo>
+func (p *Parser) Copy() (interface{}, error) {
+	p.lexer.EatKeyword("copy") // Consume COPY keyword
+
+	if p.lexer.MatchDelim('(') {
+		// A parenthesized query is exported rather than loaded
+		return p.CopyTo()
+	}
+	return p.CopyFrom()
+}
+
+// Parses the rest of a COPY command which bulk loads a CSV file into a table.
+// Returns a CopyData struct representing the load.
+// Corresponds to grammar rule: <CopyFrom> := IdTok [ ( <FieldList> ) ] FROM StrTok [ WITH ( <Options> ) ]
+// Examples:
+//   - "COPY users FROM 'users.csv'"
+//   - "COPY users (id, name) FROM 'users.csv' WITH (header=on, batchsize=5000)"
+func (p *Parser) CopyFrom() (*CopyData, error) {
+	tableName, err := p.lexer.EatId() // Parse and store the table name
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	if p.lexer.MatchDelim('(') {
+		// Parse the optional list of target fields
+		p.lexer.EatDelim('(')
+		if fields, err = p.FieldList(); err != nil {
+			return nil, err
+		}
+		if err := p.lexer.EatDelim(')'); err != nil {
+			return nil, err
+		}
+	}
+
+	// Consume FROM keyword
+	if err := p.lexer.EatKeyword("from"); err != nil {
+		return nil, err
+	}
+	fileName, err := p.lexer.EatStringConstant() // Parse the path of the file
+	if err != nil {
+		return nil, err
+	}
+
+	var options map[string]string
+	if p.lexer.MatchKeyword("with") {
+		// Parse the load options
+		p.lexer.EatKeyword("with")
+		if options, err = p.parenthesizedOptions(); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewCopyData(tableName, fields, fileName, options), nil
+}
+
+// Parses the rest of a COPY command which exports the result of a query.
+// Returns a CopyToData struct representing the export.
+// Corresponds to grammar rule: <CopyTo> := ( <Query> ) TO StrTok [ FORMAT IdTok ]
+// Examples:
+//   - "COPY (SELECT id, name FROM users) TO 'users.csv'"
+//   - "COPY (SELECT id FROM users WHERE name = 'joe') TO 'joe.json' FORMAT JSON"
+func (p *Parser) CopyTo() (*CopyToData, error) {
+	if err := p.lexer.EatDelim('('); err != nil {
+		return nil, err
+	}
+	query, err := p.Query() // Parse the query whose result is exported
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return nil, err
+	}
+
+	// Consume TO keyword
+	if err := p.lexer.EatKeyword("to"); err != nil {
+		return nil, err
+	}
+	fileName, err := p.lexer.EatStringConstant() // Parse the path of the file
+	if err != nil {
+		return nil, err
+	}
+
+	format := ""
+	if p.lexer.MatchKeyword("format") {
+		// Parse the optional output format
+		p.lexer.EatKeyword("format")
+		name, err := p.lexer.EatId()
+		if err != nil {
+			return nil, err
+		}
+		format = strings.ToLower(name)
+	}
+
+	return NewCopyToData(query, fileName, format), nil
+}
+
+// -------- METHODS FOR PARSING USER COMMANDS  ----------
+
+// Parses a CREATE USER command.
+// Returns a CreateUserData struct representing the new user.
+// Corresponds to grammar rule: <CreateUser> := CREATE USER IdTok WITH PASSWORD StrTok [ SUPERUSER ]
+// Example: "CREATE USER alice WITH PASSWORD 's3cret'"
+func (p *Parser) CreateUser() (*CreateUserData, error) {
+	if err := p.lexer.EatKeyword("user"); err != nil {
+		return nil, err
+	}
+	userName, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	password, err := p.Password()
+	if err != nil {
+		return nil, err
+	}
+
+	superuser := false
+	if p.lexer.MatchKeyword("superuser") {
+		p.lexer.EatKeyword("superuser")
+		superuser = true
+	}
+
+	return NewCreateUserData(userName, password, superuser), nil
+}
+
+// Parses an ALTER command.
+// Corresponds to grammar rule: <Alter> := ALTER <AlterTable> | ALTER <AlterUser>
+func (p *Parser) Alter() (interface{}, error) {
+	p.lexer.EatKeyword("alter") // Consume ALTER keyword
+	if p.lexer.MatchKeyword("table") {
+		return p.AlterTable()
+	}
+	return p.AlterUser()
+}
+
+// Parses the rest of an ALTER TABLE command, which adds or drops a field.
+// Corresponds to grammar rule:
+// <AlterTable> := TABLE IdTok ADD [ COLUMN ] <FieldDef> | TABLE IdTok DROP [ COLUMN ] IdTok
+// Examples: "ALTER TABLE emp ADD COLUMN age int", "ALTER TABLE emp DROP age"
+func (p *Parser) AlterTable() (interface{}, error) {
+	p.lexer.EatKeyword("table")
+	tableName, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.lexer.MatchKeyword("add"):
+		p.lexer.EatKeyword("add")
+		p.column()
+		field, err := p.FieldDef()
+		if err != nil {
+			return nil, err
+		}
+		return NewAddColumnData(tableName, field), nil
+	case p.lexer.MatchKeyword("drop"):
+		p.lexer.EatKeyword("drop")
+		p.column()
+		fieldName, err := p.Field()
+		if err != nil {
+			return nil, err
+		}
+		return NewDropColumnData(tableName, fieldName), nil
+	default:
+		return nil, p.lexer.expected("ADD or DROP")
+	}
+}
+
+// Consumes an optional COLUMN keyword
+func (p *Parser) column() {
+	if p.lexer.MatchKeyword("column") {
+		p.lexer.EatKeyword("column")
+	}
+}
+
+// Parses the rest of an ALTER USER command.
+// Returns an AlterUserData struct holding the user's new password.
+// Corresponds to grammar rule: <AlterUser> := USER IdTok WITH PASSWORD StrTok
+// Example: "ALTER USER alice WITH PASSWORD 'n3w'"
+func (p *Parser) AlterUser() (*AlterUserData, error) {
+	if err := p.lexer.EatKeyword("user"); err != nil {
+		return nil, err
+	}
+	userName, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	password, err := p.Password()
+	if err != nil {
+		return nil, err
+	}
+	return NewAlterUserData(userName, password), nil
+}
+
+// Parses the password clause of a user command.
+// Corresponds to grammar rule: <Password> := WITH PASSWORD StrTok
+func (p *Parser) Password() (string, error) {
+	if err := p.lexer.EatKeyword("with"); err != nil {
+		return "", err
+	}
+	if err := p.lexer.EatKeyword("password"); err != nil {
+		return "", err
+	}
+	return p.lexer.EatStringConstant()
+}
+
+// -------- METHODS FOR PARSING PRIVILEGE COMMANDS  ----------
+
+// The privileges that can be named in GRANT and REVOKE
+var privilegeKeywords = []string{"select", "insert", "update", "delete", "alter"}
+
+// Parses a GRANT command.
+// Returns a GrantData struct naming the privileges, the table and the user.
+// Corresponds to grammar rule: <Grant> := GRANT <Privileges> ON IdTok TO IdTok
+// Example: "GRANT SELECT, INSERT ON users TO alice"
+func (p *Parser) Grant() (*GrantData, error) {
+	p.lexer.EatKeyword("grant")
+	privileges, tableName, err := p.privilegesOn()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("to"); err != nil {
+		return nil, err
+	}
+	userName, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	return NewGrantData(privileges, tableName, userName), nil
+}
+
+// Parses a REVOKE command.
+// Returns a RevokeData struct naming the privileges, the table and the user.
+// Corresponds to grammar rule: <Revoke> := REVOKE <Privileges> ON IdTok FROM IdTok
+// Example: "REVOKE ALL ON users FROM alice"
+func (p *Parser) Revoke() (*RevokeData, error) {
+	p.lexer.EatKeyword("revoke")
+	privileges, tableName, err := p.privilegesOn()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("from"); err != nil {
+		return nil, err
+	}
+	userName, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	return NewRevokeData(privileges, tableName, userName), nil
+}
+
+// Parses the privileges of a GRANT or REVOKE command and the table they are on.
+// Corresponds to grammar rule: <Privileges> ON IdTok
+func (p *Parser) privilegesOn() ([]string, string, error) {
+	privileges, err := p.Privileges()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := p.lexer.EatKeyword("on"); err != nil {
+		return nil, "", err
+	}
+	tableName, err := p.lexer.EatId()
+	if err != nil {
+		return nil, "", err
+	}
+	return privileges, tableName, nil
+}
+
+// Parses the privileges of a GRANT or REVOKE command.
+// ALL is returned as the single privilege "all".
+// Corresponds to grammar rule: <Privileges> := ALL [ PRIVILEGES ] | <Privilege> [ , <Privileges> ]
+func (p *Parser) Privileges() ([]string, error) {
+	if p.lexer.MatchKeyword("all") {
+		p.lexer.EatKeyword("all")
+		if p.lexer.MatchKeyword("privileges") {
+			p.lexer.EatKeyword("privileges")
+		}
+		return []string{"all"}, nil
+	}
+
+	priv, err := p.Privilege()
+	if err != nil {
+		return nil, err
+	}
+	privileges := []string{priv}
+	for p.lexer.MatchDelim(',') {
+		p.lexer.EatDelim(',')
+		priv, err := p.Privilege()
+		if err != nil {
+			return nil, err
+		}
+		privileges = append(privileges, priv)
+	}
+	return privileges, nil
+}
+
+// Parses a single privilege.
+// Corresponds to grammar rule: <Privilege> := SELECT | INSERT | UPDATE | DELETE | ALTER
+func (p *Parser) Privilege() (string, error) {
+	for _, priv := range privilegeKeywords {
+		if p.lexer.MatchKeyword(priv) {
+			p.lexer.EatKeyword(priv)
+			return priv, nil
+		}
+	}
+	return "", p.lexer.expected("privilege")
+}
+
+// -------- METHODS FOR PARSING SESSION COMMANDS  ----------
+
+// Parses a command that changes or shows a setting of the current session,
+// selects or creates a database, or controls the session's transaction.
+// Returns a SetData or ShowData struct respectively, and so on.
+// Corresponds to grammar rule:
+// <SessionCmd> := <Set> | <Show> | <Describe> | <Use> | <CreateDatabase> | <TxControl>
+func (p *Parser) SessionCmd() (interface{}, error) {
+	for _, keyword := range []string{"begin", "start", "commit", "rollback"} {
+		if p.lexer.MatchKeyword(keyword) {
+			return p.TxControl()
+		}
+	}
+	if p.lexer.MatchKeyword("show") {
+		return p.Show()
+	}
+	if p.lexer.MatchKeyword("describe") || p.lexer.MatchKeyword("desc") {
+		return p.Describe()
+	}
+	if p.lexer.MatchKeyword("use") {
+		return p.Use()
+	}
+	if p.lexer.MatchKeyword("create") {
+		return p.CreateDatabase()
+	}
+	return p.Set()
+}
+
+// Parses a command that starts or ends the session's transaction.
+// Returns a BeginData, CommitData or RollbackData struct.
+// Corresponds to grammar rule:
+// <TxControl> := BEGIN [ WORK | TRANSACTION ] [ <Isolation> ] | START TRANSACTION [ <Isolation> ]
+// | COMMIT [ WORK ] | ROLLBACK [ WORK ]
+// Examples:
+//   - "BEGIN"
+//   - "START TRANSACTION ISOLATION LEVEL READ COMMITTED"
+func (p *Parser) TxControl() (interface{}, error) {
+	switch {
+	case p.lexer.MatchKeyword("begin"):
+		p.lexer.EatKeyword("begin")
+		if p.lexer.MatchKeyword("work") {
+			p.lexer.EatKeyword("work")
+		} else if p.lexer.MatchKeyword("transaction") {
+			p.lexer.EatKeyword("transaction")
+		}
+		return p.beginIsolation()
+	case p.lexer.MatchKeyword("start"):
+		p.lexer.EatKeyword("start")
+		if err := p.lexer.EatKeyword("transaction"); err != nil {
+			return nil, err
+		}
+		return p.beginIsolation()
+	case p.lexer.MatchKeyword("commit"):
+		p.lexer.EatKeyword("commit")
+		if p.lexer.MatchKeyword("work") {
+			p.lexer.EatKeyword("work")
+		}
+		return NewCommitData(), nil
+	}
+
+	if err := p.lexer.EatKeyword("rollback"); err != nil {
+		return nil, err
+	}
+	if p.lexer.MatchKeyword("work") {
+		p.lexer.EatKeyword("work")
+	}
+	return NewRollbackData(), nil
+}
+
+// Parses the isolation level that may end a BEGIN command.
+// Corresponds to grammar rule:
+// <Isolation> := ISOLATION LEVEL ( SERIALIZABLE | REPEATABLE READ | READ COMMITTED | SNAPSHOT )
+func (p *Parser) beginIsolation() (*BeginData, error) {
+	if !p.lexer.MatchKeyword("isolation") {
+		return NewBeginData(), nil
+	}
+	p.lexer.EatKeyword("isolation")
+	if err := p.lexer.EatKeyword("level"); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.lexer.MatchKeyword("serializable"):
+		p.lexer.EatKeyword("serializable")
+		return NewBeginDataWithIsolation("serializable"), nil
+	case p.lexer.MatchKeyword("snapshot"):
+		p.lexer.EatKeyword("snapshot")
+		return NewBeginDataWithIsolation("snapshot"), nil
+	case p.lexer.MatchKeyword("repeatable"):
+		p.lexer.EatKeyword("repeatable")
+		if err := p.lexer.EatKeyword("read"); err != nil {
+			return nil, err
+		}
+		return NewBeginDataWithIsolation("repeatable read"), nil
+	default:
+		if err := p.lexer.EatKeyword("read"); err != nil {
+			return nil, err
+		}
+		if err := p.lexer.EatKeyword("committed"); err != nil {
+			return nil, err
+		}
+		return NewBeginDataWithIsolation("read committed"), nil
+	}
+}
+
+// Parses a SET command.
+// Returns a SetData struct holding the setting name and its new value.
+// Corresponds to grammar rule: <Set> := SET IdTok ( = | TO ) ( IdTok | StrTok | IntTok )
+// Examples:
+//   - "SET lock_timeout = '5s'"
+//   - "SET isolation_level TO serializable"
+func (p *Parser) Set() (*SetData, error) {
+	// Consume SET keyword
+	if err := p.lexer.EatKeyword("set"); err != nil {
+		return nil, err
+	}
+	name, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.lexer.MatchKeyword("to") {
+		p.lexer.EatKeyword("to")
+	} else if err := p.lexer.EatDelim('='); err != nil {
+		return nil, err
+	}
+
+	var value string
+	if p.lexer.MatchStringConstant() {
+		value, err = p.lexer.EatStringConstant()
+	} else if p.lexer.MatchIntConstant() {
+		var n int
+		n, err = p.lexer.EatIntConstant()
+		value = strconv.Itoa(n)
+	} else {
+		value, err = p.lexer.EatId()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSetData(strings.ToLower(name), value), nil
+}
+
+// Parses a SHOW command.
+// Returns a ShowData struct holding the name of the setting to show,
+// or a ShowDatabasesData or ShowTablesData struct for SHOW DATABASES
+// and SHOW TABLES.
+// Corresponds to grammar rule: <Show> := SHOW ( IdTok | DATABASES | TABLES )
+// Examples:
+//   - "SHOW lock_timeout"
+//   - "SHOW DATABASES"
+func (p *Parser) Show() (interface{}, error) {
+	p.lexer.EatKeyword("show") // Consume SHOW keyword
+	if p.lexer.MatchKeyword("databases") {
+		p.lexer.EatKeyword("databases")
+		return NewShowDatabasesData(), nil
+	}
+	if p.lexer.MatchKeyword("tables") {
+		p.lexer.EatKeyword("tables")
+		return NewShowTablesData(), nil
+	}
+	name, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	return NewShowData(strings.ToLower(name)), nil
+}
+
+// Parses a DESCRIBE command.
+// Corresponds to grammar rule: <Describe> := ( DESCRIBE | DESC ) IdTok
+// Example: "DESCRIBE students"
+func (p *Parser) Describe() (*DescribeData, error) {
+	if p.lexer.MatchKeyword("desc") {
+		p.lexer.EatKeyword("desc")
+	} else if err := p.lexer.EatKeyword("describe"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	return NewDescribeData(tableName), nil
+}
+
+// Parses a USE command.
+// Corresponds to grammar rule: <Use> := USE IdTok
+// Example: "USE sales"
+func (p *Parser) Use() (*UseData, error) {
+	// Consume USE keyword
+	if err := p.lexer.EatKeyword("use"); err != nil {
+		return nil, err
+	}
+	name, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	return NewUseData(strings.ToLower(name)), nil
+}
+
+// Parses a CREATE DATABASE command.
+// Corresponds to grammar rule: <CreateDatabase> := CREATE DATABASE IdTok
+// Example: "CREATE DATABASE sales"
+func (p *Parser) CreateDatabase() (*CreateDatabaseData, error) {
+	// Consume CREATE and DATABASE keywords
+	if err := p.lexer.EatKeyword("create"); err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("database"); err != nil {
+		return nil, err
+	}
+	name, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	return NewCreateDatabaseData(strings.ToLower(name)), nil
 }
 
 // -------- METHODS FOR PARSING INSERT COMMANDS  ----------
 
 // Parses an INSERT command.
 // Returns an InsertData struct representing the insert operation.
-// Corresponds to grammar rule: <Insert> := INSERT INTO IdTok ( <FieldList> ) VALUES ( <ConstList> )
+// Corresponds to grammar rule: <Insert> := INSERT INTO IdTok ( <FieldList> ) VALUES <RowList>
 //   - "CREATE TABLE users (id INT, name VARCHAR(20))"
 //   - "CREATE VIEW active_users AS SELECT * FROM users WHERE status = 'active'"
 //   - "CREATE INDEX idx_user_name ON users(name)"
-func (p *Parser) Insert() *InsertData {
+func (p *Parser) Insert() (*InsertData, error) {
 	p.lexer.EatKeyword("insert") // Consume INSERT keyword
-	p.lexer.EatKeyword("into")   // Consume INTO keyword
-	tableName := p.lexer.EatId() // Parse and store the table name
+	if err := p.lexer.EatKeyword("into"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.lexer.EatId() // Parse and store the table name
+	if err != nil {
+		return nil, err
+	}
 
-	p.lexer.EatDelim('(')   // Consume opening parenthesis
-	fields := p.FieldList() // Parse the list of field names
-	p.lexer.EatDelim(')')   // Consume closing parenthesis
+	// Parse the parenthesized list of field names
+	if err := p.lexer.EatDelim('('); err != nil {
+		return nil, err
+	}
+	fields, err := p.FieldList()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return nil, err
+	}
 
-	p.lexer.EatKeyword("values") // Consume VALUES keyword
-	p.lexer.EatDelim('(')        // Consume opening parenthesis
-	values := p.ConstList()      // Parse the list of constant values
-	p.lexer.EatDelim(')')        // Consume closing parenthesis
+	// Parse VALUES and the parenthesized lists of constant values
+	if err := p.lexer.EatKeyword("values"); err != nil {
+		return nil, err
+	}
+	rows, err := p.RowList()
+	if err != nil {
+		return nil, err
+	}
 
-	return NewInsertData(tableName, fields, values)
+	return NewInsertDataWithRows(tableName, fields, rows), nil
+}
+
+// Parses the comma-separated value lists of an INSERT statement, one for
+// each record inserted.
+// Corresponds to grammar rule: <RowList> := ( <ConstList> ) [ , <RowList> ]
+// Example: "(1, 'ann'), (2, 'bo')"
+func (p *Parser) RowList() ([][]*types.Constant, error) {
+	var rows [][]*types.Constant
+	for {
+		if err := p.lexer.EatDelim('('); err != nil {
+			return nil, err
+		}
+		values, err := p.ConstList()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lexer.EatDelim(')'); err != nil {
+			return nil, err
+		}
+		rows = append(rows, values)
+
+		if !p.lexer.MatchDelim(',') {
+			return rows, nil
+		}
+		p.lexer.EatDelim(',')
+	}
 }
 
 // Parses a comma-seperated list of field names.
@@ -282,18 +1867,25 @@ func (p *Parser) Insert() *InsertData {
 //   - Single field: "(id)"
 //   - Multiple fields: "(id, name, age)"
 //   - With spaces: "( id , name , age )"
-func (p *Parser) FieldList() []string {
-	var fields []string
-	fields = append(fields, p.Field()) // Parse the first field
+func (p *Parser) FieldList() ([]string, error) {
+	field, err := p.Field() // Parse the first field
+	if err != nil {
+		return nil, err
+	}
+	fields := []string{field}
 
 	if p.lexer.MatchDelim(',') {
 		// If a comma follows, consume it and recursively parse the rest of the list
 		p.lexer.EatDelim(',')
 
 		// Append all fields from the recursive call to the current list
-		fields = append(fields, p.FieldList()...)
+		more, err := p.FieldList()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, more...)
 	}
-	return fields
+	return fields, nil
 }
 
 // Parses a comma-separated list of constants.
@@ -304,34 +1896,60 @@ func (p *Parser) FieldList() []string {
 //   - Single integer: "(1)"
 //   - Multiple types: "(1, 'John', 25)"
 //   - With spaces: "( 1 , 'John' , 25 )"
-func (p *Parser) ConstList() []*types.Constant {
-	var constants []*types.Constant
-	constants = append(constants, p.Constant()) // Parse the first constant
+func (p *Parser) ConstList() ([]*types.Constant, error) {
+	c, err := p.Constant() // Parse the first constant
+	if err != nil {
+		return nil, err
+	}
+	constants := []*types.Constant{c}
 
 	if p.lexer.MatchDelim(',') {
 		// If a comma follows, consume it and recursively parse th rest of the list
 		p.lexer.EatDelim(',')
 
 		// Append all constants from recursive call to the current list
-		constants = append(constants, p.ConstList()...)
+		more, err := p.ConstList()
+		if err != nil {
+			return nil, err
+		}
+		constants = append(constants, more...)
 	}
 
-	return constants
+	return constants, nil
 }
 
 // -------- METHODS FOR PARSING MODIFY COMMANDS  ----------
 
 // Parses an UPDATE command.
 // Returns a ModifyData struct representing the update operation.
-// Corresponds to grammar rule: <Modify> := UPDATE IdTok SET <Field> = <Expression> [ WHERE <Predicate> ]
+// Corresponds to grammar rule: <Modify> := UPDATE IdTok SET <Field> = <ValueExpression> [ WHERE <Predicate> ]
 // Used to modify existing records in a table.
-func (p *Parser) Modify() *ModifyData {
-	p.lexer.EatKeyword("update") // Consume UPDATE keyword
-	tableName := p.lexer.EatId() // Parse and store the table name
-	p.lexer.EatKeyword("set")    // Consume SET keyword
-	fieldName := p.Field()       // Parse the field to be updated
-	p.lexer.EatDelim('=')        // Consume equals operator
-	newVal := p.Expression()     // Parse the new value expression
+func (p *Parser) Modify() (*ModifyData, error) {
+	// Consume UPDATE keyword
+	if err := p.lexer.EatKeyword("update"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.lexer.EatId() // Parse and store the table name
+	if err != nil {
+		return nil, err
+	}
+	p.tables = []string{tableName}
+	// Consume SET keyword
+	if err := p.lexer.EatKeyword("set"); err != nil {
+		return nil, err
+	}
+	fieldName, err := p.Field() // Parse the field to be updated
+	if err != nil {
+		return nil, err
+	}
+	// Consume equals operator
+	if err := p.lexer.EatDelim('='); err != nil {
+		return nil, err
+	}
+	newVal, err := p.ValueExpression() // Parse the new value expression
+	if err != nil {
+		return nil, err
+	}
 
 	// Initializes an empty predicate (no WHERE clause)
 	pred := query.NewPredicate()
@@ -340,79 +1958,464 @@ func (p *Parser) Modify() *ModifyData {
 		// If WHERE keyword is present, parse the predicate
 		p.lexer.EatKeyword("where")
 
-		pred = p.Predicate()
+		if pred, err = p.Predicate(); err != nil {
+			return nil, err
+		}
 	}
 
-	return NewModifyData(tableName, fieldName, newVal, pred)
+	return NewModifyData(tableName, fieldName, newVal, pred), nil
 }
 
 // -------- METHODS FOR PARSING CREATE TABLE COMMANDS  ----------
 
 // Parses a CREATE TABLE command.
 // Returns a CreateTableData struct representing the table creation.
-// Corresponds to grammar rule: <CreateTable> := CREATE TABLE IdTok ( <FielDDefs> )
+// Corresponds to grammar rule: <CreateTable> := CREATE TABLE IdTok ( <FielDDefs> ) [ WITH ( <Options> ) ] [ <Partitioning> ]
 // Used to define a new table structure in the database.
-func (p *Parser) CreateTable() *CreateTableData {
-	p.lexer.EatKeyword("table")  // Consume TABLE keyword
-	tableName := p.lexer.EatId() // Parse and store the table name
-	p.lexer.EatDelim('(')        // Consume opening parenthesis
-	schema := p.FieldDefs()      // Parse the field definitions into a schema
-	p.lexer.EatDelim(')')        // consume closing parenthesis
+func (p *Parser) CreateTable() (*CreateTableData, error) {
+	p.lexer.EatKeyword("table")       // Consume TABLE keyword
+	tableName, err := p.lexer.EatId() // Parse and store the table name
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the parenthesized field definitions into a schema
+	if err := p.lexer.EatDelim('('); err != nil {
+		return nil, err
+	}
+	schema, err := p.FieldDefs()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return nil, err
+	}
+
+	data := NewCreateTableData(tableName, schema)
+	if p.lexer.MatchKeyword("with") {
+		// Parse the storage options of the table
+		p.lexer.EatKeyword("with")
+		options, err := p.parenthesizedOptions()
+		if err != nil {
+			return nil, err
+		}
+
+		data = NewCreateTableDataWithOptions(tableName, schema, options)
+	}
+
+	if p.lexer.MatchKeyword("partition") {
+		partitioning, err := p.Partitioning()
+		if err != nil {
+			return nil, err
+		}
+		data.SetPartitioning(partitioning)
+	}
+	return data, nil
+}
+
+// Parses the PARTITION BY clause of a CREATE TABLE statement.
+// Corresponds to grammar rule:
+// <Partitioning> := PARTITION BY RANGE ( IdTok ) ( <RangePartition> [ , <RangePartition> ] ) | PARTITION BY HASH ( IdTok ) PARTITIONS IntTok
+// Examples: "partition by range (year) (partition old values less than (2000), partition new values less than maxvalue)",
+// "partition by hash (id) partitions 4"
+func (p *Parser) Partitioning() (*PartitionData, error) {
+	if err := p.lexer.EatKeyword("partition"); err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("by"); err != nil {
+		return nil, err
+	}
+
+	if p.lexer.MatchKeyword("hash") {
+		p.lexer.EatKeyword("hash")
+		field, err := p.parenthesizedField()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lexer.EatKeyword("partitions"); err != nil {
+			return nil, err
+		}
+		n, err := p.lexer.EatIntConstant()
+		if err != nil {
+			return nil, err
+		}
+		return NewHashPartitionData(field, n), nil
+	}
+
+	if err := p.lexer.EatKeyword("range"); err != nil {
+		return nil, err
+	}
+	field, err := p.parenthesizedField()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.lexer.EatDelim('('); err != nil {
+		return nil, err
+	}
+	var partitions []RangePartition
+	for len(partitions) == 0 || p.lexer.MatchDelim(',') {
+		if len(partitions) > 0 {
+			p.lexer.EatDelim(',')
+		}
+		part, err := p.RangePartition()
+		if err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, part)
+	}
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return nil, err
+	}
+
+	return NewRangePartitionData(field, partitions), nil
+}
+
+// Parses a partition of a PARTITION BY RANGE clause.
+// Corresponds to grammar rule:
+// <RangePartition> := PARTITION IdTok VALUES LESS THAN ( [ - ] IntTok ) | PARTITION IdTok VALUES LESS THAN MAXVALUE
+func (p *Parser) RangePartition() (RangePartition, error) {
+	if err := p.lexer.EatKeyword("partition"); err != nil {
+		return RangePartition{}, err
+	}
+	name, err := p.lexer.EatId()
+	if err != nil {
+		return RangePartition{}, err
+	}
+	for _, w := range []string{"values", "less", "than"} {
+		if err := p.lexer.EatKeyword(w); err != nil {
+			return RangePartition{}, err
+		}
+	}
+
+	if p.lexer.MatchKeyword("maxvalue") {
+		p.lexer.EatKeyword("maxvalue")
+		return RangePartition{Name: name, MaxValue: true}, nil
+	}
+
+	if err := p.lexer.EatDelim('('); err != nil {
+		return RangePartition{}, err
+	}
+	sign := 1
+	if p.lexer.MatchDelim('-') {
+		p.lexer.EatDelim('-')
+		sign = -1
+	}
+	bound, err := p.lexer.EatIntConstant()
+	if err != nil {
+		return RangePartition{}, err
+	}
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return RangePartition{}, err
+	}
+	return RangePartition{Name: name, Bound: sign * bound}, nil
+}
+
+// Parses a field name in parentheses.
+// Corresponds to grammar rule: ( <Field> )
+func (p *Parser) parenthesizedField() (string, error) {
+	if err := p.lexer.EatDelim('('); err != nil {
+		return "", err
+	}
+	field, err := p.Field()
+	if err != nil {
+		return "", err
+	}
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return "", err
+	}
+	return field, nil
+}
+
+// Parses a list of options in parentheses.
+// Corresponds to grammar rule: ( <Options> )
+func (p *Parser) parenthesizedOptions() (map[string]string, error) {
+	if err := p.lexer.EatDelim('('); err != nil {
+		return nil, err
+	}
+	options, err := p.Options()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// Parses a comma-separated list of storage options.
+// Returns a map from option name to its value.
+// Corresponds to grammar rule: <Options> := IdTok = <OptionValue> [ , <Options> ]
+// Example: "fillfactor=80, compression=off"
+func (p *Parser) Options() (map[string]string, error) {
+	options := make(map[string]string)
+
+	for {
+		name, err := p.lexer.EatId()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lexer.EatDelim('='); err != nil {
+			return nil, err
+		}
+		if options[name], err = p.OptionValue(); err != nil {
+			return nil, err
+		}
+
+		if !p.lexer.MatchDelim(',') {
+			break
+		}
+		p.lexer.EatDelim(',')
+	}
+
+	return options, nil
+}
 
-	return NewCreateTableData(tableName, schema)
+// Parses the value of a storage option, which can be an integer,
+// an identifier or the keyword ON.
+// Corresponds to grammar rule: <OptionValue> := IntTok | IdTok | ON
+func (p *Parser) OptionValue() (string, error) {
+	if p.lexer.MatchIntConstant() {
+		n, err := p.lexer.EatIntConstant()
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(n), nil
+	} else if p.lexer.MatchKeyword("on") {
+		p.lexer.EatKeyword("on")
+		return "on", nil
+	} else {
+		return p.lexer.EatId()
+	}
 }
 
 // Parses a comma-seperated list of field definitions.
 // Returns a Schema struct contaning all field definitions.
 // Corresponds to grammar rule: <FieldDefs> := <FieldDef> [ , <FieldDefs> ]
 // Used to define multiple fields in a CREATE TABLE statement.
-func (p *Parser) FieldDefs() *schema.Schema {
-	schema := p.FieldDef() // Parse the first field definition
+func (p *Parser) FieldDefs() (*schema.Schema, error) {
+	schema, err := p.FieldDef() // Parse the first field definition
+	if err != nil {
+		return nil, err
+	}
 
 	if p.lexer.MatchDelim(',') {
 		// If a comma follows, consume it and recursiovely parse the rest of the definitions
 		p.lexer.EatDelim(',')
-		schema2 := p.FieldDefs()
-
-		// Merge all schemas from the recursive call into the current schema
+		schema2, err := p.FieldDefs()
+		if err != nil {
+			return nil, err
+		}
+
+		// Merge all schemas from the recursive call into the current schema,
+		// along with the options of the fields and the expressions of the
+		// generated fields
 		schema.AddAll(schema2)
+		for _, fieldName := range schema2.Fields() {
+			schema.SetNotNull(fieldName, schema2.NotNull(fieldName))
+			schema.SetDefault(fieldName, schema2.Default(fieldName))
+			schema.SetAutoIncrement(fieldName, schema2.AutoIncrement(fieldName))
+			if expr := schema2.Generated(fieldName); expr != "" {
+				schema.SetGenerated(fieldName, expr, !schema2.IsVirtual(fieldName))
+			}
+		}
 	}
 
-	return schema
+	return schema, nil
 }
 
 // Parses a single field definition.
 // Returns a Schema struct contanining a single field definition.
 // Used to define one field with its name and type.
-func (p *Parser) FieldDef() *schema.Schema {
-	fieldName := p.Field() // Parse the field name
+// Corresponds to grammar rule: <FieldDef> := IdTok <TypeDef> { <ColumnOption> } [ <Generated> ]
+func (p *Parser) FieldDef() (*schema.Schema, error) {
+	fieldName, err := p.Field() // Parse the field name
+	if err != nil {
+		return nil, err
+	}
 	// Continue parsing to get the field's type information
-	return p.FieldType(fieldName)
+	sch, err := p.FieldType(fieldName)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.ColumnOptions(fieldName, sch); err != nil {
+		return nil, err
+	}
+
+	if p.lexer.MatchKeyword("generated") || p.lexer.MatchKeyword("as") {
+		expr, stored, err := p.Generated()
+		if err != nil {
+			return nil, err
+		}
+		sch.SetGenerated(fieldName, expr, stored)
+	}
+	return sch, nil
+}
+
+// Parses the NOT NULL, DEFAULT and AUTO_INCREMENT options of a field
+// definition, in any order, into the schema of the field. The default value
+// is kept as written, to be parsed again whenever the table is used.
+// Corresponds to grammar rule:
+// <ColumnOption> := NOT NULL | DEFAULT <DefaultValue> | AUTO_INCREMENT
+// Example: "status varchar(10) not null default 'active'"
+func (p *Parser) ColumnOptions(fieldName string, sch *schema.Schema) error {
+	for {
+		switch {
+		case p.lexer.MatchKeyword("not"):
+			p.lexer.EatKeyword("not")
+			if err := p.lexer.EatKeyword("null"); err != nil {
+				return err
+			}
+			sch.SetNotNull(fieldName, true)
+		case p.lexer.MatchKeyword("default"):
+			p.lexer.EatKeyword("default")
+			start := p.lexer.Offset()
+			if _, err := p.DefaultValue(); err != nil {
+				return err
+			}
+			sch.SetDefault(fieldName, p.lexer.Text(start, p.lexer.Offset()))
+		case p.lexer.MatchKeyword("auto_increment"):
+			p.lexer.EatKeyword("auto_increment")
+			sch.SetAutoIncrement(fieldName, true)
+		default:
+			return nil
+		}
+	}
+}
+
+// Parses the default value of a field, which is a constant or a negative number.
+// Corresponds to grammar rule: <DefaultValue> := <Constant> | - IntTok | - FloatTok
+// Example: In "balance int default -1", "-1" is the default value
+func (p *Parser) DefaultValue() (*types.Constant, error) {
+	if p.lexer.MatchDelim('?') {
+		return nil, p.lexer.errorf("a default value can't be a parameter")
+	}
+	if p.lexer.MatchDelim('-') {
+		p.lexer.EatDelim('-')
+		if p.lexer.MatchFloatConstant() {
+			f, err := p.lexer.EatFloatConstant()
+			if err != nil {
+				return nil, err
+			}
+			return types.NewConstantFloat(-f), nil
+		}
+		n, err := p.lexer.EatIntConstant()
+		if err != nil {
+			return nil, err
+		}
+		return types.NewConstantInteger(-int64(n)), nil
+	}
+	return p.Constant()
+}
+
+// Parses the expression of a generated field and whether it is stored.
+// The expression is returned as written, to be parsed again whenever
+// the table is used. Fields are virtual unless declared STORED.
+// Corresponds to grammar rule:
+// <Generated> := [ GENERATED ALWAYS ] AS ( <ValueExpression> ) [ VIRTUAL | STORED ]
+// Example: "total int as (price * quantity) stored"
+func (p *Parser) Generated() (string, bool, error) {
+	if p.lexer.MatchKeyword("generated") {
+		p.lexer.EatKeyword("generated")
+		if err := p.lexer.EatKeyword("always"); err != nil {
+			return "", false, err
+		}
+	}
+	if err := p.lexer.EatKeyword("as"); err != nil {
+		return "", false, err
+	}
+
+	if err := p.lexer.EatDelim('('); err != nil {
+		return "", false, err
+	}
+	start := p.lexer.Offset()
+	if _, err := p.ValueExpression(); err != nil {
+		return "", false, err
+	}
+	expr := p.lexer.Text(start, p.lexer.Offset())
+	if err := p.lexer.EatDelim(')'); err != nil {
+		return "", false, err
+	}
+
+	if p.lexer.MatchKeyword("stored") {
+		p.lexer.EatKeyword("stored")
+		return expr, true, nil
+	}
+	if p.lexer.MatchKeyword("virtual") {
+		p.lexer.EatKeyword("virtual")
+	}
+	return expr, false, nil
 }
 
-// Parses a field type definition (int or varchar)
+// Parses a field type definition (int, bigint, float, date, timestamp, boolean, blob or varchar)
 // Returns a Schema struct containing the field with its type.
-// Corresponds to grammar rule: <TypeDef> := INT | VARCHAR (IntTok)
+// Corresponds to grammar rule: <TypeDef> := INT | BIGINT | FLOAT | DATE | TIMESTAMP | BOOLEAN | BLOB | VARCHAR (IntTok) [ COLLATE <Collation> ]
 // Used to define the data type of a field in a CREATE TABLE statement.
-func (p *Parser) FieldType(fieldName string) *schema.Schema {
+func (p *Parser) FieldType(fieldName string) (*schema.Schema, error) {
 	schema := schema.NewSchema() // Create a new schema to hold this field definition
 
 	if p.lexer.MatchKeyword("int") {
 		// If the type is INT, add an integer field to the schema
 		p.lexer.EatKeyword("int")
 		schema.AddIntField(fieldName)
+	} else if p.lexer.MatchKeyword("bigint") {
+		p.lexer.EatKeyword("bigint")
+		schema.AddLongField(fieldName)
+	} else if p.lexer.MatchKeyword("float") {
+		p.lexer.EatKeyword("float")
+		schema.AddFloatField(fieldName)
+	} else if p.lexer.MatchKeyword("date") {
+		p.lexer.EatKeyword("date")
+		schema.AddDateField(fieldName)
+	} else if p.lexer.MatchKeyword("timestamp") {
+		p.lexer.EatKeyword("timestamp")
+		schema.AddTimestampField(fieldName)
+	} else if p.lexer.MatchKeyword("boolean") {
+		p.lexer.EatKeyword("boolean")
+		schema.AddBoolField(fieldName)
+	} else if p.lexer.MatchKeyword("blob") {
+		p.lexer.EatKeyword("blob")
+		schema.AddBlobField(fieldName)
 	} else {
 		// Otherwise, assume the type is VARCHAR with a length specification
-		p.lexer.EatKeyword("varchar")
-		p.lexer.EatDelim('(')
-		strLen := p.lexer.EatIntConstant() // Parse the string length
-		p.lexer.EatDelim(')')
+		if err := p.lexer.EatKeyword("varchar"); err != nil {
+			return nil, err
+		}
+		if err := p.lexer.EatDelim('('); err != nil {
+			return nil, err
+		}
+		strLen, err := p.lexer.EatIntConstant() // Parse the string length
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lexer.EatDelim(')'); err != nil {
+			return nil, err
+		}
 
 		// Add a string field with the specified length to the schema
 		schema.AddStringField(fieldName, strLen)
+
+		if p.lexer.MatchKeyword("collate") {
+			p.lexer.EatKeyword("collate")
+			collation, err := p.Collation()
+			if err != nil {
+				return nil, err
+			}
+			schema.SetCollation(fieldName, collation)
+		}
 	}
 
-	return schema
+	return schema, nil
+}
+
+// Parses the name of a collation, an identifier such as nocase or a
+// string holding a language tag such as 'sv-SE'.
+// Corresponds to grammar rule: <Collation> := IdTok | StrTok
+func (p *Parser) Collation() (string, error) {
+	if p.lexer.MatchStringConstant() {
+		return p.lexer.EatStringConstant()
+	}
+	return p.lexer.EatId()
 }
 
 // -------- METHODS FOR PARSING CREATE VIEW COMMANDS  ----------
@@ -421,27 +2424,113 @@ func (p *Parser) FieldType(fieldName string) *schema.Schema {
 // Returns a CreateViewData struct representing the view creation.
 // Corresponds to grammar rule: <CreateView> := CREATE VIEW IdTok AS <Query>
 // Used to define a virtual table based on a SELECT query.
-func (p *Parser) CreateView() *CreateViewData {
+func (p *Parser) CreateView() (*CreateViewData, error) {
 	p.lexer.EatKeyword("view")
-	viewName := p.lexer.EatId()
-	p.lexer.EatKeyword("as")
-	qd := p.Query()
+	viewName, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("as"); err != nil {
+		return nil, err
+	}
+	qd, err := p.Query()
+	if err != nil {
+		return nil, err
+	}
 
-	return NewCreateViewData(viewName, qd)
+	return NewCreateViewData(viewName, qd), nil
 }
 
 // Parses a CREATE INDEX command.
 // Returns a CreateIndexData struct representing the index creation.
-// Corresponds to grammar rule: <CreateIndex> := CREATE INDEX IdTok ON IdTok ( <Field> )
-// Used to create an index for faster query execution.
-func (p *Parser) CreateIndex() *CreateIndexData {
-	p.lexer.EatKeyword("index")
-	indexName := p.lexer.EatId()
-	p.lexer.EatKeyword("on")
-	tableName := p.lexer.EatId()
-	p.lexer.EatDelim('(')
-	fieldName := p.Field()
-	p.lexer.EatDelim(')')
+// Corresponds to grammar rule:
+// <CreateIndex> := CREATE [ UNIQUE ] INDEX IdTok ON IdTok ( <Field> ) [ USING ( HASH | BTREE ) ]
+// Used to create an index for faster query execution. A hash index finds
+// the records equal to a value, a B-tree index also those within a range.
+// A unique index also keeps two records from having the same value.
+func (p *Parser) CreateIndex() (*CreateIndexData, error) {
+	unique := p.lexer.MatchKeyword("unique")
+	if unique {
+		p.lexer.EatKeyword("unique")
+	}
+	if err := p.lexer.EatKeyword("index"); err != nil {
+		return nil, err
+	}
+	indexName, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("on"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	fieldName, err := p.parenthesizedField()
+	if err != nil {
+		return nil, err
+	}
+
+	indexType := "hash"
+	if p.lexer.MatchKeyword("using") {
+		p.lexer.EatKeyword("using")
+		if p.lexer.MatchKeyword("btree") {
+			indexType = "btree"
+		} else if !p.lexer.MatchKeyword("hash") {
+			return nil, p.lexer.expected("HASH or BTREE")
+		}
+		p.lexer.EatKeyword(indexType)
+	}
 
-	return NewCreateIndexData(indexName, tableName, fieldName)
+	return NewCreateIndexData(indexName, tableName, fieldName, indexType, unique), nil
+}
+
+// Parses a DROP TABLE, DROP INDEX or DROP VIEW command.
+// Tables and views are dropped along with the objects depending on them
+// only if CASCADE is given.
+// Corresponds to grammar rule:
+// <Drop> := DROP TABLE IdTok [ CASCADE ] | DROP INDEX IdTok | DROP VIEW IdTok [ CASCADE ]
+// Examples:
+//   - "DROP TABLE employees CASCADE"
+//   - "DROP INDEX emp_dept"
+func (p *Parser) Drop() (interface{}, error) {
+	if err := p.lexer.EatKeyword("drop"); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.lexer.MatchKeyword("table"):
+		p.lexer.EatKeyword("table")
+		tableName, err := p.lexer.EatId()
+		if err != nil {
+			return nil, err
+		}
+		return NewDropTableData(tableName, p.cascade()), nil
+	case p.lexer.MatchKeyword("index"):
+		p.lexer.EatKeyword("index")
+		indexName, err := p.lexer.EatId()
+		if err != nil {
+			return nil, err
+		}
+		return NewDropIndexData(indexName), nil
+	case p.lexer.MatchKeyword("view"):
+		p.lexer.EatKeyword("view")
+		viewName, err := p.lexer.EatId()
+		if err != nil {
+			return nil, err
+		}
+		return NewDropViewData(viewName, p.cascade()), nil
+	default:
+		return nil, p.lexer.expected("TABLE, INDEX or VIEW")
+	}
+}
+
+// Consumes an optional CASCADE keyword and reports whether it was there
+func (p *Parser) cascade() bool {
+	if !p.lexer.MatchKeyword("cascade") {
+		return false
+	}
+	p.lexer.EatKeyword("cascade")
+	return true
 }
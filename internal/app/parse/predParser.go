@@ -17,47 +17,60 @@ func NewPredParser(s string) *PredParser {
 // Parses a field name, which must be an identifier.
 // Returns the string representation of the identifier.
 // Corresponds to the grammar rule: <Field> := IdTok
-func (pp *PredParser) Field() string {
+func (pp *PredParser) Field() (string, error) {
 	return pp.lex.EatId()
 }
 
 // Parses a constant value, which can be either a string or integer.
 // Corresponds to grammar rule: <Constant> : StrTok | IntTok
-func (pp *PredParser) Constant() {
+func (pp *PredParser) Constant() error {
+	var err error
 	if pp.lex.MatchStringConstant() {
-		pp.lex.EatStringConstant() // Consume a string constant
+		_, err = pp.lex.EatStringConstant() // Consume a string constant
 	} else {
-		pp.lex.EatIntConstant() // Consume an Integer constant
+		_, err = pp.lex.EatIntConstant() // Consume an Integer constant
 	}
+	return err
 }
 
 // Parses an expression, which can be either a field or a constant.
 // Corresponds to the grammar rule: <Expression> := <Field> | <Constant>
-func (pp *PredParser) Expression() {
+func (pp *PredParser) Expression() error {
 	if pp.lex.MatchId() {
-		pp.Field() // Parse a field if the next token is an identifier
+		_, err := pp.Field() // Parse a field if the next token is an identifier
+		return err
 	} else {
-		pp.Constant() // Otherwise parse a constant
+		return pp.Constant() // Otherwise parse a constant
 	}
 }
 
 // Parses a term, which is an equality comparison between two expressions.
 // Corresponds to the grammar rule: <Term> := <Expression> = <Expression>
-func (pp *PredParser) Term() {
-	pp.Expression()      // Parse the left-hand expression
-	pp.lex.EatDelim('=') // Consume the equals delimitter
-	pp.Expression()      // Parse the right-hand expression
+func (pp *PredParser) Term() error {
+	// Parse the left-hand expression
+	if err := pp.Expression(); err != nil {
+		return err
+	}
+	// Consume the equals delimitter
+	if err := pp.lex.EatDelim('='); err != nil {
+		return err
+	}
+	return pp.Expression() // Parse the right-hand expression
 }
 
 // Parses a predicate, which is a term optionally followed by "AND" and another predicate.
 // This implements recursive parsing to handle chained conditions.
 // Corresponds to the grammar rule: <Predicate> := <Term> [ AND <Predicate> ]
-func (pp *PredParser) Predicate() {
-	pp.Term() // Parse the first term
+func (pp *PredParser) Predicate() error {
+	// Parse the first term
+	if err := pp.Term(); err != nil {
+		return err
+	}
 
 	// If followed by "AND", recursively parse the rest of the predicate
 	if pp.lex.MatchKeyword("and") {
 		pp.lex.EatKeyword("and") // Consume the "and" keyword
-		pp.Predicate()           // Recursivly parse the next predicate
+		return pp.Predicate()    // Recursivly parse the next predicate
 	}
+	return nil
 }
@@ -2,6 +2,8 @@
 // Revised Version of PredParser to handle explicit joins
 package parse
 
+import "strconv"
+
 // Main parser structure for SQL queries.
 // Extended to support JOIN operations and more complex SQL constructs.
 type SQLParser struct {
@@ -17,35 +19,45 @@ func NewSQLParser(s string) *SQLParser {
 // Parses a field name, which must be an identifier.
 // Now supports qualified column names in the form "table.column"
 // Returns the string representation of the identifier.
-func (sp *SQLParser) Field() string {
+func (sp *SQLParser) Field() (string, error) {
 	// Parse the first identifier (table name or column name)
-	id := sp.lex.EatId()
+	id, err := sp.lex.EatId()
+	if err != nil {
+		return "", err
+	}
 
 	// If followed by a dot, this is a qualified column name
 	if sp.lex.MatchDelim('.') {
 		// Consume the dot
 		sp.lex.EatDelim('.')
 		// Parse the column name that follows the table name
-		columnName := sp.lex.EatId()
+		columnName, err := sp.lex.EatId()
+		if err != nil {
+			return "", err
+		}
 		// Return the qualified name in the form "table.column"
-		return id + "." + columnName
+		return id + "." + columnName, nil
 	}
 
-	return id
+	return id, nil
 }
 
 // Parses a constant value, which can be either a string or integer.
 // Returns the constant value as a strinf for consistency.
-func (sp *SQLParser) Constant() string {
+func (sp *SQLParser) Constant() (string, error) {
 	if sp.lex.MatchStringConstant() {
 		return sp.lex.EatStringConstant()
 	} else {
-		return string(sp.lex.EatIntConstant())
+		n, err := sp.lex.EatIntConstant()
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(n), nil
 	}
 }
 
 // Parses an expression, which can be a field or a constant.
-func (sp *SQLParser) Expression() string {
+func (sp *SQLParser) Expression() (string, error) {
 	// parse a field if next token is an identifier
 	if sp.lex.MatchId() {
 		return sp.Field()
@@ -57,89 +69,109 @@ func (sp *SQLParser) Expression() string {
 // Parses a comparison operator, which can be =, <>, <, >, <= or >=
 // Returns the string representation of the operator.
 // Corresponds to grammar rule: <CompOp> := = | <> | < | > | <= | >=
-func (sp *SQLParser) ComparisonOperator() string {
+func (sp *SQLParser) ComparisonOperator() (string, error) {
 	if sp.lex.MatchDelim('=') {
 		sp.lex.EatDelim('=')
-		return "="
+		return "=", nil
 	} else if sp.lex.MatchDelim('<') {
 		sp.lex.EatDelim('<')
 		if sp.lex.MatchDelim('>') {
 			sp.lex.EatDelim('>')
-			return "<>"
+			return "<>", nil
 		} else if sp.lex.MatchDelim('=') {
 			sp.lex.EatDelim('=')
-			return "<="
+			return "<=", nil
 		}
 
-		return "<"
+		return "<", nil
 	} else if sp.lex.MatchDelim('>') {
 		sp.lex.EatDelim('>')
 
 		if sp.lex.MatchDelim('=') {
 			sp.lex.EatDelim('=')
-			return ">="
+			return ">=", nil
 		}
-		return ">"
+		return ">", nil
 	}
 
-	// If no comparision operator is found, default to equals
-	// This is for backward compatibility, but ideally should throw an error
-	sp.lex.EatDelim('=')
-	return "="
+	// If no comparision operator is found, it is an error to not find an equals
+	if err := sp.lex.EatDelim('='); err != nil {
+		return "", err
+	}
+	return "=", nil
 }
 
 // Parses a term, which is a comparison between two expressions.
 // Now supports different comparison operators.
 // Corresponds to the grammar rule: <Term> := <Expression> <CompOp> <Expression>
-func (sp *SQLParser) Term() {
-	sp.Expression()         // Parse the left-hand expression
-	sp.ComparisonOperator() // Parse the comparison operator
-	sp.Expression()         // Parse the right-hand expression
+func (sp *SQLParser) Term() error {
+	// Parse the left-hand expression
+	if _, err := sp.Expression(); err != nil {
+		return err
+	}
+	// Parse the comparison operator
+	if _, err := sp.ComparisonOperator(); err != nil {
+		return err
+	}
+	// Parse the right-hand expression
+	_, err := sp.Expression()
+	return err
 }
 
 // Parses a predicate, which is a term optionally followed by a logical connector and logical predicate
 // Extended to support botht AND and OR logical operators.
-func (sp *SQLParser) Predicate() {
-	sp.Term()
+func (sp *SQLParser) Predicate() error {
+	if err := sp.Term(); err != nil {
+		return err
+	}
 
 	// Check for logical Operators (AND or OR)
 	if sp.lex.MatchKeyword("and") {
 		sp.lex.EatKeyword("and")
-		sp.Predicate()
+		return sp.Predicate()
 	} else if sp.lex.MatchKeyword("or") {
 		sp.lex.EatKeyword("or")
-		sp.Predicate()
+		return sp.Predicate()
 	}
+	return nil
 }
 
 // Parses a table reference, which can be a simple table name or a table with an alias.
 // Returns the table name and alias(if any)
 // Corresponds to the grammar rule: <TableRef> := IdTok [ AS IdTok ]
-func (sp *SQLParser) TableRef() (string, string) {
+func (sp *SQLParser) TableRef() (string, string, error) {
 	// Parse the table name
-	tableName := sp.lex.EatId()
+	tableName, err := sp.lex.EatId()
+	if err != nil {
+		return "", "", err
+	}
 
 	// Check if there's an alias using the AS keyword
 	if sp.lex.MatchKeyword("as") {
 		sp.lex.EatKeyword("as")
-		alias := sp.lex.EatId()
-		return tableName, alias
+		alias, err := sp.lex.EatId()
+		if err != nil {
+			return "", "", err
+		}
+		return tableName, alias, nil
 	}
 
 	// Check if there's an alias without the AS keyword
 	if sp.lex.MatchId() {
-		alias := sp.lex.EatId()
-		return tableName, alias
+		alias, _ := sp.lex.EatId()
+		return tableName, alias, nil
 	}
 
-	return tableName, ""
+	return tableName, "", nil
 }
 
 // Parses a join condition, which follows the ON keyword in a JOIN clause.
 // Corresponds to the grammar rule: <JoinCondition> := ON <Predicate>
-func (sp *SQLParser) JoinCondition() {
-	sp.lex.EatKeyword("on")
-	sp.Predicate()
+func (sp *SQLParser) JoinCondition() error {
+	if err := sp.lex.EatKeyword("on"); err != nil {
+		return err
+	}
+	return sp.Predicate()
 }
 
 // Parses a join type (INNER, RIGHT, LEFT, FULL).
@@ -179,87 +211,112 @@ func (sp *SQLParser) JoinType() string {
 
 // Parses a join clause, which consists of a join type, a table reference, and a join condition.
 // Corresponds to the grammar rule: <Join> := <JoinType> JOIN <TableRef> <JoinCondition>
-func (sp *SQLParser) Join() {
-	sp.JoinType()             // Parse the join type (INNER, LEFT, RIGHT, FULL)
-	sp.lex.EatKeyword("join") // Expect the JOIN keyword
-	sp.TableRef()             // Parse the table being joined
-	sp.JoinCondition()        // Parse the join condition
+func (sp *SQLParser) Join() error {
+	sp.JoinType() // Parse the join type (INNER, LEFT, RIGHT, FULL)
+	// Expect the JOIN keyword
+	if err := sp.lex.EatKeyword("join"); err != nil {
+		return err
+	}
+	// Parse the table being joined
+	if _, _, err := sp.TableRef(); err != nil {
+		return err
+	}
+	return sp.JoinCondition() // Parse the join condition
 }
 
 // Parses a from clause, which specifies the tables involved in the query.
 // Now supports multiple tables with explicit JOIN Operations.
 // Corresponds to the grammar rule: <FromClause> := FROM <TableRef> { <Join> }
-func (sp *SQLParser) FromClause() {
+func (sp *SQLParser) FromClause() error {
 	// Expect the FROM Keyword
-	sp.lex.EatKeyword("from")
+	if err := sp.lex.EatKeyword("from"); err != nil {
+		return err
+	}
 	// Parse the first table reference
-	sp.TableRef()
+	if _, _, err := sp.TableRef(); err != nil {
+		return err
+	}
 
 	// Parse any subsequent JOIN clauses
 	for sp.lex.MatchKeyword("inner") || sp.lex.MatchKeyword("left") ||
 		sp.lex.MatchKeyword("right") || sp.lex.MatchKeyword("full") || sp.lex.MatchKeyword("join") {
-		// If "JOIN" appears without a preceding join type, it's an INNER JOIN
-		if sp.lex.MatchKeyword("join") {
-			sp.lex.EatKeyword("join")
-			sp.TableRef()
-			sp.JoinCondition()
-		} else {
-			// Parse a join with an explicit type
-			sp.Join()
+		// If "JOIN" appears without a preceding join type, it's an INNER JOIN.
+		// Otherwise parse a join with an explicit type
+		if err := sp.Join(); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 // Parses a where clause, which filters the query result.
 // Corresponds to the grammar rule: <WhereCluase> : WHERE <Predicate>
-func (sp *SQLParser) WhereClause() {
-	sp.lex.EatKeyword("where")
-	sp.Predicate()
+func (sp *SQLParser) WhereClause() error {
+	if err := sp.lex.EatKeyword("where"); err != nil {
+		return err
+	}
+	return sp.Predicate()
 }
 
 // Parses a select item, which can be a column, an expression, or a wildcard (*).
 // Corresponds to the grammar rule: <SelectItem> := <Expression> [ AS IdTok ] | *
-func (sp *SQLParser) SelectItem() {
+func (sp *SQLParser) SelectItem() error {
 	if sp.lex.MatchDelim('*') {
 		sp.lex.EatDelim('*')
-		return
+		return nil
 	}
 
 	// Parse an expression
-	sp.Expression()
+	if _, err := sp.Expression(); err != nil {
+		return err
+	}
 
 	// Check for an alias using the AS keyword
 	if sp.lex.MatchKeyword("as") {
 		sp.lex.EatKeyword("as")
-		sp.lex.EatId()
+		_, err := sp.lex.EatId()
+		return err
 	} else if sp.lex.MatchId() {
 		// Check for an alias without the AS keyword
 		sp.lex.EatId()
 	}
+	return nil
 }
 
 // Parses a list of select items seperated by commaas
-func (sp *SQLParser) SelectList() {
-	sp.SelectItem()
+func (sp *SQLParser) SelectList() error {
+	if err := sp.SelectItem(); err != nil {
+		return err
+	}
 
 	for sp.lex.MatchDelim(',') {
 		sp.lex.EatDelim(',')
-		sp.SelectItem()
+		if err := sp.SelectItem(); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // Parses a complete SELECT statement with support for joins.
 // Corresponds to the grammar rule:
 // <Query> := SELECT <SelectList> <FromClause> [ <WhereClause> ]
-func (sp *SQLParser) Query() {
-	sp.lex.EatKeyword("select")
-	sp.SelectList()
+func (sp *SQLParser) Query() error {
+	if err := sp.lex.EatKeyword("select"); err != nil {
+		return err
+	}
+	if err := sp.SelectList(); err != nil {
+		return err
+	}
 
 	// Parse the from clause with potentital joins
-	sp.FromClause()
+	if err := sp.FromClause(); err != nil {
+		return err
+	}
 
 	// Parse an optional where clause
 	if sp.lex.MatchKeyword("where") {
-		sp.WhereClause()
+		return sp.WhereClause()
 	}
+	return nil
 }
@@ -18,44 +18,44 @@ func NewTracingSQLParser(lexer *Lexer) *TracingSQLParser {
 
 // OVERRIDE METHODS TO ADD TRACING
 
-func (tsp *TracingSQLParser) Field() string {
+func (tsp *TracingSQLParser) Field() (string, error) {
 	tsp.Trace = append(tsp.Trace, "Field")
 	return tsp.SQLParser.Field()
 }
 
-func (tsp *TracingSQLParser) Constant() string {
+func (tsp *TracingSQLParser) Constant() (string, error) {
 	tsp.Trace = append(tsp.Trace, "Constant")
 	return tsp.SQLParser.Constant()
 }
 
-func (tsp *TracingSQLParser) Expression() string {
+func (tsp *TracingSQLParser) Expression() (string, error) {
 	tsp.Trace = append(tsp.Trace, "Expression")
 	return tsp.SQLParser.Expression()
 }
 
-func (tsp *TracingSQLParser) ComparisonOperator() string {
+func (tsp *TracingSQLParser) ComparisonOperator() (string, error) {
 	tsp.Trace = append(tsp.Trace, "ComparisonOperator")
 	return tsp.SQLParser.ComparisonOperator()
 }
 
-func (tsp *TracingSQLParser) Term() {
+func (tsp *TracingSQLParser) Term() error {
 	tsp.Trace = append(tsp.Trace, "Term")
-	tsp.SQLParser.Term()
+	return tsp.SQLParser.Term()
 }
 
-func (tsp *TracingSQLParser) Predicate() {
+func (tsp *TracingSQLParser) Predicate() error {
 	tsp.Trace = append(tsp.Trace, "Predicate")
-	tsp.SQLParser.Predicate()
+	return tsp.SQLParser.Predicate()
 }
 
-func (tsp *TracingSQLParser) TableRef() (string, string) {
+func (tsp *TracingSQLParser) TableRef() (string, string, error) {
 	tsp.Trace = append(tsp.Trace, "TableRef")
 	return tsp.SQLParser.TableRef()
 }
 
-func (tsp *TracingSQLParser) JoinCondition() {
+func (tsp *TracingSQLParser) JoinCondition() error {
 	tsp.Trace = append(tsp.Trace, "JoinCondition")
-	tsp.SQLParser.JoinCondition()
+	return tsp.SQLParser.JoinCondition()
 }
 
 func (tsp *TracingSQLParser) JoinType() string {
@@ -63,34 +63,35 @@ func (tsp *TracingSQLParser) JoinType() string {
 	return tsp.SQLParser.JoinType()
 }
 
-func (tsp *TracingSQLParser) Join() {
+func (tsp *TracingSQLParser) Join() error {
 	tsp.Trace = append(tsp.Trace, "Join")
-	tsp.SQLParser.Join()
+	return tsp.SQLParser.Join()
 }
 
-func (tsp *TracingSQLParser) FromClause() {
+func (tsp *TracingSQLParser) FromClause() error {
 	tsp.Trace = append(tsp.Trace, "FromClause")
-	tsp.SQLParser.FromClause()
+	return tsp.SQLParser.FromClause()
 }
 
-func (tsp *TracingSQLParser) WhereClause() {
+func (tsp *TracingSQLParser) WhereClause() error {
 	tsp.Trace = append(tsp.Trace, "WhereClause")
-	tsp.SQLParser.WhereClause()
+	return tsp.SQLParser.WhereClause()
 }
 
-func (tsp *TracingSQLParser) SelectItem() {
+func (tsp *TracingSQLParser) SelectItem() error {
 	tsp.Trace = append(tsp.Trace, "SelectItem")
-	tsp.SQLParser.SelectItem()
+	return tsp.SQLParser.SelectItem()
 }
 
-func (tsp *TracingSQLParser) SelectList() {
+func (tsp *TracingSQLParser) SelectList() error {
 	tsp.Trace = append(tsp.Trace, "SelectList")
-	tsp.SQLParser.SelectList()
+	return tsp.SQLParser.SelectList()
 }
 
-func (tsp *TracingSQLParser) Query() {
+func (tsp *TracingSQLParser) Query() error {
 	tsp.Trace = append(tsp.Trace, "Query")
 	tsp.SQLParser.JoinType()
+	return nil
 }
 
 // Helper to check if a trace includes the expected method calls in order
@@ -123,8 +124,8 @@ func TestField(t *testing.T) {
 	lexer := NewLexer("column")
 	parser := NewTracingSQLParser(lexer)
 
-	result := parser.Field()
-	if result != "column" {
+	result, err := parser.Field()
+	if err != nil || result != "column" {
 		t.Errorf("Expected 'column', got '%s'", result)
 	}
 
@@ -132,8 +133,8 @@ func TestField(t *testing.T) {
 	lexer = NewLexer("table.column")
 	parser = NewTracingSQLParser(lexer)
 
-	result = parser.Field()
-	if result != "table.column" {
+	result, err = parser.Field()
+	if err != nil || result != "table.column" {
 		t.Errorf("Expected 'table.column', got '%s'", result)
 	}
 }
@@ -48,67 +48,84 @@ func (rpp *RefinedPredParser) exitNode() {
 // Parses a field name, which must be an identifier.
 // Returns the string reprsentation of the identifier.
 // Corresponds to the grammar rule: Field := IdTok
-func (rpp *RefinedPredParser) Field() string {
+func (rpp *RefinedPredParser) Field() (string, error) {
 	rpp.enterNode("Field")
-	id := rpp.lex.EatId()
+	defer rpp.exitNode()
+	id, err := rpp.lex.EatId()
+	if err != nil {
+		return "", err
+	}
 	rpp.logNode("Identifier", id)
-	rpp.exitNode()
-	return id
+	return id, nil
 }
 
 // Parses a constant value, which can be either a string or integer.
 // Returns the constant value as a string.
 // Corresponds to grammar rule: Constant: StrTok | IntTok
-func (rpp *RefinedPredParser) Constant() string {
+func (rpp *RefinedPredParser) Constant() (string, error) {
 	rpp.enterNode("Constant")
-	var value string
+	defer rpp.exitNode()
 
 	if rpp.lex.MatchStringConstant() {
-		value = rpp.lex.EatStringConstant() // Consume a string constant
+		value, err := rpp.lex.EatStringConstant() // Consume a string constant
+		if err != nil {
+			return "", err
+		}
 		rpp.logNode("StringConstant", value)
-	} else {
-		value = fmt.Sprintf("%d", rpp.lex.EatIntConstant()) // Consume an integer constant
-		rpp.logNode("IntConstant", value)
+		return value, nil
 	}
 
-	rpp.exitNode()
-	return value
+	n, err := rpp.lex.EatIntConstant() // Consume an integer constant
+	if err != nil {
+		return "", err
+	}
+	value := fmt.Sprintf("%d", n)
+	rpp.logNode("IntConstant", value)
+	return value, nil
 }
 
-func (rpp *RefinedPredParser) Expression() string {
+func (rpp *RefinedPredParser) Expression() (string, error) {
 	rpp.enterNode("Expression")
-	var value string
+	defer rpp.exitNode()
 
 	if rpp.lex.MatchId() {
-		value = rpp.Field() // Parse a field if the next token is an idenitifier
-	} else {
-		value = rpp.Constant() // Otherwise parse a constant
+		return rpp.Field() // Parse a field if the next token is an idenitifier
 	}
-
-	rpp.exitNode()
-	return value
+	return rpp.Constant() // Otherwise parse a constant
 }
 
-func (rpp *RefinedPredParser) Term() {
+func (rpp *RefinedPredParser) Term() error {
 	rpp.enterNode("Term")
-	leftExpr := rpp.Expression()
-	rpp.lex.EatDelim('=')
+	defer rpp.exitNode()
+	leftExpr, err := rpp.Expression()
+	if err != nil {
+		return err
+	}
+	if err := rpp.lex.EatDelim('='); err != nil {
+		return err
+	}
 	rpp.logNode("Operator", "=")
-	rightExpr := rpp.Expression()
+	rightExpr, err := rpp.Expression()
+	if err != nil {
+		return err
+	}
 	rpp.logNode("Comparison", fmt.Sprintf("%s = %s", leftExpr, rightExpr))
-	rpp.exitNode()
+	return nil
 }
 
-func (rpp *RefinedPredParser) Predicate() {
+func (rpp *RefinedPredParser) Predicate() error {
 	rpp.enterNode("Predicate")
-	rpp.Term()
+	defer rpp.exitNode()
+	if err := rpp.Term(); err != nil {
+		return err
+	}
 
 	if rpp.lex.MatchKeyword("and") {
 		rpp.lex.EatKeyword("and")
 		rpp.logNode("Connector", "AND")
-		rpp.Predicate()
+		return rpp.Predicate()
 	}
-	rpp.exitNode()
+	return nil
 }
 
 // Prints the entire tree after parsing is complete
@@ -2,24 +2,49 @@ package parse
 
 import (
 	"centauri/internal/app/query"
+	"centauri/internal/app/types"
+	"fmt"
+	"slices"
 	"strings"
 )
 
 // Represents the componnets of a SQL query:
 //   - fields to select
+//   - aggregates computed over the selected records
+//   - fields computed from each record with an expression
 //   - tables to query from
+//   - the tables joined with JOIN ... ON, and their join predicates
 //   - predicates for the WHERE clause
+//   - the fields the records are grouped by, and the predicate on the groups
+//   - the queries combined with it by UNION
+//   - the fields the output is ordered by
+//   - the maximum number of records to output
+//   - the past point in the database's history it reads, if any
 type QueryData struct {
-	fields []string
-	tables []string
-	pred   *query.Predicate
+	fields     []string
+	aggregates []*AggregateData
+	computed   []*ComputedData
+	tables     []string
+	joins      []*JoinData
+	pred       *query.Predicate
+	groupBy    []string
+	having     *query.Predicate
+	unions     []*UnionData
+	orderBy    []*OrderData
+	limit      int
+	asOf       *AsOfData
 }
 
+// The limit of a query without a LIMIT clause
+const NO_LIMIT = -1
+
 func NewQueryData(fields []string, tables []string, pred *query.Predicate) *QueryData {
 	return &QueryData{
 		fields: fields,
 		tables: tables,
 		pred:   pred,
+		having: query.NewPredicate(),
+		limit:  NO_LIMIT,
 	}
 }
 
@@ -27,14 +52,194 @@ func (qd *QueryData) Fields() []string {
 	return qd.fields
 }
 
+// Returns the aggregates of the select list and the HAVING clause. The
+// output fields of those of the select list are part of Fields.
+func (qd *QueryData) Aggregates() []*AggregateData {
+	return qd.aggregates
+}
+
+// Sets the aggregates of the select list and the HAVING clause
+func (qd *QueryData) SetAggregates(aggregates []*AggregateData) {
+	qd.aggregates = aggregates
+}
+
+// Returns the fields of the select list computed with an expression, or
+// given an alias. Their output fields are part of Fields.
+func (qd *QueryData) Computed() []*ComputedData {
+	return qd.computed
+}
+
+// Sets the computed fields of the select list
+func (qd *QueryData) SetComputed(computed []*ComputedData) {
+	qd.computed = computed
+}
+
 func (qd *QueryData) Tables() []string {
 	return qd.tables
 }
 
+// Returns the tables and views the query reads: those of its FROM clause
+// followed by those of the queries of its unions
+func (qd *QueryData) ReadTables() []string {
+	if len(qd.unions) == 0 {
+		return qd.tables
+	}
+	tables := slices.Clone(qd.tables)
+	for _, union := range qd.unions {
+		for _, table := range union.Query().ReadTables() {
+			if !slices.Contains(tables, table) {
+				tables = append(tables, table)
+			}
+		}
+	}
+	return tables
+}
+
+// Returns the tables joined with JOIN ... ON. They are part of Tables.
+func (qd *QueryData) Joins() []*JoinData {
+	return qd.joins
+}
+
+// Sets the tables joined with JOIN ... ON
+func (qd *QueryData) SetJoins(joins []*JoinData) {
+	qd.joins = joins
+}
+
+// Returns the predicate the output records satisfy: the WHERE clause
+// conjoined with the ON predicates of the joins
 func (qd *QueryData) Pred() *query.Predicate {
+	if len(qd.joins) == 0 {
+		return qd.pred
+	}
+	pred := query.NewPredicate()
+	for _, join := range qd.joins {
+		pred.ConjoinWith(join.On())
+	}
+	pred.ConjoinWith(qd.pred)
+	return pred
+}
+
+// Returns the predicate of the WHERE clause
+func (qd *QueryData) Where() *query.Predicate {
 	return qd.pred
 }
 
+// Returns the fields of the GROUP BY clause, or nil if the records aren't grouped
+func (qd *QueryData) GroupBy() []string {
+	return qd.groupBy
+}
+
+// Sets the fields of the GROUP BY clause
+func (qd *QueryData) SetGroupBy(groupBy []string) {
+	qd.groupBy = groupBy
+}
+
+// Returns the predicate of the HAVING clause, which the groups satisfy.
+// It reads the grouping fields and the output fields of the aggregates.
+func (qd *QueryData) Having() *query.Predicate {
+	return qd.having
+}
+
+// Sets the predicate of the HAVING clause
+func (qd *QueryData) SetHaving(having *query.Predicate) {
+	qd.having = having
+}
+
+// Returns the queries combined with this one by UNION, in order, or nil
+func (qd *QueryData) Unions() []*UnionData {
+	return qd.unions
+}
+
+// Sets the queries combined with this one by UNION
+func (qd *QueryData) SetUnions(unions []*UnionData) {
+	qd.unions = unions
+}
+
+// Returns the queries a UNION combines: this one without its unions and
+// the ORDER BY and LIMIT clauses, which apply to the combined records,
+// followed by the queries of its unions. A query without UNION is its only operand.
+func (qd *QueryData) Operands() []*QueryData {
+	if len(qd.unions) == 0 {
+		return []*QueryData{qd}
+	}
+	first := *qd
+	first.unions, first.orderBy, first.limit = nil, nil, NO_LIMIT
+	operands := []*QueryData{&first}
+	for _, union := range qd.unions {
+		operands = append(operands, union.Query())
+	}
+	return operands
+}
+
+// Returns the items of the ORDER BY clause in priority order,
+// or nil if the order of the output is unspecified
+func (qd *QueryData) OrderBy() []*OrderData {
+	return qd.orderBy
+}
+
+// Sets the items of the ORDER BY clause
+func (qd *QueryData) SetOrderBy(orderBy []*OrderData) {
+	qd.orderBy = orderBy
+}
+
+// Returns the fields the query reads: those of the select list, the
+// aggregates, the computed fields, the predicate, the GROUP BY clause and
+// the ORDER BY clause, along with those of the queries of its unions.
+// The names of computed fields aren't read from the tables, so they are
+// left out, as are the aggregates read by HAVING.
+func (qd *QueryData) ReadFields() []string {
+	computed := make(map[string]bool)
+	var fields []string
+	for _, cd := range qd.computed {
+		computed[cd.Name()] = true
+		fields = append(fields, cd.Expression().Fields()...)
+	}
+	for _, field := range qd.fields {
+		if !computed[field] {
+			fields = append(fields, field)
+		}
+	}
+	for _, agg := range qd.aggregates {
+		fields = append(fields, agg.FieldName())
+	}
+	if pred := qd.Pred(); pred != nil {
+		for _, term := range pred.Terms() {
+			fields = append(fields, term.Fields()...)
+		}
+	}
+	fields = append(fields, qd.groupBy...)
+	for _, union := range qd.unions {
+		fields = append(fields, union.Query().ReadFields()...)
+	}
+	for _, od := range qd.orderBy {
+		if !computed[od.FieldName()] {
+			fields = append(fields, od.FieldName())
+		}
+	}
+	return fields
+}
+
+// Returns the maximum number of records the query outputs, or NO_LIMIT
+func (qd *QueryData) Limit() int {
+	return qd.limit
+}
+
+// Sets the maximum number of records the query outputs
+func (qd *QueryData) SetLimit(limit int) {
+	qd.limit = limit
+}
+
+// Returns the point in the history of the database the query reads the
+// tables as of, or nil if it reads their current state
+func (qd *QueryData) AsOf() *AsOfData {
+	return qd.asOf
+}
+
+// Sets the point in the history of the database the query reads the tables as of
+func (qd *QueryData) SetAsOf(asOf *AsOfData) {
+	qd.asOf = asOf
+}
+
 // Generates a SQL query string from the QueryData components.
 // The method builds a SELECT statement with the specified fields, table and predicate.
 func (qd *QueryData) String() string {
@@ -44,9 +249,24 @@ func (qd *QueryData) String() string {
 	// Start building with SELECT clause
 	builder.WriteString("select ")
 
-	// Add field names with commas
+	// Add field names with commas, writing aggregates as function calls
+	// and computed fields as their expressions
+	aggregates := make(map[string]*AggregateData)
+	for _, agg := range qd.aggregates {
+		aggregates[agg.Name()] = agg
+	}
+	computed := make(map[string]*ComputedData)
+	for _, cd := range qd.computed {
+		computed[cd.Name()] = cd
+	}
 	for i, field := range qd.fields {
-		builder.WriteString(field)
+		if agg, ok := aggregates[field]; ok {
+			builder.WriteString(agg.String())
+		} else if cd, ok := computed[field]; ok {
+			builder.WriteString(cd.String())
+		} else {
+			builder.WriteString(field)
+		}
 
 		// Add comma and space if not the last field
 		if i < len(qd.fields)-1 {
@@ -56,13 +276,26 @@ func (qd *QueryData) String() string {
 
 	builder.WriteString(" from ")
 
-	// Add table names with commas
+	// Add table names with commas, writing joined tables with their ON clause
+	joins := make(map[string]*JoinData)
+	for _, join := range qd.joins {
+		joins[join.TableName()] = join
+	}
 	for i, table := range qd.tables {
-		builder.WriteString(table)
-		// Add comma and space if not the last table
-		if i < len(qd.tables)-1 {
+		if join, ok := joins[table]; ok && i > 0 {
+			builder.WriteString(" ")
+			builder.WriteString(join.String())
+			continue
+		}
+		if i > 0 {
 			builder.WriteString(", ")
 		}
+		builder.WriteString(table)
+	}
+
+	if qd.asOf != nil {
+		builder.WriteString(" ")
+		builder.WriteString(qd.asOf.String())
 	}
 
 	// Add WHERE clause if predicate exists and is not empty
@@ -72,5 +305,72 @@ func (qd *QueryData) String() string {
 		builder.WriteString(predString)
 	}
 
+	if len(qd.groupBy) > 0 {
+		builder.WriteString(" group by ")
+		builder.WriteString(strings.Join(qd.groupBy, ", "))
+	}
+	if havingString := qd.having.String(); havingString != "" {
+		builder.WriteString(" having ")
+		builder.WriteString(havingString)
+	}
+
+	for _, union := range qd.unions {
+		builder.WriteString(" ")
+		builder.WriteString(union.String())
+	}
+
+	for i, order := range qd.orderBy {
+		if i == 0 {
+			builder.WriteString(" order by ")
+		} else {
+			builder.WriteString(", ")
+		}
+		builder.WriteString(order.String())
+	}
+
+	if qd.limit != NO_LIMIT {
+		builder.WriteString(fmt.Sprintf(" limit %d", qd.limit))
+	}
+
 	return builder.String()
 }
+
+// Returns a copy of the query with each parameter placeholder
+// replaced by the value bound to it
+func (qd *QueryData) Bind(params []*types.Constant) *QueryData {
+	bound := *qd
+	bound.pred = qd.pred.Bind(params)
+	bound.having = qd.having.Bind(params)
+	bound.computed = nil
+	for _, cd := range qd.computed {
+		bound.computed = append(bound.computed, NewComputedData(cd.Name(), cd.Expression().Bind(params)))
+	}
+	bound.unions = nil
+	for _, union := range qd.unions {
+		bound.unions = append(bound.unions, NewUnionData(union.Query().Bind(params), union.All()))
+	}
+	bound.joins = nil
+	for _, join := range qd.joins {
+		bound.joins = append(bound.joins, NewJoinData(join.TableName(), join.On().Bind(params)))
+	}
+	return &bound
+}
+
+// Returns a copy of the query with each reference in its predicate to one
+// of the fields replaced by a parameter placeholder, so that a correlated
+// subquery referring to the fields of the statement around it can be bound
+// to their values, see query.Expression.Parameterize
+func (qd *QueryData) Parameterize(fields []string) *QueryData {
+	parameterized := *qd
+	parameterized.pred = qd.pred.Parameterize(fields)
+	parameterized.joins = nil
+	for _, join := range qd.joins {
+		parameterized.joins = append(parameterized.joins, NewJoinData(join.TableName(), join.On().Parameterize(fields)))
+	}
+	return &parameterized
+}
+
+// Binds the parameters of the query nested in a predicate, see query.SubqueryData
+func (qd *QueryData) BindSubquery(params []*types.Constant) query.SubqueryData {
+	return qd.Bind(params)
+}
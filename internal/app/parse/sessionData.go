@@ -0,0 +1,96 @@
+package parse
+
+// Holds the data for the SET command, which changes a setting of the current session
+type SetData struct {
+	name  string
+	value string
+}
+
+func NewSetData(name string, value string) *SetData {
+	return &SetData{
+		name:  name,
+		value: value,
+	}
+}
+
+// Returns the lowercase name of the setting
+func (sd *SetData) Name() string {
+	return sd.name
+}
+
+func (sd *SetData) Value() string {
+	return sd.value
+}
+
+// Holds the data for the SHOW command, which shows a setting of the current session
+type ShowData struct {
+	name string
+}
+
+func NewShowData(name string) *ShowData {
+	return &ShowData{
+		name: name,
+	}
+}
+
+// Returns the lowercase name of the setting
+func (sd *ShowData) Name() string {
+	return sd.name
+}
+
+// Holds the data for the SHOW TABLES command, which lists
+// the tables and views of the current database
+type ShowTablesData struct{}
+
+func NewShowTablesData() *ShowTablesData {
+	return &ShowTablesData{}
+}
+
+// Holds the data for the DESCRIBE command, which lists
+// the columns of a table or view
+type DescribeData struct {
+	tableName string
+}
+
+func NewDescribeData(tableName string) *DescribeData {
+	return &DescribeData{tableName: tableName}
+}
+
+func (dd *DescribeData) TableName() string {
+	return dd.tableName
+}
+
+// Holds the data for the BEGIN command, which starts a transaction
+// lasting until COMMIT or ROLLBACK
+type BeginData struct {
+	isolationLevel string
+}
+
+func NewBeginData() *BeginData {
+	return &BeginData{}
+}
+
+// Creates the data of a BEGIN command naming the isolation level of the transaction
+func NewBeginDataWithIsolation(isolationLevel string) *BeginData {
+	return &BeginData{isolationLevel: isolationLevel}
+}
+
+// Returns the isolation level of the transaction, such as "read committed",
+// or an empty string for the session's
+func (bd *BeginData) IsolationLevel() string {
+	return bd.isolationLevel
+}
+
+// Holds the data for the COMMIT command
+type CommitData struct{}
+
+func NewCommitData() *CommitData {
+	return &CommitData{}
+}
+
+// Holds the data for the ROLLBACK command
+type RollbackData struct{}
+
+func NewRollbackData() *RollbackData {
+	return &RollbackData{}
+}
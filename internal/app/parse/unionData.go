@@ -0,0 +1,32 @@
+package parse
+
+// Holds a query combined with the queries before it with UNION, which
+// removes duplicate records, or UNION ALL, which keeps them
+type UnionData struct {
+	query *QueryData
+	all   bool
+}
+
+func NewUnionData(query *QueryData, all bool) *UnionData {
+	return &UnionData{
+		query: query,
+		all:   all,
+	}
+}
+
+// Returns the combined query. It has no ORDER BY or LIMIT clause of its own.
+func (ud *UnionData) Query() *QueryData {
+	return ud.query
+}
+
+// Reports whether duplicate records are kept, as for UNION ALL
+func (ud *UnionData) All() bool {
+	return ud.all
+}
+
+func (ud *UnionData) String() string {
+	if ud.all {
+		return "union all " + ud.query.String()
+	}
+	return "union " + ud.query.String()
+}
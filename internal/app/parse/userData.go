@@ -0,0 +1,50 @@
+package parse
+
+// Holds the data for the CREATE USER command
+type CreateUserData struct {
+	userName  string
+	password  string
+	superuser bool
+}
+
+func NewCreateUserData(userName string, password string, superuser bool) *CreateUserData {
+	return &CreateUserData{
+		userName:  userName,
+		password:  password,
+		superuser: superuser,
+	}
+}
+
+func (cd *CreateUserData) UserName() string {
+	return cd.userName
+}
+
+func (cd *CreateUserData) Password() string {
+	return cd.password
+}
+
+// Returns true if the user is created as a superuser
+func (cd *CreateUserData) Superuser() bool {
+	return cd.superuser
+}
+
+// Holds the data for the ALTER USER command, which changes a user's password
+type AlterUserData struct {
+	userName string
+	password string
+}
+
+func NewAlterUserData(userName string, password string) *AlterUserData {
+	return &AlterUserData{
+		userName: userName,
+		password: password,
+	}
+}
+
+func (ad *AlterUserData) UserName() string {
+	return ad.userName
+}
+
+func (ad *AlterUserData) Password() string {
+	return ad.password
+}
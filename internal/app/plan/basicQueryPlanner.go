@@ -5,6 +5,7 @@ import (
 	"centauri/internal/app/metadata"
 	"centauri/internal/app/parse"
 	"centauri/internal/app/tx"
+	"fmt"
 )
 
 // Implements the QueryPlanner interface and provides functionality to create
@@ -26,7 +27,11 @@ func NewBasicQueryPlanner(mdm *metadata.MetaDataManager) *BasicQueryPlanner {
 //   - tx:   transaction context in which the plan will be executed
 //
 // Returns:   Plan interdace representing the execution strategy
-func (bqp *BasicQueryPlanner) CreatePlan(data *parse.QueryData, tx *tx.Transaction) interfaces.Plan {
+func (bqp *BasicQueryPlanner) CreatePlan(data *parse.QueryData, tx *tx.Transaction) (interfaces.Plan, error) {
+	if len(data.Unions()) > 0 {
+		return CreateUnionPlan(tx, data, bqp.CreatePlan)
+	}
+
 	// Create plans array to hold individual table/view plans
 	plans := []interfaces.Plan{}
 
@@ -35,21 +40,54 @@ func (bqp *BasicQueryPlanner) CreatePlan(data *parse.QueryData, tx *tx.Transacti
 		// Check if the table name refers to a view
 		viewDef := bqp.mdm.GetViewDef(tableName, tx)
 
-		if viewDef != "" {
+		if tableName == metadata.RELATION_SIZES_VIEW {
+			// Handle the system view reporting table and index sizes
+			if data.AsOf() != nil {
+				return nil, fmt.Errorf("%s reports the current sizes and can't be read %s", tableName, data.AsOf())
+			}
+			sizesPlan, err := NewRelationSizesPlan(tx, bqp.mdm)
+			if err != nil {
+				return nil, err
+			}
+			plans = append(plans, sizesPlan)
+		} else if viewDef != "" {
 			// Handle view - recursively plan the view definition
 			parser := parse.NewParser(viewDef)
-			viewData := parser.Query()
-			plans = append(plans, bqp.CreatePlan(viewData, tx))
+			viewData, err := parser.Query()
+			if err != nil {
+				return nil, fmt.Errorf("view %s: %w", tableName, err)
+			}
+			for _, operand := range viewData.Operands() {
+				if operand.AsOf() == nil {
+					operand.SetAsOf(data.AsOf())
+				}
+			}
+			viewPlan, err := bqp.CreatePlan(viewData, tx)
+			if err != nil {
+				return nil, err
+			}
+			plans = append(plans, viewPlan)
 		} else {
-			// Handle base table - create a table plan
-			plans = append(plans, NewTablePlan(tx, tableName, bqp.mdm))
+			// Handle base table - create a table plan, scanning only
+			// the partitions that can hold records satisfying the predicate
+			tablePlan, err := NewTablePlan(tx, tableName, bqp.mdm)
+			if err != nil {
+				return nil, err
+			}
+			pruned := tablePlan.(*TablePlan).Prune(data.Pred())
+			if asOf := data.AsOf(); asOf != nil {
+				// Read the records as they were at the point
+				plans = append(plans, NewHistoryPlan(pruned, HistoryPoint(asOf)))
+			} else {
+				plans = append(plans, pruned)
+			}
 		}
 	}
 
 	// Create the product of all table plans
 	// Start with the first plan
 	if len(plans) == 0 {
-		return nil // Or handle empty plans case appropriately
+		return nil, fmt.Errorf("query has no tables")
 	}
 
 	p := plans[0]
@@ -61,6 +99,22 @@ func (bqp *BasicQueryPlanner) CreatePlan(data *parse.QueryData, tx *tx.Transacti
 	// Add a selection plan for the predicate
 	p = NewSelectPlan(p, data.Pred())
 
-	// Project on the field name
-	return NewProjectPlan(p, data.Fields())
+	// Group the records and compute the aggregates, if any, keeping the
+	// groups satisfying the HAVING clause
+	p, err := AggregatePlan(tx, p, data, bqp.mdm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compute the fields of the select list given by expressions, if any
+	p = AddExtend(p, data)
+
+	// Sort the records by the ORDER BY clause, if any
+	p, err = AddOrderBy(tx, p, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Project on the field name, and stop at the limit
+	return AddLimit(NewProjectPlan(p, data.Fields()), data), nil
 }
@@ -4,15 +4,35 @@ import (
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/metadata"
 	"centauri/internal/app/parse"
+	"centauri/internal/app/record"
 	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
 )
 
+// Returned when an update command targets records that can't be changed
+var ErrNotUpdatable = errors.New("not updatable")
+
+// Opens the plan for changing the records of the table. Fails with
+// ErrNotUpdatable if the plan's scan can't change them.
+func OpenUpdateScan(p interfaces.Plan, tableName string) (interfaces.UpdateScan, error) {
+	s := p.Open()
+	us, ok := s.(interfaces.UpdateScan)
+	if !ok {
+		s.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNotUpdatable, tableName)
+	}
+	return us, nil
+}
+
 // Implements basic database update operations like delete, modify, insert
 // and DDL operations like create table, view and index. It uses MetadDataManager
 // to handle table metadata operations.
 type BasicUpdatePlanner struct {
 	UpdatePlanner
-	mdm *metadata.MetaDataManager
+	mdm   *metadata.MetaDataManager
+	newTx func() *tx.Transaction // starts the transactions of batched bulk loads
 }
 
 func NewBasicUpdatePlanner(mdm *metadata.MetaDataManager) *BasicUpdatePlanner {
@@ -21,6 +41,12 @@ func NewBasicUpdatePlanner(mdm *metadata.MetaDataManager) *BasicUpdatePlanner {
 	}
 }
 
+// Sets the function used to start a transaction for each batch of a bulk load.
+// Without it, COPY loads all records in the transaction of the statement.
+func (bup *BasicUpdatePlanner) SetTxFactory(newTx func() *tx.Transaction) {
+	bup.newTx = newTx
+}
+
 // Performs a delete operation on records that match a given predicate.
 // This operation follows these steps:
 // 1. Creates a table plan for accessing the target table
@@ -34,28 +60,38 @@ func NewBasicUpdatePlanner(mdm *metadata.MetaDataManager) *BasicUpdatePlanner {
 //
 //	data might contain: DELETE FROM students WHERE age > 20
 //	This would delete all student records where age is greater than 20
-func (bup *BasicUpdatePlanner) ExecuteDelete(data *parse.DeleteData, tx *tx.Transaction) int {
+func (bup *BasicUpdatePlanner) ExecuteDelete(data *parse.DeleteData, tx *tx.Transaction) (int, error) {
 	// Create a table plan for accessing the specified table
 	// This provides the basic infrastructure for reading table records
-	p := NewTablePlan(tx, data.TableName(), bup.mdm)
+	p, err := NewTablePlan(tx, data.TableName(), bup.mdm)
+	if err != nil {
+		return 0, err
+	}
 
 	// Add a selection plan that filters records based on the predicate
-	// This ensures we only process records that match our WHERE clause
-	sp := NewSelectPlan(p, data.Pred())
+	// This ensures we only process records that match our WHERE clause.
+	// Partitions that can't hold such records aren't scanned.
+	sp := NewSelectPlan(p.(*TablePlan).Prune(data.Pred()), data.Pred())
 
 	// Open an update scan that allows both reading and writing records
-	// Type assertion ensures we have update capabilities
-	us := sp.Open().(interfaces.UpdateScan)
+	us, err := OpenUpdateScan(sp, data.TableName())
+	if err != nil {
+		return 0, err
+	}
+	defer us.Close()
 	count := 0
 
 	// Delete each matching record
 	for us.Next() {
-		us.Delete()
+		if err := us.Delete(); err != nil {
+			return count, err
+		}
 		count++
 	}
 
-	us.Close()
-	return count
+	bup.mdm.AdjustRowCount(data.TableName(), -count, tx)
+	bup.mdm.RecordModification(data.TableName(), count)
+	return count, nil
 }
 
 // Performs an update operation on records that match a given predicate.
@@ -71,49 +107,144 @@ func (bup *BasicUpdatePlanner) ExecuteDelete(data *parse.DeleteData, tx *tx.Tran
 // Example:
 //
 //	ModifyData might contain: UPDATE students SET age = 21 WHERE id = 1
-func (bup *BasicUpdatePlanner) ExecuteModify(data *parse.ModifyData, tx *tx.Transaction) int {
-	p := NewTablePlan(tx, data.TableName(), bup.mdm)
+func (bup *BasicUpdatePlanner) ExecuteModify(data *parse.ModifyData, tx *tx.Transaction) (int, error) {
+	p, err := NewTablePlan(tx, data.TableName(), bup.mdm)
+	if err != nil {
+		return 0, err
+	}
 
-	sp := NewSelectPlan(p, data.Pred())
+	tp := p.(*TablePlan)
+	layout := tp.Layout()
+	changed := []string{data.TargetField()}
+	if err := CheckAssignable(layout, changed); err != nil {
+		return 0, err
+	}
 
-	us := sp.Open().(interfaces.UpdateScan)
+	sp := NewSelectPlan(tp.Prune(data.Pred()), data.Pred())
+
+	us, err := OpenUpdateScan(sp, data.TableName())
+	if err != nil {
+		return 0, err
+	}
+	defer us.Close()
 	count := 0
 
+	// Records whose partition key changes move to another partition
+	movesPartition := tp.PartitionKey() == data.TargetField()
+	mover := NewPartitionMover(tp)
+
 	for us.Next() {
+		oldVal := us.GetVal(data.TargetField())
 		val := data.NewValue().Evaluate(us)
-		us.SetVal(data.TargetField(), val)
+		if err := CheckNotNull(layout.Schema(), data.TargetField(), val); err != nil {
+			return count, err
+		}
+		if err := us.SetVal(data.TargetField(), val); err != nil {
+			return count, err
+		}
+		// Recompute the stored fields generated from the changed one
+		if _, err := SetGeneratedFields(us, layout, changed); err != nil {
+			return count, err
+		}
+		if movesPartition {
+			if err := mover.Check(us, oldVal); err != nil {
+				return count, err
+			}
+		}
 		count++
 	}
+	if err := mover.Finish(); err != nil {
+		return count, err
+	}
 
-	us.Close()
-	return count
+	bup.mdm.RecordModification(data.TableName(), count)
+	return count, nil
 }
 
 // Performs an insert operation into the specified table.
-// This operation follows these steps:
-// 1. Creates a table plan for the target table
-// 2. Creates a new record
-// 3. Sets values for all specific fields
+// This operation follows these steps for each record of the VALUES list:
+// 1. Completes the record with the next value of its AUTO_INCREMENT field
+// and the defaults of the fields left out
+// 2. Routes the record to the table, or to its partition
+// 3. Creates a new record
+// 4. Sets values for all specific fields
+// The first AUTO_INCREMENT value generated is recorded as the last insert id of the transaction.
 // Returns :
-//   - 1 If successfull (since only record is inserted at a time)
+//   - the number of records inserted
 //
 // Example:
 //
-//	InsertData might contain: INSERT INTO students (id, name, age) VALUES (1, "John", 20)
-func (bup *BasicUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx *tx.Transaction) int {
-	p := NewTablePlan(tx, data.TableName(), bup.mdm)
-	us := p.Open().(interfaces.UpdateScan)
+//	InsertData might contain: INSERT INTO students (id, name, age) VALUES (1, "John", 20), (2, "Jane", 21)
+func (bup *BasicUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx *tx.Transaction) (int, error) {
+	p, err := NewTablePlan(tx, data.TableName(), bup.mdm)
+	if err != nil {
+		return 0, err
+	}
+	layout := p.(*TablePlan).Layout()
+	if err := CheckAssignable(layout, data.Fields()); err != nil {
+		return 0, err
+	}
+
+	// The records are inserted in the transaction of the statement,
+	// so a failing one undoes the others when it rolls back
+	count := 0
+	var firstID int64
+	for _, values := range data.Rows() {
+		id, err := bup.insertRow(p.(*TablePlan), data.TableName(), data.Fields(), values, tx)
+		if err != nil {
+			return count, err
+		}
+		if firstID == 0 {
+			firstID = id
+		}
+		count++
+	}
+	if firstID != 0 {
+		tx.SetLastInsertID(firstID)
+	}
+
+	bup.mdm.AdjustRowCount(data.TableName(), count, tx)
+	bup.mdm.RecordModification(data.TableName(), count)
+	return count, nil
+}
+
+// Inserts a record with the values of the fields into the table.
+// The fields left out take their defaults. Returns the AUTO_INCREMENT
+// value generated for the record, 0 if none was.
+func (bup *BasicUpdatePlanner) insertRow(p *TablePlan, tableName string, fields []string, values []*types.Constant, tx *tx.Transaction) (int64, error) {
+	fields, values, id, err := ApplyAutoIncrement(bup.mdm, tableName, p.Layout(), fields, values, tx)
+	if err != nil {
+		return 0, err
+	}
+	fields, values, err = ApplyDefaults(p.Layout(), fields, values)
+	if err != nil {
+		return 0, err
+	}
+
+	// The record of a partitioned table goes in the partition its key routes to
+	tp, err := p.Route(fields, values)
+	if err != nil {
+		return 0, err
+	}
 
 	// Open an update scan
-	us.Insert()
+	us, err := OpenUpdateScan(tp, tableName)
+	if err != nil {
+		return 0, err
+	}
+	defer us.Close()
 
-	for i, fieldName := range data.Fields() {
-		val := data.Values()[i]
-		us.SetVal(fieldName, val)
+	if err := us.Insert(); err != nil {
+		return 0, err
 	}
 
-	us.Close()
-	return 1
+	for i, fieldName := range fields {
+		if err := us.SetVal(fieldName, values[i]); err != nil {
+			return 0, err
+		}
+	}
+	_, err = SetGeneratedFields(us, p.Layout(), nil)
+	return id, err
 }
 
 // Creates a new table in the database.
@@ -122,9 +253,35 @@ func (bup *BasicUpdatePlanner) ExecuteInsert(data *parse.InsertData, tx *tx.Tran
 // 2. Updates the metadata catalog
 // Returns:
 //   - 0 on successful creation
-func (bup *BasicUpdatePlanner) ExecuteCreateTable(data *parse.CreateTableData, tx *tx.Transaction) int {
-	bup.mdm.CreateTable(data.TableName(), data.NewSchema(), tx)
-	return 0
+//   - metadata.ErrTableExists if a table or view of the name exists
+func (bup *BasicUpdatePlanner) ExecuteCreateTable(data *parse.CreateTableData, tx *tx.Transaction) (int, error) {
+	options, err := record.ParseStorageOptions(data.Options())
+	if err != nil {
+		return 0, err
+	}
+	if err := options.Validate(tx.BlockSize()); err != nil {
+		return 0, err
+	}
+	if err := record.ValidateCollations(data.NewSchema()); err != nil {
+		return 0, err
+	}
+	if err := ValidateGenerated(data.NewSchema()); err != nil {
+		return 0, err
+	}
+	if err := ValidateFieldOptions(data.NewSchema()); err != nil {
+		return 0, err
+	}
+	options.Partitioning = NewPartitioning(data.Partitioning())
+	if options.Partitioning != nil {
+		if err := options.Partitioning.Validate(data.NewSchema()); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := bup.mdm.CreateTableWithOptions(data.TableName(), data.NewSchema(), options, tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
 }
 
 // Creates a new view in the database.
@@ -133,13 +290,125 @@ func (bup *BasicUpdatePlanner) ExecuteCreateTable(data *parse.CreateTableData, t
 // 2. Updates the metadata catalog
 // Returns:
 //   - 0 on successful creation
-func (bup *BasicUpdatePlanner) ExecuteCreateView(data *parse.CreateViewData, tx *tx.Transaction) int {
-	bup.mdm.CreateView(data.ViewName(), data.ViewDef(), tx)
-	return 0
+//   - metadata.ErrTableExists if a table or view of the name exists
+func (bup *BasicUpdatePlanner) ExecuteCreateView(data *parse.CreateViewData, tx *tx.Transaction) (int, error) {
+	if err := bup.mdm.CreateView(data.ViewName(), data.ViewDef(), data.Tables(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
 }
 
 // Creates a new index on a table field
-func (bup *BasicUpdatePlanner) ExecuteCreateIndex(data *parse.CreateIndexData, tx *tx.Transaction) int {
-	bup.mdm.CreateIndex(data.IndexName(), data.TableName(), data.FieldName(), tx)
-	return 0
+// Returns metadata.ErrTableNotFound if the table doesn't exist.
+func (bup *BasicUpdatePlanner) ExecuteCreateIndex(data *parse.CreateIndexData, tx *tx.Transaction) (int, error) {
+	layout, err := bup.mdm.GetLayout(data.TableName(), tx)
+	if err != nil {
+		return 0, err
+	}
+	if err := CheckIndexable(layout, data.FieldName()); err != nil {
+		return 0, err
+	}
+	if err := bup.mdm.CreateIndex(data.IndexName(), data.TableName(), data.FieldName(), data.IndexType(), data.IsUnique(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Removes a table, its records and the files storing them.
+// Returns metadata.ErrTableNotFound if the table doesn't exist, and
+// metadata.ErrDependentObjects if views or indexes depend on it without CASCADE.
+func (bup *BasicUpdatePlanner) ExecuteDropTable(data *parse.DropTableData, tx *tx.Transaction) (int, error) {
+	if err := bup.mdm.DropTable(data.TableName(), data.Cascade(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Removes an index and the files storing it.
+// Returns metadata.ErrIndexNotFound if the index doesn't exist.
+func (bup *BasicUpdatePlanner) ExecuteDropIndex(data *parse.DropIndexData, tx *tx.Transaction) (int, error) {
+	if err := bup.mdm.DropIndex(data.IndexName(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Removes a view.
+// Returns metadata.ErrTableNotFound if the view doesn't exist, and
+// metadata.ErrDependentObjects if other views are defined on it without CASCADE.
+func (bup *BasicUpdatePlanner) ExecuteDropView(data *parse.DropViewData, tx *tx.Transaction) (int, error) {
+	if err := bup.mdm.DropView(data.ViewName(), data.Cascade(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Adds a field to a table. The field takes its default in the existing
+// records, or is null if it has none, unless it is a stored generated
+// field, which is computed for them.
+// Returns metadata.ErrTableNotFound if the table doesn't exist, and
+// metadata.ErrFieldExists if it already has a field of the name.
+func (bup *BasicUpdatePlanner) ExecuteAddColumn(data *parse.AddColumnData, tx *tx.Transaction) (int, error) {
+	layout, err := bup.mdm.GetLayout(data.TableName(), tx)
+	if err != nil {
+		return 0, err
+	}
+	if err := ValidateAddedField(layout, data.Field()); err != nil {
+		return 0, err
+	}
+	if err := bup.mdm.AddColumn(data.TableName(), data.Field(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Removes a field from a table along with its values.
+// Returns metadata.ErrFieldNotFound if the table has no such field, and
+// metadata.ErrDependentObjects if an index, view or generated field uses it.
+func (bup *BasicUpdatePlanner) ExecuteDropColumn(data *parse.DropColumnData, tx *tx.Transaction) (int, error) {
+	if err := bup.mdm.DropColumn(data.TableName(), data.FieldName(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Loads the records of a CSV file into a table using the bulk load path.
+// Returns the number of records loaded.
+func (bup *BasicUpdatePlanner) ExecuteCopy(data *parse.CopyData, tx *tx.Transaction) (int, error) {
+	return NewBulkLoader(bup.mdm, bup.newTx).Copy(data, tx)
+}
+
+// Creates a new user.
+// Returns metadata.ErrUserExists if a user with the name already exists.
+func (bup *BasicUpdatePlanner) ExecuteCreateUser(data *parse.CreateUserData, tx *tx.Transaction) (int, error) {
+	if err := bup.mdm.CreateUser(data.UserName(), data.Password(), data.Superuser(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Changes the password of a user.
+// Returns metadata.ErrUserNotFound if the user doesn't exist.
+func (bup *BasicUpdatePlanner) ExecuteAlterUser(data *parse.AlterUserData, tx *tx.Transaction) (int, error) {
+	if err := bup.mdm.AlterUserPassword(data.UserName(), data.Password(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Gives a user privileges on a table or view.
+// Returns metadata.ErrUserNotFound or metadata.ErrTableNotFound if either doesn't exist.
+func (bup *BasicUpdatePlanner) ExecuteGrant(data *parse.GrantData, tx *tx.Transaction) (int, error) {
+	if err := bup.mdm.Grant(data.UserName(), data.TableName(), data.Privileges(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Takes privileges on a table or view away from a user.
+func (bup *BasicUpdatePlanner) ExecuteRevoke(data *parse.RevokeData, tx *tx.Transaction) (int, error) {
+	if err := bup.mdm.Revoke(data.UserName(), data.TableName(), data.Privileges(), tx); err != nil {
+		return 0, err
+	}
+	return 0, nil
 }
@@ -0,0 +1,475 @@
+package plan
+
+import (
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The number of records loaded in each transaction by default
+const BULK_BATCH_SIZE = 1000
+
+// Options controlling a bulk load, as given in the WITH clause of COPY
+type BulkOptions struct {
+	Header    bool // the first line of the file names the columns and isn't loaded
+	BatchSize int  // records per transaction, 0 to load everything in one transaction
+	Logging   bool // log the inserted records so a failed batch can be rolled back
+}
+
+func DefaultBulkOptions() BulkOptions {
+	return BulkOptions{
+		Header:    false,
+		BatchSize: BULK_BATCH_SIZE,
+		Logging:   true,
+	}
+}
+
+// Parses the options of a COPY statement, e.g.
+// WITH (header=on, batchsize=5000, logging=off)
+func ParseBulkOptions(options map[string]string) (BulkOptions, error) {
+	opts := DefaultBulkOptions()
+
+	for name, value := range options {
+		switch strings.ToLower(name) {
+		case "header":
+			on, err := parseSwitch(name, value)
+			if err != nil {
+				return opts, err
+			}
+			opts.Header = on
+		case "logging":
+			on, err := parseSwitch(name, value)
+			if err != nil {
+				return opts, err
+			}
+			opts.Logging = on
+		case "batchsize":
+			size, err := strconv.Atoi(value)
+			if err != nil || size < 0 {
+				return opts, fmt.Errorf("invalid batchsize %q: must be a non-negative integer", value)
+			}
+			opts.BatchSize = size
+		default:
+			return opts, fmt.Errorf("unknown copy option %q", name)
+		}
+	}
+	return opts, nil
+}
+
+func parseSwitch(name string, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "true":
+		return true, nil
+	case "off", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid %s %q: must be on or off", name, value)
+}
+
+// Loads records into a table much faster than individual INSERT statements:
+//   - records are written through a single table scan per batch rather than
+//     planning an insert for each record
+//   - index entries are collected while loading and inserted at the end of each
+//     batch, ordered by key, instead of after every record
+//   - each batch can be committed in its own transaction, so a large load doesn't
+//     hold every modified buffer and lock until the end
+//   - only the slots the records take are logged, not their values, and
+//     logging can be turned off altogether
+//
+// When batches are committed separately, the records of completed batches
+// remain in the table if a later batch fails.
+type BulkLoader struct {
+	mdm   *metadata.MetaDataManager
+	newTx func() *tx.Transaction // starts the transaction of each batch, nil to use the caller's
+}
+
+func NewBulkLoader(mdm *metadata.MetaDataManager, newTx func() *tx.Transaction) *BulkLoader {
+	return &BulkLoader{
+		mdm:   mdm,
+		newTx: newTx,
+	}
+}
+
+// Executes a COPY statement by loading the CSV file it names.
+// The path of the file is relative to the working directory of the server.
+func (bl *BulkLoader) Copy(data *parse.CopyData, tx *tx.Transaction) (int, error) {
+	opts, err := ParseBulkOptions(data.Options())
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Open(data.FileName())
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", data.FileName(), err)
+	}
+	defer f.Close()
+
+	return bl.LoadCSV(data.TableName(), data.Fields(), f, opts, tx)
+}
+
+// An index entry waiting to be inserted at the end of a batch
+type pendingEntry struct {
+	val *types.Constant
+	rid *types.RID
+}
+
+// Supplies the records of a bulk load. Each call returns the values of the
+// next record, one for each field being loaded, and io.EOF after the last one.
+type RecordSource func() ([]*types.Constant, error)
+
+// Loads CSV records from r into the fields of the table.
+// If fields is empty, the columns are matched by the header line when
+// there is one, otherwise by the order of the table's fields that aren't generated.
+// Returns the number of records loaded.
+func (bl *BulkLoader) LoadCSV(tableName string, fields []string, r io.Reader, opts BulkOptions, tx *tx.Transaction) (int, error) {
+	layout, err := bl.mdm.GetLayout(tableName, tx)
+	if err != nil {
+		return 0, err
+	}
+	sch := layout.Schema()
+
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	line := 0
+	if opts.Header {
+		header, err := reader.Read()
+		if err != nil {
+			return 0, fmt.Errorf("failed to read header: %w", err)
+		}
+		line++
+
+		if len(fields) == 0 {
+			for _, name := range header {
+				fields = append(fields, strings.ToLower(strings.TrimSpace(name)))
+			}
+		}
+	}
+	if len(fields) == 0 {
+		fields = AssignableFields(sch)
+	}
+	reader.FieldsPerRecord = len(fields)
+
+	return bl.Load(tableName, fields, func() ([]*types.Constant, error) {
+		values, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("line %d: %w", line+1, err)
+		}
+		line++
+
+		vals := make([]*types.Constant, len(fields))
+		for i, fieldName := range fields {
+			val, err := parseValue(sch, fieldName, values[i])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			vals[i] = val
+		}
+		return vals, nil
+	}, opts, tx)
+}
+
+// Loads the records of next into the fields of the table, through one
+// table scan per batch that logs only the slots the records take.
+// If fields is empty, the records hold a value for each field of the
+// table that isn't generated, in order. Fields missing from the records are left empty.
+// A batch with a record that has the key of another in a unique index fails
+// with index.ErrUniqueViolation. Returns the number of records loaded.
+func (bl *BulkLoader) Load(tableName string, fields []string, next RecordSource, opts BulkOptions, tx *tx.Transaction) (int, error) {
+	layout, err := bl.mdm.GetLayout(tableName, tx)
+	if err != nil {
+		return 0, err
+	}
+	sch := layout.Schema()
+
+	if len(fields) == 0 {
+		fields = AssignableFields(sch)
+	}
+	for _, fieldName := range fields {
+		if !sch.HasField(fieldName) {
+			return 0, fmt.Errorf("field %s does not exist in table %s", fieldName, tableName)
+		}
+	}
+	if err := CheckAssignable(layout, fields); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	done := false
+	for !done {
+		batchTx := tx
+		if bl.newTx != nil && opts.BatchSize > 0 {
+			batchTx = bl.newTx()
+		}
+
+		// The indexes are opened in the transaction of the batch.
+		// Only single-field indexes are maintained, see MetaDataManager.CreateCompositeIndex
+		indexes := make(map[string]metadata.IndexInfo)
+		for key, ii := range bl.mdm.GetIndexInfo(tableName, batchTx) {
+			if !ii.IsComposite() {
+				indexes[key] = ii
+			}
+		}
+
+		scans := &batchScans{tx: batchTx, tableName: tableName, layout: layout, opts: opts, scans: make(map[string]*record.TableScan)}
+
+		pending := make(map[string][]pendingEntry)
+		count := 0
+
+		for opts.BatchSize == 0 || count < opts.BatchSize {
+			vals, err := next()
+			if errors.Is(err, io.EOF) {
+				done = true
+				break
+			}
+			if err == nil {
+				err = insertRecord(bl.mdm, scans, fields, vals, indexes, pending)
+				if err != nil {
+					err = fmt.Errorf("record %d: %w", total+count+1, err)
+				}
+			}
+			if err != nil {
+				scans.close()
+				if batchTx != tx {
+					batchTx.Rollback()
+				}
+				return total, err
+			}
+			count++
+		}
+		scans.close()
+
+		if err := insertIndexEntries(indexes, pending); err != nil {
+			if batchTx != tx {
+				batchTx.Rollback()
+			}
+			return total, err
+		}
+
+		if batchTx != tx {
+			batchTx.Commit()
+		}
+		total += count
+	}
+
+	bl.mdm.AdjustRowCount(tableName, total, tx)
+	bl.mdm.RecordModification(tableName, total)
+	return total, nil
+}
+
+// The table scans a batch writes its records through: one for the table,
+// or one for each partition the batch writes to
+type batchScans struct {
+	tx        *tx.Transaction
+	tableName string
+	layout    *record.Layout
+	opts      BulkOptions
+	scans     map[string]*record.TableScan
+}
+
+// Returns the scan for the partition the record with the values goes in
+func (bs *batchScans) scanFor(fields []string, vals []*types.Constant) (*record.TableScan, error) {
+	table := bs.tableName
+	if p := bs.layout.Options().Partitioning; p != nil {
+		part, err := routeRecord(bs.layout, fields, vals)
+		if err != nil {
+			return nil, err
+		}
+		table = record.PartitionTable(bs.tableName, p.Partitions[part].Name)
+	}
+
+	ts, ok := bs.scans[table]
+	if !ok {
+		ts = record.NewTableScan(bs.tx, table, bs.layout)
+		ts.SetLogging(bs.opts.Logging)
+		ts.SetMinimalLogging()
+		bs.scans[table] = ts
+	}
+	return ts, nil
+}
+
+func (bs *batchScans) close() {
+	for _, ts := range bs.scans {
+		ts.Close()
+	}
+}
+
+// Inserts a record holding the values into the table, or the partition
+// its key routes to, computes its stored generated fields and collects
+// the index entries for it. Fields missing from the record take the next
+// value of their sequence if AUTO_INCREMENT, their defaults, or are left empty.
+func insertRecord(mdm *metadata.MetaDataManager, scans *batchScans, fields []string, vals []*types.Constant, indexes map[string]metadata.IndexInfo, pending map[string][]pendingEntry) error {
+	layout := scans.layout
+	sch := layout.Schema()
+	if len(vals) != len(fields) {
+		return fmt.Errorf("expected %d values, got %d", len(fields), len(vals))
+	}
+	for i, fieldName := range fields {
+		val, err := checkValue(sch, fieldName, vals[i])
+		if err != nil {
+			return err
+		}
+		vals[i] = val
+	}
+	fields, vals, _, err := ApplyAutoIncrement(mdm, scans.tableName, layout, fields, vals, scans.tx)
+	if err != nil {
+		return err
+	}
+	fields, vals, err = ApplyDefaults(layout, fields, vals)
+	if err != nil {
+		return err
+	}
+
+	ts, err := scans.scanFor(fields, vals)
+	if err != nil {
+		return err
+	}
+	ts.Insert()
+	rid, err := ts.GetRID()
+	if err != nil {
+		return err
+	}
+
+	for i, fieldName := range fields {
+		if err := ts.SetVal(fieldName, vals[i]); err != nil {
+			return err
+		}
+	}
+
+	if _, err := SetGeneratedFields(ts, layout, nil); err != nil {
+		return err
+	}
+
+	for key := range indexes {
+		// The field of a single-field index is its key
+		pending[key] = append(pending[key], pendingEntry{val: ts.GetVal(key), rid: rid})
+	}
+	return nil
+}
+
+// Checks that the value can be stored in the field and returns it
+// with the collation of the field
+func checkValue(sch *schema.Schema, fieldName string, val *types.Constant) (*types.Constant, error) {
+	// A null fits in any field
+	if val.IsNull() {
+		return types.NewConstantNull(), nil
+	}
+	if sch.DataType(fieldName) == schema.INTEGER {
+		if val.AsInt() == nil {
+			return nil, fmt.Errorf("%w: %s is an integer field, got %v", record.ErrFieldType, fieldName, val)
+		}
+		return val, nil
+	}
+	if sch.DataType(fieldName) == schema.BIGINT {
+		n, ok := val.AsInteger()
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is a bigint field, got %v", record.ErrFieldType, fieldName, val)
+		}
+		return types.NewConstantLong(n), nil
+	}
+	if sch.DataType(fieldName) == schema.FLOAT {
+		f, ok := val.AsNumber()
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is a float field, got %v", record.ErrFieldType, fieldName, val)
+		}
+		return types.NewConstantFloat(f), nil
+	}
+	if sch.DataType(fieldName) == schema.BOOLEAN {
+		if val.AsBool() == nil {
+			return nil, fmt.Errorf("%w: %s is a boolean field, got %v", record.ErrFieldType, fieldName, val)
+		}
+		return val, nil
+	}
+	if fieldType := sch.DataType(fieldName); fieldType.IsTemporal() {
+		t, err := record.ToTime(fieldType, val)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s is a %s field, got %v", record.ErrFieldType, fieldName, fieldType, val)
+		}
+		return t, nil
+	}
+
+	if val.AsString() == nil {
+		return nil, fmt.Errorf("%w: %s is a string field, got %v", record.ErrFieldType, fieldName, val)
+	}
+	if sch.DataType(fieldName) == schema.BLOB {
+		return val, nil
+	}
+	str := *val.AsString()
+	if len(str) > sch.Length(fieldName) {
+		return nil, fmt.Errorf("value %q is too long for field %s (maximum %d)", str, fieldName, sch.Length(fieldName))
+	}
+	return record.StringVal(sch, fieldName, str), nil
+}
+
+// Converts a CSV value to a constant of the field's type
+func parseValue(sch *schema.Schema, fieldName string, value string) (*types.Constant, error) {
+	if sch.DataType(fieldName) == schema.INTEGER {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q for field %s", value, fieldName)
+		}
+		return types.NewConstantInt(n), nil
+	}
+	if sch.DataType(fieldName) == schema.BIGINT {
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bigint %q for field %s", value, fieldName)
+		}
+		return types.NewConstantLong(n), nil
+	}
+	if sch.DataType(fieldName) == schema.FLOAT {
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q for field %s", value, fieldName)
+		}
+		return types.NewConstantFloat(f), nil
+	}
+	if sch.DataType(fieldName) == schema.BOOLEAN {
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %q for field %s", value, fieldName)
+		}
+		return types.NewConstantBool(b), nil
+	}
+	return types.NewConstantString(value), nil
+}
+
+// Inserts the collected entries into each index in key order,
+// so entries with the same key are written together.
+// Fails with index.ErrUniqueViolation if an entry has the key of
+// another in a unique index.
+func insertIndexEntries(indexes map[string]metadata.IndexInfo, pending map[string][]pendingEntry) error {
+	for key, entries := range pending {
+		order := materialize.Asc(key)
+		sort.SliceStable(entries, func(i, j int) bool {
+			return order.Compare(entries[i].val, entries[j].val) < 0
+		})
+
+		ii := indexes[key]
+		idx := ii.Open()
+		for _, entry := range entries {
+			if err := CheckUnique(&ii, idx, entry.val, entry.rid); err != nil {
+				idx.Close()
+				return err
+			}
+			idx.Insert(entry.val, entry.rid)
+		}
+		idx.Close()
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package plan
+
+import (
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"fmt"
+	"slices"
+)
+
+// Checks the NOT NULL, DEFAULT and AUTO_INCREMENT options of the fields of
+// a new table. A default must fit in the catalog and be a value of its field,
+// which can't be null if the field is declared NOT NULL. Generated fields are
+// computed, so they take none of the options. A table has at most one
+// AUTO_INCREMENT field, an integer or bigint without a default.
+func ValidateFieldOptions(sch *schema.Schema) error {
+	autoIncrement := ""
+	for _, fieldName := range sch.Fields() {
+		value := sch.Default(fieldName)
+		if sch.AutoIncrement(fieldName) {
+			if err := validateAutoIncrement(sch, fieldName, autoIncrement); err != nil {
+				return err
+			}
+			autoIncrement = fieldName
+		}
+		if sch.Generated(fieldName) != "" && (value != "" || sch.NotNull(fieldName)) {
+			return fmt.Errorf("%w: %s is generated and can't have a default or be declared NOT NULL", record.ErrGeneratedField, fieldName)
+		}
+		if value == "" {
+			continue
+		}
+		if len(value) > metadata.MAX_DEFAULT {
+			return fmt.Errorf("the default of %s is longer than %d characters", fieldName, metadata.MAX_DEFAULT)
+		}
+
+		val, err := parse.NewParser(value).DefaultValue()
+		if err != nil {
+			return err
+		}
+		if _, err := checkValue(sch, fieldName, val); err != nil {
+			return fmt.Errorf("default of %s: %w", fieldName, err)
+		}
+		if val.IsNull() && sch.NotNull(fieldName) {
+			return fmt.Errorf("%w: %s is declared NOT NULL and can't default to null", record.ErrNotNullable, fieldName)
+		}
+	}
+	return nil
+}
+
+// Checks the AUTO_INCREMENT field of a table, other being the one
+// found before it, if any
+func validateAutoIncrement(sch *schema.Schema, fieldName string, other string) error {
+	if sch.Generated(fieldName) != "" {
+		return fmt.Errorf("%w: %s is generated and can't be AUTO_INCREMENT", record.ErrGeneratedField, fieldName)
+	}
+	if fieldType := sch.DataType(fieldName); fieldType != schema.INTEGER && fieldType != schema.BIGINT {
+		return fmt.Errorf("%w: %s is a %s field and can't be AUTO_INCREMENT", record.ErrFieldType, fieldName, fieldType)
+	}
+	if sch.Default(fieldName) != "" {
+		return fmt.Errorf("%s is AUTO_INCREMENT and can't have a default", fieldName)
+	}
+	if other != "" {
+		return fmt.Errorf("%s and %s are both AUTO_INCREMENT, a table can have only one", other, fieldName)
+	}
+	return nil
+}
+
+// Fills the AUTO_INCREMENT fields a record to insert leaves out or sets to
+// null with the next values of their sequences, taken in the transaction.
+// A value set explicitly moves the sequence past it instead. Returns the
+// fields and values of the record and the value generated, 0 if none was.
+func ApplyAutoIncrement(mdm *metadata.MetaDataManager, tableName string, layout *record.Layout, fields []string, values []*types.Constant, tx *tx.Transaction) ([]string, []*types.Constant, int64, error) {
+	sch := layout.Schema()
+	var generated int64
+	for _, fieldName := range sch.Fields() {
+		if !sch.AutoIncrement(fieldName) {
+			continue
+		}
+
+		i := slices.Index(fields, fieldName)
+		if i >= 0 && !values[i].IsNull() {
+			// A value of the wrong type is rejected when the record is written
+			if n, ok := values[i].AsInteger(); ok {
+				mdm.ObserveSequenceValue(tableName, fieldName, n, tx)
+			}
+			continue
+		}
+
+		next, ok := mdm.NextSequenceValue(tableName, fieldName, tx)
+		if !ok {
+			return nil, nil, 0, fmt.Errorf("AUTO_INCREMENT field %s of %s has no sequence", fieldName, tableName)
+		}
+		val := types.NewConstantInteger(next)
+		if i >= 0 {
+			values = slices.Clone(values)
+			values[i] = val
+		} else {
+			fields = append(slices.Clip(fields), fieldName)
+			values = append(slices.Clip(values), val)
+		}
+		generated = next
+	}
+	return fields, values, generated, nil
+}
+
+// Returns the fields and values of a record to insert, completed with the
+// defaults of the fields they leave out. A field without a default is null,
+// unless it is generated or past the fields that can be null, which keep
+// the empty value of a new record. Fails with record.ErrNotNullable if a
+// field declared NOT NULL would be null.
+func ApplyDefaults(layout *record.Layout, fields []string, values []*types.Constant) ([]string, []*types.Constant, error) {
+	sch := layout.Schema()
+	for _, fieldName := range sch.Fields() {
+		if slices.Contains(fields, fieldName) {
+			continue
+		}
+		if val := layout.Default(fieldName); val != nil {
+			fields = append(slices.Clip(fields), fieldName)
+			values = append(slices.Clip(values), val)
+		} else if sch.NotNull(fieldName) {
+			return nil, nil, fmt.Errorf("%w: %s is declared NOT NULL and has no default", record.ErrNotNullable, fieldName)
+		} else if sch.Generated(fieldName) == "" && layout.NullBit(fieldName) >= 0 {
+			fields = append(slices.Clip(fields), fieldName)
+			values = append(slices.Clip(values), types.NewConstantNull())
+		}
+	}
+
+	for i, fieldName := range fields {
+		if err := CheckNotNull(sch, fieldName, values[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return fields, values, nil
+}
+
+// Fails with record.ErrNotNullable if the value is null
+// and the field is declared NOT NULL
+func CheckNotNull(sch *schema.Schema, fieldName string, val *types.Constant) error {
+	if val.IsNull() && sch.NotNull(fieldName) {
+		return fmt.Errorf("%w: %s is declared NOT NULL", record.ErrNotNullable, fieldName)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package plan
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"fmt"
+	"strings"
+)
+
+// Returns a plan as a tree, one operator per line, with the number of
+// blocks each operator is estimated to access and records to output.
+// Operators that can't describe themselves are shown by their type.
+//
+//	project name (blocks=4 records=3)
+//	  select id = 7 (blocks=4 records=3)
+//	    table students (blocks=4 records=100)
+func Explain(p interfaces.Plan) string {
+	var sb strings.Builder
+	explain(&sb, p, 0)
+	return sb.String()
+}
+
+func explain(sb *strings.Builder, p interfaces.Plan, depth int) {
+	description := fmt.Sprintf("%T", p)
+	var children []interfaces.Plan
+	if node, ok := p.(interfaces.PlanNode); ok {
+		description = node.Describe()
+		children = node.Children()
+	}
+
+	fmt.Fprintf(sb, "%s%s (blocks=%d records=%d)\n",
+		strings.Repeat("  ", depth), description, p.BlocksAccessed(), p.RecordsOutput())
+
+	for _, child := range children {
+		explain(sb, child, depth+1)
+	}
+}
+
+// Returns a plan whose records are the lines of the tree Explain shows
+// for the plan, in a single field named plan. It answers an EXPLAIN command.
+func NewExplainPlan(p interfaces.Plan) *RowsPlan {
+	return linesPlan(strings.Split(strings.TrimSuffix(Explain(p), "\n"), "\n"))
+}
+
+// Runs the plan in the transaction, and returns a plan whose records are
+// the lines Explain shows for it followed by one telling the records it
+// output and the bytes of temp tables it wrote. It answers EXPLAIN ANALYZE.
+//
+//	sort name (blocks=2 records=100)
+//	  table students (blocks=4 records=100)
+//	executed (records=100 temp_bytes=800)
+func NewExplainAnalyzePlan(p interfaces.Plan, tx *tx.Transaction) *RowsPlan {
+	lines := strings.Split(strings.TrimSuffix(Explain(p), "\n"), "\n")
+
+	tempBefore := tx.TempSpaceUsed()
+	records := 0
+	s := p.Open()
+	for s.Next() {
+		records++
+	}
+	s.Close()
+	lines = append(lines, fmt.Sprintf("executed (records=%d temp_bytes=%d)", records, tx.TempSpaceUsed()-tempBefore))
+	return linesPlan(lines)
+}
+
+// Returns a plan whose records are the lines, in a single field named plan
+func linesPlan(lines []string) *RowsPlan {
+	width := 1
+	rows := make([]map[string]*types.Constant, len(lines))
+	for i, line := range lines {
+		width = max(width, len(line))
+		rows[i] = map[string]*types.Constant{"plan": types.NewConstantString(line)}
+	}
+
+	sch := schema.NewSchema()
+	sch.AddStringField("plan", width)
+	return NewRowsPlan(sch, rows)
+}
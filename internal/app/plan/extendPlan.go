@@ -0,0 +1,94 @@
+package plan
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record/schema"
+	"strings"
+)
+
+// Extends the records of the underlying plan with fields computed from
+// each of them, such as "price * qty AS total" in a select list
+type ExtendPlan struct {
+	p        interfaces.Plan
+	computed []*parse.ComputedData
+	schema   *schema.Schema
+}
+
+// Creates a plan extending the records of p with the computed fields.
+// Their values may be null, and their types follow from their expressions.
+func NewExtendPlan(p interfaces.Plan, computed []*parse.ComputedData) *ExtendPlan {
+	sch := schema.NewSchema()
+	sch.AddAll(p.Schema())
+	for _, cd := range computed {
+		fieldType, length := cd.Expression().FieldType(p.Schema())
+		sch.AddField(cd.Name(), fieldType, length)
+		sch.SetNullable(cd.Name(), true)
+	}
+	return &ExtendPlan{
+		p:        p,
+		computed: computed,
+		schema:   sch,
+	}
+}
+
+func (ep *ExtendPlan) Open() interfaces.Scan {
+	exprs := make(map[string]*query.Expression)
+	for _, cd := range ep.computed {
+		exprs[cd.Name()] = cd.Expression()
+	}
+	return query.NewExtendScan(ep.p.Open(), ep.schema, exprs)
+}
+
+// The computed fields are evaluated as the records are read,
+// so no more blocks are read than for the underlying plan
+func (ep *ExtendPlan) BlocksAccessed() int {
+	return ep.p.BlocksAccessed()
+}
+
+func (ep *ExtendPlan) RecordsOutput() int {
+	return ep.p.RecordsOutput()
+}
+
+// A computed field has a value for each combination of the values of the
+// fields it is computed from, and a single value if it reads none
+func (ep *ExtendPlan) DistinctValues(fieldName string) int {
+	for _, cd := range ep.computed {
+		if cd.Name() != fieldName {
+			continue
+		}
+		distinct := 1
+		for _, field := range cd.Expression().Fields() {
+			distinct = min(distinct*ep.p.DistinctValues(field), max(1, ep.RecordsOutput()))
+		}
+		return distinct
+	}
+	return ep.p.DistinctValues(fieldName)
+}
+
+func (ep *ExtendPlan) Schema() *schema.Schema {
+	return ep.schema
+}
+
+func (ep *ExtendPlan) Describe() string {
+	fields := make([]string, len(ep.computed))
+	for i, cd := range ep.computed {
+		fields[i] = cd.Name() + " = " + cd.Expression().String()
+	}
+	return "extend " + strings.Join(fields, ", ")
+}
+
+func (ep *ExtendPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{ep.p}
+}
+
+// Adds the computed fields of the query's select list, if it has any, on
+// top of p. They are computed before the records are sorted, so that the
+// query may be ordered by them.
+func AddExtend(p interfaces.Plan, data *parse.QueryData) interfaces.Plan {
+	if len(data.Computed()) == 0 {
+		return p
+	}
+	return NewExtendPlan(p, data.Computed())
+}
@@ -0,0 +1,154 @@
+package plan
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Checks the generated fields of a new table. The expression of each must
+// fit in the catalog, read only fields of the table that aren't generated,
+// and compute a number for a numeric field and a string for a string field.
+func ValidateGenerated(sch *schema.Schema) error {
+	for _, fieldName := range sch.Fields() {
+		expr := sch.Generated(fieldName)
+		if expr == "" {
+			continue
+		}
+		if len(expr) > metadata.MAX_GENERATED_EXPR {
+			return fmt.Errorf("%w: the expression of %s is longer than %d characters", record.ErrGeneratedField, fieldName, metadata.MAX_GENERATED_EXPR)
+		}
+
+		e, err := parse.NewParser(expr).ValueExpression()
+		if err != nil {
+			return err
+		}
+		for _, src := range e.Fields() {
+			if !sch.HasField(src) {
+				return fmt.Errorf("%w: %s is computed from %s, which is not a field of the table", record.ErrGeneratedField, fieldName, src)
+			}
+			if sch.Generated(src) != "" {
+				return fmt.Errorf("%w: %s is computed from %s, which is generated too", record.ErrGeneratedField, fieldName, src)
+			}
+		}
+
+		want := query.FieldKind(sch, fieldName)
+		if kind := e.Kind(sch); kind != want && kind != types.KIND_NULL {
+			return fmt.Errorf("%w: %s is %s, but %s computes a value of another type", ErrTypeMismatch, fieldName, typeName(sch, fieldName), expr)
+		}
+	}
+	return nil
+}
+
+// Checks a field added to an existing table. Its collation and options must
+// be valid and, if it is generated, its expression must be valid for the
+// table's fields. It can't be AUTO_INCREMENT, since the existing records
+// would have no values for it.
+func ValidateAddedField(layout *record.Layout, field *schema.Schema) error {
+	if err := record.ValidateCollations(field); err != nil {
+		return err
+	}
+	if err := ValidateFieldOptions(field); err != nil {
+		return err
+	}
+	fieldName := field.Fields()[0]
+	if field.AutoIncrement(fieldName) {
+		return fmt.Errorf("%s can't be added as an AUTO_INCREMENT field to an existing table", fieldName)
+	}
+	if field.Generated(fieldName) == "" {
+		return nil
+	}
+
+	sch := schema.NewSchema()
+	for _, f := range layout.Schema().Fields() {
+		sch.Add(f, layout.Schema())
+		if expr := layout.Schema().Generated(f); expr != "" {
+			sch.SetGenerated(f, expr, !layout.Schema().IsVirtual(f))
+		}
+	}
+	sch.Add(fieldName, field)
+	sch.SetGenerated(fieldName, field.Generated(fieldName), !field.IsVirtual(fieldName))
+	return ValidateGenerated(sch)
+}
+
+// Checks that none of the fields assigned by a command is generated
+func CheckAssignable(layout *record.Layout, fields []string) error {
+	sch := layout.Schema()
+	for _, fieldName := range fields {
+		if expr := sch.Generated(fieldName); expr != "" {
+			return fmt.Errorf("%w: %s can't be assigned, it is computed as %s", record.ErrGeneratedField, fieldName, expr)
+		}
+	}
+	return nil
+}
+
+// Returns the stored generated fields of the table that are computed from
+// any of the changed fields, or all of them if changed is nil
+func StoredGeneratedFields(layout *record.Layout, changed []string) []string {
+	sch := layout.Schema()
+
+	var fields []string
+	for _, fieldName := range sch.Fields() {
+		g := layout.Generator(fieldName)
+		if g == nil || sch.IsVirtual(fieldName) {
+			continue
+		}
+		if changed == nil || slices.ContainsFunc(g.Fields(), func(src string) bool {
+			return slices.Contains(changed, src)
+		}) {
+			fields = append(fields, fieldName)
+		}
+	}
+	return fields
+}
+
+// Computes the stored generated fields of the record the scan is on that
+// depend on the changed fields, or all of them if changed is nil.
+// Returns the fields that were set.
+func SetGeneratedFields(s interfaces.UpdateScan, layout *record.Layout, changed []string) ([]string, error) {
+	fields := StoredGeneratedFields(layout, changed)
+	for _, fieldName := range fields {
+		if err := s.SetVal(fieldName, layout.Generator(fieldName).Evaluate(s)); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// Checks that the fields of an index key, separated by commas, are stored
+// in the table's records. Virtual fields are computed when read, so there is
+// nothing to index. Tables that are partitioned can't be indexed either, since
+// the RIDs of their records don't tell the partitions apart, and neither can
+// blobs, which are too large for an index record.
+func CheckIndexable(layout *record.Layout, fieldList string) error {
+	if layout.Options().Partitioning != nil {
+		return fmt.Errorf("%w: indexes on partitioned tables are not supported", record.ErrPartitioned)
+	}
+	for _, fieldName := range strings.Split(fieldList, ",") {
+		if layout.Computed(fieldName) != nil {
+			return fmt.Errorf("%w: %s is virtual and can't be indexed", record.ErrGeneratedField, fieldName)
+		}
+		if layout.Schema().DataType(fieldName) == schema.BLOB {
+			return fmt.Errorf("%w: %s is a blob and can't be indexed", record.ErrFieldType, fieldName)
+		}
+	}
+	return nil
+}
+
+// Returns the fields of the schema that aren't generated, in order
+func AssignableFields(sch *schema.Schema) []string {
+	var fields []string
+	for _, fieldName := range sch.Fields() {
+		if sch.Generated(fieldName) == "" {
+			fields = append(fields, fieldName)
+		}
+	}
+	return fields
+}
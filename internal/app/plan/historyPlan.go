@@ -0,0 +1,103 @@
+package plan
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"fmt"
+)
+
+// Scans the records of a table as they were at a past point in the history
+// of the database. Opening it copies the blocks of the table into a temp
+// table with the changes of the transactions that hadn't committed by then
+// undone, which is dropped when the scan closes. The table is read with its
+// current layout, since the catalog itself isn't versioned.
+type HistoryPlan struct {
+	interfaces.Plan
+	tp    *TablePlan
+	point tx.HistoryPoint
+}
+
+func NewHistoryPlan(tp *TablePlan, point tx.HistoryPoint) *HistoryPlan {
+	return &HistoryPlan{
+		tp:    tp,
+		point: point,
+	}
+}
+
+// Returns the point in the history of the database an AS OF clause names
+func HistoryPoint(asOf *parse.AsOfData) tx.HistoryPoint {
+	return tx.HistoryPoint{LSN: asOf.LSN(), Time: asOf.Time()}
+}
+
+// Copies the snapshot of the table into a temp table and returns a scan of it
+func (hp *HistoryPlan) Open() interfaces.Scan {
+	temp := materialize.NewTempTableWithLayout(hp.tp.tx, hp.tp.layout)
+	for _, table := range hp.storageTables() {
+		layout := hp.tp.layout
+		if err := hp.tp.tx.CopyAsOf(record.TableFile(table, layout), record.TableFile(temp.TableName(), layout), hp.point); err != nil {
+			temp.Drop()
+			panic(err)
+		}
+	}
+	return &historyScan{TableScan: temp.Open(), temp: temp}
+}
+
+// Returns the names the records the plan scans are stored under
+func (hp *HistoryPlan) storageTables() []string {
+	p := hp.tp.layout.Options().Partitioning
+	if p == nil {
+		return []string{hp.tp.tableName}
+	}
+	parts := hp.tp.partitions()
+	tables := make([]string, len(parts))
+	for i, part := range parts {
+		tables[i] = record.PartitionTable(hp.tp.tableName, p.Partitions[part].Name)
+	}
+	return tables
+}
+
+// The table is read once to copy it and the copy once more
+func (hp *HistoryPlan) BlocksAccessed() int {
+	return 2 * hp.tp.BlocksAccessed()
+}
+
+// Estimated from the current statistics of the table
+func (hp *HistoryPlan) RecordsOutput() int {
+	return hp.tp.RecordsOutput()
+}
+
+func (hp *HistoryPlan) DistinctValues(fieldName string) int {
+	return hp.tp.DistinctValues(fieldName)
+}
+
+func (hp *HistoryPlan) Schema() *schema.Schema {
+	return hp.tp.Schema()
+}
+
+func (hp *HistoryPlan) Describe() string {
+	return fmt.Sprintf("%s as of %s", hp.tp.Describe(), hp.point)
+}
+
+func (hp *HistoryPlan) Children() []interfaces.Plan {
+	return nil
+}
+
+// A scan over the snapshot of a table copied into a temp table
+type historyScan struct {
+	*record.TableScan
+	temp   *materialize.TempTable
+	closed bool
+}
+
+// Closes the scan and drops the temp table
+func (hs *historyScan) Close() {
+	hs.TableScan.Close()
+	if !hs.closed {
+		hs.closed = true
+		hs.temp.Drop()
+	}
+}
@@ -0,0 +1,244 @@
+package plan
+
+import (
+	"centauri/internal/app/index"
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Answers the aggregates of a query reading a whole table without
+// scanning it: count(*) comes from the row count kept in the table
+// catalog, and min and max from the edges of an ordered index on the field.
+// Like a GroupByPlan, it outputs no record for an empty table.
+type IndexAggregatePlan struct {
+	tx         *tx.Transaction
+	tableName  string
+	aggregates []*parse.AggregateData
+	indexes    map[string]metadata.IndexInfo // ordered index of each min or max field
+	mdm        *metadata.MetaDataManager
+	sch        *schema.Schema
+}
+
+// Creates a plan answering the aggregates over the table.
+// Returns false if an aggregate can't be answered without a scan, either
+// because the table's rows aren't counted, because a min or max field
+// has no ordered index, or because it estimates distinct values.
+func NewIndexAggregatePlan(tx *tx.Transaction, tableName string, aggregates []*parse.AggregateData, mdm *metadata.MetaDataManager) (*IndexAggregatePlan, bool) {
+	layout, err := mdm.GetLayout(tableName, tx)
+	if err != nil {
+		return nil, false
+	}
+	tableIndexes := mdm.GetIndexInfo(tableName, tx)
+
+	iap := &IndexAggregatePlan{
+		tx:         tx,
+		tableName:  tableName,
+		aggregates: aggregates,
+		indexes:    make(map[string]metadata.IndexInfo),
+		mdm:        mdm,
+		sch:        schema.NewSchema(),
+	}
+
+	for _, agg := range aggregates {
+		if agg.Fn() == parse.AGG_COUNT {
+			if mdm.RowCount(tableName, tx) == metadata.UNTRACKED_ROWS {
+				return nil, false
+			}
+			iap.sch.AddIntField(agg.Name())
+			continue
+		}
+		if agg.Fn() != parse.AGG_MIN && agg.Fn() != parse.AGG_MAX {
+			return nil, false
+		}
+
+		ii, ok := tableIndexes[agg.FieldName()]
+		if !ok || !isOrdered(&ii) {
+			return nil, false
+		}
+		iap.indexes[agg.FieldName()] = ii
+
+		src := layout.Schema()
+		iap.sch.AddField(agg.Name(), src.DataType(agg.FieldName()), src.Length(agg.FieldName()))
+		iap.sch.SetCollation(agg.Name(), src.Collation(agg.FieldName()))
+	}
+
+	return iap, true
+}
+
+// Reports whether the index keeps its keys in order
+func isOrdered(ii *metadata.IndexInfo) bool {
+	idx := ii.Open()
+	defer idx.Close()
+	_, ok := idx.(index.OrderedIndex)
+	return ok
+}
+
+// Computes the aggregates and returns a scan over the single record holding them
+func (iap *IndexAggregatePlan) Open() interfaces.Scan {
+	row := make(map[string]*types.Constant)
+	for _, agg := range iap.aggregates {
+		val := iap.aggregate(agg)
+		if val == nil {
+			// The table is empty
+			return query.NewRowsScan(iap.sch, nil)
+		}
+		row[agg.Name()] = val
+	}
+	return query.NewRowsScan(iap.sch, []map[string]*types.Constant{row})
+}
+
+// Returns the value of the aggregate, or nil if the table is empty
+func (iap *IndexAggregatePlan) aggregate(agg *parse.AggregateData) *types.Constant {
+	if agg.Fn() == parse.AGG_COUNT {
+		count := iap.mdm.RowCount(iap.tableName, iap.tx)
+		if count == 0 {
+			return nil
+		}
+		return types.NewConstantInt(count)
+	}
+
+	ii := iap.indexes[agg.FieldName()]
+	idx := ii.Open().(index.OrderedIndex)
+	defer idx.Close()
+
+	if agg.Fn() == parse.AGG_MIN {
+		return idx.MinKey()
+	}
+	return idx.MaxKey()
+}
+
+// Each min or max descends its index once, and the row count
+// is read from the first block of the table catalog
+func (iap *IndexAggregatePlan) BlocksAccessed() int {
+	blocks := 0
+	for _, agg := range iap.aggregates {
+		if agg.Fn() == parse.AGG_COUNT {
+			blocks++
+			continue
+		}
+		ii := iap.indexes[agg.FieldName()]
+		blocks += ii.BlocksAccessed()
+	}
+	return blocks
+}
+
+func (iap *IndexAggregatePlan) RecordsOutput() int {
+	return 1
+}
+
+func (iap *IndexAggregatePlan) DistinctValues(fieldName string) int {
+	return 1
+}
+
+func (iap *IndexAggregatePlan) Schema() *schema.Schema {
+	return iap.sch
+}
+
+func (iap *IndexAggregatePlan) Describe() string {
+	aggs := make([]string, len(iap.aggregates))
+	for i, agg := range iap.aggregates {
+		aggs[i] = agg.Name()
+	}
+	return fmt.Sprintf("index aggregate %s computing %s", iap.tableName, strings.Join(aggs, ", "))
+}
+
+func (iap *IndexAggregatePlan) Children() []interfaces.Plan {
+	return nil
+}
+
+// Adds the computation of the query's aggregates on top of p, the plan
+// selecting the query's records, grouping them by the GROUP BY clause and
+// then selecting the groups satisfying the HAVING clause. A query over the
+// current state of a whole table without GROUP BY is answered by an
+// IndexAggregatePlan when it can, and otherwise by aggregating the records
+// of p. Queries neither aggregated nor grouped are returned as they are.
+func AggregatePlan(tx *tx.Transaction, p interfaces.Plan, data *parse.QueryData, mdm *metadata.MetaDataManager) (interfaces.Plan, error) {
+	aggregates := data.Aggregates()
+	groupBy := data.GroupBy()
+	if len(aggregates) == 0 && len(groupBy) == 0 {
+		if data.Having().String() != "" {
+			return nil, fmt.Errorf("HAVING needs GROUP BY or an aggregate function")
+		}
+		return p, nil
+	}
+
+	// Every selected field must be aggregated or grouped by, or computed
+	// from grouped fields only. HAVING reads the groups, before the
+	// computed fields are added.
+	names := make(map[string]bool)
+	for _, field := range groupBy {
+		if !p.Schema().HasField(field) {
+			return nil, fmt.Errorf("unknown field %s in GROUP BY", field)
+		}
+		names[field] = true
+	}
+	for _, agg := range aggregates {
+		names[agg.Name()] = true
+		if agg.FieldName() != "" && !p.Schema().HasField(agg.FieldName()) {
+			return nil, fmt.Errorf("unknown field %s in %s", agg.FieldName(), agg)
+		}
+	}
+	for _, term := range data.Having().Terms() {
+		for _, field := range term.Fields() {
+			if !names[field] {
+				return nil, fmt.Errorf("field %s must appear in the GROUP BY clause or an aggregate function", field)
+			}
+		}
+	}
+	for _, cd := range data.Computed() {
+		for _, field := range cd.Expression().Fields() {
+			if !slices.Contains(groupBy, field) {
+				return nil, fmt.Errorf("field %s must appear in the GROUP BY clause or an aggregate function", field)
+			}
+		}
+		names[cd.Name()] = true
+	}
+	for _, field := range data.Fields() {
+		if !names[field] {
+			return nil, fmt.Errorf("field %s must appear in the GROUP BY clause or an aggregate function", field)
+		}
+	}
+
+	p = groupPlan(tx, p, data, mdm)
+	if data.Having().String() != "" {
+		p = NewSelectPlan(p, data.Having())
+	}
+	return p, nil
+}
+
+// Returns the plan computing the query's aggregates over the records of p,
+// grouped by the GROUP BY clause
+func groupPlan(tx *tx.Transaction, p interfaces.Plan, data *parse.QueryData, mdm *metadata.MetaDataManager) interfaces.Plan {
+	aggregates := data.Aggregates()
+	tables := data.Tables()
+	if len(data.GroupBy()) == 0 && len(tables) == 1 && data.Pred().String() == "" && data.AsOf() == nil &&
+		tables[0] != metadata.RELATION_SIZES_VIEW && mdm.GetViewDef(tables[0], tx) == "" {
+		if iap, ok := NewIndexAggregatePlan(tx, tables[0], aggregates, mdm); ok {
+			return iap
+		}
+	}
+
+	fns := make([]materialize.AggregateFunction, len(aggregates))
+	for i, agg := range aggregates {
+		switch agg.Fn() {
+		case parse.AGG_COUNT:
+			fns[i] = materialize.NewCountFn()
+		case parse.AGG_MIN:
+			fns[i] = materialize.NewMinFn(agg.FieldName())
+		case parse.AGG_APPROX_COUNT_DISTINCT:
+			fns[i] = materialize.NewApproxCountDistinctFn(agg.FieldName())
+		default:
+			fns[i] = materialize.NewMaxFn(agg.FieldName())
+		}
+	}
+	return materialize.NewGroupPlan(tx, p, data.GroupBy(), fns)
+}
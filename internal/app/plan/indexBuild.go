@@ -0,0 +1,197 @@
+package plan
+
+import (
+	"centauri/internal/app/index"
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"fmt"
+)
+
+// The fields of the index entries of the records of a table
+const (
+	ENTRY_KEY   = "dataval"
+	ENTRY_BLOCK = "block"
+	ENTRY_SLOT  = "id"
+)
+
+// Adds an entry for each record of the table to a newly created index on it.
+// An index that can be loaded, such as a B-tree index, gets the entries
+// sorted by key, which are sorted on disk if they don't fit in memory.
+// The others get the entries inserted one by one. Composite keys aren't
+// stored by the index structures, so their indexes are left empty.
+// Fails with index.ErrUniqueViolation if the index is unique and two
+// records have the same key, leaving the index partly built.
+// Returns the number of entries added.
+func BuildIndex(tx *tx.Transaction, tableName string, ii *metadata.IndexInfo, mdm *metadata.MetaDataManager) (int, error) {
+	if ii.IsComposite() {
+		return 0, nil
+	}
+	tp, err := NewTablePlan(tx, tableName, mdm)
+	if err != nil {
+		return 0, err
+	}
+	fieldName := ii.FieldNames()[0]
+
+	idx := ii.Open()
+	defer idx.Close()
+
+	count := 0
+	bulk, ok := idx.(index.BulkIndex)
+	if !ok {
+		ts := tp.Open().(interfaces.UpdateScan)
+		defer ts.Close()
+		for ts.Next() {
+			rid, err := ts.GetRID()
+			if err != nil {
+				return count, err
+			}
+			key := ts.GetVal(fieldName)
+			if err := CheckUnique(ii, idx, key, rid); err != nil {
+				return count, err
+			}
+			idx.Insert(key, rid)
+			count++
+		}
+		return count, nil
+	}
+
+	// The index orders null keys first. Merging the sorted runs pins as many
+	// buffers as it can, so the merged entries are written to a temp table
+	// before the index gets the buffers of its pages.
+	entries := newIndexEntryPlan(tp, fieldName)
+	order := materialize.SortSpec{Field: ENTRY_KEY, Nulls: materialize.NULLS_FIRST}
+	sorted := materialize.NewSortPlan(tx, entries, []materialize.SortSpec{order})
+	s := materialize.NewMaterializePlan(tx, sorted).Open()
+	defer s.Close()
+	// The entries with the same key follow each other
+	var prev *types.Constant
+	var loadErr error
+	bulk.Load(func(yield func(*types.Constant, *types.RID) bool) {
+		for s.Next() {
+			key := s.GetVal(ENTRY_KEY)
+			if ii.IsUnique() && prev != nil && !key.IsNull() && key.Equals(prev) {
+				loadErr = uniqueViolation(ii, key)
+				return
+			}
+			prev = key
+			count++
+			if !yield(key, types.NewRID(s.GetInt(ENTRY_BLOCK), s.GetInt(ENTRY_SLOT))) {
+				return
+			}
+		}
+	})
+	return count, loadErr
+}
+
+// Fails with index.ErrUniqueViolation if the index is unique and already has
+// an entry with the key for a record other than rid, so that the entry of
+// rid can't be added. Null keys are never the same as each other.
+func CheckUnique(ii *metadata.IndexInfo, idx index.Index, key *types.Constant, rid *types.RID) error {
+	if !ii.IsUnique() || key.IsNull() {
+		return nil
+	}
+	idx.BeforeFirst(key)
+	for idx.Next() {
+		if !idx.GetDataRid().Equals(rid) {
+			return uniqueViolation(ii, key)
+		}
+	}
+	return nil
+}
+
+func uniqueViolation(ii *metadata.IndexInfo, key *types.Constant) error {
+	return fmt.Errorf("%w %s: key %s already exists", index.ErrUniqueViolation, ii.IndexName(), key)
+}
+
+// Plan of the index entries of the records of a table: the value of the
+// indexed field and the RID of each record
+type indexEntryPlan struct {
+	p         interfaces.Plan
+	fieldName string
+	sch       *schema.Schema
+}
+
+func newIndexEntryPlan(p interfaces.Plan, fieldName string) *indexEntryPlan {
+	sch := schema.NewSchema()
+	src := p.Schema()
+	sch.AddField(ENTRY_KEY, src.DataType(fieldName), src.Length(fieldName))
+	sch.AddIntField(ENTRY_BLOCK)
+	sch.AddIntField(ENTRY_SLOT)
+	return &indexEntryPlan{p: p, fieldName: fieldName, sch: sch}
+}
+
+func (ep *indexEntryPlan) Open() interfaces.Scan {
+	return &indexEntryScan{s: ep.p.Open().(interfaces.UpdateScan), fieldName: ep.fieldName, sch: ep.sch}
+}
+
+func (ep *indexEntryPlan) BlocksAccessed() int {
+	return ep.p.BlocksAccessed()
+}
+
+func (ep *indexEntryPlan) RecordsOutput() int {
+	return ep.p.RecordsOutput()
+}
+
+func (ep *indexEntryPlan) DistinctValues(fieldName string) int {
+	if fieldName == ENTRY_KEY {
+		return ep.p.DistinctValues(ep.fieldName)
+	}
+	return ep.p.RecordsOutput()
+}
+
+func (ep *indexEntryPlan) Schema() *schema.Schema {
+	return ep.sch
+}
+
+// Scan of the index entries of the records of a table
+type indexEntryScan struct {
+	s         interfaces.UpdateScan
+	fieldName string
+	sch       *schema.Schema
+}
+
+func (es *indexEntryScan) BeforeFirst() {
+	es.s.BeforeFirst()
+}
+
+func (es *indexEntryScan) Next() bool {
+	return es.s.Next()
+}
+
+func (es *indexEntryScan) GetInt(fieldName string) int {
+	rid, _ := es.s.GetRID()
+	switch fieldName {
+	case ENTRY_BLOCK:
+		return rid.BlockNumber()
+	case ENTRY_SLOT:
+		return rid.Slot()
+	}
+	return es.s.GetInt(es.fieldName)
+}
+
+func (es *indexEntryScan) GetString(fieldName string) string {
+	return es.s.GetString(es.fieldName)
+}
+
+func (es *indexEntryScan) GetVal(fieldName string) *types.Constant {
+	if fieldName == ENTRY_KEY {
+		return es.s.GetVal(es.fieldName)
+	}
+	return types.NewConstantInt(es.GetInt(fieldName))
+}
+
+func (es *indexEntryScan) HasField(fieldName string) bool {
+	return es.sch.HasField(fieldName)
+}
+
+func (es *indexEntryScan) Schema() *schema.Schema {
+	return es.sch
+}
+
+func (es *indexEntryScan) Close() {
+	es.s.Close()
+}
@@ -0,0 +1,93 @@
+package plan
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record/schema"
+	"fmt"
+)
+
+// Implements the LIMIT clause of a query, outputting at most
+// limit records of the underlying plan
+type LimitPlan struct {
+	p     interfaces.Plan
+	limit int
+}
+
+func NewLimitPlan(p interfaces.Plan, limit int) *LimitPlan {
+	return &LimitPlan{
+		p:     p,
+		limit: limit,
+	}
+}
+
+func (lp *LimitPlan) Open() interfaces.Scan {
+	return query.NewLimitScan(lp.p.Open(), lp.limit)
+}
+
+// The underlying plan stops being read once the limit is reached,
+// see FirstRecordsCost
+func (lp *LimitPlan) BlocksAccessed() int {
+	return FirstRecordsCost(lp.p, lp.limit)
+}
+
+func (lp *LimitPlan) RecordsOutput() int {
+	return min(lp.limit, lp.p.RecordsOutput())
+}
+
+func (lp *LimitPlan) DistinctValues(fieldName string) int {
+	return min(lp.RecordsOutput(), lp.p.DistinctValues(fieldName))
+}
+
+func (lp *LimitPlan) Schema() *schema.Schema {
+	return lp.p.Schema()
+}
+
+func (lp *LimitPlan) Describe() string {
+	return fmt.Sprintf("limit %d", lp.limit)
+}
+
+func (lp *LimitPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{lp.p}
+}
+
+// Adds the LIMIT clause of the query, if it has one, on top of p
+func AddLimit(p interfaces.Plan, data *parse.QueryData) interfaces.Plan {
+	if data.Limit() == parse.NO_LIMIT {
+		return p
+	}
+	return NewLimitPlan(p, data.Limit())
+}
+
+// Estimates the number of block accesses needed to output the first n
+// records of the plan. A pipelined plan reads its input as it outputs
+// records, so the first n records cost their share of the whole. A plan
+// with a blocking operator anywhere below it pays the full cost, as the
+// operator reads all of its input first.
+func FirstRecordsCost(p interfaces.Plan, n int) int {
+	blocks := p.BlocksAccessed()
+	records := p.RecordsOutput()
+	if n >= records || isBlocking(p) {
+		return blocks
+	}
+	// Round up, as reading any record accesses at least one block
+	return max((blocks*n+records-1)/records, min(blocks, 1))
+}
+
+// Reports whether the plan or any plan below it is blocking
+func isBlocking(p interfaces.Plan) bool {
+	if _, ok := p.(interfaces.BlockingPlan); ok {
+		return true
+	}
+	node, ok := p.(interfaces.PlanNode)
+	if !ok {
+		return false
+	}
+	for _, child := range node.Children() {
+		if isBlocking(child) {
+			return true
+		}
+	}
+	return false
+}
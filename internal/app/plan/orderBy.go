@@ -0,0 +1,48 @@
+package plan
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/tx"
+	"fmt"
+)
+
+// Adds a sort by the ORDER BY clause of the query, if it has one, on top of p.
+// The fields must be fields of p, so that a query may be ordered by fields
+// it doesn't output and by its computed fields, but an aggregate query only
+// by its aggregates.
+func AddOrderBy(tx *tx.Transaction, p interfaces.Plan, data *parse.QueryData) (interfaces.Plan, error) {
+	orderBy := data.OrderBy()
+	if len(orderBy) == 0 {
+		return p, nil
+	}
+
+	for _, order := range orderBy {
+		if !p.Schema().HasField(order.FieldName()) {
+			return nil, fmt.Errorf("%w: can't order by %s", ErrUnknownField, order.FieldName())
+		}
+	}
+	return materialize.NewSortPlan(tx, p, SortSpecs(data)), nil
+}
+
+// Returns the sort specs of the ORDER BY clause of the query
+func SortSpecs(data *parse.QueryData) []materialize.SortSpec {
+	specs := make([]materialize.SortSpec, len(data.OrderBy()))
+	for i, order := range data.OrderBy() {
+		specs[i] = sortSpec(order)
+	}
+	return specs
+}
+
+// Returns the sort spec of an item of an ORDER BY clause
+func sortSpec(order *parse.OrderData) materialize.SortSpec {
+	spec := materialize.SortSpec{Field: order.FieldName(), Descending: order.Descending()}
+	switch order.Nulls() {
+	case parse.NULLS_FIRST:
+		spec.Nulls = materialize.NULLS_FIRST
+	case parse.NULLS_LAST:
+		spec.Nulls = materialize.NULLS_LAST
+	}
+	return spec
+}
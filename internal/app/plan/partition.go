@@ -0,0 +1,251 @@
+package plan
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+)
+
+// Converts the PARTITION BY clause of a CREATE TABLE statement into the
+// partitioning of the table, nil if it has none
+func NewPartitioning(data *parse.PartitionData) *record.Partitioning {
+	if data == nil {
+		return nil
+	}
+	if data.Method() == parse.PARTITION_BY_HASH {
+		return record.NewHashPartitioning(data.Field(), data.Count())
+	}
+
+	parts := make([]record.Partition, len(data.Partitions()))
+	for i, part := range data.Partitions() {
+		parts[i] = record.Partition{Name: part.Name, Bound: part.Bound, MaxValue: part.MaxValue}
+	}
+	return &record.Partitioning{Method: record.PARTITION_RANGE, Field: data.Field(), Partitions: parts}
+}
+
+// Returns the partitions the plan scans, in order
+func (tp *TablePlan) partitions() []int {
+	if tp.parts != nil {
+		return tp.parts
+	}
+	all := make([]int, len(tp.layout.Options().Partitioning.Partitions))
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+// Scales an estimate for the whole table to the partitions scanned
+func (tp *TablePlan) scaled(n int) int {
+	p := tp.layout.Options().Partitioning
+	if p == nil || tp.parts == nil {
+		return n
+	}
+	return (n*len(tp.parts) + len(p.Partitions) - 1) / len(p.Partitions)
+}
+
+// Returns a plan scanning only the partitions of the table that can hold
+// records satisfying the predicate, the plan itself if the predicate
+// neither equates the partition key with a constant nor, for range
+// partitioning, bounds it by constants, as in "key < 10" or
+// "key BETWEEN 5 AND 15"
+func (tp *TablePlan) Prune(pred *query.Predicate) *TablePlan {
+	p := tp.layout.Options().Partitioning
+	if p == nil || pred == nil {
+		return tp
+	}
+
+	pruned := *tp
+	pruned.parts = []int{}
+	if val := pred.EquatesWithConstant(p.Field); val != nil {
+		if part, err := p.Route(partitionKey(tp.layout.Schema(), p.Field, val)); err == nil {
+			pruned.parts = []int{part}
+		}
+		return &pruned
+	}
+	if p.Method != record.PARTITION_RANGE {
+		return tp
+	}
+
+	low, high := keyBounds(pred, p.Field)
+	if low == nil && high == nil {
+		return tp
+	}
+	first, last := p.Spanning(low, high)
+	for part := first; part <= last; part++ {
+		pruned.parts = append(pruned.parts, part)
+	}
+	return &pruned
+}
+
+// Returns the lowest and highest integer key the predicate allows for the
+// field, nil for an end of the range it leaves open
+func keyBounds(pred *query.Predicate, fieldName string) (*int, *int) {
+	low, high, lowInclusive, highInclusive := pred.Bounds(fieldName)
+
+	var lowKey, highKey *int
+	if low != nil && low.AsInt() != nil {
+		key := *low.AsInt()
+		if !lowInclusive {
+			key++
+		}
+		lowKey = &key
+	}
+	if high != nil && high.AsInt() != nil {
+		key := *high.AsInt()
+		if !highInclusive {
+			key--
+		}
+		highKey = &key
+	}
+	return lowKey, highKey
+}
+
+// Returns a plan scanning the partition that a new record with the values
+// goes in, the plan itself if the table isn't partitioned.
+// Fails with record.ErrNoPartition if no partition can hold the record.
+func (tp *TablePlan) Route(fields []string, vals []*types.Constant) (*TablePlan, error) {
+	if tp.layout.Options().Partitioning == nil {
+		return tp, nil
+	}
+
+	part, err := routeRecord(tp.layout, fields, vals)
+	if err != nil {
+		return nil, err
+	}
+	routed := *tp
+	routed.parts = []int{part}
+	return &routed, nil
+}
+
+// Returns the partition of a partitioned table that a new record with the
+// values goes in. A partition key missing from fields is routed by the
+// empty value a new record gets.
+func routeRecord(layout *record.Layout, fields []string, vals []*types.Constant) (int, error) {
+	p := layout.Options().Partitioning
+	sch := layout.Schema()
+
+	key := types.NewConstantString("")
+	if fieldType := sch.DataType(p.Field); fieldType == schema.INTEGER {
+		key = types.NewConstantInt(0)
+	} else if fieldType == schema.BIGINT {
+		key = types.NewConstantLong(0)
+	} else if fieldType == schema.FLOAT {
+		key = types.NewConstantFloat(0)
+	} else if fieldType.IsTemporal() {
+		key = record.TimeVal(fieldType, 0)
+	} else if fieldType == schema.BOOLEAN {
+		key = types.NewConstantBool(false)
+	}
+	for i, fieldName := range fields {
+		if fieldName == p.Field {
+			key = vals[i]
+		}
+	}
+	return p.Route(partitionKey(sch, p.Field, key))
+}
+
+// Returns the partition key value compared as the field compares it,
+// so strings equal under the field's collation go in the same partition,
+// and a date or timestamp goes where it does however it is written
+func partitionKey(sch *schema.Schema, fieldName string, val *types.Constant) *types.Constant {
+	if fieldType := sch.DataType(fieldName); fieldType.IsTemporal() {
+		if t, err := record.ToTime(fieldType, val); err == nil {
+			return t
+		}
+	}
+	if s := val.AsString(); s != nil {
+		return record.StringVal(sch, fieldName, *s)
+	}
+	return val
+}
+
+// Returns the partition key of the table, empty if it isn't partitioned
+func (tp *TablePlan) PartitionKey() string {
+	if p := tp.layout.Options().Partitioning; p != nil {
+		return p.Field
+	}
+	return ""
+}
+
+// A record taken out of its partition, with the values of its stored fields
+type movedRecord struct {
+	fields []string
+	vals   []*types.Constant
+}
+
+// Moves the records whose partition key an UPDATE changes into the
+// partitions their new keys route to. The records are taken out of their
+// partitions as the update changes them, and inserted once it is done,
+// so that the update doesn't see them again in a partition scanned later.
+type PartitionMover struct {
+	tp    *TablePlan
+	moved []movedRecord
+}
+
+func NewPartitionMover(tp *TablePlan) *PartitionMover {
+	return &PartitionMover{tp: tp}
+}
+
+// Takes the record the scan is on out of its partition if its partition
+// key was changed from oldKey to a value that routes to another partition.
+// Fails with record.ErrNoPartition if no partition can hold the record.
+func (pm *PartitionMover) Check(s interfaces.UpdateScan, oldKey *types.Constant) error {
+	p := pm.tp.layout.Options().Partitioning
+	sch := pm.tp.layout.Schema()
+
+	from, err := p.Route(partitionKey(sch, p.Field, oldKey))
+	if err != nil {
+		return err
+	}
+	to, err := p.Route(partitionKey(sch, p.Field, s.GetVal(p.Field)))
+	if err != nil || from == to {
+		return err
+	}
+
+	var rec movedRecord
+	for _, fieldName := range sch.Fields() {
+		if pm.tp.layout.Computed(fieldName) == nil {
+			rec.fields = append(rec.fields, fieldName)
+			rec.vals = append(rec.vals, s.GetVal(fieldName))
+		}
+	}
+	pm.moved = append(pm.moved, rec)
+	return s.Delete()
+}
+
+// Inserts the records taken out by Check into their new partitions
+func (pm *PartitionMover) Finish() error {
+	for _, rec := range pm.moved {
+		tp, err := pm.tp.Route(rec.fields, rec.vals)
+		if err != nil {
+			return err
+		}
+		if err := insertValues(tp, rec.fields, rec.vals); err != nil {
+			return err
+		}
+	}
+	pm.moved = nil
+	return nil
+}
+
+func insertValues(tp *TablePlan, fields []string, vals []*types.Constant) error {
+	s, err := OpenUpdateScan(tp, tp.tableName)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if err := s.Insert(); err != nil {
+		return err
+	}
+	for i, fieldName := range fields {
+		if err := s.SetVal(fieldName, vals[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
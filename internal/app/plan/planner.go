@@ -2,52 +2,208 @@ package plan
 
 import (
 	"centauri/internal/app/interfaces"
+	"centauri/internal/app/metadata"
 	"centauri/internal/app/parse"
 	"centauri/internal/app/query"
 	"centauri/internal/app/tx"
 	"centauri/internal/app/types"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"unicode"
 )
 
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Returned for update commands while the planner is read-only
+var ErrReadOnly = errors.New("database is read-only")
+
 // Orchestrates query and update operations in the database.
 // It delegates the actual execution to specialized planners while
-// handling the initial parsing and validation of commands.
+// handling the initial parsing, validation and privilege checks of commands.
 type Planner struct {
-	qPlanner QueryPlanner  // Handles all query-related operations
-	uPlanner UpdatePlanner // Handles all update-related operations
+	qPlanner QueryPlanner              // Handles all query-related operations
+	uPlanner UpdatePlanner             // Handles all update-related operations
+	mdm      *metadata.MetaDataManager // Looks up the privileges of users, nil disables the checks
+	readOnly bool                      // rejects every update command, e.g. on a standby
 }
 
-func NewPlanner(qPlanner QueryPlanner, uPlanner UpdatePlanner) *Planner {
+func NewPlanner(qPlanner QueryPlanner, uPlanner UpdatePlanner, mdm *metadata.MetaDataManager) *Planner {
 	return &Planner{
 		qPlanner: qPlanner,
 		uPlanner: uPlanner,
+		mdm:      mdm,
 	}
 }
 
+// Makes the planner reject update commands with ErrReadOnly, or accept them again
+func (p *Planner) SetReadOnly(readOnly bool) {
+	p.readOnly = readOnly
+}
+
+// Returns true if the planner rejects update commands
+func (p *Planner) ReadOnly() bool {
+	return p.readOnly
+}
+
 // Generates an execution plan for a query command.
 // It parses the command string and delegates plan creation to the query planner.
-func (p *Planner) CreateQueryPlan(cmd string, tx *tx.Transaction) interfaces.Plan {
+func (p *Planner) CreateQueryPlan(cmd string, tx *tx.Transaction) (interfaces.Plan, error) {
+	return p.CreateQueryPlanAs(cmd, nil, tx)
+}
+
+// Generates an execution plan for a query on behalf of a user, who needs
+// the SELECT privilege on every table and view it reads. A nil user stands
+// for the engine itself, which may read anything. An EXPLAIN command is
+// planned like a query, its plan holding the description of the query's.
+func (p *Planner) CreateQueryPlanAs(cmd string, user *metadata.UserInfo, tx *tx.Transaction) (interfaces.Plan, error) {
+	if parse.NewLexer(cmd).MatchKeyword("explain") {
+		return p.createExplainPlan(cmd, user, tx)
+	}
+
+	parser := parse.NewParser(cmd)
+	data, err := parser.Query()
+	if err != nil {
+		return nil, err
+	}
+	if parser.NumParams() > 0 {
+		return nil, fmt.Errorf("%w: the query has %d, prepare it to bind them", ErrParameterCount, parser.NumParams())
+	}
+	return p.createQueryPlan(data, user, tx)
+}
+
+// Plans the query of an EXPLAIN command without running it, and returns a
+// plan of the lines describing the query's plan. EXPLAIN ANALYZE runs the
+// query too, see NewExplainAnalyzePlan. Explaining a query requires the
+// same privileges as running it.
+func (p *Planner) createExplainPlan(cmd string, user *metadata.UserInfo, tx *tx.Transaction) (interfaces.Plan, error) {
 	parser := parse.NewParser(cmd)
-	data := parser.Query()
-	p.verifyQuery(data)
+	data, err := parser.Explain()
+	if err != nil {
+		return nil, err
+	}
+	if parser.NumParams() > 0 {
+		return nil, fmt.Errorf("%w: the query has %d, explain it with values instead", ErrParameterCount, parser.NumParams())
+	}
+
+	queryPlan, err := p.createQueryPlan(data.Query(), user, tx)
+	if err != nil {
+		return nil, err
+	}
+	if data.Analyze() {
+		return NewExplainAnalyzePlan(queryPlan, tx), nil
+	}
+	return NewExplainPlan(queryPlan), nil
+}
+
+// Generates an execution plan for a prepared query, with the values
+// bound to its parameters, on behalf of a user
+func (p *Planner) CreatePreparedPlanAs(ps *PreparedStatement, params []*types.Constant, user *metadata.UserInfo, tx *tx.Transaction) (interfaces.Plan, error) {
+	data, err := ps.bind(params)
+	if err != nil {
+		return nil, err
+	}
+	qd, ok := data.(*parse.QueryData)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a query", ps)
+	}
+	return p.createQueryPlan(qd, user, tx)
+}
+
+// Checks a parsed query and generates its plan
+func (p *Planner) createQueryPlan(data *parse.QueryData, user *metadata.UserInfo, tx *tx.Transaction) (interfaces.Plan, error) {
+	if err := p.verifyQuery(data); err != nil {
+		return nil, err
+	}
+
+	if err := p.requireAll(user, data.ReadTables(), metadata.PRIV_SELECT, tx); err != nil {
+		return nil, err
+	}
+
+	if err := p.planQuerySubqueries(data, user, tx); err != nil {
+		return nil, err
+	}
+
+	if err := p.checkQuery(data, tx); err != nil {
+		return nil, err
+	}
 
 	return p.qPlanner.CreatePlan(data, tx)
 }
 
 // Process various types of update commands.
 // Returns the number of affected rows.
-func (p *Planner) ExecuteUpdate(cmd string, tx *tx.Transaction) int {
+func (p *Planner) ExecuteUpdate(cmd string, tx *tx.Transaction) (int, error) {
+	return p.ExecuteUpdateAs(cmd, nil, tx)
+}
+
+// Processes an update command on behalf of a user, checking that the user
+// may run it. A nil user stands for the engine itself, which may run anything.
+func (p *Planner) ExecuteUpdateAs(cmd string, user *metadata.UserInfo, tx *tx.Transaction) (int, error) {
+	if p.readOnly {
+		return 0, ErrReadOnly
+	}
+
 	parser := parse.NewParser(cmd)
-	obj := parser.UpdateCmd()
+	obj, err := parser.UpdateCmd()
+	if err != nil {
+		return 0, err
+	}
+	if parser.NumParams() > 0 {
+		return 0, fmt.Errorf("%w: the command has %d, prepare it to bind them", ErrParameterCount, parser.NumParams())
+	}
+	return p.executeUpdate(obj, user, tx)
+}
 
+// Runs a prepared update command, with the values bound to its
+// parameters, on behalf of a user
+func (p *Planner) ExecutePreparedAs(ps *PreparedStatement, params []*types.Constant, user *metadata.UserInfo, tx *tx.Transaction) (int, error) {
+	if p.readOnly {
+		return 0, ErrReadOnly
+	}
+	if ps.IsQuery() {
+		return 0, fmt.Errorf("%s is a query", ps)
+	}
+	obj, err := ps.bind(params)
+	if err != nil {
+		return 0, err
+	}
+	return p.executeUpdate(obj, user, tx)
+}
+
+// Checks a parsed update command and runs it
+func (p *Planner) executeUpdate(obj interface{}, user *metadata.UserInfo, tx *tx.Transaction) (int, error) {
 	// Verify the update command before execution
-	err := p.verifyUpdate(obj)
+	if err := p.verifyUpdate(obj); err != nil {
+		return 0, err
+	}
+
+	if err := p.checkPrivileges(obj, user, tx); err != nil {
+		return 0, err
+	}
+
+	if err := p.planUpdateSubqueries(obj, user, tx); err != nil {
+		return 0, err
+	}
+
+	if err := p.checkUpdate(obj, tx); err != nil {
+		return 0, err
+	}
+
+	count, err := p.execute(obj, tx)
 	if err != nil {
-		return 0
+		return count, err
+	}
+
+	if err := p.grantToCreator(obj, user, tx); err != nil {
+		return count, err
 	}
+	return count, nil
+}
 
+// Passes a verified update command to the update planner
+func (p *Planner) execute(obj interface{}, tx *tx.Transaction) (int, error) {
 	switch data := obj.(type) {
 	case *parse.InsertData:
 		return p.uPlanner.ExecuteInsert(data, tx)
@@ -61,9 +217,188 @@ func (p *Planner) ExecuteUpdate(cmd string, tx *tx.Transaction) int {
 		return p.uPlanner.ExecuteCreateView(data, tx)
 	case *parse.CreateIndexData:
 		return p.uPlanner.ExecuteCreateIndex(data, tx)
+	case *parse.DropTableData:
+		return p.uPlanner.ExecuteDropTable(data, tx)
+	case *parse.DropIndexData:
+		return p.uPlanner.ExecuteDropIndex(data, tx)
+	case *parse.DropViewData:
+		return p.uPlanner.ExecuteDropView(data, tx)
+	case *parse.AddColumnData:
+		return p.uPlanner.ExecuteAddColumn(data, tx)
+	case *parse.DropColumnData:
+		return p.uPlanner.ExecuteDropColumn(data, tx)
+	case *parse.CopyData:
+		return p.uPlanner.ExecuteCopy(data, tx)
+	case *parse.CopyToData:
+		return p.executeCopyTo(data, tx)
+	case *parse.AnalyzeData:
+		return p.executeAnalyze(data, tx)
+	case *parse.CreateUserData:
+		return p.uPlanner.ExecuteCreateUser(data, tx)
+	case *parse.AlterUserData:
+		return p.uPlanner.ExecuteAlterUser(data, tx)
+	case *parse.GrantData:
+		return p.uPlanner.ExecuteGrant(data, tx)
+	case *parse.RevokeData:
+		return p.uPlanner.ExecuteRevoke(data, tx)
 	default:
-		return 0
+		return 0, fmt.Errorf("unknown update command type: %T", obj)
+	}
+}
+
+// Gathers the statistics of a table, or of every table if none is given,
+// see MetaDataManager.Analyze. Returns the number of tables analyzed.
+func (p *Planner) executeAnalyze(data *parse.AnalyzeData, tx *tx.Transaction) (int, error) {
+	if p.mdm == nil {
+		return 0, fmt.Errorf("analyze needs the catalog")
+	}
+	tableNames := p.analyzedTables(data, tx)
+	for _, tableName := range tableNames {
+		if err := p.mdm.Analyze(tableName, tx); err != nil {
+			return 0, err
+		}
+	}
+	return len(tableNames), nil
+}
+
+// Returns the tables an ANALYZE command gathers the statistics of
+func (p *Planner) analyzedTables(data *parse.AnalyzeData, tx *tx.Transaction) []string {
+	if data.TableName() != "" {
+		return []string{data.TableName()}
+	}
+	return p.mdm.TableNames(tx)
+}
+
+// Exports the result of a query to a file, in CSV unless another format is specified.
+// The file is created, or truncated if it exists, relative to the working directory
+// of the server. Returns the number of records written.
+func (p *Planner) executeCopyTo(data *parse.CopyToData, tx *tx.Transaction) (int, error) {
+	format := data.Format()
+	if format == "" {
+		format = FORMAT_CSV
+	}
+	if format != FORMAT_CSV && format != FORMAT_JSON {
+		return 0, fmt.Errorf("unsupported format %q", format)
+	}
+
+	qp, err := p.qPlanner.CreatePlan(data.Query(), tx)
+	if err != nil {
+		return 0, err
 	}
+
+	f, err := os.Create(data.FileName())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", data.FileName(), err)
+	}
+	defer f.Close()
+
+	rw, err := NewResultWriter(format, f)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := WriteResults(qp, rw)
+	if err != nil {
+		return count, fmt.Errorf("failed to write %s: %w", data.FileName(), err)
+	}
+	return count, f.Close()
+}
+
+// Checks that a user may run an update command. Changing the records of a
+// table needs the matching privilege on it, creating or dropping an index on
+// it needs ALTER, as do dropping it and adding or dropping its fields, and
+// creating a view needs SELECT on the tables the view reads.
+// Only superusers may create users, grant or revoke privileges, and read or
+// write files on the server with COPY, and a user may change only their own
+// password unless a superuser.
+func (p *Planner) checkPrivileges(data interface{}, user *metadata.UserInfo, tx *tx.Transaction) error {
+	if user == nil || user.Superuser {
+		return nil
+	}
+
+	switch cmd := data.(type) {
+	case *parse.InsertData:
+		return p.require(user, cmd.TableName(), metadata.PRIV_INSERT, tx)
+	case *parse.DeleteData:
+		return p.require(user, cmd.TableName(), metadata.PRIV_DELETE, tx)
+	case *parse.ModifyData:
+		return p.require(user, cmd.TableName(), metadata.PRIV_UPDATE, tx)
+	case *parse.CreateIndexData:
+		return p.require(user, cmd.TableName(), metadata.PRIV_ALTER, tx)
+	case *parse.DropTableData:
+		return p.require(user, cmd.TableName(), metadata.PRIV_ALTER, tx)
+	case *parse.DropViewData:
+		return p.require(user, cmd.ViewName(), metadata.PRIV_ALTER, tx)
+	case *parse.AddColumnData:
+		return p.require(user, cmd.TableName(), metadata.PRIV_ALTER, tx)
+	case *parse.DropColumnData:
+		return p.require(user, cmd.TableName(), metadata.PRIV_ALTER, tx)
+	case *parse.DropIndexData:
+		// A missing index is reported when the command runs
+		if p.mdm == nil {
+			return nil
+		}
+		if tableName, ok := p.mdm.IndexTable(cmd.IndexName(), tx); ok {
+			return p.require(user, tableName, metadata.PRIV_ALTER, tx)
+		}
+	case *parse.CreateViewData:
+		return p.requireAll(user, cmd.Tables(), metadata.PRIV_SELECT, tx)
+	case *parse.CopyData:
+		return fmt.Errorf("%w: only a superuser can copy from a file on the server", ErrPermissionDenied)
+	case *parse.CopyToData:
+		return fmt.Errorf("%w: only a superuser can copy to a file on the server", ErrPermissionDenied)
+	case *parse.AnalyzeData:
+		if p.mdm == nil {
+			return nil
+		}
+		return p.requireAll(user, p.analyzedTables(cmd, tx), metadata.PRIV_ALTER, tx)
+	case *parse.CreateUserData:
+		return fmt.Errorf("%w: only a superuser can create users", ErrPermissionDenied)
+	case *parse.AlterUserData:
+		if cmd.UserName() != user.Name {
+			return fmt.Errorf("%w: cannot change the password of %s", ErrPermissionDenied, cmd.UserName())
+		}
+	case *parse.GrantData, *parse.RevokeData:
+		return fmt.Errorf("%w: only a superuser can grant or revoke privileges", ErrPermissionDenied)
+	}
+	return nil
+}
+
+// Checks that a user holds a privilege on a table or view
+func (p *Planner) require(user *metadata.UserInfo, tableName string, privilege string, tx *tx.Transaction) error {
+	if user == nil || p.mdm == nil {
+		return nil
+	}
+	if !p.mdm.HasPrivilege(*user, tableName, privilege, tx) {
+		return fmt.Errorf("%w: %s on %s", ErrPermissionDenied, privilege, tableName)
+	}
+	return nil
+}
+
+// Checks that a user holds a privilege on each of the tables or views
+func (p *Planner) requireAll(user *metadata.UserInfo, tableNames []string, privilege string, tx *tx.Transaction) error {
+	for _, tableName := range tableNames {
+		if err := p.require(user, tableName, privilege, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Gives a user who isn't a superuser every privilege on
+// the table or view they created, so they can use it
+func (p *Planner) grantToCreator(data interface{}, user *metadata.UserInfo, tx *tx.Transaction) error {
+	if user == nil || user.Superuser || p.mdm == nil {
+		return nil
+	}
+
+	switch cmd := data.(type) {
+	case *parse.CreateTableData:
+		return p.mdm.Grant(user.Name, cmd.TableName(), []string{metadata.PRIV_ALL}, tx)
+	case *parse.CreateViewData:
+		return p.mdm.Grant(user.Name, cmd.ViewName(), []string{metadata.PRIV_ALL}, tx)
+	}
+	return nil
 }
 
 // Performs comprehensive validation of update commands.
@@ -88,17 +423,55 @@ func (p *Planner) verifyUpdate(data interface{}) error {
 		}
 	case *parse.CreateTableData:
 		if err := p.verifyTableData(cmd); err != nil {
-			return fmt.Errorf("table verification failed: %w", &err)
+			return fmt.Errorf("table verification failed: %w", err)
 		}
 
 	case *parse.CreateViewData:
 		if err := p.verifyViewData(cmd); err != nil {
-			return fmt.Errorf("view verification failed: %w", &err)
+			return fmt.Errorf("view verification failed: %w", err)
 		}
 
 	case *parse.CreateIndexData:
 		if err := p.verifyIndexData(cmd); err != nil {
-			return fmt.Errorf("view verification failed: %w", &err)
+			return fmt.Errorf("index verification failed: %w", err)
+		}
+
+	case *parse.DropTableData, *parse.DropIndexData, *parse.DropViewData,
+		*parse.AddColumnData, *parse.DropColumnData, *parse.AnalyzeData:
+		// The parser ensures a name is given, and whether the object exists is
+		// checked against the catalog when the command runs
+
+	case *parse.CopyData:
+		if err := p.verifyCopyData(cmd); err != nil {
+			return fmt.Errorf("copy verification failed: %w", err)
+		}
+
+	case *parse.CopyToData:
+		if err := p.verifyQuery(cmd.Query()); err != nil {
+			return fmt.Errorf("copy verification failed: %w", err)
+		}
+		if strings.TrimSpace(cmd.FileName()) == "" {
+			return fmt.Errorf("copy verification failed: missing file name")
+		}
+
+	case *parse.CreateUserData:
+		if err := verifyUserData(cmd.UserName(), cmd.Password()); err != nil {
+			return fmt.Errorf("user verification failed: %w", err)
+		}
+
+	case *parse.AlterUserData:
+		if err := verifyUserData(cmd.UserName(), cmd.Password()); err != nil {
+			return fmt.Errorf("user verification failed: %w", err)
+		}
+
+	case *parse.GrantData:
+		if err := verifyPrivilegeData(cmd.Privileges(), cmd.TableName(), cmd.UserName()); err != nil {
+			return fmt.Errorf("grant verification failed: %w", err)
+		}
+
+	case *parse.RevokeData:
+		if err := verifyPrivilegeData(cmd.Privileges(), cmd.TableName(), cmd.UserName()); err != nil {
+			return fmt.Errorf("revoke verification failed: %w", err)
 		}
 
 	default:
@@ -139,6 +512,14 @@ func (p *Planner) verifyQuery(data interface{}) error {
 			return fmt.Errorf("query: invalid predicate: %w", err)
 		}
 	}
+	if err := p.validatePredicate(queryData.Having()); err != nil {
+		return fmt.Errorf("query: invalid HAVING clause: %w", err)
+	}
+	for _, union := range queryData.Unions() {
+		if err := p.verifyQuery(union.Query()); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -148,13 +529,18 @@ func (p *Planner) verifyInsertData(cmd *parse.InsertData) error {
 		return fmt.Errorf("missing table name")
 	}
 
-	if len(cmd.Values()) == 0 {
+	if len(cmd.Rows()) == 0 {
 		return fmt.Errorf("no values provided")
 	}
 
-	// Verify column count matches values count
-	if len(cmd.Fields()) > 0 && len(cmd.Fields()) != len(cmd.Values()) {
-		return fmt.Errorf("column count (%d) does not match values count (%d)", len(cmd.Fields()), cmd.Values())
+	// Verify column count matches values count in every record
+	for _, values := range cmd.Rows() {
+		if len(values) == 0 {
+			return fmt.Errorf("no values provided")
+		}
+		if len(cmd.Fields()) > 0 && len(cmd.Fields()) != len(values) {
+			return fmt.Errorf("column count (%d) does not match values count (%d)", len(cmd.Fields()), len(values))
+		}
 	}
 
 	return nil
@@ -233,6 +619,50 @@ func (p *Planner) verifyIndexData(cmd *parse.CreateIndexData) error {
 	return nil
 }
 
+func (p *Planner) verifyCopyData(cmd *parse.CopyData) error {
+	if cmd.TableName() == "" {
+		return fmt.Errorf("missing table name")
+	}
+
+	if strings.TrimSpace(cmd.FileName()) == "" {
+		return fmt.Errorf("missing file name")
+	}
+
+	return nil
+}
+
+func verifyUserData(userName string, password string) error {
+	if userName == "" {
+		return fmt.Errorf("missing user name")
+	}
+
+	if len(userName) > metadata.MAX_NAME {
+		return fmt.Errorf("user name too long (max %d characters)", metadata.MAX_NAME)
+	}
+
+	if password == "" {
+		return fmt.Errorf("password cannot be empty")
+	}
+
+	return nil
+}
+
+func verifyPrivilegeData(privileges []string, tableName string, userName string) error {
+	if len(privileges) == 0 {
+		return fmt.Errorf("no privileges specified")
+	}
+
+	if tableName == "" {
+		return fmt.Errorf("missing table name")
+	}
+
+	if userName == "" {
+		return fmt.Errorf("missing user name")
+	}
+
+	return nil
+}
+
 func (p *Planner) validatePredicate(pred *query.Predicate) error {
 	if pred == nil {
 		return fmt.Errorf("nil predicate")
@@ -262,10 +692,38 @@ func validateTerm(term *query.Term, index int) error {
 		return fmt.Errorf("term is nil")
 	}
 
+	// Validate each operand of an OR or NOT term
+	if term.IsCompound() {
+		for _, operand := range term.Operands() {
+			for i, t := range operand.Terms() {
+				if err := validateTerm(&t, i); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	// A subquery is checked when it is planned
+	if term.Op() == query.OP_EXISTS {
+		return nil
+	}
+
 	// Validate left-hand side expression
 	if err := validateExpression(term.LHS(), "left-hand"); err != nil {
 		return err
 	}
+	if term.Op() == query.OP_IN || term.Op() == query.OP_BETWEEN {
+		for _, value := range term.Values() {
+			if err := validateExpression(value, "right-hand"); err != nil {
+				return err
+			}
+			if err := validateExpressionCompatibility(term.LHS(), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 
 	// Validate left-hand side expression
 	if err := validateExpression(term.RHS(), "right-hand"); err != nil {
@@ -284,6 +742,11 @@ func validateExpression(expr *query.Expression, side string) error {
 		return fmt.Errorf("%s expression is nil", side)
 	}
 
+	// A subquery is checked when it is planned
+	if expr.Subquery() != nil {
+		return nil
+	}
+
 	// If it's a field name, validate the field name
 	if expr.IsFieldName() {
 		if err := validateFieldName(expr.AsFieldName()); err != nil {
@@ -319,7 +782,7 @@ func validateFieldName(name string) error {
 	// Remaining characters must be letters, numbers or underscores
 	for i, ch := range name {
 		if !unicode.IsLetter(ch) && !unicode.IsDigit(ch) && ch != '_' {
-			return fmt.Errorf("invalid character %c at position %p in field name", ch, i)
+			return fmt.Errorf("invalid character %c at position %d in field name", ch, i)
 		}
 	}
 
@@ -331,8 +794,17 @@ func validateConstant(c *types.Constant) error {
 		return fmt.Errorf("constant cannot be nil")
 	}
 
+	if c.IsNull() {
+		return nil
+	}
+	// A date, timestamp or boolean holds its value alone
+	if c.Kind() == types.KIND_TIME || c.Kind() == types.KIND_BOOL {
+		return nil
+	}
+
 	// Check that exactly one value type is set
-	if (c.AsInt() == nil && c.AsString() == nil) || (c.AsInt() != nil && c.AsString() != nil) {
+	_, isNumber := c.AsNumber()
+	if isNumber == (c.AsString() != nil) {
 		return fmt.Errorf("constant must have exactly one value type set")
 	}
 
@@ -346,11 +818,25 @@ func validateExpressionCompatibility(lhs, rhs *query.Expression) error {
 		return nil
 	}
 
-	// If one is a field name and one is a constant, or both are constants,
-	// check type compatibility
-
-	// CONTINUE FROM HERE
+	// A field is checked against its type once the catalog is consulted,
+	// see checkPredicate
+	if lhs.IsFieldName() || rhs.IsFieldName() {
+		return nil
+	}
 
+	// Two constants must be of the same kind, unless one is NULL,
+	// which compares with anything
+	if lhs.AsConstant().IsNull() || rhs.AsConstant().IsNull() {
+		return nil
+	}
+	// A string may spell the date or timestamp it is compared with, see checkPredicate
+	lhsKind, rhsKind := lhs.AsConstant().Kind(), rhs.AsConstant().Kind()
+	if min(lhsKind, rhsKind) == types.KIND_STRING && max(lhsKind, rhsKind) == types.KIND_TIME {
+		return nil
+	}
+	if lhsKind != rhsKind {
+		return fmt.Errorf("%w: cannot compare %s with %s", ErrTypeMismatch, lhs, rhs)
+	}
 	return nil
 }
 
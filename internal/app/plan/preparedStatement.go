@@ -0,0 +1,86 @@
+package plan
+
+import (
+	"centauri/internal/app/parse"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+)
+
+var (
+	// Returned when a statement runs without a value for each of its parameters
+	ErrParameterCount = errors.New("wrong number of parameters")
+	// Returned when preparing a statement that can't have parameters
+	ErrParametersNotSupported = errors.New("parameters are not supported")
+)
+
+// A statement parsed once and then run any number of times, with values
+// bound to the ? placeholders of its parameters each time. Since the values
+// may change which plan is best, e.g. whether an index applies to a
+// predicate, the statement is planned again every time it runs. The
+// privileges and the catalog are checked again as well, as they may have
+// changed since the statement was prepared.
+type PreparedStatement struct {
+	cmd       string
+	data      interface{} // a *parse.QueryData for a query, the data of the command otherwise
+	numParams int
+}
+
+// Parses a query or update command for running it later.
+// Only queries, INSERT, UPDATE and DELETE may have parameters.
+func (p *Planner) Prepare(cmd string) (*PreparedStatement, error) {
+	parser := parse.NewParser(cmd)
+	data, err := parser.Statement()
+	if err != nil {
+		return nil, err
+	}
+
+	if parser.NumParams() > 0 {
+		switch data.(type) {
+		case *parse.QueryData, *parse.InsertData, *parse.ModifyData, *parse.DeleteData:
+		default:
+			return nil, fmt.Errorf("%w: only queries, INSERT, UPDATE and DELETE can have parameters", ErrParametersNotSupported)
+		}
+	}
+	return &PreparedStatement{cmd: cmd, data: data, numParams: parser.NumParams()}, nil
+}
+
+// Returns the number of parameters a value must be bound to
+func (ps *PreparedStatement) NumParams() int {
+	return ps.numParams
+}
+
+// Reports whether the statement is a query
+func (ps *PreparedStatement) IsQuery() bool {
+	_, ok := ps.data.(*parse.QueryData)
+	return ok
+}
+
+// Returns the text of the statement
+func (ps *PreparedStatement) String() string {
+	return ps.cmd
+}
+
+// Returns the data of the statement with the values bound to its parameters.
+// The data of the statement itself is left as it is, so the statement may
+// be run with other values.
+func (ps *PreparedStatement) bind(params []*types.Constant) (interface{}, error) {
+	if len(params) != ps.numParams {
+		return nil, fmt.Errorf("%w: the statement has %d, got %d", ErrParameterCount, ps.numParams, len(params))
+	}
+	if ps.numParams == 0 {
+		return ps.data, nil
+	}
+
+	switch data := ps.data.(type) {
+	case *parse.QueryData:
+		return data.Bind(params), nil
+	case *parse.InsertData:
+		return data.Bind(params), nil
+	case *parse.ModifyData:
+		return data.Bind(params), nil
+	case *parse.DeleteData:
+		return data.Bind(params), nil
+	}
+	return nil, ErrParametersNotSupported
+}
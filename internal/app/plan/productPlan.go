@@ -66,3 +66,11 @@ func (pp *ProductPlan) DistinctValues(fieldName string) int {
 func (pp *ProductPlan) Schema() *schema.Schema {
 	return pp.schema
 }
+
+func (pp *ProductPlan) Describe() string {
+	return "product"
+}
+
+func (pp *ProductPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{pp.p1, pp.p2}
+}
@@ -4,6 +4,7 @@ import (
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/query"
 	"centauri/internal/app/record/schema"
+	"strings"
 )
 
 // Implements a projection operation in the query execution plan.
@@ -61,3 +62,11 @@ func (pp *ProjectPlan) DistinctValues(fieldName string) int {
 func (pp *ProjectPlan) Schema() *schema.Schema {
 	return pp.schema
 }
+
+func (pp *ProjectPlan) Describe() string {
+	return "project " + strings.Join(pp.schema.Fields(), ", ")
+}
+
+func (pp *ProjectPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{pp.p}
+}
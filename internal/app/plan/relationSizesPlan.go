@@ -0,0 +1,80 @@
+package plan
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+)
+
+// Plan for the relation_sizes system view.
+// Each record describes a table or index: relname, kind, blocks, numrecs and bytes.
+// The sizes are calculated when the plan is created.
+type RelationSizesPlan struct {
+	schema *schema.Schema
+	sizes  []metadata.RelationSize
+}
+
+func NewRelationSizesPlan(tx *tx.Transaction, mdm *metadata.MetaDataManager) (*RelationSizesPlan, error) {
+	sizes, err := mdm.RelationSizes(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RelationSizesPlan{
+		schema: relationSizesSchema(),
+		sizes:  sizes,
+	}, nil
+}
+
+// Returns the schema of the relation sizes view
+func relationSizesSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddStringField("relname", metadata.MAX_NAME)
+	sch.AddStringField("kind", len(metadata.RELATION_TABLE))
+	sch.AddIntField("blocks")
+	sch.AddIntField("numrecs")
+	sch.AddIntField("bytes")
+	return sch
+}
+
+func (rp *RelationSizesPlan) Open() interfaces.Scan {
+	rows := make([]map[string]*types.Constant, 0, len(rp.sizes))
+	for _, size := range rp.sizes {
+		rows = append(rows, map[string]*types.Constant{
+			"relname": types.NewConstantString(size.Name),
+			"kind":    types.NewConstantString(size.Kind),
+			"blocks":  types.NewConstantInt(size.Blocks),
+			"numrecs": types.NewConstantInt(size.Rows),
+			"bytes":   types.NewConstantInt(size.Bytes),
+		})
+	}
+	return query.NewRowsScan(rp.schema, rows)
+}
+
+// The view is computed in memory, so no blocks are read when scanning it
+func (rp *RelationSizesPlan) BlocksAccessed() int {
+	return 0
+}
+
+func (rp *RelationSizesPlan) RecordsOutput() int {
+	return len(rp.sizes)
+}
+
+func (rp *RelationSizesPlan) DistinctValues(fieldName string) int {
+	return max(len(rp.sizes), 1)
+}
+
+func (rp *RelationSizesPlan) Schema() *schema.Schema {
+	return rp.schema
+}
+
+func (rp *RelationSizesPlan) Describe() string {
+	return "relation sizes"
+}
+
+func (rp *RelationSizesPlan) Children() []interfaces.Plan {
+	return nil
+}
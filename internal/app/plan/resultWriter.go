@@ -0,0 +1,211 @@
+package plan
+
+import (
+	"bufio"
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Output formats supported by COPY ... TO
+const (
+	FORMAT_CSV  = "csv"
+	FORMAT_JSON = "json"
+)
+
+// Describes a column of a query result
+type ResultColumn struct {
+	Name string
+	Type schema.FieldType
+}
+
+// Writes the records of a query result in some output format.
+// WriteHeader is called once before the rows, and Close after the last row.
+type ResultWriter interface {
+	// Starts the output of a result with the specified columns
+	WriteHeader(columns []ResultColumn) error
+
+	// Writes a record, holding one value per column in column order.
+	// Values are int for INTEGER columns, int64 for BIGINT columns, bool for
+	// BOOLEAN columns, []byte for BLOB columns and string for VARCHAR, DATE
+	// and TIMESTAMP columns, or nil for nulls.
+	WriteRow(values []any) error
+
+	// Finishes the output and flushes anything buffered.
+	// It doesn't close the underlying writer.
+	Close() error
+}
+
+// Returns a writer for the named format, FORMAT_CSV or FORMAT_JSON
+func NewResultWriter(format string, w io.Writer) (ResultWriter, error) {
+	switch strings.ToLower(format) {
+	case FORMAT_CSV:
+		return NewCSVResultWriter(w), nil
+	case FORMAT_JSON:
+		return NewJSONResultWriter(w), nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// Runs the plan and writes all of its records.
+// Returns the number of records written.
+func WriteResults(p interfaces.Plan, rw ResultWriter) (int, error) {
+	sch := p.Schema()
+
+	columns := make([]ResultColumn, 0, len(sch.Fields()))
+	for _, fieldName := range sch.Fields() {
+		columns = append(columns, ResultColumn{Name: fieldName, Type: sch.DataType(fieldName)})
+	}
+	if err := rw.WriteHeader(columns); err != nil {
+		return 0, err
+	}
+
+	s := p.Open()
+	defer s.Close()
+
+	count := 0
+	values := make([]any, len(columns))
+	for s.Next() {
+		for i, col := range columns {
+			values[i] = FieldValue(s, col.Name, col.Type)
+		}
+		if err := rw.WriteRow(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, rw.Close()
+}
+
+// Returns the value of a field of the current record of a scan as an int
+// for an integer field, an int64 for a bigint field, a float64 for a float field,
+// a bool for a boolean field, a []byte for a blob field and a string for others,
+// or nil if it is null.
+// Dates and timestamps are written as in SQL, e.g. "2023-01-15".
+func FieldValue(s interfaces.Scan, fieldName string, fieldType schema.FieldType) any {
+	val := s.GetVal(fieldName)
+	if val.IsNull() {
+		return nil
+	}
+	if fieldType == schema.INTEGER {
+		return s.GetInt(fieldName)
+	}
+	if fieldType == schema.BIGINT {
+		n, _ := val.AsInteger()
+		return n
+	}
+	if fieldType == schema.FLOAT {
+		f, _ := val.AsNumber()
+		return f
+	}
+	if fieldType == schema.BOOLEAN {
+		return *val.AsBool()
+	}
+	if fieldType == schema.BLOB {
+		return []byte(*val.AsString())
+	}
+	if fieldType.IsTemporal() {
+		return val.String()
+	}
+	return s.GetString(fieldName)
+}
+
+// Writes a result as CSV, with a first line naming the columns.
+// Nulls are written as empty fields, and blobs as their bytes.
+type CSVResultWriter struct {
+	w *csv.Writer
+}
+
+func NewCSVResultWriter(w io.Writer) *CSVResultWriter {
+	return &CSVResultWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *CSVResultWriter) WriteHeader(columns []ResultColumn) error {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	return cw.w.Write(names)
+}
+
+func (cw *CSVResultWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, val := range values {
+		if b, ok := val.([]byte); ok {
+			record[i] = string(b)
+		} else if val != nil {
+			record[i] = fmt.Sprint(val)
+		}
+	}
+	return cw.w.Write(record)
+}
+
+func (cw *CSVResultWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// Writes a result as a JSON array holding one object per record,
+// with the members in column order. Blobs are written in base64:
+//
+//	[
+//	{"id":1,"name":"alice"},
+//	{"id":2,"name":"bob"}
+//	]
+type JSONResultWriter struct {
+	w       *bufio.Writer
+	columns []ResultColumn
+	rows    int
+}
+
+func NewJSONResultWriter(w io.Writer) *JSONResultWriter {
+	return &JSONResultWriter{w: bufio.NewWriter(w)}
+}
+
+func (jw *JSONResultWriter) WriteHeader(columns []ResultColumn) error {
+	jw.columns = columns
+	_, err := jw.w.WriteString("[")
+	return err
+}
+
+func (jw *JSONResultWriter) WriteRow(values []any) error {
+	if jw.rows > 0 {
+		jw.w.WriteString(",")
+	}
+	jw.w.WriteString("\n{")
+
+	for i, val := range values {
+		if i > 0 {
+			jw.w.WriteString(",")
+		}
+
+		name, _ := json.Marshal(jw.columns[i].Name)
+		jw.w.Write(name)
+		jw.w.WriteString(":")
+
+		encoded, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		jw.w.Write(encoded)
+	}
+
+	jw.rows++
+	_, err := jw.w.WriteString("}")
+	return err
+}
+
+func (jw *JSONResultWriter) Close() error {
+	if jw.rows > 0 {
+		jw.w.WriteString("\n")
+	}
+	if _, err := jw.w.WriteString("]\n"); err != nil {
+		return err
+	}
+	return jw.w.Flush()
+}
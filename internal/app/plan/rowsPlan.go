@@ -0,0 +1,52 @@
+package plan
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"fmt"
+)
+
+// Plan over records held in memory, such as the
+// result of a SHOW command
+type RowsPlan struct {
+	schema *schema.Schema
+	rows   []map[string]*types.Constant
+}
+
+func NewRowsPlan(sch *schema.Schema, rows []map[string]*types.Constant) *RowsPlan {
+	return &RowsPlan{
+		schema: sch,
+		rows:   rows,
+	}
+}
+
+func (rp *RowsPlan) Open() interfaces.Scan {
+	return query.NewRowsScan(rp.schema, rp.rows)
+}
+
+// The records are held in memory, so no blocks are read when scanning them
+func (rp *RowsPlan) BlocksAccessed() int {
+	return 0
+}
+
+func (rp *RowsPlan) RecordsOutput() int {
+	return len(rp.rows)
+}
+
+func (rp *RowsPlan) DistinctValues(fieldName string) int {
+	return max(len(rp.rows), 1)
+}
+
+func (rp *RowsPlan) Schema() *schema.Schema {
+	return rp.schema
+}
+
+func (rp *RowsPlan) Describe() string {
+	return fmt.Sprintf("rows %d", len(rp.rows))
+}
+
+func (rp *RowsPlan) Children() []interfaces.Plan {
+	return nil
+}
@@ -23,10 +23,11 @@ func NewSelectPlan(p interfaces.Plan, pred *query.Predicate) interfaces.Plan {
 
 // Intiializes the scan operation for this plan.
 // It creates a new SelectScan that wraps the underlying plan's scan
-// and applies the filtering predicate
+// and applies the filtering predicate, its terms reordered to reject
+// records as cheaply as possible
 func (sp *SelectPlan) Open() interfaces.Scan {
 	s := sp.p.Open()
-	return query.NewSelectScan(s, sp.pred)
+	return query.NewSelectScan(s, sp.pred.Reorder(sp.p))
 }
 
 // Returns the number of disk blocks that need to be read
@@ -65,3 +66,11 @@ func (sp *SelectPlan) DistinctValues(fieldName string) int {
 func (sp *SelectPlan) Schema() *schema.Schema {
 	return sp.p.Schema()
 }
+
+func (sp *SelectPlan) Describe() string {
+	return "select " + sp.pred.String()
+}
+
+func (sp *SelectPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{sp.p}
+}
@@ -0,0 +1,146 @@
+package plan
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/query"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"fmt"
+	"slices"
+)
+
+// Plans the subqueries of a predicate of a statement reading the tables,
+// on behalf of a user, who needs the SELECT privilege on the tables they
+// read as for any other query. The records of each are materialized in a
+// temp table the first time the predicate is evaluated, so that they are
+// computed only once however many records the predicate is evaluated on.
+// A subquery whose predicate refers to fields of the tables that its own
+// don't have is correlated: it is planned again for each of their values.
+func (p *Planner) planSubqueries(pred *query.Predicate, tables []string, user *metadata.UserInfo, tx *tx.Transaction) error {
+	for _, sq := range pred.Subqueries() {
+		data, ok := sq.Data().(*parse.QueryData)
+		if !ok {
+			return fmt.Errorf("invalid subquery data type: %T", sq.Data())
+		}
+		outer, err := p.outerFields(data, tables, tx)
+		if err != nil {
+			return fmt.Errorf("subquery %s: %w", sq, err)
+		}
+
+		var subPlan interfaces.Plan
+		if len(outer) == 0 {
+			subPlan, err = p.createQueryPlan(data, user, tx)
+		} else {
+			// The plan for null values gives the schema and estimates
+			correlated := data.Parameterize(outer)
+			correlate := func(outerVals []*types.Constant) (interfaces.Plan, error) {
+				return p.createQueryPlan(correlated.Bind(outerVals), user, tx)
+			}
+			nulls := make([]*types.Constant, len(outer))
+			for i := range nulls {
+				nulls[i] = types.NewConstantNull()
+			}
+			subPlan, err = correlate(nulls)
+			sq.SetCorrelated(outer, correlate)
+		}
+		if err != nil {
+			return fmt.Errorf("subquery %s: %w", sq, err)
+		}
+		if sq.Valued() && len(subPlan.Schema().Fields()) != 1 {
+			return fmt.Errorf("subquery %s must select a single field", sq)
+		}
+		if len(outer) == 0 {
+			subPlan = materialize.NewMaterializePlan(tx, subPlan)
+		}
+		sq.SetPlan(subPlan)
+	}
+	return nil
+}
+
+// Returns the fields of the tables that the predicate of a subquery refers
+// to, and its own tables don't have. Only those of a query without UNION
+// can be, and without a metadata manager there are none.
+func (p *Planner) outerFields(data *parse.QueryData, tables []string, tx *tx.Transaction) ([]string, error) {
+	if p.mdm == nil || len(tables) == 0 || len(data.Unions()) > 0 {
+		return nil, nil
+	}
+	own, err := p.sourceSchema(data.Tables(), tx)
+	if err != nil {
+		return nil, err
+	}
+	around, err := p.sourceSchema(tables, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var outer []string
+	for _, term := range data.Pred().Terms() {
+		for _, field := range term.Fields() {
+			if !own.HasField(field) && around.HasField(field) && !slices.Contains(outer, field) {
+				outer = append(outer, field)
+			}
+		}
+	}
+	return outer, nil
+}
+
+// Plans the subqueries of the predicates of a query, see planSubqueries
+func (p *Planner) planQuerySubqueries(data *parse.QueryData, user *metadata.UserInfo, tx *tx.Transaction) error {
+	for _, operand := range data.Operands() {
+		for _, pred := range operandPredicates(operand) {
+			if err := p.planSubqueries(pred, operand.Tables(), user, tx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Plans the subqueries of an update command's predicates. A view is stored
+// as the text of its query and planned anew each time it is read, which
+// leaves no place to plan its subqueries, so a view can't have any.
+func (p *Planner) planUpdateSubqueries(obj interface{}, user *metadata.UserInfo, tx *tx.Transaction) error {
+	switch cmd := obj.(type) {
+	case *parse.DeleteData:
+		return p.planSubqueries(cmd.Pred(), []string{cmd.TableName()}, user, tx)
+	case *parse.ModifyData:
+		for _, pred := range append([]*query.Predicate{cmd.Pred()}, cmd.NewValue().Conditions()...) {
+			if err := p.planSubqueries(pred, []string{cmd.TableName()}, user, tx); err != nil {
+				return err
+			}
+		}
+	case *parse.CopyToData:
+		return p.planQuerySubqueries(cmd.Query(), user, tx)
+	case *parse.CreateViewData:
+		for _, pred := range queryPredicates(cmd.Query()) {
+			if len(pred.Subqueries()) > 0 {
+				return fmt.Errorf("view %s: subqueries are not supported in views", cmd.ViewName())
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the predicates of a query that may have subqueries: its
+// predicate, its HAVING clause and the conditions of the CASE expressions
+// of its computed fields, for each query of a UNION
+func queryPredicates(data *parse.QueryData) []*query.Predicate {
+	var preds []*query.Predicate
+	for _, operand := range data.Operands() {
+		preds = append(preds, operandPredicates(operand)...)
+	}
+	return preds
+}
+
+// Returns the predicates of a query of a UNION that may have subqueries,
+// see queryPredicates
+func operandPredicates(operand *parse.QueryData) []*query.Predicate {
+	preds := []*query.Predicate{operand.Pred(), operand.Having()}
+	for _, cd := range operand.Computed() {
+		preds = append(preds, cd.Expression().Conditions()...)
+	}
+	return preds
+}
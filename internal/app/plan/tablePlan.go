@@ -6,6 +6,8 @@ import (
 	"centauri/internal/app/record"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
+	"fmt"
+	"strings"
 )
 
 // TablePlan represents a basic table access plan in a query execution.
@@ -16,11 +18,17 @@ type TablePlan struct {
 	tableName string
 	layout    *record.Layout
 	si        *metadata.StatInfo
+	parts     []int // partitions of a partitioned table that are scanned, nil for all
 }
 
-func NewTablePlan(tx *tx.Transaction, tableName string, md *metadata.MetaDataManager) interfaces.Plan {
+// Creates a plan for the specified table.
+// Returns metadata.ErrTableNotFound if the table doesn't exist.
+func NewTablePlan(tx *tx.Transaction, tableName string, md *metadata.MetaDataManager) (interfaces.Plan, error) {
 
-	layout := md.GetLayout(tableName, tx)
+	layout, err := md.GetLayout(tableName, tx)
+	if err != nil {
+		return nil, err
+	}
 	si := md.GetStatInfo(tableName, layout, tx)
 
 	return &TablePlan{
@@ -28,25 +36,58 @@ func NewTablePlan(tx *tx.Transaction, tableName string, md *metadata.MetaDataMan
 		tableName: tableName,
 		layout:    layout,
 		si:        &si,
-	}
+	}, nil
 }
 
+// Opens a scan of the table. A partitioned table is scanned partition by
+// partition, unless a single partition is scanned.
 func (tp *TablePlan) Open() interfaces.Scan {
-	return record.NewTableScan(tp.tx, tp.tableName, tp.layout)
+	p := tp.layout.Options().Partitioning
+	if p == nil {
+		return record.NewTableScan(tp.tx, tp.tableName, tp.layout)
+	}
+	parts := tp.partitions()
+	if len(parts) == 1 {
+		return record.NewTableScan(tp.tx, record.PartitionTable(tp.tableName, p.Partitions[parts[0]].Name), tp.layout)
+	}
+	return record.NewPartitionScan(tp.tx, tp.tableName, tp.layout, parts)
 }
 
+// Estimates the blocks of the partitions scanned, assuming the records
+// are spread evenly over the partitions
 func (tp *TablePlan) BlocksAccessed() int {
-	return tp.si.BlocksAccessed()
+	return tp.scaled(tp.si.BlocksAccessed())
 }
 
 func (tp *TablePlan) RecordsOutput() int {
-	return tp.si.RecordsOutput()
+	return tp.scaled(tp.si.RecordsOutput())
 }
 
 func (tp *TablePlan) DistinctValues(fieldName string) int {
-	return tp.si.DistinctValues(fieldName)
+	return min(tp.si.DistinctValues(fieldName), max(tp.RecordsOutput(), 1))
 }
 
 func (tp *TablePlan) Schema() *schema.Schema {
 	return tp.layout.Schema()
 }
+
+// Returns the layout of the table's records
+func (tp *TablePlan) Layout() *record.Layout {
+	return tp.layout
+}
+
+func (tp *TablePlan) Describe() string {
+	if tp.parts == nil {
+		return "table " + tp.tableName
+	}
+	p := tp.layout.Options().Partitioning
+	names := make([]string, len(tp.parts))
+	for i, part := range tp.parts {
+		names[i] = p.Partitions[part].Name
+	}
+	return fmt.Sprintf("table %s partitions (%s)", tp.tableName, strings.Join(names, ", "))
+}
+
+func (tp *TablePlan) Children() []interfaces.Plan {
+	return nil
+}
@@ -0,0 +1,400 @@
+package plan
+
+import (
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// Returned when a command names a field that none of its tables has
+	ErrUnknownField = errors.New("unknown field")
+	// Returned when a predicate compares a number with a string
+	ErrTypeMismatch = errors.New("type mismatch")
+	// Returned when a computed field of a select list is named like another field
+	ErrDuplicateField = errors.New("duplicate field")
+)
+
+// Checks a query against the catalog: the tables and views it reads must
+// exist, the fields it selects, computes from and compares must belong to
+// them, and each term of its predicate must compare values of the same kind.
+// So must those of the GROUP BY and HAVING clauses, where HAVING may also
+// compare the aggregates. The names of computed fields must differ from
+// those of the other fields. Each query of a UNION is checked on its own.
+// Without a metadata manager nothing is checked.
+func (p *Planner) checkQuery(data *parse.QueryData, tx *tx.Transaction) error {
+	if p.mdm == nil {
+		return nil
+	}
+	if len(data.Unions()) > 0 {
+		for _, operand := range data.Operands() {
+			if err := p.checkQuery(operand, tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	sch, err := p.sourceSchema(data.Tables(), tx)
+	if err != nil {
+		return err
+	}
+
+	aggregates := make(map[string]*parse.AggregateData)
+	for _, agg := range data.Aggregates() {
+		aggregates[agg.Name()] = agg
+	}
+
+	computed := make(map[string]*parse.ComputedData)
+	for _, cd := range data.Computed() {
+		if _, ok := computed[cd.Name()]; ok || sch.HasField(cd.Name()) || aggregates[cd.Name()] != nil {
+			return fmt.Errorf("%w: %s is named like another field", ErrDuplicateField, cd.Name())
+		}
+		computed[cd.Name()] = cd
+	}
+
+	for _, field := range data.Fields() {
+		if cd, ok := computed[field]; ok {
+			if err := checkExpression(cd.Expression(), sch, data.Tables()); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := aggregates[field]; ok {
+			continue
+		}
+		if !sch.HasField(field) {
+			return unknownField(field, data.Tables())
+		}
+	}
+	for _, agg := range data.Aggregates() {
+		if agg.FieldName() != "" && !sch.HasField(agg.FieldName()) {
+			return unknownField(agg.FieldName(), data.Tables())
+		}
+	}
+
+	// A query is ordered by fields of its tables, or by its aggregates and computed fields
+	for _, order := range data.OrderBy() {
+		if aggregates[order.FieldName()] == nil && computed[order.FieldName()] == nil && !sch.HasField(order.FieldName()) {
+			return unknownField(order.FieldName(), data.Tables())
+		}
+	}
+
+	if err := checkPredicate(data.Pred(), sch, data.Tables()); err != nil {
+		return err
+	}
+
+	for _, field := range data.GroupBy() {
+		if !sch.HasField(field) {
+			return unknownField(field, data.Tables())
+		}
+	}
+	if data.Having().String() == "" {
+		return nil
+	}
+	return checkPredicate(data.Having(), groupSchema(sch, data.Aggregates()), data.Tables())
+}
+
+// Returns the fields of sch followed by the output fields of the aggregates,
+// typed like the groups of an aggregated query
+func groupSchema(sch *schema.Schema, aggregates []*parse.AggregateData) *schema.Schema {
+	groups := schema.NewSchema()
+	groups.AddAll(sch)
+	for _, agg := range aggregates {
+		if agg.FieldName() == "" || agg.Fn() == parse.AGG_APPROX_COUNT_DISTINCT {
+			groups.AddIntField(agg.Name())
+		} else if sch.HasField(agg.FieldName()) {
+			groups.AddField(agg.Name(), sch.DataType(agg.FieldName()), sch.Length(agg.FieldName()))
+		}
+	}
+	return groups
+}
+
+// Checks an update command against the catalog: the fields it assigns,
+// reads and compares must belong to its table, and each term of its predicate must
+// compare values of the same kind. Commands that don't change records, and
+// a missing table, are left to the update planner.
+func (p *Planner) checkUpdate(obj interface{}, tx *tx.Transaction) error {
+	if p.mdm == nil {
+		return nil
+	}
+
+	var tableName string
+	var fields []string
+	var preds []*query.Predicate
+	switch cmd := obj.(type) {
+	case *parse.InsertData:
+		tableName, fields = cmd.TableName(), cmd.Fields()
+	case *parse.DeleteData:
+		tableName, preds = cmd.TableName(), []*query.Predicate{cmd.Pred()}
+	case *parse.ModifyData:
+		tableName = cmd.TableName()
+		fields = append([]string{cmd.TargetField()}, cmd.NewValue().Fields()...)
+		preds = append([]*query.Predicate{cmd.Pred()}, cmd.NewValue().Conditions()...)
+	case *parse.CreateViewData:
+		return p.checkQuery(cmd.Query(), tx)
+	case *parse.CopyToData:
+		return p.checkQuery(cmd.Query(), tx)
+	default:
+		return nil
+	}
+
+	layout, err := p.mdm.GetLayout(tableName, tx)
+	if err != nil {
+		return nil
+	}
+	tables := []string{tableName}
+	for _, field := range fields {
+		if !layout.Schema().HasField(field) {
+			return unknownField(field, tables)
+		}
+	}
+	for _, pred := range preds {
+		if err := checkPredicate(pred, layout.Schema(), tables); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Checks that the fields an expression reads, including those of the
+// conditions of its CASE expressions, are in the schema
+func checkExpression(expr *query.Expression, sch *schema.Schema, tables []string) error {
+	for _, field := range expr.Fields() {
+		if !sch.HasField(field) {
+			return unknownField(field, tables)
+		}
+	}
+	for _, cond := range expr.Conditions() {
+		if err := checkPredicate(cond, sch, tables); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the fields of the tables and views, with their types
+func (p *Planner) sourceSchema(tables []string, tx *tx.Transaction) (*schema.Schema, error) {
+	sch := schema.NewSchema()
+	for _, tableName := range tables {
+		src, err := p.relationSchema(tableName, tx)
+		if err != nil {
+			return nil, err
+		}
+		sch.AddAll(src)
+	}
+	return sch, nil
+}
+
+// Returns the schema of a table or view. A view has the fields its query
+// selects, typed after the fields they come from.
+func (p *Planner) relationSchema(tableName string, tx *tx.Transaction) (*schema.Schema, error) {
+	if tableName == metadata.RELATION_SIZES_VIEW {
+		return relationSizesSchema(), nil
+	}
+
+	viewDef := p.mdm.GetViewDef(tableName, tx)
+	if viewDef == "" {
+		layout, err := p.mdm.GetLayout(tableName, tx)
+		if err != nil {
+			return nil, err
+		}
+		return layout.Schema(), nil
+	}
+
+	viewData, err := parse.NewParser(viewDef).Query()
+	if err != nil {
+		return nil, fmt.Errorf("view %s: %w", tableName, err)
+	}
+	src, err := p.sourceSchema(viewData.Tables(), tx)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregates := make(map[string]*parse.AggregateData)
+	for _, agg := range viewData.Aggregates() {
+		aggregates[agg.Name()] = agg
+	}
+
+	computed := make(map[string]*parse.ComputedData)
+	for _, cd := range viewData.Computed() {
+		computed[cd.Name()] = cd
+	}
+
+	view := schema.NewSchema()
+	for _, field := range viewData.Fields() {
+		agg, ok := aggregates[field]
+		if cd, isComputed := computed[field]; isComputed {
+			fieldType, length := cd.Expression().FieldType(src)
+			view.AddField(field, fieldType, length)
+			continue
+		}
+		switch {
+		case ok && (agg.Fn() == parse.AGG_COUNT || agg.Fn() == parse.AGG_APPROX_COUNT_DISTINCT):
+			view.AddIntField(field)
+		case ok && src.HasField(agg.FieldName()):
+			view.AddField(field, src.DataType(agg.FieldName()), src.Length(agg.FieldName()))
+		case src.HasField(field):
+			view.Add(field, src)
+		}
+	}
+	return view, nil
+}
+
+// Checks that the fields of the predicate are in the schema, and that
+// each term compares values of the same kind or with NULL
+func checkPredicate(pred *query.Predicate, sch *schema.Schema, tables []string) error {
+	if pred == nil {
+		return nil
+	}
+
+	for _, term := range pred.Terms() {
+		if term.IsCompound() {
+			for _, operand := range term.Operands() {
+				if err := checkPredicate(operand, sch, tables); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if term.Op() == query.OP_EXISTS {
+			continue
+		}
+
+		// The values of an IN subquery or list, and the bounds of a BETWEEN,
+		// are compared with the left-hand side
+		rhs := []*query.Expression{term.RHS()}
+		if term.Op() == query.OP_IN && term.Subquery() != nil {
+			rhs = []*query.Expression{query.NewExpressionSubquery(term.Subquery())}
+		} else if term.Op() == query.OP_IN || term.Op() == query.OP_BETWEEN {
+			rhs = term.Values()
+		}
+
+		lhsKind, err := expressionKind(term.LHS(), sch, tables)
+		if err != nil {
+			return err
+		}
+		for _, e := range rhs {
+			rhsKind, err := expressionKind(e, sch, tables)
+			if err != nil {
+				return err
+			}
+
+			// A string compared with a date or timestamp is read as one, as
+			// '2023-01-15' is in "created >= '2023-01-15'"
+			if lhsKind == types.KIND_TIME && rhsKind == types.KIND_STRING {
+				if rhsKind, err = timeKind(e, term); err != nil {
+					return err
+				}
+			} else if rhsKind == types.KIND_TIME && lhsKind == types.KIND_STRING {
+				if lhsKind, err = timeKind(term.LHS(), term); err != nil {
+					return err
+				}
+			}
+
+			// LIKE matches strings with a string pattern
+			if term.Op() == query.OP_LIKE {
+				for _, side := range []*query.Expression{term.LHS(), e} {
+					if kind, _ := expressionKind(side, sch, tables); kind != types.KIND_STRING && kind != types.KIND_NULL {
+						return fmt.Errorf("%w: cannot match %s in %s", ErrTypeMismatch, describeExpression(side, sch), term.String())
+					}
+				}
+				continue
+			}
+
+			// NULL compares with values of any kind
+			if lhsKind != rhsKind && lhsKind != types.KIND_NULL && rhsKind != types.KIND_NULL {
+				return fmt.Errorf("%w: cannot compare %s with %s in %s",
+					ErrTypeMismatch, describeExpression(term.LHS(), sch), describeExpression(e, sch), term.String())
+			}
+		}
+	}
+	return nil
+}
+
+// Reads a string constant of a term as the date or timestamp it spells and
+// returns its new kind. Fails with ErrTypeMismatch if it spells neither,
+// or if the string isn't a constant.
+func timeKind(expr *query.Expression, term query.Term) (int, error) {
+	if expr.IsFieldName() || expr.Subquery() != nil {
+		return 0, fmt.Errorf("%w: cannot compare %s with a date or timestamp in %s", ErrTypeMismatch, expr, term.String())
+	}
+	if err := expr.ToTime(); err != nil {
+		return 0, fmt.Errorf("%w: %w in %s", ErrTypeMismatch, err, term.String())
+	}
+	return types.KIND_TIME, nil
+}
+
+// Returns whether the expression evaluates to a number, a string, a time or a boolean
+func expressionKind(expr *query.Expression, sch *schema.Schema, tables []string) (int, error) {
+	if expr.Subquery() != nil {
+		return expr.Subquery().Kind(), nil
+	}
+	if !expr.IsFieldName() {
+		return expr.AsConstant().Kind(), nil
+	}
+
+	field := expr.AsFieldName()
+	if !sch.HasField(field) {
+		return 0, unknownField(field, tables)
+	}
+	return query.FieldKind(sch, field), nil
+}
+
+// Describes an expression with its type, as in "age (int)" or "'x' (varchar)"
+func describeExpression(expr *query.Expression, sch *schema.Schema) string {
+	if sq := expr.Subquery(); sq != nil {
+		sub := sq.Plan().Schema()
+		return fmt.Sprintf("%s (%s)", expr.String(), typeName(sub, sub.Fields()[0]))
+	}
+	if expr.IsFieldName() {
+		return fmt.Sprintf("%s (%s)", expr.AsFieldName(), typeName(sch, expr.AsFieldName()))
+	}
+	switch c := expr.AsConstant(); {
+	case c.Kind() == types.KIND_STRING:
+		return fmt.Sprintf("'%s' (varchar)", expr.String())
+	case c.IsDate():
+		return fmt.Sprintf("%s (date)", expr.String())
+	case c.IsTimestamp():
+		return fmt.Sprintf("%s (timestamp)", expr.String())
+	case c.AsLong() != nil:
+		return fmt.Sprintf("%s (bigint)", expr.String())
+	case c.AsFloat() != nil:
+		return fmt.Sprintf("%s (float)", expr.String())
+	case c.AsBool() != nil:
+		return fmt.Sprintf("%s (boolean)", expr.String())
+	}
+	return fmt.Sprintf("%s (int)", expr.String())
+}
+
+// Returns the SQL type of a field as written in CREATE TABLE
+func typeName(sch *schema.Schema, field string) string {
+	switch sch.DataType(field) {
+	case schema.VARCHAR:
+		return fmt.Sprintf("varchar(%d)", sch.Length(field))
+	case schema.BIGINT:
+		return "bigint"
+	case schema.FLOAT:
+		return "float"
+	case schema.DATE:
+		return "date"
+	case schema.TIMESTAMP:
+		return "timestamp"
+	case schema.BOOLEAN:
+		return "boolean"
+	case schema.BLOB:
+		return "blob"
+	default:
+		return "int"
+	}
+}
+
+func unknownField(field string, tables []string) error {
+	return fmt.Errorf("%w: %s is not a field of %s", ErrUnknownField, field, strings.Join(tables, ", "))
+}
@@ -0,0 +1,116 @@
+package plan
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"fmt"
+	"slices"
+)
+
+// Implements UNION ALL, outputting the records of the first plan followed
+// by those of the second. Both select as many fields, of the same kinds;
+// the fields are named as in the first plan.
+type UnionPlan struct {
+	p1, p2 interfaces.Plan
+	schema *schema.Schema
+}
+
+// Creates a plan combining the records of p1 and p2. Returns ErrTypeMismatch
+// if a field of one holds values of another kind than the field of the other
+// at its position, such as strings and numbers.
+func NewUnionPlan(p1, p2 interfaces.Plan) (*UnionPlan, error) {
+	fields1, fields2 := p1.Schema().Fields(), p2.Schema().Fields()
+	if len(fields1) != len(fields2) {
+		return nil, fmt.Errorf("the queries of a UNION select %d and %d fields", len(fields1), len(fields2))
+	}
+
+	sch := schema.NewSchema()
+	for i, field := range fields1 {
+		sch1, sch2, field2 := p1.Schema(), p2.Schema(), fields2[i]
+		type1, type2 := sch1.DataType(field), sch2.DataType(field2)
+		if query.FieldKind(sch1, field) != query.FieldKind(sch2, field2) {
+			return nil, fmt.Errorf("%w: cannot combine %s (%s) with %s (%s) in UNION",
+				ErrTypeMismatch, field, typeName(sch1, field), field2, typeName(sch2, field2))
+		}
+		// The field holds the values of both, so numbers and times take the
+		// wider type and strings the longer length
+		if type1 != schema.VARCHAR && type2 > type1 {
+			type1 = type2
+		}
+		sch.AddField(field, type1, max(sch1.Length(field), sch2.Length(field2)))
+		sch.SetCollation(field, sch1.Collation(field))
+		sch.SetNullable(field, sch1.Nullable(field) || sch2.Nullable(field2))
+	}
+	return &UnionPlan{
+		p1:     p1,
+		p2:     p2,
+		schema: sch,
+	}, nil
+}
+
+func (up *UnionPlan) Open() interfaces.Scan {
+	return query.NewUnionScan(up.p1.Open(), up.p2.Open(), up.schema, up.p2.Schema().Fields())
+}
+
+func (up *UnionPlan) BlocksAccessed() int {
+	return up.p1.BlocksAccessed() + up.p2.BlocksAccessed()
+}
+
+func (up *UnionPlan) RecordsOutput() int {
+	return up.p1.RecordsOutput() + up.p2.RecordsOutput()
+}
+
+// Assumes the values of the two plans are different
+func (up *UnionPlan) DistinctValues(fieldName string) int {
+	i := slices.Index(up.schema.Fields(), fieldName)
+	if i < 0 {
+		return up.p1.DistinctValues(fieldName)
+	}
+	return min(up.RecordsOutput(), up.p1.DistinctValues(fieldName)+up.p2.DistinctValues(up.p2.Schema().Fields()[i]))
+}
+
+func (up *UnionPlan) Schema() *schema.Schema {
+	return up.schema
+}
+
+func (up *UnionPlan) Describe() string {
+	return "union all"
+}
+
+func (up *UnionPlan) Children() []interfaces.Plan {
+	return []interfaces.Plan{up.p1, up.p2}
+}
+
+// Plans a query combining queries with UNION. Each query is planned with
+// createPlan, and their records are combined from left to right, removing
+// the duplicates of each plain UNION. The ORDER BY and LIMIT clauses apply
+// to the combined records.
+func CreateUnionPlan(tx *tx.Transaction, data *parse.QueryData, createPlan func(*parse.QueryData, *tx.Transaction) (interfaces.Plan, error)) (interfaces.Plan, error) {
+	operands := data.Operands()
+	p, err := createPlan(operands[0], tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, union := range data.Unions() {
+		p2, err := createPlan(union.Query(), tx)
+		if err != nil {
+			return nil, err
+		}
+		if p, err = NewUnionPlan(p, p2); err != nil {
+			return nil, err
+		}
+		if !union.All() {
+			p = materialize.NewDistinctPlan(tx, p)
+		}
+	}
+
+	p, err = AddOrderBy(tx, p, data)
+	if err != nil {
+		return nil, err
+	}
+	return AddLimit(p, data), nil
+}
@@ -7,23 +7,54 @@ import (
 
 // Defines the interface for executing various database modification operations.
 // It handles all non-query operations like INSERT, DELETE, CREATE TABLE, etc.
-// Each method returns the number of rows affected by the operation.
+// Each method returns the number of rows affected by the operation,
+// or an error if the operation could not be carried out.
 type UpdatePlanner interface {
 	// Processes am INSERT operation and adds new records to the table
-	ExecuteInsert(data *parse.InsertData, tx *tx.Transaction) int
+	ExecuteInsert(data *parse.InsertData, tx *tx.Transaction) (int, error)
 
 	// Removes records from a table based on specific conditions
-	ExecuteDelete(data *parse.DeleteData, tx *tx.Transaction) int
+	ExecuteDelete(data *parse.DeleteData, tx *tx.Transaction) (int, error)
 
 	// Updates existing records in a table
-	ExecuteModify(data *parse.ModifyData, tx *tx.Transaction) int
+	ExecuteModify(data *parse.ModifyData, tx *tx.Transaction) (int, error)
 
 	// Creates a new table in the database
-	ExecuteCreateTable(data *parse.CreateTableData, tx *tx.Transaction) int
+	ExecuteCreateTable(data *parse.CreateTableData, tx *tx.Transaction) (int, error)
 
 	// Creates a new view in the database
-	ExecuteCreateView(data *parse.CreateViewData, tx *tx.Transaction) int
+	ExecuteCreateView(data *parse.CreateViewData, tx *tx.Transaction) (int, error)
 
 	// Creates a new index on specified table columns
-	ExecuteCreateIndex(data *parse.CreateIndexData, tx *tx.Transaction) int
+	ExecuteCreateIndex(data *parse.CreateIndexData, tx *tx.Transaction) (int, error)
+
+	// Removes a table along with its records
+	ExecuteDropTable(data *parse.DropTableData, tx *tx.Transaction) (int, error)
+
+	// Removes an index along with its entries
+	ExecuteDropIndex(data *parse.DropIndexData, tx *tx.Transaction) (int, error)
+
+	// Removes a view
+	ExecuteDropView(data *parse.DropViewData, tx *tx.Transaction) (int, error)
+
+	// Adds a field to a table, rewriting its records
+	ExecuteAddColumn(data *parse.AddColumnData, tx *tx.Transaction) (int, error)
+
+	// Removes a field from a table, rewriting its records
+	ExecuteDropColumn(data *parse.DropColumnData, tx *tx.Transaction) (int, error)
+
+	// Bulk loads the records of a file into a table
+	ExecuteCopy(data *parse.CopyData, tx *tx.Transaction) (int, error)
+
+	// Creates a new user with a password
+	ExecuteCreateUser(data *parse.CreateUserData, tx *tx.Transaction) (int, error)
+
+	// Changes the password of an existing user
+	ExecuteAlterUser(data *parse.AlterUserData, tx *tx.Transaction) (int, error)
+
+	// Gives a user privileges on a table
+	ExecuteGrant(data *parse.GrantData, tx *tx.Transaction) (int, error)
+
+	// Takes privileges on a table away from a user
+	ExecuteRevoke(data *parse.RevokeData, tx *tx.Transaction) (int, error)
 }
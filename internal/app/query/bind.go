@@ -0,0 +1,134 @@
+package query
+
+import (
+	"centauri/internal/app/types"
+	"slices"
+)
+
+// Returns the value bound to a parameter placeholder, or the constant
+// itself if it isn't one. params holds the value of each parameter in order.
+func BindConstant(c *types.Constant, params []*types.Constant) *types.Constant {
+	if c.IsParameter() {
+		return params[c.ParameterIndex()]
+	}
+	return c
+}
+
+// Returns a copy of the expression with each parameter placeholder
+// replaced by the value bound to it
+func (e *Expression) Bind(params []*types.Constant) *Expression {
+	if e == nil {
+		return nil
+	}
+	bound := &Expression{
+		val:      BindConstant(e.val, params),
+		fldName:  e.fldName,
+		op:       e.op,
+		subquery: e.subquery.Bind(params),
+		text:     e.text,
+	}
+	for _, arg := range e.args {
+		bound.args = append(bound.args, arg.Bind(params))
+	}
+	for _, cond := range e.conds {
+		bound.conds = append(bound.conds, cond.Bind(params))
+	}
+	return bound
+}
+
+// Returns a copy of the term with each parameter placeholder
+// replaced by the value bound to it
+func (t *Term) Bind(params []*types.Constant) *Term {
+	bound := &Term{
+		lhs:      t.lhs.Bind(params),
+		rhs:      t.rhs.Bind(params),
+		op:       t.op,
+		subquery: t.subquery.Bind(params),
+	}
+	for _, pred := range t.preds {
+		bound.preds = append(bound.preds, pred.Bind(params))
+	}
+	for _, e := range t.values {
+		bound.values = append(bound.values, e.Bind(params))
+	}
+	return bound
+}
+
+// Returns a copy of the predicate with each parameter placeholder
+// replaced by the value bound to it
+func (p *Predicate) Bind(params []*types.Constant) *Predicate {
+	if p == nil {
+		return nil
+	}
+	bound := NewPredicate()
+	for _, t := range p.terms {
+		bound.terms = append(bound.terms, *t.Bind(params))
+	}
+	return bound
+}
+
+// Returns a copy of the subquery with each parameter placeholder replaced
+// by the value bound to it. The copy has yet to be planned.
+func (sq *Subquery) Bind(params []*types.Constant) *Subquery {
+	if sq == nil {
+		return nil
+	}
+	return NewSubquery(sq.data.BindSubquery(params), sq.valued)
+}
+
+// Returns a copy of the expression with each reference to one of the fields
+// replaced by a parameter placeholder, the i-th field by the i-th parameter.
+// The subqueries nested in it are left as they are.
+func (e *Expression) Parameterize(fields []string) *Expression {
+	if e == nil {
+		return nil
+	}
+	if i := slices.Index(fields, e.fldName); e.op == "" && e.fldName != "" && i >= 0 {
+		return &Expression{val: types.NewConstantParameter(i)}
+	}
+	p := &Expression{
+		val:      e.val,
+		fldName:  e.fldName,
+		op:       e.op,
+		subquery: e.subquery,
+		text:     e.text,
+	}
+	for _, arg := range e.args {
+		p.args = append(p.args, arg.Parameterize(fields))
+	}
+	for _, cond := range e.conds {
+		p.conds = append(p.conds, cond.Parameterize(fields))
+	}
+	return p
+}
+
+// Returns a copy of the term with each reference to one of the fields
+// replaced by a parameter placeholder, see Expression.Parameterize
+func (t *Term) Parameterize(fields []string) *Term {
+	p := &Term{
+		lhs:      t.lhs.Parameterize(fields),
+		rhs:      t.rhs.Parameterize(fields),
+		op:       t.op,
+		subquery: t.subquery,
+	}
+	for _, pred := range t.preds {
+		p.preds = append(p.preds, pred.Parameterize(fields))
+	}
+	for _, e := range t.values {
+		p.values = append(p.values, e.Parameterize(fields))
+	}
+	return p
+}
+
+// Returns a copy of the predicate with each reference to one of the fields
+// replaced by a parameter placeholder, see Expression.Parameterize
+func (p *Predicate) Parameterize(fields []string) *Predicate {
+	if p == nil {
+		return nil
+	}
+	parameterized := NewPredicate()
+	for _, t := range p.terms {
+		parameterized.terms = append(parameterized.terms, *t.Parameterize(fields))
+	}
+	return parameterized
+}
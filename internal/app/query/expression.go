@@ -4,14 +4,25 @@ import (
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
+	"strings"
+	"time"
 )
 
-// Represents a generic expression that can be either a constant value or a field reference.
-// It consists of either a value stored as a Constant, or a field name as a string.
-// Only one of val or fldName will be non-zero at any time.
+// How many characters of a string cost as much to compare as a number
+const STRING_COMPARE_CHARS = 8
+
+// Represents a generic expression that can be either a constant value, a field reference,
+// an operator or function applied to other expressions, as in "salary * 2" or "upper(name)",
+// or the value of a subquery outputting a single record.
+// Only one of val, fldName, op or subquery will be non-zero at any time.
 type Expression struct {
-	val     *types.Constant
-	fldName string
+	val      *types.Constant
+	fldName  string
+	op       string        // operator or function computing the expression from args
+	args     []*Expression // operands of op; for a CASE, the value of each condition and then the ELSE value
+	conds    []*Predicate  // conditions of a CASE, in order
+	subquery *Subquery     // subquery whose only record holds the value
+	text     string        // how a field or constant is written when not by its value, as for an aggregate or NOW()
 }
 
 func NewExpressionVal(val *types.Constant) *Expression {
@@ -26,6 +37,86 @@ func NewExpressionFieldName(fieldName string) *Expression {
 	}
 }
 
+// Creates an expression reading the output field of an aggregate function,
+// written as text, as "count(*)" is in "HAVING count(*) > 5"
+func NewExpressionAggregate(fieldName string, text string) *Expression {
+	return &Expression{
+		fldName: fieldName,
+		text:    text,
+	}
+}
+
+// Creates an expression for NOW(), the time the statement is read at.
+// Its value is the same for every record, like that of a constant.
+func NewExpressionNow() *Expression {
+	return &Expression{
+		val:  types.NewConstantTime(time.Now()),
+		text: FN_NOW + "()",
+	}
+}
+
+// Replaces a string constant by the date or timestamp it spells, so that
+// it compares with dates and timestamps. Other expressions are left as they are.
+// Fails with types.ErrInvalidTime if the string spells neither.
+func (e *Expression) ToTime() error {
+	if e.val == nil || e.val.AsString() == nil {
+		return nil
+	}
+	t, err := types.ParseTime(*e.val.AsString())
+	if err != nil {
+		return err
+	}
+	e.val = t
+	return nil
+}
+
+// Returns whether the values of a field of the schema are numbers, strings,
+// times or booleans (types.KIND_NUMBER, KIND_STRING, KIND_TIME or KIND_BOOL).
+// Blobs are read as strings of their bytes.
+func FieldKind(sch *schema.Schema, field string) int {
+	switch fieldType := sch.DataType(field); {
+	case fieldType == schema.VARCHAR || fieldType == schema.BLOB:
+		return types.KIND_STRING
+	case fieldType.IsTemporal():
+		return types.KIND_TIME
+	case fieldType == schema.BOOLEAN:
+		return types.KIND_BOOL
+	default:
+		return types.KIND_NUMBER
+	}
+}
+
+// Creates an expression applying an arithmetic operator (+, -, * or /) or a
+// function to its operands. A "-" with a single operand negates it.
+func NewExpressionOp(op string, args ...*Expression) *Expression {
+	return &Expression{
+		op:   strings.ToLower(op),
+		args: args,
+	}
+}
+
+// Creates a CASE expression, whose value is that of the first result whose
+// condition holds, or of elseVal if none does. A nil elseVal stands for null.
+func NewExpressionCase(conds []*Predicate, results []*Expression, elseVal *Expression) *Expression {
+	e := &Expression{
+		op:    OP_CASE,
+		args:  results,
+		conds: conds,
+	}
+	if elseVal != nil {
+		e.args = append(e.args, elseVal)
+	}
+	return e
+}
+
+// Creates an expression whose value is that of the only record of the
+// subquery, or null if it has none
+func NewExpressionSubquery(subquery *Subquery) *Expression {
+	return &Expression{
+		subquery: subquery,
+	}
+}
+
 func (e *Expression) IsFieldName() bool {
 	return e.fldName != ""
 }
@@ -38,6 +129,29 @@ func (e *Expression) AsFieldName() string {
 	return e.fldName
 }
 
+// Returns the subquery of the expression, nil if it isn't one
+func (e *Expression) Subquery() *Subquery {
+	return e.subquery
+}
+
+// Returns the subqueries within the expression
+func (e *Expression) subqueries() []*Subquery {
+	if e == nil {
+		return nil
+	}
+	if e.subquery != nil {
+		return []*Subquery{e.subquery}
+	}
+	var subqueries []*Subquery
+	for _, arg := range e.args {
+		subqueries = append(subqueries, arg.subqueries()...)
+	}
+	for _, cond := range e.conds {
+		subqueries = append(subqueries, cond.Subqueries()...)
+	}
+	return subqueries
+}
+
 // Processes the expression and returns a Constant value.
 // If the expression has a predefined value (e.val), it returns that value.
 // Otherwise, it retrieves the value associated with the field name (e.fldName)
@@ -46,8 +160,30 @@ func (e *Expression) Evaluate(s interfaces.Scan) *types.Constant {
 	if e.val != nil {
 		return e.val
 	}
+	if e.subquery != nil {
+		return e.subquery.value(s)
+	}
+	if e.op == "" {
+		return s.GetVal(e.fldName)
+	}
+
+	if e.op == OP_CASE {
+		for i, cond := range e.conds {
+			if cond.IsSatisfied(s) {
+				return e.args[i].Evaluate(s)
+			}
+		}
+		if len(e.args) > len(e.conds) {
+			return e.args[len(e.args)-1].Evaluate(s)
+		}
+		return types.NewConstantNull()
+	}
 
-	return s.GetVal(e.fldName)
+	vals := make([]*types.Constant, len(e.args))
+	for i, arg := range e.args {
+		vals[i] = arg.Evaluate(s)
+	}
+	return apply(e.op, vals)
 }
 
 // AppliesTo checks if the expression is applicable to the given schema.
@@ -59,17 +195,229 @@ func (e *Expression) Evaluate(s interfaces.Scan) *types.Constant {
 // Returns:
 //   - bool: true if the expression applies to the schema, false otherwise
 func (e *Expression) AppliesTo(schema *schema.Schema) bool {
+	if e.subquery != nil {
+		return e.subquery.appliesTo(schema)
+	}
 	if e.val != nil {
 		return true
 	}
+	if e.op == "" {
+		return schema.HasField(e.fldName)
+	}
 
-	return schema.HasField(e.fldName)
+	for _, arg := range e.args {
+		if !arg.AppliesTo(schema) {
+			return false
+		}
+	}
+	for _, cond := range e.conds {
+		if !cond.AppliesTo(schema) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the names of the fields the expression reads, including those
+// in the conditions of a CASE and the outer fields of a correlated subquery
+func (e *Expression) Fields() []string {
+	if e.fldName != "" {
+		return []string{e.fldName}
+	}
+	if e.subquery != nil {
+		return e.subquery.Outer()
+	}
+
+	var fields []string
+	for _, arg := range e.args {
+		fields = append(fields, arg.Fields()...)
+	}
+	for _, cond := range e.conds {
+		for _, t := range cond.terms {
+			fields = append(fields, t.Fields()...)
+		}
+	}
+	return fields
+}
+
+// Returns the conditions of the CASE expressions within the expression
+func (e *Expression) Conditions() []*Predicate {
+	conds := append([]*Predicate(nil), e.conds...)
+	for _, arg := range e.args {
+		conds = append(conds, arg.Conditions()...)
+	}
+	return conds
+}
+
+// Estimates the cost of evaluating the expression on a record of the schema.
+// A constant costs nothing, a number field one comparison, and a string
+// field one more for every STRING_COMPARE_CHARS characters of its length,
+// since comparing strings walks their characters. An operator costs one
+// more than its operands, and a subquery one for each of its records.
+func (e *Expression) Cost(sch *schema.Schema) int {
+	if e.val != nil {
+		return 0
+	}
+	if e.subquery != nil {
+		return e.subquery.recordsOutput()
+	}
+	if e.op != "" {
+		cost := 1
+		for _, arg := range e.args {
+			cost += arg.Cost(sch)
+		}
+		return cost
+	}
+	if sch.DataType(e.fldName) == schema.VARCHAR {
+		return 1 + sch.Length(e.fldName)/STRING_COMPARE_CHARS
+	}
+	return 1
+}
+
+// Returns whether the expression evaluates to a number, a string, a time or a boolean
+// (types.KIND_NUMBER, KIND_STRING, KIND_TIME or KIND_BOOL) on records of the schema,
+// or types.KIND_NULL for the NULL constant.
+// A CASE has the kind of its first result.
+func (e *Expression) Kind(sch *schema.Schema) int {
+	switch {
+	case e.val != nil:
+		return e.val.Kind()
+	case e.subquery != nil:
+		return e.subquery.Kind()
+	case e.op == "":
+		return FieldKind(sch, e.fldName)
+	case e.op == OP_CASE:
+		return e.args[0].Kind(sch)
+	case e.op == "upper" || e.op == "lower" || e.op == "concat":
+		return types.KIND_STRING
+	default:
+		return types.KIND_NUMBER
+	}
+}
+
+// The length of the text of a number, as written by concat: an int
+// takes at most 11 characters
+const NUMBER_TEXT_LENGTH = 11
+
+// The length of the text of a 64-bit integer, as "-9223372036854775808"
+const LONG_TEXT_LENGTH = 20
+
+// The length of the text of a float, as "-2.2250738585072014e-308"
+const FLOAT_TEXT_LENGTH = 24
+
+// Returns the type of the field holding the values of the expression on
+// records of the schema, and for a string its maximum length. Numbers are
+// int, bigint if they may not fit in 32 bits or float if they may have a
+// fraction, and the NULL constant is typed as an int too.
+func (e *Expression) FieldType(sch *schema.Schema) (schema.FieldType, int) {
+	switch {
+	case e.val != nil:
+		if e.val.Kind() == types.KIND_STRING {
+			return schema.VARCHAR, len(e.val.String())
+		} else if e.val.IsDate() {
+			return schema.DATE, 0
+		} else if e.val.IsTimestamp() {
+			return schema.TIMESTAMP, 0
+		} else if e.val.AsBool() != nil {
+			return schema.BOOLEAN, 0
+		} else if e.val.AsLong() != nil {
+			return schema.BIGINT, 0
+		} else if e.val.AsFloat() != nil {
+			return schema.FLOAT, 0
+		}
+		return schema.INTEGER, 0
+	case e.subquery != nil:
+		sub := e.subquery.Plan().Schema()
+		field := e.subquery.field()
+		return sub.DataType(field), sub.Length(field)
+	case e.op == "":
+		if fieldType := sch.DataType(e.fldName); fieldType != schema.INTEGER {
+			return fieldType, sch.Length(e.fldName)
+		}
+		return schema.INTEGER, 0
+	case e.op == OP_CASE:
+		// A string if any result is one, as long as the longest
+		fieldType, length := schema.INTEGER, 0
+		for _, arg := range e.args {
+			if t, l := arg.FieldType(sch); t == schema.VARCHAR && arg.Kind(sch) != types.KIND_NULL {
+				fieldType, length = schema.VARCHAR, max(length, l)
+			}
+		}
+		return fieldType, length
+	case e.op == "upper" || e.op == "lower":
+		return schema.VARCHAR, e.args[0].textLength(sch)
+	case e.op == "concat":
+		return schema.VARCHAR, e.args[0].textLength(sch) + e.args[1].textLength(sch)
+	default:
+		// Arithmetic on a float gives a float, and otherwise on a bigint a bigint
+		fieldType := schema.INTEGER
+		for _, arg := range e.args {
+			if t, _ := arg.FieldType(sch); t == schema.FLOAT {
+				return schema.FLOAT, 0
+			} else if t == schema.BIGINT {
+				fieldType = schema.BIGINT
+			}
+		}
+		return fieldType, 0
+	}
+}
+
+// Returns the maximum length of the text of the expression's values
+func (e *Expression) textLength(sch *schema.Schema) int {
+	if t, l := e.FieldType(sch); t == schema.VARCHAR {
+		return l
+	} else if t.IsTemporal() {
+		return len(types.TIMESTAMP_LAYOUT)
+	} else if t == schema.BOOLEAN {
+		return len("false")
+	} else if t == schema.BIGINT {
+		return LONG_TEXT_LENGTH
+	} else if t == schema.FLOAT {
+		return FLOAT_TEXT_LENGTH
+	}
+	return NUMBER_TEXT_LENGTH
 }
 
 func (e *Expression) String() string {
+	if e.text != "" {
+		return e.text
+	}
 	if e.val != nil {
 		return e.val.String()
 	}
+	if e.subquery != nil {
+		return e.subquery.String()
+	}
+	if e.op == "" {
+		return e.fldName
+	}
 
-	return e.fldName
+	switch {
+	case e.op == OP_CASE:
+		var sb strings.Builder
+		sb.WriteString("case")
+		for i, cond := range e.conds {
+			sb.WriteString(" when " + cond.String() + " then " + e.args[i].String())
+		}
+		if len(e.args) > len(e.conds) {
+			sb.WriteString(" else " + e.args[len(e.args)-1].String())
+		}
+		sb.WriteString(" end")
+		return sb.String()
+	case e.isFunction():
+		args := make([]string, len(e.args))
+		for i, arg := range e.args {
+			args[i] = arg.String()
+		}
+		return e.op + "(" + strings.Join(args, ", ") + ")"
+	case len(e.args) == 1:
+		return "-" + e.args[0].String()
+	default:
+		return "(" + e.args[0].String() + " " + e.op + " " + e.args[1].String() + ")"
+	}
+}
+
+func (e *Expression) isFunction() bool {
+	_, ok := functions[e.op]
+	return ok
 }
@@ -0,0 +1,94 @@
+package query
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"fmt"
+)
+
+// Implements the scan interface for records extended with computed fields.
+// Each computed field is evaluated on the current record of the underlying
+// scan when read; the other fields are read from the underlying scan.
+type ExtendScan struct {
+	s          interfaces.Scan
+	schema     *schema.Schema         // The fields of s followed by the computed fields
+	exprs      map[string]*Expression // The expression computing each computed field
+	subqueries []*Subquery            // The subqueries of the expressions, held until the scan closes
+}
+
+func NewExtendScan(s interfaces.Scan, sch *schema.Schema, exprs map[string]*Expression) *ExtendScan {
+	var subqueries []*Subquery
+	for _, e := range exprs {
+		subqueries = append(subqueries, e.subqueries()...)
+	}
+	for _, sq := range subqueries {
+		sq.hold()
+	}
+	return &ExtendScan{
+		s:          s,
+		schema:     sch,
+		exprs:      exprs,
+		subqueries: subqueries,
+	}
+}
+
+// Positions the scan before the first record
+func (es *ExtendScan) BeforeFirst() {
+	es.s.BeforeFirst()
+}
+
+// Advances to the next record
+func (es *ExtendScan) Next() bool {
+	return es.s.Next()
+}
+
+// Returns the value of an integer field. A computed value too wide for an
+// int panics with ErrInvalidOperand, like the scans evaluating it do on other errors.
+func (es *ExtendScan) GetInt(fieldName string) int {
+	if _, ok := es.exprs[fieldName]; !ok {
+		return es.s.GetInt(fieldName)
+	}
+	val := es.GetVal(fieldName)
+	if val.IsNull() {
+		return 0
+	}
+	if val.AsInt() == nil {
+		panic(fmt.Errorf("%w: %s = %s doesn't fit in an int", ErrInvalidOperand, fieldName, val))
+	}
+	return *val.AsInt()
+}
+
+func (es *ExtendScan) GetString(fieldName string) string {
+	if _, ok := es.exprs[fieldName]; !ok {
+		return es.s.GetString(fieldName)
+	}
+	val := es.GetVal(fieldName)
+	if val.IsNull() {
+		return ""
+	}
+	return val.String()
+}
+
+func (es *ExtendScan) GetVal(fieldName string) *types.Constant {
+	if e, ok := es.exprs[fieldName]; ok {
+		return e.Evaluate(es.s)
+	}
+	return es.s.GetVal(fieldName)
+}
+
+func (es *ExtendScan) HasField(fieldName string) bool {
+	return es.schema.HasField(fieldName)
+}
+
+func (es *ExtendScan) Schema() *schema.Schema {
+	return es.schema
+}
+
+func (es *ExtendScan) Close() {
+	es.s.Close()
+	for _, sq := range es.subqueries {
+		sq.release()
+	}
+	es.subqueries = nil
+}
@@ -0,0 +1,62 @@
+package query
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+)
+
+// Implements the scan interface for LIMIT clauses.
+// It outputs the records of an underlying scan until it has output
+// the limit, and stops reading the underlying scan there.
+type LimitScan struct {
+	s     interfaces.Scan
+	limit int
+	count int
+}
+
+func NewLimitScan(s interfaces.Scan, limit int) *LimitScan {
+	return &LimitScan{
+		s:     s,
+		limit: limit,
+	}
+}
+
+// Positions the scan before the first record
+func (ls *LimitScan) BeforeFirst() {
+	ls.s.BeforeFirst()
+	ls.count = 0
+}
+
+// Advances to the next record, returning false once the limit is reached
+func (ls *LimitScan) Next() bool {
+	if ls.count >= ls.limit || !ls.s.Next() {
+		return false
+	}
+	ls.count++
+	return true
+}
+
+func (ls *LimitScan) GetInt(fieldName string) int {
+	return ls.s.GetInt(fieldName)
+}
+
+func (ls *LimitScan) GetString(fieldName string) string {
+	return ls.s.GetString(fieldName)
+}
+
+func (ls *LimitScan) GetVal(fieldName string) *types.Constant {
+	return ls.s.GetVal(fieldName)
+}
+
+func (ls *LimitScan) HasField(fieldName string) bool {
+	return ls.s.HasField(fieldName)
+}
+
+func (ls *LimitScan) Schema() *schema.Schema {
+	return ls.s.Schema()
+}
+
+func (ls *LimitScan) Close() {
+	ls.s.Close()
+}
@@ -0,0 +1,181 @@
+package query
+
+import (
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// Raised when an operator or function is applied to values it doesn't take
+	ErrInvalidOperand = errors.New("invalid operand")
+	// Raised when dividing by zero
+	ErrDivisionByZero = errors.New("division by zero")
+)
+
+// The operator of a CASE expression
+const OP_CASE = "case"
+
+// The function returning the current time, see NewExpressionNow
+const FN_NOW = "now"
+
+// The functions an expression can call, with their number of arguments
+var functions = map[string]int{
+	"abs":    1,
+	"length": 1,
+	"upper":  1,
+	"lower":  1,
+	"concat": 2,
+	FN_NOW:   0,
+}
+
+// Reports whether name is a function expressions can call, and how many
+// arguments it takes
+func LookupFunction(name string) (int, bool) {
+	arity, ok := functions[strings.ToLower(name)]
+	return arity, ok
+}
+
+// Applies an arithmetic operator or a function to the values of its operands.
+// A null operand makes the result null.
+// Panics with ErrInvalidOperand or ErrDivisionByZero, like the scans
+// evaluating it do on other errors.
+func apply(op string, vals []*types.Constant) *types.Constant {
+	for _, val := range vals {
+		if val.IsNull() {
+			return types.NewConstantNull()
+		}
+	}
+
+	switch op {
+	case "+", "-", "*", "/":
+		if len(vals) == 1 {
+			return arithmetic("*", types.NewConstantInt(-1), vals[0])
+		}
+		return arithmetic(op, vals[0], vals[1])
+	case "abs":
+		if numberKind(vals[0]) == notNumber {
+			panic(fmt.Errorf("%w: abs(%s)", ErrInvalidOperand, vals[0]))
+		}
+		if vals[0].CompareTo(types.NewConstantInt(0)) < 0 {
+			return arithmetic("*", types.NewConstantInt(-1), vals[0])
+		}
+		return vals[0]
+	case "length":
+		return types.NewConstantInt(utf8.RuneCountInString(vals[0].String()))
+	case "upper":
+		return types.NewConstantString(strings.ToUpper(stringOperand(op, vals[0])))
+	case "lower":
+		return types.NewConstantString(strings.ToLower(stringOperand(op, vals[0])))
+	case "concat":
+		return types.NewConstantString(vals[0].String() + vals[1].String())
+	default:
+		panic(fmt.Errorf("%w: unknown operator %s", ErrInvalidOperand, op))
+	}
+}
+
+// Kinds of numbers, from the narrowest to the widest
+const (
+	notNumber = iota
+	intNumber
+	longNumber
+	floatNumber
+)
+
+func numberKind(c *types.Constant) int {
+	switch {
+	case c.Kind() == types.KIND_TIME:
+		return notNumber
+	case c.AsInt() != nil:
+		return intNumber
+	case c.AsLong() != nil:
+		return longNumber
+	case c.AsFloat() != nil:
+		return floatNumber
+	default:
+		return notNumber
+	}
+}
+
+// Computes a op b. The result is as wide as the wider operand; integers
+// that overflow an int become 64-bit and those that overflow 64 bits an error.
+func arithmetic(op string, a, b *types.Constant) *types.Constant {
+	kind := max(numberKind(a), numberKind(b))
+	if numberKind(a) == notNumber || numberKind(b) == notNumber {
+		panic(fmt.Errorf("%w: %s %s %s", ErrInvalidOperand, a, op, b))
+	}
+
+	if kind == floatNumber {
+		x, y := asFloat(a), asFloat(b)
+		switch op {
+		case "+":
+			return types.NewConstantFloat(x + y)
+		case "-":
+			return types.NewConstantFloat(x - y)
+		case "*":
+			return types.NewConstantFloat(x * y)
+		default:
+			if y == 0 {
+				panic(fmt.Errorf("%w: %s / %s", ErrDivisionByZero, a, b))
+			}
+			return types.NewConstantFloat(x / y)
+		}
+	}
+
+	x, y := asLong(a), asLong(b)
+	var result int64
+	switch op {
+	case "+":
+		result = x + y
+		if (result > x) != (y > 0) {
+			panic(fmt.Errorf("%w: %s + %s overflows", ErrInvalidOperand, a, b))
+		}
+	case "-":
+		result = x - y
+		if (result < x) != (y > 0) {
+			panic(fmt.Errorf("%w: %s - %s overflows", ErrInvalidOperand, a, b))
+		}
+	case "*":
+		result = x * y
+		if x != 0 && (result/x != y || (x == -1 && y == math.MinInt64)) {
+			panic(fmt.Errorf("%w: %s * %s overflows", ErrInvalidOperand, a, b))
+		}
+	default:
+		if y == 0 {
+			panic(fmt.Errorf("%w: %s / %s", ErrDivisionByZero, a, b))
+		}
+		if x == math.MinInt64 && y == -1 {
+			panic(fmt.Errorf("%w: %s / %s overflows", ErrInvalidOperand, a, b))
+		}
+		result = x / y
+	}
+
+	if kind == intNumber && result >= math.MinInt32 && result <= math.MaxInt32 {
+		return types.NewConstantInt(int(result))
+	}
+	return types.NewConstantLong(result)
+}
+
+func asFloat(c *types.Constant) float64 {
+	if c.AsFloat() != nil {
+		return *c.AsFloat()
+	}
+	return float64(asLong(c))
+}
+
+func asLong(c *types.Constant) int64 {
+	if c.AsInt() != nil {
+		return int64(*c.AsInt())
+	}
+	return *c.AsLong()
+}
+
+func stringOperand(fn string, c *types.Constant) string {
+	if c.AsString() == nil {
+		panic(fmt.Errorf("%w: %s(%s)", ErrInvalidOperand, fn, c))
+	}
+	return *c.AsString()
+}
@@ -4,11 +4,14 @@ import (
 	"centauri/internal/app/interfaces"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
+	"math"
+	"sort"
 	"strings"
 )
 
 // Represents a Boolean combination of terms.
 // It implements a conjuction (AND) of terms used to filter records in a database query.
+// Disjunctions and negations are terms of their own, see NewOrTerm and NewNotTerm.
 type Predicate struct {
 	terms []Term // Terms that are ANDed together to form the complete predicate. An Empty slice represents a predicate that is always true.
 }
@@ -40,8 +43,42 @@ func (p *Predicate) ConjoinWith(pred *Predicate) {
 // The predicate is satisfied if all of its terms are satisfied.
 // An empty predicate (no terms) is always satisfied.
 func (p *Predicate) IsSatisfied(s interfaces.Scan) bool {
+	return p.evaluate(s) == truthTrue
+}
+
+// The truth values of SQL's three-valued logic, ordered so that AND takes
+// the least of its operands and OR the greatest
+type truth int
+
+const (
+	truthFalse truth = iota
+	truthUnknown
+	truthTrue
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+// Evaluates the conjunction of the terms, stopping at the first false one
+func (p *Predicate) evaluate(s interfaces.Scan) truth {
+	result := truthTrue
+	for _, t := range p.terms {
+		result = min(result, t.evaluate(s))
+		if result == truthFalse {
+			break
+		}
+	}
+	return result
+}
+
+// Checks if all the terms of the predicate can be evaluated using the schema
+func (p *Predicate) AppliesTo(schema *schema.Schema) bool {
 	for _, t := range p.terms {
-		if !t.IsSatisfied(s) {
+		if !t.AppliesTo(schema) {
 			return false
 		}
 	}
@@ -52,17 +89,57 @@ func (p *Predicate) IsSatisfied(s interfaces.Scan) bool {
 // by a query. For e.g, If the reduction factor is 2, then the predicate cuts the size of the output in half.
 //
 // The reduction factor of the entire predicate is the product of the reduction factors of its individual terms, as each
-// term further filters the result set.
+// term further filters the result set. It stops growing at math.MaxInt, meaning no record is output.
 func (p *Predicate) ReductionFactor(plan interfaces.Plan) int {
 	factor := 1
 
 	for _, t := range p.terms {
-		factor *= t.ReductionFactor(plan)
+		rf := t.ReductionFactor(plan)
+		if rf > 0 && factor > math.MaxInt/rf {
+			return math.MaxInt
+		}
+		factor *= rf
 	}
 
 	return factor
 }
 
+// Returns the fraction of records kept by a condition with the reduction factor
+func keptBy(rf int) float64 {
+	return 1 / float64(max(1, rf))
+}
+
+// Converts the fraction of records a condition keeps into a reduction factor
+func reductionFactorOf(kept float64) int {
+	if kept*float64(math.MaxInt) < 1 {
+		return math.MaxInt
+	}
+	return max(1, int(math.Round(1/kept)))
+}
+
+// Returns a predicate with the same terms in the order that lets IsSatisfied,
+// which stops at the first unsatisfied term, reject records most cheaply.
+// Each term is ranked by its cost divided by the fraction of records it
+// rejects, so cheap and selective terms come first. Terms rejecting nothing
+// come last, and terms of equal rank keep their order.
+func (p *Predicate) Reorder(plan interfaces.Plan) *Predicate {
+	ranks := make([]float64, len(p.terms))
+	order := make([]int, len(p.terms))
+	for i := range p.terms {
+		order[i] = i
+		ranks[i] = p.terms[i].rank(plan)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return ranks[order[i]] < ranks[order[j]]
+	})
+
+	result := NewPredicate()
+	for _, i := range order {
+		result.terms = append(result.terms, p.terms[i])
+	}
+	return result
+}
+
 // Returns a new predicate contanining only the terms that can be evaluated using the specified schema.
 // A term can be evaluated if all fields it references are in the schema.
 func (p *Predicate) SelectSubPred(schema *schema.Schema) *Predicate {
@@ -122,6 +199,52 @@ func (p *Predicate) EquatesWithConstant(fldName string) *types.Constant {
 	return nil
 }
 
+// Searches for a term restricting the specified field to a list of constants,
+// as in "fieldName IN (1, 2, 3)" or "fieldName BETWEEN 1 AND 3", and returns
+// the constants, see Term.MatchesConstants.
+//
+// An index can look up each of the constants instead of the table being scanned.
+func (p *Predicate) MatchesConstants(fldName string) []*types.Constant {
+	for _, t := range p.terms {
+		if vals := t.MatchesConstants(fldName); vals != nil {
+			return vals
+		}
+	}
+	return nil
+}
+
+// Searches for terms comparing the specified field with a constant, as in
+// "fieldName > constant" or "fieldName BETWEEN 1 AND 10", and returns the
+// range of values they restrict the field to. A nil bound leaves its end of
+// the range open, and both are nil if no term bounds the field. The inclusive
+// flags tell whether the values equal to the bounds are in the range.
+//
+// An index keeping its keys in order can look up the range instead of the
+// table being scanned.
+func (p *Predicate) Bounds(fldName string) (low *types.Constant, high *types.Constant, lowInclusive bool, highInclusive bool) {
+	for _, t := range p.terms {
+		for _, b := range t.bounds(fldName) {
+			inclusive := b.op == OP_GREATER_EQUAL || b.op == OP_LESS_EQUAL
+			if b.op == OP_GREATER || b.op == OP_GREATER_EQUAL {
+				// Keep the higher of the lower bounds
+				if low == nil || tighter(b.val.CompareTo(low), inclusive) {
+					low, lowInclusive = b.val, inclusive
+				}
+			} else if high == nil || tighter(high.CompareTo(b.val), inclusive) {
+				// Keep the lower of the upper bounds
+				high, highInclusive = b.val, inclusive
+			}
+		}
+	}
+	return low, high, lowInclusive, highInclusive
+}
+
+// Reports whether a bound is tighter than the one found before, cmp being
+// positive if it lies further inside the range than that one
+func tighter(cmp int, inclusive bool) bool {
+	return cmp > 0 || (cmp == 0 && !inclusive)
+}
+
 // Searches for terms of the form "fieldName = otherField" and returns the name of the other field if such a
 // term exists for the specified field.
 //
@@ -158,6 +281,18 @@ func (p *Predicate) String() string {
 
 }
 
+// Returns the subqueries the predicate reads, including nested ones
+func (p *Predicate) Subqueries() []*Subquery {
+	if p == nil {
+		return nil
+	}
+	var subqueries []*Subquery
+	for _, t := range p.terms {
+		subqueries = append(subqueries, t.subqueries()...)
+	}
+	return subqueries
+}
+
 func (p *Predicate) Terms() []Term {
 	return p.terms
 }
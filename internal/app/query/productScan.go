@@ -2,6 +2,7 @@ package query
 
 import (
 	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
 )
 
@@ -9,8 +10,9 @@ import (
 // It combines records from two input scans to produce their Cartesian product.
 // For each record in S1, it iterates through all records in s2.
 type ProductScan struct {
-	s1 interfaces.Scan
-	s2 interfaces.Scan
+	s1     interfaces.Scan
+	s2     interfaces.Scan
+	s1Done bool // whether s1 has no more records
 }
 
 func NewProductScan(s1, s2 interfaces.Scan) *ProductScan {
@@ -19,7 +21,7 @@ func NewProductScan(s1, s2 interfaces.Scan) *ProductScan {
 		s2: s2,
 	}
 
-	s1.BeforeFirst() // Position at first record of s1
+	ps.BeforeFirst() // Position at first record of s1
 	return ps
 }
 
@@ -29,7 +31,7 @@ func NewProductScan(s1, s2 interfaces.Scan) *ProductScan {
 //  2. Resetting s2 to before its first record
 func (ps *ProductScan) BeforeFirst() {
 	ps.s1.BeforeFirst()
-	ps.s1.Next() // Move to first record of s1
+	ps.s1Done = !ps.s1.Next() // Move to first record of s1
 	ps.s2.BeforeFirst()
 }
 
@@ -38,13 +40,20 @@ func (ps *ProductScan) BeforeFirst() {
 // 1. Try to advance s2
 // 2. If s2 reaches end, reset s2 and advance s1
 func (ps *ProductScan) Next() bool {
+	if ps.s1Done {
+		return false
+	}
 	if ps.s2.Next() {
 		return true
 	}
 
 	// If s2 is exhausted, reset it and try next record in s1
 	ps.s2.BeforeFirst()
-	return ps.s2.Next() && ps.s1.Next()
+	if !ps.s2.Next() {
+		return false
+	}
+	ps.s1Done = !ps.s1.Next()
+	return !ps.s1Done
 }
 
 // Returns an integer value from the current record.
@@ -78,6 +87,14 @@ func (ps *ProductScan) HasField(fieldName string) bool {
 	return ps.s1.HasField(fieldName) || ps.s2.HasField(fieldName)
 }
 
+// Returns the fields of the LHS scan followed by those of the RHS scan
+func (ps *ProductScan) Schema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddAll(ps.s1.Schema())
+	sch.AddAll(ps.s2.Schema())
+	return sch
+}
+
 func (ps *ProductScan) Close() {
 	ps.s1.Close()
 	ps.s2.Close()
@@ -2,6 +2,7 @@ package query
 
 import (
 	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
 	"errors"
 )
@@ -66,6 +67,16 @@ func (ps *ProjectScan) HasField(fieldName string) bool {
 	return false
 }
 
+// Returns the projected fields, in the order of the field list
+func (ps *ProjectScan) Schema() *schema.Schema {
+	src := ps.s.Schema()
+	sch := schema.NewSchema()
+	for _, fieldName := range ps.fieldList {
+		sch.Add(fieldName, src)
+	}
+	return sch
+}
+
 func (ps *ProjectScan) Close() {
 	ps.s.Close()
 }
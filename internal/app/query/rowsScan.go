@@ -0,0 +1,73 @@
+package query
+
+import (
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+)
+
+// Implements the scan interface over a list of records held in memory.
+// It is used for results that are computed rather than read from a table,
+// such as the system views.
+type RowsScan struct {
+	schema  *schema.Schema
+	rows    []map[string]*types.Constant
+	current int
+}
+
+func NewRowsScan(schema *schema.Schema, rows []map[string]*types.Constant) *RowsScan {
+	return &RowsScan{
+		schema:  schema,
+		rows:    rows,
+		current: -1,
+	}
+}
+
+// Positions the scan before the first record
+func (rs *RowsScan) BeforeFirst() {
+	rs.current = -1
+}
+
+// Advances to the next record
+func (rs *RowsScan) Next() bool {
+	if rs.current+1 >= len(rs.rows) {
+		rs.current = len(rs.rows)
+		return false
+	}
+	rs.current++
+	return true
+}
+
+func (rs *RowsScan) GetInt(fieldName string) int {
+	val := rs.GetVal(fieldName)
+	if val == nil || val.AsInt() == nil {
+		return 0
+	}
+	return *val.AsInt()
+}
+
+func (rs *RowsScan) GetString(fieldName string) string {
+	val := rs.GetVal(fieldName)
+	if val == nil || val.AsString() == nil {
+		return ""
+	}
+	return *val.AsString()
+}
+
+// Returns the value of the field in the current record,
+// or nil if the scan isn't positioned on a record
+func (rs *RowsScan) GetVal(fieldName string) *types.Constant {
+	if rs.current < 0 || rs.current >= len(rs.rows) {
+		return nil
+	}
+	return rs.rows[rs.current][fieldName]
+}
+
+func (rs *RowsScan) HasField(fieldName string) bool {
+	return rs.schema.HasField(fieldName)
+}
+
+func (rs *RowsScan) Schema() *schema.Schema {
+	return rs.schema
+}
+
+func (rs *RowsScan) Close() {}
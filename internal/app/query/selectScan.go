@@ -2,6 +2,7 @@ package query
 
 import (
 	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
 	"errors"
 )
@@ -11,14 +12,23 @@ import (
 // The scan provides both read and update operations on the filtered records.
 type SelectScan struct {
 	interfaces.UpdateScan
-	s    interfaces.Scan // The underlying scan
-	pred *Predicate      // The selection predicate
+	s          interfaces.Scan // The underlying scan
+	pred       *Predicate      // The selection predicate
+	subqueries []*Subquery     // The subqueries of pred, held until the scan closes
 }
 
+// Creates a scan of the records of s satisfying the predicate. The records
+// of the predicate's subqueries are kept open until the scan closes, rather
+// than read anew for each record.
 func NewSelectScan(s interfaces.Scan, pred *Predicate) *SelectScan {
+	subqueries := pred.Subqueries()
+	for _, sq := range subqueries {
+		sq.hold()
+	}
 	return &SelectScan{
-		s:    s,
-		pred: pred,
+		s:          s,
+		pred:       pred,
+		subqueries: subqueries,
 	}
 }
 
@@ -56,8 +66,16 @@ func (ss *SelectScan) HasField(fieldName string) bool {
 	return ss.s.HasField(fieldName)
 }
 
+func (ss *SelectScan) Schema() *schema.Schema {
+	return ss.s.Schema()
+}
+
 func (ss *SelectScan) Close() {
 	ss.s.Close()
+	for _, sq := range ss.subqueries {
+		sq.release()
+	}
+	ss.subqueries = nil
 }
 
 // UpdateScan Interface implementation methods
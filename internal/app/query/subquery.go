@@ -0,0 +1,234 @@
+package query
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Returned when a subquery compared as a single value outputs more than one record
+var ErrSubqueryRecords = errors.New("subquery returned more than one record")
+
+// The parsed query of a subquery. It is a *parse.QueryData, which this
+// package can't name since the parser builds on it.
+type SubqueryData interface {
+	String() string
+	// Returns a copy with each parameter placeholder replaced by the value bound to it
+	BindSubquery(params []*types.Constant) SubqueryData
+}
+
+// Computes the records of a correlated subquery for the values of the
+// outer fields it refers to
+type CorrelatedPlanner func(outerVals []*types.Constant) (interfaces.Plan, error)
+
+// Represents a query nested in a predicate, as in "id IN (SELECT ...)",
+// "EXISTS (SELECT ...)" or "salary > (SELECT ...)". The parser creates it
+// with the parsed query, and the planner sets the plan computing its records
+// before the statement around it runs. A subquery that doesn't refer to the
+// fields of the statement around it has the same records for every record
+// it is evaluated on: the planner materializes them once, and they are read
+// again at each evaluation. A correlated one, which does, is planned anew
+// for each record with the values of those fields.
+type Subquery struct {
+	data      SubqueryData
+	valued    bool              // The values of the records are compared, which needs a single field
+	plan      interfaces.Plan   // Computes the records, nil until planned
+	scan      interfaces.Scan   // Open on plan between evaluations while held
+	users     int               // The number of open scans holding the scan open
+	outer     []string          // The fields of the statement around it a correlated subquery refers to
+	correlate CorrelatedPlanner // Plans a correlated subquery for the values of outer
+	outerVals []*types.Constant // The values of outer the scan was opened for
+}
+
+func NewSubquery(data SubqueryData, valued bool) *Subquery {
+	return &Subquery{
+		data:   data,
+		valued: valued,
+	}
+}
+
+// Returns the parsed query
+func (sq *Subquery) Data() SubqueryData {
+	return sq.data
+}
+
+// Reports whether the values of the subquery's records are compared, as in
+// IN or a comparison, rather than only whether there are any, as in EXISTS.
+// A valued subquery must select a single field.
+func (sq *Subquery) Valued() bool {
+	return sq.valued
+}
+
+// Sets the plan computing the records of the subquery
+func (sq *Subquery) SetPlan(p interfaces.Plan) {
+	sq.plan = p
+}
+
+// Returns the plan computing the records of the subquery, nil until planned
+func (sq *Subquery) Plan() interfaces.Plan {
+	return sq.plan
+}
+
+// Makes the subquery correlated: it refers to the outer fields of the
+// statement around it, and is planned by correlate for each of their values.
+// The plan set with SetPlan stays the one its schema and estimates come from.
+func (sq *Subquery) SetCorrelated(outer []string, correlate CorrelatedPlanner) {
+	sq.outer = outer
+	sq.correlate = correlate
+}
+
+// Returns the fields of the statement around a correlated subquery that
+// it refers to, or nil if it isn't correlated
+func (sq *Subquery) Outer() []string {
+	return sq.outer
+}
+
+// Checks if the fields the subquery refers to are in the schema
+func (sq *Subquery) appliesTo(sch *schema.Schema) bool {
+	for _, field := range sq.outer {
+		if !sch.HasField(field) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the kind of the values of the subquery, see FieldKind.
+// It must have been planned.
+func (sq *Subquery) Kind() int {
+	return FieldKind(sq.plan.Schema(), sq.field())
+}
+
+// Returns the field a valued subquery selects
+func (sq *Subquery) field() string {
+	return sq.plan.Schema().Fields()[0]
+}
+
+// Estimates the number of records the subquery outputs
+func (sq *Subquery) recordsOutput() int {
+	if sq.plan == nil {
+		return 1
+	}
+	return max(1, sq.plan.RecordsOutput())
+}
+
+// Keeps the scan over the subquery's records open between evaluations,
+// until release is called as often
+func (sq *Subquery) hold() {
+	sq.users++
+}
+
+func (sq *Subquery) release() {
+	sq.users--
+	if sq.users == 0 && sq.scan != nil {
+		sq.scan.Close()
+		sq.scan = nil
+	}
+}
+
+// Calls visit on each record of the subquery, evaluated on the record of
+// outer, until it returns false. The scan over the records is reused while
+// held, as long as the values of the outer fields stay the same, and
+// otherwise closed.
+func (sq *Subquery) each(outer interfaces.Scan, visit func(s interfaces.Scan) bool) {
+	if sq.plan == nil {
+		panic(fmt.Errorf("subquery %s has not been planned", sq))
+	}
+	if sq.correlate != nil {
+		sq.openFor(outer)
+	}
+	if sq.scan == nil {
+		sq.scan = sq.plan.Open()
+	} else {
+		sq.scan.BeforeFirst()
+	}
+	for sq.scan.Next() && visit(sq.scan) {
+	}
+	if sq.users == 0 {
+		sq.scan.Close()
+		sq.scan = nil
+	}
+}
+
+// Opens the scan of a correlated subquery on the records it has for the
+// values of the outer fields in the record of outer, unless it is already.
+// Panics if the subquery can't be planned for them.
+func (sq *Subquery) openFor(outer interfaces.Scan) {
+	vals := make([]*types.Constant, len(sq.outer))
+	for i, field := range sq.outer {
+		vals[i] = outer.GetVal(field)
+	}
+	same := func(a, b *types.Constant) bool { return a.Equals(b) }
+	if sq.scan != nil && slices.EqualFunc(vals, sq.outerVals, same) {
+		return
+	}
+
+	p, err := sq.correlate(vals)
+	if err != nil {
+		panic(fmt.Errorf("subquery %s: %w", sq, err))
+	}
+	if sq.scan != nil {
+		sq.scan.Close()
+	}
+	sq.scan = p.Open()
+	sq.outerVals = vals
+}
+
+// Reports whether the subquery outputs any record
+func (sq *Subquery) exists(outer interfaces.Scan) bool {
+	found := false
+	sq.each(outer, func(s interfaces.Scan) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// Reports whether the value is one of the subquery's values. As with a
+// comparison, a null value, or a null among the values when none equals
+// the value, makes it unknown.
+func (sq *Subquery) contains(val *types.Constant, outer interfaces.Scan) truth {
+	result := truthFalse
+	sq.each(outer, func(s interfaces.Scan) bool {
+		if val.IsNull() {
+			result = truthUnknown
+			return false
+		}
+		v := s.GetVal(sq.field())
+		if v.IsNull() {
+			result = truthUnknown
+			return true
+		}
+		if v.CompareTo(val) == 0 {
+			result = truthTrue
+			return false
+		}
+		return true
+	})
+	return result
+}
+
+// Returns the value of the subquery's only record, or null if it has none.
+// Panics with ErrSubqueryRecords if it has more than one.
+func (sq *Subquery) value(outer interfaces.Scan) *types.Constant {
+	val := types.NewConstantNull()
+	records := 0
+	sq.each(outer, func(s interfaces.Scan) bool {
+		records++
+		if records == 1 {
+			val = s.GetVal(sq.field())
+		}
+		return records == 1
+	})
+	if records > 1 {
+		panic(fmt.Errorf("%w: %s", ErrSubqueryRecords, sq))
+	}
+	return val
+}
+
+func (sq *Subquery) String() string {
+	return "(" + sq.data.String() + ")"
+}
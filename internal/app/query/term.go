@@ -5,45 +5,348 @@ import (
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
 	"math"
+	"slices"
+	"strings"
 )
 
+// The comparisons a term can make
+const (
+	OP_EQUALS        = "="
+	OP_NOT_EQUALS    = "<>"
+	OP_LESS          = "<"
+	OP_LESS_EQUAL    = "<="
+	OP_GREATER       = ">"
+	OP_GREATER_EQUAL = ">="
+	OP_IS_NULL       = "is null"
+	OP_IS_NOT_NULL   = "is not null"
+	OP_OR            = "or"      // Any of the term's predicates holds
+	OP_NOT           = "not"     // The term's only predicate doesn't hold
+	OP_IN            = "in"      // The left-hand side is one of the values of the term's subquery or list
+	OP_EXISTS        = "exists"  // The term's subquery outputs a record
+	OP_BETWEEN       = "between" // The left-hand side is within the two values of the term's list
+	OP_LIKE          = "like"    // The left-hand side matches the pattern on the right-hand side
+)
+
+// The reduction factor estimated for a range comparison with a constant,
+// which keeps about a third of the records
+const RANGE_REDUCTION_FACTOR = 3
+
+// The most integers a BETWEEN may span to be looked up one by one in an index
+const MAX_LIST_KEYS = 64
+
 // Term represents a logical term in a query expression,
 // consisting of left-hand side (lhs) and right-hand side (rhs) expressions.
 // It is used to build complex query conditions where two expressions
 // are related through some operation or comparison.
+// An OP_OR or OP_NOT term instead combines predicates, which makes
+// predicates a tree of AND, OR and NOT nodes with comparisons at the leaves.
+// An OP_IN or OP_EXISTS term reads the records of a subquery, and an
+// OP_IN or OP_BETWEEN term may instead compare with a list of values.
 type Term struct {
-	lhs *Expression
-	rhs *Expression
+	lhs      *Expression
+	rhs      *Expression
+	op       string        // One of the OP_ constants
+	preds    []*Predicate  // The operands of an OP_OR or OP_NOT term
+	subquery *Subquery     // The subquery of an OP_IN or OP_EXISTS term
+	values   []*Expression // The list of an OP_IN term, or the bounds of an OP_BETWEEN term
 }
 
 func NewTerm(lhs *Expression, rhs *Expression) *Term {
 	return &Term{
 		lhs: lhs,
 		rhs: rhs,
+		op:  OP_EQUALS,
+	}
+}
+
+// Creates a term comparing two expressions with one of OP_EQUALS,
+// OP_NOT_EQUALS, OP_LESS, OP_LESS_EQUAL, OP_GREATER or OP_GREATER_EQUAL
+func NewComparisonTerm(lhs *Expression, op string, rhs *Expression) *Term {
+	return &Term{
+		lhs: lhs,
+		rhs: rhs,
+		op:  op,
+	}
+}
+
+// Creates a term checking whether an expression is null, or with not
+// set, whether it isn't. Its right-hand side is the NULL constant.
+func NewIsNullTerm(lhs *Expression, not bool) *Term {
+	op := OP_IS_NULL
+	if not {
+		op = OP_IS_NOT_NULL
+	}
+	return &Term{
+		lhs: lhs,
+		rhs: NewExpressionVal(types.NewConstantNull()),
+		op:  op,
+	}
+}
+
+// Creates a term that holds when any of the predicates holds
+func NewOrTerm(disjuncts ...*Predicate) *Term {
+	return &Term{
+		op:    OP_OR,
+		preds: disjuncts,
 	}
 }
 
+// Creates a term that holds when the predicate doesn't
+func NewNotTerm(pred *Predicate) *Term {
+	return &Term{
+		op:    OP_NOT,
+		preds: []*Predicate{pred},
+	}
+}
+
+// Creates a term that holds when the value of the expression is one of
+// the values of the subquery
+func NewInTerm(lhs *Expression, subquery *Subquery) *Term {
+	return &Term{
+		lhs:      lhs,
+		op:       OP_IN,
+		subquery: subquery,
+	}
+}
+
+// Creates a term that holds when the value of the expression equals one of the values
+func NewInListTerm(lhs *Expression, values []*Expression) *Term {
+	return &Term{
+		lhs:    lhs,
+		op:     OP_IN,
+		values: values,
+	}
+}
+
+// Creates a term that holds when the value of the expression is at least
+// low and at most high
+func NewBetweenTerm(lhs *Expression, low *Expression, high *Expression) *Term {
+	return &Term{
+		lhs:    lhs,
+		op:     OP_BETWEEN,
+		values: []*Expression{low, high},
+	}
+}
+
+// Creates a term that holds when the string value of the expression matches
+// the pattern, see Like
+func NewLikeTerm(lhs *Expression, pattern *Expression) *Term {
+	return &Term{
+		lhs: lhs,
+		rhs: pattern,
+		op:  OP_LIKE,
+	}
+}
+
+// Creates a term that holds when the subquery outputs a record
+func NewExistsTerm(subquery *Subquery) *Term {
+	return &Term{
+		op:       OP_EXISTS,
+		subquery: subquery,
+	}
+}
+
+// Reports whether the term combines predicates rather than comparing expressions
+func (t *Term) IsCompound() bool {
+	return t.op == OP_OR || t.op == OP_NOT
+}
+
 // Checks if the term's condition is satisfied by comparing left-hand side
 // and right-hand side expressions' evaluated values.
+// Comparing a null with any value, null included, is neither true nor
+// false but unknown, which doesn't satisfy the term: only IS NULL finds nulls.
 //
 // Parameters:
 //   - s: A Scan interface that provides access to the current record/row data
 //
 // Returns:
-//   - bool: true if the left and right expressions evaluate to equal values, false otherwise
+//   - bool: true if the left and right expressions' values compare as the term's operator requires, false otherwise
 func (t *Term) IsSatisfied(s interfaces.Scan) bool {
+	return t.evaluate(s) == truthTrue
+}
+
+// Evaluates the term against the current record of the scan. A comparison
+// with null is unknown, OR is true if any operand is and NOT swaps true and
+// false, so that NOT of an unknown comparison is still unknown.
+func (t *Term) evaluate(s interfaces.Scan) truth {
+	switch t.op {
+	case OP_OR:
+		result := truthFalse
+		for _, pred := range t.preds {
+			result = max(result, pred.evaluate(s))
+			if result == truthTrue {
+				break
+			}
+		}
+		return result
+	case OP_NOT:
+		return truthTrue - t.preds[0].evaluate(s)
+	case OP_EXISTS:
+		return truthOf(t.subquery.exists(s))
+	}
+
 	lhsVal := t.lhs.Evaluate(s)
+	switch t.op {
+	case OP_IN:
+		if t.subquery == nil {
+			return t.listContains(lhsVal, s)
+		}
+		return t.subquery.contains(lhsVal, s)
+	case OP_BETWEEN:
+		if lhsVal.IsNull() {
+			return truthUnknown
+		}
+		result := truthTrue
+		for i, op := range []string{OP_GREATER_EQUAL, OP_LESS_EQUAL} {
+			bound := t.values[i].Evaluate(s)
+			if bound.IsNull() {
+				result = min(result, truthUnknown)
+			} else {
+				result = min(result, truthOf(compare(lhsVal.CompareTo(bound), op)))
+			}
+		}
+		return result
+	case OP_IS_NULL:
+		return truthOf(lhsVal.IsNull())
+	case OP_IS_NOT_NULL:
+		return truthOf(!lhsVal.IsNull())
+	}
+
 	rhsVal := t.rhs.Evaluate(s)
-	return rhsVal.Equals(lhsVal)
+	if lhsVal.IsNull() || rhsVal.IsNull() {
+		return truthUnknown
+	}
+	if t.op == OP_LIKE {
+		return truthOf(Like(stringOperand(OP_LIKE, lhsVal), stringOperand(OP_LIKE, rhsVal)))
+	}
+	return truthOf(compare(lhsVal.CompareTo(rhsVal), t.op))
+}
+
+// Reports whether the value equals one of the values of the term's list.
+// As with a comparison, a null value, or a null in the list when none
+// equals the value, makes it unknown.
+func (t *Term) listContains(val *types.Constant, s interfaces.Scan) truth {
+	if val.IsNull() {
+		return truthUnknown
+	}
+	result := truthFalse
+	for _, e := range t.values {
+		v := e.Evaluate(s)
+		if v.IsNull() {
+			result = truthUnknown
+		} else if v.CompareTo(val) == 0 {
+			return truthTrue
+		}
+	}
+	return result
+}
+
+// Reports whether the string matches the pattern of a LIKE, in which
+// % matches any sequence of characters, _ any single character, and
+// any other character itself
+func Like(str string, pattern string) bool {
+	s, p := []rune(str), []rune(pattern)
+	// The positions to return to when a mismatch follows the last %
+	star, match := -1, 0
+	i, j := 0, 0
+	for i < len(s) {
+		switch {
+		case j < len(p) && (p[j] == '_' || p[j] == s[i]) && p[j] != '%':
+			i++
+			j++
+		case j < len(p) && p[j] == '%':
+			star, match = j, i
+			j++
+		case star >= 0:
+			// Let the last % match one more character
+			match++
+			i, j = match, star+1
+		default:
+			return false
+		}
+	}
+	for j < len(p) && p[j] == '%' {
+		j++
+	}
+	return j == len(p)
+}
+
+// Reports whether the result of comparing two values satisfies the comparison operator
+func compare(cmp int, op string) bool {
+	switch op {
+	case OP_NOT_EQUALS:
+		return cmp != 0
+	case OP_LESS:
+		return cmp < 0
+	case OP_LESS_EQUAL:
+		return cmp <= 0
+	case OP_GREATER:
+		return cmp > 0
+	case OP_GREATER_EQUAL:
+		return cmp >= 0
+	default:
+		return cmp == 0
+	}
 }
 
 // Checks if both the left-hand side (lhs) and right-hand side (rhs) of the term
 // are applicable to the given schema. This method is used to validate if the term's operands
 // are compatible with the schema structure.
 func (t *Term) AppliesTo(schema *schema.Schema) bool {
+	if t.IsCompound() {
+		for _, pred := range t.preds {
+			if !pred.AppliesTo(schema) {
+				return false
+			}
+		}
+		return true
+	}
+	if t.subquery != nil && !t.subquery.appliesTo(schema) {
+		return false
+	}
+	switch t.op {
+	case OP_EXISTS:
+		return true
+	case OP_IN, OP_BETWEEN:
+		for _, e := range t.values {
+			if !e.AppliesTo(schema) {
+				return false
+			}
+		}
+		return t.lhs.AppliesTo(schema)
+	}
 	return t.lhs.AppliesTo(schema) && t.rhs.AppliesTo(schema)
 }
 
+// Returns the names of the fields the term reads. Those its subquery
+// reads belong to the subquery's own tables, except the outer fields a
+// correlated one refers to.
+func (t *Term) Fields() []string {
+	if t.IsCompound() {
+		var fields []string
+		for _, pred := range t.preds {
+			for _, term := range pred.terms {
+				fields = append(fields, term.Fields()...)
+			}
+		}
+		return fields
+	}
+	switch t.op {
+	case OP_EXISTS:
+		return t.subquery.Outer()
+	case OP_IN, OP_BETWEEN:
+		fields := t.lhs.Fields()
+		if t.subquery != nil {
+			fields = append(fields, t.subquery.Outer()...)
+		}
+		for _, e := range t.values {
+			fields = append(fields, e.Fields()...)
+		}
+		return fields
+	}
+	return append(t.lhs.Fields(), t.rhs.Fields()...)
+}
+
 // Calculates the estimated reduction factor for a Term when applied to a given Plan.
 // This factor represents how much the result set is expected to be reduced when this term`s condition
 // is applied during query execution.
@@ -52,12 +355,82 @@ func (t *Term) AppliesTo(schema *schema.Schema) bool {
 //   - An Integer representing the estimated reduction factor:
 //   - For field-to-field comparisions: maximum distinct value count between the two fields
 //   - For field-to-constant comparisions: distinct value count of the field
+//   - For range comparisons involving a field: RANGE_REDUCTION_FACTOR
+//   - For inequalities involving a field: 1 (no reduction)
+//   - For OR: the inverse of the chance that any operand keeps a record,
+//     taking the operands as independent
+//   - For NOT: the inverse of the fraction of records its operand rejects
+//   - For IN a subquery or a list: the distinct values of the field over the
+//     number of the subquery's records or of the list's values, taking each
+//     to match a value of the field
+//   - For BETWEEN integer constants: as for IN the list of the integers between
+//     them; for other bounds: RANGE_REDUCTION_FACTOR
+//   - For LIKE: as for an equation if the pattern has no wildcards, and
+//     RANGE_REDUCTION_FACTOR otherwise
+//   - For EXISTS: 1, as it holds for every record or none
 //   - For equal constants: 1 (maximum reduction)
 //   - For non-equal constants: math.MaxInt (no reduction)
 func (t *Term) ReductionFactor(p interfaces.Plan) int {
 	var lhsName string
 	var rhsName string
 
+	// Few values are null, so IS NOT NULL keeps about every record,
+	// and IS NULL is estimated like an equation with a constant
+	if t.op == OP_IS_NOT_NULL {
+		return 1
+	}
+
+	if t.op == OP_OR {
+		rejected := 1.0
+		for _, pred := range t.preds {
+			rejected *= 1 - keptBy(pred.ReductionFactor(p))
+		}
+		return reductionFactorOf(1 - rejected)
+	}
+	if t.op == OP_NOT {
+		return reductionFactorOf(1 - keptBy(t.preds[0].ReductionFactor(p)))
+	}
+	if t.op == OP_EXISTS {
+		return 1
+	}
+	if t.op == OP_IN || t.op == OP_BETWEEN {
+		if !t.lhs.IsFieldName() {
+			return 1
+		}
+		values := len(t.values)
+		if t.subquery != nil {
+			values = t.subquery.recordsOutput()
+		} else if t.op == OP_BETWEEN {
+			if values = t.betweenWidth(); values < 0 {
+				return RANGE_REDUCTION_FACTOR
+			}
+		}
+		distinct := max(1, p.DistinctValues(t.lhs.AsFieldName()))
+		return max(1, distinct/min(distinct, max(1, values)))
+	}
+	if t.op == OP_LIKE {
+		if c := t.rhs.AsConstant(); t.lhs.IsFieldName() && c != nil && c.AsString() != nil && !strings.ContainsAny(*c.AsString(), "%_") {
+			return p.DistinctValues(t.lhs.AsFieldName())
+		}
+		return RANGE_REDUCTION_FACTOR
+	}
+
+	// Comparisons other than equality are satisfied by many values of a
+	// field. Between constants they are true or false for every record.
+	if t.op != OP_EQUALS && t.op != OP_IS_NULL {
+		if !t.lhs.IsFieldName() && !t.rhs.IsFieldName() {
+			lhsVal, rhsVal := t.lhs.AsConstant(), t.rhs.AsConstant()
+			if !lhsVal.IsNull() && !rhsVal.IsNull() && compare(lhsVal.CompareTo(rhsVal), t.op) {
+				return 1
+			}
+			return math.MaxInt
+		}
+		if t.op == OP_NOT_EQUALS {
+			return 1
+		}
+		return RANGE_REDUCTION_FACTOR
+	}
+
 	// CASE 1: Both sides of the term are field names
 	if t.lhs.IsFieldName() && t.rhs.IsFieldName() {
 		lhsName = t.lhs.AsFieldName()
@@ -84,7 +457,7 @@ func (t *Term) ReductionFactor(p interfaces.Plan) int {
 	}
 
 	// CASE 4: Both sides are constants and they are equal
-	if t.lhs.AsConstant().Equals(t.rhs.AsConstant()) {
+	if t.lhs.AsConstant().Equals(t.rhs.AsConstant()) && (t.op == OP_IS_NULL || !t.lhs.AsConstant().IsNull()) {
 		// Equal constants evaluate to a single result(maximum reduction)
 		return 1
 	}
@@ -94,36 +467,242 @@ func (t *Term) ReductionFactor(p interfaces.Plan) int {
 	return math.MaxInt
 }
 
+// Estimates the cost of evaluating the term on a record output by the plan,
+// in comparisons of numbers: one for the comparison itself, plus the cost
+// of reading each side. Reading a subquery costs one for each of its records.
+func (t *Term) Cost(p interfaces.Plan) int {
+	if t.IsCompound() {
+		cost := 1
+		for _, pred := range t.preds {
+			for _, term := range pred.terms {
+				cost += term.Cost(p)
+			}
+		}
+		return cost
+	}
+	switch t.op {
+	case OP_EXISTS:
+		return t.subquery.recordsOutput()
+	case OP_IN, OP_BETWEEN:
+		if t.subquery != nil {
+			return t.lhs.Cost(p.Schema()) + t.subquery.recordsOutput()
+		}
+		cost := t.lhs.Cost(p.Schema())
+		for _, e := range t.values {
+			cost += 1 + e.Cost(p.Schema())
+		}
+		return cost
+	}
+	return 1 + t.lhs.Cost(p.Schema()) + t.rhs.Cost(p.Schema())
+}
+
+// Ranks the term for evaluation order: its cost over the fraction of
+// records it rejects. A term rejecting none ranks last.
+func (t *Term) rank(p interfaces.Plan) float64 {
+	rf := t.ReductionFactor(p)
+	if rf <= 1 {
+		return math.Inf(1)
+	}
+	rejected := 1 - 1/float64(rf)
+	return float64(t.Cost(p)) / rejected
+}
+
 // Checks if the Term represents an equation between the specified field
 // and a constant value (e.g., fieldName = constant). It returns the Constant if such an
-// equation exists, or nil otherwise.
+// equation exists, or nil otherwise. An equation with NULL holds for no
+// record, so it isn't one.
 func (t *Term) EquatesWithConstant(fldName string) *types.Constant {
-	if t.lhs.IsFieldName() && t.lhs.AsFieldName() == fldName && !t.rhs.IsFieldName() {
-		return t.rhs.AsConstant()
+	var val *types.Constant
+	if t.op != OP_EQUALS {
+		return nil
+	} else if t.lhs.IsFieldName() && t.lhs.AsFieldName() == fldName && !t.rhs.IsFieldName() {
+		val = t.rhs.AsConstant()
 	} else if t.rhs.IsFieldName() && t.rhs.AsFieldName() == fldName && !t.lhs.IsFieldName() {
-		return t.lhs.AsConstant()
-	} else {
+		val = t.lhs.AsConstant()
+	}
+
+	if val.IsNull() {
 		return nil
 	}
+	return val
+}
+
+// Checks if the Term restricts the specified field to a list of constants:
+// an IN over a list of constants, or a BETWEEN of integer constants holding
+// for at most MAX_LIST_KEYS integers. Returns the constants in order and
+// without duplicates or nulls, which match no record, or nil if the term
+// is of neither form.
+func (t *Term) MatchesConstants(fldName string) []*types.Constant {
+	if (t.op != OP_IN && t.op != OP_BETWEEN) || t.subquery != nil ||
+		!t.lhs.IsFieldName() || t.lhs.AsFieldName() != fldName {
+		return nil
+	}
+
+	var vals []*types.Constant
+	if t.op == OP_BETWEEN {
+		width := t.betweenWidth()
+		if width < 0 || width > MAX_LIST_KEYS {
+			return nil
+		}
+		low := *t.values[0].AsConstant().AsInt()
+		for i := 0; i < width; i++ {
+			vals = append(vals, types.NewConstantInt(low+i))
+		}
+		return vals
+	}
+
+	for _, e := range t.values {
+		val := e.AsConstant()
+		if e.IsFieldName() || val == nil {
+			return nil
+		}
+		if !val.IsNull() {
+			vals = append(vals, val)
+		}
+	}
+	slices.SortFunc(vals, func(a, b *types.Constant) int { return a.CompareTo(b) })
+	return slices.CompactFunc(vals, func(a, b *types.Constant) bool { return a.CompareTo(b) == 0 })
+}
+
+// A comparison of a field with a constant, see Term.bounds
+type bound struct {
+	op  string // OP_LESS, OP_LESS_EQUAL, OP_GREATER or OP_GREATER_EQUAL
+	val *types.Constant
+}
+
+// Returns the bounds the term puts on the specified field, as comparisons
+// of the field with constants: one for a range comparison with a constant,
+// two for a BETWEEN of constants, and none for other terms. Comparisons
+// with null hold for no record, so they aren't bounds.
+func (t *Term) bounds(fldName string) []bound {
+	// The comparison with the sides swapped
+	mirrored := map[string]string{
+		OP_LESS:          OP_GREATER,
+		OP_LESS_EQUAL:    OP_GREATER_EQUAL,
+		OP_GREATER:       OP_LESS,
+		OP_GREATER_EQUAL: OP_LESS_EQUAL,
+	}
+
+	var bounds []bound
+	switch {
+	case t.op == OP_BETWEEN && t.subquery == nil && t.lhs.IsFieldName() && t.lhs.AsFieldName() == fldName:
+		for i, op := range []string{OP_GREATER_EQUAL, OP_LESS_EQUAL} {
+			if !t.values[i].IsFieldName() {
+				bounds = append(bounds, bound{op, t.values[i].AsConstant()})
+			}
+		}
+	case mirrored[t.op] == "":
+	case t.lhs.IsFieldName() && t.lhs.AsFieldName() == fldName && !t.rhs.IsFieldName():
+		bounds = append(bounds, bound{t.op, t.rhs.AsConstant()})
+	case t.rhs.IsFieldName() && t.rhs.AsFieldName() == fldName && !t.lhs.IsFieldName():
+		bounds = append(bounds, bound{mirrored[t.op], t.lhs.AsConstant()})
+	}
+
+	return slices.DeleteFunc(bounds, func(b bound) bool { return b.val == nil || b.val.IsNull() })
 }
 
 func (t *Term) EquatesWithField(fldName string) string {
+	if t.op != OP_EQUALS {
+		return ""
+	}
 	if t.lhs.IsFieldName() && t.lhs.AsFieldName() == fldName && t.rhs.IsFieldName() {
 		return t.rhs.AsFieldName()
-	} else if t.rhs.IsFieldName() && t.rhs.AsFieldName() == fldName && !t.lhs.IsFieldName() {
+	} else if t.rhs.IsFieldName() && t.rhs.AsFieldName() == fldName && t.lhs.IsFieldName() {
 		return t.lhs.AsFieldName()
 	} else {
 		return ""
 	}
 }
 
+// OR terms are parenthesized, so that they read the same within a conjunction.
 func (t *Term) String() string {
-	return t.lhs.String() + "=" + t.rhs.String()
+	switch t.op {
+	case OP_OR:
+		disjuncts := make([]string, len(t.preds))
+		for i, pred := range t.preds {
+			disjuncts[i] = pred.String()
+		}
+		return "(" + strings.Join(disjuncts, " OR ") + ")"
+	case OP_NOT:
+		return "NOT (" + t.preds[0].String() + ")"
+	case OP_IN:
+		if t.subquery == nil {
+			values := make([]string, len(t.values))
+			for i, e := range t.values {
+				values[i] = e.String()
+			}
+			return t.lhs.String() + " IN (" + strings.Join(values, ", ") + ")"
+		}
+		return t.lhs.String() + " IN " + t.subquery.String()
+	case OP_BETWEEN:
+		return t.lhs.String() + " BETWEEN " + t.values[0].String() + " AND " + t.values[1].String()
+	case OP_LIKE:
+		return t.lhs.String() + " LIKE " + t.rhs.String()
+	case OP_EXISTS:
+		return "EXISTS " + t.subquery.String()
+	}
+	if t.op == OP_IS_NULL || t.op == OP_IS_NOT_NULL {
+		return t.lhs.String() + " " + t.op
+	}
+	return t.lhs.String() + t.op + t.rhs.String()
 }
 
+// Returns the comparison the term makes, one of the OP_ constants
+func (t *Term) Op() string {
+	return t.op
+}
+
+// Returns the predicates an OP_OR or OP_NOT term combines
+func (t *Term) Operands() []*Predicate {
+	return t.preds
+}
+
+// Returns the subquery of an OP_IN or OP_EXISTS term, nil for other
+// terms and for an OP_IN term over a list
+func (t *Term) Subquery() *Subquery {
+	return t.subquery
+}
+
+// Returns the values of an OP_IN term over a list, or the low and high
+// bounds of an OP_BETWEEN term; nil for other terms
+func (t *Term) Values() []*Expression {
+	return t.values
+}
+
+// Returns the number of integers an OP_BETWEEN term over integer
+// constants holds for, or -1 if its bounds aren't integer constants
+func (t *Term) betweenWidth() int {
+	low, high := t.values[0].AsConstant(), t.values[1].AsConstant()
+	if low == nil || high == nil || low.AsInt() == nil || high.AsInt() == nil {
+		return -1
+	}
+	return max(0, *high.AsInt()-*low.AsInt()+1)
+}
+
+// Returns the subqueries the term reads, including those nested in its
+// operands and expressions
+func (t *Term) subqueries() []*Subquery {
+	var subqueries []*Subquery
+	if t.subquery != nil {
+		subqueries = append(subqueries, t.subquery)
+	}
+	for _, pred := range t.preds {
+		subqueries = append(subqueries, pred.Subqueries()...)
+	}
+	for _, e := range t.values {
+		subqueries = append(subqueries, e.subqueries()...)
+	}
+	return append(append(subqueries, t.lhs.subqueries()...), t.rhs.subqueries()...)
+}
+
+// Returns the left-hand side of a comparison, OP_IN, OP_BETWEEN or
+// OP_LIKE term, nil for an OP_OR, OP_NOT or OP_EXISTS term
 func (t *Term) LHS() *Expression {
 	return t.lhs
 }
+
+// Returns the right-hand side of a comparison or the pattern of an OP_LIKE
+// term, nil for an OP_OR, OP_NOT, OP_IN, OP_BETWEEN or OP_EXISTS term
 func (t *Term) RHS() *Expression {
 	return t.rhs
 }
@@ -0,0 +1,87 @@
+package query
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"slices"
+)
+
+// Implements the scan interface for UNION ALL. It outputs the records of
+// the first scan and then those of the second. The fields are named as in
+// the first scan, and read from the field of the second at the same position.
+type UnionScan struct {
+	s1, s2  interfaces.Scan
+	schema  *schema.Schema
+	fields2 []string // The fields of s2, in the order of those of the schema
+	second  bool     // Whether the records are read from s2
+}
+
+func NewUnionScan(s1, s2 interfaces.Scan, sch *schema.Schema, fields2 []string) *UnionScan {
+	return &UnionScan{
+		s1:      s1,
+		s2:      s2,
+		schema:  sch,
+		fields2: fields2,
+	}
+}
+
+// Positions the scan before the first record of the first scan
+func (us *UnionScan) BeforeFirst() {
+	us.s1.BeforeFirst()
+	us.s2.BeforeFirst()
+	us.second = false
+}
+
+// Advances to the next record, moving on to the second scan once the first is done
+func (us *UnionScan) Next() bool {
+	if !us.second {
+		if us.s1.Next() {
+			return true
+		}
+		us.second = true
+	}
+	return us.s2.Next()
+}
+
+func (us *UnionScan) GetInt(fieldName string) int {
+	if !us.second {
+		return us.s1.GetInt(fieldName)
+	}
+	return us.s2.GetInt(us.field2(fieldName))
+}
+
+func (us *UnionScan) GetString(fieldName string) string {
+	if !us.second {
+		return us.s1.GetString(fieldName)
+	}
+	return us.s2.GetString(us.field2(fieldName))
+}
+
+func (us *UnionScan) GetVal(fieldName string) *types.Constant {
+	if !us.second {
+		return us.s1.GetVal(fieldName)
+	}
+	return us.s2.GetVal(us.field2(fieldName))
+}
+
+// Returns the field of the second scan at the position of the named field
+func (us *UnionScan) field2(fieldName string) string {
+	if i := slices.Index(us.schema.Fields(), fieldName); i >= 0 {
+		return us.fields2[i]
+	}
+	return fieldName
+}
+
+func (us *UnionScan) HasField(fieldName string) bool {
+	return us.schema.HasField(fieldName)
+}
+
+func (us *UnionScan) Schema() *schema.Schema {
+	return us.schema
+}
+
+func (us *UnionScan) Close() {
+	us.s1.Close()
+	us.s2.Close()
+}
@@ -0,0 +1,57 @@
+package record
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// In a table created WITH (compression=on), each string that isn't empty is
+// stored after a byte telling how: as it is, or compressed with DEFLATE when
+// that makes it shorter. Compression needs the variable format, in which
+// strings take the length they are stored with.
+const (
+	STRING_RAW      = 0
+	STRING_DEFLATED = 1
+)
+
+var ErrCorruptString = errors.New("stored string is corrupt")
+
+// Returns the form a string of a record is stored in
+func (l *Layout) encodeString(val string) string {
+	if !l.options.Compression || val == "" {
+		return val
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(STRING_DEFLATED)
+	w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	w.Write([]byte(val))
+	w.Close()
+	if buf.Len() < len(val)+1 {
+		return buf.String()
+	}
+	return string([]byte{STRING_RAW}) + val
+}
+
+// Returns the string of a record from the form it is stored in.
+// Panics with ErrCorruptString if it can't be decompressed.
+func (l *Layout) decodeString(stored string) string {
+	if !l.options.Compression || stored == "" {
+		return stored
+	}
+
+	switch stored[0] {
+	case STRING_RAW:
+		return stored[1:]
+	case STRING_DEFLATED:
+		b, err := io.ReadAll(flate.NewReader(bytes.NewReader([]byte(stored[1:]))))
+		if err != nil {
+			panic(fmt.Errorf("%w: %v", ErrCorruptString, err))
+		}
+		return string(b)
+	}
+	panic(fmt.Errorf("%w: unknown encoding %d", ErrCorruptString, stored[0]))
+}
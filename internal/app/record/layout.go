@@ -2,15 +2,31 @@ package record
 
 import (
 	"centauri/internal/app/file"
+	"centauri/internal/app/interfaces"
 	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
 	"unsafe"
 )
 
+// Computes the value of a generated field from the other fields of a record
+type Generator interface {
+	// Returns the value of the field for the record the scan is on
+	Evaluate(s interfaces.Scan) *types.Constant
+	// Returns the names of the fields the value is computed from
+	Fields() []string
+}
+
 // Represents the physical layout of records according to a schema.
+// Virtual fields are in the schema but take no space in the records;
+// their values are computed by their generators instead.
 type Layout struct {
-	schema   *schema.Schema
-	offsets  map[string]int
-	slotSize int
+	schema     *schema.Schema
+	offsets    map[string]int
+	slotSize   int
+	options    StorageOptions
+	generators map[string]Generator
+	defaults   map[string]*types.Constant // value of each field with a default when an insert omits it
+	nullBits   map[string]int             // bit of each field in the null bitmap of a record
 }
 
 // Creates a layout object from the schema.
@@ -27,20 +43,55 @@ func NewLayout(schema *schema.Schema) *Layout {
 		pos += lengthInBytes(schema, fieldName)
 	}
 
+	options := DefaultStorageOptions()
+	options.Format = FORMAT_FIXED
 	return &Layout{
 		schema:   schema,
 		offsets:  offsets,
+		nullBits: nullBits(schema, offsets),
 		slotSize: pos,
+		options:  options,
+	}
+}
+
+// Creates a layout in the variable format, which stores the strings of a
+// record in the free space at the end of its block rather than in the slot.
+// A string field only takes a pointer to its value in the slot, so strings
+// use as many bytes as they hold rather than their declared length.
+func NewVariableLayout(schema *schema.Schema) *Layout {
+	offsets := make(map[string]int)
+
+	// Leave Space for the empty/in-use flag
+	pos := int(unsafe.Sizeof(int(0)))
+
+	for _, fieldName := range schema.Fields() {
+		offsets[fieldName] = pos
+		pos += variableLengthInBytes(schema, fieldName)
+	}
+
+	options := DefaultStorageOptions()
+	options.Format = FORMAT_VARIABLE
+	return &Layout{
+		schema:   schema,
+		offsets:  offsets,
+		nullBits: nullBits(schema, offsets),
+		slotSize: pos,
+		options:  options,
 	}
 }
 
 // Creates a layout object from the specified metadata.
 // This function is used when the metadata is retrieved from the catalog.
+// The layout is in the fixed format until SetOptions says otherwise.
 func NewLayoutWithOffsets(schema *schema.Schema, offsets map[string]int, slotSize int) *Layout {
+	options := DefaultStorageOptions()
+	options.Format = FORMAT_FIXED
 	return &Layout{
 		schema:   schema,
 		offsets:  offsets,
+		nullBits: nullBits(schema, offsets),
 		slotSize: slotSize,
+		options:  options,
 	}
 }
 
@@ -58,18 +109,138 @@ func (l *Layout) Offset(fieldname string) int {
 	return offset
 }
 
+// Sets the generator computing the value of a generated field
+func (l *Layout) SetGenerator(fieldname string, g Generator) {
+	if l.generators == nil {
+		l.generators = make(map[string]Generator)
+	}
+	l.generators[fieldname] = g
+}
+
+// Returns the generator of a generated field, or nil for other fields
+func (l *Layout) Generator(fieldname string) Generator {
+	return l.generators[fieldname]
+}
+
+// Sets the value the field takes when an insert omits it
+func (l *Layout) SetDefault(fieldname string, val *types.Constant) {
+	if l.defaults == nil {
+		l.defaults = make(map[string]*types.Constant)
+	}
+	l.defaults[fieldname] = val
+}
+
+// Returns the value the field takes when an insert omits it,
+// or nil if it has no default
+func (l *Layout) Default(fieldname string) *types.Constant {
+	return l.defaults[fieldname]
+}
+
+// Returns the generator of a field that is computed whenever it's read
+// rather than stored, or nil if the field is stored
+func (l *Layout) Computed(fieldname string) Generator {
+	if _, stored := l.offsets[fieldname]; stored {
+		return nil
+	}
+	return l.generators[fieldname]
+}
+
+// Returns the fields stored in the records, in schema order
+func (l *Layout) storedFields() []string {
+	fields := make([]string, 0, len(l.offsets))
+	for _, fieldName := range l.schema.Fields() {
+		if _, ok := l.offsets[fieldName]; ok {
+			fields = append(fields, fieldName)
+		}
+	}
+	return fields
+}
+
+// Returns the bit of the field in the null bitmap of a record, or -1 if
+// the field can't be null
+func (l *Layout) NullBit(fieldname string) int {
+	bit, ok := l.nullBits[fieldname]
+	if !ok {
+		return -1
+	}
+	return bit
+}
+
+// Numbers the first MAX_NULLABLE_FIELDS stored fields in schema order,
+// giving the bits of the null bitmap
+func nullBits(sch *schema.Schema, offsets map[string]int) map[string]int {
+	bits := make(map[string]int)
+	for _, fieldName := range sch.Fields() {
+		if _, ok := offsets[fieldName]; ok && len(bits) < MAX_NULLABLE_FIELDS {
+			bits[fieldName] = len(bits)
+		}
+	}
+	return bits
+}
+
 // Returns the size of a slot
 func (l *Layout) SlotSize() int {
 	return l.slotSize
 }
 
+// Returns the storage options of the table
+func (l *Layout) Options() StorageOptions {
+	return l.options
+}
+
+// Sets the storage options of the table
+func (l *Layout) SetOptions(options StorageOptions) {
+	l.options = options
+}
+
+// Reports whether strings are stored outside of the slots
+func (l *Layout) Variable() bool {
+	return l.options.Format == FORMAT_VARIABLE
+}
+
+// Returns the most bytes the strings of a record take in the variable
+// format, i.e. when every string is as long as its declared length
+func (l *Layout) maxStringBytes() int {
+	size := 0
+	for _, fieldName := range l.storedFields() {
+		if l.schema.DataType(fieldName) == schema.VARCHAR {
+			size += file.MaxLength(l.schema.Length(fieldName))
+			if l.options.Compression {
+				size++ // the encoding byte
+			}
+		}
+	}
+	return size
+}
+
+// Returns the most bytes a record takes in a block
+func (l *Layout) recordSize() int {
+	if l.Variable() {
+		return VARIABLE_HEADER_SIZE + l.slotSize + l.maxStringBytes()
+	}
+	return l.slotSize
+}
+
 // Returns the number of bytes required to store the specified field
 func lengthInBytes(sch *schema.Schema, fieldname string) int {
 	fieldType := sch.DataType(fieldname)
 
-	if fieldType == schema.INTEGER {
+	if fieldType == schema.INTEGER || fieldType == schema.BOOLEAN {
 		return int(unsafe.Sizeof(int(0)))
+	} else if fieldType == schema.BIGINT || fieldType == schema.FLOAT || fieldType.IsTemporal() {
+		return int(unsafe.Sizeof(int64(0)))
+	} else if fieldType == schema.BLOB {
+		return BLOB_REF_SIZE
 	} else {
 		return file.MaxLength(sch.Length(fieldname))
 	}
 }
+
+// Returns the number of bytes the specified field takes in a slot of the
+// variable format, where strings are replaced by a pointer
+func variableLengthInBytes(sch *schema.Schema, fieldname string) int {
+	if sch.DataType(fieldname) != schema.VARCHAR {
+		return lengthInBytes(sch, fieldname)
+	}
+	return POINTER_SIZE
+}
@@ -0,0 +1,251 @@
+package record
+
+import (
+	"centauri/internal/app/file"
+	"centauri/internal/app/tx"
+	"io"
+	"io/fs"
+)
+
+// The bytes of a blob are stored outside the records, in a chain of
+// overflow blocks in a file of the table's own, see OverflowFile. The field
+// of a blob holds the number of the first block of the chain and the length
+// of the blob; an empty blob has no chain. Each block of a chain starts with
+// the number of the next block, 0 for the last, followed by a chunk of the
+// blob's bytes.
+//
+//	+------------+--------------+-------------+
+//	| next block | chunk length | chunk bytes |
+//	+------------+--------------+-------------+
+//
+// Block 0 of the file is a header holding the first block of the free list,
+// the chains of blobs that were deleted or replaced. New blobs take their
+// blocks from the free list before the file grows.
+const (
+	BLOB_REF_SIZE         = 8 // size of a blob field in a slot: first block and length
+	OVERFLOW_NEXT_OFFSET  = 0
+	OVERFLOW_CHUNK_OFFSET = 4
+	FREE_LIST_OFFSET      = 0 // in the header block, 0 while the free list is empty
+)
+
+// Returns the name of the file holding the overflow blocks of a table.
+// They have the system's block size, whatever that of the table's blocks.
+func OverflowFile(tableName string) string {
+	return tableName + ".ovf"
+}
+
+// Manages the chains of overflow blocks of a table
+type overflow struct {
+	tx       *tx.Transaction
+	filename string
+	okToLog  bool
+}
+
+// Creates the manager of the overflow blocks of the table stored in tableFile
+func newOverflow(tx *tx.Transaction, tableFile string, okToLog bool) *overflow {
+	return &overflow{
+		tx:       tx,
+		filename: OverflowFile(file.BaseName(tableFile)),
+		okToLog:  okToLog,
+	}
+}
+
+// Returns the number of bytes of a blob an overflow block holds.
+// A chunk is written with a single log record, which must fit in a log
+// block along with the empty old value.
+func (o *overflow) chunkSize() int {
+	return min(o.tx.FileBlockSize(o.filename)-file.MaxLength(OVERFLOW_CHUNK_OFFSET), o.tx.MaxLoggedString(o.filename, 0))
+}
+
+// Returns a block for a new chunk, taken from the free list or appended to
+// the file. The header block is appended first if the file is empty.
+func (o *overflow) allocate() int {
+	if size, _ := o.tx.Size(o.filename); size == 0 {
+		o.tx.Append(o.filename)
+	}
+
+	header := file.NewBlockID(o.filename, 0)
+	o.tx.Pin(header)
+	defer o.tx.Unpin(header)
+
+	free := o.getInt(header, FREE_LIST_OFFSET)
+	if free == 0 {
+		block, _ := o.tx.Append(o.filename)
+		return block.Number()
+	}
+
+	block := file.NewBlockID(o.filename, free)
+	o.tx.Pin(block)
+	next := o.getInt(block, OVERFLOW_NEXT_OFFSET)
+	o.tx.Unpin(block)
+	o.tx.SetInt(*header, FREE_LIST_OFFSET, next, o.okToLog)
+	return free
+}
+
+// Writes a chunk to a block allocated for it, as the last of its chain
+func (o *overflow) writeChunk(blockNum int, chunk []byte) {
+	block := file.NewBlockID(o.filename, blockNum)
+	o.tx.Pin(block)
+	defer o.tx.Unpin(block)
+
+	// The block may hold a chunk of a freed blob, so the length is cleared
+	// first for the old value to be logged as empty
+	o.tx.SetInt(*block, OVERFLOW_NEXT_OFFSET, 0, o.okToLog)
+	o.tx.SetInt(*block, OVERFLOW_CHUNK_OFFSET, 0, o.okToLog)
+	o.tx.SetString(*block, OVERFLOW_CHUNK_OFFSET, string(chunk), o.okToLog)
+}
+
+// Makes the block the next of a chain's block
+func (o *overflow) link(blockNum int, next int) {
+	block := file.NewBlockID(o.filename, blockNum)
+	o.tx.Pin(block)
+	defer o.tx.Unpin(block)
+	o.tx.SetInt(*block, OVERFLOW_NEXT_OFFSET, next, o.okToLog)
+}
+
+// Returns the chunk of a block and the number of the next block of its chain
+func (o *overflow) readChunk(blockNum int) ([]byte, int) {
+	block := file.NewBlockID(o.filename, blockNum)
+	o.tx.Pin(block)
+	defer o.tx.Unpin(block)
+	chunk, _ := o.tx.GetString(*block, OVERFLOW_CHUNK_OFFSET)
+	return []byte(chunk), o.getInt(block, OVERFLOW_NEXT_OFFSET)
+}
+
+// Adds the chain starting at first to the free list
+func (o *overflow) free(first int) {
+	if first == 0 {
+		return
+	}
+
+	last := first
+	for {
+		block := file.NewBlockID(o.filename, last)
+		o.tx.Pin(block)
+		next := o.getInt(block, OVERFLOW_NEXT_OFFSET)
+		o.tx.Unpin(block)
+		if next == 0 {
+			break
+		}
+		last = next
+	}
+
+	header := file.NewBlockID(o.filename, 0)
+	o.tx.Pin(header)
+	defer o.tx.Unpin(header)
+	o.link(last, o.getInt(header, FREE_LIST_OFFSET))
+	o.tx.SetInt(*header, FREE_LIST_OFFSET, first, o.okToLog)
+}
+
+// Writes a string to a new chain, returning its first block
+func (o *overflow) writeString(val string) int {
+	first, last := 0, 0
+	for b := []byte(val); len(b) > 0; {
+		n := min(len(b), o.chunkSize())
+		block := o.allocate()
+		o.writeChunk(block, b[:n])
+		if first == 0 {
+			first = block
+		} else {
+			o.link(last, block)
+		}
+		last = block
+		b = b[n:]
+	}
+	return first
+}
+
+// Reads the string stored in the chain starting at first
+func (o *overflow) readString(first int) string {
+	var b []byte
+	for next := first; next != 0; {
+		var chunk []byte
+		chunk, next = o.readChunk(next)
+		b = append(b, chunk...)
+	}
+	return string(b)
+}
+
+func (o *overflow) getInt(block *file.BlockID, offset int) int {
+	value, _ := o.tx.GetInt(*block, offset)
+	return int(value)
+}
+
+// Reads the bytes of a blob from its chain, one block at a time
+type blobReader struct {
+	o     *overflow
+	next  int    // the next block of the chain, 0 past the last
+	left  int    // the bytes of the blob not read yet
+	chunk []byte // the bytes of the current block not read yet
+}
+
+func (r *blobReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.next == 0 || r.left == 0 {
+			return 0, io.EOF
+		}
+		r.chunk, r.next = r.o.readChunk(r.next)
+	}
+
+	n := copy(p, r.chunk[:min(len(r.chunk), r.left)])
+	r.chunk = r.chunk[n:]
+	r.left -= n
+	return n, nil
+}
+
+// Writes the bytes of a blob to a new chain, a block at a time, and
+// replaces the blob of a field of a record with it when closed.
+// The blob the field held before is freed.
+type blobWriter struct {
+	o         *overflow
+	rp        *RecordPage
+	slot      int
+	fieldname string
+	first     int    // the first block of the chain, 0 while it is empty
+	last      int    // the last block of the chain
+	length    int    // the number of bytes written
+	buf       []byte // the bytes not written to a block yet
+	closed    bool
+}
+
+func (w *blobWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fs.ErrClosed
+	}
+	w.buf = append(w.buf, p...)
+	w.length += len(p)
+	for len(w.buf) >= w.o.chunkSize() {
+		w.flush(w.o.chunkSize())
+	}
+	return len(p), nil
+}
+
+// Writes the first n bytes of the buffer to a new block at the end of the chain
+func (w *blobWriter) flush(n int) {
+	block := w.o.allocate()
+	w.o.writeChunk(block, w.buf[:n])
+	if w.first == 0 {
+		w.first = block
+	} else {
+		w.o.link(w.last, block)
+	}
+	w.last = block
+	w.buf = w.buf[n:]
+}
+
+// Writes the rest of the blob and stores it in the field
+func (w *blobWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if len(w.buf) > 0 {
+		w.flush(len(w.buf))
+	}
+
+	old, _ := w.rp.blobRef(w.slot, w.fieldname)
+	w.rp.setBlobRef(w.slot, w.fieldname, w.first, w.length)
+	w.o.free(old)
+	w.o.tx.Unpin(w.rp.Block())
+	return nil
+}
@@ -0,0 +1,122 @@
+package record
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"fmt"
+)
+
+// Scans the records of some partitions of a table, one partition after
+// another. Records can be changed and deleted through the scan, but not
+// inserted, since a new record must go in the partition its key routes to.
+// The RIDs of the records identify them within their partition only.
+type PartitionScan struct {
+	interfaces.UpdateScan
+	tx        *tx.Transaction
+	tableName string
+	layout    *Layout
+	parts     []int // indexes of the partitions scanned, in order
+	current   int   // position in parts of the partition being scanned
+	ts        *TableScan
+}
+
+// Creates a scan over the partitions of the table with the specified indexes
+func NewPartitionScan(tx *tx.Transaction, tableName string, layout *Layout, parts []int) *PartitionScan {
+	ps := &PartitionScan{
+		tx:        tx,
+		tableName: tableName,
+		layout:    layout,
+		parts:     parts,
+	}
+	ps.BeforeFirst()
+	return ps
+}
+
+func (ps *PartitionScan) BeforeFirst() {
+	ps.open(0)
+}
+
+// Opens the scan of the partition at position i of parts, or none past the last
+func (ps *PartitionScan) open(i int) {
+	if ps.ts != nil {
+		ps.ts.Close()
+		ps.ts = nil
+	}
+	ps.current = i
+	if i < len(ps.parts) {
+		part := ps.layout.Options().Partitioning.Partitions[ps.parts[i]]
+		ps.ts = NewTableScan(ps.tx, PartitionTable(ps.tableName, part.Name), ps.layout)
+	}
+}
+
+func (ps *PartitionScan) Next() bool {
+	for ps.ts != nil {
+		if ps.ts.Next() {
+			return true
+		}
+		ps.open(ps.current + 1)
+	}
+	return false
+}
+
+func (ps *PartitionScan) GetInt(fieldname string) int {
+	return ps.ts.GetInt(fieldname)
+}
+
+func (ps *PartitionScan) GetString(fieldname string) string {
+	return ps.ts.GetString(fieldname)
+}
+
+func (ps *PartitionScan) GetVal(fieldname string) *types.Constant {
+	return ps.ts.GetVal(fieldname)
+}
+
+func (ps *PartitionScan) HasField(fieldname string) bool {
+	return ps.layout.Schema().HasField(fieldname)
+}
+
+func (ps *PartitionScan) Schema() *schema.Schema {
+	return ps.layout.Schema()
+}
+
+func (ps *PartitionScan) Close() {
+	if ps.ts != nil {
+		ps.ts.Close()
+		ps.ts = nil
+	}
+}
+
+func (ps *PartitionScan) SetVal(fieldname string, val *types.Constant) error {
+	return ps.ts.SetVal(fieldname, val)
+}
+
+func (ps *PartitionScan) SetInt(fieldname string, val int) error {
+	return ps.ts.SetInt(fieldname, val)
+}
+
+func (ps *PartitionScan) SetString(fieldname string, val string) error {
+	return ps.ts.SetString(fieldname, val)
+}
+
+func (ps *PartitionScan) Insert() error {
+	return fmt.Errorf("%w: records of %s are inserted into the partition their key routes to", ErrPartitioned, ps.tableName)
+}
+
+func (ps *PartitionScan) Delete() error {
+	return ps.ts.Delete()
+}
+
+// Returns the RID of the current record within its partition
+func (ps *PartitionScan) GetRID() (*types.RID, error) {
+	return ps.ts.GetRID()
+}
+
+// Moves to the record with the RID in the partition being scanned
+func (ps *PartitionScan) MoveToRID(rid *types.RID) error {
+	if ps.ts == nil {
+		return fmt.Errorf("%w: no partition of %s is being scanned", ErrPartitioned, ps.tableName)
+	}
+	return ps.ts.MoveToRID(rid)
+}
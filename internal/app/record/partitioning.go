@@ -0,0 +1,183 @@
+package record
+
+import (
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Methods of spreading the records of a table over its partitions
+const (
+	PARTITION_RANGE = 0 // by ranges of an integer field
+	PARTITION_HASH  = 1 // by the hash of a field
+)
+
+// Maximum number of partitions of a table
+const MAX_PARTITIONS = 64
+
+// Separates the name of a table from the name of a partition in the
+// name its records are stored under, e.g. orders#p0 in orders#p0.tbl.
+// It can't appear in an identifier, so the names don't clash with tables.
+const PARTITION_SEPARATOR = "#"
+
+var (
+	// Returned when a record's partition key falls outside every partition
+	ErrNoPartition = errors.New("no partition for value")
+	// Returned for operations that partitioned tables don't support
+	ErrPartitioned = errors.New("table is partitioned")
+)
+
+// A partition of a table
+type Partition struct {
+	Name     string
+	Bound    int  // values of a range partition are less than the bound
+	MaxValue bool // the last range partition holds every value from the previous bound
+}
+
+// Describes how the records of a table are spread over partitions.
+// The records of each partition are stored in a file of their own.
+// Range partitions are listed in bound order; a record goes in the first
+// whose bound is greater than its key. Hash partitions are numbered from 0
+// and a record goes in the one its key hashes to.
+type Partitioning struct {
+	Method     int    // PARTITION_RANGE or PARTITION_HASH
+	Field      string // the partition key
+	Partitions []Partition
+}
+
+// Creates the hash partitioning of a field over n partitions, named p0 to pn-1
+func NewHashPartitioning(field string, n int) *Partitioning {
+	parts := make([]Partition, n)
+	for i := range parts {
+		parts[i] = Partition{Name: fmt.Sprintf("p%d", i), Bound: i}
+	}
+	return &Partitioning{Method: PARTITION_HASH, Field: field, Partitions: parts}
+}
+
+// Checks that the partitioning can be applied to a table of the schema.
+// The key must be a stored field of the table, an integer one for range
+// partitioning, whose bounds must be ascending.
+func (p *Partitioning) Validate(sch *schema.Schema) error {
+	if !sch.HasField(p.Field) {
+		return fmt.Errorf("partition key %s is not a field of the table", p.Field)
+	}
+	if sch.Generated(p.Field) != "" {
+		return fmt.Errorf("%w: partition key %s can't be a generated field", ErrGeneratedField, p.Field)
+	}
+	if len(p.Partitions) == 0 || len(p.Partitions) > MAX_PARTITIONS {
+		return fmt.Errorf("a table must have between 1 and %d partitions, got %d", MAX_PARTITIONS, len(p.Partitions))
+	}
+
+	names := make(map[string]bool)
+	for _, part := range p.Partitions {
+		if names[part.Name] {
+			return fmt.Errorf("duplicate partition %s", part.Name)
+		}
+		names[part.Name] = true
+	}
+
+	if p.Method == PARTITION_HASH {
+		return nil
+	}
+	if sch.DataType(p.Field) != schema.INTEGER {
+		return fmt.Errorf("range partition key %s must be an integer field", p.Field)
+	}
+	for i, part := range p.Partitions {
+		if part.MaxValue && i != len(p.Partitions)-1 {
+			return fmt.Errorf("partition %s holds the values up to maxvalue and must be the last", part.Name)
+		}
+		if i > 0 && !part.MaxValue && part.Bound <= p.Partitions[i-1].Bound {
+			return fmt.Errorf("the bound of partition %s must be greater than the bound of %s", part.Name, p.Partitions[i-1].Name)
+		}
+	}
+	return nil
+}
+
+// Returns the index of the partition holding the records whose key has the value.
+// Nulls sort before every value, so a null key goes in the first range partition.
+// Fails with ErrNoPartition if the value is beyond the bound of the last range partition.
+func (p *Partitioning) Route(val *types.Constant) (int, error) {
+	if p.Method == PARTITION_HASH {
+		return int(val.HashCode() % uint64(len(p.Partitions))), nil
+	}
+	if val.IsNull() {
+		return 0, nil
+	}
+
+	key := val.AsInt()
+	if key == nil {
+		return 0, fmt.Errorf("%w: %s is not an integer", ErrFieldType, val)
+	}
+	for i, part := range p.Partitions {
+		if part.MaxValue || *key < part.Bound {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s = %d", ErrNoPartition, p.Field, *key)
+}
+
+// Returns the first and last index of the range partitions that can hold
+// keys from low to high, both included, a nil bound leaving its end of the
+// range open. Returns a first index greater than the last if none can.
+func (p *Partitioning) Spanning(low *int, high *int) (int, int) {
+	first, last := 0, len(p.Partitions)-1
+	if low != nil {
+		first = len(p.Partitions)
+		for i, part := range p.Partitions {
+			if part.MaxValue || *low < part.Bound {
+				first = i
+				break
+			}
+		}
+	}
+	if high != nil {
+		for i, part := range p.Partitions {
+			if part.MaxValue || *high < part.Bound {
+				last = i
+				break
+			}
+		}
+		if low != nil && *low > *high {
+			return first, first - 1
+		}
+	}
+	return first, last
+}
+
+// Returns the name the records of a partition of the table are stored under
+func PartitionTable(tableName string, partName string) string {
+	return tableName + PARTITION_SEPARATOR + partName
+}
+
+// Returns the names the records of the table are stored under: one for
+// each partition of a partitioned table, otherwise the name of the table
+func StorageTables(tableName string, layout *Layout) []string {
+	p := layout.Options().Partitioning
+	if p == nil {
+		return []string{tableName}
+	}
+	tables := make([]string, len(p.Partitions))
+	for i, part := range p.Partitions {
+		tables[i] = PartitionTable(tableName, part.Name)
+	}
+	return tables
+}
+
+// Returns the PARTITION BY clause defining the partitioning
+func (p *Partitioning) String() string {
+	if p.Method == PARTITION_HASH {
+		return fmt.Sprintf("partition by hash (%s) partitions %d", p.Field, len(p.Partitions))
+	}
+
+	parts := make([]string, len(p.Partitions))
+	for i, part := range p.Partitions {
+		if part.MaxValue {
+			parts[i] = fmt.Sprintf("partition %s values less than maxvalue", part.Name)
+		} else {
+			parts[i] = fmt.Sprintf("partition %s values less than (%d)", part.Name, part.Bound)
+		}
+	}
+	return fmt.Sprintf("partition by range (%s) (%s)", p.Field, strings.Join(parts, ", "))
+}
@@ -4,25 +4,70 @@ import (
 	"centauri/internal/app/file"
 	sch "centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 )
 
 const EMPTY = 0 // Indicates unused/deleted record slot
 const USED = 1  // Indicates an active record slot
 
+// The flag of a slot is followed by the null bitmap of its record, whose
+// bit i is set when the i-th stored field of the schema is null. Only the
+// first MAX_NULLABLE_FIELDS stored fields of a table can be null.
+const (
+	NULL_BITMAP_OFFSET  = 4
+	MAX_NULLABLE_FIELDS = 32
+)
+
+// A block in the variable format starts with a header holding the number
+// of slots allocated so far and the start of the heap. The slots follow the
+// header, and the strings they point to are allocated from the heap, which
+// grows down from the end of the block. Both grow until they meet.
+//
+//	+------------+------------+--------+--------+-----   -----+------+------+
+//	| slot count | heap start | slot 0 | slot 1 | ... free ... | str1 | str0 |
+//	+------------+------------+--------+--------+-----   -----+------+------+
+//
+// A string that grows is written to a new place in the heap. When the free
+// space runs out, the heap is compacted to reclaim the space of old values,
+// and a string that still doesn't fit is stored in a chain of overflow
+// blocks, as blobs are, its pointer holding the negated first block.
+const (
+	SLOT_COUNT_OFFSET    = 0
+	HEAP_START_OFFSET    = 4 // 0 while the heap is empty
+	VARIABLE_HEADER_SIZE = 8
+	POINTER_SIZE         = 4 // size of a string field in a slot, 0 for an empty string
+)
+
+var (
+	ErrRecordTooLarge = errors.New("record doesn't fit in a block")
+	ErrFieldType      = errors.New("value doesn't match the field")
+	ErrNotNullable    = errors.New("field can't be null")
+	ErrGeneratedField = errors.New("generated field")
+)
+
 // Represents a page of records in the database
 // It manages the physical storage and retrieval of records within a block
 type RecordPage struct {
-	tx     *tx.Transaction
-	block  *file.BlockID
-	layout *Layout
+	tx        *tx.Transaction
+	block     *file.BlockID
+	layout    *Layout
+	blockSize int  // size of the blocks of the table, which may differ from the system's
+	okToLog   bool // whether changes to the records are written to the log
+	logValues bool // whether changes to field values are logged along with the slots
 }
 
 // Creates and initializes a new Recordpage instance
 func NewRecordPage(tx *tx.Transaction, block *file.BlockID, layout *Layout) *RecordPage {
 	rp := &RecordPage{
-		tx:     tx,
-		block:  block,
-		layout: layout,
+		tx:        tx,
+		block:     block,
+		layout:    layout,
+		blockSize: tx.FileBlockSize(block.FileName()),
+		okToLog:   true,
+		logValues: true,
 	}
 
 	tx.Pin(block)
@@ -30,6 +75,26 @@ func NewRecordPage(tx *tx.Transaction, block *file.BlockID, layout *Layout) *Rec
 	return rp
 }
 
+// Turns logging of record changes on or off. Changes made without logging
+// are still flushed when the transaction commits, but can't be undone by a
+// rollback or during recovery.
+func (rp *RecordPage) SetLogging(okToLog bool) {
+	rp.okToLog = okToLog
+}
+
+// Turns logging of field values on or off while the changes to the slots
+// and heap of the block are still logged. A rollback then frees the slots of
+// inserted records but leaves their values behind, so this is only safe for
+// records no earlier version of which needs to be restored.
+func (rp *RecordPage) SetValueLogging(logValues bool) {
+	rp.logValues = logValues
+}
+
+// Reports whether a change to a field value is written to the log
+func (rp *RecordPage) logValue() bool {
+	return rp.okToLog && rp.logValues
+}
+
 func (rp *RecordPage) Block() *file.BlockID {
 	return rp.block
 }
@@ -42,50 +107,321 @@ func (rp *RecordPage) GetInt(slot int, fieldname string) int {
 	return int(value)
 }
 
+// Returns the 64-bit integer value stored for the specified field of a specified slot,
+// such as the days of a date or the seconds of a timestamp.
+func (rp *RecordPage) GetLong(slot int, fieldname string) int64 {
+	fieldPos := rp.offset(slot) + rp.layout.Offset(fieldname)
+	value, _ := rp.tx.GetLong(*rp.block, fieldPos)
+	return value
+}
+
+// Returns the floating-point value stored for the specified field of a specified slot
+func (rp *RecordPage) GetFloat(slot int, fieldname string) float64 {
+	return math.Float64frombits(uint64(rp.GetLong(slot, fieldname)))
+}
+
 // Returns the string value stored for the specified field of the specified slot.
 func (rp *RecordPage) GetString(slot int, fieldname string) string {
 	fieldPos := rp.offset(slot) + rp.layout.Offset(fieldname)
+	if rp.layout.Variable() {
+		if fieldPos = rp.getInt(fieldPos); fieldPos == 0 {
+			return ""
+		} else if fieldPos < 0 {
+			return rp.layout.decodeString(rp.overflow().readString(-fieldPos))
+		}
+		value, _ := rp.tx.GetString(*rp.block, fieldPos)
+		return rp.layout.decodeString(value)
+	}
 	value, _ := rp.tx.GetString(*rp.block, fieldPos)
 	return value
 }
 
+// Returns the bytes of the blob stored for the specified field of the specified slot
+func (rp *RecordPage) GetBlob(slot int, fieldname string) []byte {
+	b, _ := io.ReadAll(rp.BlobReader(slot, fieldname))
+	return b
+}
+
+// Returns a reader of the blob stored for the specified field of the
+// specified slot. The reader reads the blocks of the blob as it goes, so
+// it must be used before the record changes.
+func (rp *RecordPage) BlobReader(slot int, fieldname string) io.Reader {
+	first, length := rp.blobRef(slot, fieldname)
+	return &blobReader{o: rp.overflow(), next: first, left: length}
+}
+
+// Returns the first overflow block and the length of the blob of a field
+func (rp *RecordPage) blobRef(slot int, fieldname string) (int, int) {
+	fieldPos := rp.offset(slot) + rp.layout.Offset(fieldname)
+	return rp.getInt(fieldPos), rp.getInt(fieldPos + 4)
+}
+
+// Points the blob field of a record slot to its first overflow block
+func (rp *RecordPage) setBlobRef(slot int, fieldname string, first int, length int) {
+	fieldPos := rp.offset(slot) + rp.layout.Offset(fieldname)
+	rp.tx.SetInt(*rp.block, fieldPos, first, rp.okToLog)
+	rp.tx.SetInt(*rp.block, fieldPos+4, length, rp.okToLog)
+	rp.clearNull(slot, fieldname)
+}
+
+// Frees the overflow blocks of the blob of a field, leaving it empty
+func (rp *RecordPage) freeBlob(slot int, fieldname string) {
+	if first, _ := rp.blobRef(slot, fieldname); first != 0 {
+		rp.setBlobRef(slot, fieldname, 0, 0)
+		rp.overflow().free(first)
+	}
+}
+
+// Returns the manager of the overflow blocks of the table
+func (rp *RecordPage) overflow() *overflow {
+	return newOverflow(rp.tx, rp.block.FileName(), rp.okToLog)
+}
+
+// Reports whether the specified field of a record slot is null
+func (rp *RecordPage) IsNull(slot int, fieldname string) bool {
+	bit := rp.layout.NullBit(fieldname)
+	return bit >= 0 && rp.getInt(rp.offset(slot)+NULL_BITMAP_OFFSET)&(1<<bit) != 0
+}
+
+// Makes the specified field of a record slot null, keeping the value it
+// has in the slot. Setting a value clears it again.
+// Fails with ErrNotNullable if the field is past the null bitmap.
+func (rp *RecordPage) SetNull(slot int, fieldname string) error {
+	bit := rp.layout.NullBit(fieldname)
+	if bit < 0 {
+		return fmt.Errorf("%w: %s is past the first %d fields", ErrNotNullable, fieldname, MAX_NULLABLE_FIELDS)
+	}
+	rp.setNullBitmap(slot, rp.getInt(rp.offset(slot)+NULL_BITMAP_OFFSET)|1<<bit)
+	return nil
+}
+
 // Stores an integer value in the specified field of a record slot
 func (rp *RecordPage) SetInt(slot int, fieldname string, val int) {
 	fieldPos := rp.offset(slot) + rp.layout.Offset(fieldname)
-	rp.tx.SetInt(*rp.block, fieldPos, val, true)
+	rp.tx.SetInt(*rp.block, fieldPos, val, rp.logValue())
+	rp.clearNull(slot, fieldname)
+}
+
+// Stores a 64-bit integer value in the specified field of a record slot
+func (rp *RecordPage) SetLong(slot int, fieldname string, val int64) {
+	fieldPos := rp.offset(slot) + rp.layout.Offset(fieldname)
+	rp.tx.SetLong(*rp.block, fieldPos, val, rp.logValue())
+	rp.clearNull(slot, fieldname)
+}
+
+// Stores a floating-point value in the specified field of a record slot.
+// It is stored in the 8 bytes of a 64-bit integer, as Page.SetFloat writes it.
+func (rp *RecordPage) SetFloat(slot int, fieldname string, val float64) {
+	rp.SetLong(slot, fieldname, int64(math.Float64bits(val)))
+}
+
+// Stores a blob in the specified field of a record slot, replacing the
+// blob it held
+func (rp *RecordPage) SetBlob(slot int, fieldname string, val []byte) {
+	w := rp.BlobWriter(slot, fieldname)
+	w.Write(val)
+	w.Close()
+}
+
+// Returns a writer of a new blob for the specified field of a record slot.
+// The blob is written to overflow blocks as it goes, and replaces the
+// blob the field held once the writer is closed.
+func (rp *RecordPage) BlobWriter(slot int, fieldname string) io.WriteCloser {
+	// The writer keeps the block pinned until it is closed
+	rp.tx.Pin(rp.block)
+	page := &RecordPage{tx: rp.tx, block: rp.block, layout: rp.layout, blockSize: rp.blockSize, okToLog: rp.okToLog, logValues: rp.logValues}
+	return &blobWriter{o: rp.overflow(), rp: page, slot: slot, fieldname: fieldname}
 }
 
 // Stores a string value in the specified field of a record slot
 func (rp *RecordPage) SetString(slot int, fieldname string, val string) {
 	fieldPos := rp.offset(slot) + rp.layout.Offset(fieldname)
-	rp.tx.SetString(*rp.block, fieldPos, val, true)
+	rp.clearNull(slot, fieldname)
+	if rp.layout.Variable() {
+		rp.setHeapString(fieldPos, rp.layout.encodeString(val))
+		return
+	}
+	rp.tx.SetString(*rp.block, fieldPos, val, rp.logValue())
+}
+
+// Marks the specified field of a record slot as having a value
+func (rp *RecordPage) clearNull(slot int, fieldname string) {
+	if bit := rp.layout.NullBit(fieldname); bit >= 0 {
+		rp.setNullBitmap(slot, rp.getInt(rp.offset(slot)+NULL_BITMAP_OFFSET)&^(1<<bit))
+	}
+}
+
+// Writes the null bitmap of a record slot, unless it is unchanged
+func (rp *RecordPage) setNullBitmap(slot int, bitmap int) {
+	pos := rp.offset(slot) + NULL_BITMAP_OFFSET
+	if int32(rp.getInt(pos)) != int32(bitmap) {
+		rp.tx.SetInt(*rp.block, pos, bitmap, rp.logValue())
+	}
+}
+
+// Stores a string in the heap and points the field at ptrPos to it.
+// A value that is no longer than the current one overwrites it, any
+// other is written to newly allocated space, compacting the heap if
+// needed, or to overflow blocks if the block has no room left for it
+// or the change is too large to be logged, see Transaction.MaxLoggedString.
+func (rp *RecordPage) setHeapString(ptrPos int, val string) {
+	current := rp.getInt(ptrPos)
+	if current < 0 {
+		rp.freeOverflowString(ptrPos)
+		current = 0
+	}
+
+	if val == "" {
+		rp.tx.SetInt(*rp.block, ptrPos, 0, rp.logValue())
+		return
+	}
+
+	if current != 0 && len(val) <= min(rp.getInt(current), rp.tx.MaxLoggedString(rp.block.FileName(), rp.getInt(current))) {
+		rp.tx.SetString(*rp.block, current, val, rp.logValue())
+		return
+	}
+
+	size := file.MaxLength(len(val))
+	loggable := len(val) <= rp.tx.MaxLoggedString(rp.block.FileName(), 0)
+	if rp.freeSpace() < size && loggable {
+		rp.compactHeap(ptrPos)
+	}
+	if rp.freeSpace() < size || !loggable {
+		rp.tx.SetInt(*rp.block, ptrPos, -rp.overflow().writeString(val), rp.logValue())
+		return
+	}
+
+	// The free space may still hold the bytes of a value that was rolled
+	// back, so the length is cleared first for the old value to be logged as empty
+	pos := rp.heapStart() - size
+	rp.tx.SetInt(*rp.block, pos, 0, rp.logValue())
+	rp.tx.SetString(*rp.block, pos, val, rp.logValue())
+	rp.tx.SetInt(*rp.block, HEAP_START_OFFSET, pos, rp.okToLog)
+	rp.tx.SetInt(*rp.block, ptrPos, pos, rp.logValue())
+}
+
+// Rewrites the strings of the used slots next to each other at the end of
+// the block, reclaiming the space of the values that were replaced or
+// deleted. The string of the field at skip is about to be replaced and is
+// dropped.
+func (rp *RecordPage) compactHeap(skip int) {
+	type heapString struct {
+		ptrPos int
+		pos    int
+		val    string
+	}
+
+	var live []heapString
+	for slot := rp.searchAfter(-1, USED); slot >= 0; slot = rp.searchAfter(slot, USED) {
+		for _, fieldname := range rp.layout.storedFields() {
+			if rp.layout.Schema().DataType(fieldname) != sch.VARCHAR {
+				continue
+			}
+			ptrPos := rp.offset(slot) + rp.layout.Offset(fieldname)
+			if pos := rp.getInt(ptrPos); pos > 0 {
+				val, _ := rp.tx.GetString(*rp.block, pos)
+				live = append(live, heapString{ptrPos, pos, val})
+			}
+		}
+	}
+
+	// The strings are emptied where they are before any is moved, so that
+	// a rollback restores each of them whole, the new places overlapping
+	// the old ones
+	for _, s := range live {
+		rp.tx.SetString(*rp.block, s.pos, "", rp.logValue())
+	}
+
+	pos := rp.blockSize
+	for _, s := range live {
+		if s.ptrPos == skip {
+			rp.tx.SetInt(*rp.block, s.ptrPos, 0, rp.logValue())
+			continue
+		}
+		pos -= file.MaxLength(len(s.val))
+		rp.tx.SetInt(*rp.block, pos, 0, rp.logValue())
+		rp.tx.SetString(*rp.block, pos, s.val, rp.logValue())
+		rp.tx.SetInt(*rp.block, s.ptrPos, pos, rp.logValue())
+	}
+	if pos == rp.blockSize {
+		pos = 0
+	}
+	rp.tx.SetInt(*rp.block, HEAP_START_OFFSET, pos, rp.okToLog)
+}
+
+// Frees the overflow blocks of a string stored out of the block,
+// leaving the field at ptrPos empty
+func (rp *RecordPage) freeOverflowString(ptrPos int) {
+	if first := rp.getInt(ptrPos); first < 0 {
+		rp.tx.SetInt(*rp.block, ptrPos, 0, rp.logValue())
+		rp.overflow().free(-first)
+	}
 }
 
 // Initializes the block, making all slots empty and setting default values
 // for all record fields. This is called when the block is first allocated.
 func (rp *RecordPage) format() {
+	rp.formatSlots(false)
+}
+
+// Formats a block that already holds data, e.g. records written with a
+// different layout. Unlike format, the changes are logged so they are undone
+// if the transaction rolls back.
+func (rp *RecordPage) Reformat() {
+	rp.formatSlots(true)
+}
+
+// Empties every slot of the block and resets its fields
+func (rp *RecordPage) formatSlots(okToLog bool) {
+	if rp.layout.Variable() {
+		rp.tx.SetInt(*rp.block, SLOT_COUNT_OFFSET, 0, okToLog)
+		rp.tx.SetInt(*rp.block, HEAP_START_OFFSET, 0, okToLog)
+		return
+	}
+
 	slot := 0
 	for rp.isValidSlot(slot) {
 		// Set the slot flag to EMPTY
-		rp.tx.SetInt(*rp.block, rp.offset(slot), int(EMPTY), false)
+		rp.tx.SetInt(*rp.block, rp.offset(slot), int(EMPTY), okToLog)
 
 		// Initialize all fields in the slot
 		schema := rp.layout.Schema()
-		for _, fieldname := range schema.Fields() {
+		for _, fieldname := range rp.layout.storedFields() {
 			fieldPos := rp.offset(slot) + rp.layout.Offset(fieldname)
-			if schema.DataType(fieldname) == sch.INTEGER {
-				rp.tx.SetInt(*rp.block, fieldPos, 0, false)
+			if schema.DataType(fieldname) == sch.INTEGER || schema.DataType(fieldname) == sch.BOOLEAN {
+				rp.tx.SetInt(*rp.block, fieldPos, 0, okToLog)
+			} else if schema.DataType(fieldname) == sch.BIGINT || schema.DataType(fieldname) == sch.FLOAT || schema.DataType(fieldname).IsTemporal() || schema.DataType(fieldname) == sch.BLOB {
+				rp.tx.SetLong(*rp.block, fieldPos, 0, okToLog)
 			} else {
-				rp.tx.SetString(*rp.block, fieldPos, "", false)
+				// The bytes may belong to a record of another layout, so the
+				// length is cleared first for the old value to be logged as empty
+				rp.tx.SetInt(*rp.block, fieldPos, 0, okToLog)
+				rp.tx.SetString(*rp.block, fieldPos, "", okToLog)
 			}
 		}
 		slot++
 	}
 }
 
-// Marks a slot as empty (deleted)
+// Marks a slot as empty (deleted). The overflow blocks of its blobs and
+// strings are freed. In the variable format, the slots and heap of a block whose last
+// record is deleted are freed too.
 func (rp *RecordPage) delete(slot int) {
+	for _, fieldname := range rp.layout.storedFields() {
+		switch rp.layout.Schema().DataType(fieldname) {
+		case sch.BLOB:
+			rp.freeBlob(slot, fieldname)
+		case sch.VARCHAR:
+			if rp.layout.Variable() {
+				rp.freeOverflowString(rp.offset(slot) + rp.layout.Offset(fieldname))
+			}
+		}
+	}
 	rp.setFlag(slot, EMPTY)
+
+	if rp.layout.Variable() && rp.searchAfter(-1, USED) < 0 {
+		rp.formatSlots(rp.okToLog)
+	}
 }
 
 // Returns the next used slot after the specified slot
@@ -94,27 +430,118 @@ func (rp *RecordPage) NextAfter(slot int) int {
 }
 
 // Finds the next empty slot after the specified slot and marks
-// it as used
+// it as used. Only the slots within the table's fill factor are
+// considered, leaving the rest of the block free for later growth.
 func (rp *RecordPage) insertAfter(slot int) int {
+	if rp.layout.Variable() {
+		return rp.insertVariableAfter(slot)
+	}
+
 	newSlot := rp.searchAfter(slot, EMPTY)
+	if newSlot >= rp.fillLimit() {
+		return -1
+	}
 	if newSlot >= 0 {
 		rp.setFlag(newSlot, USED)
+		rp.setNullBitmap(newSlot, 0)
+	}
+	return newSlot
+}
+
+// Returns the number of slots of the block that inserts may use,
+// according to the fill factor of the layout. At least one slot is always usable.
+func (rp *RecordPage) fillLimit() int {
+	fillFactor := rp.layout.options.FillFactor
+	if fillFactor <= 0 {
+		fillFactor = DEFAULT_FILLFACTOR
+	}
+
+	numSlots := rp.blockSize / rp.layout.slotSize
+	return max(numSlots*fillFactor/100, 1)
+}
+
+// Finds the next empty slot after the specified slot, or allocates a
+// new one, and marks it as used. There must be room in the heap for the
+// strings of the record at their declared length, and the inserts may only
+// fill the part of the block allowed by the fill factor, leaving the rest
+// for strings to grow.
+func (rp *RecordPage) insertVariableAfter(slot int) int {
+	newSlot := rp.searchAfter(slot, EMPTY)
+
+	needed := rp.layout.maxStringBytes()
+	if newSlot < 0 {
+		needed += rp.layout.slotSize
+	}
+
+	free := rp.freeSpace()
+	if rp.searchAfter(-1, USED) >= 0 {
+		fillFactor := rp.layout.options.FillFactor
+		if fillFactor <= 0 {
+			fillFactor = DEFAULT_FILLFACTOR
+		}
+		free -= rp.blockSize * (100 - fillFactor) / 100
+	}
+	if free < needed {
+		return -1
+	}
+
+	if newSlot < 0 {
+		newSlot = rp.slotCount()
+		rp.tx.SetInt(*rp.block, SLOT_COUNT_OFFSET, newSlot+1, rp.okToLog)
+	}
+	rp.setFlag(newSlot, USED)
+	rp.setNullBitmap(newSlot, 0)
+
+	// The slot may still point to the strings of a deleted record
+	for _, fieldname := range rp.layout.storedFields() {
+		if rp.layout.Schema().DataType(fieldname) == sch.VARCHAR {
+			rp.tx.SetInt(*rp.block, rp.offset(newSlot)+rp.layout.Offset(fieldname), 0, rp.okToLog)
+		}
 	}
 	return newSlot
 }
 
 func (rp *RecordPage) offset(slot int) int {
+	if rp.layout.Variable() {
+		return VARIABLE_HEADER_SIZE + slot*rp.layout.slotSize
+	}
 	return slot * rp.layout.slotSize
 }
 
 // Checks if a slot number is within the block`s capacity
 func (rp *RecordPage) isValidSlot(slot int) bool {
-	return rp.offset(slot+1) <= rp.tx.BlockSize()
+	if rp.layout.Variable() {
+		return slot < rp.slotCount()
+	}
+	return rp.offset(slot+1) <= rp.blockSize
+}
+
+// Returns the number of slots allocated in a block of the variable format
+func (rp *RecordPage) slotCount() int {
+	return rp.getInt(SLOT_COUNT_OFFSET)
+}
+
+// Returns the offset of the first byte of the heap
+func (rp *RecordPage) heapStart() int {
+	if start := rp.getInt(HEAP_START_OFFSET); start != 0 {
+		return start
+	}
+	return rp.blockSize
+}
+
+// Returns the number of bytes between the slots and the heap
+func (rp *RecordPage) freeSpace() int {
+	return rp.heapStart() - rp.offset(rp.slotCount())
+}
+
+func (rp *RecordPage) getInt(offset int) int {
+	value, _ := rp.tx.GetInt(*rp.block, offset)
+	return int(value)
 }
 
 // Sets the status flag (EMPTY/USED) for a slot
 func (rp *RecordPage) setFlag(slot int, flag int) {
-	rp.tx.SetInt(*rp.block, rp.offset(slot), int(flag), true)
+	rp.tx.SetInt(*rp.block, rp.offset(slot), int(flag), rp.okToLog)
 }
 
 // Finds the next slot within the specified flag value
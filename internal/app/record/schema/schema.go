@@ -1,5 +1,7 @@
 package schema
 
+import "fmt"
+
 // The record schema of a table.
 // A schema contains the name and type of
 // each field value of the table, as well as the
@@ -12,13 +14,35 @@ type Schema struct {
 type FieldType int
 
 const (
-	INTEGER FieldType = 1 // integer type
-	VARCHAR FieldType = 2 // string type
+	INTEGER   FieldType = 1 // integer type
+	VARCHAR   FieldType = 2 // string type
+	BIGINT    FieldType = 3 // 64-bit integer type
+	FLOAT     FieldType = 4 // 64-bit floating-point type
+	DATE      FieldType = 5 // calendar date, stored as days since 1970-01-01
+	TIMESTAMP FieldType = 6 // point in time, stored as seconds since 1970-01-01 UTC
+	BOOLEAN   FieldType = 7 // true or false, stored as an integer 1 or 0
+	BLOB      FieldType = 8 // bytes of any length, stored in overflow blocks outside the record
 )
 
 type FieldInfo struct {
-	dataType FieldType
-	length   int
+	dataType      FieldType
+	length        int
+	collation     string // collation of a string field, empty for binary
+	nullable      bool
+	generated     string // expression computing a generated field, empty for others
+	stored        bool   // whether a generated field is stored rather than computed when read
+	notNull       bool   // whether the field was declared NOT NULL
+	defaultValue  string // value of the field when an insert omits it, as written in SQL, empty for none
+	autoIncrement bool   // whether an insert that omits the field takes the next value of its sequence
+}
+
+// Describes a field of a schema, as reported to clients
+type Column struct {
+	Name      string
+	Type      FieldType
+	Length    int // conceptual length of a string field
+	Collation string
+	Nullable  bool
 }
 
 func NewSchema() *Schema {
@@ -29,7 +53,7 @@ func NewSchema() *Schema {
 }
 
 // Add a field to the schema having a specified name, type and length.
-// If the field type is "integer", then the length value is irrelevant.
+// If the field type is numeric, then the length value is irrelevant.
 func (s *Schema) AddField(fieldName string, dataType FieldType, length int) {
 	s.fields = append(s.fields, fieldName)
 	s.info[fieldName] = FieldInfo{dataType: dataType, length: length}
@@ -40,6 +64,36 @@ func (s *Schema) AddIntField(fieldName string) {
 	s.AddField(fieldName, INTEGER, 0)
 }
 
+// Adds a 64-bit integer field to the schema
+func (s *Schema) AddLongField(fieldName string) {
+	s.AddField(fieldName, BIGINT, 0)
+}
+
+// Adds a floating-point field to the schema
+func (s *Schema) AddFloatField(fieldName string) {
+	s.AddField(fieldName, FLOAT, 0)
+}
+
+// Adds a date field to the schema
+func (s *Schema) AddDateField(fieldName string) {
+	s.AddField(fieldName, DATE, 0)
+}
+
+// Adds a timestamp field to the schema
+func (s *Schema) AddTimestampField(fieldName string) {
+	s.AddField(fieldName, TIMESTAMP, 0)
+}
+
+// Adds a boolean field to the schema
+func (s *Schema) AddBoolField(fieldName string) {
+	s.AddField(fieldName, BOOLEAN, 0)
+}
+
+// Adds a blob field to the schema
+func (s *Schema) AddBlobField(fieldName string) {
+	s.AddField(fieldName, BLOB, 0)
+}
+
 // Adds a string field to the schema.
 // The length is the conceptual length of the field.
 // For e.g, if the field is defined as varchar(8), then its length is 8.
@@ -48,12 +102,15 @@ func (s *Schema) AddStringField(fieldName string, length int) {
 }
 
 // Adds a field to the schema having the same type and length
-// as the corresponding field in another schema.
+// as the corresponding field in another schema. A generated field
+// is added as an ordinary one, since it is only generated in its table.
 func (s *Schema) Add(fieldName string, schema *Schema) {
 	dataType := schema.DataType(fieldName)
 	length := schema.Length(fieldName)
 
 	s.AddField(fieldName, dataType, length)
+	s.SetCollation(fieldName, schema.Collation(fieldName))
+	s.SetNullable(fieldName, schema.Nullable(fieldName))
 }
 
 // Add all of the fields in the specified schema to the current schema.
@@ -102,6 +159,161 @@ func (s *Schema) Length(fieldname string) int {
 	return info.length
 }
 
+// Sets the collation that the values of a string field are compared by
+func (s *Schema) SetCollation(fieldname string, collation string) {
+	info, ok := s.info[fieldname]
+	if !ok {
+		return
+	}
+
+	info.collation = collation
+	s.info[fieldname] = info
+}
+
+// Returns the collation of the specified field.
+// The value is empty for fields that compare their bytes.
+func (s *Schema) Collation(fieldname string) string {
+	return s.info[fieldname].collation
+}
+
+// Sets whether the specified field may hold null values
+func (s *Schema) SetNullable(fieldname string, nullable bool) {
+	info, ok := s.info[fieldname]
+	if !ok {
+		return
+	}
+
+	info.nullable = nullable
+	s.info[fieldname] = info
+}
+
+// Returns true if the specified field may hold null values
+func (s *Schema) Nullable(fieldname string) bool {
+	return s.info[fieldname].nullable
+}
+
+// Makes the field a generated field, whose value is computed from the
+// other fields of its record by expr. A stored field is computed when
+// the record is written, a virtual one whenever it is read.
+func (s *Schema) SetGenerated(fieldname string, expr string, stored bool) {
+	info, ok := s.info[fieldname]
+	if !ok {
+		return
+	}
+
+	info.generated = expr
+	info.stored = stored
+	s.info[fieldname] = info
+}
+
+// Returns the expression computing the specified field,
+// or "" if it isn't a generated field
+func (s *Schema) Generated(fieldname string) string {
+	return s.info[fieldname].generated
+}
+
+// Sets whether the specified field was declared NOT NULL,
+// rejecting null values when records are written
+func (s *Schema) SetNotNull(fieldname string, notNull bool) {
+	info, ok := s.info[fieldname]
+	if !ok {
+		return
+	}
+
+	info.notNull = notNull
+	s.info[fieldname] = info
+}
+
+// Returns true if the specified field was declared NOT NULL
+func (s *Schema) NotNull(fieldname string) bool {
+	return s.info[fieldname].notNull
+}
+
+// Sets the value a field takes when an insert omits it.
+// The value is kept as written in SQL, like the expression of a generated field.
+func (s *Schema) SetDefault(fieldname string, value string) {
+	info, ok := s.info[fieldname]
+	if !ok {
+		return
+	}
+
+	info.defaultValue = value
+	s.info[fieldname] = info
+}
+
+// Returns the value the specified field takes when an insert omits it,
+// as written in SQL, or "" if it has no default
+func (s *Schema) Default(fieldname string) string {
+	return s.info[fieldname].defaultValue
+}
+
+// Sets whether the specified field was declared AUTO_INCREMENT
+func (s *Schema) SetAutoIncrement(fieldname string, autoIncrement bool) {
+	info, ok := s.info[fieldname]
+	if !ok {
+		return
+	}
+
+	info.autoIncrement = autoIncrement
+	s.info[fieldname] = info
+}
+
+// Returns true if the specified field was declared AUTO_INCREMENT
+func (s *Schema) AutoIncrement(fieldname string) bool {
+	return s.info[fieldname].autoIncrement
+}
+
+// Returns true if the specified field is a generated field
+// that is computed whenever it is read
+func (s *Schema) IsVirtual(fieldname string) bool {
+	info := s.info[fieldname]
+	return info.generated != "" && !info.stored
+}
+
+// Returns a description of each field in the schema, in field order
+func (s *Schema) Columns() []Column {
+	columns := make([]Column, 0, len(s.fields))
+	for _, fieldName := range s.fields {
+		info := s.info[fieldName]
+		columns = append(columns, Column{
+			Name:      fieldName,
+			Type:      info.dataType,
+			Length:    info.length,
+			Collation: info.collation,
+			Nullable:  info.nullable,
+		})
+	}
+	return columns
+}
+
+// Returns the name of the field type, as written in SQL
+func (ft FieldType) String() string {
+	switch ft {
+	case INTEGER:
+		return "int"
+	case VARCHAR:
+		return "varchar"
+	case BIGINT:
+		return "bigint"
+	case FLOAT:
+		return "float"
+	case DATE:
+		return "date"
+	case TIMESTAMP:
+		return "timestamp"
+	case BOOLEAN:
+		return "boolean"
+	case BLOB:
+		return "blob"
+	}
+	return fmt.Sprintf("FieldType(%d)", int(ft))
+}
+
+// Reports whether the type holds dates or timestamps
+func (ft FieldType) IsTemporal() bool {
+	return ft == DATE || ft == TIMESTAMP
+}
+
 func (s *Schema) ToFieldType(value int) FieldType {
 	return FieldType(value)
 }
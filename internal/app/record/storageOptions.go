@@ -0,0 +1,111 @@
+package record
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The default fill factor, inserts may use every slot of a block
+const DEFAULT_FILLFACTOR = 100
+
+// The limits of the block size a table may be created with
+const (
+	MIN_BLOCK_SIZE = 64
+	MAX_BLOCK_SIZE = 1 << 16
+)
+
+// Record formats of a table
+const (
+	FORMAT_FIXED    = 0 // every string takes its declared length in the slot
+	FORMAT_VARIABLE = 1 // strings are stored apart from the slot and take their actual length
+)
+
+// Holds the physical storage options of a table.
+// They are set with CREATE TABLE ... WITH (fillfactor=80, blocksize=400, compression=off,
+// format=variable) and persisted in the table catalog.
+// In the variable format, a string that grows is written to the free space of
+// its block, so tables whose strings are updated should leave some with the fill factor.
+type StorageOptions struct {
+	FillFactor  int  // Percentage of the slots of a block that inserts may fill, or of the block in the variable format
+	BlockSize   int  // Block size of the table file in bytes, 0 means the database block size
+	Compression bool // Whether the strings of records are stored compressed, see Layout.encodeString
+	Format      int  // FORMAT_FIXED or FORMAT_VARIABLE
+
+	// How the records are spread over partition files, set with
+	// CREATE TABLE ... PARTITION BY. Nil if they are in the table's own file.
+	Partitioning *Partitioning
+}
+
+// Returns the options used for tables created without a WITH clause
+func DefaultStorageOptions() StorageOptions {
+	return StorageOptions{
+		FillFactor: DEFAULT_FILLFACTOR,
+		Format:     FORMAT_VARIABLE,
+	}
+}
+
+// Converts the options of a WITH clause into StorageOptions.
+// Option names are case-insensitive; unknown options and invalid values are rejected.
+func ParseStorageOptions(opts map[string]string) (StorageOptions, error) {
+	so := DefaultStorageOptions()
+
+	for name, val := range opts {
+		switch strings.ToLower(name) {
+		case "fillfactor":
+			ff, err := strconv.Atoi(val)
+			if err != nil || ff < 10 || ff > 100 {
+				return so, fmt.Errorf("fillfactor must be an integer between 10 and 100, got %q", val)
+			}
+			so.FillFactor = ff
+		case "blocksize":
+			bs, err := strconv.Atoi(val)
+			if err != nil || bs <= 0 {
+				return so, fmt.Errorf("blocksize must be a positive integer, got %q", val)
+			}
+			so.BlockSize = bs
+		case "compression":
+			switch strings.ToLower(val) {
+			case "on", "true", "1":
+				so.Compression = true
+			case "off", "false", "0":
+				so.Compression = false
+			default:
+				return so, fmt.Errorf("compression must be on or off, got %q", val)
+			}
+		case "format":
+			switch strings.ToLower(val) {
+			case "fixed":
+				so.Format = FORMAT_FIXED
+			case "variable":
+				so.Format = FORMAT_VARIABLE
+			default:
+				return so, fmt.Errorf("format must be fixed or variable, got %q", val)
+			}
+		default:
+			return so, fmt.Errorf("unknown storage option %q", name)
+		}
+	}
+
+	return so, nil
+}
+
+// Checks that the options can be honored by a database with the specified block size.
+// A table may have blocks of another size within the limits, the buffers holding
+// them being resized as needed. Only the variable format can have blocks larger
+// than the database's, since every change must fit in a log block, whose size is
+// the database's, and its strings are moved out of the block when they don't.
+// Compressed strings are stored with the length they take, which the fixed format
+// doesn't allow either.
+func (so StorageOptions) Validate(dbBlockSize int) error {
+	if so.BlockSize != 0 && (so.BlockSize < MIN_BLOCK_SIZE || so.BlockSize > MAX_BLOCK_SIZE) {
+		return fmt.Errorf("blocksize must be between %d and %d, got %d", MIN_BLOCK_SIZE, MAX_BLOCK_SIZE, so.BlockSize)
+	}
+	if so.BlockSize > dbBlockSize && so.Format != FORMAT_VARIABLE {
+		return fmt.Errorf("blocksize %d needs the variable format, fixed tables can't have blocks larger than the database's (%d)", so.BlockSize, dbBlockSize)
+	}
+	if so.Compression && so.Format != FORMAT_VARIABLE {
+		return fmt.Errorf("compression needs the variable format")
+	}
+	return nil
+}
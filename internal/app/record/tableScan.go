@@ -1,10 +1,15 @@
 package record
 
 import (
+	"bytes"
 	"centauri/internal/app/file"
 	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
 	"centauri/internal/app/tx"
 	"centauri/internal/app/types"
+	"fmt"
+	"io"
+	"math"
 )
 
 // Provides the abstraction for scanning and manipulating records in a table
@@ -17,24 +22,34 @@ type TableScan struct {
 	rp          *RecordPage
 	filename    string
 	currentSlot int
+	okToLog     bool
+	appendOnly  bool   // inserts go to blocks appended by the scan, see SetMinimalLogging
+	appended    bool   // whether the current block was appended by the scan
+	onNewBlock  func() // called whenever a block is appended to the table
+}
+
+// Returns the name of the file holding the records of a table, which
+// carries the block size of the table if it was created with one of its own
+func TableFile(tableName string, layout *Layout) string {
+	return file.SizedFileName(tableName, ".tbl", layout.options.BlockSize)
 }
 
 func NewTableScan(tx *tx.Transaction, tableName string, layout *Layout) *TableScan {
 	ts := &TableScan{
 		tx:          tx,
 		layout:      layout,
-		filename:    tableName + ".tbl",
+		filename:    TableFile(tableName, layout),
 		currentSlot: -1,
+		okToLog:     true,
 	}
 
 	// Check if the table file exists and has any blocks
 	size, _ := tx.Size(ts.filename)
 
-	// For empty tables, create the first block
+	// For empty tables the scan has no block until a record is inserted,
+	// so that reading one doesn't lock out the writers of the table
 	// For existing tables, position at the first block
-	if size == 0 {
-		ts.moveToNewBlock()
-	} else {
+	if size > 0 {
 		ts.moveToBlock(0)
 	}
 
@@ -44,37 +59,149 @@ func NewTableScan(tx *tx.Transaction, tableName string, layout *Layout) *TableSc
 // Positions the scan before the first record
 // This allows for a fresh scan of the table from the beginning
 func (ts *TableScan) BeforeFirst() {
+	if ts.rp == nil {
+		return
+	}
 	ts.moveToBlock(0)
 }
 
 // Moves to the next record in the table
 // Returns false if there are no more records
 func (ts *TableScan) Next() bool {
-	// Try to move to next slot in the current block
-	ts.currentSlot = ts.rp.NextAfter(ts.currentSlot)
+	ts.tx.CheckCancelled()
+	if ts.rp == nil {
+		return false
+	}
 
-	// If no more slots in current block
-	if ts.currentSlot < 0 {
-		// Check if we're at the last block
+	for {
+		// Try to move to next slot in the current block
+		ts.currentSlot = ts.rp.NextAfter(ts.currentSlot)
+		if ts.currentSlot >= 0 {
+			return true
+		}
+
+		// No more slots in current block, stop at the last block
 		if ts.atLastBlock() {
 			return false
 		}
-		// Move to next block and try again
+		// Move to next block and try again, skipping blocks without records
 		ts.moveToBlock(ts.rp.Block().Number() + 1)
 	}
-	return true
 }
 
 // Retrieves an integer value from the current record
 func (ts *TableScan) GetInt(fieldname string) int {
+	if g := ts.layout.Computed(fieldname); g != nil {
+		return computedInt(g.Evaluate(ts))
+	}
 	return ts.rp.GetInt(ts.currentSlot, fieldname)
 }
 
-// Retrieves a string value from the current record
+// Retrieves a string value from the current record.
+// A bigint, float, date, timestamp or boolean is retrieved as it is written, e.g. "2023-01-15" or "true",
+// and a blob as a string of its bytes.
 func (ts *TableScan) GetString(fieldname string) string {
+	if g := ts.layout.Computed(fieldname); g != nil {
+		return computedString(g.Evaluate(ts))
+	}
+	if ts.layout.Schema().DataType(fieldname) == schema.BLOB {
+		return string(ts.rp.GetBlob(ts.currentSlot, fieldname))
+	}
+	if fieldType := ts.layout.Schema().DataType(fieldname); fieldType == schema.BIGINT || fieldType == schema.FLOAT || fieldType.IsTemporal() || fieldType == schema.BOOLEAN {
+		if val := ts.GetVal(fieldname); !val.IsNull() {
+			return val.String()
+		}
+		return ""
+	}
 	return ts.rp.GetString(ts.currentSlot, fieldname)
 }
 
+// Retrieves the value of a field from the current record as a constant
+func (ts *TableScan) GetVal(fieldname string) *types.Constant {
+	if g := ts.layout.Computed(fieldname); g != nil {
+		return g.Evaluate(ts)
+	}
+	if ts.rp.IsNull(ts.currentSlot, fieldname) {
+		return types.NewConstantNull()
+	}
+	if fieldType := ts.layout.Schema().DataType(fieldname); fieldType == schema.INTEGER {
+		return types.NewConstantInt(ts.GetInt(fieldname))
+	} else if fieldType == schema.BIGINT {
+		return types.NewConstantLong(ts.rp.GetLong(ts.currentSlot, fieldname))
+	} else if fieldType == schema.FLOAT {
+		return types.NewConstantFloat(ts.rp.GetFloat(ts.currentSlot, fieldname))
+	} else if fieldType.IsTemporal() {
+		return TimeVal(fieldType, ts.rp.GetLong(ts.currentSlot, fieldname))
+	} else if fieldType == schema.BOOLEAN {
+		return types.NewConstantBool(ts.GetInt(fieldname) != 0)
+	} else if fieldType == schema.BLOB {
+		return types.NewConstantString(ts.GetString(fieldname))
+	}
+	return StringVal(ts.layout.Schema(), fieldname, ts.GetString(fieldname))
+}
+
+// Returns the value of a virtual integer field, 0 if it is null
+func computedInt(val *types.Constant) int {
+	if val == nil || val.AsInt() == nil {
+		return 0
+	}
+	return *val.AsInt()
+}
+
+// Returns the value of a virtual string field, "" if it is null
+func computedString(val *types.Constant) string {
+	if val == nil || val.AsString() == nil {
+		return ""
+	}
+	return *val.AsString()
+}
+
+// Checks that the collations of the string fields of a schema exist
+func ValidateCollations(sch *schema.Schema) error {
+	for _, fieldname := range sch.Fields() {
+		if collation := sch.Collation(fieldname); collation != "" {
+			if _, err := types.LookupCollation(collation); err != nil {
+				return fmt.Errorf("%w for field %s", err, fieldname)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns a string value of the field as a constant that compares
+// according to the field's collation
+func StringVal(sch *schema.Schema, fieldname string, val string) *types.Constant {
+	if coll := types.CollationNamed(sch.Collation(fieldname)); coll != nil {
+		return types.NewConstantStringWithCollation(val, coll)
+	}
+	return types.NewConstantString(val)
+}
+
+// Returns the integer a boolean field stores for a value, 1 for true and 0 for false
+func BoolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Returns the value stored for a date or timestamp field as a constant
+func TimeVal(fieldType schema.FieldType, stored int64) *types.Constant {
+	if fieldType == schema.DATE {
+		return types.NewConstantDate(stored)
+	}
+	return types.NewConstantTimestamp(stored)
+}
+
+// Converts a value to the date or timestamp a field of the type stores.
+// Strings are parsed, and dates and timestamps converted to each other.
+func ToTime(fieldType schema.FieldType, val *types.Constant) (*types.Constant, error) {
+	if fieldType == schema.DATE {
+		return val.ToDate()
+	}
+	return val.ToTimestamp()
+}
+
 // Releases any resources held by the scanner
 // This primarily involves unpinning the current block
 func (ts *TableScan) Close() {
@@ -88,50 +215,243 @@ func (ts *TableScan) moveToBlock(blockNum int) {
 	ts.Close() // Release current block if any
 	block := file.NewBlockID(ts.filename, blockNum)
 	ts.rp = NewRecordPage(ts.tx, block, ts.layout)
+	ts.rp.SetLogging(ts.okToLog)
+	ts.rp.SetValueLogging(!ts.appendOnly)
+	ts.appended = false
 	ts.currentSlot = -1 // Reset position within new block
 }
 
+// Calls fn whenever an insert appends a block to the table,
+// e.g. to account for the space the table takes
+func (ts *TableScan) OnNewBlock(fn func()) {
+	ts.onNewBlock = fn
+}
+
 // Appends a new block to the table and positions the scanner there
 // This is used when we need to expand the table
 func (ts *TableScan) moveToNewBlock() {
 	ts.Close()
 	block, _ := ts.tx.Append(ts.filename)
 	ts.rp = NewRecordPage(ts.tx, &block, ts.layout)
+	ts.rp.SetLogging(ts.okToLog)
+	ts.rp.SetValueLogging(!ts.appendOnly)
+	ts.appended = true
 	ts.currentSlot = -1 // Reset position within new block
+
+	if ts.onNewBlock != nil {
+		ts.onNewBlock()
+	}
+}
+
+// Turns logging of the scan's changes on or off, e.g. to bulk load a table
+// with minimal logging. See RecordPage.SetLogging.
+func (ts *TableScan) SetLogging(okToLog bool) {
+	ts.okToLog = okToLog
+	if ts.rp != nil {
+		ts.rp.SetLogging(okToLog)
+	}
+}
+
+// Makes the scan insert records only into blocks it appends to the table, and
+// log just the slots they take rather than their values. The inserts can still
+// be rolled back, since that frees the slots and no other record was ever in
+// them, while the log holds a fraction of what it otherwise would.
+// The scan must only be used to insert records.
+func (ts *TableScan) SetMinimalLogging() {
+	ts.appendOnly = true
+	if ts.rp != nil {
+		ts.rp.SetValueLogging(false)
+	}
 }
 
 // Sets an integer value in the current record
-func (ts *TableScan) SetInt(fieldname string, val int) {
+func (ts *TableScan) SetInt(fieldname string, val int) error {
+	if ts.layout.Computed(fieldname) != nil {
+		return fmt.Errorf("%w: %s is computed when read and can't be set", ErrGeneratedField, fieldname)
+	}
 	ts.rp.SetInt(ts.currentSlot, fieldname, val)
+	return nil
 }
 
 // Sets a string value in the current record
-func (ts *TableScan) SetString(fieldname string, val string) {
+func (ts *TableScan) SetString(fieldname string, val string) error {
+	if ts.layout.Computed(fieldname) != nil {
+		return fmt.Errorf("%w: %s is computed when read and can't be set", ErrGeneratedField, fieldname)
+	}
 	ts.rp.SetString(ts.currentSlot, fieldname, val)
+	return nil
+}
+
+// Makes a field of the current record null. Its value in the record is
+// reset, so it reads as 0 or "" from GetInt and GetString.
+// Fails with ErrNotNullable if the field is past the null bitmap.
+func (ts *TableScan) SetNull(fieldname string) error {
+	if ts.layout.Computed(fieldname) != nil {
+		return fmt.Errorf("%w: %s is computed when read and can't be set", ErrGeneratedField, fieldname)
+	}
+	if ts.layout.NullBit(fieldname) < 0 {
+		return fmt.Errorf("%w: %s is past the first %d fields", ErrNotNullable, fieldname, MAX_NULLABLE_FIELDS)
+	}
+
+	if fieldType := ts.layout.Schema().DataType(fieldname); fieldType == schema.INTEGER || fieldType == schema.BOOLEAN {
+		ts.rp.SetInt(ts.currentSlot, fieldname, 0)
+	} else if fieldType == schema.BIGINT || fieldType == schema.FLOAT || fieldType.IsTemporal() {
+		ts.rp.SetLong(ts.currentSlot, fieldname, 0)
+	} else if fieldType == schema.BLOB {
+		ts.rp.freeBlob(ts.currentSlot, fieldname)
+	} else {
+		ts.rp.SetString(ts.currentSlot, fieldname, "")
+	}
+	return ts.rp.SetNull(ts.currentSlot, fieldname)
 }
 
-// Creates a new record in the table
-func (ts *TableScan) Insert() bool {
+// Sets the value of a field in the current record from a constant,
+// which may be NULL.
+// Fails with ErrFieldType if the table has no such field or the constant
+// doesn't match the field's type.
+func (ts *TableScan) SetVal(fieldname string, val *types.Constant) error {
+	sch := ts.layout.Schema()
+	if !sch.HasField(fieldname) {
+		return fmt.Errorf("%w: %s has no field %s", ErrFieldType, ts.filename, fieldname)
+	}
+	if val.IsNull() {
+		return ts.SetNull(fieldname)
+	}
+
+	if sch.DataType(fieldname) == schema.INTEGER {
+		n, ok := val.AsInteger()
+		if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+			return fmt.Errorf("%w: %s is an integer field, got %v", ErrFieldType, fieldname, val)
+		}
+		return ts.SetInt(fieldname, int(n))
+	}
+
+	if sch.DataType(fieldname) == schema.BIGINT {
+		n, ok := val.AsInteger()
+		if !ok {
+			return fmt.Errorf("%w: %s is a bigint field, got %v", ErrFieldType, fieldname, val)
+		}
+		if ts.layout.Computed(fieldname) != nil {
+			return fmt.Errorf("%w: %s is computed when read and can't be set", ErrGeneratedField, fieldname)
+		}
+		ts.rp.SetLong(ts.currentSlot, fieldname, n)
+		return nil
+	}
+
+	if sch.DataType(fieldname) == schema.FLOAT {
+		f, ok := val.AsNumber()
+		if !ok {
+			return fmt.Errorf("%w: %s is a float field, got %v", ErrFieldType, fieldname, val)
+		}
+		if ts.layout.Computed(fieldname) != nil {
+			return fmt.Errorf("%w: %s is computed when read and can't be set", ErrGeneratedField, fieldname)
+		}
+		ts.rp.SetFloat(ts.currentSlot, fieldname, f)
+		return nil
+	}
+
+	if sch.DataType(fieldname) == schema.BOOLEAN {
+		if val == nil || val.AsBool() == nil {
+			return fmt.Errorf("%w: %s is a boolean field, got %v", ErrFieldType, fieldname, val)
+		}
+		return ts.SetInt(fieldname, BoolInt(*val.AsBool()))
+	}
+
+	if fieldType := sch.DataType(fieldname); fieldType.IsTemporal() {
+		t, err := ToTime(fieldType, val)
+		if err != nil {
+			return fmt.Errorf("%w: %s is a %s field, got %v", ErrFieldType, fieldname, fieldType, val)
+		}
+		if ts.layout.Computed(fieldname) != nil {
+			return fmt.Errorf("%w: %s is computed when read and can't be set", ErrGeneratedField, fieldname)
+		}
+		ts.rp.SetLong(ts.currentSlot, fieldname, *t.AsLong())
+		return nil
+	}
+
+	if val == nil || val.AsString() == nil {
+		return fmt.Errorf("%w: %s is a string field, got %v", ErrFieldType, fieldname, val)
+	}
+	if sch.DataType(fieldname) == schema.BLOB {
+		return ts.SetBlob(fieldname, []byte(*val.AsString()))
+	}
+	return ts.SetString(fieldname, *val.AsString())
+}
+
+// Stores the bytes in a blob field of the current record
+func (ts *TableScan) SetBlob(fieldname string, val []byte) error {
+	w, err := ts.BlobWriter(fieldname)
+	if err != nil {
+		return err
+	}
+	w.Write(val)
+	return w.Close()
+}
+
+// Returns a reader of the bytes of a blob field of the current record,
+// which reads the overflow blocks of the blob as it goes. A null blob
+// reads as empty. The reader must be used before the record changes.
+// Fails with ErrFieldType if the field isn't a blob.
+func (ts *TableScan) BlobReader(fieldname string) (io.Reader, error) {
+	if ts.layout.Schema().DataType(fieldname) != schema.BLOB {
+		return nil, fmt.Errorf("%w: %s is not a blob field", ErrFieldType, fieldname)
+	}
+	if ts.rp.IsNull(ts.currentSlot, fieldname) {
+		return bytes.NewReader(nil), nil
+	}
+	return ts.rp.BlobReader(ts.currentSlot, fieldname), nil
+}
+
+// Returns a writer of a new value for a blob field of the current record.
+// The bytes are written to overflow blocks as they come, so a blob may be
+// larger than a block and than memory. The value replaces the one of the
+// record once the writer is closed, even if the scan has moved on.
+// Fails with ErrFieldType if the field isn't a blob.
+func (ts *TableScan) BlobWriter(fieldname string) (io.WriteCloser, error) {
+	if ts.layout.Schema().DataType(fieldname) != schema.BLOB {
+		return nil, fmt.Errorf("%w: %s is not a blob field", ErrFieldType, fieldname)
+	}
+	if ts.layout.Computed(fieldname) != nil {
+		return nil, fmt.Errorf("%w: %s is computed when read and can't be set", ErrGeneratedField, fieldname)
+	}
+	return ts.rp.BlobWriter(ts.currentSlot, fieldname), nil
+}
+
+// Creates a new record in the table.
+// Panics with ErrRecordTooLarge if the record can't fit in any block.
+func (ts *TableScan) Insert() error {
+	// A minimally logged insert can't reuse the slots of other records
+	if ts.rp == nil || (ts.appendOnly && !ts.appended) {
+		ts.moveToNewBlock()
+	}
+
 	// Attempt to insert in current block after current position
 	ts.currentSlot = ts.rp.insertAfter(ts.currentSlot)
 
 	// If no more slots in current block
 	for ts.currentSlot < 0 {
 		// Check if we're at the last block
-		if ts.atLastBlock() {
+		newBlock := ts.atLastBlock() || ts.appendOnly
+		if newBlock {
 			ts.moveToNewBlock()
 		} else {
 			ts.moveToBlock(ts.rp.Block().Number() + 1)
 		}
 		ts.currentSlot = ts.rp.insertAfter(ts.currentSlot)
+
+		// A record that doesn't fit in an empty block never will
+		if ts.currentSlot < 0 && newBlock {
+			panic(fmt.Errorf("%w: %s needs %d bytes", ErrRecordTooLarge, ts.filename, ts.layout.recordSize()))
+		}
 	}
 
-	return true
+	return nil
 }
 
 // Removes the current record from the table
-func (ts *TableScan) Delete() {
+func (ts *TableScan) Delete() error {
 	ts.rp.delete(ts.currentSlot)
+	return nil
 }
 
 // Checks if the table has a field with the given name
@@ -139,17 +459,27 @@ func (ts *TableScan) HasField(fieldname string) bool {
 	return ts.layout.Schema().HasField(fieldname)
 }
 
+// Returns the schema of the table
+func (ts *TableScan) Schema() *schema.Schema {
+	return ts.layout.Schema()
+}
+
 // Positions the scanner at a specific record identified by RID
-func (ts *TableScan) MoveToRID(rid any) {
-	ts.Close()                                                       // Release current block if any
-	block := file.NewBlockID(ts.filename, ts.GetRID().BlockNumber()) // Loads the specified block into memory
+func (ts *TableScan) MoveToRID(rid *types.RID) error {
+	ts.Close()                                               // Release current block if any
+	block := file.NewBlockID(ts.filename, rid.BlockNumber()) // Loads the specified block into memory
 	ts.rp = NewRecordPage(ts.tx, block, ts.layout)
+	ts.rp.SetLogging(ts.okToLog)
+	ts.rp.SetValueLogging(!ts.appendOnly)
+	ts.appended = false
 	// Positions at the exact slot within the block
-	ts.currentSlot = ts.GetRID().Slot()
+	ts.currentSlot = rid.Slot()
+	return nil
 }
 
-func (ts *TableScan) GetRID() *types.RID {
-	return types.NewRID(ts.rp.Block().Number(), ts.currentSlot)
+// Returns the Record ID of the current record
+func (ts *TableScan) GetRID() (*types.RID, error) {
+	return types.NewRID(ts.rp.Block().Number(), ts.currentSlot), nil
 }
 
 // Checks if the current block is the last block of the table
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// A statement recorded in the audit log
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"` // empty for statements run by the engine itself
+	Database  string    `json:"database"`
+	Statement string    `json:"statement"`
+	Duration  float64   `json:"duration_ms"`
+	Rows      int       `json:"rows"` // records affected by an update, 0 for other statements
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// AuditLog appends a record of every statement clients execute to a
+// file, one JSON object per line. The file is only ever appended to, so
+// it can be shipped or rotated by external tools.
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Opens the audit log at path, creating it if it doesn't exist
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &AuditLog{file: f}, nil
+}
+
+// Appends an entry to the log
+func (al *AuditLog) Record(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if _, err := al.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+func (al *AuditLog) Close() error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	return al.file.Close()
+}
+
+// Records a statement executed on behalf of a user in the audit log,
+// if the database keeps one. A failure to write the log is reported but
+// doesn't fail the statement.
+func (db *CentauriDB) audit(user string, database string, cmd string, start time.Time, rows int, err error) {
+	if db.auditLog == nil {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:      start,
+		User:      user,
+		Database:  database,
+		Statement: cmd,
+		Duration:  float64(time.Since(start).Microseconds()) / 1000,
+		Rows:      rows,
+		Success:   err == nil,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if err := db.auditLog.Record(entry); err != nil {
+		fmt.Printf("warning: %v\n", err)
+	}
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"centauri/config"
+	"centauri/internal/app/file"
+	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// The file of a backup recording how to restore it: the block size of the
+// database, the LSN replaying the log starts after and the log's file name
+const BACKUP_LABEL_FILE = "backup.label"
+
+var (
+	ErrNotBackup     = errors.New("not a backup directory")
+	ErrRestoreTarget = errors.New("a backup can only be restored to an empty directory")
+)
+
+// Copies the files of the database to dir, which must not exist yet,
+// while transactions keep running. The files other transactions change
+// during the copy are copied as they are at that moment, which restoring
+// the backup repairs by replaying the log from the last checkpoint taken
+// before the copy started. A checkpoint is written first if no transaction
+// is running, so that there is less of the log to replay.
+//
+// The log is copied last, so it holds every change that reached the copied
+// files. Changes that aren't logged, such as those of a COPY FROM, are only
+// in the backup if they were written to disk before the copy started. The
+// other databases of the instance are backed up on their own.
+func (db *CentauriDB) Backup(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("backup directory %s already exists", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	fromLSN, err := db.backupCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(db.cfg.DataDir)
+	if err != nil {
+		return fmt.Errorf("failed to list data files: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || file.IsTemp(name) || name == STANDBY_POSITION_FILE ||
+			(db.logFm == db.fm && name == db.cfg.LogFile) {
+			continue
+		}
+		// A table dropped during the copy needs no copy
+		if err := copyFile(db.fm, name, dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if err := db.lm.Flush(db.lm.LatestLSN()); err != nil {
+		return fmt.Errorf("failed to flush log: %w", err)
+	}
+	if err := copyFile(db.logFm, db.cfg.LogFile, dir); err != nil {
+		return err
+	}
+
+	label := fmt.Sprintf("%d %d %s\n", db.cfg.BlockSize, fromLSN, db.cfg.LogFile)
+	if err := os.WriteFile(filepath.Join(dir, BACKUP_LABEL_FILE), []byte(label), 0644); err != nil {
+		return fmt.Errorf("failed to write backup label: %w", err)
+	}
+	return nil
+}
+
+// Writes a checkpoint if no transaction is running, holding new ones back
+// meanwhile, and returns the LSN of the last checkpoint of the log
+func (db *CentauriDB) backupCheckpoint() (int, error) {
+	db.txMu.Lock()
+	if db.activeTxs == 0 {
+		db.saveRowCounts()
+		if err := tx.Checkpoint(db.lm, db.bm); err != nil {
+			db.txMu.Unlock()
+			return 0, fmt.Errorf("checkpoint failed: %w", err)
+		}
+	}
+	db.txMu.Unlock()
+
+	return tx.LastCheckpointLSN(db.lm)
+}
+
+// Copies a file managed by fm to a directory
+func copyFile(fm *file.FileManager, name string, dir string) error {
+	out, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	if err := fm.CopyFile(name, out); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to sync %s: %w", name, err)
+	}
+	return out.Close()
+}
+
+// Restores the backup in backupDir to the empty directory dirName,
+// see RestoreFromConfig
+func Restore(backupDir string, dirName string, blockSize int, buffSize int) error {
+	return RestoreFromConfig(backupDir, configFor(dirName, blockSize, buffSize))
+}
+
+// Restores the backup in backupDir to the data and log directories of cfg,
+// which must be empty or not exist. The changes logged since the backup's
+// checkpoint are replayed in order, then the transactions that hadn't
+// finished when the log was copied are rolled back, leaving the database
+// as it was then. The database can be opened with cfg afterwards.
+func RestoreFromConfig(backupDir string, cfg *config.Config) error {
+	blockSize, fromLSN, logFile, err := readBackupLabel(backupDir)
+	if err != nil {
+		return err
+	}
+	if blockSize != cfg.BlockSize {
+		return fmt.Errorf("the backup has a block size of %d, not %d", blockSize, cfg.BlockSize)
+	}
+	for _, dir := range []string{cfg.DataDir, cfg.LogDirectory()} {
+		if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+			return fmt.Errorf("%w: %s", ErrRestoreTarget, dir)
+		}
+	}
+
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch name {
+		case BACKUP_LABEL_FILE:
+			continue
+		case logFile:
+			err = copyPlainFile(filepath.Join(backupDir, name), filepath.Join(cfg.LogDirectory(), cfg.LogFile))
+		default:
+			err = copyPlainFile(filepath.Join(backupDir, name), filepath.Join(cfg.DataDir, name))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	db, err := newCentauriDB(cfg)
+	if err != nil {
+		return err
+	}
+	t := db.NewTx()
+	if err := t.Replay(fromLSN); err != nil {
+		t.Rollback()
+		db.Close()
+		return fmt.Errorf("failed to replay log: %w", err)
+	}
+	if err := t.Recover(); err != nil {
+		db.Close()
+		return fmt.Errorf("recovery failed: %w", err)
+	}
+	t.Commit()
+
+	if err := db.lm.Close(); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to close log: %w", err)
+	}
+	return db.Close()
+}
+
+// Reads the label of a backup
+func readBackupLabel(backupDir string) (blockSize int, fromLSN int, logFile string, err error) {
+	data, err := os.ReadFile(filepath.Join(backupDir, BACKUP_LABEL_FILE))
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("%w: %s: %w", ErrNotBackup, backupDir, err)
+	}
+	if _, err := fmt.Sscanf(string(data), "%d %d %s", &blockSize, &fromLSN, &logFile); err != nil {
+		return 0, 0, "", fmt.Errorf("%w: invalid label in %s: %w", ErrNotBackup, backupDir, err)
+	}
+	return blockSize, fromLSN, logFile, nil
+}
+
+// Copies a file that nothing else is writing
+func copyPlainFile(src string, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(dst), err)
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	if err := os.WriteFile(dst, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"fmt"
+	"sort"
+)
+
+// The version of the database server
+const SERVER_VERSION = "0.1.0"
+
+// The version of the wire protocol described in protocol.go
+const PROTOCOL_VERSION = 5
+
+// Kinds of relations listed by ListTables
+const (
+	KIND_TABLE = "table"
+	KIND_VIEW  = "view"
+)
+
+// The maximum length of a type name listed by DESCRIBE
+const MAX_TYPE_NAME = 16
+
+// Describes the versions of the server, its catalog format and wire protocol
+type VersionInfo struct {
+	Server   string
+	Catalog  int
+	Protocol int
+}
+
+// Names a table or view
+type TableInfo struct {
+	Name string
+	Kind string // KIND_TABLE or KIND_VIEW
+}
+
+// Describes a column of a table or view
+type ColumnInfo struct {
+	Name          string
+	Type          schema.FieldType
+	Length        int    // maximum length of varchar values
+	Collation     string // collation of varchar values, empty for binary
+	Generated     string // expression computing the column, empty if it is assigned
+	Stored        bool   // whether a generated column is computed on write rather than on read
+	NotNull       bool   // whether the column was declared NOT NULL
+	Default       string // value of the column when an insert omits it, as written in SQL, empty for none
+	AutoIncrement bool   // whether an insert that omits the column takes the next value of its sequence
+}
+
+// Describes an index and the fields it is built on, in key order
+type IndexDesc struct {
+	Name   string
+	Fields []string
+	Type   string // the structure of the index, "hash" or "btree"
+	Unique bool   // whether no two records may have the same key
+}
+
+// Describes a constraint on the records of a table
+type ConstraintDesc struct {
+	Name   string
+	Kind   string
+	Fields []string
+}
+
+// Describes the structure of a table or view, as used by tools that
+// introspect the database. Views have columns and a definition, but no
+// indexes or constraints.
+type TableDesc struct {
+	Name        string
+	Kind        string
+	Definition  string // the query defining a view, empty for tables
+	Partitions  string // the PARTITION BY clause of a partitioned table, empty otherwise
+	Columns     []ColumnInfo
+	Indexes     []IndexDesc
+	Constraints []ConstraintDesc
+}
+
+// Returns the versions of the server, its catalog format and wire protocol
+func (db *CentauriDB) ServerVersion() VersionInfo {
+	return VersionInfo{
+		Server:   SERVER_VERSION,
+		Catalog:  metadata.CATALOG_VERSION,
+		Protocol: PROTOCOL_VERSION,
+	}
+}
+
+// Returns the user tables followed by the views, each in the order
+// they were created. The catalog tables are not included.
+func (db *CentauriDB) ListTables(tx *tx.Transaction) []TableInfo {
+	tables := []TableInfo{}
+	for _, tableName := range db.mdm.TableNames(tx) {
+		tables = append(tables, TableInfo{Name: tableName, Kind: KIND_TABLE})
+	}
+	for _, viewName := range db.mdm.ViewNames(tx) {
+		tables = append(tables, TableInfo{Name: viewName, Kind: KIND_VIEW})
+	}
+	return tables
+}
+
+// Describes the columns, indexes and constraints of a table, or the columns of a view.
+// Returns metadata.ErrTableNotFound if there is no table or view with the name.
+func (db *CentauriDB) DescribeTable(tableName string, tx *tx.Transaction) (TableDesc, error) {
+	if viewDef := db.mdm.GetViewDef(tableName, tx); viewDef != "" {
+		p, err := db.planner.CreateQueryPlan(viewDef, tx)
+		if err != nil {
+			return TableDesc{}, fmt.Errorf("failed to plan view %s: %w", tableName, err)
+		}
+		return TableDesc{
+			Name:        tableName,
+			Kind:        KIND_VIEW,
+			Definition:  viewDef,
+			Columns:     columnInfos(p.Schema()),
+			Indexes:     []IndexDesc{},
+			Constraints: []ConstraintDesc{},
+		}, nil
+	}
+
+	layout, err := db.mdm.GetLayout(tableName, tx)
+	if err != nil {
+		return TableDesc{}, err
+	}
+
+	indexes := []IndexDesc{}
+	for _, ii := range db.mdm.GetIndexInfo(tableName, tx) {
+		indexes = append(indexes, IndexDesc{Name: ii.IndexName(), Fields: ii.FieldNames(), Type: ii.IndexType(), Unique: ii.IsUnique()})
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].Name < indexes[j].Name
+	})
+
+	partitions := ""
+	if p := layout.Options().Partitioning; p != nil {
+		partitions = p.String()
+	}
+
+	return TableDesc{
+		Name:        tableName,
+		Kind:        KIND_TABLE,
+		Partitions:  partitions,
+		Columns:     columnInfos(layout.Schema()),
+		Indexes:     indexes,
+		Constraints: []ConstraintDesc{},
+	}, nil
+}
+
+func columnInfos(sch *schema.Schema) []ColumnInfo {
+	cols := make([]ColumnInfo, 0, len(sch.Fields()))
+	for _, fieldName := range sch.Fields() {
+		cols = append(cols, ColumnInfo{
+			Name:          fieldName,
+			Type:          sch.DataType(fieldName),
+			Length:        sch.Length(fieldName),
+			Collation:     sch.Collation(fieldName),
+			Generated:     sch.Generated(fieldName),
+			Stored:        sch.Generated(fieldName) != "" && !sch.IsVirtual(fieldName),
+			NotNull:       sch.NotNull(fieldName),
+			Default:       sch.Default(fieldName),
+			AutoIncrement: sch.AutoIncrement(fieldName),
+		})
+	}
+	return cols
+}
+
+// Plans a query, SHOW TABLES or DESCRIBE, for callers that have no session,
+// such as an embedded database. The other session commands change or show
+// the settings of a session, and fail here.
+func (db *CentauriDB) CreateQueryPlan(sql string, tx *tx.Transaction) (interfaces.Plan, error) {
+	lexer := parse.NewLexer(sql)
+	if !lexer.MatchKeyword("show") && !lexer.MatchKeyword("describe") && !lexer.MatchKeyword("desc") {
+		return db.planner.CreateQueryPlan(sql, tx)
+	}
+
+	obj, err := parse.NewParser(sql).SessionCmd()
+	if err != nil {
+		return nil, err
+	}
+	switch data := obj.(type) {
+	case *parse.ShowTablesData:
+		return db.tablesPlan(tx), nil
+	case *parse.DescribeData:
+		return db.describePlan(data.TableName(), tx)
+	}
+	return nil, fmt.Errorf("%s can only be run in a session", sql)
+}
+
+// Returns the tables and views of the database as records of their
+// name and kind, in the order of ListTables. Answers SHOW TABLES.
+func (db *CentauriDB) tablesPlan(tx *tx.Transaction) *plan.RowsPlan {
+	sch := schema.NewSchema()
+	sch.AddStringField("name", metadata.MAX_NAME)
+	sch.AddStringField("kind", len(KIND_TABLE))
+
+	tables := db.ListTables(tx)
+	rows := make([]map[string]*types.Constant, len(tables))
+	for i, table := range tables {
+		rows[i] = map[string]*types.Constant{
+			"name": types.NewConstantString(table.Name),
+			"kind": types.NewConstantString(table.Kind),
+		}
+	}
+	return plan.NewRowsPlan(sch, rows)
+}
+
+// Returns the columns of a table or view as records of their name, type,
+// length and offset in the records of the table. The length is null but
+// for varchar columns, and the offset is null for the columns of a view
+// and the generated columns that aren't stored. Answers DESCRIBE.
+func (db *CentauriDB) describePlan(tableName string, tx *tx.Transaction) (*plan.RowsPlan, error) {
+	desc, err := db.DescribeTable(tableName, tx)
+	if err != nil {
+		return nil, err
+	}
+	var layout *record.Layout
+	if desc.Kind == KIND_TABLE {
+		if layout, err = db.mdm.GetLayout(tableName, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	sch := schema.NewSchema()
+	sch.AddStringField("name", metadata.MAX_NAME)
+	sch.AddStringField("type", MAX_TYPE_NAME)
+	sch.AddIntField("length")
+	sch.AddIntField("offset")
+
+	rows := make([]map[string]*types.Constant, len(desc.Columns))
+	for i, col := range desc.Columns {
+		length, offset := types.NewConstantNull(), types.NewConstantNull()
+		if col.Type == schema.VARCHAR {
+			length = types.NewConstantInt(col.Length)
+		}
+		if layout != nil && layout.Offset(col.Name) >= 0 {
+			offset = types.NewConstantInt(layout.Offset(col.Name))
+		}
+		rows[i] = map[string]*types.Constant{
+			"name":   types.NewConstantString(col.Name),
+			"type":   types.NewConstantString(col.Type.String()),
+			"length": length,
+			"offset": offset,
+		}
+	}
+	return plan.NewRowsPlan(sch, rows), nil
+}
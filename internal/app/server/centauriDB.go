@@ -1,63 +1,154 @@
 package server
 
 import (
+	"centauri/config"
 	"centauri/internal/app/buffer"
 	"centauri/internal/app/file"
+	"centauri/internal/app/index/planner"
 	"centauri/internal/app/log"
 	"centauri/internal/app/metadata"
 	"centauri/internal/app/plan"
 	"centauri/internal/app/tx"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
 )
 
-const BLOCK_SIZE = 400
-const BUFFER_SIZE = 8
-const LOG_FILE = "centauridb.log"
+const BLOCK_SIZE = config.DEFAULT_BLOCK_SIZE
+const BUFFER_SIZE = config.DEFAULT_BUFFER_COUNT
+const LOG_FILE = config.DEFAULT_LOG_FILE
+
+// Returned by BeginTx once the database has started shutting down
+var ErrShuttingDown = errors.New("database is shutting down")
+
+// Returned when opening a directory that holds files but no database
+var ErrNotDatabase = errors.New("not a database directory")
 
 type CentauriDB struct {
+	name    string // empty for the default database
+	cfg     *config.Config
 	fm      *file.FileManager
+	logFm   *file.FileManager // file manager for the log directory, the same as fm unless configured apart
 	bm      *buffer.BufferManager
 	lm      *log.LogManager
 	mdm     *metadata.MetaDataManager
 	planner *plan.Planner
-	mu      sync.RWMutex
+	standby *Standby // applies the primary's log, nil unless running as a standby
+
+	auditLog *AuditLog     // records the statements clients execute, nil if not configured
+	slowLog  *SlowQueryLog // records the statements that run too long, nil if not configured
+
+	databases *databases // the other databases of the instance, nil for all but the default one
+	mu        sync.RWMutex
+
+	txMu        sync.Mutex    // protects the fields below
+	activeTxs   int           // number of transactions that haven't finished
+	closing     bool          // set once Shutdown is called, rejects new transactions
+	countsSaved bool          // set once the row counts are saved for closing
+	idle        chan struct{} // closed when the last active transaction finishes during shutdown
 }
 
 // Creates a new CentauriDb instance with custom configuration
 func NewCentauriDBWithConfig(dirName string, blockSize int, buffSize int) (*CentauriDB, error) {
-	if err := os.MkdirAll(dirName, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create dirctory: %w", err)
+	return newCentauriDB(configFor(dirName, blockSize, buffSize))
+}
+
+// Returns the default configuration with the given directory, block size and buffer count
+func configFor(dirName string, blockSize int, buffSize int) *config.Config {
+	cfg := config.Default()
+	cfg.DataDir = dirName
+	cfg.BlockSize = blockSize
+	cfg.BufferCount = buffSize
+	return cfg
+}
+
+// Creates the file, log and buffer managers described by cfg
+func newCentauriDB(cfg *config.Config) (*CentauriDB, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	db := &CentauriDB{}
+	db := &CentauriDB{cfg: cfg}
+	syncWrites := cfg.Fsync == config.FSYNC_ALWAYS
 
 	// Intialize the File Manager
-	fm, err := file.NewFileManager(dirName, blockSize)
+	fm, err := file.NewFileManager(cfg.DataDir, cfg.BlockSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file manager: %w", err)
 	}
+	fm.SetSyncWrites(syncWrites)
 	db.fm = fm
+	db.logFm = fm
+
+	// The log may live in its own directory, e.g. on a separate disk
+	if logDir := cfg.LogDirectory(); logDir != cfg.DataDir {
+		logFm, err := file.NewFileManager(logDir, cfg.BlockSize)
+		if err != nil {
+			fm.Close()
+			return nil, fmt.Errorf("failed to create log file manager: %w", err)
+		}
+		logFm.SetSyncWrites(syncWrites)
+		db.logFm = logFm
+	}
 
 	// Intialize the Log Manager
-	lm, err := log.NewLogManager(fm, LOG_FILE)
+	lm, err := log.NewLogManager(db.logFm, cfg.LogFile)
 	if err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to create log manager: %w", err)
 	}
+	lm.SetRetention(cfg.Retention)
 	db.lm = lm
 
 	// Intialize the Buffer Manager
-	bm := buffer.NewBufferManager(fm, lm, buffSize)
+	bm := buffer.NewBufferManager(fm, lm, cfg.BufferCount)
+	bm.SetMaxWaitTime(cfg.PinTimeout)
 	db.bm = bm
 
+	tx.SetLockTimeoutFor(fm, cfg.LockTimeout)
+
+	if cfg.AuditFile != "" {
+		auditLog, err := OpenAuditLog(cfg.AuditFile)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		db.auditLog = auditLog
+	}
+
+	if cfg.SlowQueryTime > 0 {
+		slowLog, err := OpenSlowQueryLog(cfg.SlowQueryFile, cfg.SlowQueryTime)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		db.slowLog = slowLog
+	}
+
 	return db, nil
 }
 
 // Creates a new CentauriDB instance with default configuration
 // and initializes the metadata table
 func NewCentauriDB(dirName string) (*CentauriDB, error) {
-	db, err := NewCentauriDBWithConfig(dirName, BLOCK_SIZE, BUFFER_SIZE)
+	return OpenCentauriDB(dirName, BLOCK_SIZE, BUFFER_SIZE)
+}
+
+// Creates a new CentauriDB instance with custom configuration,
+// recovers the database if it already exists and initializes the
+// metadata manager and planners
+func OpenCentauriDB(dirName string, blockSize int, buffSize int) (*CentauriDB, error) {
+	return OpenCentauriDBFromConfig(configFor(dirName, blockSize, buffSize))
+}
+
+// Opens the database described by cfg, recovering it if it already exists
+func OpenCentauriDBFromConfig(cfg *config.Config) (*CentauriDB, error) {
+	if err := checkDataDir(cfg.DataDir); err != nil {
+		return nil, err
+	}
+	db, err := newCentauriDB(cfg)
 
 	if err != nil {
 		return nil, err
@@ -65,31 +156,82 @@ func NewCentauriDB(dirName string) (*CentauriDB, error) {
 
 	tx := db.NewTx()
 
+	// Releases the transaction and the files of a database that failed to
+	// open. Its row counts aren't saved, as they may not have been recounted.
+	fail := func(err error) (*CentauriDB, error) {
+		tx.Rollback()
+		db.mdm = nil
+		db.Close()
+		return nil, err
+	}
+
 	// Check if this is a new database
 	isNew := db.fm.IsNew()
 
+	if isNew && cfg.StandbyLogDir != "" {
+		return fail(ErrNoBaseBackup)
+	}
+
 	if isNew {
 		fmt.Println("creating new database")
 	} else {
 		fmt.Println("recovering existing database")
 		if err := tx.Recover(); err != nil {
-			return nil, fmt.Errorf("recovery failed: %w", &err)
+			return fail(fmt.Errorf("recovery failed: %w", err))
 		}
 	}
 
 	// Initialize metadata manager
-	mdm := metadata.NewMetaDataManager(isNew, tx)
+	mdm, err := metadata.NewMetaDataManager(isNew, tx)
+	if err != nil {
+		return fail(fmt.Errorf("failed to load metadata: %w", err))
+	}
 	db.mdm = mdm
 
-	// Initialize query and update planners
+	// The row count changes of the last run may have been lost in a crash
+	if !isNew {
+		saved, err := db.takeRowCountsSaved()
+		if err != nil {
+			return fail(err)
+		}
+		if !saved && cfg.StandbyLogDir == "" {
+			mdm.RecountRows(tx)
+		}
+	}
+	if cfg.StandbyLogDir != "" {
+		mdm.IgnoreRowCounts()
+	}
+
+	// A standby gets its users from the primary
+	if cfg.StandbyLogDir == "" {
+		if err := db.createAdmin(tx); err != nil {
+			return fail(err)
+		}
+	}
+
+	// Initialize query and update planners. Updates keep the indexes
+	// up to date, so that the ordered ones can answer min and max.
 	qp := plan.NewBasicQueryPlanner(mdm)
-	up := plan.NewBasicUpdatePlanner(mdm)
+	up := planner.NewIndexUpdatePlanner(mdm)
+	up.SetTxFactory(db.NewTx)
 
-	db.planner = plan.NewPlanner(qp, up)
+	db.planner = plan.NewPlanner(qp, up, mdm)
+	db.databases = newDatabases(db)
 
 	// Commit the transaction
 	tx.Commit()
 
+	if cfg.StandbyLogDir != "" {
+		if err := db.startStandby(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	if cfg.StatsRefresh > 0 {
+		mdm.StatMgr().StartBackgroundRefresh(cfg.StatsRefresh, db.NewTx)
+	}
+
 	// if err := tx.Commit(); err != nil {
 	// 	return nil, fmt.Errorf("failed to commit transaction: %w", &err)
 	// }
@@ -97,8 +239,225 @@ func NewCentauriDB(dirName string) (*CentauriDB, error) {
 	return db, nil
 }
 
+// Fails with ErrNotDatabase if the directory holds files but no table
+// catalog, which every database has. A directory that doesn't exist or
+// holds only temp files gets a new database.
+func checkDataDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.Name() == metadata.TABLE_CATALOG_FILE {
+			return nil
+		}
+	}
+	for _, entry := range entries {
+		if !file.IsTemp(entry.Name()) {
+			return fmt.Errorf("%w: %s has no %s", ErrNotDatabase, dir, metadata.TABLE_CATALOG_FILE)
+		}
+	}
+	return nil
+}
+
+// Makes the database a read-only standby that applies the log
+// shipped from its primary
+func (db *CentauriDB) startStandby() error {
+	standby, err := newStandby(db)
+	if err != nil {
+		return err
+	}
+
+	db.planner.SetReadOnly(true)
+	db.standby = standby
+	standby.start(db.cfg.StandbyPoll)
+	fmt.Printf("running as a standby of the log shipped to %s\n", db.cfg.StandbyLogDir)
+	return nil
+}
+
+// Returns the standby applying the primary's log, or nil
+// if the database isn't running as a standby
+func (db *CentauriDB) Standby() *Standby {
+	return db.standby
+}
+
+// Creates the configured superuser if the database has no users yet,
+// so that a new database can be logged into over the network
+func (db *CentauriDB) createAdmin(tx *tx.Transaction) error {
+	if len(db.mdm.UserNames(tx)) > 0 {
+		return nil
+	}
+
+	if db.cfg.AdminPassword == "" {
+		fmt.Println("warning: no users exist and no admin password is configured, network clients cannot log in")
+		return nil
+	}
+
+	if err := db.mdm.CreateUser(db.cfg.AdminUser, db.cfg.AdminPassword, true, tx); err != nil {
+		return fmt.Errorf("failed to create admin user: %w", err)
+	}
+	fmt.Printf("created superuser %s\n", db.cfg.AdminUser)
+	return nil
+}
+
+// Checks a user's password and returns the user's details.
+// Fails with metadata.ErrAuthFailed if the name or password is wrong.
+func (db *CentauriDB) Authenticate(userName string, password string) (metadata.UserInfo, error) {
+	tx, err := db.BeginReadOnlyTx()
+	if err != nil {
+		return metadata.UserInfo{}, err
+	}
+	defer tx.Commit()
+
+	return db.mdm.Authenticate(userName, password, tx)
+}
+
+// Starts a new transaction, even while the database is shutting down.
+// Used for work that belongs to a transaction already running; clients
+// should use BeginTx.
 func (db *CentauriDB) NewTx() *tx.Transaction {
-	return tx.NewTransaction(db.fm, db.lm, db.bm)
+	db.txMu.Lock()
+	db.activeTxs++
+	db.txMu.Unlock()
+
+	return db.newTrackedTx(tx.SERIALIZABLE)
+}
+
+// Starts a new transaction, unless the database is shutting down
+func (db *CentauriDB) BeginTx() (*tx.Transaction, error) {
+	return db.BeginTxWithIsolation(tx.SERIALIZABLE)
+}
+
+// Starts a new transaction at the given isolation level, unless the
+// database is shutting down
+func (db *CentauriDB) BeginTxWithIsolation(level tx.IsolationLevel) (*tx.Transaction, error) {
+	if err := db.startTx(); err != nil {
+		return nil, err
+	}
+	return db.newTrackedTx(level), nil
+}
+
+// Starts a new read-only transaction, unless the database is shutting
+// down. It suits metadata queries and reports: it logs nothing and
+// fails with tx.ErrReadOnlyTransaction if it writes a table.
+func (db *CentauriDB) BeginReadOnlyTx() (*tx.Transaction, error) {
+	if err := db.startTx(); err != nil {
+		return nil, err
+	}
+	t := tx.NewReadOnlyTransaction(db.fm, db.lm, db.bm)
+	t.OnFinish(db.txFinished)
+	return t, nil
+}
+
+// Counts a transaction that is about to start as active, unless the
+// database is shutting down
+func (db *CentauriDB) startTx() error {
+	db.txMu.Lock()
+	defer db.txMu.Unlock()
+
+	if db.closing {
+		return ErrShuttingDown
+	}
+	db.activeTxs++
+	return nil
+}
+
+// Creates a transaction that's counted as active until it finishes
+func (db *CentauriDB) newTrackedTx(level tx.IsolationLevel) *tx.Transaction {
+	t := tx.NewTransactionWithIsolation(db.fm, db.lm, db.bm, level)
+	t.OnFinish(db.txFinished)
+	return t
+}
+
+func (db *CentauriDB) txFinished() {
+	db.txMu.Lock()
+	defer db.txMu.Unlock()
+
+	db.activeTxs--
+	if db.activeTxs == 0 && db.idle != nil {
+		close(db.idle)
+		db.idle = nil
+	}
+}
+
+// Returns the number of transactions that haven't committed or rolled back
+func (db *CentauriDB) ActiveTxs() int {
+	db.txMu.Lock()
+	defer db.txMu.Unlock()
+
+	return db.activeTxs
+}
+
+// Shuts the database down gracefully. It stops new transactions from
+// starting, waits for the active ones to finish until ctx is done,
+// writes a checkpoint, flushes the buffers and closes the log and files.
+//
+// If ctx is done first, the checkpoint is skipped and ctx's error is
+// returned; the unfinished transactions are undone by recovery the next
+// time the database is opened.
+func (db *CentauriDB) Shutdown(ctx context.Context) error {
+	db.txMu.Lock()
+	db.closing = true
+	var idle chan struct{}
+	if db.activeTxs > 0 {
+		if db.idle == nil {
+			db.idle = make(chan struct{})
+		}
+		idle = db.idle
+	}
+	db.txMu.Unlock()
+
+	// The refresh runs transactions of its own
+	db.stopStatsRefresh()
+
+	var dbsErr error
+	if db.databases != nil {
+		dbsErr = db.databases.shutdown(ctx)
+	}
+
+	// Applying the shipped log starts transactions of its own
+	if db.standby != nil {
+		if err := db.standby.close(); err != nil {
+			fmt.Printf("standby: %v\n", err)
+		}
+		db.standby = nil
+	}
+
+	var waitErr error
+	if idle != nil {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			waitErr = fmt.Errorf("%d transactions still active: %w", db.ActiveTxs(), ctx.Err())
+		}
+	}
+
+	if waitErr == nil {
+		db.saveRowCounts()
+		db.countsSaved = true
+		if err := tx.Checkpoint(db.lm, db.bm); err != nil {
+			db.Close()
+			return fmt.Errorf("checkpoint failed: %w", err)
+		}
+	}
+
+	if err := db.lm.Close(); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to close log: %w", err)
+	}
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+	if waitErr != nil {
+		return waitErr
+	}
+	return dbsErr
+}
+
+// Returns the configuration the database was opened with
+func (db *CentauriDB) Config() *config.Config {
+	return db.cfg
 }
 
 func (db *CentauriDB) MdMgr() *metadata.MetaDataManager {
@@ -120,3 +479,56 @@ func (db *CentauriDB) LogMgr() *log.LogManager {
 func (db *CentauriDB) BufferMgr() *buffer.BufferManager {
 	return db.bm
 }
+
+// Stops recalculating the statistics in the background, if it was started
+func (db *CentauriDB) stopStatsRefresh() {
+	if db.mdm != nil {
+		db.mdm.StatMgr().StopBackgroundRefresh()
+	}
+}
+
+// Closes the database files. Transactions must be committed
+// or rolled back before closing.
+func (db *CentauriDB) Close() error {
+	db.stopStatsRefresh()
+	if db.databases != nil {
+		db.databases.close()
+	}
+	if db.standby != nil {
+		db.standby.close()
+		db.standby = nil
+	}
+	if db.auditLog != nil {
+		db.auditLog.Close()
+		db.auditLog = nil
+	}
+	if db.slowLog != nil {
+		db.slowLog.Close()
+		db.slowLog = nil
+	}
+
+	// Shutdown saves the row counts itself, before its checkpoint
+	db.txMu.Lock()
+	if !db.closing && db.activeTxs == 0 {
+		db.saveRowCounts()
+		db.countsSaved = true
+	}
+	db.txMu.Unlock()
+
+	// Committed changes may still be only in the buffer pool and the log
+	if db.bm != nil {
+		db.bm.FlushAllBuffers()
+	}
+	if db.countsSaved && db.mdm != nil && db.cfg.StandbyLogDir == "" {
+		if err := db.markRowCountsSaved(); err != nil {
+			fmt.Println(err)
+		}
+	}
+	err := db.fm.Close()
+	if db.logFm != db.fm {
+		if logErr := db.logFm.Close(); err == nil {
+			err = logErr
+		}
+	}
+	return err
+}
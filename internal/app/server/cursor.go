@@ -0,0 +1,186 @@
+package server
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUnknownCursor = errors.New("unknown cursor")
+	ErrCursorExists  = errors.New("cursor already exists")
+	ErrNotAQuery     = errors.New("only queries can be opened as cursors")
+)
+
+// A query whose records are read a batch at a time with Session.Fetch,
+// so a large result never has to be held in memory as a whole. The scan
+// stays open between fetches, in the transaction started with Begin or
+// otherwise in a transaction of the cursor's own, which commits when the
+// cursor is closed.
+type Cursor struct {
+	name    string
+	plan    interfaces.Plan
+	scan    interfaces.Scan
+	tx      *tx.Transaction
+	ownTx   bool // the cursor's transaction ends when the cursor closes
+	fetched int  // number of records fetched so far
+}
+
+func (c *Cursor) Name() string {
+	return c.name
+}
+
+// Returns the schema of the cursor's records
+func (c *Cursor) Schema() *schema.Schema {
+	return c.scan.Schema()
+}
+
+// Returns the number of records fetched so far
+func (c *Cursor) Fetched() int {
+	return c.fetched
+}
+
+// Closes the scan, and commits or rolls back the cursor's own transaction
+func (c *Cursor) close(commit bool) {
+	c.scan.Close()
+	if !c.ownTx {
+		return
+	}
+	if commit {
+		c.tx.Commit()
+	} else {
+		c.tx.Rollback()
+	}
+}
+
+// Opens a cursor over the records of a query
+func (s *Session) OpenCursor(name string, cmd string) (c *Cursor, err error) {
+	start := time.Now()
+	defer func() { s.audit(cmd, start, 0, err) }()
+
+	if _, ok := s.cursors[name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrCursorExists, name)
+	}
+	if !IsQuery(cmd) {
+		return nil, ErrNotAQuery
+	}
+
+	t := s.tx
+	ownTx := t == nil
+	if ownTx {
+		if t, err = s.newTx(); err != nil {
+			return nil, err
+		}
+	} else {
+		t.ResetCancel()
+		t.ResetTempSpace()
+	}
+
+	s.running.Store(t)
+	defer s.running.Store(nil)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = s.abort(t, ownTx, r)
+		}
+	}()
+
+	p, err := s.db.Planner().CreateQueryPlanAs(cmd, s.user, t)
+	if err != nil {
+		if ownTx {
+			t.Rollback()
+		}
+		return nil, err
+	}
+
+	c = &Cursor{name: name, plan: p, scan: p.Open(), tx: t, ownTx: ownTx}
+	s.cursors[name] = c
+	return c, nil
+}
+
+// Returns the open cursor with the given name
+func (s *Session) Cursor(name string) (*Cursor, error) {
+	c, ok := s.cursors[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCursor, name)
+	}
+	return c, nil
+}
+
+// Reads up to n records from a cursor, calling handle for each of them.
+// Returns false once the cursor has no more records, in which case it has
+// been closed. A cursor that fails is closed, and its transaction rolled back.
+func (s *Session) Fetch(name string, n int, handle func(interfaces.Scan) error) (more bool, err error) {
+	c, ok := s.cursors[name]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrUnknownCursor, name)
+	}
+
+	if !c.ownTx {
+		c.tx.ResetCancel()
+	}
+	s.running.Store(c.tx)
+	defer s.running.Store(nil)
+
+	defer func() {
+		if r := recover(); r != nil {
+			delete(s.cursors, name)
+			c.scan.Close()
+			err = s.abort(c.tx, c.ownTx, r)
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		if !c.scan.Next() {
+			delete(s.cursors, name)
+			c.close(true)
+			return false, nil
+		}
+		if err := handle(c.scan); err != nil {
+			return true, err
+		}
+		c.fetched++
+	}
+	return true, nil
+}
+
+// Closes a cursor before all of its records have been fetched.
+// Returns the number of records that were fetched.
+func (s *Session) CloseCursor(name string) (int, error) {
+	c, ok := s.cursors[name]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownCursor, name)
+	}
+
+	delete(s.cursors, name)
+	c.close(true)
+	return c.fetched, nil
+}
+
+// Closes the open cursors. With txOnly set, only the cursors reading in
+// the transaction started with Begin are closed, e.g. when it ends.
+func (s *Session) closeCursors(txOnly bool, commit bool) {
+	for name, c := range s.cursors {
+		if txOnly && c.ownTx {
+			continue
+		}
+		delete(s.cursors, name)
+		c.close(commit)
+	}
+}
+
+// Rolls back the transaction of a statement that panicked and returns the
+// panic as an error. If the transaction was started with Begin, it ends too.
+func (s *Session) abort(t *tx.Transaction, ownTx bool, r any) error {
+	if !ownTx {
+		s.closeCursors(true, false)
+		t.Rollback()
+		s.tx = nil
+		return fmt.Errorf("statement failed, transaction rolled back: %w", panicError(r))
+	}
+	t.Rollback()
+	return fmt.Errorf("statement failed: %w", panicError(r))
+}
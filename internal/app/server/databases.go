@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// The name of the database stored directly in the data directory
+const DEFAULT_DATABASE = "main"
+
+// The directory, under the data and log directories, holding the
+// files of the other databases, each in a directory of its own
+const DATABASES_DIR = "databases"
+
+// The maximum length of a database name
+const MAX_DATABASE_NAME = 64
+
+var (
+	ErrDatabaseExists      = errors.New("database already exists")
+	ErrUnknownDatabase     = errors.New("unknown database")
+	ErrInvalidDatabaseName = errors.New("invalid database name")
+)
+
+// databases holds the databases of a server instance besides the
+// default one. Each has its own catalog, files, log and buffer pool, and
+// is opened the first time it's used.
+type databases struct {
+	main *CentauriDB
+	mu   sync.Mutex
+	open map[string]*CentauriDB // databases opened so far, by name
+}
+
+func newDatabases(main *CentauriDB) *databases {
+	return &databases{
+		main: main,
+		open: make(map[string]*CentauriDB),
+	}
+}
+
+// Returns the name of the database
+func (db *CentauriDB) Name() string {
+	if db.name == "" {
+		return DEFAULT_DATABASE
+	}
+	return db.name
+}
+
+// Returns the database with the given name, opening it if needed.
+// Only the default database knows the others.
+func (db *CentauriDB) Database(name string) (*CentauriDB, error) {
+	if name == db.Name() {
+		return db, nil
+	}
+	if db.databases == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDatabase, name)
+	}
+	return db.databases.get(name, false)
+}
+
+// Creates a new, empty database
+func (db *CentauriDB) CreateDatabase(name string) error {
+	if db.databases == nil || name == DEFAULT_DATABASE {
+		return fmt.Errorf("%w: %s", ErrDatabaseExists, name)
+	}
+	_, err := db.databases.get(name, true)
+	return err
+}
+
+// Returns the names of all databases in alphabetical order
+func (db *CentauriDB) DatabaseNames() ([]string, error) {
+	names := []string{db.Name()}
+	if db.databases == nil {
+		return names, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(db.cfg.DataDir, DATABASES_DIR))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list databases: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Opens a database, creating it if create is set
+func (dbs *databases) get(name string, create bool) (*CentauriDB, error) {
+	if !validDatabaseName(name) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidDatabaseName, name)
+	}
+
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+
+	db, ok := dbs.open[name]
+	if !ok {
+		cfg := *dbs.main.cfg
+		cfg.DataDir = filepath.Join(cfg.DataDir, DATABASES_DIR, name)
+		if cfg.LogDir != "" {
+			cfg.LogDir = filepath.Join(cfg.LogDir, DATABASES_DIR, name)
+		}
+		if cfg.StandbyLogDir != "" {
+			cfg.StandbyLogDir = filepath.Join(cfg.StandbyLogDir, DATABASES_DIR, name)
+		}
+		// Statements are logged by the default database, whichever database they run in
+		cfg.AuditFile = ""
+		cfg.SlowQueryTime = 0
+
+		_, err := os.Stat(cfg.DataDir)
+		exists := err == nil
+		if !exists && !create {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownDatabase, name)
+		}
+		if exists && create {
+			return nil, fmt.Errorf("%w: %s", ErrDatabaseExists, name)
+		}
+
+		if db, err = OpenCentauriDBFromConfig(&cfg); err != nil {
+			return nil, fmt.Errorf("failed to open database %s: %w", name, err)
+		}
+		// Only the default database knows the others
+		db.name = name
+		db.databases = nil
+		dbs.open[name] = db
+		return db, nil
+	}
+
+	if create {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseExists, name)
+	}
+	return db, nil
+}
+
+// Shuts down every database that was opened, returning the first error
+func (dbs *databases) shutdown(ctx context.Context) error {
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+
+	var firstErr error
+	for name, db := range dbs.open {
+		if err := db.Shutdown(ctx); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("database %s: %w", name, err)
+		}
+		delete(dbs.open, name)
+	}
+	return firstErr
+}
+
+// Closes the files of every database that was opened
+func (dbs *databases) close() {
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+
+	for name, db := range dbs.open {
+		db.Close()
+		delete(dbs.open, name)
+	}
+}
+
+// A database name is used as a directory name, so it must be a
+// plain identifier: a letter followed by letters, digits or underscores
+func validDatabaseName(name string) bool {
+	if name == "" || len(name) > MAX_DATABASE_NAME {
+		return false
+	}
+	for i, ch := range name {
+		isLetter := ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+		isDigit := ch >= '0' && ch <= '9'
+		if !isLetter && (i == 0 || !isDigit && ch != '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,346 @@
+package server
+
+import (
+	"centauri/config"
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/plan"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const DEFAULT_HTTP_ADDR = config.DEFAULT_HTTP_ADDR
+
+// The largest request body accepted by /query
+const MAX_REQUEST_SIZE = 1 << 20
+
+// The number of rows written between flushes of a streamed result
+const HTTP_FLUSH_ROWS = 100
+
+// Serves the database over HTTP for scripting and lightweight integrations.
+//
+//	POST /query   {"sql": "select ..."}    runs a statement and returns its result as JSON
+//	GET  /health                            reports whether the server is up
+//	GET  /stats                             reports request counters and relation sizes
+//
+// Query results are streamed as they are read, so large results aren't held in memory:
+//
+//	{"columns":["id","name"],"rows":[[1,"alice"],[2,"bob"]],"count":2}
+//
+// An update returns {"affected": n}, along with "last_insert_id" if it
+// generated an AUTO_INCREMENT value. A failed statement returns {"error": "..."}
+// with status 400, or an "error" member after the rows if it fails while streaming.
+//
+// /query and /stats require HTTP Basic authentication with the name and
+// password of a database user, and answer status 401 without it.
+type HTTPServer struct {
+	db        *CentauriDB
+	srv       *http.Server
+	startTime time.Time
+	queries   atomic.Int64
+	updates   atomic.Int64
+	errors    atomic.Int64
+}
+
+type queryRequest struct {
+	SQL string `json:"sql"`
+}
+
+type updateResponse struct {
+	Affected     int   `json:"affected"`
+	LastInsertID int64 `json:"last_insert_id,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type statsResponse struct {
+	UptimeSeconds    int64          `json:"uptime_seconds"`
+	Queries          int64          `json:"queries"`
+	Updates          int64          `json:"updates"`
+	Errors           int64          `json:"errors"`
+	BuffersAvailable int            `json:"buffers_available"`
+	Relations        []relationStat `json:"relations"`
+}
+
+type relationStat struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Blocks int    `json:"blocks"`
+	Rows   int    `json:"rows"`
+	Bytes  int    `json:"bytes"`
+}
+
+func NewHTTPServer(db *CentauriDB, addr string) *HTTPServer {
+	if addr == "" {
+		addr = DEFAULT_HTTP_ADDR
+	}
+
+	hs := &HTTPServer{
+		db:        db,
+		startTime: time.Now(),
+	}
+	hs.srv = &http.Server{
+		Addr:    addr,
+		Handler: hs.Handler(),
+	}
+	return hs
+}
+
+// Returns the handler serving the endpoints, e.g. to mount it in another server
+func (hs *HTTPServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/query", hs.handleQuery)
+	mux.HandleFunc("/health", hs.handleHealth)
+	mux.HandleFunc("/stats", hs.handleStats)
+	return mux
+}
+
+func (hs *HTTPServer) ListenAndServe() error {
+	return hs.srv.ListenAndServe()
+}
+
+// Serves requests on connections accepted from the listener
+func (hs *HTTPServer) Serve(listener net.Listener) error {
+	return hs.srv.Serve(listener)
+}
+
+// Stops accepting requests and waits for the running ones to finish
+func (hs *HTTPServer) Shutdown(ctx context.Context) error {
+	return hs.srv.Shutdown(ctx)
+}
+
+// Checks the Basic credentials of a request, answering status 401 if they're
+// missing or wrong. Returns the authenticated user, or false if the request was refused.
+func (hs *HTTPServer) authenticate(w http.ResponseWriter, r *http.Request) (*metadata.UserInfo, bool) {
+	userName, password, ok := r.BasicAuth()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="centauri"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: ErrAuthenticationRequired.Error()})
+		return nil, false
+	}
+
+	user, err := hs.db.Authenticate(userName, password)
+	if err != nil {
+		hs.errors.Add(1)
+		w.Header().Set("WWW-Authenticate", `Basic realm="centauri"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: err.Error()})
+		return nil, false
+	}
+	return &user, true
+}
+
+func (hs *HTTPServer) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	user, ok := hs.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req queryRequest
+	body := http.MaxBytesReader(w, r.Body, MAX_REQUEST_SIZE)
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		hs.errors.Add(1)
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	if req.SQL == "" {
+		hs.errors.Add(1)
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "missing sql"})
+		return
+	}
+
+	start := time.Now()
+	stats := &statementStats{}
+	err := hs.execute(w, r, req.SQL, user, stats)
+	if err != nil {
+		hs.errors.Add(1)
+	}
+	hs.db.audit(user.Name, hs.db.Name(), req.SQL, start, stats.rows, err)
+	hs.db.logSlowQuery(user.Name, hs.db.Name(), req.SQL, start, stats, err)
+}
+
+// Runs a statement on behalf of a user in a new transaction and writes its result.
+// What the statement did is collected in stats. The statement is cancelled
+// if the client goes away before it finishes.
+func (hs *HTTPServer) execute(w http.ResponseWriter, r *http.Request, cmd string, user *metadata.UserInfo, stats *statementStats) (err error) {
+	tx, err := hs.db.BeginTx()
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+		return err
+	}
+	streaming := false
+
+	if hs.db.Config() != nil {
+		tx.SetTempQuota(int64(hs.db.Config().TempQuota))
+	}
+
+	pins := tx.BlocksPinned()
+	defer func() {
+		stats.pins = tx.BlocksPinned() - pins
+		stats.temp = tx.TempSpaceUsed()
+	}()
+
+	stop := context.AfterFunc(r.Context(), tx.Cancel)
+	defer stop()
+
+	// The planners and scans panic on some invalid input,
+	// which must not take down the whole server
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			err = fmt.Errorf("statement failed: %w", panicError(r))
+			if streaming {
+				fmt.Fprintf(w, `],"error":%s}`, mustMarshal(err.Error()))
+			} else {
+				writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			}
+		}
+	}()
+
+	if IsQuery(cmd) {
+		hs.queries.Add(1)
+
+		p, err := hs.db.Planner().CreateQueryPlanAs(cmd, user, tx)
+		if err != nil {
+			tx.Rollback()
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return err
+		}
+		if hs.db.slowLog != nil {
+			p = stats.countRows(p)
+		}
+
+		streaming = true
+		err = streamResults(w, p)
+		tx.Commit()
+		return err
+	}
+
+	hs.updates.Add(1)
+
+	count, err := hs.db.Planner().ExecuteUpdateAs(cmd, user, tx)
+	stats.rows = count
+	if err != nil {
+		tx.Rollback()
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return err
+	}
+	lastInsertID := tx.LastInsertID()
+	tx.Commit()
+
+	writeJSON(w, http.StatusOK, updateResponse{Affected: count, LastInsertID: lastInsertID})
+	return nil
+}
+
+// Writes the records of a query plan as a JSON object,
+// flushing the response every HTTP_FLUSH_ROWS rows
+func streamResults(w http.ResponseWriter, p interfaces.Plan) error {
+	scan := p.Open()
+	defer scan.Close()
+
+	sch := scan.Schema()
+	fields := sch.Fields()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	if _, err := fmt.Fprintf(w, `{"columns":%s,"rows":[`, mustMarshal(fields)); err != nil {
+		return err
+	}
+
+	count := 0
+	values := make([]any, len(fields))
+	for scan.Next() {
+		for i, fieldName := range fields {
+			values[i] = plan.FieldValue(scan, fieldName, sch.DataType(fieldName))
+		}
+
+		if count > 0 {
+			io.WriteString(w, ",")
+		}
+		if _, err := w.Write(mustMarshal(values)); err != nil {
+			return err
+		}
+
+		count++
+		if count%HTTP_FLUSH_ROWS == 0 && flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	_, err := fmt.Fprintf(w, `],"count":%d}`, count)
+	return err
+}
+
+func (hs *HTTPServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (hs *HTTPServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	if _, ok := hs.authenticate(w, r); !ok {
+		return
+	}
+
+	stats := statsResponse{
+		UptimeSeconds:    int64(time.Since(hs.startTime).Seconds()),
+		Queries:          hs.queries.Load(),
+		Updates:          hs.updates.Load(),
+		Errors:           hs.errors.Load(),
+		BuffersAvailable: hs.db.BufferMgr().Available(),
+		Relations:        []relationStat{},
+	}
+
+	tx, err := hs.db.BeginReadOnlyTx()
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+		return
+	}
+	sizes, err := hs.db.MdMgr().RelationSizes(tx)
+	tx.Commit()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+
+	for _, size := range sizes {
+		stats.Relations = append(stats.Relations, relationStat{
+			Name:   size.Name,
+			Kind:   size.Kind,
+			Blocks: size.Blocks,
+			Rows:   size.Rows,
+			Bytes:  size.Bytes,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Marshals values that are known to be encodable, i.e. strings and ints
+func mustMarshal(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(errors.New("failed to encode value: " + err.Error()))
+	}
+	return data
+}
@@ -0,0 +1,555 @@
+package server
+
+import (
+	"bufio"
+	"centauri/internal/app/record/schema"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Wire protocol
+//
+// Every message is framed as a one byte message type followed by a
+// 4 byte big-endian payload length and the payload itself:
+//
+//	+------+----------------+-----------------+
+//	| type | length (int32) | payload (bytes) |
+//	+------+----------------+-----------------+
+//
+// Inside a payload, integers are 4 byte big-endian values and strings are
+// a 4 byte length followed by the UTF-8 bytes.
+//
+// A client first sends MSG_AUTH with a user name and password. The server
+// answers with MSG_AUTH_OK holding the session id and cancel key, or with
+// MSG_ERROR and closes the connection if the credentials are wrong.
+// Statements sent before that are refused.
+//
+// A client sends MSG_QUERY with the SQL text of a single statement.
+// The server answers a query with MSG_ROW_DESCRIPTION, one MSG_DATA_ROW per
+// record and a final MSG_COMMAND_COMPLETE holding the number of rows sent.
+// Any other statement is answered with MSG_COMMAND_COMPLETE holding the
+// number of affected records. MSG_COMMAND_COMPLETE also holds an 8 byte last
+// insert id: the first AUTO_INCREMENT value generated by the last insert of
+// the session that generated one, 0 if none did or for a query. A failed statement is answered with MSG_ERROR,
+// which may also arrive after part of a result set has been sent.
+// MSG_TERMINATE closes the connection.
+//
+// Each statement runs in a transaction of its own that commits once it
+// completes, unless the client starts one with a BEGIN statement. The
+// statements that follow then run in that transaction until a COMMIT or
+// ROLLBACK statement ends it, or the connection closes, which rolls it back.
+//
+// A large result can be read in batches through a cursor instead, so
+// neither side has to hold more than a batch in memory. MSG_OPEN_CURSOR
+// names a cursor and holds the SQL text of a query, and is answered with
+// MSG_ROW_DESCRIPTION. Each MSG_FETCH names the cursor and the number of
+// records wanted, and is answered with up to that many MSG_DATA_ROW followed
+// by MSG_PORTAL_SUSPENDED if more records may follow, or by
+// MSG_COMMAND_COMPLETE holding the total number of records fetched once
+// the result is exhausted, which also closes the cursor. MSG_CLOSE_CURSOR
+// closes a cursor early and is answered with MSG_COMMAND_COMPLETE.
+//
+// Tools introspect the database with MSG_METADATA, holding one of the
+// METADATA_* request kinds and, for METADATA_DESCRIBE_TABLE, a table name.
+// It is answered with MSG_METADATA_RESULT holding a VersionInfo, a list of
+// TableInfo or a TableDesc respectively, or with MSG_ERROR.
+//
+// To cancel a running statement, a client opens a second connection and
+// sends MSG_CANCEL with the session id and cancel key, without logging in.
+// The statement fails with "query cancelled" and the server closes the second
+// connection without answering. A cancel request with a wrong key is ignored.
+const (
+	MSG_AUTH             byte = 'A'
+	MSG_AUTH_OK          byte = 'R'
+	MSG_QUERY            byte = 'Q'
+	MSG_CANCEL           byte = 'K'
+	MSG_OPEN_CURSOR      byte = 'O'
+	MSG_FETCH            byte = 'F'
+	MSG_CLOSE_CURSOR     byte = 'L'
+	MSG_PORTAL_SUSPENDED byte = 's'
+	MSG_METADATA         byte = 'M'
+	MSG_METADATA_RESULT  byte = 'm'
+	MSG_TERMINATE        byte = 'X'
+	MSG_ROW_DESCRIPTION  byte = 'T'
+	MSG_DATA_ROW         byte = 'D'
+	MSG_COMMAND_COMPLETE byte = 'C'
+	MSG_ERROR            byte = 'E'
+)
+
+// Kinds of MSG_METADATA requests
+const (
+	METADATA_SERVER_VERSION = 1
+	METADATA_LIST_TABLES    = 2
+	METADATA_DESCRIBE_TABLE = 3
+)
+
+// The largest payload accepted from the other side of a connection
+const MAX_MESSAGE_SIZE = 1 << 24
+
+var ErrMessageTooLarge = errors.New("message exceeds maximum size")
+
+// Describes a single column of a result set
+type ColumnDesc struct {
+	Name string
+	Type schema.FieldType
+}
+
+// Writes a single framed message
+func WriteMessage(w io.Writer, msgType byte, payload []byte) error {
+	header := make([]byte, 5)
+	header[0] = msgType
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// Reads a single framed message, returning its type and payload
+func ReadMessage(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > MAX_MESSAGE_SIZE {
+		return 0, nil, fmt.Errorf("%w: %d bytes", ErrMessageTooLarge, length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// Encodes the column names and types of a result set
+func EncodeRowDescription(cols []ColumnDesc) []byte {
+	buf := appendInt(nil, len(cols))
+	for _, col := range cols {
+		buf = appendString(buf, col.Name)
+		buf = appendInt(buf, int(col.Type))
+	}
+	return buf
+}
+
+func DecodeRowDescription(payload []byte) ([]ColumnDesc, error) {
+	d := &decoder{buf: payload}
+	n := d.int()
+
+	cols := make([]ColumnDesc, 0)
+	for i := 0; i < n && d.err == nil; i++ {
+		name := d.string()
+		fieldType := schema.FieldType(d.int())
+		cols = append(cols, ColumnDesc{Name: name, Type: fieldType})
+	}
+	return cols, d.err
+}
+
+// The type a value of a record is sent with when it is null, in place of
+// the field type of its column
+const VALUE_NULL byte = 0
+
+// Encodes one record. The values must be in the same order as the
+// columns of the row description, and each value is either an int, an int64,
+// a float64, a string, a bool, a []byte or nil for a null. Each is sent as a one
+// byte type followed by the value, an int64 or float64 in 8 bytes, a bool as a
+// byte 1 or 0 and a []byte like a string.
+func EncodeDataRow(values []any) ([]byte, error) {
+	buf := appendInt(nil, len(values))
+	for _, val := range values {
+		switch v := val.(type) {
+		case nil:
+			buf = append(buf, VALUE_NULL)
+		case int:
+			buf = append(buf, byte(schema.INTEGER))
+			buf = appendInt(buf, v)
+		case int64:
+			buf = append(buf, byte(schema.BIGINT))
+			buf = binary.BigEndian.AppendUint64(buf, uint64(v))
+		case float64:
+			buf = append(buf, byte(schema.FLOAT))
+			buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+		case string:
+			buf = append(buf, byte(schema.VARCHAR))
+			buf = appendString(buf, v)
+		case []byte:
+			buf = append(buf, byte(schema.BLOB))
+			buf = appendString(buf, string(v))
+		case bool:
+			buf = append(buf, byte(schema.BOOLEAN))
+			if v {
+				buf = append(buf, 1)
+			} else {
+				buf = append(buf, 0)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported value type %T", val)
+		}
+	}
+	return buf, nil
+}
+
+func DecodeDataRow(payload []byte) ([]any, error) {
+	d := &decoder{buf: payload}
+	n := d.int()
+
+	values := make([]any, 0)
+	for i := 0; i < n && d.err == nil; i++ {
+		switch typ := d.byte(); {
+		case typ == VALUE_NULL:
+			values = append(values, nil)
+		case schema.FieldType(typ) == schema.INTEGER:
+			values = append(values, d.int())
+		case schema.FieldType(typ) == schema.BIGINT:
+			values = append(values, d.long())
+		case schema.FieldType(typ) == schema.FLOAT:
+			values = append(values, math.Float64frombits(uint64(d.long())))
+		case schema.FieldType(typ) == schema.VARCHAR:
+			values = append(values, d.string())
+		case schema.FieldType(typ) == schema.BOOLEAN:
+			values = append(values, d.byte() != 0)
+		case schema.FieldType(typ) == schema.BLOB:
+			values = append(values, []byte(d.string()))
+		default:
+			if d.err == nil {
+				d.err = fmt.Errorf("unknown value type in data row")
+			}
+		}
+	}
+	return values, d.err
+}
+
+// Encodes the credentials a client logs in with
+func EncodeAuth(userName string, password string) []byte {
+	buf := appendString(nil, userName)
+	return appendString(buf, password)
+}
+
+func DecodeAuth(payload []byte) (string, string, error) {
+	d := &decoder{buf: payload}
+	userName := d.string()
+	password := d.string()
+	return userName, password, d.err
+}
+
+// Encodes the session id and cancel key sent with MSG_AUTH_OK
+func EncodeAuthOK(sessionID int, cancelKey int) []byte {
+	buf := appendInt(nil, sessionID)
+	return appendInt(buf, cancelKey)
+}
+
+func DecodeAuthOK(payload []byte) (int, int, error) {
+	d := &decoder{buf: payload}
+	sessionID := d.int()
+	cancelKey := d.int()
+	return sessionID, cancelKey, d.err
+}
+
+// Encodes a request to cancel the statement running in a session.
+// The payload is the same as that of MSG_AUTH_OK.
+func EncodeCancel(sessionID int, cancelKey int) []byte {
+	return EncodeAuthOK(sessionID, cancelKey)
+}
+
+func DecodeCancel(payload []byte) (int, int, error) {
+	return DecodeAuthOK(payload)
+}
+
+// Encodes the name of a cursor and the query it reads
+func EncodeOpenCursor(name string, cmd string) []byte {
+	buf := appendString(nil, name)
+	return appendString(buf, cmd)
+}
+
+func DecodeOpenCursor(payload []byte) (string, string, error) {
+	d := &decoder{buf: payload}
+	name := d.string()
+	cmd := d.string()
+	return name, cmd, d.err
+}
+
+// Encodes a request for the next count records of a cursor
+func EncodeFetch(name string, count int) []byte {
+	buf := appendString(nil, name)
+	return appendInt(buf, count)
+}
+
+func DecodeFetch(payload []byte) (string, int, error) {
+	d := &decoder{buf: payload}
+	name := d.string()
+	count := d.int()
+	return name, count, d.err
+}
+
+func EncodeCloseCursor(name string) []byte {
+	return appendString(nil, name)
+}
+
+func DecodeCloseCursor(payload []byte) (string, error) {
+	d := &decoder{buf: payload}
+	name := d.string()
+	return name, d.err
+}
+
+// Encodes a metadata request. arg is the table name for
+// METADATA_DESCRIBE_TABLE and empty otherwise.
+func EncodeMetadataRequest(kind int, arg string) []byte {
+	buf := appendInt(nil, kind)
+	return appendString(buf, arg)
+}
+
+func DecodeMetadataRequest(payload []byte) (int, string, error) {
+	d := &decoder{buf: payload}
+	kind := d.int()
+	arg := d.string()
+	return kind, arg, d.err
+}
+
+func EncodeVersionInfo(info VersionInfo) []byte {
+	buf := appendString(nil, info.Server)
+	buf = appendInt(buf, info.Catalog)
+	return appendInt(buf, info.Protocol)
+}
+
+func DecodeVersionInfo(payload []byte) (VersionInfo, error) {
+	d := &decoder{buf: payload}
+	info := VersionInfo{
+		Server:   d.string(),
+		Catalog:  d.int(),
+		Protocol: d.int(),
+	}
+	return info, d.err
+}
+
+func EncodeTableList(tables []TableInfo) []byte {
+	buf := appendInt(nil, len(tables))
+	for _, t := range tables {
+		buf = appendString(buf, t.Name)
+		buf = appendString(buf, t.Kind)
+	}
+	return buf
+}
+
+func DecodeTableList(payload []byte) ([]TableInfo, error) {
+	d := &decoder{buf: payload}
+	n := d.int()
+
+	tables := make([]TableInfo, 0)
+	for i := 0; i < n && d.err == nil; i++ {
+		name := d.string()
+		kind := d.string()
+		tables = append(tables, TableInfo{Name: name, Kind: kind})
+	}
+	return tables, d.err
+}
+
+func EncodeTableDesc(desc TableDesc) []byte {
+	buf := appendString(nil, desc.Name)
+	buf = appendString(buf, desc.Kind)
+
+	buf = appendInt(buf, len(desc.Columns))
+	for _, col := range desc.Columns {
+		buf = appendString(buf, col.Name)
+		buf = appendInt(buf, int(col.Type))
+		buf = appendInt(buf, col.Length)
+		buf = appendString(buf, col.Collation)
+		buf = appendString(buf, col.Generated)
+		stored := 0
+		if col.Stored {
+			stored = 1
+		}
+		buf = appendInt(buf, stored)
+		notNull := 0
+		if col.NotNull {
+			notNull = 1
+		}
+		buf = appendInt(buf, notNull)
+		buf = appendString(buf, col.Default)
+		autoIncrement := 0
+		if col.AutoIncrement {
+			autoIncrement = 1
+		}
+		buf = appendInt(buf, autoIncrement)
+	}
+
+	buf = appendInt(buf, len(desc.Indexes))
+	for _, idx := range desc.Indexes {
+		buf = appendString(buf, idx.Name)
+		buf = appendStrings(buf, idx.Fields)
+		buf = appendString(buf, idx.Type)
+		unique := 0
+		if idx.Unique {
+			unique = 1
+		}
+		buf = appendInt(buf, unique)
+	}
+
+	buf = appendInt(buf, len(desc.Constraints))
+	for _, c := range desc.Constraints {
+		buf = appendString(buf, c.Name)
+		buf = appendString(buf, c.Kind)
+		buf = appendStrings(buf, c.Fields)
+	}
+	buf = appendString(buf, desc.Definition)
+	return appendString(buf, desc.Partitions)
+}
+
+func DecodeTableDesc(payload []byte) (TableDesc, error) {
+	d := &decoder{buf: payload}
+	desc := TableDesc{
+		Name:        d.string(),
+		Kind:        d.string(),
+		Columns:     []ColumnInfo{},
+		Indexes:     []IndexDesc{},
+		Constraints: []ConstraintDesc{},
+	}
+
+	n := d.int()
+	for i := 0; i < n && d.err == nil; i++ {
+		name := d.string()
+		fieldType := schema.FieldType(d.int())
+		length := d.int()
+		collation := d.string()
+		generated := d.string()
+		stored := d.int() == 1
+		notNull := d.int() == 1
+		deflt := d.string()
+		autoIncrement := d.int() == 1
+		desc.Columns = append(desc.Columns, ColumnInfo{
+			Name:          name,
+			Type:          fieldType,
+			Length:        length,
+			Collation:     collation,
+			Generated:     generated,
+			Stored:        stored,
+			NotNull:       notNull,
+			Default:       deflt,
+			AutoIncrement: autoIncrement,
+		})
+	}
+
+	n = d.int()
+	for i := 0; i < n && d.err == nil; i++ {
+		name := d.string()
+		fields := d.strings()
+		indexType := d.string()
+		desc.Indexes = append(desc.Indexes, IndexDesc{Name: name, Fields: fields, Type: indexType, Unique: d.int() == 1})
+	}
+
+	n = d.int()
+	for i := 0; i < n && d.err == nil; i++ {
+		name := d.string()
+		kind := d.string()
+		desc.Constraints = append(desc.Constraints, ConstraintDesc{Name: name, Kind: kind, Fields: d.strings()})
+	}
+	desc.Definition = d.string()
+	desc.Partitions = d.string()
+	return desc, d.err
+}
+
+func EncodeCommandComplete(count int, lastInsertID int64) []byte {
+	return binary.BigEndian.AppendUint64(appendInt(nil, count), uint64(lastInsertID))
+}
+
+func DecodeCommandComplete(payload []byte) (int, int64, error) {
+	d := &decoder{buf: payload}
+	count := d.int()
+	lastInsertID := d.long()
+	return count, lastInsertID, d.err
+}
+
+// Returns a writer that buffers messages until Flush is called,
+// so that a result set isn't sent one small write at a time
+func newMessageWriter(w io.Writer) *bufio.Writer {
+	return bufio.NewWriter(w)
+}
+
+func appendInt(buf []byte, val int) []byte {
+	return binary.BigEndian.AppendUint32(buf, uint32(int32(val)))
+}
+
+func appendString(buf []byte, val string) []byte {
+	buf = appendInt(buf, len(val))
+	return append(buf, val...)
+}
+
+func appendStrings(buf []byte, vals []string) []byte {
+	buf = appendInt(buf, len(vals))
+	for _, val := range vals {
+		buf = appendString(buf, val)
+	}
+	return buf
+}
+
+// Reads values from a payload, remembering the first error so
+// callers can check once after decoding everything
+type decoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (d *decoder) need(n int) bool {
+	if d.err != nil {
+		return false
+	}
+	if n < 0 || d.pos+n > len(d.buf) {
+		d.err = io.ErrUnexpectedEOF
+		return false
+	}
+	return true
+}
+
+func (d *decoder) byte() byte {
+	if !d.need(1) {
+		return 0
+	}
+	b := d.buf[d.pos]
+	d.pos++
+	return b
+}
+
+func (d *decoder) int() int {
+	if !d.need(4) {
+		return 0
+	}
+	val := int(int32(binary.BigEndian.Uint32(d.buf[d.pos:])))
+	d.pos += 4
+	return val
+}
+
+func (d *decoder) long() int64 {
+	if !d.need(8) {
+		return 0
+	}
+	val := int64(binary.BigEndian.Uint64(d.buf[d.pos:]))
+	d.pos += 8
+	return val
+}
+
+func (d *decoder) string() string {
+	n := d.int()
+	if !d.need(n) {
+		return ""
+	}
+	s := string(d.buf[d.pos : d.pos+n])
+	d.pos += n
+	return s
+}
+
+func (d *decoder) strings() []string {
+	n := d.int()
+
+	vals := make([]string, 0)
+	for i := 0; i < n && d.err == nil; i++ {
+		vals = append(vals, d.string())
+	}
+	return vals
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Written to the data directory when the database closes with its row
+// counts saved to the table catalog. Without it, the row count changes
+// kept in memory may have been lost, and the rows are recounted on open.
+const ROW_COUNTS_FILE = "rowcounts.saved"
+
+// Writes the row count changes of committed transactions into the table
+// catalog in a transaction of its own. Called when no other transaction is
+// running, so it neither waits for catalog locks nor holds up writers.
+func (db *CentauriDB) saveRowCounts() {
+	if db.mdm == nil || db.cfg.StandbyLogDir != "" {
+		return
+	}
+	t := tx.NewTransaction(db.fm, db.lm, db.bm)
+	db.mdm.SaveRowCounts(t)
+	t.Commit()
+}
+
+// Records that the row counts in the table catalog are up to date
+func (db *CentauriDB) markRowCountsSaved() error {
+	path := filepath.Join(db.cfg.DataDir, ROW_COUNTS_FILE)
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Removes the mark left by markRowCountsSaved, returning whether it was there
+func (db *CentauriDB) takeRowCountsSaved() (bool, error) {
+	err := os.Remove(filepath.Join(db.cfg.DataDir, ROW_COUNTS_FILE))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,619 @@
+package server
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Names of the settings a session can change with SET
+const (
+	SETTING_ISOLATION_LEVEL = "isolation_level"
+	SETTING_SEARCH_SCHEMA   = "search_schema"
+	SETTING_LOCK_TIMEOUT    = "lock_timeout"
+	SETTING_TEMP_QUOTA      = "temp_quota"
+)
+
+// Isolation levels a session can run its transactions at
+const (
+	ISOLATION_SERIALIZABLE    = "serializable"
+	ISOLATION_REPEATABLE_READ = "repeatable read"
+	ISOLATION_READ_COMMITTED  = "read committed"
+	ISOLATION_SNAPSHOT        = "snapshot"
+)
+
+// The lock_timeout of a session whose transactions fail right away rather
+// than wait for a lock another transaction holds, see tx.NOWAIT
+const LOCK_TIMEOUT_NOWAIT = "nowait"
+
+// The transaction isolation level of each name
+var isolationLevels = map[string]tx.IsolationLevel{
+	ISOLATION_SERIALIZABLE:    tx.SERIALIZABLE,
+	ISOLATION_REPEATABLE_READ: tx.REPEATABLE_READ,
+	ISOLATION_READ_COMMITTED:  tx.READ_COMMITTED,
+	ISOLATION_SNAPSHOT:        tx.SNAPSHOT,
+}
+
+// Returns the name of an isolation level, accepting any case and
+// underscores for spaces, such as read_committed
+func isolationName(value string) (string, bool) {
+	name := strings.ReplaceAll(strings.ToLower(value), "_", " ")
+	_, ok := isolationLevels[name]
+	return name, ok
+}
+
+// The schema unqualified names are looked up in
+const DEFAULT_SCHEMA = "public"
+
+// The maximum length of a value shown by SHOW
+const MAX_SETTING_LENGTH = 100
+
+var (
+	ErrUnknownSetting     = errors.New("unknown setting")
+	ErrInvalidSetting     = errors.New("invalid value for setting")
+	ErrNoTransaction      = errors.New("no transaction in progress")
+	ErrTransactionStarted = errors.New("a transaction is already in progress")
+	ErrUnknownStatement   = errors.New("unknown prepared statement")
+)
+
+var nextSessionID atomic.Int64
+
+// Settings of a session, applied to every transaction it starts
+type Settings struct {
+	IsolationLevel string        // isolation level of the session's transactions
+	SearchSchema   string        // schema unqualified names are looked up in
+	LockTimeout    time.Duration // how long a transaction waits for a lock before aborting
+	TempQuota      int64         // most bytes the temp tables of a statement may take, no limit if 0
+}
+
+// Result of a statement executed by a session.
+// Plan is set for queries; Count holds the number of records
+// affected by an update and is 0 for other statements.
+// LastInsertID is the first AUTO_INCREMENT value generated by the
+// last insert of the session that generated one, 0 if none did.
+type Result struct {
+	Plan         interfaces.Plan
+	Count        int
+	LastInsertID int64
+}
+
+// Session holds the state of a single client connection: the user it is
+// logged in as, the database it works in, its settings, the transaction it
+// has explicitly started, if any, its prepared statements and open cursors.
+// A session isn't safe for concurrent use.
+type Session struct {
+	id           int64
+	cancelKey    int                // secret a client must present to cancel the session's statements
+	root         *CentauriDB        // default database of the instance, which users log in to
+	db           *CentauriDB        // database selected with USE
+	user         *metadata.UserInfo // nil until Authenticate succeeds, runs as the engine itself
+	tx           *tx.Transaction    // transaction started with Begin, nil when autocommitting
+	settings     Settings
+	prepared     map[string]string  // prepared statements by name
+	cursors      map[string]*Cursor // open cursors by name
+	lastInsertID int64              // first AUTO_INCREMENT value generated by the last insert that generated one
+
+	running atomic.Pointer[tx.Transaction] // transaction of the statement being executed, if any
+}
+
+// Creates a session with the default settings of the database
+func NewSession(db *CentauriDB) *Session {
+	settings := Settings{
+		IsolationLevel: ISOLATION_SERIALIZABLE,
+		SearchSchema:   DEFAULT_SCHEMA,
+		LockTimeout:    tx.MaxWaitTime,
+	}
+	if db.Config() != nil {
+		settings.LockTimeout = db.Config().LockTimeout
+		settings.TempQuota = int64(db.Config().TempQuota)
+	}
+
+	return &Session{
+		id:        nextSessionID.Add(1),
+		cancelKey: newCancelKey(),
+		root:      db,
+		db:        db,
+		settings:  settings,
+		prepared:  make(map[string]string),
+		cursors:   make(map[string]*Cursor),
+	}
+}
+
+func (s *Session) ID() int64 {
+	return s.id
+}
+
+// Returns the secret that identifies the session in a cancel request
+func (s *Session) CancelKey() int {
+	return s.cancelKey
+}
+
+// Returns a random, non-negative cancel key
+func newCancelKey() int {
+	var b [4]byte
+	rand.Read(b[:])
+	return int(binary.BigEndian.Uint32(b[:]) >> 1)
+}
+
+func (s *Session) Settings() Settings {
+	return s.settings
+}
+
+// Logs the session in as a user. Statements executed afterwards
+// run with the user's privileges.
+func (s *Session) Authenticate(userName string, password string) error {
+	user, err := s.root.Authenticate(userName, password)
+	if err != nil {
+		return err
+	}
+	s.user = &user
+	return nil
+}
+
+// Returns the user the session is logged in as, or nil if it hasn't authenticated
+func (s *Session) User() *metadata.UserInfo {
+	return s.user
+}
+
+// Returns the database the session works in
+func (s *Session) Database() *CentauriDB {
+	return s.db
+}
+
+// Makes the session work in another database from its next statement on.
+// Not allowed while a transaction started with Begin is in progress.
+func (s *Session) Use(dbName string) error {
+	if s.tx != nil {
+		return ErrTransactionStarted
+	}
+
+	db, err := s.root.Database(dbName)
+	if err != nil {
+		return err
+	}
+	s.db = db
+	return nil
+}
+
+// Creates a new database, which only superusers may do
+func (s *Session) CreateDatabase(dbName string) error {
+	if s.user != nil && !s.user.Superuser {
+		return fmt.Errorf("%w: only superusers may create databases", plan.ErrPermissionDenied)
+	}
+	return s.root.CreateDatabase(dbName)
+}
+
+// Changes a setting of the session. Transactions that are
+// already running keep the settings they started with.
+func (s *Session) Set(name string, value string) error {
+	switch name {
+	case SETTING_ISOLATION_LEVEL:
+		level, ok := isolationName(value)
+		if !ok {
+			return fmt.Errorf("%w %s: %q", ErrInvalidSetting, name, value)
+		}
+		s.settings.IsolationLevel = level
+
+	case SETTING_SEARCH_SCHEMA:
+		if value == "" {
+			return fmt.Errorf("%w %s: %q", ErrInvalidSetting, name, value)
+		}
+		s.settings.SearchSchema = value
+
+	case SETTING_LOCK_TIMEOUT:
+		timeout, err := parseLockTimeout(value)
+		if err != nil {
+			return fmt.Errorf("%w %s: %q", ErrInvalidSetting, name, value)
+		}
+		s.settings.LockTimeout = timeout
+
+	case SETTING_TEMP_QUOTA:
+		quota, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || quota < 0 {
+			return fmt.Errorf("%w %s: %q", ErrInvalidSetting, name, value)
+		}
+		s.settings.TempQuota = quota
+
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownSetting, name)
+	}
+	return nil
+}
+
+// Returns the value of a setting of the session
+func (s *Session) Show(name string) (string, error) {
+	switch name {
+	case SETTING_ISOLATION_LEVEL:
+		return s.settings.IsolationLevel, nil
+	case SETTING_SEARCH_SCHEMA:
+		return s.settings.SearchSchema, nil
+	case SETTING_LOCK_TIMEOUT:
+		if s.settings.LockTimeout == tx.NOWAIT {
+			return LOCK_TIMEOUT_NOWAIT, nil
+		}
+		return s.settings.LockTimeout.String(), nil
+	case SETTING_TEMP_QUOTA:
+		return strconv.FormatInt(s.settings.TempQuota, 10), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownSetting, name)
+	}
+}
+
+// Parses a lock timeout like parseTimeout, or nowait or 0 for tx.NOWAIT
+func parseLockTimeout(value string) (time.Duration, error) {
+	if strings.EqualFold(value, LOCK_TIMEOUT_NOWAIT) || value == "0" {
+		return tx.NOWAIT, nil
+	}
+	return parseTimeout(value)
+}
+
+// Parses a timeout given either as a duration such as "5s"
+// or as a number of milliseconds
+func parseTimeout(value string) (time.Duration, error) {
+	if ms, err := strconv.Atoi(value); err == nil {
+		value = strconv.Itoa(ms) + "ms"
+	}
+
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+	return timeout, nil
+}
+
+// Starts a transaction that lasts until Commit or Rollback.
+// Until then, every statement the session executes runs in it.
+func (s *Session) Begin() error {
+	return s.BeginWithIsolation(s.settings.IsolationLevel)
+}
+
+// Starts a transaction like Begin, at the given isolation level
+// instead of the session's
+func (s *Session) BeginWithIsolation(level string) error {
+	if s.tx != nil {
+		return ErrTransactionStarted
+	}
+	name, ok := isolationName(level)
+	if !ok {
+		return fmt.Errorf("%w %s: %q", ErrInvalidSetting, SETTING_ISOLATION_LEVEL, level)
+	}
+
+	t, err := s.newTxWithIsolation(name)
+	if err != nil {
+		return err
+	}
+	s.tx = t
+	return nil
+}
+
+// Commits the transaction started with Begin, closing the cursors opened in it
+func (s *Session) Commit() error {
+	if s.tx == nil {
+		return ErrNoTransaction
+	}
+	s.closeCursors(true, true)
+	s.tx.Commit()
+	s.tx = nil
+	return nil
+}
+
+// Rolls back the transaction started with Begin, closing the cursors opened in it
+func (s *Session) Rollback() error {
+	if s.tx == nil {
+		return ErrNoTransaction
+	}
+	s.closeCursors(true, false)
+	s.tx.Rollback()
+	s.tx = nil
+	return nil
+}
+
+// Returns true if a transaction started with Begin is in progress
+func (s *Session) InTransaction() bool {
+	return s.tx != nil
+}
+
+// Saves a statement under a name so it can be executed later
+func (s *Session) Prepare(name string, sql string) {
+	s.prepared[name] = sql
+}
+
+// Returns the statement prepared under name
+func (s *Session) Prepared(name string) (string, error) {
+	sql, ok := s.prepared[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownStatement, name)
+	}
+	return sql, nil
+}
+
+// Removes the statement prepared under name
+func (s *Session) Deallocate(name string) error {
+	if _, ok := s.prepared[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownStatement, name)
+	}
+	delete(s.prepared, name)
+	return nil
+}
+
+// Cancels the statement the session is executing, which then fails with
+// tx.ErrQueryCancelled. Does nothing if no statement is running. Unlike the
+// other methods, Cancel may be called from any goroutine.
+func (s *Session) Cancel() {
+	if t := s.running.Load(); t != nil {
+		t.Cancel()
+	}
+}
+
+// Ends the session, closing its cursors and rolling back
+// the transaction started with Begin if any
+func (s *Session) Close() {
+	s.closeCursors(false, false)
+	if s.tx != nil {
+		s.tx.Rollback()
+		s.tx = nil
+	}
+}
+
+// Runs fn in the transaction started with Begin, or otherwise
+// in a transaction of its own that commits once fn returns
+func (s *Session) inTx(fn func(t *tx.Transaction) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	t, err := s.newTx()
+	if err != nil {
+		return err
+	}
+	defer t.Commit()
+	return fn(t)
+}
+
+// Starts a transaction with the session's settings
+func (s *Session) newTx() (*tx.Transaction, error) {
+	return s.newTxWithIsolation(s.settings.IsolationLevel)
+}
+
+// Starts a transaction with the session's settings, at the named isolation level
+func (s *Session) newTxWithIsolation(level string) (*tx.Transaction, error) {
+	t, err := s.db.BeginTxWithIsolation(isolationLevels[level])
+	if err != nil {
+		return nil, err
+	}
+	t.SetLockTimeout(s.settings.LockTimeout)
+	t.SetTempQuota(s.settings.TempQuota)
+	return t, nil
+}
+
+// Executes a statement and passes its result to handle. Queries and
+// updates run in the transaction started with Begin, or otherwise in a
+// transaction of their own that commits once handle returns, so the
+// records of a query can be read inside handle.
+//
+// If the statement fails its own transaction is rolled back, while a
+// transaction started with Begin stays open unless the statement panicked.
+// The statement is recorded in the audit log and, if it runs too long,
+// in the slow query log, if there are ones.
+func (s *Session) Execute(cmd string, handle func(*Result) error) error {
+	start := time.Now()
+	stats := &statementStats{}
+	err := s.execute(cmd, handle, stats)
+	s.audit(cmd, start, stats.rows, err)
+	s.root.logSlowQuery(s.userName(), s.db.Name(), cmd, start, stats, err)
+	return err
+}
+
+// Records a statement of the session in the audit log
+func (s *Session) audit(cmd string, start time.Time, count int, err error) {
+	s.root.audit(s.userName(), s.db.Name(), cmd, start, count, err)
+}
+
+// Returns the name of the user the session is logged in as, empty if none
+func (s *Session) userName() string {
+	if s.user == nil {
+		return ""
+	}
+	return s.user.Name
+}
+
+func (s *Session) execute(cmd string, handle func(*Result) error, stats *statementStats) (err error) {
+	if IsSessionCmd(cmd) {
+		return s.executeSessionCmd(cmd, handle)
+	}
+
+	t := s.tx
+	autocommit := t == nil
+	if autocommit {
+		if t, err = s.newTx(); err != nil {
+			return err
+		}
+	} else {
+		// A cancel that arrived after the previous statement
+		// finished must not affect this one
+		t.ResetCancel()
+		t.ResetTempSpace()
+	}
+
+	s.running.Store(t)
+	defer s.running.Store(nil)
+
+	pins := t.BlocksPinned()
+	defer func() {
+		stats.pins = t.BlocksPinned() - pins
+		stats.temp = t.TempSpaceUsed()
+	}()
+
+	// The planners and scans panic on some invalid input, and scans
+	// panic when the statement is cancelled, which must not end the session
+	defer func() {
+		if r := recover(); r != nil {
+			err = s.abort(t, autocommit, r)
+		}
+	}()
+
+	result := &Result{}
+	if IsQuery(cmd) {
+		result.Plan, err = s.db.Planner().CreateQueryPlanAs(cmd, s.user, t)
+	} else {
+		result.Count, err = s.db.Planner().ExecuteUpdateAs(cmd, s.user, t)
+		if id := t.LastInsertID(); err == nil && id != 0 {
+			s.lastInsertID = id
+		}
+		result.LastInsertID = s.lastInsertID
+	}
+	if err != nil {
+		if autocommit {
+			t.Rollback()
+		}
+		return err
+	}
+
+	if result.Plan != nil && s.root.slowLog != nil {
+		result.Plan = stats.countRows(result.Plan)
+	}
+	stats.rows += result.Count
+
+	err = handle(result)
+	if autocommit {
+		t.Commit()
+	}
+	return err
+}
+
+// Returns the value a statement panicked with as an error,
+// so errors such as tx.ErrQueryCancelled can be told apart
+func panicError(r any) error {
+	if err, ok := r.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", r)
+}
+
+// Executes a SET, SHOW, DESCRIBE, USE or CREATE DATABASE command, or a
+// BEGIN, COMMIT or ROLLBACK controlling the session's transaction. A SHOW
+// command returns a single record holding the setting's value, SHOW
+// DATABASES a record for each database, SHOW TABLES a record for each table
+// and view, and DESCRIBE a record for each column of the table.
+func (s *Session) executeSessionCmd(cmd string, handle func(*Result) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("statement failed: %v", r)
+		}
+	}()
+
+	obj, err := parse.NewParser(cmd).SessionCmd()
+	if err != nil {
+		return err
+	}
+
+	switch data := obj.(type) {
+	case *parse.SetData:
+		if err := s.Set(data.Name(), data.Value()); err != nil {
+			return err
+		}
+		return handle(&Result{})
+
+	case *parse.ShowData:
+		value, err := s.Show(data.Name())
+		if err != nil {
+			return err
+		}
+
+		sch := schema.NewSchema()
+		sch.AddStringField(data.Name(), MAX_SETTING_LENGTH)
+		row := map[string]*types.Constant{data.Name(): types.NewConstantString(value)}
+		return handle(&Result{Plan: plan.NewRowsPlan(sch, []map[string]*types.Constant{row})})
+
+	case *parse.ShowDatabasesData:
+		names, err := s.root.DatabaseNames()
+		if err != nil {
+			return err
+		}
+
+		sch := schema.NewSchema()
+		sch.AddStringField("database", MAX_DATABASE_NAME)
+		rows := make([]map[string]*types.Constant, len(names))
+		for i, name := range names {
+			rows[i] = map[string]*types.Constant{"database": types.NewConstantString(name)}
+		}
+		return handle(&Result{Plan: plan.NewRowsPlan(sch, rows)})
+
+	case *parse.ShowTablesData:
+		return s.inTx(func(t *tx.Transaction) error {
+			return handle(&Result{Plan: s.db.tablesPlan(t)})
+		})
+
+	case *parse.DescribeData:
+		return s.inTx(func(t *tx.Transaction) error {
+			p, err := s.db.describePlan(data.TableName(), t)
+			if err != nil {
+				return err
+			}
+			return handle(&Result{Plan: p})
+		})
+
+	case *parse.UseData:
+		if err := s.Use(data.DatabaseName()); err != nil {
+			return err
+		}
+		return handle(&Result{})
+
+	case *parse.CreateDatabaseData:
+		if err := s.CreateDatabase(data.DatabaseName()); err != nil {
+			return err
+		}
+		return handle(&Result{})
+
+	case *parse.BeginData:
+		level := data.IsolationLevel()
+		if level == "" {
+			level = s.settings.IsolationLevel
+		}
+		if err := s.BeginWithIsolation(level); err != nil {
+			return err
+		}
+		return handle(&Result{})
+
+	case *parse.CommitData:
+		if err := s.Commit(); err != nil {
+			return err
+		}
+		return handle(&Result{})
+
+	case *parse.RollbackData:
+		if err := s.Rollback(); err != nil {
+			return err
+		}
+		return handle(&Result{})
+	}
+	return nil
+}
+
+// Returns true if the statement changes or shows a session setting,
+// lists the tables or describes one, creates or selects a database,
+// or controls the session's transaction
+func IsSessionCmd(cmd string) bool {
+	lexer := parse.NewLexer(cmd)
+	if lexer.MatchKeyword("create") {
+		lexer.EatKeyword("create")
+		return lexer.MatchKeyword("database")
+	}
+	for _, keyword := range []string{"set", "show", "describe", "desc", "use", "begin", "start", "commit", "rollback"} {
+		if lexer.MatchKeyword(keyword) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/plan"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// What was measured while a statement ran, for the slow query log
+type statementStats struct {
+	plan interfaces.Plan // plan of a query, nil for other statements
+	rows int             // records a query returned, or an update affected
+	pins int             // blocks pinned by the statement
+	temp int64           // bytes written to temp tables by the statement
+}
+
+// Wraps the plan of a query so the records read from it are counted
+func (st *statementStats) countRows(p interfaces.Plan) interfaces.Plan {
+	st.plan = p
+	return &countingPlan{Plan: p, rows: &st.rows}
+}
+
+type countingPlan struct {
+	interfaces.Plan
+	rows *int
+}
+
+func (cp *countingPlan) Open() interfaces.Scan {
+	return &countingScan{Scan: cp.Plan.Open(), rows: cp.rows}
+}
+
+type countingScan struct {
+	interfaces.Scan
+	rows *int
+}
+
+func (cs *countingScan) Next() bool {
+	if !cs.Scan.Next() {
+		return false
+	}
+	*cs.rows++
+	return true
+}
+
+// SlowQueryLog records the statements that take at least a threshold
+// to run, with the plan of queries and the number of blocks pinned, so
+// the statements worth tuning can be found.
+type SlowQueryLog struct {
+	mu        sync.Mutex
+	w         io.Writer
+	file      *os.File // nil when writing to standard output
+	threshold time.Duration
+}
+
+// Opens the slow query log at path, creating it if it doesn't exist.
+// Statements are written to standard output if path is empty.
+func OpenSlowQueryLog(path string, threshold time.Duration) (*SlowQueryLog, error) {
+	if path == "" {
+		return &SlowQueryLog{w: os.Stdout, threshold: threshold}, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open slow query log: %w", err)
+	}
+	return &SlowQueryLog{w: f, file: f, threshold: threshold}, nil
+}
+
+// Returns the duration from which statements are recorded
+func (sl *SlowQueryLog) Threshold() time.Duration {
+	return sl.threshold
+}
+
+// Records a statement if it ran for at least the threshold. An entry
+// looks like:
+//
+//	# 2025-01-02T15:04:05Z user=alice database=main duration=1.5s rows=3 pins=1200 temp=0 success=true
+//	select name from students where id = 7
+//	project name (blocks=4 records=3)
+//	  select id = 7 (blocks=4 records=3)
+//	    table students (blocks=4 records=100)
+func (sl *SlowQueryLog) Record(user string, database string, cmd string, start time.Time, stats *statementStats, err error) error {
+	duration := time.Since(start)
+	if duration < sl.threshold {
+		return nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s user=%s database=%s duration=%s rows=%d pins=%d temp=%d success=%t\n",
+		start.Format(time.RFC3339), user, database, duration, stats.rows, stats.pins, stats.temp, err == nil)
+	fmt.Fprintln(&sb, strings.TrimSpace(cmd))
+	if err != nil {
+		fmt.Fprintf(&sb, "error: %v\n", err)
+	}
+	if stats.plan != nil {
+		sb.WriteString(explainPlan(stats.plan))
+	}
+	sb.WriteString("\n")
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	if _, err := io.WriteString(sl.w, sb.String()); err != nil {
+		return fmt.Errorf("failed to write slow query log: %w", err)
+	}
+	return nil
+}
+
+// Explains a plan whose statement has already finished. Estimating
+// the cost of some plans reads the catalog, which may fail by then.
+func explainPlan(p interfaces.Plan) (s string) {
+	defer func() {
+		if r := recover(); r != nil {
+			s = fmt.Sprintf("plan unavailable: %v\n", r)
+		}
+	}()
+	return plan.Explain(p)
+}
+
+func (sl *SlowQueryLog) Close() error {
+	if sl.file == nil {
+		return nil
+	}
+	return sl.file.Close()
+}
+
+// Records a statement in the slow query log, if the database keeps one
+func (db *CentauriDB) logSlowQuery(user string, database string, cmd string, start time.Time, stats *statementStats, err error) {
+	if db.slowLog == nil {
+		return
+	}
+	if err := db.slowLog.Record(user, database, cmd, start, stats, err); err != nil {
+		fmt.Printf("warning: %v\n", err)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"centauri/internal/app/file"
+	"centauri/internal/app/log"
+	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// The file in a standby's data directory recording where
+// applying the shipped log resumes after a restart
+const STANDBY_POSITION_FILE = "standby.pos"
+
+// Returned when a standby is started on an empty data directory
+var ErrNoBaseBackup = errors.New("a standby must be started on a copy of the primary's data directory")
+
+// Standby keeps a read-only copy of a primary database up to date. The
+// primary's log is shipped to a directory of the standby, by copying the
+// log file or from shared storage, and the standby continuously applies
+// the transactions that commit in it to its own data files, while serving
+// read-only transactions.
+//
+// The data directory must start out as a copy of the primary's, taken
+// while the primary was shut down. Changes the primary doesn't log, such
+// as those of a COPY FROM, only reach the standby with a new copy.
+type Standby struct {
+	db      *CentauriDB
+	fm      *file.FileManager // file manager for the directory the log is shipped to
+	posPath string
+
+	mu      sync.Mutex // serializes Apply
+	applier *tx.LogApplier
+	saved   log.LogPosition // position last saved to posPath
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Creates a standby that applies the log shipped to cfg.StandbyLogDir,
+// resuming from the position saved in the data directory
+func newStandby(db *CentauriDB) (*Standby, error) {
+	fm, err := file.NewFileManager(db.cfg.StandbyLogDir, db.cfg.BlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shipped log directory: %w", err)
+	}
+
+	s := &Standby{
+		db:      db,
+		fm:      fm,
+		posPath: filepath.Join(db.cfg.DataDir, STANDBY_POSITION_FILE),
+	}
+
+	pos, err := s.loadPosition()
+	if err != nil {
+		fm.Close()
+		return nil, err
+	}
+	s.applier = s.newApplier(pos)
+	s.saved = pos
+	return s, nil
+}
+
+func (s *Standby) newApplier(pos log.LogPosition) *tx.LogApplier {
+	return tx.NewLogApplier(log.NewLogReader(s.fm, s.db.cfg.LogFile, pos))
+}
+
+// Applies the transactions that committed in the shipped log since the
+// last call and returns how many were applied. After a failure, the
+// next call retries from the last position that was saved.
+func (s *Standby) Apply() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	applied, err := s.applier.Apply(s.db.NewTx)
+	if pos := s.applier.ResumePosition(); pos != s.saved {
+		if saveErr := s.savePosition(pos); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+	if err != nil {
+		s.applier = s.newApplier(s.applier.ResumePosition())
+	}
+	return applied, err
+}
+
+// Applies the shipped log every poll interval until close is called
+func (s *Standby) start(poll time.Duration) {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.Apply(); err != nil {
+				fmt.Printf("standby: %v\n", err)
+			}
+
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stops applying the shipped log and closes its directory
+func (s *Standby) close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+	return s.fm.Close()
+}
+
+// Reads the saved position, the start of the log if there's none
+func (s *Standby) loadPosition() (log.LogPosition, error) {
+	var pos log.LogPosition
+
+	data, err := os.ReadFile(s.posPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return pos, nil
+	}
+	if err != nil {
+		return pos, fmt.Errorf("failed to read standby position: %w", err)
+	}
+
+	if _, err := fmt.Sscanf(string(data), "%d %d", &pos.Block, &pos.Index); err != nil {
+		return pos, fmt.Errorf("invalid standby position in %s: %w", s.posPath, err)
+	}
+	return pos, nil
+}
+
+// Saves the position by replacing the file, so a crash
+// leaves either the old position or the new one
+func (s *Standby) savePosition(pos log.LogPosition) error {
+	tmp := s.posPath + ".tmp"
+	data := fmt.Sprintf("%d %d\n", pos.Block, pos.Index)
+	if err := os.WriteFile(tmp, []byte(data), 0644); err != nil {
+		return fmt.Errorf("failed to save standby position: %w", err)
+	}
+	if err := os.Rename(tmp, s.posPath); err != nil {
+		return fmt.Errorf("failed to save standby position: %w", err)
+	}
+	s.saved = pos
+	return nil
+}
@@ -0,0 +1,408 @@
+package server
+
+import (
+	"centauri/config"
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record/schema"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+const DEFAULT_ADDR = config.DEFAULT_ADDR
+
+var (
+	ErrServerClosed           = errors.New("server closed")
+	ErrAuthenticationRequired = errors.New("authentication required")
+)
+
+// Accepts client connections over TCP and executes the statements they send.
+// Each connection is served by its own goroutine and must authenticate before
+// its statements are accepted. Each statement runs in its own transaction
+// which is committed once the statement succeeds.
+type TCPServer struct {
+	db       *CentauriDB
+	addr     string
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	sessions map[int]*Session // sessions of the open connections by id, for cancel requests
+	closed   bool
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+}
+
+func NewTCPServer(db *CentauriDB, addr string) *TCPServer {
+	if addr == "" {
+		addr = DEFAULT_ADDR
+	}
+	return &TCPServer{
+		db:       db,
+		addr:     addr,
+		conns:    make(map[net.Conn]struct{}),
+		sessions: make(map[int]*Session),
+	}
+}
+
+// Listens on the server's address and serves connections until Close is called
+func (s *TCPServer) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	return s.Serve(listener)
+}
+
+// Serves connections accepted from the listener until Close is called
+func (s *TCPServer) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+// Returns the address the server is listening on
+func (s *TCPServer) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stops accepting connections, closes the open ones
+// and waits for their handlers to finish
+func (s *TCPServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// Reads messages from a client until it disconnects or sends MSG_TERMINATE
+func (s *TCPServer) handleConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+
+		s.wg.Done()
+	}()
+
+	w := newMessageWriter(conn)
+
+	// Settings and transactions last as long as the connection
+	session := NewSession(s.db)
+	defer session.Close()
+
+	s.addSession(session)
+	defer s.removeSession(session)
+
+	for {
+		msgType, payload, err := ReadMessage(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("connection %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		switch msgType {
+		case MSG_AUTH:
+			if err = s.authenticate(w, session, payload); err != nil {
+				w.Flush()
+				log.Printf("connection %s: %v", conn.RemoteAddr(), err)
+				return
+			}
+		case MSG_CANCEL:
+			// The connection only carries the cancel request
+			s.cancel(payload)
+			return
+		case MSG_QUERY, MSG_OPEN_CURSOR, MSG_FETCH, MSG_CLOSE_CURSOR, MSG_METADATA:
+			if session.User() == nil {
+				err = WriteMessage(w, MSG_ERROR, []byte(ErrAuthenticationRequired.Error()))
+				break
+			}
+
+			switch msgType {
+			case MSG_QUERY:
+				err = s.execute(w, session, string(payload))
+			case MSG_OPEN_CURSOR:
+				err = s.openCursor(w, session, payload)
+			case MSG_FETCH:
+				err = s.fetch(w, session, payload)
+			case MSG_CLOSE_CURSOR:
+				err = s.closeCursor(w, session, payload)
+			case MSG_METADATA:
+				err = s.metadata(w, session, payload)
+			}
+		case MSG_TERMINATE:
+			return
+		default:
+			err = WriteMessage(w, MSG_ERROR, []byte(fmt.Sprintf("unknown message type %q", msgType)))
+		}
+
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			log.Printf("connection %s: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// Logs the connection's session in with the credentials of a MSG_AUTH message.
+// A failed attempt is reported to the client and returned, ending the connection.
+func (s *TCPServer) authenticate(w io.Writer, session *Session, payload []byte) error {
+	userName, password, err := DecodeAuth(payload)
+	if err == nil {
+		err = session.Authenticate(userName, password)
+	}
+	if err != nil {
+		WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+		return fmt.Errorf("authentication failed for %q: %w", userName, err)
+	}
+	return WriteMessage(w, MSG_AUTH_OK, EncodeAuthOK(int(session.ID()), session.CancelKey()))
+}
+
+func (s *TCPServer) addSession(session *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[int(session.ID())] = session
+}
+
+func (s *TCPServer) removeSession(session *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, int(session.ID()))
+}
+
+// Cancels the statement running in the session named by a MSG_CANCEL
+// message, if the cancel key matches
+func (s *TCPServer) cancel(payload []byte) {
+	sessionID, cancelKey, err := DecodeCancel(payload)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	session, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+
+	if ok && session.CancelKey() == cancelKey {
+		session.Cancel()
+	}
+}
+
+// Runs a statement in the connection's session and writes its result.
+// Errors raised by the statement are sent to the client; only
+// failures to write to the connection are returned.
+func (s *TCPServer) execute(w io.Writer, session *Session, cmd string) error {
+	var writeErr error
+
+	err := session.Execute(cmd, func(result *Result) error {
+		if result.Plan != nil {
+			writeErr = writeResults(w, result.Plan)
+		} else {
+			writeErr = WriteMessage(w, MSG_COMMAND_COMPLETE, EncodeCommandComplete(result.Count, result.LastInsertID))
+		}
+		return writeErr
+	})
+
+	if writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
+		return WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+	}
+	return nil
+}
+
+// Opens a cursor named by a MSG_OPEN_CURSOR message and describes its columns
+func (s *TCPServer) openCursor(w io.Writer, session *Session, payload []byte) error {
+	name, cmd, err := DecodeOpenCursor(payload)
+	if err != nil {
+		return WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+	}
+
+	c, err := session.OpenCursor(name, cmd)
+	if err != nil {
+		return WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+	}
+	return WriteMessage(w, MSG_ROW_DESCRIPTION, EncodeRowDescription(columnsOf(c.Schema())))
+}
+
+// Sends the next batch of records of a cursor. Each batch is flushed on its
+// own, so only one batch of a large result is buffered at a time.
+func (s *TCPServer) fetch(w io.Writer, session *Session, payload []byte) error {
+	name, count, err := DecodeFetch(payload)
+	if err != nil {
+		return WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+	}
+
+	c, err := session.Cursor(name)
+	if err != nil {
+		return WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+	}
+	cols := columnsOf(c.Schema())
+
+	var writeErr error
+	more, err := session.Fetch(name, count, func(scan interfaces.Scan) error {
+		writeErr = writeRow(w, scan, cols)
+		return writeErr
+	})
+
+	switch {
+	case writeErr != nil:
+		return writeErr
+	case err != nil:
+		return WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+	case more:
+		return WriteMessage(w, MSG_PORTAL_SUSPENDED, nil)
+	default:
+		return WriteMessage(w, MSG_COMMAND_COMPLETE, EncodeCommandComplete(c.Fetched(), 0))
+	}
+}
+
+// Closes a cursor named by a MSG_CLOSE_CURSOR message
+func (s *TCPServer) closeCursor(w io.Writer, session *Session, payload []byte) error {
+	name, err := DecodeCloseCursor(payload)
+	if err != nil {
+		return WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+	}
+
+	fetched, err := session.CloseCursor(name)
+	if err != nil {
+		return WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+	}
+	return WriteMessage(w, MSG_COMMAND_COMPLETE, EncodeCommandComplete(fetched, 0))
+}
+
+// Answers a MSG_METADATA request about the session's database
+func (s *TCPServer) metadata(w io.Writer, session *Session, payload []byte) error {
+	kind, arg, err := DecodeMetadataRequest(payload)
+	if err != nil {
+		return WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+	}
+
+	if kind == METADATA_SERVER_VERSION {
+		return WriteMessage(w, MSG_METADATA_RESULT, EncodeVersionInfo(s.db.ServerVersion()))
+	}
+
+	db := session.Database()
+	tx, err := db.BeginTx()
+	if err != nil {
+		return WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+	}
+	defer tx.Commit()
+
+	switch kind {
+	case METADATA_LIST_TABLES:
+		return WriteMessage(w, MSG_METADATA_RESULT, EncodeTableList(db.ListTables(tx)))
+	case METADATA_DESCRIBE_TABLE:
+		desc, err := db.DescribeTable(arg, tx)
+		if err != nil {
+			return WriteMessage(w, MSG_ERROR, []byte(err.Error()))
+		}
+		return WriteMessage(w, MSG_METADATA_RESULT, EncodeTableDesc(desc))
+	default:
+		return WriteMessage(w, MSG_ERROR, []byte(fmt.Sprintf("unknown metadata request %d", kind)))
+	}
+}
+
+// Streams the records of a query plan to the client
+func writeResults(w io.Writer, p interfaces.Plan) error {
+	scan := p.Open()
+	defer scan.Close()
+
+	cols := columnsOf(scan.Schema())
+	if err := WriteMessage(w, MSG_ROW_DESCRIPTION, EncodeRowDescription(cols)); err != nil {
+		return err
+	}
+
+	count := 0
+	for scan.Next() {
+		if err := writeRow(w, scan, cols); err != nil {
+			return err
+		}
+		count++
+	}
+
+	return WriteMessage(w, MSG_COMMAND_COMPLETE, EncodeCommandComplete(count, 0))
+}
+
+// Describes the columns of a result
+func columnsOf(sch *schema.Schema) []ColumnDesc {
+	cols := make([]ColumnDesc, 0, len(sch.Fields()))
+	for _, fieldName := range sch.Fields() {
+		cols = append(cols, ColumnDesc{Name: fieldName, Type: sch.DataType(fieldName)})
+	}
+	return cols
+}
+
+// Sends the current record of a scan as a MSG_DATA_ROW
+func writeRow(w io.Writer, scan interfaces.Scan, cols []ColumnDesc) error {
+	values := make([]any, len(cols))
+	for i, col := range cols {
+		values[i] = plan.FieldValue(scan, col.Name, col.Type)
+	}
+
+	row, err := EncodeDataRow(values)
+	if err != nil {
+		return err
+	}
+	return WriteMessage(w, MSG_DATA_ROW, row)
+}
+
+// Reports whether a statement is a query rather than an update command.
+// EXPLAIN counts as a query, since it returns the plan as records.
+func IsQuery(cmd string) bool {
+	fields := strings.Fields(cmd)
+	return len(fields) > 0 && (strings.EqualFold(fields[0], "select") || strings.EqualFold(fields[0], "explain"))
+}
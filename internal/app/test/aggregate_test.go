@@ -0,0 +1,62 @@
+package test
+
+import (
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"path/filepath"
+	"testing"
+)
+
+func TestGroupByPlan_Aggregates(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "aggdb"))
+	defer db.fm.Close()
+
+	sch := schema.NewSchema()
+	sch.AddStringField("name", 10)
+	sch.AddIntField("age")
+
+	var rows []map[string]*types.Constant
+	for _, r := range []struct {
+		name string
+		age  int
+	}{{"cid", 41}, {"ann", 27}, {"dee", 35}} {
+		rows = append(rows, map[string]*types.Constant{
+			"name": types.NewConstantString(r.name),
+			"age":  types.NewConstantInt(r.age),
+		})
+	}
+
+	tx1 := db.newTx()
+	defer tx1.Rollback()
+
+	fns := []materialize.AggregateFunction{
+		materialize.NewCountFn(),
+		materialize.NewMinFn("age"),
+		materialize.NewMaxFn("name"),
+	}
+	p := materialize.NewGroupPlan(tx1, plan.NewRowsPlan(sch, rows), nil, fns)
+
+	if p.Schema().DataType("maxofname") != schema.VARCHAR || p.Schema().DataType("count") != schema.INTEGER {
+		t.Errorf("expected max(name) to be a string and count(*) an integer")
+	}
+
+	s := p.Open()
+	defer s.Close()
+	if !s.Next() {
+		t.Fatal("expected one record")
+	}
+	if got := s.GetInt("count"); got != 3 {
+		t.Errorf("expected count 3, got %d", got)
+	}
+	if got := s.GetInt("minofage"); got != 27 {
+		t.Errorf("expected min age 27, got %d", got)
+	}
+	if got := s.GetString("maxofname"); got != "dee" {
+		t.Errorf("expected max name dee, got %s", got)
+	}
+	if s.Next() {
+		t.Error("expected a single record")
+	}
+}
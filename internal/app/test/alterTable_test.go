@@ -0,0 +1,235 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/index/planner"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestParser_AlterTable(t *testing.T) {
+	obj, err := parse.NewParser("alter table emp add column age int").UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if data, ok := obj.(*parse.AddColumnData); !ok || data.TableName() != "emp" || data.FieldName() != "age" ||
+		data.Field().DataType("age") != schema.INTEGER {
+		t.Errorf("unexpected command %#v", obj)
+	}
+
+	obj, err = parse.NewParser("ALTER TABLE emp ADD total int AS (id * 2) STORED").UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if data, ok := obj.(*parse.AddColumnData); !ok || data.Field().Generated("total") != "id * 2" || data.Field().IsVirtual("total") {
+		t.Errorf("unexpected command %#v", obj)
+	}
+
+	for _, sql := range []string{"alter table emp drop column age", "alter table emp drop age"} {
+		obj, err = parse.NewParser(sql).UpdateCmd()
+		if err != nil {
+			t.Fatalf("%s: %v", sql, err)
+		}
+		if data, ok := obj.(*parse.DropColumnData); !ok || data.TableName() != "emp" || data.FieldName() != "age" {
+			t.Errorf("%s: unexpected command %#v", sql, obj)
+		}
+	}
+
+	// ALTER USER still parses
+	if obj, err := parse.NewParser("alter user ann with password 'pw'").UpdateCmd(); err != nil {
+		t.Errorf("alter user failed: %v", err)
+	} else if _, ok := obj.(*parse.AlterUserData); !ok {
+		t.Errorf("unexpected command %#v", obj)
+	}
+
+	for _, sql := range []string{
+		"alter table emp",
+		"alter table emp rename to staff",
+		"alter table emp add column age",
+		"alter table emp drop column",
+		"alter table add age int",
+	} {
+		if _, err := parse.NewParser(sql).UpdateCmd(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestAlterTable_Exec(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "alterdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	exec := func(stmts ...string) {
+		t.Helper()
+		for _, stmt := range stmts {
+			if _, err := d.Exec(stmt); err != nil {
+				t.Fatalf("%s failed: %v", stmt, err)
+			}
+		}
+	}
+	exec(
+		"create table emp (id int, name varchar(8))",
+		"insert into emp (id, name) values (1, 'ann')",
+		"insert into emp (id, name) values (2, 'bo')",
+		"create view names as select name from emp",
+	)
+
+	// The existing records have no value for a new field
+	exec("alter table emp add column dept int")
+	if got := fmt.Sprint(queryRecords(t, d, "select id, name, dept from emp")); got != "[[1 ann <nil>] [2 bo <nil>]]" {
+		t.Errorf("expected the new field to be null, got %s", got)
+	}
+	exec(
+		"insert into emp (id, name, dept) values (3, 'cy', 10)",
+		"update emp set dept = 20 where id = 1",
+	)
+	if got := fmt.Sprint(queryRecords(t, d, "select id, dept from emp where dept > 0")); got != "[[1 20] [3 10]]" {
+		t.Errorf("expected the new field to be set, got %s", got)
+	}
+
+	// A stored generated field is computed for the existing records
+	exec("alter table emp add twice int as (id * 2) stored")
+	if got := fmt.Sprint(queryRecords(t, d, "select id, twice from emp")); got != "[[1 2] [2 4] [3 6]]" {
+		t.Errorf("expected the generated field to be computed, got %s", got)
+	}
+	if got := countRows(t, d, "select name from names"); got != 3 {
+		t.Errorf("expected the view to read 3 records, got %d", got)
+	}
+
+	for stmt, want := range map[string]error{
+		"alter table emp add dept int":                     metadata.ErrFieldExists,
+		"alter table emp drop salary":                      metadata.ErrFieldNotFound,
+		"alter table nope add dept int":                    metadata.ErrTableNotFound,
+		"alter table nope drop dept":                       metadata.ErrTableNotFound,
+		"alter table emp drop name":                        metadata.ErrDependentObjects,
+		"alter table emp drop column id":                   metadata.ErrDependentObjects,
+		"alter table emp add n varchar(4) collate klingon": types.ErrUnknownCollation,
+	} {
+		if _, err := d.Exec(stmt); !errors.Is(err, want) {
+			t.Errorf("%s: expected %v, got %v", stmt, want, err)
+		}
+	}
+
+	// The values of the remaining fields are kept
+	exec("alter table emp drop column dept")
+	if got := fmt.Sprint(queryRecords(t, d, "select id, name, twice from emp")); got != "[[1 ann 2] [2 bo 4] [3 cy 6]]" {
+		t.Errorf("expected the records to keep their values, got %s", got)
+	}
+	if _, err := d.Query("select dept from emp"); err == nil {
+		t.Errorf("expected the dropped field to be gone")
+	}
+	if got := countRows(t, d, "select id from emp"); got != 3 {
+		t.Errorf("expected 3 records, got %d", got)
+	}
+}
+
+func TestAlterTable_Indexes(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	db.exec(t, "create table emp (id int, name varchar(8))")
+	db.exec(t, "create index emp_id on emp (id)")
+	for i := 0; i < 20; i++ {
+		db.exec(t, fmt.Sprintf("insert into emp (id, name) values (%d, 'e%d')", i, i))
+	}
+
+	// The index entries point at the rewritten records
+	db.exec(t, "alter table emp add column dept int")
+	if got := db.exec(t, "update emp set dept = 7 where id = 13"); got != 1 {
+		t.Errorf("expected 1 record updated, got %d", got)
+	}
+	if got := db.exec(t, "delete from emp where id = 5"); got != 1 {
+		t.Errorf("expected 1 record deleted, got %d", got)
+	}
+
+	layout, err := db.mdm.GetLayout("emp", db.tx)
+	if err != nil {
+		t.Fatalf("GetLayout failed: %v", err)
+	}
+	idx := db.mdm.GetIndexInfo("emp", db.tx)["id"]
+	index := idx.Open()
+	defer index.Close()
+	ts := record.NewTableScan(db.tx, "emp", layout)
+	defer ts.Close()
+
+	index.BeforeFirst(types.NewConstantInt(13))
+	if !index.Next() {
+		t.Fatalf("expected the index to find id 13")
+	}
+	rid := index.GetDataRid()
+	ts.MoveToRID(rid)
+	if got := fmt.Sprint(ts.GetVal("id"), ts.GetVal("name"), ts.GetVal("dept")); got != "13 e13 7" {
+		t.Errorf("expected the indexed record to be 13 e13 7, got %s", got)
+	}
+
+	if _, err := db.iup.ExecuteDropColumn(parse.NewDropColumnData("emp", "id"), db.tx); !errors.Is(err, metadata.ErrDependentObjects) {
+		t.Errorf("expected %v dropping an indexed field, got %v", metadata.ErrDependentObjects, err)
+	}
+}
+
+func TestAlterTable_Rollback(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "alterrollbackdb"))
+	defer db.fm.Close()
+
+	tx1 := db.newTx()
+	mdm, err := metadata.NewMetaDataManager(true, tx1)
+	if err != nil {
+		t.Fatalf("NewMetaDataManager failed: %v", err)
+	}
+	iup := planner.NewIndexUpdatePlanner(mdm)
+	// Fixed slots are rewritten in place, so emptying them has to be undone too
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8)) with (format = fixed)",
+		"insert into emp (id, name) values (1, 'ann')",
+		"insert into emp (id, name) values (2, 'bo')",
+	} {
+		obj, err := parse.NewParser(stmt).UpdateCmd()
+		if err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+		switch data := obj.(type) {
+		case *parse.CreateTableData:
+			_, err = iup.ExecuteCreateTable(data, tx1)
+		case *parse.InsertData:
+			_, err = iup.ExecuteInsert(data, tx1)
+		}
+		if err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	tx1.Commit()
+
+	tx2 := db.newTx()
+	if err := mdm.DropColumn("emp", "name", tx2); err != nil {
+		t.Fatalf("DropColumn failed: %v", err)
+	}
+	tx2.Rollback()
+
+	// The field and its values are back
+	tx3 := db.newTx()
+	defer tx3.Commit()
+	layout, err := mdm.GetLayout("emp", tx3)
+	if err != nil {
+		t.Fatalf("GetLayout failed: %v", err)
+	}
+	if !layout.Schema().HasField("name") {
+		t.Fatalf("expected the dropped field to be restored")
+	}
+	ts := record.NewTableScan(tx3, "emp", layout)
+	defer ts.Close()
+	var records []string
+	for ts.Next() {
+		records = append(records, fmt.Sprintf("%d %s", ts.GetInt("id"), ts.GetString("name")))
+	}
+	if got := fmt.Sprint(records); got != "[1 ann 2 bo]" {
+		t.Errorf("expected the records to be restored, got %s", got)
+	}
+}
@@ -0,0 +1,175 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/index"
+	"centauri/internal/app/index/planner"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_Analyze(t *testing.T) {
+	for _, tc := range []struct {
+		sql  string
+		want string
+	}{
+		{"analyze", ""},
+		{"ANALYZE people", "people"},
+	} {
+		data, err := parse.NewParser(tc.sql).UpdateCmd()
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tc.sql, err)
+		}
+		if got := data.(*parse.AnalyzeData).TableName(); got != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.sql, tc.want, got)
+		}
+	}
+}
+
+func TestAnalyze_Statistics(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "analyzedb")
+	rdb := openRecoveryTestDB(t, dir)
+	defer rdb.fm.Close()
+
+	tx1 := rdb.newTx()
+	mdm, err := metadata.NewMetaDataManager(true, tx1)
+	if err != nil {
+		t.Fatalf("NewMetaDataManager failed: %v", err)
+	}
+	db := &indexPlannerTestDB{tx: tx1, mdm: mdm, iup: planner.NewIndexUpdatePlanner(mdm)}
+	for _, stmt := range []string{
+		"create table people (id int, age int)",
+		"create index people_age on people (age) using btree",
+		"create index people_id on people (id)",
+	} {
+		db.exec(t, stmt)
+	}
+	var values []string
+	for i := 0; i < 600; i++ {
+		values = append(values, fmt.Sprintf("(%d, %d)", i, i%20))
+	}
+	db.exec(t, "insert into people (id, age) values "+strings.Join(values, ", "))
+
+	p := plan.NewPlanner(plan.NewBasicQueryPlanner(mdm), db.iup, mdm)
+	if count, err := p.ExecuteUpdate("analyze people", tx1); err != nil || count != 1 {
+		t.Fatalf("analyze: expected 1 table, got %d, %v", count, err)
+	}
+
+	// A search through the B-tree reads a block per level
+	indexes := mdm.GetIndexInfo("people", tx1)
+	ii := indexes["age"]
+	idx := ii.Open()
+	height := idx.(index.TreeIndex).Height()
+	idx.Close()
+	if height < 2 || ii.BlocksAccessed() != height {
+		t.Errorf("expected a search cost of the height %d, got %d", height, ii.BlocksAccessed())
+	}
+	if got := mdm.StatMgr().IndexHeight("people_id"); got != 0 {
+		t.Errorf("expected no height for a hash index, got %d", got)
+	}
+
+	layout, err := mdm.GetLayout("people", tx1)
+	if err != nil {
+		t.Fatalf("GetLayout failed: %v", err)
+	}
+	si := mdm.GetStatInfo("people", layout, tx1)
+	if got := si.DistinctValues("age"); got < 18 || got > 22 {
+		t.Errorf("expected about 20 distinct ages, got %d", got)
+	}
+
+	// Records added behind the planners' back leave the row count as it was
+	ts := record.NewTableScan(tx1, "people", layout)
+	for i := 0; i < 10; i++ {
+		ts.Insert()
+		ts.SetVal("id", types.NewConstantInt(1000+i))
+		ts.SetVal("age", types.NewConstantInt(100+i))
+	}
+	ts.Close()
+	tx1.Commit()
+
+	// Closing the database saves the row counts to the catalog
+	save := rdb.newTx()
+	mdm.SaveRowCounts(save)
+	save.Commit()
+
+	// The statistics of the catalog are read when the database is opened again
+	tx2 := rdb.newTx()
+	defer tx2.Rollback()
+	mdm, err = metadata.NewMetaDataManager(false, tx2)
+	if err != nil {
+		t.Fatalf("NewMetaDataManager failed: %v", err)
+	}
+	if got := mdm.StatMgr().IndexHeight("people_age"); got != height {
+		t.Errorf("expected the height %d, got %d", height, got)
+	}
+	si = mdm.GetStatInfo("people", layout, tx2)
+	if got := si.DistinctValues("age"); got < 18 || got > 22 {
+		t.Errorf("expected the stored estimate of about 20 distinct ages, got %d", got)
+	}
+
+	// Analyzing again finds the new records, and corrects the row count
+	p = plan.NewPlanner(plan.NewBasicQueryPlanner(mdm), planner.NewIndexUpdatePlanner(mdm), mdm)
+	if _, err := p.ExecuteUpdate("analyze", tx2); err != nil {
+		t.Fatalf("analyze failed: %v", err)
+	}
+	si = mdm.GetStatInfo("people", layout, tx2)
+	if got := si.DistinctValues("age"); got < 27 || got > 33 {
+		t.Errorf("expected about 30 distinct ages, got %d", got)
+	}
+	if got := mdm.RowCount("people", tx2); got != 610 {
+		t.Errorf("expected 610 records, got %d", got)
+	}
+
+	if err := mdm.DropIndex("people_age", tx2); err != nil {
+		t.Fatalf("DropIndex failed: %v", err)
+	}
+	if got := mdm.StatMgr().IndexHeight("people_age"); got != 0 {
+		t.Errorf("expected the height to be dropped with the index, got %d", got)
+	}
+}
+
+func TestAnalyze_Exec(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "analyzedb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table a (id int)",
+		"create table b (id int)",
+		"create view v as select id from a",
+		"insert into a (id) values (1), (2), (2)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	if count, err := d.Exec("analyze"); err != nil || count != 2 {
+		t.Errorf("expected 2 tables analyzed, got %d, %v", count, err)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select numrecs, distinctvals from statcat where tblname = 'a'")); got != "[[3 2]]" {
+		t.Errorf("expected [[3 2]], got %s", got)
+	}
+	for _, stmt := range []string{"analyze nosuch", "analyze v"} {
+		if _, err := d.Exec(stmt); !errors.Is(err, metadata.ErrTableNotFound) {
+			t.Errorf("%s: expected %v, got %v", stmt, metadata.ErrTableNotFound, err)
+		}
+	}
+
+	// Dropping the table drops its statistics
+	if _, err := d.Exec("drop table a cascade"); err != nil {
+		t.Fatalf("drop table failed: %v", err)
+	}
+	if got := countRows(t, d, "select tblname from statcat where tblname = 'a'"); got != 0 {
+		t.Errorf("expected no statistics left, got %d", got)
+	}
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/types"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestHyperLogLog_Estimate(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 1000, 100000} {
+		h := types.NewHyperLogLog()
+		for i := 0; i < n; i++ {
+			// Every value is added twice, and counts once
+			h.Add(types.NewConstantInt(i))
+			h.Add(types.NewConstantLong(int64(i)))
+		}
+		got := h.Estimate()
+		if diff := got - n; diff*diff > (n/25+1)*(n/25+1) {
+			t.Errorf("%d distinct values: estimate %d is off by more than 4%%", n, got)
+		}
+	}
+
+	// Merging the sketches of two halves estimates the whole
+	a, b := types.NewHyperLogLog(), types.NewHyperLogLog()
+	for i := 0; i < 5000; i++ {
+		a.Add(types.NewConstantString(fmt.Sprintf("a%d", i)))
+		b.Add(types.NewConstantString(fmt.Sprintf("b%d", i)))
+	}
+	a.Merge(b)
+	if got := a.Estimate(); got < 9600 || got > 10400 {
+		t.Errorf("expected about 10000 distinct values after merging, got %d", got)
+	}
+}
+
+func TestApproxCountDistinct_Query(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "approxdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Exec("create table visits (id int, page varchar(8) collate nocase)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	pages := []string{"home", "HOME", "about", "faq", "Faq", "home"}
+	for i, page := range pages {
+		stmt := fmt.Sprintf("insert into visits (id, page) values (%d, '%s')", i, page)
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	// Pages equal under the collation are the same value
+	query := "select approx_count_distinct(page), approx_count_distinct(id), count(*) from visits"
+	if got := fmt.Sprint(queryRecords(t, d, query)); got != "[[3 6 6]]" {
+		t.Errorf("%s: expected [[3 6 6]], got %s", query, got)
+	}
+	query = "select approx_count_distinct(id) from visits where page = 'home'"
+	if got := fmt.Sprint(queryRecords(t, d, query)); got != "[[3]]" {
+		t.Errorf("%s: expected [[3]], got %s", query, got)
+	}
+}
+
+func TestStatManager_DistinctValues(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	db.exec(t, "create table t (id int, grp int, name varchar(8))")
+	for i := 0; i < 600; i++ {
+		db.exec(t, fmt.Sprintf("insert into t (id, grp, name) values (%d, %d, 'n%d')", i, i%7, i%50))
+	}
+
+	layout, err := db.mdm.GetLayout("t", db.tx)
+	if err != nil {
+		t.Fatalf("GetLayout failed: %v", err)
+	}
+	// The statistics are those of the table when last calculated,
+	// which the inserts may have made stale
+	si := db.mdm.GetStatInfo("t", layout, db.tx)
+	for field, want := range map[string]int{"id": si.RecordsOutput(), "grp": 7, "name": 50} {
+		got := si.DistinctValues(field)
+		if diff := got - want; diff*diff > (want/25+1)*(want/25+1) {
+			t.Errorf("%s: expected about %d distinct values, got %d", field, want, got)
+		}
+	}
+
+	// The estimates are refreshed once enough of the table has changed
+	db.exec(t, "update t set grp = id")
+	si = db.mdm.GetStatInfo("t", layout, db.tx)
+	if got := si.DistinctValues("grp"); got < 576 {
+		t.Errorf("expected about 600 distinct values of grp after the update, got %d", got)
+	}
+}
@@ -0,0 +1,147 @@
+package test
+
+import (
+	"centauri/db"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAsOf_Snapshots(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "asofdb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	exec := func(stmts ...string) {
+		t.Helper()
+		for _, stmt := range stmts {
+			if _, err := d.Exec(stmt); err != nil {
+				t.Fatalf("%s failed: %v", stmt, err)
+			}
+		}
+	}
+
+	exec("create table accounts (id int, owner varchar(8), balance int)")
+	empty := d.LSN()
+	exec(
+		"insert into accounts (id, owner, balance) values (1, 'ann', 100)",
+		"insert into accounts (id, owner, balance) values (2, 'bob', 50)",
+	)
+	opened := d.LSN()
+	time.Sleep(10 * time.Millisecond)
+	openedAt := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	exec(
+		"update accounts set balance = 70 where id = 1",
+		"update accounts set owner = 'robert' where id = 2",
+		"delete from accounts where id = 1",
+		"insert into accounts (id, owner, balance) values (3, 'cy', 10)",
+	)
+
+	// A transaction that rolls back is in no snapshot
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx.Exec("update accounts set balance = 0 where id = 3"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	check := func(d *db.DB) {
+		t.Helper()
+		for query, want := range map[string]string{
+			"select id, owner, balance from accounts":                               "[[3 cy 10] [2 robert 50]]",
+			fmt.Sprintf("select id, owner, balance from accounts as of %d", opened): "[[1 ann 100] [2 bob 50]]",
+			fmt.Sprintf("select id from accounts as of %d", empty):                  "[]",
+			fmt.Sprintf("select owner from accounts as of %d where id = 2", opened): "[[bob]]",
+			fmt.Sprintf("select owner from accounts as of timestamp '%s' where balance = 100",
+				openedAt.Format(time.RFC3339Nano)): "[[ann]]",
+		} {
+			if got := fmt.Sprint(queryRecords(t, d, query)); got != want {
+				t.Errorf("%s: expected %s, got %s", query, want, got)
+			}
+		}
+	}
+	check(d)
+
+	// LSNs identify the same point once the database is reopened
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	d, err = db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer d.Close()
+	check(d)
+}
+
+func TestAsOf_Retention(t *testing.T) {
+	opts := db.DefaultOptions()
+	opts.Retention = time.Minute
+	d, err := db.Open(filepath.Join(t.TempDir(), "asofdb"), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Exec("create table t (id int)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	query := fmt.Sprintf("select id from t as of timestamp '%s'", time.Now().Add(-time.Hour).Format(time.RFC3339))
+	rows, err := d.Query(query)
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+	}
+	if err == nil || !strings.Contains(err.Error(), "older than the history retention") {
+		t.Errorf("%s: expected the snapshot to be too old, got %v", query, err)
+	}
+}
+
+func TestAsOf_RolledBackBeforePoint(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "asofdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table t (id int, val int)",
+		"insert into t (id, val) values (1, 0)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx.Exec("update t set val = 9 where id = 1"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if _, err := d.Exec("update t set val = 5 where id = 1"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	// Undoing the rolled back change again would bring back its old value
+	query := fmt.Sprintf("select val from t as of %d", d.LSN())
+	if got := fmt.Sprint(queryRecords(t, d, query)); got != "[[5]]" {
+		t.Errorf("%s: expected [[5]], got %s", query, got)
+	}
+}
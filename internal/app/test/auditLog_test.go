@@ -0,0 +1,56 @@
+package test
+
+import (
+	"bufio"
+	"centauri/internal/app/server"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLog_Record(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	// Reopening must append rather than truncate
+	for i, entry := range []server.AuditEntry{
+		{Time: time.Now(), User: "alice", Database: "main", Statement: "delete from t", Rows: 3, Success: true},
+		{Time: time.Now(), User: "bob", Database: "main", Statement: "drop table t", Error: "permission denied"},
+	} {
+		al, err := server.OpenAuditLog(path)
+		if err != nil {
+			t.Fatalf("OpenAuditLog failed: %v", err)
+		}
+		if err := al.Record(entry); err != nil {
+			t.Fatalf("Record %d failed: %v", i, err)
+		}
+		al.Close()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var entries []server.AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry server.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid audit log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].User != "alice" || entries[0].Rows != 3 || !entries[0].Success {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].Success || entries[1].Error != "permission denied" {
+		t.Errorf("unexpected second entry: %+v", entries[1])
+	}
+}
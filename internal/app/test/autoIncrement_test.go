@@ -0,0 +1,207 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/driver"
+	"centauri/dump"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/record"
+	"centauri/internal/app/server"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParser_AutoIncrement(t *testing.T) {
+	data, err := parse.NewParser("create table users (id int not null auto_increment, name varchar(10))").UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	sch := data.(*parse.CreateTableData).NewSchema()
+	if !sch.AutoIncrement("id") || !sch.NotNull("id") || sch.AutoIncrement("name") {
+		t.Errorf("expected only id to be AUTO_INCREMENT")
+	}
+}
+
+// Executes an insert in its own transaction and returns its last insert id
+func insertReturningID(t *testing.T, d *db.DB, stmt string) int64 {
+	t.Helper()
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx.Exec(stmt); err != nil {
+		t.Fatalf("%s failed: %v", stmt, err)
+	}
+	id := tx.LastInsertID()
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	return id
+}
+
+func TestAutoIncrement_Insert(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "autoincdb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"create table users (id int auto_increment, name varchar(10))",
+		"create index users_id on users (id)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	// A multi-record insert reports the first value it generated
+	if id := insertReturningID(t, d, "insert into users (name) values ('ann'), ('bob')"); id != 1 {
+		t.Errorf("expected last insert id 1, got %d", id)
+	}
+	if id := insertReturningID(t, d, "insert into users (id, name) values (null, 'cid')"); id != 3 {
+		t.Errorf("expected last insert id 3, got %d", id)
+	}
+
+	// An explicit value moves the sequence past it
+	if id := insertReturningID(t, d, "insert into users (id, name) values (10, 'dan')"); id != 0 {
+		t.Errorf("expected no value generated, got %d", id)
+	}
+	if id := insertReturningID(t, d, "insert into users (name) values ('eve')"); id != 11 {
+		t.Errorf("expected last insert id 11, got %d", id)
+	}
+
+	// A rolled back insert gives its value back
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx.Exec("insert into users (name) values ('gone')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+
+	// Loaded records take values too
+	count, err := d.BulkInsert("users", []string{"name"}, func(yield func([]any) bool) {
+		yield([]any{"fay"})
+	})
+	if err != nil || count != 1 {
+		t.Fatalf("BulkInsert: expected 1 record, got %d, %v", count, err)
+	}
+
+	records := queryRecords(t, d, "select id, name from users")
+	sort.Strings(records)
+	if got, want := fmt.Sprint(records), "[[1 ann] [10 dan] [11 eve] [12 fay] [2 bob] [3 cid]]"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select name from users where id = 12")); got != "[[fay]]" {
+		t.Errorf("expected the index to find fay, got %s", got)
+	}
+
+	for _, tt := range []struct {
+		stmt string
+		want error
+	}{
+		{"create table t (name varchar(5) auto_increment)", record.ErrFieldType},
+		{"create table t (id int, twice int auto_increment as (id * 2))", record.ErrGeneratedField},
+	} {
+		if _, err := d.Exec(tt.stmt); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.stmt, tt.want, err)
+		}
+	}
+	for _, stmt := range []string{
+		"create table t (a int auto_increment, b bigint auto_increment)",
+		"create table t (a int auto_increment default 1)",
+		"alter table users add column seq int auto_increment",
+	} {
+		if _, err := d.Exec(stmt); err == nil {
+			t.Errorf("%s: expected an error", stmt)
+		}
+	}
+
+	// Altering the table keeps the sequence
+	for _, stmt := range []string{
+		"alter table users add column note varchar(5)",
+		"alter table users drop column note",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The sequence is kept in the catalog
+	d, err = db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+	if id := insertReturningID(t, d, "insert into users (name) values ('gus')"); id != 13 {
+		t.Errorf("expected last insert id 13, got %d", id)
+	}
+
+	desc, err := d.DescribeTable("users")
+	if err != nil {
+		t.Fatalf("DescribeTable failed: %v", err)
+	}
+	if !desc.Columns[0].AutoIncrement || desc.Columns[1].AutoIncrement {
+		t.Errorf("expected only id to be AUTO_INCREMENT, got %+v", desc.Columns)
+	}
+	var out strings.Builder
+	if err := dump.Dump(d, &out, nil); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	if want := "create table users (id int auto_increment, name varchar(10));"; !strings.Contains(out.String(), want) {
+		t.Errorf("expected the dump to contain %q, got:\n%s", want, out.String())
+	}
+
+	// Dropping the table drops its sequence
+	for _, stmt := range []string{
+		"drop table users cascade",
+		"create table users (id bigint auto_increment, name varchar(10))",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	if id := insertReturningID(t, d, "insert into users (name) values ('hal')"); id != 1 {
+		t.Errorf("expected a new sequence, got last insert id %d", id)
+	}
+}
+
+func TestAutoIncrement_Protocol(t *testing.T) {
+	count, lastInsertID, err := server.DecodeCommandComplete(server.EncodeCommandComplete(3, 1<<40))
+	if err != nil || count != 3 || lastInsertID != 1<<40 {
+		t.Errorf("expected 3 and %d, got %d, %d, %v", int64(1<<40), count, lastInsertID, err)
+	}
+
+	fs := newFakeServer(t, func(n int, conn net.Conn) bool {
+		server.WriteMessage(conn, server.MSG_COMMAND_COMPLETE, server.EncodeCommandComplete(2, 7))
+		return true
+	})
+	pool, err := driver.OpenDB(fs.dsn(""))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer pool.Close()
+
+	res, err := pool.Exec("insert into t (a) values (1), (2)")
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Errorf("expected 2 records affected, got %d", n)
+	}
+	if id, _ := res.LastInsertId(); id != 7 {
+		t.Errorf("expected last insert id 7, got %d", id)
+	}
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/server"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestBackup_Restore(t *testing.T) {
+	dir := t.TempDir()
+	d, err := db.Open(filepath.Join(dir, "live"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table a (id int)",
+		"create table b (id int)",
+		"create table c (id int)",
+		"insert into a (id) values (0)",
+		"insert into b (id) values (0)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	// A transaction left open is not in the backup
+	open, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := open.Exec("insert into c (id) values (1)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	// A writer keeps inserting into both tables in one transaction at a time
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for id := 1; ; id++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			tx, err := d.Begin()
+			if err != nil {
+				return
+			}
+			tx.Exec(fmt.Sprintf("insert into a (id) values (%d)", id))
+			tx.Exec(fmt.Sprintf("insert into b (id) values (%d)", id))
+			if id%3 == 0 {
+				tx.Rollback()
+			} else {
+				tx.Commit()
+			}
+		}
+	}()
+
+	backup := filepath.Join(dir, "backup")
+	err = d.Backup(backup)
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := d.Backup(backup); err == nil {
+		t.Errorf("expected a backup to an existing directory to fail")
+	}
+
+	restored := filepath.Join(dir, "restored")
+	if err := db.Restore(backup, restored, nil); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	r, err := db.Open(restored, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+
+	// Every transaction is either entirely in the backup or not at all
+	inA := queryRecords(t, r, "select id from a")
+	inB := queryRecords(t, r, "select id from b")
+	sort.Strings(inA)
+	sort.Strings(inB)
+	if fmt.Sprint(inA) != fmt.Sprint(inB) {
+		t.Errorf("expected the same records in both tables, got %d and %d", len(inA), len(inB))
+	}
+	if got := countRows(t, r, "select id from a where id = 0"); got != 1 {
+		t.Errorf("expected the records committed before the backup, got %d", got)
+	}
+	if got := countRows(t, r, "select id from c"); got != 0 {
+		t.Errorf("expected the open transaction's record to be left out, got %d", got)
+	}
+
+	// Without a running transaction the backup starts with a checkpoint
+	if err := open.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if err := d.Backup(filepath.Join(dir, "quiet")); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := db.Restore(filepath.Join(dir, "quiet"), filepath.Join(dir, "quietdb"), nil); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	q, err := db.Open(filepath.Join(dir, "quietdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer q.Close()
+	if got, want := countRows(t, q, "select id from b"), countRows(t, d, "select id from b"); got != want {
+		t.Errorf("expected %d records, got %d", want, got)
+	}
+
+	if err := db.Restore(backup, restored, nil); !errors.Is(err, server.ErrRestoreTarget) {
+		t.Errorf("expected %v, got %v", server.ErrRestoreTarget, err)
+	}
+	if err := db.Restore(dir, filepath.Join(dir, "other"), nil); !errors.Is(err, server.ErrNotBackup) {
+		t.Errorf("expected %v, got %v", server.ErrNotBackup, err)
+	}
+}
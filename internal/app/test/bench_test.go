@@ -0,0 +1,47 @@
+package test
+
+import (
+	"centauri/bench"
+	"errors"
+	"testing"
+)
+
+func TestBench_ParseMix(t *testing.T) {
+	mix, err := bench.ParseMix("point=70, range=10,update=15,join=5")
+	if err != nil {
+		t.Fatalf("ParseMix failed: %v", err)
+	}
+	if mix[bench.OP_POINT] != 70 || mix[bench.OP_JOIN] != 5 {
+		t.Errorf("unexpected mix: %v", mix)
+	}
+	if got := mix.String(); got != "point=70,range=10,update=15,join=5" {
+		t.Errorf("unexpected mix string %q", got)
+	}
+
+	for _, s := range []string{"point", "point=-1", "point=many"} {
+		if _, err := bench.ParseMix(s); !errors.Is(err, bench.ErrInvalidWorkload) {
+			t.Errorf("expected ErrInvalidWorkload for %q, got %v", s, err)
+		}
+	}
+}
+
+func TestBench_Validate(t *testing.T) {
+	if err := bench.DefaultWorkload().Validate(); err != nil {
+		t.Fatalf("default workload is invalid: %v", err)
+	}
+
+	invalid := map[string]func(w *bench.Workload){
+		"no records":        func(w *bench.Workload) { w.Records = 0 },
+		"no workers":        func(w *bench.Workload) { w.Workers = 0 },
+		"no end":            func(w *bench.Workload) { w.Operations = 0 },
+		"unknown operation": func(w *bench.Workload) { w.Mix = bench.Mix{"scan": 1} },
+		"empty mix":         func(w *bench.Workload) { w.Mix = bench.Mix{bench.OP_POINT: 0} },
+	}
+	for name, change := range invalid {
+		w := bench.DefaultWorkload()
+		change(w)
+		if err := w.Validate(); !errors.Is(err, bench.ErrInvalidWorkload) {
+			t.Errorf("%s: expected ErrInvalidWorkload, got %v", name, err)
+		}
+	}
+}
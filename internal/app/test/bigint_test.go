@@ -0,0 +1,179 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/server"
+	"centauri/internal/app/types"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestParser_Bigint(t *testing.T) {
+	data, err := parse.NewParser("create table hits (id int, views bigint)").UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	sch := data.(*parse.CreateTableData).NewSchema()
+	if got := sch.DataType("views"); got != schema.BIGINT {
+		t.Errorf("expected bigint, got %s", got)
+	}
+
+	// Integers too large for 32 bits are 64-bit constants
+	tests := []struct {
+		sql  string
+		want *types.Constant
+	}{
+		{"select id from hits where views = 2147483647", types.NewConstantInt(math.MaxInt32)},
+		{"select id from hits where views = 2147483648", types.NewConstantLong(math.MaxInt32 + 1)},
+		{"select id from hits where views = 9223372036854775807", types.NewConstantLong(math.MaxInt64)},
+	}
+	for _, tt := range tests {
+		q, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		got := q.Pred().Terms()[0].RHS().AsConstant()
+		if !got.Equals(tt.want) || (got.AsLong() != nil) != (tt.want.AsLong() != nil) {
+			t.Errorf("%s: expected %v, got %v", tt.sql, tt.want, got)
+		}
+	}
+}
+
+func TestBigint_Query(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bigintdb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"create table hits (id int, views bigint)",
+		"insert into hits (id, views) values (1, 5000000000), (3, 42), (4, null)",
+		"create index hits_views on hits (views)",
+		"insert into hits (id, views) values (5, 9223372036854775807)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	insert, err := d.Prepare("insert into hits (id, views) values (?, ?)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if _, err := insert.Exec(2, -math.MaxInt64); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select id, views from hits where id = 1", "[[1 5000000000]]"},
+		{"select id from hits where views = 5000000000", "[[1]]"},
+		{"select id from hits where views = 42", "[[3]]"},
+		{"select id from hits where views > 2147483647", "[[1] [5]]"},
+		{"select id from hits where views < 0", "[[2]]"},
+		{"select id from hits where views between 0 and 6000000000", "[[1] [3]]"},
+		{"select id from hits where views in (42, 9223372036854775807)", "[[3] [5]]"},
+		{"select id from hits where views is null", "[[4]]"},
+		{"select max(views), min(views) from hits", "[[9223372036854775807 -9223372036854775807]]"},
+		{"select id, views * 2 as double from hits where id = 1", "[[1 10000000000]]"},
+		{"select id, views + 1 as next from hits where id = 3", "[[3 43]]"},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		sort.Strings(records)
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select id from hits where views is not null order by views desc")); got != "[[5] [1] [3] [2]]" {
+		t.Errorf("expected [[5] [1] [3] [2]], got %s", got)
+	}
+
+	// Values are int64, and Go ints of any size can be stored
+	stmt, err := d.Prepare("update hits set views = ? where id = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if n, err := stmt.Exec(int64(math.MinInt64), 4); err != nil || n != 1 {
+		t.Fatalf("update: expected 1 record, got %d, %v", n, err)
+	}
+	if n, err := stmt.Exec(1<<40, 3); err != nil || n != 1 {
+		t.Fatalf("update: expected 1 record, got %d, %v", n, err)
+	}
+	rows, err := d.Query("select id, views from hits where views < 0 or views = 1099511627776")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	got := map[int]any{}
+	for rows.Next() {
+		got[rows.Row().Value("id").(int)] = rows.Row().Value("views")
+	}
+	rows.Close()
+	if fmt.Sprint(got) != "map[2:-9223372036854775807 3:1099511627776 4:-9223372036854775808]" {
+		t.Errorf("unexpected values %v", got)
+	}
+	if _, ok := got[3].(int64); !ok {
+		t.Errorf("expected an int64, got %T", got[3])
+	}
+
+	// An int field holds 32 bits
+	for _, stmt := range []string{
+		"insert into hits (id, views) values (5000000000, 1)",
+		"insert into hits (id, views) values (6, 'many')",
+	} {
+		if _, err := d.Exec(stmt); err == nil {
+			t.Errorf("%s: expected an error", stmt)
+		}
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The values and the index are kept on disk
+	d, err = db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+	lookup, err := d.Prepare("select id from hits where views = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	rows, err = lookup.Query(int64(math.MinInt64))
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if !rows.Next() || rows.Row().Value("id") != 4 || rows.Next() {
+		t.Errorf("expected record 4 alone to have the least views")
+	}
+	rows.Close()
+	desc, err := d.DescribeTable("hits")
+	if err != nil {
+		t.Fatalf("DescribeTable failed: %v", err)
+	}
+	if desc.Columns[1].Type != schema.BIGINT {
+		t.Errorf("expected a bigint column, got %s", desc.Columns[1].Type)
+	}
+}
+
+func TestProtocol_Bigint(t *testing.T) {
+	values := []any{int64(math.MinInt64), int64(5000000000), 7}
+	payload, err := server.EncodeDataRow(values)
+	if err != nil {
+		t.Fatalf("EncodeDataRow failed: %v", err)
+	}
+	got, err := server.DecodeDataRow(payload)
+	if err != nil {
+		t.Fatalf("DecodeDataRow failed: %v", err)
+	}
+	if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", values) {
+		t.Errorf("expected %#v, got %#v", values, got)
+	}
+}
@@ -0,0 +1,253 @@
+package test
+
+import (
+	"bytes"
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/server"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Returns n bytes that differ from block to block
+func blobBytes(n int, seed byte) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i/7) + seed
+	}
+	return b
+}
+
+func readBlob(t *testing.T, ts *record.TableScan, field string) []byte {
+	r, err := ts.BlobReader(field)
+	if err != nil {
+		t.Fatalf("BlobReader failed: %v", err)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	return b
+}
+
+func TestBlob_Overflow(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "blobdb"))
+	defer db.fm.Close()
+
+	sch := schema.NewSchema()
+	sch.AddIntField("id")
+	sch.AddBlobField("body")
+	layout := record.NewLayout(sch)
+
+	tx1 := db.newTx()
+	ts := record.NewTableScan(tx1, "doc", layout)
+	ts.Insert()
+	ts.SetInt("id", 1)
+
+	// A blob several blocks long is streamed in small writes
+	big := blobBytes(3000, 0)
+	w, err := ts.BlobWriter("body")
+	if err != nil {
+		t.Fatalf("BlobWriter failed: %v", err)
+	}
+	for i := 0; i < len(big); i += 100 {
+		if _, err := w.Write(big[i:min(i+100, len(big))]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Errorf("expected writing a closed blob to fail")
+	}
+
+	ts.Insert()
+	ts.SetInt("id", 2)
+	ts.SetBlob("body", []byte("short"))
+
+	ts.BeforeFirst()
+	ts.Next()
+	if got := readBlob(t, ts, "body"); !bytes.Equal(got, big) {
+		t.Errorf("expected %d bytes back, got %d", len(big), len(got))
+	}
+	if blocks, _ := tx1.Size(record.OverflowFile("doc")); blocks < 3000/tx1.BlockSize()+2 {
+		t.Errorf("expected the blob to take several overflow blocks, got %d", blocks)
+	}
+	ts.Next()
+	if got := ts.GetString("body"); got != "short" {
+		t.Errorf("expected short, got %q", got)
+	}
+	if _, err := ts.BlobReader("id"); !errors.Is(err, record.ErrFieldType) {
+		t.Errorf("expected %v, got %v", record.ErrFieldType, err)
+	}
+	tx1.Commit()
+
+	// The blocks of replaced and deleted blobs are reused instead of
+	// growing the file
+	tx2 := db.newTx()
+	ts = record.NewTableScan(tx2, "doc", layout)
+	ts.Next()
+	ts.SetBlob("body", blobBytes(2000, 1))
+	ts.Next()
+	ts.Delete()
+	blocks, _ := tx2.Size(record.OverflowFile("doc"))
+	ts.BeforeFirst()
+	ts.Insert()
+	ts.SetInt("id", 3)
+	ts.SetBlob("body", blobBytes(900, 2))
+	if after, _ := tx2.Size(record.OverflowFile("doc")); after != blocks {
+		t.Errorf("expected %d overflow blocks, got %d", blocks, after)
+	}
+	ts.SetNull("body")
+	tx2.Commit()
+
+	// A rolled back update restores the blob it replaced
+	tx3 := db.newTx()
+	ts = record.NewTableScan(tx3, "doc", layout)
+	for ts.Next() {
+		if id := ts.GetInt("id"); id == 1 {
+			ts.SetBlob("body", []byte("gone"))
+		}
+	}
+	ts.Close()
+	tx3.Rollback()
+
+	tx4 := db.newTx()
+	ts = record.NewTableScan(tx4, "doc", layout)
+	found := 0
+	for ts.Next() {
+		switch id := ts.GetInt("id"); id {
+		case 1:
+			if got := readBlob(t, ts, "body"); !bytes.Equal(got, blobBytes(2000, 1)) {
+				t.Errorf("expected the blob to be restored, got %d bytes", len(got))
+			}
+		case 3:
+			if !ts.GetVal("body").IsNull() || len(readBlob(t, ts, "body")) != 0 {
+				t.Errorf("expected a null blob")
+			}
+		}
+		found++
+	}
+	if found != 2 {
+		t.Errorf("expected 2 records, got %d", found)
+	}
+	ts.Close()
+	tx4.Commit()
+}
+
+func TestBlob_Query(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "blobdb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Exec("create table doc (id int, name varchar(8), body blob)"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	insert, err := d.Prepare("insert into doc (id, name, body) values (?, ?, ?)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	image := blobBytes(10000, 3)
+	for i, body := range [][]byte{image, []byte("hello"), nil} {
+		if _, err := insert.Exec(i+1, fmt.Sprintf("d%d", i+1), body); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+	if _, err := d.Exec("insert into doc (id, name, body) values (4, 'd4', 'text')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	rows, err := d.Query("select id, body from doc where id = 1")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if !rows.Next() {
+		t.Fatalf("expected a record")
+	}
+	if got, ok := rows.Row().Values()[1].([]byte); !ok || !bytes.Equal(got, image) {
+		t.Errorf("expected the %d bytes inserted, got %T of length %d", len(image), rows.Row().Values()[1], len(got))
+	}
+	rows.Close()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select id from doc where body = 'hello'", "[[2]]"},
+		{"select id from doc where body is null", "[[3]]"},
+		{"select name from doc where body like 'te%'", "[[d4]]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(queryRecords(t, d, tt.query)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	if n, err := d.Exec("update doc set body = 'small' where id = 1"); err != nil || n != 1 {
+		t.Fatalf("update: expected 1 record, got %d, %v", n, err)
+	}
+	if n, err := d.Exec("delete from doc where body = 'small'"); err != nil || n != 1 {
+		t.Fatalf("delete: expected 1 record, got %d, %v", n, err)
+	}
+	if got := countRows(t, d, "select id from doc"); got != 3 {
+		t.Errorf("expected 3 records left, got %d", got)
+	}
+
+	desc, err := d.DescribeTable("doc")
+	if err != nil {
+		t.Fatalf("DescribeTable failed: %v", err)
+	}
+	if desc.Columns[2].Type != schema.BLOB {
+		t.Errorf("expected a blob, got %s", desc.Columns[2].Type)
+	}
+	if _, err := d.Exec("create index doc_body on doc (body)"); !errors.Is(err, record.ErrFieldType) {
+		t.Errorf("expected %v, got %v", record.ErrFieldType, err)
+	}
+
+	// Dropping the table removes its overflow blocks
+	ovf := filepath.Join(dir, record.OverflowFile("doc"))
+	if _, err := os.Stat(ovf); err != nil {
+		t.Fatalf("expected the overflow file: %v", err)
+	}
+	if _, err := d.Exec("drop table doc"); err != nil {
+		t.Fatalf("drop failed: %v", err)
+	}
+	if _, err := os.Stat(ovf); !os.IsNotExist(err) {
+		t.Errorf("expected the overflow file to be removed, got %v", err)
+	}
+}
+
+func TestParser_Blob(t *testing.T) {
+	data, err := parse.NewParser("create table doc (id int, body blob)").UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got := data.(*parse.CreateTableData).NewSchema().DataType("body"); got != schema.BLOB {
+		t.Errorf("expected blob, got %s", got)
+	}
+}
+
+func TestProtocol_Blob(t *testing.T) {
+	values := []any{[]byte{0, 1, 255}, "text", []byte{}}
+	payload, err := server.EncodeDataRow(values)
+	if err != nil {
+		t.Fatalf("EncodeDataRow failed: %v", err)
+	}
+	got, err := server.DecodeDataRow(payload)
+	if err != nil {
+		t.Fatalf("DecodeDataRow failed: %v", err)
+	}
+	if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", values) {
+		t.Errorf("expected %#v, got %#v", values, got)
+	}
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParser_BooleanPredicate(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"select id from t where a = 1 or b = 2", "select id from t where (a=1 OR b=2)"},
+		// AND binds tighter than OR
+		{"select id from t where a = 1 and b = 2 or c = 3", "select id from t where (a=1 AND b=2 OR c=3)"},
+		{"select id from t where a = 1 and (b = 2 or c = 3)", "select id from t where a=1 AND (b=2 OR c=3)"},
+		{"select id from t where (a = 1 and b = 2)", "select id from t where a=1 AND b=2"},
+		{"select id from t where not a = 1 and b = 2", "select id from t where NOT (a=1) AND b=2"},
+		{"select id from t where not (a = 1 or b is null)", "select id from t where NOT ((a=1 OR b is null))"},
+		{"select id from t where a = 1 OR NOT (b < 2 AND c IS NOT NULL) or d <> 4", "select id from t where (a=1 OR NOT (b<2 AND c is not null) OR d<>4)"},
+	}
+	for _, tt := range tests {
+		data, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		// Views are stored as their query text, so it must parse back the same
+		got := data.String()
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		again, err := parse.NewParser(got).Query()
+		if err != nil {
+			t.Errorf("%s: parsing %q back failed: %v", tt.sql, got, err)
+		} else if again.String() != got {
+			t.Errorf("%s: expected %q to parse back the same, got %q", tt.sql, got, again.String())
+		}
+	}
+
+	for _, sql := range []string{
+		"select id from t where a = 1 or",
+		"select id from t where (a = 1 or b = 2",
+		"select id from t where not",
+		"select id from t where a = 1 and or b = 2",
+	} {
+		if _, err := parse.NewParser(sql).Query(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestBooleanPredicate_Query(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "booleandb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), dept int)",
+		"insert into emp (id, name, dept) values (1, 'ann', 10)",
+		"insert into emp (id, name, dept) values (2, 'bo', 20)",
+		"insert into emp (id, name, dept) values (3, 'cy', NULL)",
+		"insert into emp (id, name, dept) values (4, 'dee', 30)",
+		"create view some as select id from emp where dept = 10 or id = 4",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select id from emp where dept = 10 or dept = 30", "[[1] [4]]"},
+		{"select id from emp where id > 1 and (dept = 10 or dept = 20)", "[[2]]"},
+		{"select id from emp where id = 1 or id = 2 and dept = 10", "[[1]]"},
+		{"select id from emp where not (dept = 10 or dept = 20)", "[[4]]"},
+		// A comparison with null is unknown, and so is its negation, but
+		// an alternative that holds makes the disjunction true
+		{"select id from emp where not dept = 10", "[[2] [4]]"},
+		{"select id from emp where dept = 20 or dept is null", "[[2] [3]]"},
+		{"select id from emp where not (dept = 20 or id = 3)", "[[1] [4]]"},
+		{"select id from emp where not not dept = 20", "[[2]]"},
+		{"select id from some", "[[1] [4]]"},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		sort.Strings(records)
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	if _, err := d.Exec("update emp set dept = 40 where dept is null or id = 1"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if _, err := d.Exec("delete from emp where not (dept = 40)"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select id from emp where dept = 40 or dept = 20")); got != "[[1] [3]]" {
+		t.Errorf("expected [[1] [3]], got %s", got)
+	}
+}
+
+func TestBooleanPredicate_HeuristicPlanner(t *testing.T) {
+	db := openOptimizerTestDB(t)
+
+	// An equation within a disjunction can't select through an index
+	explained, records := planQuery(t, db, "select name from emp where id = 3 or id = 97")
+	if strings.Contains(explained, "index select") {
+		t.Errorf("unexpected index select in plan\n%s", explained)
+	}
+	if got := strings.Join(records, ", "); got != "e3, e97" {
+		t.Errorf("expected e3, e97, got %s", got)
+	}
+
+	// A disjunction over both tables is applied once they are joined
+	explained, records = planQuery(t, db, "select name, dname from emp, dept where deptid = did and (id = 13 or dname = 'd1') and id < 20")
+	if !strings.Contains(explained, "index join") {
+		t.Errorf("expected an index join in plan\n%s", explained)
+	}
+	if got := strings.Join(records, ", "); got != "e1 d1, e11 d1, e13 d3, e16 d1, e6 d1" {
+		t.Errorf("unexpected records %s", got)
+	}
+}
@@ -0,0 +1,172 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestBoolean_Constants(t *testing.T) {
+	yes, no := types.NewConstantBool(true), types.NewConstantBool(false)
+	if yes.String() != "true" || no.String() != "false" {
+		t.Errorf("expected true and false, got %s and %s", yes, no)
+	}
+	if yes.Kind() != types.KIND_BOOL {
+		t.Errorf("expected a boolean, got kind %d", yes.Kind())
+	}
+	if no.CompareTo(yes) >= 0 || !yes.Equals(types.NewConstantBool(true)) {
+		t.Errorf("expected false before true")
+	}
+	if yes.HashCode() != types.NewConstantBool(true).HashCode() || yes.HashCode() == no.HashCode() {
+		t.Errorf("expected equal booleans to hash alike")
+	}
+
+	// Booleans are neither numbers nor strings
+	if yes.Equals(types.NewConstantInt(1)) || yes.Equals(types.NewConstantString("true")) {
+		t.Errorf("expected true to differ from 1 and 'true'")
+	}
+}
+
+func TestParser_Boolean(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"select id from t where active = true", "select id from t where active=true"},
+		{"select id from t where active", "select id from t where active=true"},
+		{"select id from t where active and id > 3", "select id from t where active=true AND id>3"},
+		{"select id from t where not active or FALSE", "select id from t where (NOT (active=true) OR false=true)"},
+		{"select id from t where (active)", "select id from t where active=true"},
+	}
+	for _, tt := range tests {
+		data, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := data.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+	}
+
+	// TRUE and FALSE are keywords, so no field can be named after them
+	for _, sql := range []string{
+		"select id from true",
+		"create table t (true int)",
+		"select id from t where 'x'",
+	} {
+		var err error
+		if _, err = parse.NewParser(sql).Query(); err == nil {
+			_, err = parse.NewParser(sql).UpdateCmd()
+		}
+		if !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestBoolean_Query(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "booleandb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"create table account (id int, name varchar(8), active boolean)",
+		"insert into account (id, name, active) values (1, 'ann', true), (2, 'bob', false), (3, 'cid', TRUE)",
+		"insert into account (id, name, active) values (4, 'dan', null)",
+		"create index account_active on account (active)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select id, active from account where id = 1", "[[1 true]]"},
+		{"select id from account where active", "[[1] [3]]"},
+		{"select id from account where not active", "[[2]]"},
+		{"select id from account where active = false", "[[2]]"},
+		{"select id from account where active <> true and id > 0", "[[2]]"},
+		{"select id from account where active and name <> 'ann'", "[[3]]"},
+		{"select id from account where active or id = 4", "[[1] [3] [4]]"},
+		{"select id from account where active is null", "[[4]]"},
+		{"select id from account where active in (false, null)", "[[2]]"},
+		{"select active, count(*) from account group by active", "[[<nil> 1] [false 1] [true 2]]"},
+		{"select id, true as yes from account where id = 2", "[[2 true]]"},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		sort.Strings(records)
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	if n, err := d.Exec("update account set active = true where not active"); err != nil || n != 1 {
+		t.Fatalf("update: expected 1 record, got %d, %v", n, err)
+	}
+	stmt, err := d.Prepare("select id from account where active = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	rows, err := stmt.Query(true)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	count := 0
+	for rows.Next() {
+		if _, ok := rows.Row().Value("id").(int); !ok {
+			t.Errorf("expected an int id, got %v", rows.Row().Value("id"))
+		}
+		count++
+	}
+	rows.Close()
+	if count != 3 {
+		t.Errorf("expected 3 active accounts, got %d", count)
+	}
+
+	for _, stmt := range []string{
+		"select id from account where active = 1",
+		"select id from account where active = 'true'",
+		"select id from account where id",
+		"select id from account where active like 't%'",
+	} {
+		if _, err := d.Query(stmt); !errors.Is(err, plan.ErrTypeMismatch) {
+			t.Errorf("%s: expected %v, got %v", stmt, plan.ErrTypeMismatch, err)
+		}
+	}
+	if _, err := d.Exec("insert into account (id, active) values (5, 1)"); err == nil {
+		t.Errorf("expected inserting an int into a boolean field to fail")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The values are kept on disk, and read as bools
+	d, err = db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+	rows, err = d.Query("select active from account where id = 2")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	defer rows.Close()
+	if !rows.Next() || rows.Row().Value("active") != true {
+		t.Errorf("expected account 2 to be active")
+	}
+	if rows.Columns()[0].Type != db.BOOLEAN {
+		t.Errorf("expected a boolean column, got %s", rows.Columns()[0].Type)
+	}
+}
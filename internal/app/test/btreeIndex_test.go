@@ -0,0 +1,288 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/dump"
+	"centauri/internal/app/index/planner"
+	"centauri/internal/app/optimization"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/query"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_CreateIndexUsing(t *testing.T) {
+	for _, tc := range []struct {
+		sql  string
+		want string
+	}{
+		{"create index people_age on people (age)", "hash"},
+		{"create index people_age on people (age) using hash", "hash"},
+		{"create index people_age on people (age) USING BTREE", "btree"},
+	} {
+		data, err := parse.NewParser(tc.sql).UpdateCmd()
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tc.sql, err)
+		}
+		if got := data.(*parse.CreateIndexData).IndexType(); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.sql, tc.want, got)
+		}
+	}
+
+	if _, err := parse.NewParser("create index people_age on people (age) using gist").UpdateCmd(); !errors.Is(err, parse.ErrSyntax) {
+		t.Errorf("expected %v, got %v", parse.ErrSyntax, err)
+	}
+}
+
+func TestBTreeIndex_RangeSelect(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	for _, stmt := range []string{
+		"create table people (id int, age int, name varchar(20))",
+		"create index people_age on people (age) using btree",
+		"insert into people (id, age, name) values (9999, null, 'nobody')",
+	} {
+		db.exec(t, stmt)
+	}
+	// Inserted out of order, so the index returns them sorted
+	var values []string
+	for i := 0; i < 500; i++ {
+		age := (i * 37) % 500
+		values = append(values, fmt.Sprintf("(%d, %d, 'person %d')", age, age, age))
+	}
+	db.exec(t, "insert into people (id, age, name) values "+strings.Join(values, ", "))
+
+	for _, tc := range []struct {
+		query string
+		plan  string
+		want  string
+	}{
+		{"select id from people where age > 495", "index select people_age > 495", "[496 497 498 499]"},
+		{"select id from people where 3 > age", "index select people_age < 3", "[0 1 2]"},
+		{"select id from people where age >= 200 and age < 203", "index select people_age >= 200 and < 203", "[200 201 202]"},
+		{"select id from people where age between 300 and 302 and id <> 301", "index select people_age >= 300 and <= 302", "[300 302]"},
+	} {
+		plan, records := planQuery(t, db, tc.query)
+		if !strings.Contains(plan, tc.plan) {
+			t.Errorf("%s: expected %q in the plan, got:\n%s", tc.query, tc.plan, plan)
+		}
+		if got := fmt.Sprint(records); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.query, tc.want, got)
+		}
+	}
+
+	// A range holding most of the records is cheaper to scan
+	if plan, _ := planQuery(t, db, "select id from people where age > 10"); strings.Contains(plan, "index select") {
+		t.Errorf("expected a table scan, got:\n%s", plan)
+	}
+
+	// The index follows the changes of the records
+	db.exec(t, "delete from people where age > 490")
+	db.exec(t, "update people set age = 1000 where age >= 480")
+	if _, records := planQuery(t, db, "select id from people where age > 470 and age < 480"); len(records) != 9 {
+		t.Errorf("expected 9 records, got %v", records)
+	}
+	if _, records := planQuery(t, db, "select id from people where age >= 1000"); len(records) != 11 {
+		t.Errorf("expected 11 records, got %v", records)
+	}
+}
+
+func TestBTreeIndex_Catalog(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "btreedb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table people (id int, age int, name varchar(50))",
+		"create index people_age on people (age) using btree",
+		"create index people_id on people (id)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	if _, err := d.Exec("create index people_both on people (id, age) using btree"); err == nil {
+		t.Errorf("expected a btree index on two fields to fail")
+	}
+
+	desc, err := d.DescribeTable("people")
+	if err != nil {
+		t.Fatalf("DescribeTable failed: %v", err)
+	}
+	types := map[string]string{}
+	for _, idx := range desc.Indexes {
+		types[idx.Name] = idx.Type
+	}
+	if types["people_age"] != "btree" || types["people_id"] != "hash" {
+		t.Errorf("unexpected index types %v", types)
+	}
+
+	var out strings.Builder
+	if err := dump.Dump(d, &out, nil); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	for _, want := range []string{
+		"create index people_age on people (age) using btree;",
+		"create index people_id on people (id);",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected the dump to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestBTreeIndex_OrderBy(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	for _, stmt := range []string{
+		"create table people (id int, age int)",
+		"create index people_age on people (age) using btree",
+		"insert into people (id, age) values (1000, null)",
+	} {
+		db.exec(t, stmt)
+	}
+	var values []string
+	for i := 0; i < 300; i++ {
+		age := (i * 37) % 300
+		values = append(values, fmt.Sprintf("(%d, %d)", age, age))
+	}
+	db.exec(t, "insert into people (id, age) values "+strings.Join(values, ", "))
+
+	// Returns the plan of the query and the ids it outputs, in order
+	orderedIDs := func(query string) (string, []string) {
+		data, err := parse.NewParser(query).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		p, err := optimization.NewHeuristicQueryPlanner(db.mdm).CreatePlan(data, db.tx)
+		if err != nil {
+			t.Fatalf("%s: CreatePlan failed: %v", query, err)
+		}
+		s := p.Open()
+		defer s.Close()
+		var ids []string
+		for s.Next() {
+			ids = append(ids, s.GetVal("id").String())
+		}
+		return plan.Explain(p), ids
+	}
+
+	for _, tc := range []struct {
+		query string
+		plan  string
+		want  string
+	}{
+		{"select id from people where age < 5 order by age desc", "index select people_age < 5 descending", "[4 3 2 1 0]"},
+		{"select id from people where age >= 295 order by age", "index select people_age >= 295", "[295 296 297 298 299]"},
+		{"select id from people order by age desc limit 3", "index scan people_age descending", "[1000 299 298]"},
+		{"select id from people order by age desc nulls last limit 3", "index scan people_age descending", "[299 298 297]"},
+		{"select id from people order by age limit 3", "index scan people_age", "[0 1 2]"},
+		{"select id from people order by age nulls first limit 3", "index scan people_age", "[1000 0 1]"},
+	} {
+		explain, ids := orderedIDs(tc.query)
+		if !strings.Contains(explain, tc.plan) || strings.Contains(explain, "sort") {
+			t.Errorf("%s: expected %q and no sort in the plan, got:\n%s", tc.query, tc.plan, explain)
+		}
+		if got := fmt.Sprint(ids); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.query, tc.want, got)
+		}
+	}
+
+	// Reading every record through the index costs more than sorting them
+	if explain, _ := orderedIDs("select id from people order by age desc"); !strings.Contains(explain, "sort") {
+		t.Errorf("expected a sort, got:\n%s", explain)
+	}
+	if explain, _ := orderedIDs("select id from people order by id desc limit 3"); !strings.Contains(explain, "sort") {
+		t.Errorf("expected a sort without an index, got:\n%s", explain)
+	}
+
+	// Through the index, the null keys come after the others
+	tp, err := plan.NewTablePlan(db.tx, "people", db.mdm)
+	if err != nil {
+		t.Fatalf("NewTablePlan failed: %v", err)
+	}
+	p := planner.MakeIndexOrderedSelect(tp, query.NewPredicate(), db.mdm.GetIndexInfo("people", db.tx), "age", true, false)
+	s := p.Open()
+	defer s.Close()
+	var ages []string
+	nulls := 0
+	for s.Next() {
+		if s.GetVal("age").IsNull() {
+			nulls++
+		} else if nulls > 0 {
+			t.Fatalf("expected the nulls last")
+		} else {
+			ages = append(ages, s.GetVal("age").String())
+		}
+	}
+	if len(ages) != 300 || ages[0] != "299" || ages[299] != "0" || nulls != 1 {
+		t.Errorf("expected every age from the largest down, then a null, got %v and %d nulls", ages, nulls)
+	}
+}
+
+func TestCreateIndex_Backfill(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	db.exec(t, "create table people (id int, age int)")
+	var values []string
+	for i := 0; i < 300; i++ {
+		values = append(values, fmt.Sprintf("(%d, %d)", i, (i*37)%100))
+	}
+	db.exec(t, "insert into people (id, age) values "+strings.Join(values, ", ")+", (300, null)")
+	db.exec(t, "create index people_age on people (age) using btree")
+	db.exec(t, "create index people_id on people (id)")
+
+	for _, tc := range []struct {
+		query string
+		plan  string
+		want  string
+	}{
+		{"select id from people where age = 37", "index select people_age = 37", "[1 101 201]"},
+		{"select id from people where age > 97", "index select people_age > 97", "[127 154 227 254 27 54]"},
+		{"select age from people where id = 299", "index select people_id = 299", "[63]"},
+	} {
+		plan, records := planQuery(t, db, tc.query)
+		if !strings.Contains(plan, tc.plan) {
+			t.Errorf("%s: expected %q in the plan, got:\n%s", tc.query, tc.plan, plan)
+		}
+		if got := fmt.Sprint(records); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.query, tc.want, got)
+		}
+	}
+}
+
+func TestCreateIndex_BackfillExec(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "backfilldb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table people (id int, age int)",
+		"insert into people (id, age) values (1, 30), (2, 70), (3, null), (4, 50)",
+		"create index people_age on people (age) using btree",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	// The minimum and maximum are read from the index, which has the
+	// records inserted before it was created and those inserted since
+	if got := fmt.Sprint(queryRecords(t, d, "select min(age), max(age) from people")); got != "[[30 70]]" {
+		t.Errorf("expected [[30 70]], got %s", got)
+	}
+	if _, err := d.Exec("insert into people (id, age) values (5, 90)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select min(age), max(age) from people")); got != "[[30 90]]" {
+		t.Errorf("expected [[30 90]], got %s", got)
+	}
+	if explain := strings.Join(queryRecords(t, d, "explain select max(age) from people"), "\n"); !strings.Contains(explain, "index aggregate") {
+		t.Errorf("expected the index to answer max, got:\n%s", explain)
+	}
+}
@@ -3,6 +3,7 @@ package test
 import (
 	"centauri/internal/app/buffer"
 	"centauri/internal/app/file"
+	"centauri/internal/app/index"
 	"centauri/internal/app/index/btree"
 	"centauri/internal/app/log"
 	"centauri/internal/app/record"
@@ -11,6 +12,8 @@ import (
 	"centauri/internal/app/types"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -510,7 +513,12 @@ func TestReopenIndex(t *testing.T) {
 	txn1.Commit()
 
 	// Second transaction - reopen the index and verify contents
+	// A reopened database recovers first, redoing the committed
+	// changes that only reached the log
 	txn2 := createTx(t, dbDir)
+	if err := txn2.Recover(); err != nil {
+		t.Fatalf("Failed to recover: %v", err)
+	}
 	idx2 := createIntIndex(t, txn2, "reopentest")
 	defer idx2.Close()
 	defer txn2.Commit()
@@ -558,3 +566,233 @@ func TestSearchCost(t *testing.T) {
 		})
 	}
 }
+
+func TestMinMaxKeys(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "edgedb"))
+	defer db.fm.Close()
+
+	txn := db.newTx()
+	defer txn.Commit()
+
+	idx := createIntIndex(t, txn, "edgetest")
+	defer idx.Close()
+
+	if idx.MinKey() != nil || idx.MaxKey() != nil {
+		t.Fatalf("expected no keys in an empty index")
+	}
+
+	// Enough records to split the leaves, inserted out of order
+	for i := 0; i < 200; i++ {
+		key := (i * 37) % 200
+		idx.Insert(types.NewConstantInt(key), types.NewRID(key, 0))
+	}
+	if got := idx.MinKey(); !got.Equals(types.NewConstantInt(0)) {
+		t.Errorf("expected min 0, got %v", got)
+	}
+	if got := idx.MaxKey(); !got.Equals(types.NewConstantInt(199)) {
+		t.Errorf("expected max 199, got %v", got)
+	}
+
+	// Emptying the edge leaves moves the edges inwards
+	for key := 0; key < 50; key++ {
+		idx.Delete(types.NewConstantInt(key), types.NewRID(key, 0))
+	}
+	for key := 150; key < 200; key++ {
+		idx.Delete(types.NewConstantInt(key), types.NewRID(key, 0))
+	}
+	if got := idx.MinKey(); !got.Equals(types.NewConstantInt(50)) {
+		t.Errorf("expected min 50 after deletes, got %v", got)
+	}
+	if got := idx.MaxKey(); !got.Equals(types.NewConstantInt(149)) {
+		t.Errorf("expected max 149 after deletes, got %v", got)
+	}
+}
+
+func TestBeforeFirstRange(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "rangedb"))
+	defer db.fm.Close()
+
+	txn := db.newTx()
+	defer txn.Commit()
+
+	idx := createIntIndex(t, txn, "rangetest")
+	defer idx.Close()
+
+	// Enough records to split the leaves, inserted out of order, with
+	// overflow blocks for key 100 and nulls, which are in no range
+	for i := 0; i < 200; i++ {
+		key := (i * 37) % 200
+		idx.Insert(types.NewConstantInt(key), types.NewRID(key, 0))
+	}
+	for i := 1; i <= 60; i++ {
+		idx.Insert(types.NewConstantInt(100), types.NewRID(100, i))
+	}
+	for i := 0; i < 3; i++ {
+		idx.Insert(types.NewConstantNull(), types.NewRID(-1, i))
+	}
+
+	// The keys are read back from the block numbers of the RIDs
+	scan := func(rng index.KeyRange) []int {
+		idx.BeforeFirstRange(rng)
+		var keys []int
+		for idx.Next() {
+			keys = append(keys, idx.GetDataRid().BlockNumber())
+		}
+		return keys
+	}
+	keyRange := func(from, to int) []int {
+		var keys []int
+		for key := from; key <= to; key++ {
+			keys = append(keys, key)
+			if key == 100 {
+				keys = append(keys, slices.Repeat([]int{100}, 60)...)
+			}
+		}
+		return keys
+	}
+
+	for _, tc := range []struct {
+		name string
+		rng  index.KeyRange
+		want []int
+	}{
+		{"closed", index.KeyRange{Low: types.NewConstantInt(50), High: types.NewConstantInt(60), LowInclusive: true, HighInclusive: true}, keyRange(50, 60)},
+		{"open", index.KeyRange{Low: types.NewConstantInt(50), High: types.NewConstantInt(60)}, keyRange(51, 59)},
+		{"no low bound", index.KeyRange{High: types.NewConstantInt(5)}, keyRange(0, 4)},
+		{"no high bound", index.KeyRange{Low: types.NewConstantInt(190)}, keyRange(191, 199)},
+		{"unbounded", index.KeyRange{}, keyRange(0, 199)},
+		{"overflow", index.KeyRange{Low: types.NewConstantInt(99), High: types.NewConstantInt(101), LowInclusive: true, HighInclusive: true}, keyRange(99, 101)},
+		{"single key", index.KeyRange{Low: types.NewConstantInt(100), High: types.NewConstantInt(100), LowInclusive: true, HighInclusive: true}, keyRange(100, 100)},
+		{"empty", index.KeyRange{Low: types.NewConstantInt(60), High: types.NewConstantInt(50), LowInclusive: true, HighInclusive: true}, nil},
+		{"below the keys", index.KeyRange{Low: types.NewConstantLong(-1 << 40), High: types.NewConstantInt(2), LowInclusive: true}, keyRange(0, 1)},
+		{"above the keys", index.KeyRange{Low: types.NewConstantInt(197), High: types.NewConstantLong(1 << 40)}, keyRange(198, 199)},
+	} {
+		if got := scan(tc.rng); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+
+		// Read in reverse, the range holds the same keys from the largest down
+		tc.rng.Descending = true
+		want := slices.Clone(tc.want)
+		slices.Reverse(want)
+		if got := scan(tc.rng); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s descending: expected %v, got %v", tc.name, want, got)
+		}
+	}
+	if got := scan(index.KeyRange{Nulls: true, Descending: true}); fmt.Sprint(got) != "[-1 -1 -1]" {
+		t.Errorf("expected the null keys, got %v", got)
+	}
+
+	// Leaves emptied by deletions are skipped
+	for key := 20; key < 180; key++ {
+		if key != 100 {
+			idx.Delete(types.NewConstantInt(key), types.NewRID(key, 0))
+		}
+	}
+	rng := index.KeyRange{Low: types.NewConstantInt(10), High: types.NewConstantInt(190), HighInclusive: true}
+	want := append(append(keyRange(11, 19), keyRange(100, 100)...), keyRange(180, 190)...)
+	if got := scan(rng); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v after deletes, got %v", want, got)
+	}
+	rng.Descending = true
+	slices.Reverse(want)
+	if got := scan(rng); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v descending after deletes, got %v", want, got)
+	}
+
+	// A search for a key still works after a range
+	idx.BeforeFirst(types.NewConstantInt(19))
+	if !idx.Next() || idx.GetDataRid().BlockNumber() != 19 || idx.Next() {
+		t.Errorf("expected to find key 19 once")
+	}
+}
+
+func TestBTreeIndex_Load(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "loaddb"))
+	defer db.fm.Close()
+
+	txn := db.newTx()
+	defer txn.Commit()
+
+	idx := createIntIndex(t, txn, "loadtest")
+	defer idx.Close()
+
+	// Nulls first, then enough keys for two directory levels,
+	// with overflow blocks for key 1500
+	idx.Load(func(yield func(*types.Constant, *types.RID) bool) {
+		for i := 0; i < 5; i++ {
+			yield(types.NewConstantNull(), types.NewRID(-1, i))
+		}
+		for key := 0; key < 3000; key++ {
+			copies := 1
+			if key == 1500 {
+				copies = 101
+			}
+			for i := 0; i < copies; i++ {
+				yield(types.NewConstantInt(key), types.NewRID(key, i))
+			}
+		}
+	})
+	if blocks, _ := txn.Size("loadtestdir"); blocks < 3 {
+		t.Errorf("expected directory pages below the root, got %d blocks", blocks)
+	}
+
+	count := func(key int) int {
+		idx.BeforeFirst(types.NewConstantInt(key))
+		n := 0
+		for idx.Next() {
+			if idx.GetDataRid().BlockNumber() != key {
+				t.Fatalf("found key %d looking for %d", idx.GetDataRid().BlockNumber(), key)
+			}
+			n++
+		}
+		return n
+	}
+	for _, key := range []int{0, 1, 777, 1499, 1501, 2999} {
+		if n := count(key); n != 1 {
+			t.Errorf("expected key %d once, found it %d times", key, n)
+		}
+	}
+	if n := count(1500); n != 101 {
+		t.Errorf("expected key 1500 101 times, found it %d times", n)
+	}
+	if n := idx.NumEntries(); n != 3105 {
+		t.Errorf("expected 3105 entries, got %d", n)
+	}
+	if idx.MinKey().String() != "0" || idx.MaxKey().String() != "2999" {
+		t.Errorf("expected keys from 0 to 2999, got %v to %v", idx.MinKey(), idx.MaxKey())
+	}
+
+	for _, descending := range []bool{false, true} {
+		idx.BeforeFirstRange(index.KeyRange{Descending: descending})
+		var keys []int
+		for idx.Next() {
+			keys = append(keys, idx.GetDataRid().BlockNumber())
+		}
+		if descending {
+			slices.Reverse(keys)
+		}
+		if len(keys) != 3100 || !slices.IsSorted(keys) || keys[0] != 0 || keys[3099] != 2999 {
+			t.Errorf("expected the keys in order, descending %v, got %d keys", descending, len(keys))
+		}
+	}
+
+	// The loaded pages split like inserted ones
+	for key := 0; key < 3000; key += 7 {
+		idx.Insert(types.NewConstantInt(key), types.NewRID(key, 200))
+	}
+	idx.Delete(types.NewConstantInt(777), types.NewRID(777, 0))
+	for key, want := range map[int]int{0: 2, 1: 1, 7: 2, 777: 1, 1500: 101, 2996: 2, 2999: 1} {
+		if n := count(key); n != want {
+			t.Errorf("expected key %d %d times, found it %d times", key, want, n)
+		}
+	}
+
+	// Entries loaded into an index that isn't empty are inserted
+	idx.Load(func(yield func(*types.Constant, *types.RID) bool) {
+		yield(types.NewConstantInt(5000), types.NewRID(5000, 0))
+	})
+	if n := count(5000); n != 1 || idx.MaxKey().String() != "5000" {
+		t.Errorf("expected key 5000 to be inserted, found it %d times", n)
+	}
+}
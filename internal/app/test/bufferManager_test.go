@@ -6,6 +6,7 @@ import (
 	"centauri/internal/app/log"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -163,7 +164,7 @@ func TestBufferManager_Flushall(t *testing.T) {
 	defer cleanup()
 
 	fileName := "testfile"
-	testFile, err := os.Create("./testdb" + fileName)
+	testFile, err := os.Create(filepath.Join("./testdb", fileName))
 	if err != nil {
 		t.Fatalf("Failed to create test file: %v", err)
 	}
@@ -0,0 +1,170 @@
+package test
+
+import (
+	"centauri/config"
+	"centauri/db"
+	"centauri/internal/app/record"
+	"centauri/internal/app/server"
+	"errors"
+	"fmt"
+	"iter"
+	"path/filepath"
+	"testing"
+)
+
+// Yields n rows (i, 'n<i>') with i counting from 0
+func bulkTestRows(n int) iter.Seq[[]any] {
+	return func(yield func([]any) bool) {
+		for i := 0; i < n; i++ {
+			if !yield([]any{i, fmt.Sprintf("n%d", i)}) {
+				return
+			}
+		}
+	}
+}
+
+// Returns the number of records of the query's result
+func countRows(t *testing.T, d *db.DB, query string) int {
+	t.Helper()
+	rows, err := d.Query(query)
+	if err != nil {
+		t.Fatalf("%s failed: %v", query, err)
+	}
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	return count
+}
+
+func TestDB_BulkInsert(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "bulkdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table t (id int, name varchar(6))",
+		"create index t_id on t (id)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	count, err := d.BulkInsert("t", []string{"id", "name"}, bulkTestRows(500))
+	if err != nil {
+		t.Fatalf("BulkInsert failed: %v", err)
+	}
+	if count != 500 {
+		t.Errorf("expected 500 records, got %d", count)
+	}
+	if got := countRows(t, d, "select id from t"); got != 500 {
+		t.Errorf("expected 500 records in the table, got %d", got)
+	}
+
+	rows, err := d.Query("select name from t where id = 321")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if !rows.Next() || rows.Row().String("name") != "n321" {
+		t.Error("expected to find a loaded record")
+	}
+	rows.Close()
+
+	// A row that can't be stored rolls back the whole insert
+	bad := func(yield func([]any) bool) {
+		for i := 1000; i < 1100; i++ {
+			row := []any{i, "ok"}
+			if i == 1090 {
+				row = []any{"oops", "bad"}
+			}
+			if !yield(row) {
+				return
+			}
+		}
+	}
+	if _, err := d.BulkInsert("t", []string{"id", "name"}, bad); !errors.Is(err, record.ErrFieldType) {
+		t.Errorf("expected ErrFieldType, got %v", err)
+	}
+	if _, err := d.BulkInsert("t", nil, func(yield func([]any) bool) { yield([]any{1, "toolong!"}) }); err == nil {
+		t.Error("expected a value too long for its field to fail")
+	}
+
+	// The records of a rolled back insert are gone, and their slots are reused
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx.BulkInsert("t", nil, bulkTestRows(100)); err != nil {
+		t.Fatalf("BulkInsert failed: %v", err)
+	}
+	tx.Rollback()
+
+	if got := countRows(t, d, "select id from t"); got != 500 {
+		t.Errorf("expected 500 records after the rollbacks, got %d", got)
+	}
+	if _, err := d.Exec("insert into t (id, name) values (1000, 'last')"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if got := countRows(t, d, "select id from t where name = 'last'"); got != 1 {
+		t.Errorf("expected the inserted record, got %d", got)
+	}
+}
+
+func TestDB_BulkInsertStandby(t *testing.T) {
+	primaryDir := filepath.Join(t.TempDir(), "primary")
+	d, err := db.Open(primaryDir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+	if _, err := d.Exec("create table t (id int, name varchar(6))"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	// The standby starts from a backup and applies the primary's log
+	backupDir := filepath.Join(t.TempDir(), "backup")
+	if err := d.Backup(backupDir); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	standbyDir := filepath.Join(t.TempDir(), "standby")
+	if err := db.Restore(backupDir, standbyDir, nil); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	// The load changes its blocks without logging their values
+	if count, err := d.BulkInsert("t", []string{"id", "name"}, bulkTestRows(500)); err != nil || count != 500 {
+		t.Fatalf("BulkInsert: expected 500 records, got %d, %v", count, err)
+	}
+
+	cfg := config.Default()
+	cfg.DataDir = standbyDir
+	cfg.StandbyLogDir = primaryDir
+	standby, err := server.OpenCentauriDBFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("opening the standby failed: %v", err)
+	}
+	defer standby.Close()
+	if _, err := standby.Standby().Apply(); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	s := server.NewSession(standby)
+	defer s.Close()
+	records := querySession(t, s, "select id, name from t")
+	if len(records) != 500 {
+		t.Fatalf("expected 500 records on the standby, got %d", len(records))
+	}
+	for _, record := range records {
+		var id int
+		var name string
+		if _, err := fmt.Sscan(record, &id, &name); err != nil || name != fmt.Sprintf("n%d", id) {
+			t.Errorf("expected the loaded values on the standby, got %q", record)
+		}
+	}
+	if got := querySession(t, s, "select count(*) from t"); fmt.Sprint(got) != "[500]" {
+		t.Errorf("expected a count of 500 on the standby, got %v", got)
+	}
+}
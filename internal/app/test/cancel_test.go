@@ -0,0 +1,50 @@
+package test
+
+import (
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCancel_StopsScan(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "canceldb"))
+	defer db.fm.Close()
+
+	sch := schema.NewSchema()
+	sch.AddIntField("a")
+	layout := record.NewLayout(sch)
+
+	tx1 := db.newTx()
+	ts := record.NewTableScan(tx1, "cancel", layout)
+	ts.Insert()
+	ts.SetInt("a", 1)
+	ts.BeforeFirst()
+
+	tx1.Cancel()
+	if !tx1.Cancelled() {
+		t.Fatal("expected the transaction to be cancelled")
+	}
+
+	func() {
+		defer func() {
+			r := recover()
+			err, ok := r.(error)
+			if !ok || !errors.Is(err, tx.ErrQueryCancelled) {
+				t.Errorf("expected scan to panic with ErrQueryCancelled, got %v", r)
+			}
+		}()
+		ts.Next()
+	}()
+
+	// Once reset, the next statement of the transaction can run
+	tx1.ResetCancel()
+	ts.BeforeFirst()
+	if !ts.Next() || ts.GetInt("a") != 1 {
+		t.Error("expected to read the record after ResetCancel")
+	}
+	ts.Close()
+	tx1.Rollback()
+}
@@ -0,0 +1,72 @@
+package test
+
+import (
+	"centauri/db"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestCatalogInfo_Query(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "catalogdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table items (id int, name varchar(8))",
+		"create view named as select name from items",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	// SHOW TABLES and DESCRIBE are answered as they are in a session
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"show tables", "[[items table] [named view]]"},
+		{"describe named", "[[name varchar 8 <nil>]]"},
+		{"desc named", "[[name varchar 8 <nil>]]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(queryRecords(t, d, tt.query)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+	if got := queryRecords(t, d, "describe items"); len(got) != 2 {
+		t.Errorf("expected the 2 columns of items, got %v", got)
+	}
+
+	// A transaction sees the tables it created
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx.Exec("create table orders (id int)"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	rows, err := tx.Query("show tables")
+	if err != nil {
+		t.Fatalf("show tables failed: %v", err)
+	}
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	rows.Close()
+	if count != 3 {
+		t.Errorf("expected 3 tables and views, got %d", count)
+	}
+	tx.Rollback()
+
+	// The other session commands need a session
+	for _, query := range []string{"describe nope", "show isolation_level", "show databases"} {
+		if _, err := d.Query(query); err == nil {
+			t.Errorf("%s: expected an error", query)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package test
+
+import (
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Returns the layout of the bootstrap record holding the catalog version
+func catalogVersionLayout() *record.Layout {
+	sch := schema.NewSchema()
+	sch.AddIntField("version")
+	return record.NewLayout(sch)
+}
+
+// Returns the catalog version in the bootstrap record, -1 if there is none
+func catalogVersion(t1 *tx.Transaction) int {
+	ts := record.NewTableScan(t1, metadata.CATALOG_VERSION_TABLE, catalogVersionLayout())
+	defer ts.Close()
+	if !ts.Next() {
+		return -1
+	}
+	return ts.GetInt("version")
+}
+
+// Overwrites the catalog version in the bootstrap record
+func setCatalogVersion(t1 *tx.Transaction, version int) {
+	ts := record.NewTableScan(t1, metadata.CATALOG_VERSION_TABLE, catalogVersionLayout())
+	defer ts.Close()
+	ts.Next()
+	ts.SetInt("version", version)
+}
+
+// Deletes the records of a catalog table whose field has the value
+func deleteCatalogRecords(t *testing.T, mdm *metadata.MetaDataManager, t1 *tx.Transaction, table string, field string, val string) {
+	t.Helper()
+	layout, err := mdm.GetLayout(table, t1)
+	if err != nil {
+		t.Fatalf("GetLayout(%s) failed: %v", table, err)
+	}
+	ts := record.NewTableScan(t1, table, layout)
+	defer ts.Close()
+	for ts.Next() {
+		if ts.GetString(field) == val {
+			ts.Delete()
+		}
+	}
+}
+
+// Returns the number of records of a catalog table
+func catalogRecords(t *testing.T, mdm *metadata.MetaDataManager, t1 *tx.Transaction, table string) int {
+	t.Helper()
+	layout, err := mdm.GetLayout(table, t1)
+	if err != nil {
+		t.Fatalf("GetLayout(%s) failed: %v", table, err)
+	}
+	ts := record.NewTableScan(t1, table, layout)
+	defer ts.Close()
+	count := 0
+	for ts.Next() {
+		count++
+	}
+	return count
+}
+
+func TestCatalogVersion_Migrate(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "catalogdb"))
+	defer db.fm.Close()
+
+	// A new database records the current version
+	tx1 := db.newTx()
+	mdm, err := metadata.NewMetaDataManager(true, tx1)
+	if err != nil {
+		t.Fatalf("NewMetaDataManager failed: %v", err)
+	}
+	if got := catalogVersion(tx1); got != metadata.CATALOG_VERSION {
+		t.Errorf("expected version %d, got %d", metadata.CATALOG_VERSION, got)
+	}
+
+	// Turn the catalog back into version 12, which had no statistics catalogs
+	for _, table := range []string{"statcat", "idxstatcat"} {
+		deleteCatalogRecords(t, mdm, tx1, "fldcat", "tblname", table)
+		deleteCatalogRecords(t, mdm, tx1, "tblcat", "tblname", table)
+	}
+	setCatalogVersion(tx1, 12)
+	tx1.Commit()
+
+	// Opening the database applies the pending migration
+	tx2 := db.newTx()
+	defer tx2.Commit()
+	mdm, err = metadata.NewMetaDataManager(false, tx2)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if got := catalogVersion(tx2); got != metadata.CATALOG_VERSION {
+		t.Errorf("expected the catalog to be migrated to version %d, got %d", metadata.CATALOG_VERSION, got)
+	}
+	for _, table := range []string{"statcat", "idxstatcat"} {
+		if _, err := mdm.GetLayout(table, tx2); err != nil {
+			t.Errorf("expected the migration to create %s: %v", table, err)
+		}
+	}
+
+	// Opening a current catalog applies no migration again
+	tables := catalogRecords(t, mdm, tx2, "tblcat")
+	if mdm, err = metadata.NewMetaDataManager(false, tx2); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if got := catalogRecords(t, mdm, tx2, "tblcat"); got != tables {
+		t.Errorf("expected %d tables in the catalog after reopening, got %d", tables, got)
+	}
+}
+
+func TestCatalogVersion_Newer(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "catalogdb"))
+	defer db.fm.Close()
+
+	tx1 := db.newTx()
+	if _, err := metadata.NewMetaDataManager(true, tx1); err != nil {
+		t.Fatalf("NewMetaDataManager failed: %v", err)
+	}
+	setCatalogVersion(tx1, metadata.CATALOG_VERSION+1)
+	tx1.Commit()
+
+	// A catalog written by a later version isn't opened
+	tx2 := db.newTx()
+	defer tx2.Rollback()
+	_, err := metadata.NewMetaDataManager(false, tx2)
+	if err == nil || !strings.Contains(err.Error(), "newer than the supported version") {
+		t.Errorf("expected the newer catalog to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestParser_Comparison(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"select id from t where id < 3", "select id from t where id<3"},
+		{"select id from t where id <= 3 and id >= 0", "select id from t where id<=3 AND id>=0"},
+		{"select id from t where 3 > id and id <> 2", "select id from t where 3>id AND id<>2"},
+		{"select id from t where a < b", "select id from t where a<b"},
+	}
+	for _, tt := range tests {
+		data, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		// Views are stored as their query text, so it must parse back the same
+		if got := data.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		if _, err := parse.NewParser(data.String()).Query(); err != nil {
+			t.Errorf("%s: parsing %q back failed: %v", tt.sql, data.String(), err)
+		}
+	}
+
+	for _, sql := range []string{"select id from t where id 3", "select id from t where id =< 3", "select id from t where id <"} {
+		if _, err := parse.NewParser(sql).Query(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestComparison_Query(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "comparisondb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), salary int)",
+		"insert into emp (id, name, salary) values (1, 'ann', 300)",
+		"insert into emp (id, name, salary) values (2, 'bo', 100)",
+		"insert into emp (id, name, salary) values (3, 'cy', NULL)",
+		"insert into emp (id, name, salary) values (4, 'dee', 200)",
+		"create view paid as select id from emp where salary >= 200",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select id from emp where salary < 200", "[[2]]"},
+		{"select id from emp where salary <= 200", "[[2] [4]]"},
+		{"select id from emp where salary > 100 and salary < 300", "[[4]]"},
+		// Comparisons with null are unknown, so they hold for neither the
+		// record without a salary nor against it
+		{"select id from emp where salary <> 100", "[[1] [4]]"},
+		{"select id from emp where 250 > salary", "[[2] [4]]"},
+		{"select id from emp where name > 'bo'", "[[3] [4]]"},
+		{"select id from emp where id > salary", "[]"},
+		{"select id from paid", "[[1] [4]]"},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		sort.Strings(records)
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	if _, err := d.Exec("update emp set salary = 0 where salary < 150"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if _, err := d.Exec("delete from emp where id >= 3"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select id, salary from emp where salary < 300")); got != "[[2 0]]" {
+		t.Errorf("expected [[2 0]], got %s", got)
+	}
+}
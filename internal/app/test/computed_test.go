@@ -0,0 +1,184 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/query"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_Computed(t *testing.T) {
+	tests := []struct {
+		sql      string
+		want     string
+		fields   string
+		computed int
+	}{
+		{
+			"select name, price * qty as total from orders",
+			"select name, (price * qty) as total from orders",
+			"[name total]",
+			1,
+		},
+		{
+			"select price*qty+1, 'x', upper(name) from orders",
+			"select ((price * qty) + 1), x, upper(name) from orders",
+			"[((price * qty) + 1) x upper(name)]",
+			3,
+		},
+		{
+			"select name as n, count(*) from orders",
+			"select name as n, count(*) from orders",
+			"[n count]",
+			1,
+		},
+		{
+			"select id, case when qty > 10 then 'bulk' else 'retail' end as kind from orders order by kind",
+			"select id, case when qty>10 then bulk else retail end as kind from orders order by kind",
+			"[id kind]",
+			1,
+		},
+		{
+			"select -price from orders",
+			"select -price from orders",
+			"[-price]",
+			1,
+		},
+	}
+	for _, tt := range tests {
+		data, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := data.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		if got := fmt.Sprint(data.Fields()); got != tt.fields {
+			t.Errorf("%s: expected fields %s, got %s", tt.sql, tt.fields, got)
+		}
+		if got := len(data.Computed()); got != tt.computed {
+			t.Errorf("%s: expected %d computed fields, got %d", tt.sql, tt.computed, got)
+		}
+	}
+
+	// The aliases aren't read from the tables
+	data, err := parse.NewParser("select price * qty as total from orders order by total").Query()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got := fmt.Sprint(data.ReadFields()); got != "[price qty]" {
+		t.Errorf("expected the query to read [price qty], got %s", got)
+	}
+
+	for _, sql := range []string{
+		"select price * from orders",
+		"select price as from orders",
+		"select price as 'total' from orders",
+		"select nope(price) from orders",
+	} {
+		if _, err := parse.NewParser(sql).Query(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestComputed_Query(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "computeddb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table orders (id int, name varchar(8), price int, qty int)",
+		"insert into orders (id, name, price, qty) values (1, 'pen', 3, 10), (2, 'ink', 12, 2), (3, 'pad', 5, 4)",
+		"insert into orders (id, name, price, qty) values (4, 'cap', 7, null)",
+		"create view totals as select name, price * qty as total from orders",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select name, price * qty as total from orders where id < 4", "[[pen 30] [ink 24] [pad 20]]"},
+		{"select name, price * qty as total from orders order by total", "[[pad 20] [ink 24] [pen 30] [cap <nil>]]"},
+		{"select id, 1, 'x' as tag from orders where id = 2", "[[2 1 x]]"},
+		{"select upper(name) as big, concat(name, id) from orders where id = 3", "[[PAD pad3]]"},
+		{"select name as label from orders where id = 1", "[[pen]]"},
+		{"select name, case when qty > 5 then 'bulk' else 'retail' end as kind from orders where id < 3", "[[pen bulk] [ink retail]]"},
+		{"select name, total from totals where name = 'ink'", "[[ink 24]]"},
+		{"select count(*), 7 as seven from orders", "[[4 7]]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(queryRecords(t, d, tt.query)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  error
+	}{
+		{"select price * nope as x from orders", plan.ErrUnknownField},
+		{"select price * 2 as qty from orders", plan.ErrDuplicateField},
+		{"select price as x, qty as x from orders", plan.ErrDuplicateField},
+		{"select name from orders order by nope", plan.ErrUnknownField},
+	} {
+		if _, err := d.Query(tt.query); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, err)
+		}
+	}
+
+	// Invalid operands are found when the records are read
+	rows, err := d.Query("select name * 2 from orders")
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+	}
+	if err == nil || !strings.Contains(err.Error(), query.ErrInvalidOperand.Error()) {
+		t.Errorf("expected %v, got %v", query.ErrInvalidOperand, err)
+	}
+	if _, err := d.Query("select count(*), price + 1 from orders"); err == nil {
+		t.Errorf("expected a computed field reading a field of an aggregate query to fail")
+	}
+}
+
+func TestComputed_Plan(t *testing.T) {
+	db := openOptimizerTestDB(t)
+
+	explained, records := planQuery(t, db, "select name, id * 2 as double from emp where id = 13")
+	if !strings.Contains(explained, "extend double = (id * 2)") {
+		t.Errorf("expected an extend plan, got:\n%s", explained)
+	}
+	if got := strings.Join(records, ", "); got != "e13 26" {
+		t.Errorf("expected e13 26, got %s", got)
+	}
+
+	// The computed fields are typed after their expressions
+	tp, err := plan.NewTablePlan(db.tx, "emp", db.mdm)
+	if err != nil {
+		t.Fatalf("NewTablePlan failed: %v", err)
+	}
+	sch := plan.NewExtendPlan(tp, []*parse.ComputedData{
+		parse.NewComputedData("double", query.NewExpressionOp("*", query.NewExpressionFieldName("id"), query.NewExpressionVal(types.NewConstantInt(2)))),
+		parse.NewComputedData("big", query.NewExpressionOp("upper", query.NewExpressionFieldName("name"))),
+		parse.NewComputedData("label", query.NewExpressionOp("concat", query.NewExpressionFieldName("name"), query.NewExpressionFieldName("id"))),
+	}).Schema()
+	for field, want := range map[string]string{"id": "int 0", "double": "int 0", "big": "varchar 8", "label": "varchar 19"} {
+		if got := fmt.Sprintf("%s %d", sch.DataType(field), sch.Length(field)); got != want {
+			t.Errorf("%s: expected %s, got %s", field, want, got)
+		}
+	}
+}
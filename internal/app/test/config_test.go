@@ -0,0 +1,96 @@
+package test
+
+import (
+	"centauri/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "centauri.toml")
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestConfig_Defaults(t *testing.T) {
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.BlockSize != config.DEFAULT_BLOCK_SIZE || cfg.BufferCount != config.DEFAULT_BUFFER_COUNT {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+	if cfg.LogDirectory() != cfg.DataDir {
+		t.Errorf("expected log directory %q, got %q", cfg.DataDir, cfg.LogDirectory())
+	}
+}
+
+func TestConfig_File(t *testing.T) {
+	path := writeConfigFile(t, `
+# engine settings
+data_dir = "/var/lib/centauri"
+log_dir = '/var/log/centauri' # on its own disk
+block_size = 4096
+buffer_count = 64
+lock_timeout = "2s"
+fsync = "never"
+
+[server]
+addr = ":9000"
+`)
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.DataDir != "/var/lib/centauri" || cfg.LogDirectory() != "/var/log/centauri" {
+		t.Errorf("unexpected directories: %q, %q", cfg.DataDir, cfg.LogDirectory())
+	}
+	if cfg.BlockSize != 4096 || cfg.BufferCount != 64 {
+		t.Errorf("unexpected sizes: %d, %d", cfg.BlockSize, cfg.BufferCount)
+	}
+	if cfg.LockTimeout != 2*time.Second {
+		t.Errorf("expected lock timeout 2s, got %v", cfg.LockTimeout)
+	}
+	if cfg.Fsync != config.FSYNC_NEVER {
+		t.Errorf("expected fsync %q, got %q", config.FSYNC_NEVER, cfg.Fsync)
+	}
+	if cfg.Addr != ":9000" || cfg.HTTPAddr != config.DEFAULT_HTTP_ADDR {
+		t.Errorf("unexpected addresses: %q, %q", cfg.Addr, cfg.HTTPAddr)
+	}
+}
+
+func TestConfig_EnvOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, "buffer_count = 16\n")
+	t.Setenv("CENTAURI_BUFFER_COUNT", "32")
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.BufferCount != 32 {
+		t.Errorf("expected buffer count 32, got %d", cfg.BufferCount)
+	}
+}
+
+func TestConfig_Invalid(t *testing.T) {
+	tests := []string{
+		"unknown = 1\n",
+		"block_size = abc\n",
+		"block_size = 0\n",
+		"lock_timeout = \"soon\"\n",
+		"fsync = \"sometimes\"\n",
+		"temp_quota = -1\n",
+		"[server\n",
+	}
+
+	for _, contents := range tests {
+		if _, err := config.Load(writeConfigFile(t, contents)); err == nil {
+			t.Errorf("expected error for %q", contents)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package test
+
+import (
+	"centauri/internal/app/file"
+	"centauri/internal/app/types"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestConstant_Long(t *testing.T) {
+	big := types.NewConstantLong(math.MaxInt32 + 1)
+	if got := *big.AsLong(); got != math.MaxInt32+1 {
+		t.Errorf("expected %d, got %d", int64(math.MaxInt32+1), got)
+	}
+	if big.AsInt() != nil {
+		t.Errorf("expected a long constant to have no int value")
+	}
+	if big.String() != "2147483648" {
+		t.Errorf("unexpected string %q", big.String())
+	}
+
+	small := types.NewConstantLong(7)
+	if big.CompareTo(small) <= 0 || small.CompareTo(big) >= 0 {
+		t.Errorf("expected %s > %s", big, small)
+	}
+
+	// Integers of both widths compare and hash by value
+	seven := types.NewConstantInt(7)
+	if !small.Equals(seven) || !seven.Equals(small) {
+		t.Errorf("expected long 7 to equal int 7")
+	}
+	if small.CompareTo(seven) != 0 || big.CompareTo(seven) <= 0 {
+		t.Errorf("unexpected comparison between long and int")
+	}
+	if small.HashCode() != seven.HashCode() {
+		t.Errorf("expected equal integers to hash alike")
+	}
+}
+
+func TestPage_Long(t *testing.T) {
+	p := file.NewPage(32)
+	values := []int64{0, -1, math.MaxInt64, math.MinInt64, 1 << 40}
+	for _, v := range values {
+		p.SetLong(4, v)
+		if got := p.GetLong(4); got != v {
+			t.Errorf("expected %d, got %d", v, got)
+		}
+	}
+
+	// The neighbouring ints are untouched
+	p.SetInt(0, 11)
+	p.SetInt(12, 12)
+	p.SetLong(4, -1)
+	if p.GetInt(0) != 11 || p.GetInt(12) != 12 {
+		t.Errorf("SetLong overwrote neighbouring values")
+	}
+}
+
+func TestConstant_Float(t *testing.T) {
+	half := types.NewConstantFloat(0.5)
+	if got := *half.AsFloat(); got != 0.5 {
+		t.Errorf("expected 0.5, got %v", got)
+	}
+	if half.String() != "0.5" {
+		t.Errorf("unexpected string %q", half.String())
+	}
+
+	ordered := []*types.Constant{
+		types.NewConstantFloat(math.Inf(-1)),
+		types.NewConstantLong(math.MinInt64),
+		types.NewConstantInt(-1),
+		types.NewConstantFloat(-0.5),
+		types.NewConstantInt(0),
+		half,
+		types.NewConstantInt(1),
+		types.NewConstantFloat(1.5),
+		types.NewConstantLong(math.MaxInt64 - 1),
+		types.NewConstantLong(math.MaxInt64),
+		types.NewConstantFloat(math.Inf(1)),
+		types.NewConstantFloat(math.NaN()),
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := a.CompareTo(b); got != want {
+				t.Errorf("%s compared to %s: expected %d, got %d", a, b, want, got)
+			}
+		}
+	}
+
+	// Whole floats equal the integers of the same value
+	two := types.NewConstantFloat(2)
+	for _, c := range []*types.Constant{types.NewConstantInt(2), types.NewConstantLong(2)} {
+		if !two.Equals(c) || !c.Equals(two) {
+			t.Errorf("expected 2.0 to equal %s", c)
+		}
+		if two.HashCode() != c.HashCode() {
+			t.Errorf("expected 2.0 and %s to hash alike", c)
+		}
+	}
+	if !types.NewConstantFloat(math.NaN()).Equals(types.NewConstantFloat(math.NaN())) {
+		t.Errorf("expected NaN to equal itself")
+	}
+	if types.NewConstantFloat(0.5).Equals(types.NewConstantInt(0)) {
+		t.Errorf("expected 0.5 not to equal 0")
+	}
+}
+
+func TestPage_Float(t *testing.T) {
+	p := file.NewPage(16)
+	for _, f := range []float64{0, -1.25, math.MaxFloat64, math.SmallestNonzeroFloat64, math.Inf(-1)} {
+		p.SetFloat(8, f)
+		if got := p.GetFloat(8); got != f {
+			t.Errorf("expected %v, got %v", f, got)
+		}
+	}
+	p.SetFloat(0, math.NaN())
+	if !math.IsNaN(p.GetFloat(0)) {
+		t.Errorf("expected NaN, got %v", p.GetFloat(0))
+	}
+}
+
+// Returns a random constant of any type, drawn from a small pool of values
+// so that equal values of different types come up often
+func randomConstant(rnd *rand.Rand) *types.Constant {
+	n := rnd.Intn(7) - 3
+	switch rnd.Intn(7) {
+	case 0:
+		return types.NewConstantInt(n)
+	case 1:
+		return types.NewConstantLong(int64(n) << (rnd.Intn(2) * 40))
+	case 2:
+		return types.NewConstantFloat(float64(n) / float64(rnd.Intn(2)+1))
+	case 3:
+		special := []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1)}
+		return types.NewConstantFloat(special[rnd.Intn(len(special))])
+	case 4:
+		return types.NewConstantString(string(rune('a' + rnd.Intn(3))))
+	case 5:
+		return types.NewConstantNull()
+	default:
+		return types.NewConstantString(types.NewConstantInt(n).String())
+	}
+}
+
+func TestConstant_TotalOrder(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20000; i++ {
+		a, b, c := randomConstant(rnd), randomConstant(rnd), randomConstant(rnd)
+
+		ab, ba := a.CompareTo(b), b.CompareTo(a)
+		if ab != -ba {
+			t.Fatalf("not antisymmetric: %s vs %s is %d, the other way %d", a, b, ab, ba)
+		}
+		if a.CompareTo(a) != 0 || !a.Equals(a) {
+			t.Fatalf("%s doesn't equal itself", a)
+		}
+		if a.Equals(b) != (ab == 0) {
+			t.Fatalf("Equals and CompareTo disagree on %s and %s", a, b)
+		}
+		if a.Equals(b) && a.HashCode() != b.HashCode() {
+			t.Fatalf("%s equals %s but hashes differently", a, b)
+		}
+		if ab <= 0 && b.CompareTo(c) <= 0 && a.CompareTo(c) > 0 {
+			t.Fatalf("not transitive: %s <= %s <= %s but %s > %s", a, b, c, a, c)
+		}
+	}
+}
+
+func TestConstant_Kinds(t *testing.T) {
+	// Strings are never converted to numbers
+	five := types.NewConstantInt(5)
+	if five.Equals(types.NewConstantString("5")) {
+		t.Errorf("expected 5 not to equal '5'")
+	}
+
+	// Every number sorts before every string
+	for _, n := range []*types.Constant{five, types.NewConstantLong(math.MaxInt64), types.NewConstantFloat(math.NaN())} {
+		if n.CompareTo(types.NewConstantString("")) >= 0 {
+			t.Errorf("expected %s to sort before a string", n)
+		}
+		if n.Kind() != types.KIND_NUMBER {
+			t.Errorf("expected %s to be a number", n)
+		}
+	}
+	if types.NewConstantString("5").Kind() != types.KIND_STRING {
+		t.Errorf("expected '5' to be a string")
+	}
+}
+
+func TestCollation_Compare(t *testing.T) {
+	nocase, err := types.LookupCollation("NOCASE")
+	if err != nil {
+		t.Fatalf("LookupCollation failed: %v", err)
+	}
+
+	a := types.NewConstantStringWithCollation("Alice", nocase)
+	if !a.Equals(types.NewConstantString("ALICE")) || !types.NewConstantString("alice").Equals(a) {
+		t.Errorf("expected a case-insensitive match")
+	}
+	if a.HashCode() != types.NewConstantStringWithCollation("aLiCe", nocase).HashCode() {
+		t.Errorf("expected equal strings to hash alike")
+	}
+	if types.NewConstantString("Alice").Equals(types.NewConstantString("alice")) {
+		t.Errorf("expected the binary collation to be case-sensitive")
+	}
+
+	// Swedish sorts ö after z, binary and German order it before
+	swedish, err := types.LookupCollation("sv")
+	if err != nil {
+		t.Fatalf("LookupCollation failed: %v", err)
+	}
+	german, _ := types.LookupCollation("de")
+	if swedish.Compare("öl", "zon") <= 0 {
+		t.Errorf("expected ö after z in Swedish")
+	}
+	if german.Compare("öl", "zon") >= 0 {
+		t.Errorf("expected ö before z in German")
+	}
+	if types.NewConstantStringWithCollation("öl", swedish).CompareTo(types.NewConstantString("zon")) <= 0 {
+		t.Errorf("expected the constant to compare by its collation")
+	}
+
+	if _, err := types.LookupCollation("no such collation"); !errors.Is(err, types.ErrUnknownCollation) {
+		t.Errorf("expected ErrUnknownCollation, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package test
+
+import (
+	"centauri/internal/app/buffer"
+	"centauri/internal/app/file"
+	"centauri/internal/app/log"
+	"centauri/internal/app/tx"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// Layout of the table the crash workloads change: each block holds
+// CRASH_SLOTS slots, each an integer followed by a string
+const (
+	CRASH_FILE       = "crash.tbl"
+	CRASH_BLOCKS     = 4
+	CRASH_SLOTS      = 16
+	CRASH_SLOT_SIZE  = 24
+	CRASH_STRING_LEN = 16
+	CRASH_BUFFERS    = 3 // fewer than the blocks, so that uncommitted changes reach the disk
+)
+
+// Runs random workloads against the transaction and recovery managers,
+// crashes at arbitrary points by dropping the buffers and log records that
+// weren't written, then reopens the database, recovers and checks that
+// exactly the committed changes survived
+type crashHarness struct {
+	t    *testing.T
+	dir  string
+	rnd  *rand.Rand
+	fm   *file.FileManager
+	lm   *log.LogManager
+	bm   *buffer.BufferManager
+	ints [CRASH_BLOCKS * CRASH_SLOTS]int32  // committed integer of each slot
+	strs [CRASH_BLOCKS * CRASH_SLOTS]string // committed string of each slot
+}
+
+func newCrashHarness(t *testing.T, seed int64) *crashHarness {
+	h := &crashHarness{
+		t:   t,
+		dir: filepath.Join(t.TempDir(), "crashdb"),
+		rnd: rand.New(rand.NewSource(seed)),
+	}
+	h.open()
+
+	setup := h.newTx()
+	for i := 0; i < CRASH_BLOCKS; i++ {
+		if _, err := setup.Append(CRASH_FILE); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	setup.Commit()
+	return h
+}
+
+func (h *crashHarness) open() {
+	h.t.Helper()
+	fm, err := file.NewFileManager(h.dir, 400)
+	if err != nil {
+		h.t.Fatalf("Failed to create file manager: %v", err)
+	}
+	lm, err := log.NewLogManager(fm, "crashlog")
+	if err != nil {
+		h.t.Fatalf("Failed to create log manager: %v", err)
+	}
+	h.fm, h.lm, h.bm = fm, lm, buffer.NewBufferManager(fm, lm, CRASH_BUFFERS)
+}
+
+func (h *crashHarness) newTx() *tx.Transaction {
+	return tx.NewTransaction(h.fm, h.lm, h.bm)
+}
+
+// Crashes the engine: whatever is only in the buffer pool or the
+// log page in memory is lost, along with running transactions
+func (h *crashHarness) crash() {
+	h.fm.Close()
+}
+
+// Reopens the database and recovers it, as a restart after a crash does
+func (h *crashHarness) restart() {
+	h.t.Helper()
+	h.open()
+	recoveryTx := h.newTx()
+	if err := recoveryTx.Recover(); err != nil {
+		h.t.Fatalf("Recover failed: %v", err)
+	}
+	recoveryTx.Commit()
+}
+
+// Returns the block and offset of a slot
+func crashSlot(slot int) (*file.BlockID, int) {
+	return file.NewBlockID(CRASH_FILE, slot/CRASH_SLOTS), (slot % CRASH_SLOTS) * CRASH_SLOT_SIZE
+}
+
+// Runs random transactions, checkpointing now and then, until ops changes
+// have been made, then returns with the last transaction left unfinished
+func (h *crashHarness) runUntilCrash(ops int) {
+	h.t.Helper()
+	for h.runTx(&ops) {
+		if h.rnd.Intn(10) == 0 {
+			if err := tx.Checkpoint(h.lm, h.bm); err != nil {
+				h.t.Fatalf("Checkpoint failed: %v", err)
+			}
+		}
+	}
+}
+
+// Runs a random transaction that commits or rolls back, unless the crash
+// comes first, counting its changes down from ops. Returns false if it
+// was left unfinished. A transaction that changes values without logging
+// always commits, since a crash or rollback would leave its changes
+// in place, as it does for bulk loads.
+func (h *crashHarness) runTx(ops *int) bool {
+	h.t.Helper()
+	t2 := h.newTx()
+	logged := h.rnd.Intn(8) != 0
+	ints := make(map[int]int32)
+	strs := make(map[int]string)
+
+	for n := 1 + h.rnd.Intn(10); n > 0; n-- {
+		if logged {
+			if *ops <= 0 {
+				return false
+			}
+			*ops--
+		}
+
+		slot := h.rnd.Intn(CRASH_BLOCKS * CRASH_SLOTS)
+		block, offset := crashSlot(slot)
+		t2.Pin(block)
+		if h.rnd.Intn(2) == 0 {
+			val := h.rnd.Int31()
+			if err := t2.SetInt(*block, offset, int(val), logged); err != nil {
+				h.t.Fatalf("SetInt failed: %v", err)
+			}
+			ints[slot] = val
+		} else {
+			val := randomCrashString(h.rnd)
+			if err := t2.SetString(*block, offset+4, val, logged); err != nil {
+				h.t.Fatalf("SetString failed: %v", err)
+			}
+			strs[slot] = val
+		}
+		t2.Unpin(block)
+	}
+
+	if logged && h.rnd.Intn(4) == 0 {
+		t2.Rollback()
+		return true
+	}
+	t2.Commit()
+	for slot, val := range ints {
+		h.ints[slot] = val
+	}
+	for slot, val := range strs {
+		h.strs[slot] = val
+	}
+	return true
+}
+
+func randomCrashString(rnd *rand.Rand) string {
+	b := make([]byte, rnd.Intn(CRASH_STRING_LEN+1))
+	for i := range b {
+		b[i] = byte('a' + rnd.Intn(26))
+	}
+	return string(b)
+}
+
+// Checks that every slot holds its committed values, and
+// that every record of the log can be read
+func (h *crashHarness) verify(round int) {
+	h.t.Helper()
+	check := h.newTx()
+	defer check.Commit()
+	for slot := range h.ints {
+		block, offset := crashSlot(slot)
+		check.Pin(block)
+		if val, _ := check.GetInt(*block, offset); val != h.ints[slot] {
+			h.t.Errorf("Round %d: slot %d holds %d, expected %d", round, slot, val, h.ints[slot])
+		}
+		if val, _ := check.GetString(*block, offset+4); val != h.strs[slot] {
+			h.t.Errorf("Round %d: slot %d holds %q, expected %q", round, slot, val, h.strs[slot])
+		}
+		check.Unpin(block)
+	}
+
+	iter, err := h.lm.ForwardIterator(0)
+	if err != nil {
+		h.t.Fatalf("Round %d: failed to read the log: %v", round, err)
+	}
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		if err != nil {
+			h.t.Fatalf("Round %d: failed to read the log: %v", round, err)
+		}
+		if tx.CreateLogRecord(bytes) == nil {
+			h.t.Fatalf("Round %d: unknown log record at LSN %d", round, iter.LSN())
+		}
+	}
+}
+
+func TestCrashRecovery(t *testing.T) {
+	seeds, rounds := 8, 20
+	if testing.Short() {
+		seeds, rounds = 2, 5
+	}
+
+	for seed := int64(1); seed <= int64(seeds); seed++ {
+		t.Run(fmt.Sprintf("seed%d", seed), func(t *testing.T) {
+			h := newCrashHarness(t, seed)
+			for round := 0; round < rounds; round++ {
+				h.runUntilCrash(1 + h.rnd.Intn(60))
+				h.crash()
+				h.restart()
+				h.verify(round)
+
+				// Recovering again after crashing right away changes nothing
+				if h.rnd.Intn(4) == 0 {
+					h.crash()
+					h.restart()
+					h.verify(round)
+				}
+			}
+			h.crash()
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/dump"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/record"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParser_FieldOptions(t *testing.T) {
+	data, err := parse.NewParser("create table users (id int not null, status varchar(10) default 'active', level int default -1 not null, note varchar(5))").UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	sch := data.(*parse.CreateTableData).NewSchema()
+	for _, tt := range []struct {
+		field   string
+		notNull bool
+		value   string
+	}{
+		{"id", true, ""},
+		{"status", false, "'active'"},
+		{"level", true, "-1"},
+		{"note", false, ""},
+	} {
+		if sch.NotNull(tt.field) != tt.notNull || sch.Default(tt.field) != tt.value {
+			t.Errorf("%s: expected %t %q, got %t %q", tt.field, tt.notNull, tt.value, sch.NotNull(tt.field), sch.Default(tt.field))
+		}
+	}
+
+	for _, sql := range []string{
+		"create table t (id int not)",
+		"create table t (id int default)",
+		"create table t (id int default ?)",
+		"create table t (id int default id)",
+	} {
+		if _, err := parse.NewParser(sql).UpdateCmd(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestFieldOptions_Insert(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "defaultsdb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"create table users (id int not null, name varchar(10), status varchar(10) default 'active', level int not null default 1)",
+		"create index users_status on users (status)",
+		"insert into users (id, name) values (1, 'ann')",
+		"insert into users (id, name, status, level) values (2, 'bob', 'banned', 3)",
+		"insert into users (id, status) values (3, null)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select id, name, status, level from users", "[[1 ann active 1] [2 bob banned 3] [3 <nil> <nil> 1]]"},
+		{"select id from users where status = 'active'", "[[1]]"},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		sort.Strings(records)
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	for _, stmt := range []string{
+		"insert into users (name) values ('cid')",
+		"insert into users (id, level) values (4, null)",
+		"insert into users (id) values (4), (null)",
+		"update users set level = null where id = 1",
+		"update users set id = null",
+	} {
+		if _, err := d.Exec(stmt); !errors.Is(err, record.ErrNotNullable) {
+			t.Errorf("%s: expected %v, got %v", stmt, record.ErrNotNullable, err)
+		}
+	}
+	// The failed statements changed nothing
+	if got := countRows(t, d, "select id from users where id >= 4"); got != 0 {
+		t.Errorf("expected no records inserted, got %d", got)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select id, level from users where id = 1")); got != "[[1 1]]" {
+		t.Errorf("expected [[1 1]], got %s", got)
+	}
+
+	// Loaded records take the defaults too
+	count, err := d.BulkInsert("users", []string{"id", "name"}, func(yield func([]any) bool) {
+		for i := 10; i < 13; i++ {
+			if !yield([]any{i, fmt.Sprintf("u%d", i)}) {
+				return
+			}
+		}
+	})
+	if err != nil || count != 3 {
+		t.Fatalf("BulkInsert: expected 3 records, got %d, %v", count, err)
+	}
+	if got := countRows(t, d, "select id from users where status = 'active' and level = 1"); got != 4 {
+		t.Errorf("expected 4 records with the defaults, got %d", got)
+	}
+	if _, err := d.BulkInsert("users", []string{"name"}, func(yield func([]any) bool) {
+		yield([]any{"nobody"})
+	}); !errors.Is(err, record.ErrNotNullable) {
+		t.Errorf("expected %v, got %v", record.ErrNotNullable, err)
+	}
+
+	for _, tt := range []struct {
+		stmt string
+		want error
+	}{
+		{"create table t (id int default 'x')", record.ErrFieldType},
+		{"create table t (id int not null default null)", record.ErrNotNullable},
+		{"create table t (id int, twice int default 0 as (id * 2))", record.ErrGeneratedField},
+	} {
+		if _, err := d.Exec(tt.stmt); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.stmt, tt.want, err)
+		}
+	}
+	if _, err := d.Exec("create table t (name varchar(3) default 'toolong')"); err == nil {
+		t.Errorf("expected a default too long for its field to fail")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The options are kept in the catalog
+	d, err = db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+	if _, err := d.Exec("insert into users (id) values (20)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select status, level from users where id = 20")); got != "[[active 1]]" {
+		t.Errorf("expected [[active 1]], got %s", got)
+	}
+	desc, err := d.DescribeTable("users")
+	if err != nil {
+		t.Fatalf("DescribeTable failed: %v", err)
+	}
+	if c := desc.Columns[0]; !c.NotNull || c.Default != "" {
+		t.Errorf("expected id to be NOT NULL without a default, got %+v", c)
+	}
+	if c := desc.Columns[3]; !c.NotNull || c.Default != "1" {
+		t.Errorf("expected level to be NOT NULL with default 1, got %+v", c)
+	}
+
+	var out strings.Builder
+	if err := dump.Dump(d, &out, nil); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	want := "create table users (id int not null, name varchar(10), status varchar(10) default 'active', level int not null default 1);"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("expected the dump to contain %q, got:\n%s", want, out.String())
+	}
+}
+
+func TestFieldOptions_NullWithoutDefault(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "defaultsdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table q (a int, b varchar(8), c bigint, e boolean, f date, v int)",
+		"create index q_b on q (b)",
+		"insert into q (a) values (1)",
+		"insert into q (a, b, v) values (2, 'x', 5)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	// The fields an insert leaves out are null, not the empty values of their types
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select a, b, c, e, f, v from q where a = 1", "[[1 <nil> <nil> <nil> <nil> <nil>]]"},
+		{"select a from q where b is null", "[[1]]"},
+		{"select a from q where v is not null", "[[2]]"},
+		{"select min(v) from q", "[[5]]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(queryRecords(t, d, tt.query)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestFieldOptions_AddColumn(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "defaultsdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int)",
+		"insert into emp (id) values (1), (2)",
+		"alter table emp add column dept varchar(8) not null default 'none'",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	// The existing records take the default
+	if got := fmt.Sprint(queryRecords(t, d, "select id, dept from emp")); got != "[[1 none] [2 none]]" {
+		t.Errorf("expected [[1 none] [2 none]], got %s", got)
+	}
+
+	// Without a default, the existing records would be null
+	if _, err := d.Exec("alter table emp add column salary int not null"); !errors.Is(err, record.ErrNotNullable) {
+		t.Errorf("expected %v, got %v", record.ErrNotNullable, err)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select id, dept from emp")); got != "[[1 none] [2 none]]" {
+		t.Errorf("expected the table to be unchanged, got %s", got)
+	}
+
+	// Dropping another field keeps the options
+	for _, stmt := range []string{
+		"alter table emp add column tmp int",
+		"alter table emp drop column tmp",
+		"insert into emp (id) values (3)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select dept from emp where id = 3")); got != "[[none]]" {
+		t.Errorf("expected [[none]], got %s", got)
+	}
+}
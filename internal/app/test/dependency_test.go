@@ -0,0 +1,82 @@
+package test
+
+import (
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/server"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDependency_Tracking(t *testing.T) {
+	cdb, err := server.OpenCentauriDB(filepath.Join(t.TempDir(), "dependencydb"), 400, 8)
+	if err != nil {
+		t.Fatalf("OpenCentauriDB failed: %v", err)
+	}
+	defer cdb.Close()
+	s := server.NewSession(cdb)
+	defer s.Close()
+
+	for _, cmd := range []string{
+		"create table a (aid int, name varchar(8))",
+		"create table b (bid int, ref int)",
+		"create index a_aid on a (aid)",
+		"create view ab as select name, bid from a, b where aid = ref",
+		"create view onlyb as select bid from b",
+		"create view top as select name from ab",
+	} {
+		if _, err := execSession(t, s, cmd); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+
+	// Returns the objects depending on the table or view
+	dependents := func(name string) string {
+		t.Helper()
+		t1 := cdb.NewTx()
+		defer t1.Commit()
+		views, indexes := cdb.MdMgr().Dependents(name, t1)
+		return fmt.Sprint(views, indexes)
+	}
+	for name, want := range map[string]string{
+		"a":     "[ab] [a_aid]",
+		"b":     "[ab onlyb] []",
+		"ab":    "[top] []",
+		"onlyb": "[] []",
+	} {
+		if got := dependents(name); got != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+
+	// The error names every object depending on the table
+	_, err = execSession(t, s, "drop table a")
+	if !errors.Is(err, metadata.ErrDependentObjects) {
+		t.Fatalf("expected %v, got %v", metadata.ErrDependentObjects, err)
+	}
+	for _, dep := range []string{"view ab", "index a_aid"} {
+		if !strings.Contains(err.Error(), dep) {
+			t.Errorf("expected the error to name %s, got %v", dep, err)
+		}
+	}
+
+	// Cascading leaves no catalog entries behind for the dropped objects
+	if _, err := execSession(t, s, "drop table a cascade"); err != nil {
+		t.Fatalf("drop table failed: %v", err)
+	}
+	t1 := cdb.NewTx()
+	defer t1.Commit()
+	if got := fmt.Sprint(cdb.MdMgr().ViewNames(t1)); got != "[onlyb]" {
+		t.Errorf("expected only the view on b to remain, got %s", got)
+	}
+	if got := len(cdb.MdMgr().GetIndexInfo("a", t1)); got != 0 {
+		t.Errorf("expected the index on a to be dropped, got %d indexes", got)
+	}
+	for name, want := range map[string]string{"b": "[onlyb] []", "ab": "[] []"} {
+		if got := dependents(name); got != want {
+			t.Errorf("%s: expected %s after the drop, got %s", name, want, got)
+		}
+	}
+}
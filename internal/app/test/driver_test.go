@@ -0,0 +1,274 @@
+package test
+
+import (
+	"centauri/driver"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/server"
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A server speaking just enough of the wire protocol to test the driver.
+// answer is called for every statement with the number of statements
+// received so far and returns false to drop the connection instead.
+type fakeServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	conns    int
+	stmts    int
+	answer   func(n int, conn net.Conn) bool
+}
+
+func newFakeServer(t *testing.T, answer func(n int, conn net.Conn) bool) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	fs := &fakeServer{listener: listener, answer: answer}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			fs.mu.Lock()
+			fs.conns++
+			fs.mu.Unlock()
+			go fs.serve(conn)
+		}
+	}()
+	return fs
+}
+
+func (fs *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		msgType, _, err := server.ReadMessage(conn)
+		if err != nil {
+			return
+		}
+
+		switch msgType {
+		case server.MSG_AUTH:
+			server.WriteMessage(conn, server.MSG_AUTH_OK, server.EncodeAuthOK(1, 2))
+		case server.MSG_METADATA:
+			server.WriteMessage(conn, server.MSG_METADATA_RESULT, server.EncodeVersionInfo(server.VersionInfo{}))
+		case server.MSG_QUERY:
+			fs.mu.Lock()
+			fs.stmts++
+			n := fs.stmts
+			fs.mu.Unlock()
+			if !fs.answer(n, conn) {
+				return
+			}
+		case server.MSG_TERMINATE:
+			return
+		}
+	}
+}
+
+func (fs *fakeServer) counts() (int, int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.conns, fs.stmts
+}
+
+func (fs *fakeServer) dsn(params string) string {
+	return "centauri://alice:secret@" + fs.listener.Addr().String() + "/?retry_backoff=1ms" + params
+}
+
+// Answers a query with a single record holding 7
+func writeSeven(conn net.Conn) {
+	row, _ := server.EncodeDataRow([]any{7})
+	server.WriteMessage(conn, server.MSG_ROW_DESCRIPTION, server.EncodeRowDescription([]server.ColumnDesc{{Name: "a", Type: schema.INTEGER}}))
+	server.WriteMessage(conn, server.MSG_DATA_ROW, row)
+	server.WriteMessage(conn, server.MSG_COMMAND_COMPLETE, server.EncodeCommandComplete(1, 0))
+}
+
+func TestDriver_ParseDSN(t *testing.T) {
+	cfg, err := driver.ParseDSN("centauri://bob:pw@db.example.com/sales?retries=5&max_open=8&health_check=0s&max_lifetime=1h")
+	if err != nil {
+		t.Fatalf("ParseDSN failed: %v", err)
+	}
+	if cfg.User != "bob" || cfg.Password != "pw" || cfg.Database != "sales" {
+		t.Errorf("unexpected credentials or database: %+v", cfg)
+	}
+	if cfg.Addr != "db.example.com:7432" {
+		t.Errorf("expected default port, got %s", cfg.Addr)
+	}
+	if cfg.Retries != 5 || cfg.MaxOpen != 8 || cfg.HealthCheck != 0 || cfg.MaxLifetime != time.Hour {
+		t.Errorf("unexpected parameters: %+v", cfg)
+	}
+	if cfg.MaxIdle != driver.DEFAULT_MAX_IDLE || cfg.RetryBackoff != driver.DEFAULT_RETRY_BACKOFF {
+		t.Errorf("expected defaults for unset parameters: %+v", cfg)
+	}
+
+	for _, dsn := range []string{
+		"postgres://localhost",
+		"centauri://localhost?retries=-1",
+		"centauri://localhost?retry_backoff=soon",
+		"centauri://localhost?pool=3",
+	} {
+		if _, err := driver.ParseDSN(dsn); !errors.Is(err, driver.ErrInvalidDSN) {
+			t.Errorf("expected ErrInvalidDSN for %s, got %v", dsn, err)
+		}
+	}
+}
+
+func TestDriver_RetryReconnects(t *testing.T) {
+	// The first connection is dropped in the middle of the query
+	fs := newFakeServer(t, func(n int, conn net.Conn) bool {
+		if n == 1 {
+			return false
+		}
+		writeSeven(conn)
+		return true
+	})
+
+	pool, err := driver.OpenDB(fs.dsn(""))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer pool.Close()
+
+	var a int
+	if err := pool.QueryRow("select a from t").Scan(&a); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if a != 7 {
+		t.Errorf("expected 7, got %d", a)
+	}
+	if conns, stmts := fs.counts(); conns != 2 || stmts != 2 {
+		t.Errorf("expected 2 connections and 2 statements, got %d and %d", conns, stmts)
+	}
+}
+
+func TestDriver_RetryTransientError(t *testing.T) {
+	fs := newFakeServer(t, func(n int, conn net.Conn) bool {
+		if n == 1 {
+			server.WriteMessage(conn, server.MSG_ERROR, []byte("statement failed: lock acquistion timed out"))
+		} else {
+			writeSeven(conn)
+		}
+		return true
+	})
+
+	pool, err := driver.OpenDB(fs.dsn(""))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer pool.Close()
+
+	var a int
+	if err := pool.QueryRow("select a from t").Scan(&a); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if conns, stmts := fs.counts(); conns != 1 || stmts != 2 {
+		t.Errorf("expected 1 connection and 2 statements, got %d and %d", conns, stmts)
+	}
+}
+
+func TestDriver_UpdatesAreNotRetried(t *testing.T) {
+	fs := newFakeServer(t, func(n int, conn net.Conn) bool {
+		return false
+	})
+
+	pool, err := driver.OpenDB(fs.dsn("&retries=3"))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec("insert into t (a) values (1)"); err == nil {
+		t.Fatal("expected the update to fail")
+	}
+	if _, stmts := fs.counts(); stmts != 1 {
+		t.Errorf("expected the update to be sent once, got %d", stmts)
+	}
+
+	// The broken connection is dropped and the next statement gets a new one
+	if _, err := pool.Exec("insert into t (a) values (2)"); err == nil {
+		t.Fatal("expected the update to fail")
+	}
+	if conns, _ := fs.counts(); conns != 2 {
+		t.Errorf("expected 2 connections, got %d", conns)
+	}
+}
+
+func TestDriver_Transaction(t *testing.T) {
+	fs := newFakeServer(t, func(n int, conn net.Conn) bool {
+		switch n {
+		case 2:
+			server.WriteMessage(conn, server.MSG_ERROR, []byte("statement failed: lock acquistion timed out"))
+		case 5:
+			return false
+		case 6:
+			writeSeven(conn)
+		default:
+			server.WriteMessage(conn, server.MSG_COMMAND_COMPLETE, server.EncodeCommandComplete(0, 0))
+		}
+		return true
+	})
+
+	pool, err := driver.OpenDB(fs.dsn("&retries=3"))
+	if err != nil {
+		t.Fatalf("OpenDB failed: %v", err)
+	}
+	defer pool.Close()
+
+	// Statements of a transaction aren't retried
+	tx, err := pool.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	var a int
+	if err := tx.QueryRow("select a from t").Scan(&a); err == nil {
+		t.Fatal("expected the query to fail")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if _, stmts := fs.counts(); stmts != 3 {
+		t.Errorf("expected begin, the query and commit, got %d statements", stmts)
+	}
+
+	// Nor is a broken connection redialed, as the transaction is lost
+	if tx, err = pool.Begin(); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx.Exec("insert into t (a) values (1)"); err == nil {
+		t.Fatal("expected the insert to fail")
+	}
+	if err := tx.Rollback(); !errors.Is(err, driver.ErrTxAborted) {
+		t.Errorf("expected %v, got %v", driver.ErrTxAborted, err)
+	}
+	if conns, stmts := fs.counts(); conns != 1 || stmts != 5 {
+		t.Errorf("expected 1 connection and 5 statements, got %d and %d", conns, stmts)
+	}
+
+	// Outside a transaction the next statement gets a new connection
+	if err := pool.QueryRow("select a from t").Scan(&a); err != nil || a != 7 {
+		t.Fatalf("expected 7, got %d, %v", a, err)
+	}
+
+	for _, opts := range []*sql.TxOptions{{ReadOnly: true}, {Isolation: sql.LevelLinearizable}} {
+		if _, err := pool.BeginTx(context.Background(), opts); !errors.Is(err, driver.ErrTxOptionsNotSupported) {
+			t.Errorf("%+v: expected %v, got %v", opts, driver.ErrTxOptionsNotSupported, err)
+		}
+	}
+
+	rc, err := pool.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		t.Fatalf("BeginTx at read committed failed: %v", err)
+	}
+	if err := rc.Commit(); err != nil {
+		t.Errorf("Commit failed: %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/index/hash"
+	"centauri/internal/app/index/planner"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParser_Drop(t *testing.T) {
+	obj, err := parse.NewParser("drop table emp cascade").UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if data, ok := obj.(*parse.DropTableData); !ok || data.TableName() != "emp" || !data.Cascade() {
+		t.Errorf("unexpected command %#v", obj)
+	}
+
+	obj, err = parse.NewParser("DROP VIEW staff").UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if data, ok := obj.(*parse.DropViewData); !ok || data.ViewName() != "staff" || data.Cascade() {
+		t.Errorf("unexpected command %#v", obj)
+	}
+
+	obj, err = parse.NewParser("drop index emp_id").UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if data, ok := obj.(*parse.DropIndexData); !ok || data.IndexName() != "emp_id" {
+		t.Errorf("unexpected command %#v", obj)
+	}
+
+	for _, sql := range []string{"drop", "drop user ann", "drop table", "drop index"} {
+		if _, err := parse.NewParser(sql).UpdateCmd(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestDrop_Exec(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dropdb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8))",
+		"create index emp_id on emp (id)",
+		"insert into emp (id, name) values (1, 'ann')",
+		"insert into emp (id, name) values (2, 'bo')",
+		"create view named as select name from emp",
+		"create view few as select name from named",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	exists := func(file string) bool {
+		_, err := os.Stat(filepath.Join(dir, file))
+		return err == nil
+	}
+	if !exists("emp.tbl") {
+		t.Fatalf("expected the table file to exist")
+	}
+
+	// Objects depending on another keep it from being dropped
+	for _, stmt := range []string{"drop table emp", "drop view named"} {
+		if _, err := d.Exec(stmt); !errors.Is(err, metadata.ErrDependentObjects) {
+			t.Errorf("%s: expected %v, got %v", stmt, metadata.ErrDependentObjects, err)
+		}
+	}
+	for stmt, want := range map[string]error{
+		"drop table nope": metadata.ErrTableNotFound,
+		"drop table few":  metadata.ErrTableNotFound,
+		"drop view emp":   metadata.ErrTableNotFound,
+		"drop index nope": metadata.ErrIndexNotFound,
+	} {
+		if _, err := d.Exec(stmt); !errors.Is(err, want) {
+			t.Errorf("%s: expected %v, got %v", stmt, want, err)
+		}
+	}
+
+	if _, err := d.Exec("drop view few"); err != nil {
+		t.Fatalf("drop view failed: %v", err)
+	}
+	if _, err := d.Query("select name from few"); err == nil {
+		t.Errorf("expected the dropped view to be gone")
+	}
+
+	if _, err := d.Exec("drop index emp_id"); err != nil {
+		t.Fatalf("drop index failed: %v", err)
+	}
+	if got := len(queryRecords(t, d, "select name from emp where id = 2")); got != 1 {
+		t.Errorf("expected 1 record without the index, got %d", got)
+	}
+
+	if _, err := d.Exec("drop table emp cascade"); err != nil {
+		t.Fatalf("drop table failed: %v", err)
+	}
+	if exists("emp.tbl") {
+		t.Errorf("expected the table file to be removed")
+	}
+	if _, err := d.Query("select name from named"); err == nil {
+		t.Errorf("expected the view on the dropped table to be gone")
+	}
+
+	// The name can be used again, starting with no records
+	if _, err := d.Exec("create table emp (code varchar(4))"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	if got := countRows(t, d, "select code from emp"); got != 0 {
+		t.Errorf("expected the new table to be empty, got %d records", got)
+	}
+}
+
+func TestDrop_RollbackKeepsFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "droprollbackdb")
+	db := openRecoveryTestDB(t, dir)
+	defer db.fm.Close()
+
+	tx1 := db.newTx()
+	mdm, err := metadata.NewMetaDataManager(true, tx1)
+	if err != nil {
+		t.Fatalf("NewMetaDataManager failed: %v", err)
+	}
+	iup := planner.NewIndexUpdatePlanner(mdm)
+	for _, stmt := range []string{
+		"create table emp (id int)",
+		"create index emp_id on emp (id)",
+		"insert into emp (id) values (7)",
+	} {
+		obj, err := parse.NewParser(stmt).UpdateCmd()
+		if err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+		switch data := obj.(type) {
+		case *parse.CreateTableData:
+			_, err = iup.ExecuteCreateTable(data, tx1)
+		case *parse.CreateIndexData:
+			_, err = iup.ExecuteCreateIndex(data, tx1)
+		case *parse.InsertData:
+			_, err = iup.ExecuteInsert(data, tx1)
+		}
+		if err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	tx1.Commit()
+
+	// Returns how many of the table's and index's files exist
+	exist := func() int {
+		count := 0
+		files := []string{"emp.tbl"}
+		for bucket := 0; bucket < hash.NUM_BUCKETS; bucket++ {
+			files = append(files, hash.BucketTableName("emp_id", bucket)+".tbl")
+		}
+		for _, file := range files {
+			if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+				count++
+			}
+		}
+		return count
+	}
+	// The table and the bucket of the index entry have a file
+	files := exist()
+	if files != 2 {
+		t.Fatalf("expected the table and index files to exist, found %d", files)
+	}
+
+	// The files are only removed once the drop commits
+	tx2 := db.newTx()
+	if err := mdm.DropTable("emp", true, tx2); err != nil {
+		t.Fatalf("DropTable failed: %v", err)
+	}
+	if got := exist(); got != files {
+		t.Errorf("expected the files to remain until commit, found %d of %d", got, files)
+	}
+	tx2.Rollback()
+	if got := exist(); got != files {
+		t.Errorf("expected the files to remain after rollback, found %d of %d", got, files)
+	}
+
+	tx3 := db.newTx()
+	if _, err := mdm.GetLayout("emp", tx3); err != nil {
+		t.Errorf("expected the table to be restored: %v", err)
+	}
+	if _, ok := mdm.IndexTable("emp_id", tx3); !ok {
+		t.Errorf("expected the index to be restored")
+	}
+	if err := mdm.DropIndex("emp_id", tx3); err != nil {
+		t.Fatalf("DropIndex failed: %v", err)
+	}
+	if err := mdm.DropTable("emp", false, tx3); err != nil {
+		t.Fatalf("DropTable failed: %v", err)
+	}
+	tx3.Commit()
+	if got := exist(); got != 0 {
+		t.Errorf("expected the files to be removed, found %d", got)
+	}
+}
@@ -0,0 +1,54 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/dump"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDump_Schema(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "dumpdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table students (id int, name varchar(20))",
+		"create index students_id on students (id)",
+		"create view names as select name from students",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	var out strings.Builder
+	if err := dump.Dump(d, &out, nil); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"create table students (id int, name varchar(20));",
+		"create index students_id on students (id);",
+		"create view names as select name from students;",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected the dump to contain %q, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Index(out.String(), "create index") > strings.Index(out.String(), "create view") {
+		t.Errorf("expected views to be created last, got:\n%s", out.String())
+	}
+}
+
+func TestDump_Literal(t *testing.T) {
+	if got := dump.Literal("it's"); got != "'it''s'" {
+		t.Errorf("expected 'it''s', got %s", got)
+	}
+	if got := dump.Literal(42); got != "42" {
+		t.Errorf("expected 42, got %s", got)
+	}
+}
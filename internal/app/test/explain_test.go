@@ -0,0 +1,171 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/optimization"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/server"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_Explain(t *testing.T) {
+	data, err := parse.NewParser("EXPLAIN select name from emp where id = 3").Explain()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got := data.String(); got != "explain select name from emp where id=3" || data.Analyze() {
+		t.Errorf("unexpected command %q", got)
+	}
+	data, err = parse.NewParser("EXPLAIN ANALYZE select name from emp").Explain()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got := data.String(); got != "explain analyze select name from emp" || !data.Analyze() {
+		t.Errorf("unexpected command %q", got)
+	}
+
+	for _, sql := range []string{"explain", "explain analyze", "explain delete from emp", "select name from emp"} {
+		if _, err := parse.NewParser(sql).Explain(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+
+	if !server.IsQuery("explain select name from emp") {
+		t.Errorf("expected EXPLAIN to be treated as a query")
+	}
+}
+
+func TestExplain_Query(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "explaindb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8))",
+		"insert into emp (id, name) values (1, 'ann'), (2, 'bo')",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	rows, err := d.Query("explain select name from emp where id = 2")
+	if err != nil {
+		t.Fatalf("explain failed: %v", err)
+	}
+	var lines []string
+	for rows.Next() {
+		lines = append(lines, rows.Row().String("plan"))
+	}
+	rows.Close()
+
+	// One record per operator, the scan of the table being the deepest
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "project name") || !strings.HasPrefix(lines[2], "    table emp (blocks=") {
+		t.Errorf("unexpected plan %q", lines)
+	}
+
+	// The query itself isn't run, so it changes nothing
+	if got := countRows(t, d, "select name from emp"); got != 2 {
+		t.Errorf("expected 2 records, got %d", got)
+	}
+
+	if _, err := d.Query("explain select name from nope"); err == nil {
+		t.Errorf("expected explaining a query of an unknown table to fail")
+	}
+	if _, err := d.Query("explain select name from emp where id = ?"); !errors.Is(err, plan.ErrParameterCount) {
+		t.Errorf("expected %v, got %v", plan.ErrParameterCount, err)
+	}
+}
+
+func TestExplain_Analyze(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "explainanalyzedb"), &db.Options{BlockSize: 400, BufferSize: 8})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Exec("create table emp (id int, name varchar(8))"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := d.Exec(fmt.Sprintf("insert into emp (id, name) values (%d, 'e%d')", i, 50-i)); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	analyze := func(query string) []string {
+		t.Helper()
+		rows, err := d.Query(query)
+		if err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+		defer rows.Close()
+		var lines []string
+		for rows.Next() {
+			lines = append(lines, rows.Row().String("plan"))
+		}
+		return lines
+	}
+
+	// The plan is followed by what running it took
+	lines := analyze("explain analyze select name from emp where id < 10")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "project name") {
+		t.Fatalf("expected the plan and a line more, got %q", lines)
+	}
+	if got := lines[3]; got != "executed (records=10 temp_bytes=0)" {
+		t.Errorf("expected 10 records and no temp space, got %q", got)
+	}
+
+	// Sorting writes the records to temp tables of whole blocks
+	lines = analyze("explain analyze select name from emp order by name")
+	var records, tempBytes int
+	if _, err := fmt.Sscanf(lines[len(lines)-1], "executed (records=%d temp_bytes=%d)", &records, &tempBytes); err != nil {
+		t.Fatalf("unexpected last line %q: %v", lines[len(lines)-1], err)
+	}
+	if records != 50 || tempBytes == 0 || tempBytes%400 != 0 {
+		t.Errorf("expected 50 records and whole temp blocks, got %d records and %d bytes", records, tempBytes)
+	}
+}
+
+func TestExplain_AccessPaths(t *testing.T) {
+	db := openOptimizerTestDB(t)
+	planner := plan.NewPlanner(optimization.NewHeuristicQueryPlanner(db.mdm), db.iup, db.mdm)
+
+	explain := func(query string) string {
+		p, err := planner.CreateQueryPlan(query, db.tx)
+		if err != nil {
+			t.Fatalf("%s failed: %v", query, err)
+		}
+		s := p.Open()
+		defer s.Close()
+		var lines []string
+		for s.Next() {
+			lines = append(lines, s.GetString("plan"))
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	if got := explain("explain select name from emp where id = 13"); !strings.Contains(got, "index select") {
+		t.Errorf("expected an index select, got:\n%s", got)
+	}
+	got := explain("explain select name from emp where deptid = 3 and name = 'e13'")
+	if !strings.Contains(got, "index select") {
+		t.Errorf("expected an index select, got:\n%s", got)
+	}
+	if got := explain("explain select dname from emp, dept where deptid = did and id = 13"); !strings.Contains(got, "index join") {
+		t.Errorf("expected an index join, got:\n%s", got)
+	}
+	if got := explain("explain select dname from dept"); strings.Contains(got, "index") {
+		t.Errorf("expected a table scan, got:\n%s", got)
+	}
+}
@@ -0,0 +1,159 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/server"
+	"centauri/internal/app/types"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestParser_Float(t *testing.T) {
+	data, err := parse.NewParser("create table prices (id int, price float default -0.5)").UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	sch := data.(*parse.CreateTableData).NewSchema()
+	if got := sch.DataType("price"); got != schema.FLOAT {
+		t.Errorf("expected float, got %s", got)
+	}
+
+	// Numbers with a fraction or an exponent are float constants
+	tests := []struct {
+		sql  string
+		want *types.Constant
+	}{
+		{"select id from prices where price = 1.5", types.NewConstantFloat(1.5)},
+		{"select id from prices where price = 2e3", types.NewConstantFloat(2000)},
+		{"select id from prices where price = 2", types.NewConstantInt(2)},
+	}
+	for _, tt := range tests {
+		q, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		got := q.Pred().Terms()[0].RHS().AsConstant()
+		if !got.Equals(tt.want) || (got.AsFloat() != nil) != (tt.want.AsFloat() != nil) {
+			t.Errorf("%s: expected %v, got %v", tt.sql, tt.want, got)
+		}
+	}
+}
+
+func TestFloat_Query(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "floatdb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"create table prices (id int, price float, qty int)",
+		"insert into prices (id, price, qty) values (1, 9.99, 3), (3, 4, 2), (4, null, 5)",
+		"create index prices_price on prices (price) using btree",
+		"insert into prices (id, price, qty) values (5, 1.25e2, 1)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	insert, err := d.Prepare("insert into prices (id, price, qty) values (?, ?, ?)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	for _, args := range [][]any{{2, -0.5, 1}, {6, 0.1, 10}} {
+		if _, err := insert.Exec(args...); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select id, price from prices where id = 1", "[[1 9.99]]"},
+		{"select id from prices where price = 9.99", "[[1]]"},
+		{"select id from prices where price = 4", "[[3]]"},
+		{"select id from prices where price > 4", "[[1] [5]]"},
+		{"select id from prices where price < 0", "[[2]]"},
+		{"select id from prices where price between 0 and 10", "[[1] [3] [6]]"},
+		{"select id from prices where price is null", "[[4]]"},
+		{"select max(price), min(price) from prices", "[[125 -0.5]]"},
+		{"select id, price * qty as total from prices where id = 1", "[[1 29.97]]"},
+		{"select id, qty / 4.0 as part from prices where id = 3", "[[3 0.5]]"},
+		{"select id, price + 1 as next from prices where id = 2", "[[2 0.5]]"},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		sort.Strings(records)
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select id from prices where price is not null order by price desc")); got != "[[5] [1] [3] [6] [2]]" {
+		t.Errorf("expected [[5] [1] [3] [6] [2]], got %s", got)
+	}
+
+	// Values are float64
+	rows, err := d.Query("select price from prices where id = 6")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	var price float64
+	if !rows.Next() {
+		t.Fatalf("expected a row")
+	}
+	if err := rows.Scan(&price); err != nil || price != 0.1 {
+		t.Errorf("expected 0.1, got %v, %v", price, err)
+	}
+	rows.Close()
+
+	// An int field holds no fraction, and a float field no string
+	for _, stmt := range []string{
+		"insert into prices (id, price, qty) values (1.5, 1, 1)",
+		"insert into prices (id, price, qty) values (7, 'cheap', 1)",
+	} {
+		if _, err := d.Exec(stmt); err == nil {
+			t.Errorf("%s: expected an error", stmt)
+		}
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The values and the index are kept on disk
+	d, err = db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+	if got := fmt.Sprint(queryRecords(t, d, "select id from prices where price = 9.99")); got != "[[1]]" {
+		t.Errorf("expected [[1]], got %s", got)
+	}
+	desc, err := d.DescribeTable("prices")
+	if err != nil {
+		t.Fatalf("DescribeTable failed: %v", err)
+	}
+	if desc.Columns[1].Type != schema.FLOAT {
+		t.Errorf("expected a float column, got %s", desc.Columns[1].Type)
+	}
+}
+
+func TestProtocol_Float(t *testing.T) {
+	values := []any{1.5, math.Inf(-1), int64(3), 7}
+	payload, err := server.EncodeDataRow(values)
+	if err != nil {
+		t.Fatalf("EncodeDataRow failed: %v", err)
+	}
+	got, err := server.DecodeDataRow(payload)
+	if err != nil {
+		t.Fatalf("DecodeDataRow failed: %v", err)
+	}
+	if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", values) {
+		t.Errorf("expected %#v, got %#v", values, got)
+	}
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/dump"
+	"centauri/internal/app/index/planner"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerated_Values(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "generateddb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"create table items (price int, qty int, name varchar(8), total int as (price * qty) stored, label varchar(8) generated always as (upper(name)))",
+		"insert into items (price, qty, name) values (3, 4, 'pen')",
+		"insert into items (price, qty, name) values (10, 1, 'ink')",
+		"update items set qty = 2 where name = 'ink'",
+		"update items set name = 'pencil' where name = 'pen'",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	check := func(d *db.DB) {
+		t.Helper()
+		for query, want := range map[string]string{
+			"select name, total, label from items":                 "[[pencil 12 PENCIL] [ink 20 INK]]",
+			"select name, total from items where label = 'INK'":    "[[ink 20]]",
+			"select label from items where total = 12 and qty = 4": "[[PENCIL]]",
+		} {
+			rows, err := d.Query(query)
+			if err != nil {
+				t.Fatalf("%s failed: %v", query, err)
+			}
+			var got []string
+			for rows.Next() {
+				got = append(got, fmt.Sprint(rows.Row().Values()))
+			}
+			rows.Close()
+			if fmt.Sprint(got) != want {
+				t.Errorf("%s: expected %s, got %v", query, want, got)
+			}
+		}
+	}
+	check(d)
+
+	// The definitions are read back from the catalog
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	d, err = db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer d.Close()
+	check(d)
+
+	var out strings.Builder
+	if err := dump.Dump(d, &out, nil); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	for _, want := range []string{
+		"total int as (price * qty) stored, label varchar(8) as (upper(name)) virtual)",
+		"insert into items (price, qty, name) values (3, 4, 'pencil');",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected the dump to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestGenerated_Errors(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "generateddb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Exec("create table items (price int, qty int, total int as (price * qty) stored, twice int as (price * 2))"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	tests := []struct {
+		stmt string
+		err  error
+	}{
+		{"insert into items (price, qty, total) values (1, 2, 3)", record.ErrGeneratedField},
+		{"update items set total = 5", record.ErrGeneratedField},
+		{"create index items_twice on items (twice)", record.ErrGeneratedField},
+		{"create table bad (a int, b varchar(5) as (a + 1))", plan.ErrTypeMismatch},
+		{"create table bad (a int, b int as (c + 1))", record.ErrGeneratedField},
+		{"create table bad (a int, b int as (a + 1), c int as (b + 1))", record.ErrGeneratedField},
+	}
+	for _, tt := range tests {
+		if _, err := d.Exec(tt.stmt); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.stmt, tt.err, err)
+		}
+	}
+
+	if _, err := d.Exec("create index items_total on items (total)"); err != nil {
+		t.Errorf("expected a stored generated field to be indexable, got %v", err)
+	}
+}
+
+func TestIndexUpdatePlanner_Generated(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	exec := func(stmt string) int {
+		t.Helper()
+		return db.exec(t, stmt)
+	}
+
+	exec("create table t (a int, b int, c int as (a + b) stored)")
+	exec("create index t_c on t (c)")
+	exec("insert into t (a, b) values (1, 2)")
+	exec("insert into t (a, b) values (5, 5)")
+
+	// Changing a base field moves the index entry of the generated one
+	if count := exec("update t set b = 7 where a = 1"); count != 1 {
+		t.Fatalf("expected 1 record updated, got %d", count)
+	}
+
+	for c, want := range map[int]int{3: 0, 8: 1, 10: 1} {
+		tp, err := plan.NewTablePlan(db.tx, "t", db.mdm)
+		if err != nil {
+			t.Fatalf("NewTablePlan failed: %v", err)
+		}
+		pred := parsePredicate(t, fmt.Sprintf("c = %d", c))
+		p := planner.MakeIndexSelect(tp, pred, db.mdm.GetIndexInfo("t", db.tx))
+		if p == nil {
+			t.Fatalf("expected an index select for %s", pred)
+		}
+		s := p.Open()
+		count := 0
+		for s.Next() {
+			count++
+		}
+		s.Close()
+		if count != want {
+			t.Errorf("c = %d: expected %d index entries, got %d", c, want, count)
+		}
+	}
+}
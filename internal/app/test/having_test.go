@@ -0,0 +1,174 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_GroupByHaving(t *testing.T) {
+	tests := []struct {
+		sql        string
+		want       string
+		aggregates int
+	}{
+		{
+			"select dept, count(*) from emp group by dept",
+			"select dept, count(*) from emp group by dept",
+			1,
+		},
+		{
+			"select dept, count(*) from emp where salary > 10 group by dept having count(*) > 5 order by dept",
+			"select dept, count(*) from emp where salary>10 group by dept having count(*)>5 order by dept",
+			1,
+		},
+		// Aggregates only in HAVING are computed too, but not selected
+		{
+			"select dept, title from emp group by dept, title having max(salary) >= 100 and count(*) < 3",
+			"select dept, title from emp group by dept, title having max(salary)>=100 AND count(*)<3",
+			2,
+		},
+		{
+			"select count(*) from emp having count(*) between 1 and 4",
+			"select count(*) from emp having count(*) BETWEEN 1 AND 4",
+			1,
+		},
+	}
+	for _, tt := range tests {
+		data, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := data.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		if got := len(data.Aggregates()); got != tt.aggregates {
+			t.Errorf("%s: expected %d aggregates, got %d", tt.sql, tt.aggregates, got)
+		}
+
+		// The query text parses back to the same query
+		again, err := parse.NewParser(data.String()).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", data.String(), err)
+		}
+		if again.String() != data.String() {
+			t.Errorf("%s: expected %q, got %q", tt.sql, data.String(), again.String())
+		}
+	}
+
+	// Aggregates outside HAVING are field names
+	data, err := parse.NewParser("select dept from emp group by dept having dept > 1 and exists (select id from emp where count = 1)").Query()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if len(data.Aggregates()) != 0 {
+		t.Errorf("expected no aggregates, got %v", data.Aggregates())
+	}
+
+	for _, sql := range []string{
+		"select dept from emp group dept",
+		"select dept from emp group by",
+		"select dept from emp group by dept having",
+		"select dept from emp group by dept having sum(salary) > 1",
+		"select dept from emp where count(*) > 1",
+	} {
+		if _, err := parse.NewParser(sql).Query(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestGroupByHaving_Query(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "havingdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), dept int, salary int)",
+		"insert into emp (id, name, dept, salary) values (1, 'ann', 1, 100), (2, 'bob', 1, 300), (3, 'cid', 2, 200)",
+		"insert into emp (id, name, dept, salary) values (4, 'dan', 2, 50), (5, 'eve', 2, 400), (6, 'fay', 3, 150)",
+		"create view big as select dept, count(*) from emp group by dept having count(*) > 1",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select dept, count(*) from emp group by dept", "[[1 2] [2 3] [3 1]]"},
+		{"select dept, count(*) from emp group by dept having count(*) > 1", "[[1 2] [2 3]]"},
+		{"select dept, max(salary) from emp group by dept having max(salary) >= 300", "[[1 300] [2 400]]"},
+		// HAVING filters the groups, WHERE the records before grouping
+		{"select dept, count(*) from emp where salary > 100 group by dept having count(*) > 1", "[[2 2]]"},
+		{"select dept from emp group by dept having min(salary) < 100", "[[2]]"},
+		{"select dept from emp group by dept having dept <> 2 order by dept desc", "[[3] [1]]"},
+		{"select dept, dept * 10 as tens from emp group by dept having count(*) = 1", "[[3 30]]"},
+		{"select dept from emp group by dept order by dept limit 2", "[[1] [2]]"},
+		{"select count(*) from emp having count(*) > 5", "[[6]]"},
+		{"select count(*) from emp having count(*) > 6", "[]"},
+		{"select dept from emp group by dept having max(salary) > (select salary from emp where id = 2)", "[[2]]"},
+		{"select dept, count from big", "[[1 2] [2 3]]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(queryRecords(t, d, tt.query)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  error
+	}{
+		{"select dept from emp group by nope", plan.ErrUnknownField},
+		{"select dept from emp group by dept having max(nope) > 1", plan.ErrUnknownField},
+		{"select dept from emp group by dept having count(*) > 'x'", plan.ErrTypeMismatch},
+		{"select dept from emp group by dept having max(name) = 1", plan.ErrTypeMismatch},
+	} {
+		if _, err := d.Query(tt.query); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, err)
+		}
+	}
+
+	// Fields read after grouping must be grouped by or aggregated
+	for _, q := range []string{
+		"select name, count(*) from emp group by dept",
+		"select dept from emp group by dept having salary > 1",
+		"select dept, salary + 1 from emp group by dept",
+		"select id from emp having id > 1",
+	} {
+		if _, err := d.Query(q); err == nil {
+			t.Errorf("%s: expected an error", q)
+		}
+	}
+}
+
+func TestGroupByHaving_HeuristicPlanner(t *testing.T) {
+	db := openOptimizerTestDB(t)
+
+	explained, records := planQuery(t, db, "select deptid, count(*) from emp where id < 12 group by deptid having count(*) > 2")
+	if got := strings.Join(records, ", "); got != "0 3, 1 3" {
+		t.Errorf("expected 0 3, 1 3, got %s", got)
+	}
+	// The groups are selected above the grouping
+	having := strings.Index(explained, "select count(*)>2")
+	group := strings.Index(explained, "group by deptid computing count")
+	if having < 0 || group < 0 || having > group {
+		t.Errorf("expected the HAVING clause to select the groups, got:\n%s", explained)
+	}
+
+	// The limit applies to the groups, not the records grouped
+	_, records = planQuery(t, db, "select deptid from emp group by deptid limit 3")
+	if len(records) != 3 {
+		t.Errorf("expected 3 groups, got %v", records)
+	}
+}
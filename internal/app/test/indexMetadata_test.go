@@ -0,0 +1,82 @@
+package test
+
+import (
+	"centauri/internal/app/index"
+	"centauri/internal/app/metadata"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIndexMetadata_Composite(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	db.exec(t, "create table people (last varchar(8), first varchar(8), age int)")
+
+	if err := db.mdm.CreateCompositeIndex("people_name", "people", []string{"last", "first"}, db.tx); err != nil {
+		t.Fatalf("CreateCompositeIndex failed: %v", err)
+	}
+	if err := db.mdm.CreateCompositeIndex("people_first", "people", []string{"first", "last", "age"}, db.tx); err != nil {
+		t.Fatalf("CreateCompositeIndex failed: %v", err)
+	}
+	if err := db.mdm.CreateIndex("people_age", "people", "age", index.INDEX_BTREE, true, db.tx); err != nil {
+		t.Fatalf("CreateIndex failed: %v", err)
+	}
+
+	// Each index is keyed by its fields, listed in key order
+	check := func(mdm *metadata.MetaDataManager) {
+		t.Helper()
+		infos := mdm.GetIndexInfo("people", db.tx)
+		if len(infos) != 3 {
+			t.Fatalf("expected 3 indexes, got %d", len(infos))
+		}
+		for key, want := range map[string]string{
+			"last,first":     "people_name [last first] hash composite=true unique=false",
+			"first,last,age": "people_first [first last age] hash composite=true unique=false",
+			"age":            "people_age [age] btree composite=false unique=true",
+		} {
+			ii, ok := infos[key]
+			if !ok {
+				t.Errorf("expected an index on %s", key)
+				continue
+			}
+			got := fmt.Sprintf("%s %v %s composite=%t unique=%t", ii.IndexName(), ii.FieldNames(), ii.IndexType(), ii.IsComposite(), ii.IsUnique())
+			if got != want {
+				t.Errorf("%s: expected %s, got %s", key, want, got)
+			}
+		}
+	}
+	check(db.mdm)
+
+	// The field lists are read back from idxcat
+	mdm, err := metadata.NewMetaDataManager(false, db.tx)
+	if err != nil {
+		t.Fatalf("NewMetaDataManager failed: %v", err)
+	}
+	check(mdm)
+}
+
+func TestIndexMetadata_Errors(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	db.exec(t, "create table people (last varchar(8), first varchar(8))")
+
+	tests := []struct {
+		name   string
+		fields []string
+		msg    string
+	}{
+		{"none", nil, "has no fields"},
+		{"long", []string{strings.Repeat("f", 40), strings.Repeat("g", 40)}, "exceeds"},
+	}
+	for _, tt := range tests {
+		err := db.mdm.CreateCompositeIndex(tt.name, "people", tt.fields, db.tx)
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.msg, err)
+		}
+	}
+	if err := db.mdm.CreateIndex("other", "people", "last", "rtree", false, db.tx); err == nil || !strings.Contains(err.Error(), "unknown index type") {
+		t.Errorf("expected an unknown index type error, got %v", err)
+	}
+	if got := len(db.mdm.GetIndexInfo("people", db.tx)); got != 0 {
+		t.Errorf("expected no index to be created, got %d", got)
+	}
+}
@@ -0,0 +1,219 @@
+package test
+
+import (
+	"centauri/internal/app/parse"
+	"centauri/internal/app/server"
+	"centauri/internal/app/tx"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParser_BeginIsolation(t *testing.T) {
+	for _, tc := range []struct {
+		sql  string
+		want string
+	}{
+		{"begin", ""},
+		{"begin isolation level serializable", "serializable"},
+		{"BEGIN WORK ISOLATION LEVEL READ COMMITTED", "read committed"},
+		{"start transaction isolation level repeatable read", "repeatable read"},
+		{"begin isolation level snapshot", "snapshot"},
+	} {
+		data, err := parse.NewParser(tc.sql).TxControl()
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tc.sql, err)
+		}
+		if got := data.(*parse.BeginData).IsolationLevel(); got != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.sql, tc.want, got)
+		}
+	}
+
+	if _, err := parse.NewParser("begin isolation level read uncommitted").TxControl(); !errors.Is(err, parse.ErrSyntax) {
+		t.Errorf("expected %v, got %v", parse.ErrSyntax, err)
+	}
+}
+
+func TestIsolation_LockDuration(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "isolationdb"))
+	defer db.fm.Close()
+
+	setup := db.newTx()
+	block, err := setup.Append("isolationtest")
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	setup.Pin(&block)
+	if err := setup.SetInt(block, 0, 1, true); err != nil {
+		t.Fatalf("SetInt failed: %v", err)
+	}
+	setup.Commit()
+
+	// Starts a transaction with the block pinned, which waits briefly for locks
+	begin := func(level tx.IsolationLevel) *tx.Transaction {
+		t1 := tx.NewTransactionWithIsolation(db.fm, db.lm, db.bm, level)
+		t1.SetLockTimeout(100 * time.Millisecond)
+		t1.Pin(&block)
+		return t1
+	}
+
+	for _, tc := range []struct {
+		level    tx.IsolationLevel
+		blocks   bool // whether a read keeps writers of the block out
+		phantoms bool // whether reading the size lets other transactions append
+	}{
+		{tx.SERIALIZABLE, true, false},
+		{tx.REPEATABLE_READ, true, true},
+		{tx.READ_COMMITTED, false, true},
+	} {
+		t.Run(tc.level.String(), func(t *testing.T) {
+			reader := begin(tc.level)
+			defer reader.Rollback()
+			if reader.IsolationLevel() != tc.level {
+				t.Errorf("expected %v, got %v", tc.level, reader.IsolationLevel())
+			}
+			before, err := reader.GetInt(block, 0)
+			if err != nil {
+				t.Fatalf("GetInt failed: %v", err)
+			}
+			if _, err := reader.Size("isolationtest"); err != nil {
+				t.Fatalf("Size failed: %v", err)
+			}
+
+			writer := begin(tx.SERIALIZABLE)
+			err = lockError(func() error { return writer.SetInt(block, 0, int(before)+1, true) })
+			if tc.blocks != (err == tx.LockAbortError) {
+				t.Errorf("expected the write to wait for the reader %v, got %v", tc.blocks, err)
+			}
+			err = lockError(func() error { _, err := writer.Append("isolationtest"); return err })
+			if tc.phantoms != (err == nil) {
+				t.Errorf("expected the append to succeed %v, got %v", tc.phantoms, err)
+			}
+			writer.Commit()
+
+			// Reading again sees the committed write only without the lock
+			after, err := reader.GetInt(block, 0)
+			if err != nil {
+				t.Fatalf("GetInt failed: %v", err)
+			}
+			if changed := after != before; changed == tc.blocks {
+				t.Errorf("expected the value to change %v, got %d then %d", !tc.blocks, before, after)
+			}
+		})
+	}
+
+	// Even at read committed, reads wait for the writers to finish
+	writer := begin(tx.SERIALIZABLE)
+	if err := writer.SetInt(block, 0, 100, true); err != nil {
+		t.Fatalf("SetInt failed: %v", err)
+	}
+	reader := begin(tx.READ_COMMITTED)
+	defer reader.Rollback()
+	if err := lockError(func() error { _, err := reader.GetInt(block, 0); return err }); err != tx.LockAbortError {
+		t.Errorf("expected %v, got %v", tx.LockAbortError, err)
+	}
+	writer.Rollback()
+}
+
+func TestSession_IsolationLevel(t *testing.T) {
+	cdb, err := server.OpenCentauriDB(filepath.Join(t.TempDir(), "sessiondb"), 400, 8)
+	if err != nil {
+		t.Fatalf("OpenCentauriDB failed: %v", err)
+	}
+	defer cdb.Close()
+
+	s := server.NewSession(cdb)
+	if _, err := execSession(t, s, "set isolation_level to read_committed"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+	if got, err := s.Show(server.SETTING_ISOLATION_LEVEL); err != nil || got != server.ISOLATION_READ_COMMITTED {
+		t.Errorf("expected %q, got %q, %v", server.ISOLATION_READ_COMMITTED, got, err)
+	}
+	if _, err := execSession(t, s, "set isolation_level to 'read uncommitted'"); !errors.Is(err, server.ErrInvalidSetting) {
+		t.Errorf("expected %v, got %v", server.ErrInvalidSetting, err)
+	}
+
+	// Returns a transaction's isolation level by the locks its read leaves
+	lockedByRead := func(begin string) bool {
+		t.Helper()
+		for _, cmd := range []string{begin, "select id from t"} {
+			if _, err := execSession(t, s, cmd); err != nil {
+				t.Fatalf("%s failed: %v", cmd, err)
+			}
+		}
+		defer execSession(t, s, "rollback")
+
+		other := server.NewSession(cdb)
+		defer other.Close()
+		other.Set(server.SETTING_LOCK_TIMEOUT, "100ms")
+		_, err := execSession(t, other, "update t set id = 2")
+		return errors.Is(err, tx.LockAbortError)
+	}
+
+	if _, err := execSession(t, s, "create table t (id int)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	if _, err := execSession(t, s, "insert into t (id) values (1)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if lockedByRead("begin") {
+		t.Errorf("expected the session's read committed level to release the read locks")
+	}
+	if !lockedByRead("begin isolation level serializable") {
+		t.Errorf("expected a serializable transaction to keep the read locks")
+	}
+}
+
+func TestIsolation_ReadCommittedRollback(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "isolationdb"))
+	defer db.fm.Close()
+
+	setup := db.newTx()
+	block, err := setup.Append("isolationtest")
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	setup.Pin(&block)
+	if err := setup.SetInt(block, 0, 1, true); err != nil {
+		t.Fatalf("SetInt failed: %v", err)
+	}
+	setup.Commit()
+
+	// A writer keeps writing a value it always rolls back, retrying the
+	// lock without waiting so that it gets it as soon as it is released
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			writer := tx.NewTransactionWithIsolation(db.fm, db.lm, db.bm, tx.SERIALIZABLE)
+			writer.SetLockTimeout(tx.NOWAIT)
+			writer.Pin(&block)
+			for lockError(func() error { return writer.SetInt(block, 0, 999, true) }) != nil {
+			}
+			writer.Rollback()
+		}
+	}()
+	defer func() { <-done }()
+
+	// The reads take the lock only while reading, but never see the value
+	reader := tx.NewTransactionWithIsolation(db.fm, db.lm, db.bm, tx.READ_COMMITTED)
+	reader.SetLockTimeout(time.Second)
+	reader.Pin(&block)
+	defer reader.Rollback()
+	for reads := 0; ; reads++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		var val int32
+		if err := lockError(func() error { val, err = reader.GetInt(block, 0); return err }); err != nil {
+			continue
+		}
+		if val != 1 {
+			t.Errorf("read %d saw the uncommitted value %d", reads, val)
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParser_Join(t *testing.T) {
+	tests := []struct {
+		sql    string
+		want   string
+		tables string
+		pred   string
+	}{
+		{
+			sql:    "select name from emp join dept on deptid = did",
+			want:   "select name from emp join dept on deptid=did",
+			tables: "[emp dept]",
+			pred:   "deptid=did",
+		},
+		{
+			sql:    "select name from emp INNER JOIN dept ON emp.deptid = dept.did where id = 3",
+			want:   "select name from emp join dept on deptid=did where id=3",
+			tables: "[emp dept]",
+			pred:   "deptid=did AND id=3",
+		},
+		{
+			sql:    "select name from emp join dept on deptid = did and did = 1, proj where pid = id",
+			want:   "select name from emp join dept on deptid=did AND did=1, proj where pid=id",
+			tables: "[emp dept proj]",
+			pred:   "deptid=did AND did=1 AND pid=id",
+		},
+	}
+	for _, tt := range tests {
+		data, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		// Views are stored as their query text, so it must parse back the same
+		if got := data.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		if got := fmt.Sprint(data.Tables()); got != tt.tables {
+			t.Errorf("%s: expected tables %s, got %s", tt.sql, tt.tables, got)
+		}
+		if got := data.Pred().String(); got != tt.pred {
+			t.Errorf("%s: expected predicate %q, got %q", tt.sql, tt.pred, got)
+		}
+	}
+
+	for _, sql := range []string{
+		"select name from emp join dept",
+		"select name from emp join dept on",
+		"select name from emp join on deptid = did",
+		"select name from emp left join dept on deptid = did",
+		// A qualifier must name one of the tables joined so far
+		"select name from emp join dept on proj.did = deptid",
+		"select name from emp where dept.did = 1",
+	} {
+		if _, err := parse.NewParser(sql).Query(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestJoin_Query(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "joindb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), deptid int)",
+		"create table dept (did int, dname varchar(8))",
+		"insert into emp (id, name, deptid) values (1, 'ann', 10)",
+		"insert into emp (id, name, deptid) values (2, 'bo', 20)",
+		"insert into emp (id, name, deptid) values (3, 'cy', 10)",
+		"insert into emp (id, name, deptid) values (4, 'dee', 30)",
+		"insert into dept (did, dname) values (10, 'eng')",
+		"insert into dept (did, dname) values (20, 'ops')",
+		"create view staff as select name, dname from emp join dept on emp.deptid = dept.did",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select name, dname from emp join dept on emp.deptid = dept.did", "[[ann eng] [bo ops] [cy eng]]"},
+		{"select name from emp join dept on deptid = did where dname = 'eng'", "[[ann] [cy]]"},
+		{"select name from emp join dept on deptid = did and dname = 'ops'", "[[bo]]"},
+		{"select name, dname from staff", "[[ann eng] [bo ops] [cy eng]]"},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		sort.Strings(records)
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestJoin_HeuristicPlanner(t *testing.T) {
+	db := openOptimizerTestDB(t)
+
+	// An explicit join is planned like the equivalent comma join
+	explained, records := planQuery(t, db, "select name, dname from dept join emp on did = deptid where id = 13")
+	if !strings.Contains(explained, "index join") || strings.Contains(explained, "product") {
+		t.Errorf("expected an index join, got:\n%s", explained)
+	}
+	if got := strings.Join(records, ", "); got != "e13 d3" {
+		t.Errorf("expected e13 d3, got %s", got)
+	}
+
+	_, records = planQuery(t, db, "select id, dname from emp join dept on emp.deptid = dept.did")
+	if len(records) != 80 {
+		t.Errorf("expected 80 records, got %d", len(records))
+	}
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"path/filepath"
+	"testing"
+)
+
+// A plan with given cost estimates
+type costPlan struct {
+	interfaces.Plan
+	blocks  int
+	records int
+}
+
+func (cp *costPlan) BlocksAccessed() int {
+	return cp.blocks
+}
+
+func (cp *costPlan) RecordsOutput() int {
+	return cp.records
+}
+
+func (cp *costPlan) DistinctValues(fieldName string) int {
+	return 10
+}
+
+func (cp *costPlan) Schema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddIntField("k")
+	return sch
+}
+
+func TestLimitPlan_Estimates(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "limitdb"))
+	defer db.fm.Close()
+
+	tx1 := db.newTx()
+	defer tx1.Rollback()
+
+	src := &costPlan{blocks: 100, records: 1000}
+	sorted := materialize.NewSortPlan(tx1, src, materialize.AscAll([]string{"k"}))
+
+	tests := []struct {
+		name    string
+		p       interfaces.Plan
+		limit   int
+		blocks  int
+		records int
+	}{
+		{"pipelined", src, 10, 1, 10},
+		{"pipelined, rounded up", src, 15, 2, 15},
+		{"pipelined, above the output", src, 5000, 100, 1000},
+		// A tenth of the records are selected, so ten times as many are read
+		{"over a select", plan.NewSelectPlan(src, fieldEquals("k", types.NewConstantInt(1))), 10, 10, 10},
+		{"blocking", sorted, 10, sorted.BlocksAccessed(), 10},
+		{"above a blocking plan", plan.NewProjectPlan(sorted, []string{"k"}), 10, sorted.BlocksAccessed(), 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lp := plan.NewLimitPlan(tt.p, tt.limit)
+			if got := lp.BlocksAccessed(); got != tt.blocks {
+				t.Errorf("expected %d blocks, got %d", tt.blocks, got)
+			}
+			if got := lp.RecordsOutput(); got != tt.records {
+				t.Errorf("expected %d records, got %d", tt.records, got)
+			}
+		})
+	}
+}
+
+func TestLimit_Query(t *testing.T) {
+	d := openPlannerTestDB(t)
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"insert into t (id, name) values (3, 'cid')",
+		"insert into t (id, name) values (4, 'dee')",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		count int
+	}{
+		{"select id from t limit 2", 2},
+		{"select id from t where name = 'cid' limit 2", 1},
+		{"select id from t limit 0", 0},
+		{"select id from t limit 10", 4},
+		{"select count(*) from t limit 1", 1},
+	}
+	for _, tt := range tests {
+		if got := countRows(t, d, tt.query); got != tt.count {
+			t.Errorf("%s: expected %d records, got %d", tt.query, tt.count, got)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/server"
+	"centauri/internal/app/tx"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockTimeout_PerDatabase(t *testing.T) {
+	opts := db.DefaultOptions()
+	opts.LockTimeout = 100 * time.Millisecond
+	d, err := db.Open(filepath.Join(t.TempDir(), "timeoutdb"), opts)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Exec("create table t (id int)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	if _, err := d.Exec("insert into t (id) values (1)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	writer, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	defer writer.Rollback()
+	if _, err := writer.Exec("update t set id = 2"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	// The database's timeout applies unless the transaction sets its own
+	start := time.Now()
+	if _, err := d.Exec("update t set id = 3"); !errors.Is(err, tx.LockAbortError) {
+		t.Errorf("expected %v, got %v", tx.LockAbortError, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the database's timeout of 100ms, waited %v", elapsed)
+	}
+
+	other, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	defer other.Rollback()
+	if err := other.SetLockTimeout(db.NOWAIT); err != nil {
+		t.Fatalf("SetLockTimeout failed: %v", err)
+	}
+	if _, err := other.Exec("update t set id = 4"); !errors.Is(err, tx.ErrLockNotAvailable) {
+		t.Errorf("expected %v, got %v", tx.ErrLockNotAvailable, err)
+	}
+}
+
+func TestSession_LockTimeoutNoWait(t *testing.T) {
+	cdb, err := server.OpenCentauriDB(filepath.Join(t.TempDir(), "sessiondb"), 400, 8)
+	if err != nil {
+		t.Fatalf("OpenCentauriDB failed: %v", err)
+	}
+	defer cdb.Close()
+
+	s := server.NewSession(cdb)
+	defer s.Close()
+	for _, cmd := range []string{
+		"create table t (id int)",
+		"insert into t (id) values (1)",
+		"begin",
+		"update t set id = 2",
+	} {
+		if _, err := execSession(t, s, cmd); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+
+	other := server.NewSession(cdb)
+	defer other.Close()
+	for _, value := range []string{"NOWAIT", "0"} {
+		if err := other.Set(server.SETTING_LOCK_TIMEOUT, value); err != nil {
+			t.Fatalf("SET lock_timeout = %s failed: %v", value, err)
+		}
+		if got, err := other.Show(server.SETTING_LOCK_TIMEOUT); err != nil || got != server.LOCK_TIMEOUT_NOWAIT {
+			t.Errorf("expected %q, got %q, %v", server.LOCK_TIMEOUT_NOWAIT, got, err)
+		}
+	}
+	if _, err := execSession(t, other, "select id from t"); !errors.Is(err, tx.ErrLockNotAvailable) {
+		t.Errorf("expected %v, got %v", tx.ErrLockNotAvailable, err)
+	}
+	if err := other.Set(server.SETTING_LOCK_TIMEOUT, "-1s"); !errors.Is(err, server.ErrInvalidSetting) {
+		t.Errorf("expected %v, got %v", server.ErrInvalidSetting, err)
+	}
+}
@@ -3,8 +3,12 @@ package test
 import (
 	"centauri/internal/app/file"
 	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewLockTable(t *testing.T) {
@@ -224,3 +228,265 @@ func TestConcurrency(t *testing.T) {
 	})
 
 }
+
+func TestUpgrade(t *testing.T) {
+	lt := tx.NewLockTable()
+	block := file.NewBlockID("test.db", 1)
+
+	t.Run("Upgrade Own SLock", func(t *testing.T) {
+		if err := lt.SLock(block); err != nil {
+			t.Errorf("Failed to acquire SLock: %v", err)
+		}
+
+		if err := lt.Upgrade(block); err != nil {
+			t.Errorf("Failed to upgrade SLock: %v", err)
+		}
+
+		if lt.GetLockVal(block) != -1 {
+			t.Errorf("Expected lock value -1, got %d", lt.GetLockVal(block))
+		}
+	})
+
+	t.Run("Upgrade Timeout with Other SLocks", func(t *testing.T) {
+		block2 := file.NewBlockID("test.db", 2)
+		lt.SLock(block2)
+		lt.SLock(block2)
+
+		if err := lt.Upgrade(block2); err != tx.LockAbortError {
+			t.Errorf("Expected timeout error, got %v", err)
+		}
+	})
+}
+
+func TestLockTable_BlockIDValue(t *testing.T) {
+	tx.SetLockTimeout(100 * time.Millisecond)
+	defer tx.SetLockTimeout(tx.MaxWaitTime)
+	lt := tx.NewLockTable()
+
+	// Two BlockIDs naming the same block share its lock
+	if err := lt.XLock(file.NewBlockID("test.db", 1)); err != nil {
+		t.Fatalf("Failed to acquire XLock: %v", err)
+	}
+	if err := lt.SLock(file.NewBlockID("test.db", 1)); err != tx.LockAbortError {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+	if err := lt.SLock(file.NewBlockID("other.db", 1)); err != nil {
+		t.Errorf("Failed to acquire SLock on another file: %v", err)
+	}
+
+	// A waiter is woken up once the block is unlocked
+	acquired := make(chan error)
+	go func() {
+		acquired <- lt.SLock(file.NewBlockID("test.db", 1))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	lt.Unlock(file.NewBlockID("test.db", 1))
+	if err := <-acquired; err != nil {
+		t.Errorf("Failed to acquire SLock after unlock: %v", err)
+	}
+}
+
+func TestLockTable_Shards(t *testing.T) {
+	lt := tx.NewLockTable()
+	const numBlocks = 200
+
+	var wg sync.WaitGroup
+	for i := 0; i < numBlocks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			block := file.NewBlockID(fmt.Sprintf("file%d.db", i%7), i)
+			if err := lt.XLock(block); err != nil {
+				t.Errorf("Failed to acquire XLock on %v: %v", block, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	locks := lt.GetLocks()
+	if len(locks) != numBlocks {
+		t.Errorf("Expected %d locked blocks, got %d", numBlocks, len(locks))
+	}
+	for block := range locks {
+		lt.Unlock(&block)
+	}
+	if locks := lt.GetLocks(); len(locks) != 0 {
+		t.Errorf("Expected no locks left, got %d", len(locks))
+	}
+}
+
+// Runs an operation of a transaction, returning the error of a
+// lock it couldn't acquire, which is raised as a panic
+func lockError(op func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+	return op()
+}
+
+func TestLockTable_SharedByTransactions(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "lockdb"))
+	defer db.fm.Close()
+
+	tx1 := db.newTx()
+	block, err := tx1.Append("locktest")
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	tx1.Pin(&block)
+	if err := tx1.SetInt(block, 0, 42, true); err != nil {
+		t.Fatalf("SetInt failed: %v", err)
+	}
+
+	// Another transaction of the database waits for the exclusive lock
+	tx2 := db.newTx()
+	defer tx2.Rollback()
+	tx2.SetLockTimeout(100 * time.Millisecond)
+	tx2.Pin(&block)
+	if err := lockError(func() error { _, err := tx2.GetInt(block, 0); return err }); err != tx.LockAbortError {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+
+	tx1.Commit()
+	if val, err := tx2.GetInt(block, 0); err != nil || val != 42 {
+		t.Errorf("Expected 42 once the writer committed, got %d, %v", val, err)
+	}
+}
+
+func TestLockTable_Deadlock(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "lockdb"))
+	defer db.fm.Close()
+
+	setup := db.newTx()
+	var blocks []file.BlockID
+	for i := 0; i < 2; i++ {
+		block, err := setup.Append("locktest")
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		blocks = append(blocks, block)
+	}
+	setup.Commit()
+
+	// Starts a transaction with the blocks pinned
+	begin := func() *tx.Transaction {
+		t1 := db.newTx()
+		t1.SetLockTimeout(5 * time.Second)
+		for i := range blocks {
+			t1.Pin(&blocks[i])
+		}
+		return t1
+	}
+	// Writes to the block in another goroutine, which waits for the lock
+	writeLater := func(t1 *tx.Transaction, block file.BlockID) chan error {
+		result := make(chan error, 1)
+		go func() {
+			result <- lockError(func() error { return t1.SetInt(block, 0, 1, true) })
+		}()
+		time.Sleep(50 * time.Millisecond)
+		return result
+	}
+
+	t.Run("Two Blocks", func(t *testing.T) {
+		tx1, tx2 := begin(), begin()
+		if err := tx1.SetInt(blocks[0], 0, 1, true); err != nil {
+			t.Fatalf("SetInt failed: %v", err)
+		}
+		if err := tx2.SetInt(blocks[1], 0, 2, true); err != nil {
+			t.Fatalf("SetInt failed: %v", err)
+		}
+		result := writeLater(tx1, blocks[1])
+
+		start := time.Now()
+		if err := lockError(func() error { return tx2.SetInt(blocks[0], 0, 2, true) }); !errors.Is(err, tx.DeadlockError) {
+			t.Errorf("Expected %v, got %v", tx.DeadlockError, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected the deadlock to be found right away, took %v", elapsed)
+		}
+
+		// Rolling back the victim lets the other transaction go on
+		tx2.Rollback()
+		if err := <-result; err != nil {
+			t.Errorf("Expected the waiting write to succeed, got %v", err)
+		}
+		tx1.Commit()
+	})
+
+	t.Run("Upgrade", func(t *testing.T) {
+		tx1, tx2 := begin(), begin()
+		for _, t1 := range []*tx.Transaction{tx1, tx2} {
+			if _, err := t1.GetInt(blocks[0], 0); err != nil {
+				t.Fatalf("GetInt failed: %v", err)
+			}
+		}
+		result := writeLater(tx1, blocks[0])
+
+		if err := lockError(func() error { return tx2.SetInt(blocks[0], 0, 2, true) }); !errors.Is(err, tx.DeadlockError) {
+			t.Errorf("Expected %v, got %v", tx.DeadlockError, err)
+		}
+		tx2.Rollback()
+		if err := <-result; err != nil {
+			t.Errorf("Expected the waiting write to succeed, got %v", err)
+		}
+		tx1.Commit()
+	})
+
+	t.Run("No Cycle", func(t *testing.T) {
+		tx1, tx2 := begin(), begin()
+		if err := tx1.SetInt(blocks[0], 0, 1, true); err != nil {
+			t.Fatalf("SetInt failed: %v", err)
+		}
+		result := writeLater(tx2, blocks[0])
+
+		tx1.Commit()
+		if err := <-result; err != nil {
+			t.Errorf("Expected the waiting write to succeed, got %v", err)
+		}
+		tx2.Commit()
+	})
+}
+
+func TestLockTable_NoWait(t *testing.T) {
+	lt := tx.NewLockTable()
+	lt.SetMaxWaitTime(tx.NOWAIT)
+	block := file.NewBlockID("test.db", 1)
+	if err := lt.XLock(block); err != nil {
+		t.Fatalf("Failed to acquire XLock: %v", err)
+	}
+	start := time.Now()
+	if err := lt.SLock(block); !errors.Is(err, tx.ErrLockNotAvailable) {
+		t.Errorf("Expected %v, got %v", tx.ErrLockNotAvailable, err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected no wait, took %v", elapsed)
+	}
+	lt.Unlock(block)
+	if err := lt.SLock(block); err != nil {
+		t.Errorf("Failed to acquire a free lock without waiting: %v", err)
+	}
+
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "lockdb"))
+	defer db.fm.Close()
+
+	tx1 := db.newTx()
+	defer tx1.Rollback()
+	txBlock, err := tx1.Append("locktest")
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	tx1.Pin(&txBlock)
+	if err := tx1.SetInt(txBlock, 0, 1, true); err != nil {
+		t.Fatalf("SetInt failed: %v", err)
+	}
+
+	tx2 := db.newTx()
+	defer tx2.Rollback()
+	tx2.SetLockTimeout(tx.NOWAIT)
+	tx2.Pin(&txBlock)
+	if err := lockError(func() error { _, err := tx2.GetInt(txBlock, 0); return err }); !errors.Is(err, tx.ErrLockNotAvailable) {
+		t.Errorf("Expected %v, got %v", tx.ErrLockNotAvailable, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"centauri/internal/app/file"
 	"centauri/internal/app/log"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -235,3 +236,155 @@ func TestLogManager_ConcurrentOperations(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestLogManager_ForwardIterator(t *testing.T) {
+	lm, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	// Enough records to fill several blocks
+	var records [][]byte
+	var lsns []int
+	for i := 0; i < 50; i++ {
+		record := []byte(fmt.Sprintf("record%02d", i))
+		lsn, err := lm.Append(record)
+		if err != nil {
+			t.Fatalf("failed to append record: %v", err)
+		}
+		records = append(records, record)
+		lsns = append(lsns, lsn)
+	}
+
+	for _, from := range []int{0, 17, 49} {
+		iter, err := lm.ForwardIterator(lsns[from])
+		if err != nil {
+			t.Fatalf("ForwardIterator() error = %v", err)
+		}
+		i := from
+		for iter.HasNext() {
+			record, err := iter.Next()
+			if err != nil {
+				t.Fatalf("ForwardIterator.Next() error = %v", err)
+			}
+			if i >= len(records) {
+				t.Fatalf("ForwardIterator returned too many records from %d", from)
+			}
+			if !bytes.Equal(record, records[i]) || iter.LSN() != lsns[i] {
+				t.Errorf("ForwardIterator.Next() = %s at LSN %d, want %s at LSN %d", record, iter.LSN(), records[i], lsns[i])
+			}
+			i++
+		}
+		if i != len(records) {
+			t.Errorf("ForwardIterator from record %d stopped at %d", from, i)
+		}
+	}
+
+	// An LSN between two records starts at the later one
+	iter, err := lm.ForwardIterator(lsns[10] + 1)
+	if err != nil {
+		t.Fatalf("ForwardIterator() error = %v", err)
+	}
+	if record, _ := iter.Next(); !bytes.Equal(record, records[11]) {
+		t.Errorf("ForwardIterator.Next() = %s, want %s", record, records[11])
+	}
+}
+
+func TestLogManager_LSNLocation(t *testing.T) {
+	lm, _, cleanup := setupTest(t)
+	defer cleanup()
+
+	for i := 0; i < 50; i++ {
+		lsn, err := lm.Append([]byte(fmt.Sprintf("record%02d", i)))
+		if err != nil {
+			t.Fatalf("failed to append record: %v", err)
+		}
+		block, pos := log.LSNLocation(lsn, 400)
+		if got := log.LSNAt(block, pos, 400); got != lsn {
+			t.Errorf("LSNLocation(%d) = (%d, %d), which maps back to %d", lsn, block, pos, got)
+		}
+		if pos < 4 || pos >= 400 {
+			t.Errorf("LSNLocation(%d) = position %d, outside the block", lsn, pos)
+		}
+	}
+}
+
+func TestLogManager_Checksums(t *testing.T) {
+	fm, err := file.NewFileManager(t.TempDir(), 400)
+	if err != nil {
+		t.Fatalf("failed to create file manager: %v", err)
+	}
+	defer fm.Close()
+	lm, err := log.NewLogManager(fm, "test.log")
+	if err != nil {
+		t.Fatalf("failed to create log manager: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		lm.Append([]byte(fmt.Sprintf("record%d", i)))
+	}
+	lm.Flush(lm.LatestLSN())
+
+	// Damage the contents of the middle record, which
+	// starts right after the last one appended
+	block := file.NewBlockID("test.log", 0)
+	p := file.NewPage(fm.BlockSize())
+	fm.Read(block, p)
+	_, pos := log.LSNLocation(lm.LatestLSN(), fm.BlockSize())
+	middle := pos + log.RECORD_OVERHEAD + len("record2")
+	p.Contents()[middle+4] ^= 0xff
+	fm.Write(block, p)
+
+	reopened, err := log.NewLogManager(fm, "test.log")
+	if err != nil {
+		t.Fatalf("failed to reopen log: %v", err)
+	}
+	backward, _ := reopened.Iterator()
+	if _, err := backward.Next(); err != nil {
+		t.Fatalf("Iterator.Next() error = %v on an intact record", err)
+	}
+	if _, err := backward.Next(); !errors.Is(err, log.ErrCorruptLogRecord) {
+		t.Errorf("Iterator.Next() error = %v, want %v", err, log.ErrCorruptLogRecord)
+	}
+	if _, err := reopened.ForwardIterator(0); !errors.Is(err, log.ErrCorruptLogRecord) {
+		t.Errorf("ForwardIterator() error = %v, want %v", err, log.ErrCorruptLogRecord)
+	}
+}
+
+func TestLogManager_RecordsWithoutChecksums(t *testing.T) {
+	fm, err := file.NewFileManager(t.TempDir(), 400)
+	if err != nil {
+		t.Fatalf("failed to create file manager: %v", err)
+	}
+	defer fm.Close()
+
+	// A block written by a version that didn't checksum records
+	block, _ := fm.Append("old.log")
+	p := file.NewPage(fm.BlockSize())
+	pos := fm.BlockSize()
+	for _, rec := range []string{"first", "second"} {
+		pos -= 4 + len(rec)
+		p.SetBytes(pos, []byte(rec))
+	}
+	p.SetInt(0, int32(pos))
+	fm.Write(block, p)
+
+	lm, err := log.NewLogManager(fm, "old.log")
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+	lm.Append([]byte("third"))
+
+	iter, err := lm.ForwardIterator(0)
+	if err != nil {
+		t.Fatalf("ForwardIterator() error = %v", err)
+	}
+	var got []string
+	for iter.HasNext() {
+		rec, err := iter.Next()
+		if err != nil {
+			t.Fatalf("ForwardIterator.Next() error = %v", err)
+		}
+		got = append(got, string(rec))
+	}
+	if fmt.Sprint(got) != "[first second third]" {
+		t.Errorf("ForwardIterator returned %v, want [first second third]", got)
+	}
+}
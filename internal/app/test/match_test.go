@@ -0,0 +1,181 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/query"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParser_InBetweenLike(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"select id from t where id in (1, 2, 3)", "select id from t where id IN (1, 2, 3)"},
+		{"select id from t where id not in (1, b)", "select id from t where NOT (id IN (1, b))"},
+		{"select id from t where id between 1 and 10 and id <> 5", "select id from t where id BETWEEN 1 AND 10 AND id<>5"},
+		{"select id from t where id not between a and 3", "select id from t where NOT (id BETWEEN a AND 3)"},
+		{"select id from t where name like 'Jo%'", "select id from t where name LIKE Jo%"},
+		{"select id from t where name not like 'J_'", "select id from t where NOT (name LIKE J_)"},
+	}
+	for _, tt := range tests {
+		data, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := data.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+	}
+
+	for _, sql := range []string{
+		"select id from t where id in ()",
+		"select id from t where id in (1, 2",
+		"select id from t where id between 1",
+		"select id from t where id between 1 or 2",
+		"select id from t where name like",
+		"select id from t where id not 3",
+	} {
+		if _, err := parse.NewParser(sql).Query(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestLike(t *testing.T) {
+	tests := []struct {
+		str     string
+		pattern string
+		want    bool
+	}{
+		{"John", "Jo%", true},
+		{"Joan", "Jo%n", true},
+		{"Jo", "Jo%", true},
+		{"Ann", "Jo%", false},
+		{"John", "J_hn", true},
+		{"John", "J_n", false},
+		{"John", "%", true},
+		{"", "%", true},
+		{"", "_", false},
+		{"banana", "%an%a", true},
+		{"banana", "b%n_", true},
+		{"banana", "%x%", false},
+		{"100%", "100%", true},
+		{"Ünïcode", "_n_code", true},
+		{"john", "Jo%", false},
+	}
+	for _, tt := range tests {
+		if got := query.Like(tt.str, tt.pattern); got != tt.want {
+			t.Errorf("%q LIKE %q: expected %v, got %v", tt.str, tt.pattern, tt.want, got)
+		}
+	}
+}
+
+func TestInBetweenLike_Query(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "matchdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), salary int)",
+		"insert into emp (id, name, salary) values (1, 'John', 300), (2, 'Joan', 100), (3, 'Ann', NULL), (4, 'Jo', 200)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select id from emp where id in (1, 3, 9)", "[[1] [3]]"},
+		{"select id from emp where id not in (1, 3)", "[[2] [4]]"},
+		// NOT IN a list holding NULL is never true
+		{"select id from emp where id not in (1, null)", "[]"},
+		{"select id from emp where id in (2, null)", "[[2]]"},
+		{"select id from emp where salary between 100 and 200", "[[2] [4]]"},
+		{"select id from emp where salary not between 150 and 250", "[[1] [2]]"},
+		{"select id from emp where id between 3 and 2", "[]"},
+		{"select id from emp where name like 'Jo%'", "[[1] [2] [4]]"},
+		{"select id from emp where name like 'J_%n'", "[[1] [2]]"},
+		{"select id from emp where name not like '%o%'", "[[3]]"},
+		{"select id from emp where name in ('Ann', 'Jo') and salary between 0 and 1000", "[[4]]"},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		sort.Strings(records)
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	// Changing records through the operators
+	if n, err := d.Exec("update emp set salary = 0 where id in (1, 2)"); err != nil || n != 2 {
+		t.Fatalf("update: expected 2 records, got %d, %v", n, err)
+	}
+	if n, err := d.Exec("delete from emp where name like 'Jo%' and salary between 0 and 0"); err != nil || n != 2 {
+		t.Fatalf("delete: expected 2 records, got %d, %v", n, err)
+	}
+	if got := countRows(t, d, "select id from emp"); got != 2 {
+		t.Errorf("expected 2 records left, got %d", got)
+	}
+
+	for _, stmt := range []string{
+		"select id from emp where id in (1, 'x')",
+		"select id from emp where name between 1 and 'z'",
+		"select id from emp where id like '1%'",
+		"select id from emp where name like 1",
+	} {
+		if _, err := d.Query(stmt); !errors.Is(err, plan.ErrTypeMismatch) {
+			t.Errorf("%s: expected %v, got %v", stmt, plan.ErrTypeMismatch, err)
+		}
+	}
+	if _, err := d.Query("select id from emp where nope in (1, 2)"); !errors.Is(err, plan.ErrUnknownField) {
+		t.Errorf("expected %v, got %v", plan.ErrUnknownField, err)
+	}
+}
+
+func TestInBetweenLike_HeuristicPlanner(t *testing.T) {
+	db := openOptimizerTestDB(t)
+
+	tests := []struct {
+		query   string
+		explain string // part of the plan, or "" if it must not use an index
+		records string
+	}{
+		{"select name from emp where id between 10 and 12", "index select emp_id in (10, 11, 12)", "e10, e11, e12"},
+		{"select name from emp where id in (40, 7, 40)", "index select emp_id in (7, 40)", "e40, e7"},
+		{"select name from emp where id between 10 and 12 and name <> 'e11'", "index select emp_id in (10, 11, 12)", "e10, e12"},
+		// A range too wide to look up key by key is found by scanning the table
+		{"select name from emp where id between 1 and 95", "", ""},
+		{"select name from emp where name like 'e9_'", "", "e90, e91, e92, e93, e94, e95, e96, e97, e98, e99"},
+	}
+	for _, tt := range tests {
+		explained, records := planQuery(t, db, tt.query)
+		if tt.explain == "" && strings.Contains(explained, "index select") {
+			t.Errorf("%s: unexpected index select in plan\n%s", tt.query, explained)
+		}
+		if tt.explain != "" && !strings.Contains(explained, tt.explain) {
+			t.Errorf("%s: expected %q in plan\n%s", tt.query, tt.explain, explained)
+		}
+		if tt.records == "" {
+			if len(records) != 95 {
+				t.Errorf("%s: expected 95 records, got %d", tt.query, len(records))
+			}
+			continue
+		}
+		if got := strings.Join(records, ", "); got != tt.records {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.records, got)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"path/filepath"
+	"testing"
+)
+
+// Returns the number of temp table files in the database directory
+func tempFiles(t *testing.T, dir string) int {
+	files, err := filepath.Glob(filepath.Join(dir, "temp*.tbl"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	return len(files)
+}
+
+func TestMaterializePlan_SharedTempTable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "materializedb")
+	db := openRecoveryTestDB(t, dir)
+	defer db.fm.Close()
+
+	sch := schema.NewSchema()
+	sch.AddIntField("a")
+	var rows []map[string]*types.Constant
+	for i := 0; i < 5; i++ {
+		rows = append(rows, map[string]*types.Constant{"a": types.NewConstantInt(i)})
+	}
+
+	tx1 := db.newTx()
+	defer tx1.Rollback()
+
+	mp := materialize.NewMaterializePlan(tx1, plan.NewRowsPlan(sch, rows)).(*materialize.MaterializePlan)
+
+	// Two consumers read the same records from one temp table
+	s1 := mp.Open()
+	s2 := mp.Open()
+	for _, s := range []interface{ Next() bool }{s1, s2} {
+		count := 0
+		for s.Next() {
+			count++
+		}
+		if count != len(rows) {
+			t.Errorf("expected %d records, got %d", len(rows), count)
+		}
+	}
+
+	stats := mp.Stats()
+	if stats.Builds != 1 || stats.Reuses != 1 || stats.Records != len(rows) || stats.Blocks != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if got := tempFiles(t, dir); got != 1 {
+		t.Errorf("expected one temp table, found %d", got)
+	}
+
+	// The temp table outlives the first consumer, but not the last
+	s1.Close()
+	if got := tempFiles(t, dir); got != 1 {
+		t.Errorf("expected the temp table to be kept for the open scan, found %d", got)
+	}
+	s2.Close()
+	s2.Close()
+	if got := tempFiles(t, dir); got != 0 {
+		t.Errorf("expected the temp table to be dropped, found %d", got)
+	}
+
+	// Opening the plan again materializes the source again
+	s3 := mp.Open()
+	if !s3.Next() || s3.GetInt("a") != 0 {
+		t.Errorf("expected to read the first record again")
+	}
+	s3.Close()
+	if got := mp.Stats().Builds; got != 2 {
+		t.Errorf("expected 2 builds, got %d", got)
+	}
+}
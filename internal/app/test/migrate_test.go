@@ -0,0 +1,106 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/migrate"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrate_Statements(t *testing.T) {
+	stmts, err := migrate.Statements(`-- students
+create table students (id int, name varchar(10)); -- trailing comment
+insert into students (id, name) values (1, 'a;b--c');;
+`)
+	if err != nil {
+		t.Fatalf("Statements failed: %v", err)
+	}
+	want := "[create table students (id int, name varchar(10)) insert into students (id, name) values (1, 'a;b--c')]"
+	if got := fmt.Sprint(stmts); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if _, err := migrate.Statements("insert into t (s) values ('open"); err == nil {
+		t.Errorf("expected an unterminated string to fail")
+	}
+}
+
+func TestMigrate_Up(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "migratedb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	fsys := fstest.MapFS{
+		"0002_add_students.sql":    {Data: []byte("insert into students (id, name) values (1, 'ann');\ninsert into students (id, name) values (2, 'bob');")},
+		"0001_create_students.sql": {Data: []byte("create table students (id int, name varchar(10));")},
+		"README.md":                {Data: []byte("not a migration")},
+	}
+
+	// A dry run changes nothing
+	pending, err := migrate.Up(d, fsys, &migrate.Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(pending) != 2 || pending[0].Version != 1 || pending[1].Version != 2 {
+		t.Fatalf("expected migrations 1 and 2 to be pending, got %v", pending)
+	}
+	if versions, _ := migrate.Applied(d); len(versions) != 0 {
+		t.Errorf("expected a dry run to apply nothing, got %v", versions)
+	}
+
+	applied, err := migrate.Up(d, fsys, nil)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("expected 2 migrations applied, got %d", len(applied))
+	}
+	if count := countRows(t, d, "select id from students"); count != 2 {
+		t.Errorf("expected 2 students, got %d", count)
+	}
+
+	// Applied migrations don't run again
+	applied, err = migrate.Up(d, fsys, nil)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("expected nothing to apply, got %v, %v", applied, err)
+	}
+
+	// A failing migration is rolled back and stops the ones after it
+	fsys["0003_add_carl.sql"] = &fstest.MapFile{Data: []byte("insert into students (id, name) values (3, 'carl');\ninsert into nosuchtable (id) values (1);")}
+	fsys["0004_add_dora.sql"] = &fstest.MapFile{Data: []byte("insert into students (id, name) values (4, 'dora');")}
+	if _, err := migrate.Up(d, fsys, nil); err == nil {
+		t.Fatalf("expected migration 3 to fail")
+	}
+	if count := countRows(t, d, "select id from students"); count != 2 {
+		t.Errorf("expected the failed migration to be rolled back, got %d students", count)
+	}
+	versions, err := migrate.Applied(d)
+	if err != nil || fmt.Sprint(versions) != "[1 2]" {
+		t.Errorf("expected versions [1 2], got %v, %v", versions, err)
+	}
+
+	// Migrations older than the latest applied are refused
+	delete(fsys, "0003_add_carl.sql")
+	if _, err := migrate.Up(d, fsys, nil); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	fsys["0003_add_carl.sql"] = &fstest.MapFile{Data: []byte("insert into students (id, name) values (3, 'carl');")}
+	if _, err := migrate.Up(d, fsys, nil); !errors.Is(err, migrate.ErrOutOfOrder) {
+		t.Errorf("expected ErrOutOfOrder, got %v", err)
+	}
+}
+
+func TestMigrate_DuplicateVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"1_a.sql":  {Data: []byte("create table a (id int);")},
+		"01_b.sql": {Data: []byte("create table b (id int);")},
+	}
+	if _, err := migrate.Load(fsys); !errors.Is(err, migrate.ErrDuplicateVersion) {
+		t.Errorf("expected ErrDuplicateVersion, got %v", err)
+	}
+}
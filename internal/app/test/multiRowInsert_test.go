@@ -0,0 +1,78 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/record"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestInsert_MultiRow(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "multirowdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8))",
+		"create table orders (id int, year int) partition by range (year) (partition old values less than (2000), partition recent values less than maxvalue)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	n, err := d.Exec("insert into emp (id, name) values (1, 'ann'), (2, 'bo'), (3, NULL)")
+	if err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 records inserted, got %d", n)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select id, name from emp")); got != "[[1 ann] [2 bo] [3 <nil>]]" {
+		t.Errorf("unexpected records %s", got)
+	}
+
+	// Each record goes to the partition its key routes to
+	if _, err := d.Exec("insert into orders (id, year) values (1, 1999), (2, 2005), (3, 1980)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	records := queryRecords(t, d, "select id from orders where year < 2000")
+	sort.Strings(records)
+	if got := fmt.Sprint(records); got != "[[1] [3]]" {
+		t.Errorf("expected the old orders 1 and 3, got %s", got)
+	}
+
+	// A record that can't be inserted undoes the whole statement
+	if _, err := d.Exec("insert into emp (id, name) values (4, 'cy'), ('five', 'dee')"); !errors.Is(err, record.ErrFieldType) {
+		t.Errorf("expected %v, got %v", record.ErrFieldType, err)
+	}
+	if _, err := d.Exec("insert into emp (id, name) values (4, 'cy'), (5)"); err == nil {
+		t.Errorf("expected a record with too few values to fail")
+	}
+	if got := countRows(t, d, "select id from emp"); got != 3 {
+		t.Errorf("expected the failed inserts to leave 3 records, got %d", got)
+	}
+}
+
+func TestInsert_MultiRowIndexes(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	db.exec(t, "create table emp (id int, name varchar(8))")
+	db.exec(t, "create index emp_name on emp (name)")
+
+	if got := db.exec(t, "insert into emp (id, name) values (1, 'ann'), (2, 'bo'), (3, 'ann')"); got != 3 {
+		t.Errorf("expected 3 records inserted, got %d", got)
+	}
+	if got := db.mdm.RowCount("emp", db.tx); got != 3 {
+		t.Errorf("expected a row count of 3, got %d", got)
+	}
+
+	// Every record is in the index
+	if got := db.exec(t, "delete from emp where name = 'ann'"); got != 2 {
+		t.Errorf("expected 2 records deleted through the index, got %d", got)
+	}
+}
@@ -0,0 +1,155 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/types"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestConstant_Null(t *testing.T) {
+	null := types.NewConstantNull()
+	if !null.IsNull() || types.NewConstantInt(0).IsNull() || types.NewConstantString("").IsNull() {
+		t.Errorf("expected only the null constant to be null")
+	}
+	if null.String() != "NULL" || null.Kind() != types.KIND_NULL {
+		t.Errorf("unexpected null constant %s of kind %d", null, null.Kind())
+	}
+
+	// NULL sorts before every value and equals itself, so that records
+	// without a value sort and group together
+	for _, c := range []*types.Constant{types.NewConstantInt(-1 << 31), types.NewConstantString("")} {
+		if null.CompareTo(c) >= 0 || c.CompareTo(null) <= 0 || null.Equals(c) {
+			t.Errorf("expected NULL to sort before %s", c)
+		}
+	}
+	if !null.Equals(types.NewConstantNull()) || null.HashCode() != types.NewConstantNull().HashCode() {
+		t.Errorf("expected NULL to equal itself")
+	}
+}
+
+func TestParser_Null(t *testing.T) {
+	tests := []struct {
+		pred string
+		want string
+	}{
+		{"manager is null", "manager is null"},
+		{"manager IS NOT NULL and id = 1", "manager is not null AND id=1"},
+		{"name = null", "name=NULL"},
+	}
+	for _, tt := range tests {
+		if got := parsePredicate(t, tt.pred).String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.pred, tt.want, got)
+		}
+	}
+
+	modify, err := parse.NewParser("update emp set manager = NULL where id = 1").Modify()
+	if err != nil {
+		t.Fatalf("Modify failed: %v", err)
+	}
+	if c := modify.NewValue().AsConstant(); !c.IsNull() {
+		t.Errorf("expected the new value to be NULL, got %v", c)
+	}
+}
+
+func TestNull_Query(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nulldb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), manager int)",
+		"create index emp_manager on emp (manager)",
+		"insert into emp (id, name, manager) values (1, 'ann', NULL)",
+		"insert into emp (id, name, manager) values (2, NULL, 1)",
+		"insert into emp (id, name, manager) values (3, 'cy', 1)",
+		"insert into emp (id, name, manager) values (4, 'dee', 3)",
+		// Setting a value clears the null, and setting NULL sets it again
+		"update emp set name = 'bo' where id = 2",
+		"update emp set manager = NULL where id = 4",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	check := func(d *db.DB) {
+		t.Helper()
+		for query, want := range map[string]string{
+			"select id, manager from emp":                         "[[1 <nil>] [2 1] [3 1] [4 <nil>]]",
+			"select id from emp where manager is null":            "[[1] [4]]",
+			"select id from emp where manager is not null":        "[[2] [3]]",
+			"select name from emp where id = 2":                   "[[bo]]",
+			"select id from emp where manager = 1":                "[[2] [3]]",
+			"select id from emp where manager = null":             "[]",
+			"select id from emp where manager is null and id = 4": "[[4]]",
+			// Aggregates skip nulls
+			"select min(manager), max(manager) from emp": "[[1 1]]",
+		} {
+			if got := fmt.Sprint(queryRecords(t, d, query)); got != want {
+				t.Errorf("%s: expected %s, got %s", query, want, got)
+			}
+		}
+	}
+	check(d)
+
+	// The nulls are stored with the records
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	d, err = db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer d.Close()
+	check(d)
+
+	// Records with a null key are removed from the index with them
+	if _, err := d.Exec("delete from emp where manager is null"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select id from emp where manager = 1")); got != "[[2] [3]]" {
+		t.Errorf("expected [[2] [3]], got %s", got)
+	}
+
+	// Bulk loads take nulls as nil
+	rows := func(yield func([]any) bool) {
+		yield([]any{5, nil, nil})
+	}
+	if _, err := d.BulkInsert("emp", nil, rows); err != nil {
+		t.Fatalf("BulkInsert failed: %v", err)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select name, manager from emp where id = 5")); got != "[[<nil> <nil>]]" {
+		t.Errorf("expected [[<nil> <nil>]], got %s", got)
+	}
+}
+
+func TestNull_Join(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "nulldb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, dept int)",
+		"create table dept (did int, dname varchar(8))",
+		"insert into emp (id, dept) values (1, 10)",
+		"insert into emp (id, dept) values (2, NULL)",
+		"insert into dept (did, dname) values (10, 'ops')",
+		"insert into dept (did, dname) values (NULL, 'none')",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	// Nulls never equal each other in a predicate, so they join no record
+	if got := fmt.Sprint(queryRecords(t, d, "select id, dname from emp, dept where dept = did")); got != "[[1 ops]]" {
+		t.Errorf("expected [[1 ops]], got %s", got)
+	}
+}
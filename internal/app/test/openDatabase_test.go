@@ -0,0 +1,84 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/server"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenDatabase_EmptyDirectory(t *testing.T) {
+	dir := t.TempDir()
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("expected an empty directory to get a new database, got %v", err)
+	}
+	if _, err := d.Exec("create table t (id int)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	if _, err := d.Exec("insert into t (id) values (1)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	d.Close()
+
+	d, err = db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer d.Close()
+	if got := fmt.Sprint(queryRecords(t, d, "select id from t")); got != "[[1]]" {
+		t.Errorf("expected [[1]], got %s", got)
+	}
+}
+
+func TestOpenDatabase_NotDatabase(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Open(dir, nil); !errors.Is(err, server.ErrNotDatabase) {
+		t.Fatalf("expected %v, got %v", server.ErrNotDatabase, err)
+	}
+	// Nothing is written to the directory
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected the directory to be left alone, got %d files", len(entries))
+	}
+}
+
+// Returns the number of files the process has open
+func openFiles(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("the open files of the process can't be listed")
+	}
+	return len(entries)
+}
+
+func TestOpenDatabase_FailureClosesFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "opendb")
+	cdb, err := server.OpenCentauriDB(dir, 400, 8)
+	if err != nil {
+		t.Fatalf("OpenCentauriDB failed: %v", err)
+	}
+	t1 := cdb.NewTx()
+	setCatalogVersion(t1, metadata.CATALOG_VERSION+1)
+	t1.Commit()
+	cdb.Close()
+
+	// A database that fails to open leaves no file open
+	before := openFiles(t)
+	for i := 0; i < 3; i++ {
+		if _, err := server.OpenCentauriDB(dir, 400, 8); err == nil {
+			t.Fatalf("expected the newer catalog to be refused")
+		}
+	}
+	if after := openFiles(t); after != before {
+		t.Errorf("expected %d open files after the failures, got %d", before, after)
+	}
+}
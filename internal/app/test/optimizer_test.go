@@ -0,0 +1,153 @@
+package test
+
+import (
+	"centauri/internal/app/optimization"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Opens a database holding emp (id, name, deptid) with 100 employees spread
+// over 5 departments, and dept (did, dname), with indexes on emp.id,
+// emp.deptid and dept.did. Department 5 has no employees.
+func openOptimizerTestDB(t *testing.T) *indexPlannerTestDB {
+	db := openIndexPlannerTestDB(t)
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), deptid int)",
+		"create table dept (did int, dname varchar(8))",
+		"create index emp_id on emp (id)",
+		"create index emp_dept on emp (deptid)",
+		"create index dept_did on dept (did)",
+	} {
+		db.exec(t, stmt)
+	}
+	for i := 0; i < 100; i++ {
+		db.exec(t, fmt.Sprintf("insert into emp (id, name, deptid) values (%d, 'e%d', %d)", i, i, i%5))
+	}
+	for i := 1; i <= 5; i++ {
+		db.exec(t, fmt.Sprintf("insert into dept (did, dname) values (%d, 'd%d')", i, i))
+	}
+	return db
+}
+
+// Plans the query with the heuristic planner and returns the plan's
+// operators, one per line, with its records sorted
+func planQuery(t *testing.T, db *indexPlannerTestDB, query string) (string, []string) {
+	t.Helper()
+	data, err := parse.NewParser(query).Query()
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	p, err := optimization.NewHeuristicQueryPlanner(db.mdm).CreatePlan(data, db.tx)
+	if err != nil {
+		t.Fatalf("%s: CreatePlan failed: %v", query, err)
+	}
+
+	s := p.Open()
+	defer s.Close()
+	var records []string
+	for s.Next() {
+		vals := make([]string, len(data.Fields()))
+		for i, field := range data.Fields() {
+			vals[i] = s.GetVal(field).String()
+		}
+		records = append(records, strings.Join(vals, " "))
+	}
+	sort.Strings(records)
+	return plan.Explain(p), records
+}
+
+func TestHeuristicQueryPlanner_ChosenOperators(t *testing.T) {
+	db := openOptimizerTestDB(t)
+
+	tests := []struct {
+		query     string
+		operators []string // operators the plan must use
+		absent    []string // operators the plan must not use
+		records   []string
+	}{
+		{
+			query:     "select name from emp where id = 42",
+			operators: []string{"index select emp_id = 42"},
+			records:   []string{"e42"},
+		},
+		{
+			query:     "select name from emp where id = 42 and deptid = 2",
+			operators: []string{"index select", "select"},
+			records:   []string{"e42"},
+		},
+		{
+			query:     "select name from emp where id = 42 and deptid = 1",
+			operators: []string{"index select"},
+		},
+		{
+			query:     "select id from emp where name = 'e7'",
+			operators: []string{"table emp"},
+			absent:    []string{"index"},
+			records:   []string{"7"},
+		},
+		{
+			query:     "select name, dname from dept, emp where did = deptid and id = 13",
+			operators: []string{"index join"},
+			absent:    []string{"product"},
+			records:   []string{"e13 d3"},
+		},
+		{
+			query:     "select name from dept, emp where deptid = did and dname = 'd5'",
+			operators: []string{"index join emp_dept on did"},
+		},
+		{
+			query:     "select id, did from emp, dept where id = 3 and did = 4",
+			operators: []string{"index select emp_id = 3", "index select dept_did = 4", "multibuffer product"},
+			records:   []string{"3 4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			explained, records := planQuery(t, db, tt.query)
+			for _, op := range tt.operators {
+				if !strings.Contains(explained, op) {
+					t.Errorf("expected operator %q in plan\n%s", op, explained)
+				}
+			}
+			for _, op := range tt.absent {
+				if strings.Contains(explained, op) {
+					t.Errorf("unexpected operator %q in plan\n%s", op, explained)
+				}
+			}
+			if strings.Join(records, ", ") != strings.Join(tt.records, ", ") {
+				t.Errorf("expected records %v, got %v", tt.records, records)
+			}
+		})
+	}
+}
+
+func TestIndexJoinPlan_Records(t *testing.T) {
+	db := openOptimizerTestDB(t)
+
+	// Every employee appears once, with the name of its department,
+	// and the department without employees not at all
+	_, records := planQuery(t, db, "select id, dname from dept, emp where did = deptid")
+	if len(records) != 80 {
+		t.Errorf("expected 80 records, got %d", len(records))
+	}
+	seen := make(map[string]bool)
+	for _, record := range records {
+		var id int
+		var dname string
+		fmt.Sscan(record, &id, &dname)
+		if want := fmt.Sprintf("d%d", id%5); dname != want || seen[record] {
+			t.Errorf("unexpected record %q", record)
+		}
+		seen[record] = true
+	}
+
+	// A join whose outer side is empty outputs nothing
+	explained, records := planQuery(t, db, "select name from dept, emp where did = deptid and dname = 'none'")
+	if !strings.Contains(explained, "index join") || len(records) != 0 {
+		t.Errorf("expected no records from an index join, got %v\n%s", records, explained)
+	}
+}
@@ -0,0 +1,122 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/optimization"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_OrderBy(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"select id from t order by id", "select id from t order by id"},
+		{"select id, name from t where id = 1 ORDER BY name DESC, id ASC limit 3", "select id, name from t where id=1 order by name desc, id limit 3"},
+		{"select id from t order by name nulls first, id desc nulls last", "select id from t order by name nulls first, id desc nulls last"},
+	}
+	for _, tt := range tests {
+		data, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		// Views are stored as their query text, so it must parse back the same
+		if got := data.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+	}
+
+	for _, sql := range []string{"select id from t order id", "select id from t order by", "select id from t order by id nulls"} {
+		if _, err := parse.NewParser(sql).Query(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestOrderBy_Query(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "orderdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), dept int)",
+		"insert into emp (id, name, dept) values (1, 'cy', 2)",
+		"insert into emp (id, name, dept) values (2, 'ann', 1)",
+		"insert into emp (id, name, dept) values (3, 'bo', NULL)",
+		"insert into emp (id, name, dept) values (4, 'dee', 2)",
+		"create view ranked as select id, dept from emp order by dept desc, id",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select name from emp order by name", "[[ann] [bo] [cy] [dee]]"},
+		{"select name from emp order by name desc", "[[dee] [cy] [bo] [ann]]"},
+		// Nulls come last in ascending order and first in descending order
+		{"select id from emp order by dept, id", "[[2] [1] [4] [3]]"},
+		{"select id from emp order by dept desc, id", "[[3] [1] [4] [2]]"},
+		{"select id from emp order by dept nulls first, id desc", "[[3] [2] [4] [1]]"},
+		// A query may be ordered by fields it doesn't output
+		{"select name from emp where dept = 2 order by id desc", "[[dee] [cy]]"},
+		// The limit applies to the ordered records
+		{"select id from emp order by name desc limit 2", "[[4] [1]]"},
+		{"select max(id), min(id) from emp order by maxofid", "[[4 1]]"},
+		{"select id from ranked", "[[3] [1] [4] [2]]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(queryRecords(t, d, tt.query)); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	for _, query := range []string{
+		"select id from emp order by salary",
+		"select max(id) from emp order by name",
+	} {
+		if _, err := d.Query(query); !errors.Is(err, plan.ErrUnknownField) {
+			t.Errorf("%s: expected %v, got %v", query, plan.ErrUnknownField, err)
+		}
+	}
+}
+
+func TestOrderBy_HeuristicPlanner(t *testing.T) {
+	db := openOptimizerTestDB(t)
+
+	// The records are sorted before the limit, rather than the index
+	// select stopping at the first three
+	query := "select name from emp where deptid = 3 order by id desc limit 3"
+	data, err := parse.NewParser(query).Query()
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	p, err := optimization.NewHeuristicQueryPlanner(db.mdm).CreatePlan(data, db.tx)
+	if err != nil {
+		t.Fatalf("CreatePlan failed: %v", err)
+	}
+	if explain := plan.Explain(p); !strings.Contains(explain, "sort id desc") {
+		t.Errorf("expected the plan to sort by id, got:\n%s", explain)
+	}
+
+	s := p.Open()
+	defer s.Close()
+	var names []string
+	for s.Next() {
+		names = append(names, s.GetString("name"))
+	}
+	if got := strings.Join(names, " "); got != "e98 e93 e88" {
+		t.Errorf("expected e98 e93 e88, got %s", got)
+	}
+}
@@ -1,14 +1,38 @@
 package test
 
 import (
+	"centauri/db"
 	"centauri/internal/app/parse"
 	"centauri/internal/app/query"
 	"centauri/internal/app/record/schema"
 	"centauri/internal/app/types"
+	"errors"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
+// Parses a predicate, failing the test if it is malformed
+func parsePredicate(t *testing.T, s string) *query.Predicate {
+	t.Helper()
+	pred, err := parse.NewParser(s).Predicate()
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return pred
+}
+
+// Parses a value expression, failing the test if it is malformed
+func parseValueExpression(t *testing.T, s string) *query.Expression {
+	t.Helper()
+	e, err := parse.NewParser(s).ValueExpression()
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return e
+}
+
 func TestParser_Query(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -24,12 +48,55 @@ func TestParser_Query(t *testing.T) {
 				query.NewPredicate(),
 			),
 		},
+		{
+			name: "SELECT with LIMIT",
+			sql:  "select id from users limit 5",
+			expected: func() *parse.QueryData {
+				qd := parse.NewQueryData([]string{"id"}, []string{"users"}, query.NewPredicate())
+				qd.SetLimit(5)
+				return qd
+			}(),
+		},
+		{
+			name: "SELECT AS OF an LSN",
+			sql:  "select id from users as of 4812",
+			expected: func() *parse.QueryData {
+				qd := parse.NewQueryData([]string{"id"}, []string{"users"}, query.NewPredicate())
+				qd.SetAsOf(parse.NewAsOfLSN(4812))
+				return qd
+			}(),
+		},
+		{
+			name: "SELECT AS OF a timestamp",
+			sql:  "select id from users as of timestamp '2024-05-01T12:00:00Z' limit 1",
+			expected: func() *parse.QueryData {
+				qd := parse.NewQueryData([]string{"id"}, []string{"users"}, query.NewPredicate())
+				qd.SetAsOf(parse.NewAsOfTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+				qd.SetLimit(1)
+				return qd
+			}(),
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			parser := parse.NewParser(tt.sql)
-			result := parser.Query()
+			result, err := parser.Query()
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+
+			if (result.AsOf() == nil) != (tt.expected.AsOf() == nil) ||
+				result.AsOf() != nil && !result.AsOf().Time().Equal(tt.expected.AsOf().Time()) {
+				t.Errorf("AsOf mismatch: got %v, want %v", result.AsOf(), tt.expected.AsOf())
+			}
+
+			if result.Limit() != tt.expected.Limit() {
+				t.Errorf("Limit mismatch: got %d, want %d", result.Limit(), tt.expected.Limit())
+			}
+			if result.String() != tt.expected.String() {
+				t.Errorf("String mismatch: got %q, want %q", result.String(), tt.expected.String())
+			}
 
 			if !reflect.DeepEqual(result.Fields(), tt.expected.Fields()) {
 				t.Errorf("Fields mismatch: got %v, want %v", result.Fields(), tt.expected.Fields())
@@ -43,6 +110,37 @@ func TestParser_Query(t *testing.T) {
 
 }
 
+func TestParser_Aggregates(t *testing.T) {
+	result, err := parse.NewParser("select count(*), MIN(age), max(name) from users").Query()
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+
+	if want := []string{"count", "minofage", "maxofname"}; !reflect.DeepEqual(result.Fields(), want) {
+		t.Errorf("Fields mismatch: got %v, want %v", result.Fields(), want)
+	}
+
+	aggs := result.Aggregates()
+	if len(aggs) != 3 {
+		t.Fatalf("expected 3 aggregates, got %d", len(aggs))
+	}
+	if aggs[0].Fn() != parse.AGG_COUNT || aggs[0].FieldName() != "" {
+		t.Errorf("unexpected first aggregate %s", aggs[0])
+	}
+	if aggs[1].Fn() != parse.AGG_MIN || aggs[1].FieldName() != "age" {
+		t.Errorf("unexpected second aggregate %s", aggs[1])
+	}
+
+	// Views are stored as their query text, so it must parse back the same
+	if got := result.String(); got != "select count(*), min(age), max(name) from users" {
+		t.Errorf("unexpected query text %q", got)
+	}
+
+	if _, err := parse.NewParser("select avg(age) from users").Query(); !errors.Is(err, parse.ErrSyntax) {
+		t.Errorf("expected a syntax error for an unknown aggregate, got %v", err)
+	}
+}
+
 func TestParser_Insert(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -88,27 +186,42 @@ func TestParser_Insert(t *testing.T) {
 				},
 			),
 		},
-		// TODO: Implement support for NULL values
-		// {
-		// 	name: "INSERT with NULL values",
-		// 	sql:  "insert into products (id, name, description, price) values (1, 'Widget', NULL, 29)",
-		// 	expected: parse.NewInsertData(
-		// 		"products",
-		// 		[]string{"id", "name", "description", "price"},
-		// 		[]*types.Constant{
-		// 			types.NewConstantInt(1),
-		// 			types.NewConstantString("Widget"),
-		// 			types.NewConstantString("NULL"),
-		// 			types.NewConstantInt(29),
-		// 		},
-		// 	),
-		// },
+		{
+			name: "INSERT with NULL values",
+			sql:  "insert into products (id, name, description, price) values (1, 'Widget', NULL, 29)",
+			expected: parse.NewInsertData(
+				"products",
+				[]string{"id", "name", "description", "price"},
+				[]*types.Constant{
+					types.NewConstantInt(1),
+					types.NewConstantString("Widget"),
+					types.NewConstantNull(),
+					types.NewConstantInt(29),
+				},
+			),
+		},
+		{
+			name: "INSERT of several records",
+			sql:  "insert into users (id, name) values (1, 'John'), (2, NULL),(3, 'Jane')",
+			expected: parse.NewInsertDataWithRows(
+				"users",
+				[]string{"id", "name"},
+				[][]*types.Constant{
+					{types.NewConstantInt(1), types.NewConstantString("John")},
+					{types.NewConstantInt(2), types.NewConstantNull()},
+					{types.NewConstantInt(3), types.NewConstantString("Jane")},
+				},
+			),
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			parser := parse.NewParser(tt.sql)
-			result := parser.Insert()
+			result, err := parser.Insert()
+			if err != nil {
+				t.Fatalf("Insert failed: %v", err)
+			}
 
 			if result.TableName() != tt.expected.TableName() {
 				t.Errorf("Table name mismatch: got %v, want %v", result.TableName(), tt.expected.TableName())
@@ -118,12 +231,21 @@ func TestParser_Insert(t *testing.T) {
 				t.Errorf("Fields mismatch: got %v, want %v", result.Fields(), tt.expected.Fields())
 			}
 
-			if !reflect.DeepEqual(result.Values(), tt.expected.Values()) {
-				t.Errorf("Values mismatch: got %v, want %v", result.Values(), tt.expected.Values())
+			if !reflect.DeepEqual(result.Rows(), tt.expected.Rows()) {
+				t.Errorf("Values mismatch: got %v, want %v", result.Rows(), tt.expected.Rows())
 			}
 		})
 	}
 
+	for _, sql := range []string{
+		"insert into users (id) values (1),",
+		"insert into users (id) values (1), 2",
+	} {
+		if _, err := parse.NewParser(sql).UpdateCmd(); err == nil {
+			t.Errorf("%s: expected a syntax error", sql)
+		}
+	}
+
 }
 
 func TestParser_Delete(t *testing.T) {
@@ -148,7 +270,10 @@ func TestParser_Delete(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			parser := parse.NewParser(tt.sql)
-			result := parser.Delete()
+			result, err := parser.Delete()
+			if err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
 
 			if result.TableName() != tt.expected.TableName() {
 				t.Errorf("Table name mismatch: got %v, want %v", result.TableName(), tt.expected.TableName())
@@ -162,6 +287,256 @@ func TestParser_Delete(t *testing.T) {
 
 }
 
+func TestParser_Copy(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected *parse.CopyData
+	}{
+		{
+			name:     "Simple COPY",
+			sql:      "copy users from 'users.csv'",
+			expected: parse.NewCopyData("users", nil, "users.csv", nil),
+		},
+		{
+			name: "COPY with fields and options",
+			sql:  "copy users (id, name) from 'data/users.csv' with (header=on, batchsize=500)",
+			expected: parse.NewCopyData("users", []string{"id", "name"}, "data/users.csv",
+				map[string]string{"header": "on", "batchsize": "500"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := parse.NewParser(tt.sql)
+			obj, err := parser.UpdateCmd()
+			if err != nil {
+				t.Fatalf("UpdateCmd failed: %v", err)
+			}
+			result, ok := obj.(*parse.CopyData)
+			if !ok {
+				t.Fatalf("Expected *parse.CopyData")
+			}
+
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("CopyData mismatch: got %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParser_CopyTo(t *testing.T) {
+	parser := parse.NewParser("copy (select id, name from users where id = 1) to 'out/users.json' format json")
+	obj, err := parser.UpdateCmd()
+	if err != nil {
+		t.Fatalf("UpdateCmd failed: %v", err)
+	}
+	result, ok := obj.(*parse.CopyToData)
+	if !ok {
+		t.Fatalf("Expected *parse.CopyToData")
+	}
+
+	expectedQuery := parse.NewQueryData([]string{"id", "name"}, []string{"users"},
+		query.NewPredicateWithTerm(
+			query.NewTerm(
+				query.NewExpressionFieldName("id"),
+				query.NewExpressionVal(types.NewConstantInt(1)),
+			),
+		))
+
+	if !reflect.DeepEqual(result.Query(), expectedQuery) {
+		t.Errorf("Query mismatch: got %v, want %v", result.Query(), expectedQuery)
+	}
+	if result.FileName() != "out/users.json" {
+		t.Errorf("File name mismatch: got %v, want %v", result.FileName(), "out/users.json")
+	}
+	if result.Format() != "json" {
+		t.Errorf("Format mismatch: got %v, want %v", result.Format(), "json")
+	}
+}
+
+func TestParser_SessionCmd(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected interface{}
+	}{
+		{
+			name:     "SET with string",
+			sql:      "set lock_timeout = '5s'",
+			expected: parse.NewSetData("lock_timeout", "5s"),
+		},
+		{
+			name:     "SET with TO and identifier",
+			sql:      "SET Isolation_Level TO serializable",
+			expected: parse.NewSetData("isolation_level", "serializable"),
+		},
+		{
+			name:     "SET with integer",
+			sql:      "set lock_timeout = 500",
+			expected: parse.NewSetData("lock_timeout", "500"),
+		},
+		{
+			name:     "SHOW",
+			sql:      "show lock_timeout",
+			expected: parse.NewShowData("lock_timeout"),
+		},
+		{
+			name:     "SHOW DATABASES",
+			sql:      "show databases",
+			expected: parse.NewShowDatabasesData(),
+		},
+		{
+			name:     "SHOW TABLES",
+			sql:      "SHOW TABLES",
+			expected: parse.NewShowTablesData(),
+		},
+		{
+			name:     "DESCRIBE",
+			sql:      "describe students",
+			expected: parse.NewDescribeData("students"),
+		},
+		{
+			name:     "DESC",
+			sql:      "desc students",
+			expected: parse.NewDescribeData("students"),
+		},
+		{
+			name:     "USE",
+			sql:      "use Sales",
+			expected: parse.NewUseData("sales"),
+		},
+		{
+			name:     "CREATE DATABASE",
+			sql:      "create database sales",
+			expected: parse.NewCreateDatabaseData("sales"),
+		},
+		{
+			name:     "BEGIN",
+			sql:      "begin",
+			expected: parse.NewBeginData(),
+		},
+		{
+			name:     "COMMIT",
+			sql:      "COMMIT",
+			expected: parse.NewCommitData(),
+		},
+		{
+			name:     "ROLLBACK",
+			sql:      "rollback",
+			expected: parse.NewRollbackData(),
+		},
+		{
+			name:     "BEGIN TRANSACTION",
+			sql:      "begin transaction",
+			expected: parse.NewBeginData(),
+		},
+		{
+			name:     "START TRANSACTION",
+			sql:      "Start Transaction",
+			expected: parse.NewBeginData(),
+		},
+		{
+			name:     "COMMIT WORK",
+			sql:      "commit work",
+			expected: parse.NewCommitData(),
+		},
+		{
+			name:     "ROLLBACK WORK",
+			sql:      "rollback work",
+			expected: parse.NewRollbackData(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parse.NewParser(tt.sql).SessionCmd()
+			if err != nil {
+				t.Fatalf("SessionCmd failed: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("SessionCmd mismatch: got %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParser_UserCmd(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected interface{}
+	}{
+		{
+			name:     "CREATE USER",
+			sql:      "create user alice with password 's3cret'",
+			expected: parse.NewCreateUserData("alice", "s3cret", false),
+		},
+		{
+			name:     "CREATE USER SUPERUSER",
+			sql:      "CREATE USER root WITH PASSWORD 'pw' SUPERUSER",
+			expected: parse.NewCreateUserData("root", "pw", true),
+		},
+		{
+			name:     "ALTER USER",
+			sql:      "alter user alice with password 'n3w'",
+			expected: parse.NewAlterUserData("alice", "n3w"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parse.NewParser(tt.sql).UpdateCmd()
+			if err != nil {
+				t.Fatalf("UpdateCmd failed: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("UpdateCmd mismatch: got %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParser_PrivilegeCmd(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		expected interface{}
+	}{
+		{
+			name:     "GRANT single privilege",
+			sql:      "grant select on users to alice",
+			expected: parse.NewGrantData([]string{"select"}, "users", "alice"),
+		},
+		{
+			name:     "GRANT privilege list",
+			sql:      "GRANT SELECT, INSERT, ALTER ON users TO alice",
+			expected: parse.NewGrantData([]string{"select", "insert", "alter"}, "users", "alice"),
+		},
+		{
+			name:     "REVOKE ALL PRIVILEGES",
+			sql:      "revoke all privileges on users from alice",
+			expected: parse.NewRevokeData([]string{"all"}, "users", "alice"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parse.NewParser(tt.sql).UpdateCmd()
+			if err != nil {
+				t.Fatalf("UpdateCmd failed: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("UpdateCmd mismatch: got %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+
+	if _, err := parse.NewParser("grant drop on users to alice").UpdateCmd(); !errors.Is(err, parse.ErrSyntax) {
+		t.Errorf("expected a syntax error for an unknown privilege, got %v", err)
+	}
+}
+
 func TestParser_CreateTable(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -178,12 +553,79 @@ func TestParser_CreateTable(t *testing.T) {
 				return parse.NewCreateTableData("users", s)
 			}(),
 		},
+		{
+			name: "Create table with storage options",
+			sql:  "table users (id int) with (fillfactor=80, compression=on)",
+			expected: func() *parse.CreateTableData {
+				s := schema.NewSchema()
+				s.AddIntField("id")
+				return parse.NewCreateTableDataWithOptions("users", s, map[string]string{
+					"fillfactor":  "80",
+					"compression": "on",
+				})
+			}(),
+		},
+		{
+			name: "Create table with collations",
+			sql:  "table users (name varchar(20) collate nocase, city varchar(20) collate 'sv-SE')",
+			expected: func() *parse.CreateTableData {
+				s := schema.NewSchema()
+				s.AddStringField("name", 20)
+				s.SetCollation("name", "nocase")
+				s.AddStringField("city", 20)
+				s.SetCollation("city", "sv-SE")
+				return parse.NewCreateTableData("users", s)
+			}(),
+		},
+		{
+			name: "Create table with generated fields",
+			sql:  "table items (price int, qty int, total int generated always as (price * qty) stored, label varchar(8) as ( upper(label) ))",
+			expected: func() *parse.CreateTableData {
+				s := schema.NewSchema()
+				s.AddIntField("price")
+				s.AddIntField("qty")
+				s.AddIntField("total")
+				s.SetGenerated("total", "price * qty", true)
+				s.AddStringField("label", 8)
+				s.SetGenerated("label", "upper(label)", false)
+				return parse.NewCreateTableData("items", s)
+			}(),
+		},
+		{
+			name: "Create table partitioned by range",
+			sql:  "table orders (id int, year int) partition by range (year) (partition old values less than (-1), partition rest values less than maxvalue)",
+			expected: func() *parse.CreateTableData {
+				s := schema.NewSchema()
+				s.AddIntField("id")
+				s.AddIntField("year")
+				data := parse.NewCreateTableData("orders", s)
+				data.SetPartitioning(parse.NewRangePartitionData("year", []parse.RangePartition{
+					{Name: "old", Bound: -1},
+					{Name: "rest", MaxValue: true},
+				}))
+				return data
+			}(),
+		},
+		{
+			name: "Create table partitioned by hash",
+			sql:  "table users (id int) with (fillfactor=80) partition by hash (id) partitions 4",
+			expected: func() *parse.CreateTableData {
+				s := schema.NewSchema()
+				s.AddIntField("id")
+				data := parse.NewCreateTableDataWithOptions("users", s, map[string]string{"fillfactor": "80"})
+				data.SetPartitioning(parse.NewHashPartitionData("id", 4))
+				return data
+			}(),
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			parser := parse.NewParser(tt.sql)
-			result := parser.CreateTable()
+			result, err := parser.CreateTable()
+			if err != nil {
+				t.Fatalf("CreateTable failed: %v", err)
+			}
 
 			if result.TableName() != tt.expected.TableName() {
 				t.Errorf("Table name mismatch: got %v, want %v", result.TableName(), tt.expected.TableName())
@@ -193,6 +635,26 @@ func TestParser_CreateTable(t *testing.T) {
 				t.Errorf("Table Schema mismatch: got %v, want %v", result.NewSchema().Fields(), tt.expected.NewSchema().Fields())
 
 			}
+
+			for _, field := range tt.expected.NewSchema().Fields() {
+				if got, want := result.NewSchema().Collation(field), tt.expected.NewSchema().Collation(field); got != want {
+					t.Errorf("Collation of %s mismatch: got %q, want %q", field, got, want)
+				}
+				if got, want := result.NewSchema().Generated(field), tt.expected.NewSchema().Generated(field); got != want {
+					t.Errorf("Generated expression of %s mismatch: got %q, want %q", field, got, want)
+				}
+				if got, want := result.NewSchema().IsVirtual(field), tt.expected.NewSchema().IsVirtual(field); got != want {
+					t.Errorf("Virtual %s mismatch: got %v, want %v", field, got, want)
+				}
+			}
+
+			if !reflect.DeepEqual(result.Options(), tt.expected.Options()) {
+				t.Errorf("Table options mismatch: got %v, want %v", result.Options(), tt.expected.Options())
+			}
+
+			if !reflect.DeepEqual(result.Partitioning(), tt.expected.Partitioning()) {
+				t.Errorf("Partitioning mismatch: got %+v, want %+v", result.Partitioning(), tt.expected.Partitioning())
+			}
 		})
 	}
 
@@ -224,7 +686,10 @@ func TestParser_UpdateCmd(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			parser := parse.NewParser(tt.sql)
-			result := parser.UpdateCmd()
+			result, err := parser.UpdateCmd()
+			if err != nil {
+				t.Fatalf("UpdateCmd failed: %v", err)
+			}
 
 			resultType := reflect.TypeOf(result).String()
 			if resultType != tt.kind {
@@ -234,3 +699,126 @@ func TestParser_UpdateCmd(t *testing.T) {
 	}
 
 }
+
+func TestParser_ValueExpression(t *testing.T) {
+	sch := schema.NewSchema()
+	sch.AddIntField("sal")
+	sch.AddStringField("name", 10)
+	s := query.NewRowsScan(sch, []map[string]*types.Constant{
+		{"sal": types.NewConstantInt(100), "name": types.NewConstantString("ann")},
+	})
+	s.Next()
+
+	tests := []struct {
+		expr     string
+		str      string
+		expected *types.Constant
+	}{
+		{"sal * 2 + 1", "((sal * 2) + 1)", types.NewConstantInt(201)},
+		{"sal - 10 - 20", "((sal - 10) - 20)", types.NewConstantInt(70)},
+		{"sal - (10 - 20)", "(sal - (10 - 20))", types.NewConstantInt(110)},
+		{"-sal / 3", "(-sal / 3)", types.NewConstantInt(-33)},
+		{"sal * -2", "(sal * -2)", types.NewConstantInt(-200)},
+		{"sal * 2147483647", "(sal * 2147483647)", types.NewConstantLong(214748364700)},
+		{"upper(name)", "upper(name)", types.NewConstantString("ANN")},
+		{"concat(name, '!')", "concat(name, !)", types.NewConstantString("ann!")},
+		{"abs(length(name) - 5)", "abs((length(name) - 5))", types.NewConstantInt(2)},
+		{
+			"case when name = 'bob' then 1 when sal = 100 then sal / 4 else 0 end",
+			"case when name=bob then 1 when sal=100 then (sal / 4) else 0 end",
+			types.NewConstantInt(25),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e := parseValueExpression(t, tt.expr)
+			if got := e.String(); got != tt.str {
+				t.Errorf("expected %q, got %q", tt.str, got)
+			}
+			if got := e.Evaluate(s); !got.Equals(tt.expected) {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	// A CASE without ELSE is null when no condition holds
+	if got := parseValueExpression(t, "case when sal = 1 then 2 end").Evaluate(s); !got.IsNull() {
+		t.Errorf("expected null, got %s", got)
+	}
+
+	for _, expr := range []string{"nosuch(sal)", "abs(sal, 1)", "case else 1 end", "(sal + 1"} {
+		if _, err := parse.NewParser(expr).ValueExpression(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("expected a syntax error for %q, got %v", expr, err)
+		}
+	}
+
+	for expr, want := range map[string]error{
+		"sal / 0":    query.ErrDivisionByZero,
+		"name + 1":   query.ErrInvalidOperand,
+		"upper(sal)": query.ErrInvalidOperand,
+		"abs(name)":  query.ErrInvalidOperand,
+	} {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, want) {
+					t.Errorf("%s: expected %v, got %v", expr, want, err)
+				}
+			}()
+			parseValueExpression(t, expr).Evaluate(s)
+		}()
+	}
+}
+
+func TestParser_SyntaxError(t *testing.T) {
+	tests := []struct {
+		sql    string
+		line   int
+		column int
+		msg    string
+	}{
+		{"select id from", 1, 15, "expected identifier, found end of statement"},
+		{"select id\nfrom users\nwhere id == 1", 3, 11, `expected integer constant, found "="`},
+		{"selec id from users", 1, 1, `expected keyword SELECT, found "selec"`},
+		{"select sum(id) from users", 1, 8, "unknown aggregate function sum"},
+		{"select id from users as of 0", 1, 28, "the LSN of AS OF must be positive"},
+		{"select id from users where name = 'joe", 1, 35, "unclosed string literal"},
+	}
+	for _, tt := range tests {
+		_, err := parse.NewParser(tt.sql).Query()
+		var syntaxErr *parse.SyntaxError
+		if !errors.As(err, &syntaxErr) || !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%q: expected a syntax error, got %v", tt.sql, err)
+			continue
+		}
+		if syntaxErr.Line != tt.line || syntaxErr.Column != tt.column || syntaxErr.Msg != tt.msg {
+			t.Errorf("%q: expected %q at %d:%d, got %q at %d:%d", tt.sql, tt.msg, tt.line, tt.column,
+				syntaxErr.Msg, syntaxErr.Line, syntaxErr.Column)
+		}
+	}
+
+	for _, sql := range []string{
+		"insert into users (id) values (1",
+		"create table users (id int, name varchar)",
+		"update users set = 1",
+		"grant select on users alice",
+	} {
+		if _, err := parse.NewParser(sql).UpdateCmd(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%q: expected a syntax error, got %v", sql, err)
+		}
+	}
+
+	// Statements run through the database fail with the error
+	d, err := db.Open(filepath.Join(t.TempDir(), "syntaxdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+	if _, err := d.Exec("create table users (id int"); !errors.Is(err, parse.ErrSyntax) {
+		t.Errorf("expected Exec to return a syntax error, got %v", err)
+	}
+	if _, err := d.Query("select id frm users"); !errors.Is(err, parse.ErrSyntax) {
+		t.Errorf("expected Query to return a syntax error, got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/dump"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Returns the values of the records of a query, one string per record
+func queryRecords(t *testing.T, d *db.DB, query string) []string {
+	t.Helper()
+	rows, err := d.Query(query)
+	if err != nil {
+		t.Fatalf("%s failed: %v", query, err)
+	}
+	defer rows.Close()
+
+	var records []string
+	for rows.Next() {
+		records = append(records, fmt.Sprint(rows.Row().Values()))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("%s failed: %v", query, err)
+	}
+	return records
+}
+
+func TestPartition_Range(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "partitiondb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"create table orders (id int, year int, item varchar(8)) partition by range (year) (partition old values less than (2000), partition recent values less than (2020), partition future values less than maxvalue)",
+		"insert into orders (id, year, item) values (1, 1999, 'pen')",
+		"insert into orders (id, year, item) values (2, 2005, 'ink')",
+		"insert into orders (id, year, item) values (3, 2024, 'pad')",
+		"insert into orders (id, year, item) values (4, 2005, 'cap')",
+		// Changing the key moves the record to the partition it now belongs in
+		"update orders set year = 2030 where id = 4",
+		"delete from orders where year = 1999",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	// Each partition is stored in a file of its own
+	for _, part := range []string{"old", "recent", "future"} {
+		if _, err := os.Stat(filepath.Join(dir, record.PartitionTable("orders", part)+".tbl")); err != nil {
+			t.Errorf("expected a file for partition %s: %v", part, err)
+		}
+	}
+
+	check := func(d *db.DB) {
+		t.Helper()
+		for query, want := range map[string]string{
+			"select id, year from orders":                        "[[2 2005] [3 2024] [4 2030]]",
+			"select id from orders where year = 2030":            "[[4]]",
+			"select id from orders where year = 2005":            "[[2]]",
+			"select id from orders where year = 1999":            "[]",
+			"select item from orders where id = 3":               "[[pad]]",
+			"select id from orders where year = 2024 and id = 9": "[]",
+		} {
+			if got := fmt.Sprint(queryRecords(t, d, query)); got != want {
+				t.Errorf("%s: expected %s, got %s", query, want, got)
+			}
+		}
+	}
+	check(d)
+
+	// The partitions are read back from the catalog
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	d, err = db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer d.Close()
+	check(d)
+
+	var out strings.Builder
+	if err := dump.Dump(d, &out, nil); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	want := "create table orders (id int, year int, item varchar(8)) partition by range (year) (partition old values less than (2000), partition recent values less than (2020), partition future values less than maxvalue);"
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("expected the dump to contain %q, got:\n%s", want, out.String())
+	}
+}
+
+func TestPartition_RangeQueries(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "partitiondb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table t (id int, k int) partition by range (k) (partition low values less than (10), partition mid values less than (20), partition high values less than (30))",
+		"insert into t (id, k) values (1, 5), (2, 10), (3, 15), (4, 20), (5, 29)",
+		"update t set id = id + 10 where k >= 10 and k < 20",
+		"delete from t where k between 25 and 40",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	for query, want := range map[string]string{
+		"select id from t where k < 10":              "[[1]]",
+		"select id from t where k <= 10":             "[[1] [12]]",
+		"select id from t where k > 10":              "[[13] [4]]",
+		"select id from t where k between 10 and 20": "[[12] [13] [4]]",
+		"select id from t where k > 100":             "[]",
+	} {
+		if got := fmt.Sprint(queryRecords(t, d, query)); got != want {
+			t.Errorf("%s: expected %s, got %s", query, want, got)
+		}
+	}
+}
+
+func TestPartition_Hash(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "partitiondb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Exec("create table users (id int, name varchar(8) collate nocase) partition by hash (name) partitions 4"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := d.Exec(fmt.Sprintf("insert into users (id, name) values (%d, 'user%d')", i, i)); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	if got := len(queryRecords(t, d, "select id from users")); got != 20 {
+		t.Errorf("expected 20 records, got %d", got)
+	}
+	// Names equal under the collation are routed to the same partition
+	if got := fmt.Sprint(queryRecords(t, d, "select id from users where name = 'USER7'")); got != "[[7]]" {
+		t.Errorf("expected [[7]], got %s", got)
+	}
+	if _, err := d.Exec("update users set name = 'renamed' where name = 'user7'"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select id from users where name = 'Renamed'")); got != "[[7]]" {
+		t.Errorf("expected the renamed record to be found, got %s", got)
+	}
+	// Bulk loads route each record as well
+	if _, err := d.BulkInsert("users", []string{"id", "name"}, bulkTestRows(100)); err != nil {
+		t.Fatalf("BulkInsert failed: %v", err)
+	}
+	if got := countRows(t, d, "select id from users"); got != 120 {
+		t.Errorf("expected 120 records, got %d", got)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select id from users where name = 'N42'")); got != "[[42]]" {
+		t.Errorf("expected [[42]], got %s", got)
+	}
+}
+
+func TestPartition_Errors(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "partitiondb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Exec("create table t (id int, k int) partition by range (k) (partition low values less than (10), partition high values less than (20))"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	if _, err := d.Exec("insert into t (id, k) values (1, 5)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+
+	tests := []struct {
+		stmt string
+		err  error
+	}{
+		{"insert into t (id, k) values (2, 25)", record.ErrNoPartition},
+		{"update t set k = 30 where id = 1", record.ErrNoPartition},
+		{"create index t_id on t (id)", record.ErrPartitioned},
+	}
+	for _, tt := range tests {
+		if _, err := d.Exec(tt.stmt); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.stmt, tt.err, err)
+		}
+	}
+
+	for _, stmt := range []string{
+		"create table bad (id int, name varchar(5)) partition by range (name) (partition a values less than (1))",
+		"create table bad (id int) partition by range (id) (partition a values less than (10), partition b values less than (5))",
+		"create table bad (id int) partition by range (id) (partition a values less than maxvalue, partition b values less than (5))",
+		"create table bad (id int) partition by range (other) (partition a values less than (1))",
+		"create table bad (id int) partition by hash (id) partitions 0",
+	} {
+		if _, err := d.Exec(stmt); err == nil {
+			t.Errorf("%s: expected an error", stmt)
+		}
+	}
+}
+
+func TestPartition_Prune(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	db.exec(t, "create table t (id int, k int) partition by range (k) (partition low values less than (10), partition mid values less than (20), partition high values less than maxvalue)")
+
+	tests := []struct {
+		pred     string
+		describe string
+	}{
+		{"k = 3", "table t partitions (low)"},
+		{"id = 1 and k = 12", "table t partitions (mid)"},
+		{"id = 1", "table t"},
+		{"k < 10", "table t partitions (low)"},
+		{"k <= 10", "table t partitions (low, mid)"},
+		{"k > 19", "table t partitions (high)"},
+		{"k >= 19", "table t partitions (mid, high)"},
+		{"10 <= k", "table t partitions (mid, high)"},
+		{"k between 12 and 25", "table t partitions (mid, high)"},
+		{"k between 0 and 9", "table t partitions (low)"},
+		{"k > 5 and k < 15", "table t partitions (low, mid)"},
+		{"k between 15 and 12", "table t partitions ()"},
+		{"k > 5 or k < 15", "table t"},
+	}
+	for _, tt := range tests {
+		p, err := plan.NewTablePlan(db.tx, "t", db.mdm)
+		if err != nil {
+			t.Fatalf("NewTablePlan failed: %v", err)
+		}
+		pruned := p.(*plan.TablePlan).Prune(parsePredicate(t, tt.pred))
+		if got := pruned.Describe(); got != tt.describe {
+			t.Errorf("%s: expected %q, got %q", tt.pred, tt.describe, got)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/index/planner"
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record"
+	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Opens a database holding the table t (id int, name varchar(5)),
+// with an index on id
+func openPlannerTestDB(t *testing.T) *db.DB {
+	d, err := db.Open(filepath.Join(t.TempDir(), "plannerdb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"create table t (id int, name varchar(5))",
+		"create index t_id on t (id)",
+		"insert into t (id, name) values (1, 'ann')",
+		"insert into t (id, name) values (2, 'bob')",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+	return d
+}
+
+func TestPlanner_UpdateCounts(t *testing.T) {
+	d := openPlannerTestDB(t)
+	defer d.Close()
+
+	tests := []struct {
+		stmt  string
+		count int
+	}{
+		{"update t set name = 'cid' where id = 2", 1},
+		{"update t set id = 3 where id = 2", 1},
+		{"delete from t where id = 1", 1},
+		{"delete from t where id = 1", 0},
+	}
+	for _, tt := range tests {
+		count, err := d.Exec(tt.stmt)
+		if err != nil {
+			t.Fatalf("%s failed: %v", tt.stmt, err)
+		}
+		if count != tt.count {
+			t.Errorf("%s: expected %d records, got %d", tt.stmt, tt.count, count)
+		}
+	}
+
+	rows, err := d.Query("select id, name from t")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatal("expected a record")
+	}
+	if got := fmt.Sprint(rows.Row().Values()); got != "[3 cid]" {
+		t.Errorf("unexpected record %v", got)
+	}
+	if rows.Next() {
+		t.Error("expected a single record")
+	}
+}
+
+func TestPlanner_UpdateErrors(t *testing.T) {
+	d := openPlannerTestDB(t)
+	defer d.Close()
+
+	tests := []struct {
+		stmt string
+		err  error
+	}{
+		{"create table t (id int)", metadata.ErrTableExists},
+		{"create view t as select id from t", metadata.ErrTableExists},
+		{"insert into t (id, name) values ('x', 'bob')", record.ErrFieldType},
+		{"update t set name = 3 where id = 1", record.ErrFieldType},
+		{"delete from nosuchtable where id = 1", metadata.ErrTableNotFound},
+	}
+	for _, tt := range tests {
+		count, err := d.Exec(tt.stmt)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.stmt, tt.err, err)
+		}
+		if count != 0 {
+			t.Errorf("%s: expected no records, got %d", tt.stmt, count)
+		}
+	}
+
+	// Names too long for the catalog are rejected rather than cut short
+	for _, stmt := range []string{
+		"create table averyveryverylongname (id int)",
+		"create table u (averyveryverylongname int)",
+	} {
+		if _, err := d.Exec(stmt); err == nil {
+			t.Errorf("%s: expected an error", stmt)
+		}
+	}
+}
+
+func TestPlanner_TypeCheck(t *testing.T) {
+	d := openPlannerTestDB(t)
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table u (uid int, tid int)",
+		"create view names as select name from t",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	queries := []struct {
+		query string
+		err   error
+		msg   string
+	}{
+		{"select id from t where name = 'ann'", nil, ""},
+		{"select id from t, u where id = tid", nil, ""},
+		{"select name from names where name = 'bob'", nil, ""},
+		{"select age from t", plan.ErrUnknownField, "age is not a field of t"},
+		{"select id from t where age = 1", plan.ErrUnknownField, "age is not a field of t"},
+		{"select id from names", plan.ErrUnknownField, "id is not a field of names"},
+		{"select max(age) from t", plan.ErrUnknownField, "age is not a field of t"},
+		{"select id from nosuchtable", metadata.ErrTableNotFound, ""},
+		{"select id from t where id = 'one'", plan.ErrTypeMismatch, "cannot compare id (int) with 'one' (varchar)"},
+		{"select id from t, u where name = tid", plan.ErrTypeMismatch, "cannot compare name (varchar(5)) with tid (int)"},
+		{"select name from names where name = 2", plan.ErrTypeMismatch, "cannot compare name (varchar(5)) with 2 (int)"},
+		{"select id from t where 1 = 'one'", plan.ErrTypeMismatch, ""},
+	}
+	for _, tt := range queries {
+		rows, err := d.Query(tt.query)
+		if err == nil {
+			rows.Close()
+		}
+		if !errors.Is(err, tt.err) || (err != nil && !strings.Contains(err.Error(), tt.msg)) {
+			t.Errorf("%s: expected %v %q, got %v", tt.query, tt.err, tt.msg, err)
+		}
+	}
+
+	updates := []struct {
+		stmt string
+		err  error
+	}{
+		{"update t set age = 3 where id = 1", plan.ErrUnknownField},
+		{"update t set name = 'cid' where id = 'one'", plan.ErrTypeMismatch},
+		{"delete from t where name = 1", plan.ErrTypeMismatch},
+		{"insert into t (id, age) values (3, 4)", plan.ErrUnknownField},
+		{"create view ages as select age from t", plan.ErrUnknownField},
+	}
+	for _, tt := range updates {
+		if _, err := d.Exec(tt.stmt); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.stmt, tt.err, err)
+		}
+	}
+}
+
+func TestPlanner_UpdateExpressions(t *testing.T) {
+	d := openPlannerTestDB(t)
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"update t set id = id * 10 + 1 where name = 'bob'",
+		"update t set name = case when id = 1 then upper(name) else concat(name, '!') end",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	rows, err := d.Query("select id, name from t")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var got []string
+	for rows.Next() {
+		got = append(got, fmt.Sprint(rows.Row().Values()))
+	}
+	rows.Close()
+	if want := "[[1 ANN] [21 bob!]]"; fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %v", want, got)
+	}
+
+	// The index on id finds the updated record
+	rows, err = d.Query("select name from t where id = 21")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if !rows.Next() || fmt.Sprint(rows.Row().Values()) != "[bob!]" {
+		t.Error("expected to find the updated record by its new id")
+	}
+	rows.Close()
+
+	if _, err := d.Exec("update t set id = id / 0"); err == nil {
+		t.Error("expected division by zero to fail the update")
+	}
+	if _, err := d.Exec("update t set id = age + 1"); !errors.Is(err, plan.ErrUnknownField) {
+		t.Errorf("expected ErrUnknownField, got %v", err)
+	}
+}
+
+// A database planned by the index-aware planners, in a single transaction
+type indexPlannerTestDB struct {
+	tx  *tx.Transaction
+	mdm *metadata.MetaDataManager
+	iup *planner.IndexUpdatePlanner
+}
+
+// Opens an empty database. The transaction is rolled back when the test ends.
+func openIndexPlannerTestDB(t *testing.T) *indexPlannerTestDB {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "indexplannerdb"))
+	t.Cleanup(func() { db.fm.Close() })
+
+	tx1 := db.newTx()
+	t.Cleanup(func() { tx1.Rollback() })
+	mdm, err := metadata.NewMetaDataManager(true, tx1)
+	if err != nil {
+		t.Fatalf("NewMetaDataManager failed: %v", err)
+	}
+	return &indexPlannerTestDB{tx: tx1, mdm: mdm, iup: planner.NewIndexUpdatePlanner(mdm)}
+}
+
+// Executes an update statement and returns the number of affected records
+func (db *indexPlannerTestDB) exec(t *testing.T, stmt string) int {
+	t.Helper()
+	count, err := db.tryExec(t, stmt)
+	if err != nil {
+		t.Fatalf("%s failed: %v", stmt, err)
+	}
+	return count
+}
+
+// Executes an update statement that may fail
+func (db *indexPlannerTestDB) tryExec(t *testing.T, stmt string) (int, error) {
+	t.Helper()
+	var count int
+	obj, err := parse.NewParser(stmt).UpdateCmd()
+	if err != nil {
+		t.Fatalf("%s: %v", stmt, err)
+	}
+	switch data := obj.(type) {
+	case *parse.CreateTableData:
+		count, err = db.iup.ExecuteCreateTable(data, db.tx)
+	case *parse.CreateIndexData:
+		count, err = db.iup.ExecuteCreateIndex(data, db.tx)
+	case *parse.InsertData:
+		count, err = db.iup.ExecuteInsert(data, db.tx)
+	case *parse.DeleteData:
+		count, err = db.iup.ExecuteDelete(data, db.tx)
+	case *parse.ModifyData:
+		count, err = db.iup.ExecuteModify(data, db.tx)
+	case *parse.AddColumnData:
+		count, err = db.iup.ExecuteAddColumn(data, db.tx)
+	case *parse.DropColumnData:
+		count, err = db.iup.ExecuteDropColumn(data, db.tx)
+	}
+	return count, err
+}
+
+func TestIndexUpdatePlanner_UpdatesThroughIndex(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	tx1, mdm := db.tx, db.mdm
+	exec := func(stmt string) int {
+		t.Helper()
+		return db.exec(t, stmt)
+	}
+
+	exec("create table t (id int, name varchar(5))")
+	exec("create index t_id on t (id)")
+	for i := 0; i < 50; i++ {
+		exec(fmt.Sprintf("insert into t (id, name) values (%d, 'n%d')", i%5, i))
+	}
+
+	// Each key has ten records; changing the indexed field of the records
+	// found through the index must not make the lookup skip or revisit any
+	tests := []struct {
+		stmt  string
+		count int
+	}{
+		{"update t set id = 7 where id = 3", 10},
+		{"update t set id = id + 10 where id = 7", 10},
+		{"delete from t where id = 17", 10},
+		{"delete from t where id = 1 and name = 'n11'", 1},
+		{"update t set name = 'x' where name = 'n12'", 1},
+	}
+	for _, tt := range tests {
+		if count := exec(tt.stmt); count != tt.count {
+			t.Errorf("%s: expected %d records, got %d", tt.stmt, tt.count, count)
+		}
+	}
+
+	// The index stays in step with the table
+	for id, want := range map[int]int{0: 10, 1: 9, 2: 10, 3: 0, 7: 0, 17: 0} {
+		pred := parsePredicate(t, fmt.Sprintf("id = %d", id))
+		tp, err := plan.NewTablePlan(tx1, "t", mdm)
+		if err != nil {
+			t.Fatalf("NewTablePlan failed: %v", err)
+		}
+		p := planner.MakeIndexSelect(tp, pred, mdm.GetIndexInfo("t", tx1))
+		if p == nil {
+			t.Fatalf("expected an index select for %s", pred)
+		}
+		s := p.Open()
+		count := 0
+		for s.Next() {
+			count++
+		}
+		s.Close()
+		if count != want {
+			t.Errorf("id %d: expected %d index entries, got %d", id, want, count)
+		}
+	}
+
+	tp, err := plan.NewTablePlan(tx1, "t", mdm)
+	if err != nil {
+		t.Fatalf("NewTablePlan failed: %v", err)
+	}
+	if planner.MakeIndexSelect(tp, parsePredicate(t, "name = 'x'"), mdm.GetIndexInfo("t", tx1)) != nil {
+		t.Error("expected no index select on an unindexed field")
+	}
+}
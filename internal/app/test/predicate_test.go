@@ -0,0 +1,151 @@
+package test
+
+import (
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"math"
+	"testing"
+)
+
+// A plan with given counts of distinct values per field
+type distinctPlan struct {
+	interfaces.Plan
+	distinct map[string]int
+}
+
+func (dp *distinctPlan) DistinctValues(fieldName string) int {
+	return dp.distinct[fieldName]
+}
+
+func fieldEquals(field string, val *types.Constant) *query.Predicate {
+	return query.NewPredicateWithTerm(query.NewTerm(
+		query.NewExpressionFieldName(field),
+		query.NewExpressionVal(val),
+	))
+}
+
+func TestPredicate_Reorder(t *testing.T) {
+	sch := schema.NewSchema()
+	sch.AddIntField("id")
+	sch.AddStringField("name", 100)
+	sch.AddIntField("flag")
+
+	p := &distinctPlan{
+		Plan:     plan.NewRowsPlan(sch, nil),
+		distinct: map[string]int{"id": 2, "name": 50, "flag": 1},
+	}
+
+	pred := fieldEquals("flag", types.NewConstantInt(1))
+	pred.ConjoinWith(fieldEquals("name", types.NewConstantString("x")))
+	pred.ConjoinWith(fieldEquals("id", types.NewConstantInt(3)))
+	pred.ConjoinWith(query.NewPredicateWithTerm(query.NewTerm(
+		query.NewExpressionVal(types.NewConstantInt(1)),
+		query.NewExpressionVal(types.NewConstantInt(2)),
+	)))
+
+	// The constant comparison rejects every record for nothing, the cheap
+	// id comparison comes before the long string one, and the flag, which
+	// has a single value, rejects nothing
+	expected := "1=2 AND id=3 AND name=x AND flag=1"
+	if got := pred.Reorder(p).String(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if got := pred.String(); got != "flag=1 AND name=x AND id=3 AND 1=2" {
+		t.Errorf("expected the predicate to be unchanged, got %q", got)
+	}
+}
+
+func TestSelectPlan_ReorderedPredicate(t *testing.T) {
+	sch := schema.NewSchema()
+	sch.AddIntField("id")
+	sch.AddStringField("name", 100)
+
+	var rows []map[string]*types.Constant
+	for i := 0; i < 10; i++ {
+		rows = append(rows, map[string]*types.Constant{
+			"id":   types.NewConstantInt(i % 3),
+			"name": types.NewConstantString(string(rune('a' + i%2))),
+		})
+	}
+
+	pred := fieldEquals("name", types.NewConstantString("a"))
+	pred.ConjoinWith(fieldEquals("id", types.NewConstantInt(0)))
+
+	s := plan.NewSelectPlan(plan.NewRowsPlan(sch, rows), pred).Open()
+	defer s.Close()
+
+	count := 0
+	for s.Next() {
+		if s.GetInt("id") != 0 || s.GetString("name") != "a" {
+			t.Errorf("unexpected record id=%d name=%s", s.GetInt("id"), s.GetString("name"))
+		}
+		count++
+	}
+	// Records 0 and 6
+	if count != 2 {
+		t.Errorf("expected 2 records, got %d", count)
+	}
+}
+
+func TestTerm_RangeReductionFactor(t *testing.T) {
+	sch := schema.NewSchema()
+	sch.AddIntField("id")
+	p := &distinctPlan{
+		Plan:     plan.NewRowsPlan(sch, nil),
+		distinct: map[string]int{"id": 100},
+	}
+
+	field := query.NewExpressionFieldName("id")
+	val := func(n int) *query.Expression { return query.NewExpressionVal(types.NewConstantInt(n)) }
+	tests := []struct {
+		term *query.Term
+		want int
+	}{
+		{query.NewComparisonTerm(field, query.OP_EQUALS, val(3)), 100},
+		{query.NewComparisonTerm(field, query.OP_LESS, val(3)), query.RANGE_REDUCTION_FACTOR},
+		{query.NewComparisonTerm(val(3), query.OP_GREATER_EQUAL, field), query.RANGE_REDUCTION_FACTOR},
+		{query.NewComparisonTerm(field, query.OP_NOT_EQUALS, val(3)), 1},
+		// A comparison of constants keeps every record or none
+		{query.NewComparisonTerm(val(1), query.OP_LESS, val(2)), 1},
+		{query.NewComparisonTerm(val(2), query.OP_LESS_EQUAL, val(1)), math.MaxInt},
+	}
+	for _, tt := range tests {
+		if got := tt.term.ReductionFactor(p); got != tt.want {
+			t.Errorf("%s: expected reduction factor %d, got %d", tt.term, tt.want, got)
+		}
+	}
+}
+
+func TestTerm_BooleanReductionFactor(t *testing.T) {
+	sch := schema.NewSchema()
+	sch.AddIntField("id")
+	sch.AddIntField("flag")
+	p := &distinctPlan{
+		Plan:     plan.NewRowsPlan(sch, nil),
+		distinct: map[string]int{"id": 10, "flag": 1},
+	}
+
+	idIs := func(n int) *query.Predicate { return fieldEquals("id", types.NewConstantInt(n)) }
+	both := idIs(1)
+	both.ConjoinWith(idIs(2))
+	tests := []struct {
+		term *query.Term
+		want int
+	}{
+		// Each of two alternatives keeps a tenth, so together they keep about a fifth
+		{query.NewOrTerm(idIs(1), idIs(2)), 5},
+		{query.NewOrTerm(idIs(1), fieldEquals("flag", types.NewConstantInt(1))), 1},
+		{query.NewOrTerm(both, idIs(3)), 9},
+		// Negating a term that keeps a tenth keeps nine tenths
+		{query.NewNotTerm(idIs(1)), 1},
+		{query.NewNotTerm(fieldEquals("flag", types.NewConstantInt(1))), math.MaxInt},
+	}
+	for _, tt := range tests {
+		if got := tt.term.ReductionFactor(p); got != tt.want {
+			t.Errorf("%s: expected reduction factor %d, got %d", tt.term, tt.want, got)
+		}
+	}
+}
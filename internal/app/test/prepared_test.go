@@ -0,0 +1,176 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/optimization"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParser_Parameters(t *testing.T) {
+	parser := parse.NewParser("select name from emp where id = ? and name = ?")
+	data, err := parser.Query()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if n := parser.NumParams(); n != 2 {
+		t.Errorf("expected 2 parameters, got %d", n)
+	}
+	if got := data.Pred().String(); got != "id=? AND name=?" {
+		t.Errorf("expected the placeholders in the predicate, got %q", got)
+	}
+
+	// Binding returns a copy, leaving the placeholders to be bound again
+	bound := data.Bind([]*types.Constant{types.NewConstantInt(7), types.NewConstantString("ann")})
+	if got := bound.Pred().String(); got != "id=7 AND name=ann" {
+		t.Errorf("expected the bound values, got %q", got)
+	}
+	if got := data.Pred().String(); got != "id=? AND name=?" {
+		t.Errorf("expected the statement to keep its placeholders, got %q", got)
+	}
+
+	parser = parse.NewParser("insert into emp (id, name) values (?, 'x'), (3, ?)")
+	obj, err := parser.UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	insert := obj.(*parse.InsertData).Bind([]*types.Constant{types.NewConstantInt(1), types.NewConstantNull()})
+	if got := fmt.Sprint(insert.Rows()); got != "[[1 x] [3 NULL]]" {
+		t.Errorf("expected the bound values, got %s", got)
+	}
+}
+
+func TestPrepared_Exec(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "prepareddb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Exec("create table emp (id int, name varchar(10), dept int)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+
+	insert, err := d.Prepare("insert into emp (id, name, dept) values (?, ?, ?)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if n := insert.NumInput(); n != 3 {
+		t.Errorf("expected 3 parameters, got %d", n)
+	}
+	for i, name := range []string{"ann", "o'brien", "x' or 'a'='a"} {
+		if _, err := insert.Exec(i+1, name, 10); err != nil {
+			t.Fatalf("insert of %s failed: %v", name, err)
+		}
+	}
+	if _, err := insert.Exec(4, nil, nil); err != nil {
+		t.Fatalf("insert of nulls failed: %v", err)
+	}
+
+	// The values are never read as SQL
+	query, err := d.Prepare("select id from emp where name = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	for name, want := range map[string]string{"o'brien": "[[2]]", "x' or 'a'='a": "[[3]]", "nobody": "[]"} {
+		rows, err := query.Query(name)
+		if err != nil {
+			t.Fatalf("query of %s failed: %v", name, err)
+		}
+		var got []string
+		for rows.Next() {
+			got = append(got, fmt.Sprint(rows.Row().Values()))
+		}
+		rows.Close()
+		if fmt.Sprint("[", strings.Join(got, " "), "]") != want {
+			t.Errorf("%s: expected %s, got %v", name, want, got)
+		}
+	}
+
+	update, err := d.Prepare("update emp set dept = ? where id > ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if n, err := update.Exec(20, 2); err != nil || n != 2 {
+		t.Errorf("expected 2 records updated, got %d, %v", n, err)
+	}
+	del, err := d.Prepare("delete from emp where dept = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if n, err := del.Exec(20); err != nil || n != 2 {
+		t.Errorf("expected 2 records deleted, got %d, %v", n, err)
+	}
+
+	// Statements run in a transaction are undone with it
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	txInsert, err := tx.Prepare("insert into emp (id, name, dept) values (?, 'tmp', 0)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	for i := 10; i < 13; i++ {
+		if _, err := txInsert.Exec(i); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+	tx.Rollback()
+	if got := countRows(t, d, "select id from emp"); got != 2 {
+		t.Errorf("expected 2 records after the rollback, got %d", got)
+	}
+
+	if _, err := insert.Exec(5, "bo"); !errors.Is(err, plan.ErrParameterCount) {
+		t.Errorf("expected %v, got %v", plan.ErrParameterCount, err)
+	}
+	if _, err := insert.Exec(5, 1.5, 0); err == nil {
+		t.Errorf("expected a float value to be rejected")
+	}
+	if _, err := d.Exec("delete from emp where id = ?"); !errors.Is(err, plan.ErrParameterCount) {
+		t.Errorf("expected %v running a statement with parameters directly, got %v", plan.ErrParameterCount, err)
+	}
+	if _, err := d.Prepare("create view v as select id from emp where dept = ?"); !errors.Is(err, plan.ErrParametersNotSupported) {
+		t.Errorf("expected %v, got %v", plan.ErrParametersNotSupported, err)
+	}
+	if _, err := query.Exec("ann"); err == nil {
+		t.Errorf("expected a query to be rejected by Exec")
+	}
+}
+
+func TestPrepared_Replans(t *testing.T) {
+	db := openOptimizerTestDB(t)
+	planner := plan.NewPlanner(optimization.NewHeuristicQueryPlanner(db.mdm), db.iup, db.mdm)
+
+	ps, err := planner.Prepare("select name from emp where id = ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+
+	// Each execution is planned with its values, so the index is used for all of them
+	for _, id := range []int{13, 42} {
+		p, err := planner.CreatePreparedPlanAs(ps, []*types.Constant{types.NewConstantInt(id)}, nil, db.tx)
+		if err != nil {
+			t.Fatalf("CreatePreparedPlanAs failed: %v", err)
+		}
+		if explain := plan.Explain(p); !strings.Contains(explain, "index select") {
+			t.Errorf("expected an index select, got:\n%s", explain)
+		}
+
+		s := p.Open()
+		var names []string
+		for s.Next() {
+			names = append(names, s.GetString("name"))
+		}
+		s.Close()
+		if got := strings.Join(names, " "); got != fmt.Sprintf("e%d", id) {
+			t.Errorf("expected e%d, got %s", id, got)
+		}
+	}
+}
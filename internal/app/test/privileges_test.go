@@ -0,0 +1,65 @@
+package test
+
+import (
+	"centauri/internal/app/plan"
+	"centauri/internal/app/server"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrivileges_Copy(t *testing.T) {
+	dir := t.TempDir()
+	cdb, err := server.OpenCentauriDB(filepath.Join(dir, "privdb"), 400, 8)
+	if err != nil {
+		t.Fatalf("OpenCentauriDB failed: %v", err)
+	}
+	defer cdb.Close()
+
+	admin := server.NewSession(cdb)
+	defer admin.Close()
+	csvFile := filepath.Join(dir, "in.csv")
+	if err := os.WriteFile(csvFile, []byte("1,ann\n2,bob\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []string{
+		"create table t (id int, name varchar(8))",
+		"create user alice with password 'pw'",
+		"grant select, insert on t to alice",
+		fmt.Sprintf("copy t from '%s'", csvFile),
+	} {
+		if _, err := execSession(t, admin, cmd); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+
+	// Holding the privileges on the table doesn't let a user read or
+	// write the server's files
+	s := server.NewSession(cdb)
+	defer s.Close()
+	if err := s.Authenticate("alice", "pw"); err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	outFile := filepath.Join(dir, "out.csv")
+	for _, cmd := range []string{
+		fmt.Sprintf("copy t from '%s'", csvFile),
+		fmt.Sprintf("copy (select id, name from t) to '%s'", outFile),
+	} {
+		if _, err := execSession(t, s, cmd); !errors.Is(err, plan.ErrPermissionDenied) {
+			t.Errorf("%s: expected %v, got %v", cmd, plan.ErrPermissionDenied, err)
+		}
+	}
+	if _, err := os.Stat(outFile); !os.IsNotExist(err) {
+		t.Errorf("expected %s not to be created, got %v", outFile, err)
+	}
+	if got := querySession(t, s, "select id from t"); len(got) != 2 {
+		t.Errorf("expected the refused copy to load no records, got %v", got)
+	}
+
+	// A superuser can
+	if count, err := execSession(t, admin, fmt.Sprintf("copy (select id, name from t) to '%s'", outFile)); err != nil || count != 2 {
+		t.Errorf("expected 2 records to be copied, got %d, %v", count, err)
+	}
+}
@@ -0,0 +1,179 @@
+package test
+
+import (
+	"bytes"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/server"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestProtocol_Framing(t *testing.T) {
+	var buf bytes.Buffer
+
+	messages := []struct {
+		msgType byte
+		payload []byte
+	}{
+		{server.MSG_QUERY, []byte("select a from t")},
+		{server.MSG_COMMAND_COMPLETE, server.EncodeCommandComplete(3, 42)},
+		{server.MSG_TERMINATE, []byte{}},
+	}
+
+	for _, msg := range messages {
+		if err := server.WriteMessage(&buf, msg.msgType, msg.payload); err != nil {
+			t.Fatalf("WriteMessage failed: %v", err)
+		}
+	}
+
+	for _, want := range messages {
+		msgType, payload, err := server.ReadMessage(&buf)
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %v", err)
+		}
+		if msgType != want.msgType {
+			t.Errorf("expected message type %q, got %q", want.msgType, msgType)
+		}
+		if !bytes.Equal(payload, want.payload) {
+			t.Errorf("expected payload %v, got %v", want.payload, payload)
+		}
+	}
+
+	if _, _, err := server.ReadMessage(&buf); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF after last message, got %v", err)
+	}
+}
+
+func TestProtocol_Rows(t *testing.T) {
+	cols := []server.ColumnDesc{
+		{Name: "id", Type: schema.INTEGER},
+		{Name: "name", Type: schema.VARCHAR},
+	}
+
+	gotCols, err := server.DecodeRowDescription(server.EncodeRowDescription(cols))
+	if err != nil {
+		t.Fatalf("DecodeRowDescription failed: %v", err)
+	}
+	if !reflect.DeepEqual(gotCols, cols) {
+		t.Errorf("expected columns %v, got %v", cols, gotCols)
+	}
+
+	values := []any{-42, "centauri", nil, true, false}
+	row, err := server.EncodeDataRow(values)
+	if err != nil {
+		t.Fatalf("EncodeDataRow failed: %v", err)
+	}
+
+	gotValues, err := server.DecodeDataRow(row)
+	if err != nil {
+		t.Fatalf("DecodeDataRow failed: %v", err)
+	}
+	if !reflect.DeepEqual(gotValues, values) {
+		t.Errorf("expected values %v, got %v", values, gotValues)
+	}
+
+	// A truncated row must be reported rather than decoded partially
+	if _, err := server.DecodeDataRow(row[:len(row)-2]); err == nil {
+		t.Error("expected error for truncated data row")
+	}
+
+	if _, err := server.EncodeDataRow([]any{uint8(1)}); err == nil {
+		t.Error("expected error for unsupported value type")
+	}
+}
+
+func TestProtocol_Auth(t *testing.T) {
+	userName, password, err := server.DecodeAuth(server.EncodeAuth("alice", "s3cret"))
+	if err != nil {
+		t.Fatalf("DecodeAuth failed: %v", err)
+	}
+	if userName != "alice" || password != "s3cret" {
+		t.Errorf("expected alice/s3cret, got %s/%s", userName, password)
+	}
+
+	if _, _, err := server.DecodeAuth(server.EncodeAuth("alice", "")[:6]); err == nil {
+		t.Error("expected error for truncated auth message")
+	}
+}
+
+func TestProtocol_Cancel(t *testing.T) {
+	sessionID, cancelKey, err := server.DecodeCancel(server.EncodeCancel(7, 123456))
+	if err != nil {
+		t.Fatalf("DecodeCancel failed: %v", err)
+	}
+	if sessionID != 7 || cancelKey != 123456 {
+		t.Errorf("expected 7/123456, got %d/%d", sessionID, cancelKey)
+	}
+}
+
+func TestProtocol_Cursor(t *testing.T) {
+	name, cmd, err := server.DecodeOpenCursor(server.EncodeOpenCursor("c1", "select a from t"))
+	if err != nil {
+		t.Fatalf("DecodeOpenCursor failed: %v", err)
+	}
+	if name != "c1" || cmd != "select a from t" {
+		t.Errorf("expected c1/%q, got %s/%q", "select a from t", name, cmd)
+	}
+
+	name, count, err := server.DecodeFetch(server.EncodeFetch("c1", 100))
+	if err != nil {
+		t.Fatalf("DecodeFetch failed: %v", err)
+	}
+	if name != "c1" || count != 100 {
+		t.Errorf("expected c1/100, got %s/%d", name, count)
+	}
+
+	if name, err := server.DecodeCloseCursor(server.EncodeCloseCursor("c1")); err != nil || name != "c1" {
+		t.Errorf("expected c1, got %s (%v)", name, err)
+	}
+}
+
+func TestProtocol_Metadata(t *testing.T) {
+	kind, arg, err := server.DecodeMetadataRequest(server.EncodeMetadataRequest(server.METADATA_DESCRIBE_TABLE, "users"))
+	if err != nil {
+		t.Fatalf("DecodeMetadataRequest failed: %v", err)
+	}
+	if kind != server.METADATA_DESCRIBE_TABLE || arg != "users" {
+		t.Errorf("expected describe users, got %d %s", kind, arg)
+	}
+
+	version := server.VersionInfo{Server: "1.2.3", Catalog: 4, Protocol: 1}
+	if got, err := server.DecodeVersionInfo(server.EncodeVersionInfo(version)); err != nil || got != version {
+		t.Errorf("expected %+v, got %+v (%v)", version, got, err)
+	}
+
+	tables := []server.TableInfo{{Name: "users", Kind: server.KIND_TABLE}, {Name: "adults", Kind: server.KIND_VIEW}}
+	gotTables, err := server.DecodeTableList(server.EncodeTableList(tables))
+	if err != nil || !reflect.DeepEqual(gotTables, tables) {
+		t.Errorf("expected %+v, got %+v (%v)", tables, gotTables, err)
+	}
+
+	desc := server.TableDesc{
+		Name: "users",
+		Kind: server.KIND_TABLE,
+		Columns: []server.ColumnInfo{
+			{Name: "id", Type: schema.INTEGER, NotNull: true},
+			{Name: "name", Type: schema.VARCHAR, Length: 20, Collation: "nocase", Default: "'none'"},
+		},
+		Indexes:     []server.IndexDesc{{Name: "users_id", Fields: []string{"id"}}},
+		Constraints: []server.ConstraintDesc{{Name: "users_pk", Kind: "primary key", Fields: []string{"id"}}},
+	}
+	gotDesc, err := server.DecodeTableDesc(server.EncodeTableDesc(desc))
+	if err != nil {
+		t.Fatalf("DecodeTableDesc failed: %v", err)
+	}
+	if !reflect.DeepEqual(gotDesc, desc) {
+		t.Errorf("expected %+v, got %+v", desc, gotDesc)
+	}
+}
+
+func TestProtocol_MessageTooLarge(t *testing.T) {
+	header := []byte{server.MSG_QUERY, 0xff, 0xff, 0xff, 0xff}
+
+	_, _, err := server.ReadMessage(bytes.NewReader(header))
+	if !errors.Is(err, server.ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"centauri/internal/app/log"
+	"centauri/internal/app/server"
+	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Returns the number of records in the log
+func logRecords(t *testing.T, lm *log.LogManager) int {
+	t.Helper()
+	iter, err := lm.Iterator()
+	if err != nil {
+		t.Fatalf("Iterator failed: %v", err)
+	}
+	count := 0
+	for iter.HasNext() {
+		if _, err := iter.Next(); err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		count++
+	}
+	return count
+}
+
+func TestReadOnlyTransaction(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "readonlydb"))
+	defer db.fm.Close()
+
+	setup := db.newTx()
+	block, err := setup.Append("readonlytest")
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	setup.Pin(&block)
+	if err := setup.SetInt(block, 0, 7, true); err != nil {
+		t.Fatalf("SetInt failed: %v", err)
+	}
+	setup.Commit()
+
+	records := logRecords(t, db.lm)
+	ro := tx.NewReadOnlyTransactionWithIsolation(db.fm, db.lm, db.bm, tx.READ_COMMITTED)
+	if !ro.ReadOnly() || ro.IsolationLevel() != tx.READ_COMMITTED {
+		t.Errorf("expected a read-only transaction at read committed, got %v at %v", ro.ReadOnly(), ro.IsolationLevel())
+	}
+	ro.Pin(&block)
+	if n, err := ro.GetInt(block, 0); err != nil || n != 7 {
+		t.Errorf("expected 7, got %d, %v", n, err)
+	}
+
+	for name, write := range map[string]func() error{
+		"SetInt":    func() error { return ro.SetInt(block, 0, 8, true) },
+		"SetString": func() error { return ro.SetString(block, 20, "x", false) },
+		"Append":    func() error { _, err := ro.Append("readonlytest"); return err },
+	} {
+		if err := lockError(write); !errors.Is(err, tx.ErrReadOnlyTransaction) {
+			t.Errorf("%s: expected %v, got %v", name, tx.ErrReadOnlyTransaction, err)
+		}
+	}
+
+	// The writes took no locks that would keep a writer waiting
+	writer := db.newTx()
+	writer.SetLockTimeout(100 * time.Millisecond)
+	writer.Pin(&block)
+	if err := lockError(func() error { return writer.SetInt(block, 0, 9, true) }); err != nil {
+		t.Errorf("expected the writer not to wait, got %v", err)
+	}
+	writer.Rollback()
+
+	// Temp files may be written, they belong to the transaction alone
+	temp, err := ro.Append("temptest.tbl")
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	ro.Pin(&temp)
+	if err := ro.SetInt(temp, 0, 1, false); err != nil {
+		t.Errorf("SetInt on a temp file failed: %v", err)
+	}
+	ro.Commit()
+
+	// Only the writer's records were logged, not even START or COMMIT ones of the reader
+	if got := logRecords(t, db.lm) - records; got != 3 {
+		t.Errorf("expected the 3 records of the writer, got %d", got)
+	}
+}
+
+func TestReadOnlyTransaction_Queries(t *testing.T) {
+	cdb, err := server.OpenCentauriDB(filepath.Join(t.TempDir(), "readonlydb"), 400, 8)
+	if err != nil {
+		t.Fatalf("OpenCentauriDB failed: %v", err)
+	}
+	defer cdb.Close()
+
+	s := server.NewSession(cdb)
+	defer s.Close()
+	for _, cmd := range []string{
+		"create table t (id int, name varchar(10))",
+		"create table empty (id int)",
+		"insert into t (id, name) values (3, 'c'), (1, 'a'), (2, 'b')",
+	} {
+		if _, err := execSession(t, s, cmd); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+
+	ro, err := cdb.BeginReadOnlyTx()
+	if err != nil {
+		t.Fatalf("BeginReadOnlyTx failed: %v", err)
+	}
+	defer ro.Commit()
+
+	// Reading returns what it would in any transaction, sorting through
+	// temp tables, and an empty table isn't given a block
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{"select id, name from t order by name desc", "[3 c 2 b 1 a]"},
+		{"select id from empty", "[]"},
+	} {
+		p, err := cdb.Planner().CreateQueryPlan(tc.query, ro)
+		if err != nil {
+			t.Fatalf("%s: CreateQueryPlan failed: %v", tc.query, err)
+		}
+		var values []string
+		scan := p.Open()
+		for scan.Next() {
+			for _, field := range p.Schema().Fields() {
+				values = append(values, scan.GetVal(field).String())
+			}
+		}
+		scan.Close()
+		if got := fmt.Sprint(values); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.query, tc.want, got)
+		}
+	}
+	if size, err := ro.Size("empty.tbl"); err != nil || size != 0 {
+		t.Errorf("expected the empty table to have no blocks, got %d, %v", size, err)
+	}
+
+	err = lockError(func() error {
+		_, err := cdb.Planner().ExecuteUpdate("insert into t (id, name) values (4, 'd')", ro)
+		return err
+	})
+	if !errors.Is(err, tx.ErrReadOnlyTransaction) {
+		t.Errorf("expected %v, got %v", tx.ErrReadOnlyTransaction, err)
+	}
+}
@@ -0,0 +1,240 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/file"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func nameSchema() *schema.Schema {
+	sch := schema.NewSchema()
+	sch.AddIntField("id")
+	sch.AddStringField("name", 100)
+	return sch
+}
+
+// Inserts count records and returns their RIDs
+func insertNames(ts *record.TableScan, count int) []*types.RID {
+	var rids []*types.RID
+	for i := 0; i < count; i++ {
+		ts.Insert()
+		ts.SetInt("id", i)
+		ts.SetString("name", fmt.Sprintf("n%d", i))
+		rid, _ := ts.GetRID()
+		rids = append(rids, rid)
+	}
+	return rids
+}
+
+// Calls f with the record page holding the record
+func atRID(tx *tx.Transaction, filename string, layout *record.Layout, rid *types.RID, f func(rp *record.RecordPage, slot int)) {
+	rp := record.NewRecordPage(tx, file.NewBlockID(filename, rid.BlockNumber()), layout)
+	defer tx.Unpin(rp.Block())
+	f(rp, rid.Slot())
+}
+
+func TestRecordPage_VariableLayout(t *testing.T) {
+	fixed := record.NewLayout(nameSchema())
+	variable := record.NewVariableLayout(nameSchema())
+
+	if !variable.Variable() || fixed.Variable() {
+		t.Fatalf("unexpected formats: fixed %t, variable %t", fixed.Variable(), variable.Variable())
+	}
+	if variable.SlotSize() >= fixed.SlotSize() {
+		t.Errorf("expected the variable slot (%d bytes) to be smaller than the fixed one (%d bytes)",
+			variable.SlotSize(), fixed.SlotSize())
+	}
+
+	options, err := record.ParseStorageOptions(map[string]string{"format": "fixed"})
+	if err != nil || options.Format != record.FORMAT_FIXED {
+		t.Errorf("expected the fixed format, got %d (%v)", options.Format, err)
+	}
+	if _, err := record.ParseStorageOptions(map[string]string{"format": "packed"}); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+func TestRecordPage_VariableStrings(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "recorddb"))
+	tx := db.newTx()
+
+	ts := record.NewTableScan(tx, "variable", record.NewVariableLayout(nameSchema()))
+	rids := insertNames(ts, 60)
+
+	fixed := record.NewTableScan(tx, "fixed", record.NewLayout(nameSchema()))
+	insertNames(fixed, 60)
+	fixed.Close()
+
+	variableBlocks, _ := tx.Size("variable.tbl")
+	fixedBlocks, _ := tx.Size("fixed.tbl")
+	if variableBlocks >= fixedBlocks {
+		t.Errorf("expected short strings to take fewer blocks, got %d variable and %d fixed", variableBlocks, fixedBlocks)
+	}
+
+	ts.Close()
+
+	// Grow, shrink and empty some of the strings
+	layout := record.NewVariableLayout(nameSchema())
+	long := strings.Repeat("x", 100)
+	updates := map[int]string{3: long, 4: "", 5: "m"}
+	for i, val := range updates {
+		atRID(tx, "variable.tbl", layout, rids[i], func(rp *record.RecordPage, slot int) {
+			rp.SetString(slot, "name", val)
+		})
+	}
+
+	for i, rid := range rids {
+		want, ok := updates[i]
+		if !ok {
+			want = fmt.Sprintf("n%d", i)
+		}
+		atRID(tx, "variable.tbl", layout, rid, func(rp *record.RecordPage, slot int) {
+			if got := rp.GetString(slot, "name"); got != want {
+				t.Errorf("record %d: expected %q, got %q", i, want, got)
+			}
+			if got := rp.GetInt(slot, "id"); got != i {
+				t.Errorf("record %d: expected id %d, got %d", i, i, got)
+			}
+		})
+	}
+	tx.Commit()
+
+	// Rolled back strings are gone. The last block still has room for them.
+	last := rids[len(rids)-1]
+	tx = db.newTx()
+	atRID(tx, "variable.tbl", layout, last, func(rp *record.RecordPage, slot int) {
+		rp.SetString(slot, "name", long)
+	})
+	tx.Rollback()
+
+	tx = db.newTx()
+	atRID(tx, "variable.tbl", layout, last, func(rp *record.RecordPage, slot int) {
+		if got := rp.GetString(slot, "name"); got != fmt.Sprintf("n%d", len(rids)-1) {
+			t.Errorf("expected the rolled back update to be undone, got %q", got)
+		}
+	})
+	tx.Commit()
+}
+
+func TestRecordPage_RecordTooLarge(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "recorddb"))
+	tx := db.newTx()
+	defer tx.Commit()
+
+	sch := schema.NewSchema()
+	sch.AddStringField("a", 300)
+	sch.AddStringField("b", 300)
+	ts := record.NewTableScan(tx, "large", record.NewVariableLayout(sch))
+	defer ts.Close()
+
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, record.ErrRecordTooLarge) {
+			t.Errorf("expected ErrRecordTooLarge, got %v", err)
+		}
+	}()
+	ts.Insert()
+}
+
+func TestTableScan_MoveToRID(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "recorddb"))
+	tx := db.newTx()
+	defer tx.Commit()
+
+	ts := record.NewTableScan(tx, "moves", record.NewLayout(nameSchema()))
+	defer ts.Close()
+	rids := insertNames(ts, 20)
+	if rids[0].BlockNumber() == rids[len(rids)-1].BlockNumber() {
+		t.Fatal("expected the records to span several blocks")
+	}
+
+	// Jump back and forth between blocks, away from the current record
+	for _, i := range []int{0, 19, 7, 7, 12, 1} {
+		ts.MoveToRID(rids[i])
+		if got := ts.GetInt("id"); got != i {
+			t.Errorf("expected record %d, got %d", i, got)
+		}
+		if rid, _ := ts.GetRID(); !rid.Equals(rids[i]) {
+			t.Errorf("expected to be at %v, got %v", rids[i], rid)
+		}
+	}
+
+	// The scan continues from the record it moved to
+	ts.MoveToRID(rids[3])
+	if !ts.Next() || ts.GetInt("id") != 4 {
+		t.Errorf("expected the next record to be 4")
+	}
+}
+
+func TestRecordPage_GrowStrings(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "growdb")
+	d, err := db.Open(dir, &db.Options{BlockSize: 400, BufferSize: 8})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	exec := func(sql string) {
+		t.Helper()
+		if _, err := d.Exec(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+	exec("create table t (id int, name varchar(100))")
+	const rows = 30
+	for i := 0; i < rows; i++ {
+		exec(fmt.Sprintf("insert into t (id, name) values (%d, 'n%d')", i, i))
+	}
+
+	// Every row of the full blocks grows to the declared length
+	long := func(i int) string { return fmt.Sprintf("%03d%s", i, strings.Repeat("x", 97)) }
+	for i := 0; i < rows; i++ {
+		exec(fmt.Sprintf("update t set name = '%s' where id = %d", long(i), i))
+	}
+	check := func(name func(i int) string) {
+		t.Helper()
+		got := queryRecords(t, d, "select id, name from t order by id")
+		if len(got) != rows {
+			t.Fatalf("expected %d rows, got %d", rows, len(got))
+		}
+		for i, record := range got {
+			if want := fmt.Sprint([]any{i, name(i)}); record != want {
+				t.Errorf("row %d: expected %s, got %s", i, want, record)
+			}
+		}
+	}
+	check(long)
+	if _, err := os.Stat(filepath.Join(dir, record.OverflowFile("t"))); err != nil {
+		t.Errorf("expected the strings that didn't fit to be stored in overflow blocks: %v", err)
+	}
+
+	// A rolled back change of the grown strings is undone
+	tx1, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx1.Exec("update t set name = 'short'"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	tx1.Rollback()
+	check(long)
+
+	// The strings shrink and grow again, reusing the space of the old values
+	exec("update t set name = 's'")
+	exec(fmt.Sprintf("update t set name = '%s'", strings.Repeat("y", 100)))
+	check(func(int) string { return strings.Repeat("y", 100) })
+
+	exec("delete from t")
+	if got := queryRecords(t, d, "select id from t"); len(got) != 0 {
+		t.Errorf("expected no rows, got %v", got)
+	}
+}
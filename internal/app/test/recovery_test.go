@@ -0,0 +1,547 @@
+package test
+
+import (
+	"centauri/internal/app/buffer"
+	"centauri/internal/app/file"
+	"centauri/internal/app/log"
+	"centauri/internal/app/tx"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type recoveryTestDB struct {
+	fm *file.FileManager
+	lm *log.LogManager
+	bm *buffer.BufferManager
+}
+
+func openRecoveryTestDB(t *testing.T, dir string) *recoveryTestDB {
+	fm, err := file.NewFileManager(dir, 400)
+	if err != nil {
+		t.Fatalf("Failed to create file manager: %v", err)
+	}
+
+	lm, err := log.NewLogManager(fm, "recoverylog")
+	if err != nil {
+		t.Fatalf("Failed to create log manager: %v", err)
+	}
+
+	return &recoveryTestDB{fm: fm, lm: lm, bm: buffer.NewBufferManager(fm, lm, 8)}
+}
+
+func (db *recoveryTestDB) newTx() *tx.Transaction {
+	return tx.NewTransaction(db.fm, db.lm, db.bm)
+}
+
+func TestRecovery_UndoesUnfinishedTransactions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "recoverydb")
+	db := openRecoveryTestDB(t, dir)
+
+	tx1 := db.newTx()
+	block, err := tx1.Append("recovery.tbl")
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	tx1.Pin(&block)
+	if err := tx1.SetInt(block, 0, 42, true); err != nil {
+		t.Fatalf("SetInt failed: %v", err)
+	}
+	if err := tx1.SetString(block, 8, "committed", true); err != nil {
+		t.Fatalf("SetString failed: %v", err)
+	}
+	tx1.Commit()
+
+	// Modify the block without committing, and force the
+	// changes to disk as if the buffer had been replaced
+	tx2 := db.newTx()
+	tx2.Pin(&block)
+	tx2.SetInt(block, 0, 7, true)
+	tx2.SetString(block, 8, "lost", true)
+	db.bm.FlushAllBuffers()
+	db.fm.Close()
+
+	// Reopen and recover
+	db = openRecoveryTestDB(t, dir)
+	recoveryTx := db.newTx()
+	if err := recoveryTx.Recover(); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	recoveryTx.Commit()
+
+	tx3 := db.newTx()
+	tx3.Pin(&block)
+	if val, _ := tx3.GetInt(block, 0); val != 42 {
+		t.Errorf("Expected 42 after recovery, got %d", val)
+	}
+	if val, _ := tx3.GetString(block, 8); val != "committed" {
+		t.Errorf("Expected %q after recovery, got %q", "committed", val)
+	}
+	tx3.Commit()
+	db.fm.Close()
+}
+
+func TestRecovery_Rollback(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "recoverydb"))
+	defer db.fm.Close()
+
+	tx1 := db.newTx()
+	block, _ := tx1.Append("rollback.tbl")
+	tx1.Pin(&block)
+	tx1.SetInt(block, 0, 1, true)
+	tx1.Commit()
+
+	tx2 := db.newTx()
+	tx2.Pin(&block)
+	tx2.SetInt(block, 0, 2, true)
+	tx2.Rollback()
+
+	tx3 := db.newTx()
+	tx3.Pin(&block)
+	if val, _ := tx3.GetInt(block, 0); val != 1 {
+		t.Errorf("Expected 1 after rollback, got %d", val)
+	}
+	tx3.Commit()
+}
+
+func TestRecovery_SetLong(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "recoverydb")
+	db := openRecoveryTestDB(t, dir)
+
+	tx1 := db.newTx()
+	block, _ := tx1.Append("long.tbl")
+	tx1.Pin(&block)
+	tx1.SetLong(block, 0, 1<<40, true)
+	tx1.SetLong(block, 8, -5, true)
+	tx1.Commit()
+
+	// A rollback restores all 64 bits
+	tx2 := db.newTx()
+	tx2.Pin(&block)
+	tx2.SetLong(block, 0, 7, true)
+	tx2.Rollback()
+
+	// So does recovery of a transaction that didn't finish
+	tx3 := db.newTx()
+	tx3.Pin(&block)
+	tx3.SetLong(block, 8, 1<<50, true)
+	db.bm.FlushAllBuffers()
+	db.fm.Close()
+
+	db = openRecoveryTestDB(t, dir)
+	defer db.fm.Close()
+	recoveryTx := db.newTx()
+	if err := recoveryTx.Recover(); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	recoveryTx.Commit()
+
+	tx4 := db.newTx()
+	tx4.Pin(&block)
+	if val, _ := tx4.GetLong(block, 0); val != 1<<40 {
+		t.Errorf("Expected %d after rollback, got %d", int64(1<<40), val)
+	}
+	if val, _ := tx4.GetLong(block, 8); val != -5 {
+		t.Errorf("Expected -5 after recovery, got %d", val)
+	}
+	tx4.Commit()
+}
+
+func TestRecovery_OnFinish(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "recoverydb"))
+	defer db.fm.Close()
+
+	calls := 0
+	tx1 := db.newTx()
+	tx1.OnFinish(func() { calls++ })
+	tx1.Commit()
+
+	if calls != 1 {
+		t.Errorf("Expected OnFinish to run once, ran %d times", calls)
+	}
+}
+
+func TestRecovery_Checkpoint(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "recoverydb"))
+	defer db.fm.Close()
+
+	tx1 := db.newTx()
+	tx1.Commit()
+
+	if err := tx.Checkpoint(db.lm, db.bm); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+
+	iter, err := db.lm.Iterator()
+	if err != nil {
+		t.Fatalf("Iterator failed: %v", err)
+	}
+	rec, _ := iter.Next()
+	if op := tx.CreateLogRecord(rec).Op(); op != tx.CHECKPOINT {
+		t.Errorf("Expected the last log record to be a checkpoint, got %v", op)
+	}
+}
+
+func TestRecovery_LogApplier(t *testing.T) {
+	primary := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "primary"))
+	defer primary.fm.Close()
+	standby := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "standby"))
+	defer standby.fm.Close()
+
+	tx1 := primary.newTx()
+	block, _ := tx1.Append("shipped.tbl")
+	tx1.Pin(&block)
+	tx1.SetInt(block, 0, 42, true)
+	tx1.SetString(block, 8, "committed", true)
+	tx1.Commit()
+
+	tx2 := primary.newTx()
+	tx2.Pin(&block)
+	tx2.SetInt(block, 0, 7, true)
+	tx2.Rollback()
+
+	// Still running, so its change must not be applied yet
+	tx3 := primary.newTx()
+	tx3.Pin(&block)
+	tx3.SetString(block, 8, "pending", true)
+	primary.lm.Flush(0)
+
+	applier := tx.NewLogApplier(log.NewLogReader(primary.fm, "recoverylog", log.LogPosition{}))
+	applied, err := applier.Apply(standby.newTx)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if applied != 1 {
+		t.Errorf("Expected 1 transaction to be applied, got %d", applied)
+	}
+
+	check := func(wantInt int32, wantString string) {
+		t.Helper()
+		rtx := standby.newTx()
+		rtx.Pin(&block)
+		if val, _ := rtx.GetInt(block, 0); val != wantInt {
+			t.Errorf("Expected %d on the standby, got %d", wantInt, val)
+		}
+		if val, _ := rtx.GetString(block, 8); val != wantString {
+			t.Errorf("Expected %q on the standby, got %q", wantString, val)
+		}
+		rtx.Commit()
+	}
+	check(42, "committed")
+
+	tx3.Commit()
+	if applied, err = applier.Apply(standby.newTx); err != nil || applied != 1 {
+		t.Fatalf("Expected the second Apply to apply 1 transaction, got %d, %v", applied, err)
+	}
+	check(42, "pending")
+}
+
+func TestRecovery_Replay(t *testing.T) {
+	primary := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "primary"))
+	defer primary.fm.Close()
+
+	tx1 := primary.newTx()
+	block, _ := tx1.Append("replay.tbl")
+	tx1.Pin(&block)
+	tx1.SetInt(block, 0, 1, true)
+	tx1.SetString(block, 8, "one", true)
+	tx1.Commit()
+	if err := tx.Checkpoint(primary.lm, primary.bm); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	fromLSN, err := tx.LastCheckpointLSN(primary.lm)
+	if err != nil || fromLSN == 0 {
+		t.Fatalf("Expected the LSN of the checkpoint, got %d, %v", fromLSN, err)
+	}
+
+	// The file is copied with a change that is rolled back afterwards
+	tx2 := primary.newTx()
+	tx2.Pin(&block)
+	tx2.SetInt(block, 0, 2, true)
+	primary.bm.FlushAllBuffers()
+
+	copyDir := filepath.Join(t.TempDir(), "copy")
+	if err := os.MkdirAll(copyDir, 0755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	copyTo := func(fm *file.FileManager, name string) {
+		t.Helper()
+		out, err := os.Create(filepath.Join(copyDir, name))
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		defer out.Close()
+		if err := fm.CopyFile(name, out); err != nil {
+			t.Fatalf("CopyFile failed: %v", err)
+		}
+	}
+	copyTo(primary.fm, "replay.tbl")
+	tx2.Rollback()
+
+	// Changes made after the copy, one to a block the copy doesn't have
+	tx3 := primary.newTx()
+	tx3.Pin(&block)
+	tx3.SetInt(block, 4, 5, true)
+	block2, _ := tx3.Append("replay.tbl")
+	tx3.Pin(&block2)
+	tx3.SetInt(block2, 0, 9, true)
+	tx3.Commit()
+
+	// Still running when the log is copied
+	tx4 := primary.newTx()
+	tx4.Pin(&block)
+	tx4.SetString(block, 8, "open", true)
+	primary.bm.FlushAllBuffers()
+	copyTo(primary.fm, "recoverylog")
+
+	copied := openRecoveryTestDB(t, copyDir)
+	defer copied.fm.Close()
+	rtx := copied.newTx()
+	if err := rtx.Replay(fromLSN); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if err := rtx.Recover(); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	rtx.Commit()
+
+	check := copied.newTx()
+	defer check.Commit()
+	check.Pin(&block)
+	check.Pin(&block2)
+	if val, _ := check.GetInt(block, 0); val != 1 {
+		t.Errorf("Expected the rolled back change to be undone, got %d", val)
+	}
+	if val, _ := check.GetInt(block, 4); val != 5 {
+		t.Errorf("Expected 5, got %d", val)
+	}
+	if val, _ := check.GetInt(block2, 0); val != 9 {
+		t.Errorf("Expected 9 in the appended block, got %d", val)
+	}
+	if val, _ := check.GetString(block, 8); val != "one" {
+		t.Errorf("Expected the unfinished change to be undone, got %q", val)
+	}
+}
+
+func TestRecovery_RedoesCommittedTransactions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "recoverydb")
+	db := openRecoveryTestDB(t, dir)
+
+	tx1 := db.newTx()
+	block, _ := tx1.Append("redo.tbl")
+	tx1.Pin(&block)
+	tx1.SetInt(block, 0, 42, true)
+	tx1.SetString(block, 8, "durable", true)
+	tx1.Commit()
+
+	tx2 := db.newTx()
+	tx2.Pin(&block)
+	tx2.SetInt(block, 0, 7, true)
+
+	// Crash: the log reached the disk, the buffers didn't
+	db.fm.Close()
+	db = openRecoveryTestDB(t, dir)
+	defer db.fm.Close()
+
+	p := file.NewPage(db.fm.BlockSize())
+	db.fm.Read(&block, p)
+	if val := p.GetInt(0); val != 0 {
+		t.Fatalf("Expected the committed change not to be on disk yet, got %d", val)
+	}
+
+	recoveryTx := db.newTx()
+	if err := recoveryTx.Recover(); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	recoveryTx.Commit()
+
+	tx3 := db.newTx()
+	defer tx3.Commit()
+	tx3.Pin(&block)
+	if val, _ := tx3.GetInt(block, 0); val != 42 {
+		t.Errorf("Expected 42 after recovery, got %d", val)
+	}
+	if val, _ := tx3.GetString(block, 8); val != "durable" {
+		t.Errorf("Expected %q after recovery, got %q", "durable", val)
+	}
+}
+
+func TestRecovery_UnloggedChangesAreWritten(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "recoverydb")
+	db := openRecoveryTestDB(t, dir)
+
+	tx1 := db.newTx()
+	block, _ := tx1.Append("pagewrite.tbl")
+	tx1.Pin(&block)
+	tx1.SetInt(block, 0, 1, true)
+	tx1.Commit()
+
+	// Redo can't repeat the unlogged change, so the commit writes
+	// the block, and redo mustn't write the older change over it
+	tx2 := db.newTx()
+	tx2.Pin(&block)
+	tx2.SetInt(block, 0, 99, false)
+	tx2.Commit()
+
+	db.fm.Close()
+	db = openRecoveryTestDB(t, dir)
+	defer db.fm.Close()
+
+	recoveryTx := db.newTx()
+	if err := recoveryTx.Recover(); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	recoveryTx.Commit()
+
+	tx3 := db.newTx()
+	defer tx3.Commit()
+	tx3.Pin(&block)
+	if val, _ := tx3.GetInt(block, 0); val != 99 {
+		t.Errorf("Expected the unlogged change to survive recovery, got %d", val)
+	}
+}
+
+func TestRecovery_CorruptLog(t *testing.T) {
+	// Each case damages the log block written last, given the
+	// position of its newest record and of the one before it
+	tests := []struct {
+		name    string
+		damage  func(buf []byte, newest, older int)
+		want    int32 // value of tx1 after recovery
+		wantErr error
+	}{
+		// A torn write damages the commit record, which ends the log
+		{"torn record", func(buf []byte, newest, older int) { buf[newest+4] ^= 0xff }, 0, nil},
+		{"torn size", func(buf []byte, newest, older int) { buf[newest+1] ^= 0x7f }, 0, nil},
+		// The intact records are found without the boundary
+		{"torn boundary", func(buf []byte, newest, older int) { buf[0] ^= 0x7f }, 42, nil},
+		// Intact records after a damaged one can't come from a torn write
+		{"damaged older record", func(buf []byte, newest, older int) { buf[older+4] ^= 0xff }, 0, log.ErrCorruptLogRecord},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "recoverydb")
+			db := openRecoveryTestDB(t, dir)
+
+			tx1 := db.newTx()
+			block, _ := tx1.Append("corrupt.tbl")
+			tx1.Pin(&block)
+			tx1.SetInt(block, 0, 42, true)
+			tx1.Commit()
+			db.fm.Close()
+
+			fm, _ := file.NewFileManager(dir, 400)
+			logBlock := file.NewBlockID("recoverylog", 0)
+			p := file.NewPage(fm.BlockSize())
+			fm.Read(logBlock, p)
+			newest := int(p.GetInt(0))
+			older := newest + int(uint32(p.GetInt(newest))&^log.CHECKSUM_FLAG) + log.RECORD_OVERHEAD
+			tt.damage(p.Contents(), newest, older)
+			fm.Write(logBlock, p)
+			fm.Close()
+
+			db = openRecoveryTestDB(t, dir)
+			defer db.fm.Close()
+			recoveryTx := db.newTx()
+			err := recoveryTx.Recover()
+			if !errors.Is(err, tt.wantErr) || (err != nil && tt.wantErr == nil) {
+				t.Fatalf("Expected Recover to return %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				recoveryTx.Rollback()
+				return
+			}
+			recoveryTx.Commit()
+
+			// Without its commit record tx1 is undone, and the
+			// log can be appended to and read again
+			tx2 := db.newTx()
+			tx2.Pin(&block)
+			if val, _ := tx2.GetInt(block, 0); val != tt.want {
+				t.Errorf("Expected %d after recovery, got %d", tt.want, val)
+			}
+			tx2.SetInt(block, 0, 7, true)
+			tx2.Commit()
+			tx3 := db.newTx()
+			if err := tx3.Recover(); err != nil {
+				t.Errorf("Expected the log to be readable after recovery, got %v", err)
+			}
+			tx3.Commit()
+		})
+	}
+}
+
+func TestRecovery_LogRecords(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "recoverydb"))
+	defer db.fm.Close()
+
+	tx1 := db.newTx()
+	block, _ := tx1.Append("records.tbl")
+	tx1.Pin(&block)
+	tx1.SetInt(block, 0, 42, true)
+	tx1.SetLong(block, 4, 1<<40, true)
+	tx1.SetString(block, 12, "hello", true)
+	tx1.Commit()
+
+	// The records of tx1, newest first
+	iter, _ := db.lm.Iterator()
+	var records []tx.LogRecord
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		record := tx.CreateLogRecord(bytes)
+		records = append(records, record)
+		if record.Op() == tx.START {
+			break
+		}
+	}
+	want := []tx.LogRecordType{tx.COMMIT, tx.SETSTRING, tx.SETLONG, tx.SETINT, tx.START}
+	if len(records) != len(want) {
+		t.Fatalf("Expected %d records, got %v", len(want), records)
+	}
+	for i, record := range records {
+		if record.Op() != want[i] || record.TxNumber() != records[0].TxNumber() {
+			t.Errorf("Record %d is %v, expected a %d record of transaction %d", i, record, want[i], records[0].TxNumber())
+		}
+	}
+
+	check := func(t2 *tx.Transaction, wantInt int32, wantLong int64, wantString string) {
+		t.Helper()
+		if val, _ := t2.GetInt(block, 0); val != wantInt {
+			t.Errorf("Expected %d, got %d", wantInt, val)
+		}
+		if val, _ := t2.GetLong(block, 4); val != wantLong {
+			t.Errorf("Expected %d, got %d", wantLong, val)
+		}
+		if val, _ := t2.GetString(block, 12); val != wantString {
+			t.Errorf("Expected %q, got %q", wantString, val)
+		}
+	}
+
+	tx2 := db.newTx()
+	defer tx2.Rollback()
+	tx2.Pin(&block)
+	for _, record := range records {
+		record.Undo(tx2)
+	}
+	check(tx2, 0, 0, "")
+	for i := len(records) - 1; i >= 0; i-- {
+		if err := records[i].Redo(tx2); err != nil {
+			t.Fatalf("Redo of %v failed: %v", records[i], err)
+		}
+	}
+	check(tx2, 42, 1<<40, "hello")
+
+	// A record written by a version that didn't log new values
+	old := make([]byte, 8+file.MaxLength(len("records.tbl"))+12)
+	p := file.NewPageFromBytes(old)
+	p.SetInt(0, tx.SETINT)
+	p.SetInt(4, 1)
+	p.SetString(8, "records.tbl")
+	if err := tx.CreateLogRecord(old).Redo(tx2); !errors.Is(err, tx.ErrCannotRedo) {
+		t.Errorf("Expected %v redoing an old record, got %v", tx.ErrCannotRedo, err)
+	}
+}
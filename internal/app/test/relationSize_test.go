@@ -0,0 +1,80 @@
+package test
+
+import (
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/server"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRelationSize(t *testing.T) {
+	cdb, err := server.OpenCentauriDB(filepath.Join(t.TempDir(), "sizedb"), 400, 8)
+	if err != nil {
+		t.Fatalf("OpenCentauriDB failed: %v", err)
+	}
+	defer cdb.Close()
+	s := server.NewSession(cdb)
+	defer s.Close()
+
+	var values []string
+	for i := 0; i < 60; i++ {
+		values = append(values, fmt.Sprintf("(%d, 'name%d')", i, i))
+	}
+	for _, cmd := range []string{
+		"create table t (id int, name varchar(8))",
+		"create index t_id on t (id)",
+		"create index t_name on t (name) using btree",
+		"insert into t (id, name) values " + strings.Join(values, ", "),
+		"create table empty (id int)",
+	} {
+		if _, err := execSession(t, s, cmd); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+
+	t1 := cdb.NewTx()
+	defer t1.Commit()
+	mdm := cdb.MdMgr()
+
+	size, err := mdm.TableSize("t", t1)
+	if err != nil {
+		t.Fatalf("TableSize failed: %v", err)
+	}
+	if size.Kind != metadata.RELATION_TABLE || size.Rows != 60 || size.Blocks < 2 || size.Bytes != size.Blocks*400 {
+		t.Errorf("unexpected size of t: %+v", size)
+	}
+	if size, err := mdm.TableSize("empty", t1); err != nil || size.Rows != 0 || size.Blocks != 0 {
+		t.Errorf("expected an empty table to take no blocks, got %+v, %v", size, err)
+	}
+	for _, idxName := range []string{"t_id", "t_name"} {
+		size, err := mdm.IndexSize(idxName, t1)
+		if err != nil {
+			t.Fatalf("IndexSize(%s) failed: %v", idxName, err)
+		}
+		if size.Kind != metadata.RELATION_INDEX || size.Rows != 60 || size.Blocks == 0 || size.Bytes != size.Blocks*400 {
+			t.Errorf("unexpected size of %s: %+v", idxName, size)
+		}
+	}
+
+	if _, err := mdm.TableSize("nosuch", t1); !errors.Is(err, metadata.ErrTableNotFound) {
+		t.Errorf("expected %v, got %v", metadata.ErrTableNotFound, err)
+	}
+	if _, err := mdm.IndexSize("nosuch", t1); !errors.Is(err, metadata.ErrIndexNotFound) {
+		t.Errorf("expected %v, got %v", metadata.ErrIndexNotFound, err)
+	}
+
+	// The system view reports the same sizes, catalog tables included
+	got := querySession(t, s, "select relname, kind, blocks, numrecs, bytes from relation_sizes where relname = 't'")
+	if want := fmt.Sprintf("[t table %d 60 %d]", size.Blocks, size.Bytes); fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %v", want, got)
+	}
+	if got := querySession(t, s, "select kind from relation_sizes where relname = 't_name'"); fmt.Sprint(got) != "[index]" {
+		t.Errorf("expected [index], got %v", got)
+	}
+	if got := querySession(t, s, "select numrecs from relation_sizes where relname = 'tblcat'"); len(got) != 1 {
+		t.Errorf("expected the catalog tables to be reported, got %v", got)
+	}
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"bytes"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record/schema"
+	"testing"
+)
+
+func writeTestResult(t *testing.T, rw plan.ResultWriter) {
+	columns := []plan.ResultColumn{
+		{Name: "id", Type: schema.INTEGER},
+		{Name: "name", Type: schema.VARCHAR},
+	}
+	rows := [][]any{
+		{1, "alice"},
+		{2, `bob "the builder", jr`},
+	}
+
+	if err := rw.WriteHeader(columns); err != nil {
+		t.Fatalf("WriteHeader failed: %v", err)
+	}
+	for _, row := range rows {
+		if err := rw.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow failed: %v", err)
+		}
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestResultWriter_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	writeTestResult(t, plan.NewCSVResultWriter(&buf))
+
+	expected := "id,name\n1,alice\n2,\"bob \"\"the builder\"\", jr\"\n"
+	if buf.String() != expected {
+		t.Errorf("CSV mismatch:\ngot  %q\nwant %q", buf.String(), expected)
+	}
+}
+
+func TestResultWriter_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	writeTestResult(t, plan.NewJSONResultWriter(&buf))
+
+	expected := "[\n{\"id\":1,\"name\":\"alice\"},\n{\"id\":2,\"name\":\"bob \\\"the builder\\\", jr\"}\n]\n"
+	if buf.String() != expected {
+		t.Errorf("JSON mismatch:\ngot  %q\nwant %q", buf.String(), expected)
+	}
+}
+
+func TestResultWriter_UnsupportedFormat(t *testing.T) {
+	if _, err := plan.NewResultWriter("xml", &bytes.Buffer{}); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
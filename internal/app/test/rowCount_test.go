@@ -0,0 +1,163 @@
+package test
+
+import (
+	"centauri/internal/app/server"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Returns the row count the catalog keeps for the table
+func rowCount(t *testing.T, cdb *server.CentauriDB, tableName string) int {
+	t.Helper()
+	t1 := cdb.NewTx()
+	defer t1.Commit()
+	return cdb.MdMgr().RowCount(tableName, t1)
+}
+
+func openRowCountDB(t *testing.T, dir string) *server.CentauriDB {
+	t.Helper()
+	cdb, err := server.OpenCentauriDB(dir, 400, 8)
+	if err != nil {
+		t.Fatalf("OpenCentauriDB failed: %v", err)
+	}
+	return cdb
+}
+
+func TestRowCount_InsertDeleteRollback(t *testing.T) {
+	cdb := openRowCountDB(t, filepath.Join(t.TempDir(), "rowcountdb"))
+	defer cdb.Close()
+
+	s := server.NewSession(cdb)
+	defer s.Close()
+	for _, cmd := range []string{
+		"create table t (id int)",
+		"insert into t (id) values (1), (2), (3), (4)",
+		"delete from t where id = 4",
+		"begin",
+		"insert into t (id) values (5), (6)",
+	} {
+		if _, err := execSession(t, s, cmd); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+
+	// The transaction sees its own inserts, others don't
+	if got := fmt.Sprint(querySession(t, s, "select count(*) from t")); got != "[5]" {
+		t.Errorf("expected [5] inside the transaction, got %s", got)
+	}
+	if got := rowCount(t, cdb, "t"); got != 3 {
+		t.Errorf("expected 3 rows outside the transaction, got %d", got)
+	}
+
+	if _, err := execSession(t, s, "rollback"); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	if got := fmt.Sprint(querySession(t, s, "select count(*) from t")); got != "[3]" {
+		t.Errorf("expected [3] after the rollback, got %s", got)
+	}
+}
+
+func TestRowCount_ConcurrentWriters(t *testing.T) {
+	cdb := openRowCountDB(t, filepath.Join(t.TempDir(), "rowcountdb"))
+	defer cdb.Close()
+
+	s := server.NewSession(cdb)
+	defer s.Close()
+	for _, cmd := range []string{
+		"create table a (id int)",
+		"create table b (id int)",
+		"begin",
+		"insert into a (id) values (1)",
+	} {
+		if _, err := execSession(t, s, cmd); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+
+	// A writer of another table doesn't wait for the first one
+	other := server.NewSession(cdb)
+	defer other.Close()
+	other.Set(server.SETTING_LOCK_TIMEOUT, "100ms")
+	for _, cmd := range []string{"begin", "insert into b (id) values (1), (2)", "commit"} {
+		if _, err := execSession(t, other, cmd); err != nil {
+			t.Fatalf("%s: expected the writer of a not to hold up writers of b, got %v", cmd, err)
+		}
+	}
+	if _, err := execSession(t, s, "commit"); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	if got := rowCount(t, cdb, "a"); got != 1 {
+		t.Errorf("expected 1 row in a, got %d", got)
+	}
+	if got := rowCount(t, cdb, "b"); got != 2 {
+		t.Errorf("expected 2 rows in b, got %d", got)
+	}
+}
+
+func TestRowCount_Reopen(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "rowcountdb")
+	cdb := openRowCountDB(t, dir)
+	s := server.NewSession(cdb)
+	for _, cmd := range []string{
+		"create table t (id int)",
+		"insert into t (id) values (1), (2), (3)",
+	} {
+		if _, err := execSession(t, s, cmd); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+	s.Close()
+	cdb.Close()
+
+	// Closing saves the counts to the catalog
+	cdb = openRowCountDB(t, dir)
+	if got := rowCount(t, cdb, "t"); got != 3 {
+		t.Errorf("expected 3 rows after reopening, got %d", got)
+	}
+	s = server.NewSession(cdb)
+	if _, err := execSession(t, s, "insert into t (id) values (4), (5)"); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	s.Close()
+
+	// A crash loses the counts kept in memory, so they're recounted
+	cdb.FileMgr().Close()
+	cdb = openRowCountDB(t, dir)
+	defer cdb.Close()
+	if got := rowCount(t, cdb, "t"); got != 5 {
+		t.Errorf("expected 5 rows after the crash, got %d", got)
+	}
+}
+
+func TestRowCount_Statistics(t *testing.T) {
+	cdb := openRowCountDB(t, filepath.Join(t.TempDir(), "rowcountdb"))
+	defer cdb.Close()
+
+	s := server.NewSession(cdb)
+	defer s.Close()
+	for _, cmd := range []string{
+		"create table t (id int)",
+		"insert into t (id) values (1), (2), (3), (4)",
+	} {
+		if _, err := execSession(t, s, cmd); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+
+	// The planner estimates from the current count, though the table
+	// changed too little for its statistics to be recalculated
+	got := fmt.Sprint(querySession(t, s, "explain select id from t"))
+	if !strings.Contains(got, "table t (blocks=1 records=4)") {
+		t.Errorf("expected the table to be estimated at 4 records, got %s", got)
+	}
+	if _, err := execSession(t, s, "delete from t where id = 1"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	got = fmt.Sprint(querySession(t, s, "explain select id from t"))
+	if !strings.Contains(got, "table t (blocks=1 records=3)") {
+		t.Errorf("expected the table to be estimated at 3 records, got %s", got)
+	}
+}
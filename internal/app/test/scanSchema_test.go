@@ -0,0 +1,84 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/record/schema"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchema_Columns(t *testing.T) {
+	sch := schema.NewSchema()
+	sch.AddIntField("id")
+	sch.AddStringField("name", 10)
+	sch.SetCollation("name", "nocase")
+	sch.SetNullable("name", true)
+
+	got := fmt.Sprint(sch.Columns())
+	want := "[{id int 0  false} {name varchar 10 nocase true}]"
+	if got != want {
+		t.Errorf("expected columns %s, got %s", want, got)
+	}
+
+	// Fields added from another schema keep their nullability
+	copied := schema.NewSchema()
+	copied.Add("name", sch)
+	if !copied.Nullable("name") {
+		t.Errorf("expected the copied field to be nullable")
+	}
+}
+
+func TestScan_Schema(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "schemadb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), deptid int)",
+		"create table dept (did int, dname varchar(12))",
+		"insert into emp (id, name, deptid) values (1, 'ann', 10)",
+		"insert into dept (did, dname) values (10, 'sales')",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query   string
+		columns string
+	}{
+		{"select name, id from emp", "[name varchar(8) id int]"},
+		{"select dname, name from emp, dept where deptid = did", "[dname varchar(12) name varchar(8)]"},
+		{"select count(*), max(name) from emp", "[count int maxofname varchar(8)]"},
+		// The schema is known even when there are no records
+		{"select max(dname) from dept where did = 99", "[maxofdname varchar(12)]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rows, err := d.Query(tt.query)
+			if err != nil {
+				t.Fatalf("query failed: %v", err)
+			}
+			defer rows.Close()
+
+			var columns []string
+			for _, col := range rows.Columns() {
+				if col.Nullable {
+					t.Errorf("expected column %s not to be nullable", col.Name)
+				}
+				if col.Type == db.VARCHAR {
+					columns = append(columns, fmt.Sprintf("%s %s(%d)", col.Name, col.Type, col.Length))
+				} else {
+					columns = append(columns, fmt.Sprintf("%s %s", col.Name, col.Type))
+				}
+			}
+			if got := fmt.Sprint(columns); got != tt.columns {
+				t.Errorf("expected columns %s, got %s", tt.columns, got)
+			}
+		})
+	}
+}
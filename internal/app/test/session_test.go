@@ -0,0 +1,180 @@
+package test
+
+import (
+	"centauri/internal/app/parse"
+	"centauri/internal/app/server"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Executes a statement in the session, returning the number of records
+// it affected or, for a query, found
+func execSession(t *testing.T, s *server.Session, cmd string) (int, error) {
+	t.Helper()
+	count := 0
+	err := s.Execute(cmd, func(r *server.Result) error {
+		if r.Plan == nil {
+			count = r.Count
+			return nil
+		}
+		scan := r.Plan.Open()
+		defer scan.Close()
+		for scan.Next() {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Executes a query in the session and returns its records
+func querySession(t *testing.T, s *server.Session, cmd string) []string {
+	t.Helper()
+	var records []string
+	err := s.Execute(cmd, func(r *server.Result) error {
+		scan := r.Plan.Open()
+		defer scan.Close()
+		for scan.Next() {
+			var values []string
+			for _, fieldName := range r.Plan.Schema().Fields() {
+				values = append(values, scan.GetVal(fieldName).String())
+			}
+			records = append(records, strings.Join(values, " "))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%s failed: %v", cmd, err)
+	}
+	return records
+}
+
+func TestSession_Transaction(t *testing.T) {
+	cdb, err := server.OpenCentauriDB(filepath.Join(t.TempDir(), "sessiondb"), 400, 8)
+	if err != nil {
+		t.Fatalf("OpenCentauriDB failed: %v", err)
+	}
+	defer cdb.Close()
+
+	s := server.NewSession(cdb)
+	mustExec := func(cmd string) int {
+		t.Helper()
+		count, err := execSession(t, s, cmd)
+		if err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+		return count
+	}
+
+	mustExec("create table t (id int)")
+
+	// A rolled back transaction leaves nothing behind
+	mustExec("begin")
+	mustExec("insert into t (id) values (1), (2)")
+	if got := mustExec("select id from t"); got != 2 {
+		t.Errorf("expected the transaction to see its 2 records, got %d", got)
+	}
+	mustExec("rollback")
+	if s.InTransaction() {
+		t.Errorf("expected ROLLBACK to end the transaction")
+	}
+	if got := mustExec("select id from t"); got != 0 {
+		t.Errorf("expected no records after ROLLBACK, got %d", got)
+	}
+
+	// A committed one keeps its changes
+	mustExec("START TRANSACTION")
+	mustExec("insert into t (id) values (3)")
+	mustExec("update t set id = 4 where id = 3")
+	mustExec("COMMIT WORK")
+	if got := mustExec("select id from t where id = 4"); got != 1 {
+		t.Errorf("expected the committed record, got %d", got)
+	}
+
+	if _, err := execSession(t, s, "commit"); !errors.Is(err, server.ErrNoTransaction) {
+		t.Errorf("expected %v, got %v", server.ErrNoTransaction, err)
+	}
+	if _, err := execSession(t, s, "rollback"); !errors.Is(err, server.ErrNoTransaction) {
+		t.Errorf("expected %v, got %v", server.ErrNoTransaction, err)
+	}
+	if _, err := execSession(t, s, "start"); !errors.Is(err, parse.ErrSyntax) {
+		t.Errorf("expected %v, got %v", parse.ErrSyntax, err)
+	}
+	mustExec("begin")
+	if _, err := execSession(t, s, "begin work"); !errors.Is(err, server.ErrTransactionStarted) {
+		t.Errorf("expected %v, got %v", server.ErrTransactionStarted, err)
+	}
+
+	// Closing the session rolls back its transaction
+	mustExec("delete from t")
+	s.Close()
+	s = server.NewSession(cdb)
+	if got := mustExec("select id from t"); got != 1 {
+		t.Errorf("expected the delete to be rolled back, got %d records", got)
+	}
+}
+
+func TestSession_ShowTables(t *testing.T) {
+	cdb, err := server.OpenCentauriDB(filepath.Join(t.TempDir(), "sessiondb"), 400, 8)
+	if err != nil {
+		t.Fatalf("OpenCentauriDB failed: %v", err)
+	}
+	defer cdb.Close()
+
+	s := server.NewSession(cdb)
+	for _, cmd := range []string{
+		"create table items (id int, name varchar(8), price bigint, label varchar(8) as (upper(name)) virtual)",
+		"create table orders (id int)",
+		"create view cheap as select id, name from items where price < 10",
+	} {
+		if _, err := execSession(t, s, cmd); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+
+	if got := fmt.Sprint(querySession(t, s, "show tables")); got != "[items table orders table cheap view]" {
+		t.Errorf("expected the tables then the view, got %s", got)
+	}
+
+	tx := cdb.NewTx()
+	layout, err := cdb.MdMgr().GetLayout("items", tx)
+	tx.Commit()
+	if err != nil {
+		t.Fatalf("GetLayout failed: %v", err)
+	}
+	want := fmt.Sprint([]string{
+		fmt.Sprintf("id int NULL %d", layout.Offset("id")),
+		fmt.Sprintf("name varchar 8 %d", layout.Offset("name")),
+		fmt.Sprintf("price bigint NULL %d", layout.Offset("price")),
+		"label varchar 8 NULL",
+	})
+	if got := fmt.Sprint(querySession(t, s, "describe items")); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got := fmt.Sprint(querySession(t, s, "desc cheap")); got != "[id int NULL NULL name varchar 8 NULL]" {
+		t.Errorf("expected the columns of the view without offsets, got %s", got)
+	}
+
+	// The catalog is read in the session's transaction, which sees its own changes
+	if _, err := execSession(t, s, "begin"); err != nil {
+		t.Fatalf("begin failed: %v", err)
+	}
+	if _, err := execSession(t, s, "drop table orders"); err != nil {
+		t.Fatalf("drop failed: %v", err)
+	}
+	if got := fmt.Sprint(querySession(t, s, "show tables")); got != "[items table cheap view]" {
+		t.Errorf("expected orders to be gone, got %s", got)
+	}
+	if _, err := execSession(t, s, "describe orders"); err == nil {
+		t.Errorf("expected describing a dropped table to fail")
+	}
+	if _, err := execSession(t, s, "rollback"); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	if got := len(querySession(t, s, "show tables")); got != 3 {
+		t.Errorf("expected 3 tables and views after ROLLBACK, got %d", got)
+	}
+}
@@ -0,0 +1,235 @@
+package test
+
+import (
+	"centauri/internal/app/server"
+	"centauri/internal/app/tx"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshot_Reads(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "snapshotdb"))
+	defer db.fm.Close()
+
+	setup := db.newTx()
+	block, err := setup.Append("snapshottest")
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	setup.Pin(&block)
+	if err := setup.SetInt(block, 0, 1, true); err != nil {
+		t.Fatalf("SetInt failed: %v", err)
+	}
+	if err := setup.SetString(block, 40, "one", true); err != nil {
+		t.Fatalf("SetString failed: %v", err)
+	}
+	setup.Commit()
+
+	// Starts a transaction with the block pinned, which waits briefly for locks
+	begin := func(level tx.IsolationLevel) *tx.Transaction {
+		t1 := tx.NewTransactionWithIsolation(db.fm, db.lm, db.bm, level)
+		t1.SetLockTimeout(100 * time.Millisecond)
+		t1.Pin(&block)
+		return t1
+	}
+	// Returns the values of the block as the transaction sees them
+	read := func(t1 *tx.Transaction) string {
+		t.Helper()
+		var n int32
+		var s string
+		err := lockError(func() error {
+			var err error
+			if n, err = t1.GetInt(block, 0); err != nil {
+				return err
+			}
+			s, err = t1.GetString(block, 40)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		return fmt.Sprintf("%d %s", n, s)
+	}
+
+	reader := begin(tx.SNAPSHOT)
+	if got := read(reader); got != "1 one" {
+		t.Errorf("expected 1 one, got %s", got)
+	}
+	if _, err := reader.Size("snapshottest"); err != nil {
+		t.Fatalf("Size failed: %v", err)
+	}
+
+	// The reader holds no locks, so the writer doesn't wait for it,
+	// and the reader doesn't wait for the writer in turn
+	writer := begin(tx.SERIALIZABLE)
+	err = lockError(func() error {
+		if err := writer.SetInt(block, 0, 2, true); err != nil {
+			return err
+		}
+		if err := writer.SetString(block, 40, "two", true); err != nil {
+			return err
+		}
+		_, err := writer.Append("snapshottest")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("expected the writer not to wait for the snapshot, got %v", err)
+	}
+	if got := read(reader); got != "1 one" {
+		t.Errorf("expected the uncommitted write to be hidden, got %s", got)
+	}
+	writer.Commit()
+	if got := read(reader); got != "1 one" {
+		t.Errorf("expected the write committed since the snapshot to be hidden, got %s", got)
+	}
+
+	// A snapshot taken while a writer runs has what was committed before
+	later := begin(tx.SNAPSHOT)
+	defer later.Rollback()
+	running := begin(tx.SERIALIZABLE)
+	if err := running.SetInt(block, 0, 3, true); err != nil {
+		t.Fatalf("SetInt failed: %v", err)
+	}
+	current := begin(tx.SNAPSHOT)
+	if got := read(later); got != "2 two" {
+		t.Errorf("expected 2 two, got %s", got)
+	}
+	if got := read(current); got != "2 two" {
+		t.Errorf("expected the running writer to be hidden, got %s", got)
+	}
+	running.Rollback()
+	current.Rollback()
+
+	// The first to change the block wins
+	if err := lockError(func() error { return reader.SetInt(block, 0, 4, true) }); !errors.Is(err, tx.ErrSerializationFailure) {
+		t.Errorf("expected %v, got %v", tx.ErrSerializationFailure, err)
+	}
+	reader.Rollback()
+	if err := lockError(func() error { return later.SetInt(block, 0, 5, true) }); err != nil {
+		t.Fatalf("expected the snapshot seeing every change to write, got %v", err)
+	}
+	if got := read(later); got != "5 two" {
+		t.Errorf("expected its own write, got %s", got)
+	}
+}
+
+func TestSession_Snapshot(t *testing.T) {
+	cdb, err := server.OpenCentauriDB(filepath.Join(t.TempDir(), "sessiondb"), 400, 8)
+	if err != nil {
+		t.Fatalf("OpenCentauriDB failed: %v", err)
+	}
+	defer cdb.Close()
+
+	s := server.NewSession(cdb)
+	defer s.Close()
+	for _, cmd := range []string{
+		"create table t (id int)",
+		"insert into t (id) values (1)",
+		"begin isolation level snapshot",
+	} {
+		if _, err := execSession(t, s, cmd); err != nil {
+			t.Fatalf("%s failed: %v", cmd, err)
+		}
+	}
+	if got := fmt.Sprint(querySession(t, s, "select id from t")); got != "[1]" {
+		t.Errorf("expected [1], got %s", got)
+	}
+
+	other := server.NewSession(cdb)
+	defer other.Close()
+	other.Set(server.SETTING_LOCK_TIMEOUT, "100ms")
+	for _, cmd := range []string{"update t set id = 2", "insert into t (id) values (3)"} {
+		if _, err := execSession(t, other, cmd); err != nil {
+			t.Fatalf("%s: expected the snapshot not to keep writers waiting, got %v", cmd, err)
+		}
+	}
+
+	if got := fmt.Sprint(querySession(t, s, "select id from t")); got != "[1]" {
+		t.Errorf("expected the snapshot to stay [1], got %s", got)
+	}
+	// The failed update ends the transaction
+	if _, err := execSession(t, s, "update t set id = 4"); !errors.Is(err, tx.ErrSerializationFailure) {
+		t.Errorf("expected %v, got %v", tx.ErrSerializationFailure, err)
+	}
+	if got := fmt.Sprint(querySession(t, s, "select id from t order by id")); got != "[2 3]" {
+		t.Errorf("expected [2 3], got %s", got)
+	}
+}
+
+func TestSnapshot_VersionLimit(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "snapshotdb"))
+	defer db.fm.Close()
+	tx.SetSnapshotVersionLimitFor(db.fm, 4)
+
+	setup := db.newTx()
+	block, err := setup.Append("snapshottest")
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	setup.Pin(&block)
+	if err := setup.SetInt(block, 0, 1, true); err != nil {
+		t.Fatalf("SetInt failed: %v", err)
+	}
+	setup.Commit()
+
+	begin := func(level tx.IsolationLevel) *tx.Transaction {
+		t1 := tx.NewTransactionWithIsolation(db.fm, db.lm, db.bm, level)
+		t1.SetLockTimeout(100 * time.Millisecond)
+		t1.Pin(&block)
+		return t1
+	}
+	read := func(t1 *tx.Transaction) (int32, error) {
+		var n int32
+		err := lockError(func() error {
+			var err error
+			n, err = t1.GetInt(block, 0)
+			return err
+		})
+		return n, err
+	}
+	write := func(t1 *tx.Transaction, changes int) {
+		t.Helper()
+		for i := 0; i < changes; i++ {
+			if err := t1.SetInt(block, 0, 10+i, true); err != nil {
+				t.Fatalf("SetInt failed: %v", err)
+			}
+		}
+	}
+
+	// Keeping more changes than the limit for a snapshot expires it
+	old := begin(tx.SNAPSHOT)
+	writer := begin(tx.SERIALIZABLE)
+	write(writer, 5)
+	writer.Commit()
+	if _, err := read(old); !errors.Is(err, tx.ErrSnapshotTooOld) {
+		t.Errorf("expected %v, got %v", tx.ErrSnapshotTooOld, err)
+	}
+	old.Rollback()
+
+	// Snapshots taken once the changes are dropped read as before
+	current := begin(tx.SNAPSHOT)
+	if n, err := read(current); err != nil || n != 14 {
+		t.Errorf("expected 14, got %d, %v", n, err)
+	}
+	current.Rollback()
+
+	// A writer changing more than the limit stops keeping its changes,
+	// so a snapshot that would need them expires until it finishes
+	writer = begin(tx.SERIALIZABLE)
+	write(writer, 5)
+	during := begin(tx.SNAPSHOT)
+	if _, err := read(during); !errors.Is(err, tx.ErrSnapshotTooOld) {
+		t.Errorf("expected %v, got %v", tx.ErrSnapshotTooOld, err)
+	}
+	during.Rollback()
+	writer.Commit()
+
+	after := begin(tx.SNAPSHOT)
+	defer after.Rollback()
+	if n, err := read(after); err != nil || n != 14 {
+		t.Errorf("expected 14, got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,258 @@
+package test
+
+import (
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/query"
+	"centauri/internal/app/record"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Sorts the rows with the comparator and returns their names in order.
+// A row without a value for a field has a null there.
+func sortRows(comp *materialize.RecordComparator, rows []map[string]*types.Constant) []string {
+	sch := schema.NewSchema()
+	sch.AddStringField("name", 10)
+	sch.AddIntField("age")
+
+	scanAt := func(row map[string]*types.Constant) *query.RowsScan {
+		s := query.NewRowsScan(sch, []map[string]*types.Constant{row})
+		s.Next()
+		return s
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return comp.Compare(scanAt(rows[i]), scanAt(rows[j])) < 0
+	})
+
+	names := make([]string, len(rows))
+	for i, row := range rows {
+		names[i] = row["name"].String()
+	}
+	return names
+}
+
+func sortTestRows() []map[string]*types.Constant {
+	return []map[string]*types.Constant{
+		{"name": types.NewConstantString("ann"), "age": types.NewConstantInt(30)},
+		{"name": types.NewConstantString("bob")},
+		{"name": types.NewConstantString("cid"), "age": types.NewConstantInt(25)},
+		{"name": types.NewConstantString("dee"), "age": types.NewConstantInt(30)},
+	}
+}
+
+func TestRecordComparator_SortSpecs(t *testing.T) {
+	tests := []struct {
+		name     string
+		specs    []materialize.SortSpec
+		expected []string
+	}{
+		{
+			name:     "ascending, nulls last",
+			specs:    []materialize.SortSpec{materialize.Asc("age")},
+			expected: []string{"cid", "ann", "dee", "bob"},
+		},
+		{
+			name:     "descending, nulls first",
+			specs:    []materialize.SortSpec{materialize.Desc("age")},
+			expected: []string{"bob", "ann", "dee", "cid"},
+		},
+		{
+			name:     "ascending, nulls first",
+			specs:    []materialize.SortSpec{{Field: "age", Nulls: materialize.NULLS_FIRST}},
+			expected: []string{"bob", "cid", "ann", "dee"},
+		},
+		{
+			name:     "descending, nulls last",
+			specs:    []materialize.SortSpec{{Field: "age", Descending: true, Nulls: materialize.NULLS_LAST}},
+			expected: []string{"ann", "dee", "cid", "bob"},
+		},
+		{
+			name:     "ties broken by the next spec",
+			specs:    []materialize.SortSpec{materialize.Desc("age"), materialize.Desc("name")},
+			expected: []string{"bob", "dee", "ann", "cid"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sortRows(materialize.NewRecordComparator(tt.specs), sortTestRows())
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRecordComparator_String(t *testing.T) {
+	comp := materialize.NewRecordComparator([]materialize.SortSpec{
+		materialize.Asc("a"),
+		materialize.Desc("b"),
+		{Field: "c", Nulls: materialize.NULLS_FIRST},
+	})
+	if got := comp.String(); got != "a, b desc, c nulls first" {
+		t.Errorf("unexpected string %q", got)
+	}
+}
+
+func TestSortPlan_ManyRuns(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "sortdb"))
+	defer db.fm.Close()
+
+	sch := schema.NewSchema()
+	sch.AddIntField("k")
+	sch.AddIntField("seq")
+	sch.AddStringField("pad", 80)
+
+	// Wide records fit a few to a block, so the input makes more runs
+	// than the buffer pool can merge at once. Each key appears twice.
+	var rows []map[string]*types.Constant
+	for i := 0; i < 200; i++ {
+		rows = append(rows, map[string]*types.Constant{
+			"k":   types.NewConstantInt(99 - i%100),
+			"seq": types.NewConstantInt(i),
+			"pad": types.NewConstantString("x"),
+		})
+	}
+
+	tx1 := db.newTx()
+	defer tx1.Rollback()
+
+	sp := materialize.NewSortPlan(tx1, plan.NewRowsPlan(sch, rows), materialize.AscAll([]string{"k"}))
+	s := sp.Open()
+	defer s.Close()
+
+	count := 0
+	for s.Next() {
+		k, seq := s.GetInt("k"), s.GetInt("seq")
+		if k != count/2 {
+			t.Fatalf("record %d: expected key %d, got %d", count, count/2, k)
+		}
+		// Equal keys keep their input order
+		want := 99 - k
+		if count%2 == 1 {
+			want += 100
+		}
+		if seq != want {
+			t.Errorf("record %d: expected seq %d, got %d", count, want, seq)
+		}
+		count++
+	}
+	if count != len(rows) {
+		t.Errorf("expected %d records, got %d", len(rows), count)
+	}
+}
+
+func TestSortPlan_SplitIntoRuns(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "sortdb"))
+	defer db.fm.Close()
+
+	sch := schema.NewSchema()
+	sch.AddIntField("k")
+	sch.AddStringField("pad", 80)
+
+	var rows []map[string]*types.Constant
+	for i := 0; i < 100; i++ {
+		rows = append(rows, map[string]*types.Constant{
+			"k":   types.NewConstantInt(99 - i),
+			"pad": types.NewConstantString("x"),
+		})
+	}
+
+	tx1 := db.newTx()
+	defer tx1.Rollback()
+
+	src := plan.NewRowsPlan(sch, rows)
+	sp := materialize.NewSortPlan(tx1, src, materialize.AscAll([]string{"k"}))
+	s := src.Open()
+	runs := sp.SplitIntoRuns(s)
+	s.Close()
+
+	// Each run is sorted and spans several blocks
+	perBlock := tx1.BlockSize() / record.NewLayout(sch).SlotSize()
+	total := 0
+	for i, run := range runs {
+		ts := run.Open()
+		count, prev := 0, -1
+		for ts.Next() {
+			k := ts.GetInt("k")
+			if k < prev {
+				t.Errorf("run %d: %d after %d", i, k, prev)
+			}
+			prev = k
+			count++
+		}
+		ts.Close()
+
+		if i < len(runs)-1 && count <= perBlock {
+			t.Errorf("run %d: expected more than %d records, got %d", i, perBlock, count)
+		}
+		total += count
+	}
+	if total != len(rows) {
+		t.Errorf("expected %d records, got %d", len(rows), total)
+	}
+	if len(runs) < 2 || len(runs) >= len(rows)/perBlock {
+		t.Errorf("expected fewer runs than blocks of input, got %d", len(runs))
+	}
+}
+
+func TestSortPlan_NoRecords(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "sortdb"))
+	defer db.fm.Close()
+
+	sch := schema.NewSchema()
+	sch.AddIntField("k")
+
+	tx1 := db.newTx()
+	defer tx1.Rollback()
+
+	s := materialize.NewSortPlan(tx1, plan.NewRowsPlan(sch, nil), materialize.AscAll([]string{"k"})).Open()
+	defer s.Close()
+	if s.Next() {
+		t.Error("expected no records")
+	}
+}
+
+func TestSortScan_RestorePosition(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "sortdb"))
+	defer db.fm.Close()
+
+	sch := schema.NewSchema()
+	sch.AddIntField("k")
+
+	var rows []map[string]*types.Constant
+	for _, k := range []int{5, 3, 8, 1, 9, 2, 7, 4, 6, 0} {
+		rows = append(rows, map[string]*types.Constant{"k": types.NewConstantInt(k)})
+	}
+
+	tx1 := db.newTx()
+	defer tx1.Rollback()
+
+	s := materialize.NewSortPlan(tx1, plan.NewRowsPlan(sch, rows), materialize.AscAll([]string{"k"})).Open()
+	defer s.Close()
+	ss := s.(*materialize.SortScan)
+
+	for i := 0; i <= 3; i++ {
+		ss.Next()
+	}
+	ss.SavePosition()
+	for ss.Next() {
+	}
+
+	// The saved record is current again and the merge continues after it
+	ss.RestorePosition()
+	for want := 3; want < 10; want++ {
+		if got := ss.GetInt("k"); got != want {
+			t.Fatalf("expected %d, got %d", want, got)
+		}
+		if ss.Next() != (want < 9) {
+			t.Fatalf("unexpected end of records after %d", want)
+		}
+	}
+}
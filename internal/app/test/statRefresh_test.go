@@ -0,0 +1,94 @@
+package test
+
+import (
+	"centauri/config"
+	"centauri/internal/app/server"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns the number of records the statistics estimate for the table
+func estimatedRecords(t *testing.T, cdb *server.CentauriDB, tableName string) int {
+	t.Helper()
+	t1 := cdb.NewTx()
+	defer t1.Commit()
+	layout, err := cdb.MdMgr().GetLayout(tableName, t1)
+	if err != nil {
+		t.Fatalf("GetLayout failed: %v", err)
+	}
+	si := cdb.MdMgr().GetStatInfo(tableName, layout, t1)
+	return si.RecordsOutput()
+}
+
+// Inserts the records with ids from first up to, but not including, last
+func insertIDs(t *testing.T, s *server.Session, first int, last int) {
+	t.Helper()
+	var values []string
+	for i := first; i < last; i++ {
+		values = append(values, fmt.Sprintf("(%d)", i))
+	}
+	if _, err := execSession(t, s, "insert into t (id) values "+strings.Join(values, ", ")); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+}
+
+func TestStatRefresh_Threshold(t *testing.T) {
+	cdb := openRowCountDB(t, filepath.Join(t.TempDir(), "statsdb"))
+	defer cdb.Close()
+	s := server.NewSession(cdb)
+	defer s.Close()
+
+	// The statistics of a table whose rows are counted are always current,
+	// so the threshold applies to untracked tables, as on a standby
+	cdb.MdMgr().IgnoreRowCounts()
+	if _, err := execSession(t, s, "create table t (id int)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	if got := estimatedRecords(t, cdb, "t"); got != 0 {
+		t.Fatalf("expected 0 records, got %d", got)
+	}
+
+	// Fewer modifications than the threshold leave the cached statistics
+	cdb.MdMgr().StatMgr().SetModificationThreshold(20)
+	insertIDs(t, s, 0, 10)
+	if got := estimatedRecords(t, cdb, "t"); got != 0 {
+		t.Errorf("expected the cached 0 records below the threshold, got %d", got)
+	}
+	insertIDs(t, s, 10, 20)
+	if got := estimatedRecords(t, cdb, "t"); got != 20 {
+		t.Errorf("expected 20 records once the threshold is reached, got %d", got)
+	}
+}
+
+func TestStatRefresh_Background(t *testing.T) {
+	cfg := config.Default()
+	cfg.DataDir = filepath.Join(t.TempDir(), "statsdb")
+	cfg.StatsRefresh = 20 * time.Millisecond
+	cdb, err := server.OpenCentauriDBFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("OpenCentauriDBFromConfig failed: %v", err)
+	}
+	defer cdb.Close()
+	s := server.NewSession(cdb)
+	defer s.Close()
+
+	if _, err := execSession(t, s, "create table t (id int)"); err != nil {
+		t.Fatalf("create table failed: %v", err)
+	}
+	if got := estimatedRecords(t, cdb, "t"); got != 0 {
+		t.Fatalf("expected 0 records, got %d", got)
+	}
+
+	// Far below the threshold, only the background refresh updates them
+	insertIDs(t, s, 0, 5)
+	deadline := time.Now().Add(5 * time.Second)
+	for estimatedRecords(t, cdb, "t") != 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the background refresh to find 5 records, still %d", estimatedRecords(t, cdb, "t"))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
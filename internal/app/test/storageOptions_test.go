@@ -0,0 +1,159 @@
+package test
+
+import (
+	"centauri/db"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStorageOptions_BlockSize(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "blocksizedb")
+	d, err := db.Open(dir, &db.Options{BlockSize: 400, BufferSize: 8})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	exec := func(d *db.DB, sql string) {
+		t.Helper()
+		if _, err := d.Exec(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+	exec(d, "create table big (id int, name varchar(400)) with (blocksize=2048)")
+	exec(d, "create table small (id int, name varchar(10)) with (blocksize=128, format=fixed)")
+	exec(d, "create table same (id int) with (blocksize=400)")
+
+	// Strings too long to be logged in a block of the database's size go to overflow blocks
+	name := func(i int) string { return fmt.Sprintf("%03d%s", i, strings.Repeat("x", 350+i)) }
+	for i := 0; i < 20; i++ {
+		exec(d, fmt.Sprintf("insert into big (id, name) values (%d, '%s')", i, name(i)))
+		exec(d, fmt.Sprintf("insert into small (id, name) values (%d, 's%d')", i, i))
+	}
+	exec(d, "insert into same (id) values (1)")
+
+	// A rolled back change of the strings is undone
+	tx1, err := d.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := tx1.Exec("update big set name = 'short'"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	tx1.Rollback()
+	exec(d, "update big set name = 'y' where id = 3")
+	d.Close()
+
+	// The blocks of each file have the table's size, and the database's
+	// size is stored in the table's own file
+	for filename, blockSize := range map[string]int64{"big@2048.tbl": 2048, "small@128.tbl": 128, "same.tbl": 400} {
+		info, err := os.Stat(filepath.Join(dir, filename))
+		if err != nil {
+			t.Errorf("expected %s: %v", filename, err)
+		} else if info.Size() == 0 || info.Size()%blockSize != 0 {
+			t.Errorf("expected %s to hold blocks of %d bytes, got %d bytes", filename, blockSize, info.Size())
+		}
+	}
+
+	d, err = db.Open(dir, &db.Options{BlockSize: 400, BufferSize: 8})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer d.Close()
+	got := queryRecords(t, d, "select id, name from big order by id")
+	if len(got) != 20 {
+		t.Fatalf("expected 20 rows of big, got %d", len(got))
+	}
+	for i, record := range got {
+		want := fmt.Sprint([]any{i, name(i)})
+		if i == 3 {
+			want = "[3 y]"
+		}
+		if record != want {
+			t.Errorf("row %d: expected %s, got %s", i, want, record)
+		}
+	}
+	if got := queryRecords(t, d, "select id, name from small where id = 17"); fmt.Sprint(got) != "[[17 s17]]" {
+		t.Errorf("expected [[17 s17]], got %v", got)
+	}
+
+	for _, sql := range []string{
+		"create table tiny (id int) with (blocksize=32)",
+		"create table fixed (id int) with (blocksize=800, format=fixed)",
+	} {
+		if _, err := d.Exec(sql); err == nil || !strings.Contains(err.Error(), "blocksize") {
+			t.Errorf("%s: expected a blocksize error, got %v", sql, err)
+		}
+	}
+}
+
+func TestStorageOptions_Compression(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "compressiondb")
+	d, err := db.Open(dir, &db.Options{BlockSize: 400, BufferSize: 8})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, sql := range []string{
+		"create table plain (id int, doc varchar(150))",
+		"create table packed (id int, doc varchar(150)) with (compression=on)",
+	} {
+		if _, err := d.Exec(sql); err != nil {
+			t.Fatalf("%s failed: %v", sql, err)
+		}
+	}
+
+	// Repetitive strings are stored compressed, others as they are
+	doc := func(i int) string {
+		if i%5 == 0 {
+			return fmt.Sprintf("q%d", i)
+		}
+		return fmt.Sprintf("%d:%s", i, strings.Repeat("abc", 45))
+	}
+	for i := 0; i < 30; i++ {
+		for _, table := range []string{"plain", "packed"} {
+			if _, err := d.Exec(fmt.Sprintf("insert into %s (id, doc) values (%d, '%s')", table, i, doc(i))); err != nil {
+				t.Fatalf("insert failed: %v", err)
+			}
+		}
+	}
+	if _, err := d.Exec("update packed set doc = '' where id = 7"); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	got := queryRecords(t, d, "select id, doc from packed order by id")
+	if len(got) != 30 {
+		t.Fatalf("expected 30 rows, got %d", len(got))
+	}
+	for i, record := range got {
+		want := fmt.Sprint([]any{i, doc(i)})
+		if i == 7 {
+			want = "[7 ]"
+		}
+		if record != want {
+			t.Errorf("row %d: expected %s, got %s", i, want, record)
+		}
+	}
+	if got := queryRecords(t, d, fmt.Sprintf("select id from packed where doc = '%s'", doc(12))); fmt.Sprint(got) != "[[12]]" {
+		t.Errorf("expected [[12]], got %v", got)
+	}
+
+	size := func(filename string) int64 {
+		t.Helper()
+		info, err := os.Stat(filepath.Join(dir, filename))
+		if err != nil {
+			t.Fatalf("expected %s: %v", filename, err)
+		}
+		return info.Size()
+	}
+	if plain, packed := size("plain.tbl"), size("packed.tbl"); packed >= plain {
+		t.Errorf("expected the compressed table to take less space than %d bytes, got %d", plain, packed)
+	}
+
+	if _, err := d.Exec("create table fixed (doc varchar(10)) with (compression=on, format=fixed)"); err == nil || !strings.Contains(err.Error(), "compression") {
+		t.Errorf("expected compression to be refused in the fixed format, got %v", err)
+	}
+}
@@ -0,0 +1,354 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/interfaces"
+	"centauri/internal/app/optimization"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/query"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// A plan counting the scans opened on it
+type countingPlan struct {
+	interfaces.Plan
+	opens int
+}
+
+func (cp *countingPlan) Open() interfaces.Scan {
+	cp.opens++
+	return cp.Plan.Open()
+}
+
+func TestParser_Subquery(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{
+			"select name from emp where deptid in (select did from dept where dname = 'eng')",
+			"select name from emp where deptid IN (select did from dept where dname=eng)",
+		},
+		{
+			"select name from emp where deptid not in (select did from dept)",
+			"select name from emp where NOT (deptid IN (select did from dept))",
+		},
+		{
+			"select name from emp where exists (select did from dept where did = 10) and id > 1",
+			"select name from emp where EXISTS (select did from dept where did=10) AND id>1",
+		},
+		{
+			"select name from emp where id = (select max(id) from emp)",
+			"select name from emp where id=(select max(id) from emp)",
+		},
+		{
+			"select name from emp where deptid in (select did from dept where did in (select deptid from proj))",
+			"select name from emp where deptid IN (select did from dept where did IN (select deptid from proj))",
+		},
+	}
+	for _, tt := range tests {
+		data, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := data.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		// The fields of a subquery belong to its own tables
+		if got := fmt.Sprint(data.Pred().Terms()[0].Fields()); strings.Contains(got, "did") {
+			t.Errorf("%s: expected the fields of the subquery to be left out, got %s", tt.sql, got)
+		}
+	}
+
+	for _, sql := range []string{
+		"select name from emp where exists dept",
+		"select name from emp where id in (select did from dept, 2)",
+		"select name from emp where id in (select did from dept",
+		"select name from emp where id not = 3",
+		// The tables of a subquery don't qualify fields after it
+		"select name from emp where id in (select did from dept) and dept.did > 1",
+	} {
+		if _, err := parse.NewParser(sql).Query(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+
+	// The outer tables qualify fields in a subquery, and again after it
+	for _, sql := range []string{
+		"select name from emp where exists (select did from dept where emp.deptid = did)",
+		"select name from emp where id in (select did from dept) and emp.id > 1",
+	} {
+		if _, err := parse.NewParser(sql).Query(); err != nil {
+			t.Errorf("%s: expected the outer table to qualify fields: %v", sql, err)
+		}
+	}
+}
+
+func TestSubquery_Query(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "subquerydb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), deptid int)",
+		"create table dept (did int, dname varchar(8))",
+		"insert into emp (id, name, deptid) values (1, 'ann', 10), (2, 'bo', 20), (3, 'cy', 10), (4, 'dee', 30), (5, 'ed', null)",
+		"insert into dept (did, dname) values (10, 'eng'), (20, 'ops'), (40, 'hr')",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select name from emp where deptid in (select did from dept)", "[[ann] [bo] [cy]]"},
+		{"select name from emp where deptid in (select did from dept where dname = 'eng')", "[[ann] [cy]]"},
+		// A null is in no set, and not in no set that isn't empty either
+		{"select name from emp where deptid not in (select did from dept)", "[[dee]]"},
+		{"select name from emp where deptid not in (select did from dept where did > 100)", "[[ann] [bo] [cy] [dee] [ed]]"},
+		{"select dname from dept where did not in (select deptid from emp)", "[]"},
+		{"select dname from dept where did not in (select deptid from emp where deptid is not null)", "[[hr]]"},
+		{"select name from emp where exists (select did from dept where did = 20)", "[[ann] [bo] [cy] [dee] [ed]]"},
+		{"select name from emp where not exists (select did from dept where did = 30)", "[[ann] [bo] [cy] [dee] [ed]]"},
+		{"select name from emp where exists (select did from dept where did = 30)", "[]"},
+		{"select name from emp where id = (select max(id) from emp)", "[[ed]]"},
+		{"select name from emp where id > (select count(*) from dept)", "[[dee] [ed]]"},
+		{"select name from emp where deptid = (select did from dept where dname = 'none')", "[]"},
+		{"select name from emp where deptid in (select did from dept where did in (select deptid from emp where id > 1))", "[[ann] [bo] [cy]]"},
+		{"select name, dname from emp join dept on deptid = did where id in (select id from emp where name = 'bo' or name = 'cy')", "[[bo ops] [cy eng]]"},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		sort.Strings(records)
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	// The temp tables holding the records of the subqueries are dropped
+	if temps, _ := filepath.Glob(filepath.Join(dir, "temp*")); len(temps) > 0 {
+		t.Errorf("expected the temp tables to be dropped, found %v", temps)
+	}
+
+	for query, want := range map[string]error{
+		"select name from emp where id = (select id from emp)":                    query.ErrSubqueryRecords,
+		"select name from emp where id in (select dname from dept)":               plan.ErrTypeMismatch,
+		"select name from emp where name = (select max(did) from dept)":           plan.ErrTypeMismatch,
+		"select name from emp where id in (select nope from dept)":                plan.ErrUnknownField,
+		"select name from emp where exists (select did from dept where nope = 1)": plan.ErrUnknownField,
+	} {
+		rows, err := d.Query(query)
+		if err == nil {
+			for rows.Next() {
+			}
+			err = rows.Err()
+			rows.Close()
+		}
+		// Errors while reading the rows are reported by their message
+		if err == nil || !strings.Contains(err.Error(), want.Error()) {
+			t.Errorf("%s: expected %v, got %v", query, want, err)
+		}
+	}
+	if _, err := d.Query("select name from emp where id in (select did, dname from dept)"); err == nil {
+		t.Errorf("expected a subquery of two fields to be rejected by IN")
+	}
+	if _, err := d.Exec("create view v as select name from emp where deptid in (select did from dept)"); err == nil {
+		t.Errorf("expected a view with a subquery to be rejected")
+	}
+}
+
+func TestSubquery_Update(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "subqueryupdatedb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), deptid int)",
+		"create table dept (did int, dname varchar(8))",
+		"insert into emp (id, name, deptid) values (1, 'ann', 10), (2, 'bo', 20), (3, 'cy', 10), (4, 'dee', 30)",
+		"insert into dept (did, dname) values (10, 'eng'), (20, 'ops')",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	if n, err := d.Exec("update emp set deptid = 20 where deptid in (select did from dept where dname = 'eng')"); err != nil || n != 2 {
+		t.Errorf("expected 2 records updated, got %d, %v", n, err)
+	}
+	// The subquery reads the records as they were before the deletion started
+	if n, err := d.Exec("delete from emp where id < (select max(id) from emp)"); err != nil || n != 3 {
+		t.Errorf("expected 3 records deleted, got %d, %v", n, err)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select name, deptid from emp")); got != "[[dee 30]]" {
+		t.Errorf("expected [[dee 30]], got %s", got)
+	}
+
+	// Parameters in a subquery are bound along with the others
+	stmt, err := d.Prepare("select name from emp where deptid in (select deptid from emp where id = ?)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	for id, want := range map[int]int{4: 1, 1: 0} {
+		rows, err := stmt.Query(id)
+		if err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		count := 0
+		for rows.Next() {
+			count++
+		}
+		rows.Close()
+		if count != want {
+			t.Errorf("id %d: expected %d records, got %d", id, want, count)
+		}
+	}
+}
+
+func TestSubquery_Correlated(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "correlateddb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(8), deptid int, salary int)",
+		"create table dept (did int, dname varchar(8), budget int)",
+		"create table proj (pid int, empid int)",
+		"insert into emp (id, name, deptid, salary) values (1, 'ann', 10, 50), (2, 'bo', 20, 40), (3, 'cy', 10, 70), (4, 'dee', 30, 60), (5, 'ed', null, 30)",
+		"insert into dept (did, dname, budget) values (10, 'eng', 100), (20, 'ops', 30), (40, 'hr', 10)",
+		"insert into proj (pid, empid) values (100, 1), (101, 1), (102, 4)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	// The fields of the statement around a subquery that its own tables
+	// don't have are read from the record it is evaluated on
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select id from emp where exists (select pid from proj where empid = id)", "[[1] [4]]"},
+		{"select id from emp where exists (select pid from proj where proj.empid = emp.id)", "[[1] [4]]"},
+		{"select id from emp where not exists (select pid from proj where empid = id)", "[[2] [3] [5]]"},
+		{"select name from emp where salary > (select budget from dept where did = deptid)", "[[bo]]"},
+		{"select dname from dept where budget > (select max(salary) from emp where deptid = did)", "[[eng]]"},
+		{"select dname from dept where exists (select id from emp where deptid = did and salary > 60)", "[[eng]]"},
+		{"select dname from dept where 2 = (select count(*) from emp where deptid = did)", "[[eng]]"},
+		{"select name, dname from emp join dept on deptid = did where exists (select pid from proj where empid = id)", "[[ann eng]]"},
+		{"select dname from dept where did in (select deptid from emp where salary > budget)", "[[ops]]"},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		sort.Strings(records)
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	if n, err := d.Exec("delete from emp where not exists (select did from dept where did = deptid)"); err != nil || n != 2 {
+		t.Errorf("expected 2 records deleted, got %d, %v", n, err)
+	}
+	if n, err := d.Exec("update dept set budget = 0 where not exists (select id from emp where deptid = did)"); err != nil || n != 1 {
+		t.Errorf("expected 1 record updated, got %d, %v", n, err)
+	}
+	if got := fmt.Sprint(queryRecords(t, d, "select dname from dept where budget = 0")); got != "[[hr]]" {
+		t.Errorf("expected [[hr]], got %s", got)
+	}
+
+	// Parameters are bound along with the outer fields
+	stmt, err := d.Prepare("select name from emp where exists (select pid from proj where empid = id and pid > ?)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	rows, err := stmt.Query(100)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		names = append(names, name)
+	}
+	rows.Close()
+	if got := strings.Join(names, " "); got != "ann" {
+		t.Errorf("expected ann, got %s", got)
+	}
+
+	if temps, _ := filepath.Glob(filepath.Join(dir, "temp*")); len(temps) > 0 {
+		t.Errorf("expected the temp tables to be dropped, found %v", temps)
+	}
+}
+
+func TestSubquery_HeuristicPlanner(t *testing.T) {
+	db := openOptimizerTestDB(t)
+	planner := plan.NewPlanner(optimization.NewHeuristicQueryPlanner(db.mdm), db.iup, db.mdm)
+
+	p, err := planner.CreateQueryPlan("select name from emp where id in (select did from dept where dname = 'd3') and deptid = 3", db.tx)
+	if err != nil {
+		t.Fatalf("CreateQueryPlan failed: %v", err)
+	}
+	if explain := plan.Explain(p); !strings.Contains(explain, "index select") || !strings.Contains(explain, "IN (select did") {
+		t.Errorf("expected the subquery to be checked after the index select, got:\n%s", explain)
+	}
+	s := p.Open()
+	var names []string
+	for s.Next() {
+		names = append(names, s.GetString("name"))
+	}
+	s.Close()
+	if got := strings.Join(names, " "); got != "e3" {
+		t.Errorf("expected e3, got %s", got)
+	}
+
+	// The subquery is computed once, however many records it is evaluated on
+	data, err := parse.NewParser("select id from emp where deptid in (select did from dept where did < 3)").Query()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	p, err = optimization.NewHeuristicQueryPlanner(db.mdm).CreatePlan(data, db.tx)
+	if err != nil {
+		t.Fatalf("CreatePlan failed: %v", err)
+	}
+	sq := data.Pred().Subqueries()[0]
+	if sq.Plan() != nil {
+		t.Fatalf("expected the subquery not to be planned by the query planner")
+	}
+	subPlan, err := planner.CreateQueryPlan("select did from dept where did < 3", db.tx)
+	if err != nil {
+		t.Fatalf("CreateQueryPlan failed: %v", err)
+	}
+	counted := &countingPlan{Plan: subPlan}
+	sq.SetPlan(counted)
+	s = p.Open()
+	count := 0
+	for s.Next() {
+		count++
+	}
+	s.Close()
+	if count != 40 {
+		t.Errorf("expected 40 records, got %d", count)
+	}
+	if counted.opens != 1 {
+		t.Errorf("expected the subquery to be opened once, got %d", counted.opens)
+	}
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"centauri/internal/app/metadata"
+	"centauri/internal/app/optimization"
+	"centauri/internal/app/plan"
+	"errors"
+	"testing"
+)
+
+func TestTableNotFound_Layout(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+
+	layout, err := db.mdm.GetLayout("nosuch", db.tx)
+	if layout != nil || !errors.Is(err, metadata.ErrTableNotFound) {
+		t.Errorf("expected no layout and %v, got %v, %v", metadata.ErrTableNotFound, layout, err)
+	}
+
+	p, err := plan.NewTablePlan(db.tx, "nosuch", db.mdm)
+	if p != nil || !errors.Is(err, metadata.ErrTableNotFound) {
+		t.Errorf("NewTablePlan: expected no plan and %v, got %v, %v", metadata.ErrTableNotFound, p, err)
+	}
+	tp, err := optimization.NewTablePlanner("nosuch", nil, db.tx, db.mdm)
+	if tp != nil || !errors.Is(err, metadata.ErrTableNotFound) {
+		t.Errorf("NewTablePlanner: expected no planner and %v, got %v, %v", metadata.ErrTableNotFound, tp, err)
+	}
+}
+
+func TestTableNotFound_Planners(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	db.exec(t, "create table t (id int)")
+
+	planners := map[string]*plan.Planner{
+		"basic":     plan.NewPlanner(plan.NewBasicQueryPlanner(db.mdm), plan.NewBasicUpdatePlanner(db.mdm), db.mdm),
+		"heuristic": plan.NewPlanner(optimization.NewHeuristicQueryPlanner(db.mdm), db.iup, db.mdm),
+	}
+	for name, p := range planners {
+		for _, query := range []string{
+			"select id from nosuch",
+			"select id from t, nosuch",
+		} {
+			if _, err := p.CreateQueryPlan(query, db.tx); !errors.Is(err, metadata.ErrTableNotFound) {
+				t.Errorf("%s planner, %s: expected %v, got %v", name, query, metadata.ErrTableNotFound, err)
+			}
+		}
+		for _, stmt := range []string{
+			"insert into nosuch (id) values (1)",
+			"update nosuch set id = 2 where id = 1",
+			"delete from nosuch where id = 1",
+			"create index nosuch_id on nosuch (id)",
+		} {
+			if _, err := p.ExecuteUpdate(stmt, db.tx); !errors.Is(err, metadata.ErrTableNotFound) {
+				t.Errorf("%s planner, %s: expected %v, got %v", name, stmt, metadata.ErrTableNotFound, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"centauri/internal/app/materialize"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/tx"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestTempQuota_StopsTempTable(t *testing.T) {
+	db := openRecoveryTestDB(t, filepath.Join(t.TempDir(), "tempdb"))
+	defer db.fm.Close()
+
+	sch := schema.NewSchema()
+	sch.AddIntField("a")
+
+	tx1 := db.newTx()
+	defer tx1.Rollback()
+	blockSize := int64(tx1.BlockSize())
+	tx1.SetTempQuota(3 * blockSize)
+
+	ts := materialize.NewTempTable(tx1, sch).Open()
+	if got := tx1.TempSpaceUsed(); got != blockSize {
+		t.Errorf("expected the first block to be accounted, got %d bytes", got)
+	}
+
+	inserted := 0
+	func() {
+		defer func() {
+			r := recover()
+			err, ok := r.(error)
+			if !ok || !errors.Is(err, tx.ErrTempQuotaExceeded) {
+				t.Errorf("expected insert to panic with ErrTempQuotaExceeded, got %v", r)
+			}
+		}()
+		for {
+			ts.Insert()
+			ts.SetInt("a", inserted)
+			inserted++
+		}
+	}()
+	ts.Close()
+
+	// The statement stopped on appending its fourth block
+	if got := tx1.TempSpaceUsed(); got != 4*blockSize {
+		t.Errorf("expected %d bytes used, got %d", 4*blockSize, got)
+	}
+	if inserted == 0 {
+		t.Error("expected records to be inserted before the quota was exceeded")
+	}
+
+	// The next statement starts from nothing
+	tx1.ResetTempSpace()
+	if got := tx1.TempSpaceUsed(); got != 0 {
+		t.Errorf("expected no temp space after reset, got %d", got)
+	}
+}
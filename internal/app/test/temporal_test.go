@@ -0,0 +1,258 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"centauri/internal/app/record/schema"
+	"centauri/internal/app/types"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemporal_Constants(t *testing.T) {
+	date, err := types.ParseDate("2023-01-15")
+	if err != nil {
+		t.Fatalf("ParseDate failed: %v", err)
+	}
+	if got := *date.AsLong(); got != 19372 {
+		t.Errorf("expected 19372 days, got %d", got)
+	}
+	if got := date.String(); got != "2023-01-15" {
+		t.Errorf("expected 2023-01-15, got %s", got)
+	}
+
+	ts, err := types.ParseTimestamp("2023-01-15 10:30:00")
+	if err != nil {
+		t.Fatalf("ParseTimestamp failed: %v", err)
+	}
+	if got := ts.String(); got != "2023-01-15 10:30:00" {
+		t.Errorf("expected 2023-01-15 10:30:00, got %s", got)
+	}
+	if got := ts.Kind(); got != types.KIND_TIME {
+		t.Errorf("expected a time, got kind %d", got)
+	}
+
+	// A date equals the timestamp of its midnight
+	midnight, _ := types.ParseTimestamp("2023-01-15")
+	if !date.Equals(midnight) || date.HashCode() != midnight.HashCode() {
+		t.Errorf("expected %s to equal %s", date, midnight)
+	}
+	if date.CompareTo(ts) >= 0 || ts.CompareTo(date) <= 0 {
+		t.Errorf("expected %s before %s", date, ts)
+	}
+
+	// Times are neither numbers nor strings
+	if date.Equals(types.NewConstantLong(19372)) || date.Equals(types.NewConstantString("2023-01-15")) {
+		t.Errorf("expected %s to differ from a number or string", date)
+	}
+
+	// Timestamps convert to the day they fall on, even before 1970
+	for s, want := range map[string]string{"2023-01-15 23:59:59": "2023-01-15", "1969-12-31 12:00:00": "1969-12-31"} {
+		ts, _ := types.ParseTimestamp(s)
+		if d, err := ts.ToDate(); err != nil || d.String() != want {
+			t.Errorf("%s: expected %s, got %v, %v", s, want, d, err)
+		}
+	}
+
+	for _, s := range []string{"2023-13-01", "15/01/2023", "", "2023-01-15 25:00:00"} {
+		if _, err := types.ParseTimestamp(s); !errors.Is(err, types.ErrInvalidTime) {
+			t.Errorf("%q: expected %v, got %v", s, types.ErrInvalidTime, err)
+		}
+	}
+}
+
+func TestParser_Temporal(t *testing.T) {
+	data, err := parse.NewParser("create table event (id int, day date, at timestamp)").UpdateCmd()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	sch := data.(*parse.CreateTableData).NewSchema()
+	for field, want := range map[string]schema.FieldType{"id": schema.INTEGER, "day": schema.DATE, "at": schema.TIMESTAMP} {
+		if got := sch.DataType(field); got != want {
+			t.Errorf("%s: expected %s, got %s", field, want, got)
+		}
+	}
+
+	// Fields may still be named like the types
+	q, err := parse.NewParser("select date, now() from event where timestamp < now()").Query()
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	if got := q.String(); got != "select date, now() from event where timestamp<now()" {
+		t.Errorf("unexpected query %q", got)
+	}
+
+	for _, sql := range []string{
+		"select id from event where at < now(1)",
+		"select now( from event",
+	} {
+		if _, err := parse.NewParser(sql).Query(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestTemporal_Query(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "temporaldb")
+	d, err := db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for _, stmt := range []string{
+		"create table event (id int, name varchar(8), day date, at timestamp)",
+		"insert into event (id, name, day, at) values (1, 'launch', '2023-01-15', '2023-01-15 09:00:00')",
+		"insert into event (id, name, day, at) values (2, 'review', '2023-03-01', '2023-03-01 17:30:00'), (3, 'retro', '1969-07-20', '1969-07-20 20:17:40')",
+		"insert into event (id, name, day, at) values (4, 'draft', null, '2023-01-15')",
+		"create index event_day on event (day)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select id, day, at from event where id = 1", "[[1 2023-01-15 2023-01-15 09:00:00]]"},
+		{"select id from event where day = '2023-01-15'", "[[1]]"},
+		{"select id from event where day >= '2023-01-01'", "[[1] [2]]"},
+		{"select id from event where '2000-01-01' > day", "[[3]]"},
+		{"select id from event where at < '2023-01-15 12:00:00' and at > '2000-01-01'", "[[1] [4]]"},
+		{"select id from event where day < at", "[[1] [2] [3]]"},
+		{"select id from event where '2023-01-15' = now()", "[]"},
+		{"select id from event where day between '2023-01-01' and '2023-12-31'", "[[1] [2]]"},
+		{"select id from event where day in ('1969-07-20', '2023-03-01')", "[[2] [3]]"},
+		{"select id from event where at < now()", "[[1] [2] [3] [4]]"},
+		{"select id from event where day is null", "[[4]]"},
+		{"select max(day), min(at) from event", "[[2023-03-01 1969-07-20 20:17:40]]"},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		sort.Strings(records)
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	if got := fmt.Sprint(queryRecords(t, d, "select name from event where day is not null order by day desc")); got != "[[review] [launch] [retro]]" {
+		t.Errorf("expected [[review] [launch] [retro]], got %s", got)
+	}
+
+	// NOW() is the time the statement is read at
+	rows := queryRecords(t, d, "select now() from event where id = 1")
+	now, err := types.ParseTimestamp(strings.Trim(rows[0], "[]"))
+	if err != nil || time.Since(now.Time()) > time.Minute || time.Since(now.Time()) < -time.Minute {
+		t.Errorf("expected the current time, got %v, %v", rows, err)
+	}
+
+	// Updates store strings and times alike
+	if n, err := d.Exec("update event set day = '2024-02-29' where id = 4"); err != nil || n != 1 {
+		t.Fatalf("update: expected 1 record, got %d, %v", n, err)
+	}
+	if n, err := d.Exec("update event set at = now() where day = '2024-02-29'"); err != nil || n != 1 {
+		t.Fatalf("update: expected 1 record, got %d, %v", n, err)
+	}
+	stmt, err := d.Prepare("select id from event where at >= ?")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	for _, arg := range []any{"2024-01-01", time.Now().Add(-time.Hour)} {
+		rows, err := stmt.Query(arg)
+		if err != nil {
+			t.Fatalf("%v: query failed: %v", arg, err)
+		}
+		var ids []any
+		for rows.Next() {
+			ids = append(ids, rows.Row().Values()...)
+		}
+		rows.Close()
+		if fmt.Sprint(ids) != "[4]" {
+			t.Errorf("%v: expected [4], got %v", arg, ids)
+		}
+	}
+
+	for _, tt := range []struct {
+		stmt string
+		want error
+	}{
+		{"select id from event where day = 1", plan.ErrTypeMismatch},
+		{"select id from event where day = 'soon'", plan.ErrTypeMismatch},
+		{"select id from event where day = name", plan.ErrTypeMismatch},
+		{"select id from event where day like '2023%'", plan.ErrTypeMismatch},
+	} {
+		if _, err := d.Query(tt.stmt); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.stmt, tt.want, err)
+		}
+	}
+	for _, stmt := range []string{
+		"insert into event (id, day) values (5, 'tomorrow')",
+		"insert into event (id, at) values (5, 20230115)",
+	} {
+		if _, err := d.Exec(stmt); err == nil {
+			t.Errorf("%s: expected an error", stmt)
+		}
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The values and the index are kept on disk
+	d, err = db.Open(dir, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+	if got := fmt.Sprint(queryRecords(t, d, "select id, at from event where day = '1969-07-20'")); got != "[[3 1969-07-20 20:17:40]]" {
+		t.Errorf("expected [[3 1969-07-20 20:17:40]], got %s", got)
+	}
+	desc, err := d.DescribeTable("event")
+	if err != nil {
+		t.Fatalf("DescribeTable failed: %v", err)
+	}
+	if got := fmt.Sprint(desc.Columns[2].Type, " ", desc.Columns[3].Type); got != "date timestamp" {
+		t.Errorf("expected date timestamp, got %s", got)
+	}
+}
+
+func TestTemporal_HeuristicPlanner(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "temporaldb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	if _, err := d.Exec("create table log (id int, at timestamp)"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	if _, err := d.Exec("create index log_at on log (at)"); err != nil {
+		t.Fatalf("create index failed: %v", err)
+	}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	stmt, err := d.Prepare("insert into log (id, at) values (?, ?)")
+	if err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	for i := range 50 {
+		if _, err := stmt.Exec(i, start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	rows := queryRecords(t, d, "select id from log where at = '2023-01-02 01:00:00'")
+	if fmt.Sprint(rows) != "[[25]]" {
+		t.Errorf("expected [[25]], got %v", rows)
+	}
+	// A date stands for its midnight
+	rows = queryRecords(t, d, "select id from log where at = '2023-01-03'")
+	if fmt.Sprint(rows) != "[[48]]" {
+		t.Errorf("expected [[48]], got %v", rows)
+	}
+}
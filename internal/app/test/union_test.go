@@ -0,0 +1,152 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/internal/app/parse"
+	"centauri/internal/app/plan"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParser_Union(t *testing.T) {
+	tests := []struct {
+		sql    string
+		want   string
+		unions int
+		tables string
+	}{
+		{
+			"select id from a union select id from b",
+			"select id from a union select id from b",
+			1,
+			"[a b]",
+		},
+		{
+			"select id, name from a where id > 1 union all select no, title from b union select id, name from a order by id desc limit 3",
+			"select id, name from a where id>1 union all select no, title from b union select id, name from a order by id desc limit 3",
+			2,
+			"[a b]",
+		},
+		{
+			"select dept, count(*) from a group by dept having count(*) > 1 union all select dept, 0 from b",
+			"select dept, count(*) from a group by dept having count(*)>1 union all select dept, 0 from b",
+			1,
+			"[a b]",
+		},
+	}
+	for _, tt := range tests {
+		data, err := parse.NewParser(tt.sql).Query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		if got := data.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.sql, tt.want, got)
+		}
+		if got := len(data.Unions()); got != tt.unions {
+			t.Errorf("%s: expected %d unions, got %d", tt.sql, tt.unions, got)
+		}
+		if got := fmt.Sprint(data.ReadTables()); got != tt.tables {
+			t.Errorf("%s: expected tables %s, got %s", tt.sql, tt.tables, got)
+		}
+		// The first query doesn't take the ORDER BY and LIMIT of the whole
+		if first := data.Operands()[0]; len(first.OrderBy()) != 0 || first.Limit() != parse.NO_LIMIT || len(first.Unions()) != 0 {
+			t.Errorf("%s: expected the first operand alone, got %q", tt.sql, first)
+		}
+	}
+
+	for _, sql := range []string{
+		"select id from a union",
+		"select id from a union all",
+		"select id from a union (select id from b)",
+	} {
+		if _, err := parse.NewParser(sql).Query(); !errors.Is(err, parse.ErrSyntax) {
+			t.Errorf("%s: expected a syntax error, got %v", sql, err)
+		}
+	}
+}
+
+func TestUnion_Query(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "uniondb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table emp (id int, name varchar(5), dept int)",
+		"create table contractor (cid int, cname varchar(12), dept int)",
+		"insert into emp (id, name, dept) values (1, 'ann', 10), (2, 'bob', 20), (3, 'cid', 10)",
+		"insert into contractor (cid, cname, dept) values (7, 'bob', 20), (8, 'dorothy-anne', null), (9, 'eve', null)",
+		"create view people as select name from emp union select cname from contractor",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+		order bool // whether the query orders its records
+	}{
+		{"select name from emp union all select cname from contractor", "[[ann] [bob] [bob] [cid] [dorothy-anne] [eve]]", false},
+		{"select name from emp union select cname from contractor", "[[ann] [bob] [cid] [dorothy-anne] [eve]]", false},
+		{"select name, dept from emp union select cname, dept from contractor order by name desc", "[[eve <nil>] [dorothy-anne <nil>] [cid 10] [bob 20] [ann 10]]", true},
+		// Nulls are duplicates of each other
+		{"select dept from emp union select dept from contractor order by dept", "[[10] [20] [<nil>]]", true},
+		{"select dept from emp union all select dept from contractor order by dept limit 4", "[[10] [10] [20] [20]]", true},
+		{"select id from emp where id = 1 union select cid from contractor where cid > 8 union all select id from emp where id = 1", "[[1] [1] [9]]", false},
+		{"select dept, count(*) from emp group by dept union all select dept, cid from contractor where cid = 7", "[[10 2] [20 1] [20 7]]", false},
+		{"select name from people order by name", "[[ann] [bob] [cid] [dorothy-anne] [eve]]", true},
+		{"select name from emp where name in (select cname from contractor union select 'cid' as c from emp)", "[[bob] [cid]]", false},
+	}
+	for _, tt := range tests {
+		records := queryRecords(t, d, tt.query)
+		if !tt.order {
+			sort.Strings(records)
+		}
+		if got := fmt.Sprint(records); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.query, tt.want, got)
+		}
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  error
+	}{
+		{"select name from emp union select cid from contractor", plan.ErrTypeMismatch},
+		{"select name from emp union select nope from contractor", plan.ErrUnknownField},
+		{"select name from emp union select cname from contractor order by cname", plan.ErrUnknownField},
+	} {
+		if _, err := d.Query(tt.query); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, err)
+		}
+	}
+	if _, err := d.Query("select id, name from emp union select cid from contractor"); err == nil {
+		t.Errorf("expected queries selecting different numbers of fields to fail")
+	}
+}
+
+func TestUnion_HeuristicPlanner(t *testing.T) {
+	db := openOptimizerTestDB(t)
+
+	explained, records := planQuery(t, db, "select name from emp where id = 3 union select dname from dept where did = 2")
+	if got := strings.Join(records, ", "); got != "d2, e3" {
+		t.Errorf("expected d2, e3, got %s", got)
+	}
+	// Each query uses its own index, and duplicates are removed after sorting
+	for _, want := range []string{"distinct", "sort name", "union all", "index select emp_id", "index select dept_did"} {
+		if !strings.Contains(explained, want) {
+			t.Errorf("expected %q in plan\n%s", want, explained)
+		}
+	}
+
+	explained, records = planQuery(t, db, "select deptid from emp union all select did from dept limit 3")
+	if len(records) != 3 || strings.Contains(explained, "distinct") {
+		t.Errorf("expected 3 records without removing duplicates, got %v\n%s", records, explained)
+	}
+}
@@ -0,0 +1,209 @@
+package test
+
+import (
+	"centauri/db"
+	"centauri/dump"
+	"centauri/internal/app/index"
+	"centauri/internal/app/parse"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParser_CreateUniqueIndex(t *testing.T) {
+	for _, tc := range []struct {
+		sql    string
+		unique bool
+	}{
+		{"create index people_id on people (id)", false},
+		{"create unique index people_id on people (id)", true},
+		{"CREATE UNIQUE INDEX people_id ON people (id) USING BTREE", true},
+	} {
+		data, err := parse.NewParser(tc.sql).UpdateCmd()
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tc.sql, err)
+		}
+		if got := data.(*parse.CreateIndexData).IsUnique(); got != tc.unique {
+			t.Errorf("%s: expected unique %v, got %v", tc.sql, tc.unique, got)
+		}
+	}
+}
+
+// Returns the sorted records of a query, each as a string
+func sortedRecords(t *testing.T, d *db.DB, query string) string {
+	t.Helper()
+	records := queryRecords(t, d, query)
+	sort.Strings(records)
+	return fmt.Sprint(records)
+}
+
+func TestUniqueIndex_Enforced(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "uniquedb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table people (id int, email varchar(20), age int)",
+		"create unique index people_id on people (id)",
+		"create unique index people_email on people (email) using btree",
+		"create index people_age on people (age)",
+		"insert into people (id, email, age) values (1, 'a', 30), (2, 'b', 30), (3, null, 40), (4, null, 40)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	for _, stmt := range []string{
+		"insert into people (id, email, age) values (1, 'z', 50)",
+		"insert into people (id, email, age) values (9, 'b', 50)",
+		"update people set id = 2 where id = 1",
+		"update people set email = 'a' where id = 3",
+	} {
+		if _, err := d.Exec(stmt); !errors.Is(err, index.ErrUniqueViolation) {
+			t.Errorf("%s: expected %v, got %v", stmt, index.ErrUniqueViolation, err)
+		}
+	}
+
+	want := "[[1 a 30] [2 b 30] [3 <nil> 40] [4 <nil> 40]]"
+	if got := sortedRecords(t, d, "select id, email, age from people"); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{"select age from people where id = 1", "[[30]]"},
+		{"select id from people where email = 'b'", "[[2]]"},
+		{"select id from people where email = 'z'", "[]"},
+		{"select id from people where age = 50", "[]"},
+	} {
+		if got := sortedRecords(t, d, tc.query); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.query, tc.want, got)
+		}
+	}
+
+	// The key of a deleted record can be used again
+	for _, stmt := range []string{
+		"delete from people where id = 1",
+		"insert into people (id, email, age) values (1, 'a', 31)",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	count, err := d.BulkInsert("people", []string{"id", "email"}, func(yield func([]any) bool) {
+		if yield([]any{7, "x"}) {
+			yield([]any{7, "y"})
+		}
+	})
+	if !errors.Is(err, index.ErrUniqueViolation) {
+		t.Errorf("BulkInsert: expected %v, got %d, %v", index.ErrUniqueViolation, count, err)
+	}
+}
+
+func TestUniqueIndex_Create(t *testing.T) {
+	d, err := db.Open(filepath.Join(t.TempDir(), "uniquedb"), nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer d.Close()
+
+	for _, stmt := range []string{
+		"create table people (id int, age int)",
+		"insert into people (id, age) values (1, 30), (2, 30), (3, null), (4, null)",
+		"create unique index people_id on people (id) using btree",
+	} {
+		if _, err := d.Exec(stmt); err != nil {
+			t.Fatalf("%s failed: %v", stmt, err)
+		}
+	}
+
+	// An index that the records already break isn't created
+	for _, stmt := range []string{
+		"create unique index people_age on people (age)",
+		"create unique index people_age on people (age) using btree",
+	} {
+		if _, err := d.Exec(stmt); !errors.Is(err, index.ErrUniqueViolation) {
+			t.Errorf("%s: expected %v, got %v", stmt, index.ErrUniqueViolation, err)
+		}
+	}
+	if _, err := d.Exec("create index people_age on people (age)"); err != nil {
+		t.Fatalf("create index failed: %v", err)
+	}
+
+	desc, err := d.DescribeTable("people")
+	if err != nil {
+		t.Fatalf("DescribeTable failed: %v", err)
+	}
+	if len(desc.Indexes) != 2 || desc.Indexes[0].Name != "people_age" || desc.Indexes[0].Unique || !desc.Indexes[1].Unique {
+		t.Errorf("expected people_age and a unique people_id, got %+v", desc.Indexes)
+	}
+
+	var out strings.Builder
+	if err := dump.Dump(d, &out, nil); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+	for _, want := range []string{
+		"create unique index people_id on people (id) using btree;",
+		"create index people_age on people (age);",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected the dump to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestUniqueIndex_StatementUndone(t *testing.T) {
+	db := openIndexPlannerTestDB(t)
+	for _, stmt := range []string{
+		"create table people (id int, email varchar(20), age int)",
+		"create unique index people_id on people (id)",
+		"create unique index people_email on people (email) using btree",
+		"create index people_age on people (age)",
+		"insert into people (id, email, age) values (1, 'a', 30), (2, 'b', 30), (3, null, 40), (4, null, 40)",
+	} {
+		db.exec(t, stmt)
+	}
+
+	// The transaction goes on after a statement is turned down,
+	// without the changes the statement made before
+	for _, stmt := range []string{
+		"insert into people (id, email, age) values (5, 'e', 50), (6, 'f', 60), (5, 'g', 70)",
+		"insert into people (id, email, age) values (7, 'h', 50), (8, 'h', 60)",
+		"update people set id = 10 where age = 30",
+		"update people set email = 'c' where age = 40",
+	} {
+		if _, err := db.tryExec(t, stmt); !errors.Is(err, index.ErrUniqueViolation) {
+			t.Errorf("%s: expected %v, got %v", stmt, index.ErrUniqueViolation, err)
+		}
+	}
+	if count := db.exec(t, "update people set id = id + 10 where age = 40"); count != 2 {
+		t.Errorf("expected 2 records updated, got %d", count)
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{"select id from people", "[1 13 14 2]"},
+		{"select id from people where id = 13", "[13]"},
+		{"select id from people where id = 5", "[]"},
+		{"select id from people where id = 10", "[]"},
+		{"select id from people where email = 'a'", "[1]"},
+		{"select id from people where email = 'c'", "[]"},
+		{"select id from people where email = 'h'", "[]"},
+		{"select id from people where age = 30", "[1 2]"},
+		{"select id from people where age = 50", "[]"},
+	} {
+		if _, records := planQuery(t, db, tc.query); fmt.Sprint(records) != tc.want {
+			t.Errorf("%s: expected %s, got %v", tc.query, tc.want, records)
+		}
+	}
+}
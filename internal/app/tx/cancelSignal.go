@@ -0,0 +1,63 @@
+package tx
+
+import (
+	"errors"
+	"sync"
+)
+
+// Returned, or raised as a panic by scans, when the statement
+// a transaction is running has been cancelled
+var ErrQueryCancelled = errors.New("query cancelled")
+
+// Signals that the statement running in a transaction should stop.
+// It is shared by the transaction and its concurrency manager, so a
+// cancellation also wakes up a lock wait.
+type cancelSignal struct {
+	mu sync.Mutex
+	ch chan struct{} // closed once cancelled
+}
+
+func newCancelSignal() *cancelSignal {
+	return &cancelSignal{ch: make(chan struct{})}
+}
+
+// Marks the statement as cancelled. Safe to call more than once.
+func (cs *cancelSignal) cancel() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	select {
+	case <-cs.ch:
+	default:
+		close(cs.ch)
+	}
+}
+
+// Returns a channel that is closed once the statement is cancelled
+func (cs *cancelSignal) done() <-chan struct{} {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.ch
+}
+
+func (cs *cancelSignal) cancelled() bool {
+	select {
+	case <-cs.done():
+		return true
+	default:
+		return false
+	}
+}
+
+// Clears a cancellation so the next statement can run
+func (cs *cancelSignal) reset() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	select {
+	case <-cs.ch:
+		cs.ch = make(chan struct{})
+	default:
+	}
+}
@@ -1,12 +1,11 @@
 package tx
 
 import (
+	"centauri/internal/app/file"
 	"centauri/internal/app/log"
-	"encoding/binary"
 )
 
 type CheckPointRecord struct {
-	LogRecord
 }
 
 func NewCheckpointRecord() *CheckPointRecord {
@@ -26,7 +25,12 @@ func (cp *CheckPointRecord) TxNumber() int {
 
 // Defines how to reverse a CHECKPOINT operation
 // Does nothing because a checkpoint record contains no undo information.
-func (cp *CheckPointRecord) undo(tx *Transaction) {}
+func (cp *CheckPointRecord) Undo(tx *Transaction) {}
+
+// Does nothing because a checkpoint record changes no values
+func (cp *CheckPointRecord) Redo(tx *Transaction) error {
+	return nil
+}
 
 func (cp *CheckPointRecord) String() string {
 	return "<CHECKPOINT>"
@@ -43,9 +47,10 @@ func writeToLogCheckpointRecord(lm *log.LogManager, txNum int) int {
 	// Create a byte slice with capacity for two 32-bit integers
 	rec := make([]byte, 8)
 
-	// Convert integers to bytes and write them to the slice
-	binary.LittleEndian.PutUint32(rec[0:4], uint32(CHECKPOINT))
-	binary.LittleEndian.PutUint32(rec[4:8], uint32(txNum))
+	// Write the integers in the same byte order the records are read with
+	p := file.NewPageFromBytes(rec)
+	p.SetInt(0, int32(CHECKPOINT))
+	p.SetInt(4, int32(txNum))
 
 	// Append to log and return position
 	lsn, _ := lm.Append(rec)
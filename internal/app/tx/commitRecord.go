@@ -3,26 +3,32 @@ package tx
 import (
 	"centauri/internal/app/file"
 	"centauri/internal/app/log"
-	"encoding/binary"
 	"fmt"
+	"time"
 )
 
 type CommitRecord struct {
-	LogRecord
 	txNum int
+	time  time.Time // when the transaction committed, zero if not logged
 }
 
-func NewCommitRecord(p *file.Page) *StartRecord {
+func NewCommitRecord(p *file.Page) *CommitRecord {
 	tPos := 4
-
-	return &StartRecord{
+	cr := &CommitRecord{
 		txNum: int(p.GetInt(tPos)),
 	}
+
+	// Records written by older versions end with the transaction number
+	timePos := tPos + 4
+	if len(p.Contents()) >= timePos+8 {
+		cr.time = time.Unix(0, p.GetLong(timePos))
+	}
+	return cr
 }
 
 // Returns the operation type constant for COMMIT operations
 // This helps identify the record type when reading from the log.
-func (cr *CommitRecord) Op() int {
+func (cr *CommitRecord) Op() LogRecordType {
 	return COMMIT
 }
 
@@ -30,28 +36,42 @@ func (cr *CommitRecord) TxNumber() int {
 	return cr.txNum
 }
 
+// Returns when the transaction committed, or the zero time
+// for records written by versions that didn't log it
+func (cr *CommitRecord) Time() time.Time {
+	return cr.time
+}
+
 // Defines how to reverse a COMMIT operation
-// Does nothing because a start record contains no undo information.
-func (cr *CommitRecord) undo(tx *Transaction) {}
+// Does nothing because a commit record contains no undo information.
+func (cr *CommitRecord) Undo(tx *Transaction) {}
+
+// Does nothing because a commit record changes no values
+func (cr *CommitRecord) Redo(tx *Transaction) error {
+	return nil
+}
 
 func (cr *CommitRecord) String() string {
-	return fmt.Sprintf("<START %t>", cr.txNum)
+	return fmt.Sprintf("<COMMIT %d>", cr.txNum)
 }
 
 // Writes a commit record to the transaction log.
-// The record is written as 8 bytes:
+// The record is written as 16 bytes:
 //   - First 4 bytes: COMMIT operation code
-//   - Last 4 bytes:  Transaction number
+//   - Next 4 bytes:  Transaction number
+//   - Last 8 bytes:  Commit time in nanoseconds since the Unix epoch
 //
 // Returns:
 //   - LSN (Log sequence number) of the written record
 func writeToLogCommitRecord(lm *log.LogManager, txNum int) int {
-	// Create a byte slice with capacity for two 32-bit integers
-	rec := make([]byte, 8)
+	// Create a byte slice with capacity for two 32-bit integers and the time
+	rec := make([]byte, 16)
 
-	// Convert integers to bytes and write them to the slice
-	binary.LittleEndian.PutUint32(rec[0:4], uint32(COMMIT))
-	binary.LittleEndian.PutUint32(rec[4:8], uint32(txNum))
+	// Write the integers in the same byte order the records are read with
+	p := file.NewPageFromBytes(rec)
+	p.SetInt(0, COMMIT)
+	p.SetInt(4, int32(txNum))
+	p.SetLong(8, time.Now().UnixNano())
 
 	// Append to log and return position
 	lsn, _ := lm.Append(rec)
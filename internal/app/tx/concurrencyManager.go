@@ -3,28 +3,87 @@ package tx
 import (
 	"centauri/internal/app/file"
 	"sync"
+	"time"
 )
 
 const shared string = "S"    // represents a shared (read) lock
 const exclusive string = "X" // represents an exclusive (write) lock
 
+// The isolation level of a transaction, which decides how long it holds its
+// shared locks. Exclusive locks are always held until the transaction ends.
+type IsolationLevel int
+
+const (
+	// Shared locks are held until the transaction ends, including the one on
+	// the end of a file that keeps other transactions from appending blocks
+	// the transaction would see if it read the file again
+	SERIALIZABLE IsolationLevel = iota
+	// Shared locks on blocks are held until the transaction ends, but the one
+	// on the end of a file is released once the size is read, so reading a
+	// file again may find records in blocks appended since
+	REPEATABLE_READ
+	// Shared locks are released as soon as the block is read. A read still
+	// waits for the writers of the block to finish, so it sees only committed
+	// records, but reading the block again may see newer ones.
+	READ_COMMITTED
+	// Blocks are read without shared locks, as they were when the transaction
+	// started, so reads neither wait for writers nor keep them waiting.
+	// Writes still take exclusive locks, and fail with ErrSerializationFailure
+	// on a block changed by a transaction the snapshot doesn't see. The
+	// versions the snapshot reads are kept in memory, so it doesn't survive
+	// a restart, and it fails with ErrSnapshotTooOld if they are discarded.
+	SNAPSHOT
+)
+
+// Returns the name of the isolation level as written in SQL
+func (level IsolationLevel) String() string {
+	switch level {
+	case REPEATABLE_READ:
+		return "repeatable read"
+	case READ_COMMITTED:
+		return "read committed"
+	case SNAPSHOT:
+		return "snapshot"
+	default:
+		return "serializable"
+	}
+}
+
 // Handles transaction-level concurrency control.
 // Each transaction has its own concurrencyManager instance that tracks
 // which locks the transaction currently holds and coordinates with
 // the global lock table for lock acquistion and release.
+// Its isolation level decides which shared locks are kept, see IsolationLevel.
 type ConcurrencyManager struct {
 	locks     map[file.BlockID]string // Tracks the types of locks this transaction holds on each block
 	locktable *LockTable              // Global lock manager shared by all transactions, using pointer ensures all transactions refer to the same instance
+	txnum     int64                   // The transaction the locks are held for
+	level     IsolationLevel          // How long shared locks are held
+	timeout   time.Duration           // How long to wait for a lock before aborting
+	cancel    *cancelSignal           // Ends lock waits when the running statement is cancelled
 	mu        sync.RWMutex            // protects concurrent access to the locks map
 }
 
-func NewConcurrencyManager(lt *LockTable) *ConcurrencyManager {
+func NewConcurrencyManager(lt *LockTable, txnum int64, level IsolationLevel) *ConcurrencyManager {
 	return &ConcurrencyManager{
 		locks:     make(map[file.BlockID]string),
 		locktable: lt,
+		txnum:     txnum,
+		level:     level,
+		timeout:   lt.timeout(),
+		cancel:    newCancelSignal(),
 	}
 }
 
+// Sets how long this transaction waits for a lock before aborting,
+// NOWAIT for not waiting at all
+func (cm *ConcurrencyManager) SetLockTimeout(timeout time.Duration) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.timeout = timeout
+}
+
 // Obtains a shared lock on the specified block.
 // If the transaction does`nt already have any lock on the block,
 // it requests one from the global lock table and records it locally.
@@ -35,9 +94,14 @@ func (cm *ConcurrencyManager) SLock(block file.BlockID) error {
 	// Check if we already have any lock on this block
 	if _, exists := cm.locks[block]; !exists {
 		// Request shared lock from global lock table
-		if err := cm.locktable.SLock(&block); err != nil {
+		if err := cm.locktable.sLock(&block, cm.txnum, cm.timeout, cm.cancel.done()); err != nil {
 			return err
 		}
+		if !cm.holdsSLock(block) {
+			// Waiting for the lock was enough to let the writers finish
+			cm.locktable.unlock(&block, cm.txnum)
+			return nil
+		}
 		// Record the lock in our local map
 		cm.locks[block] = shared
 	}
@@ -45,6 +109,41 @@ func (cm *ConcurrencyManager) SLock(block file.BlockID) error {
 	return nil
 }
 
+// Obtains a shared lock on the block for a single read. The returned
+// function must be called once the value has been read: it releases the
+// lock unless the lock is held until the transaction ends at the
+// transaction's isolation level, so that at READ_COMMITTED no writer
+// changes the block while the value is copied out of it.
+func (cm *ConcurrencyManager) SLockRead(block file.BlockID) (func(), error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if _, exists := cm.locks[block]; exists {
+		return func() {}, nil
+	}
+	if err := cm.locktable.sLock(&block, cm.txnum, cm.timeout, cm.cancel.done()); err != nil {
+		return nil, err
+	}
+	if cm.holdsSLock(block) {
+		cm.locks[block] = shared
+		return func() {}, nil
+	}
+	return func() { cm.locktable.unlock(&block, cm.txnum) }, nil
+}
+
+// Reports whether a shared lock on the block is held until the
+// transaction ends at the transaction's isolation level
+func (cm *ConcurrencyManager) holdsSLock(block file.BlockID) bool {
+	switch cm.level {
+	case READ_COMMITTED:
+		return false
+	case REPEATABLE_READ:
+		return block.Number() != EndOfFile
+	default:
+		return true
+	}
+}
+
 // Obtains an exclusive lock on the specified block.
 // If the transaction does`nt have an exclusive lock already:
 // 1. First obtains a shared lock (if necessary)
@@ -57,14 +156,14 @@ func (cm *ConcurrencyManager) XLock(block file.BlockID) error {
 	if !cm.hasXLock(block) {
 		// First get a shared lock if we dont have any
 		if _, exists := cm.locks[block]; !exists {
-			if err := cm.locktable.SLock(&block); err != nil {
+			if err := cm.locktable.sLock(&block, cm.txnum, cm.timeout, cm.cancel.done()); err != nil {
 				return err
 			}
 			cm.locks[block] = shared
 		}
 
 		// Now upgrade to exclusive lock
-		if err := cm.locktable.XLock(&block); err != nil {
+		if err := cm.locktable.upgrade(&block, cm.txnum, cm.timeout, cm.cancel.done()); err != nil {
 			return err
 		}
 
@@ -82,13 +181,21 @@ func (cm *ConcurrencyManager) Release() {
 
 	// Release each lock in the global lock table
 	for block := range cm.locks {
-		cm.locktable.Unlock(&block)
+		cm.locktable.unlock(&block, cm.txnum)
 	}
 
 	// Clear out our local lock tracking
 	clear(cm.locks)
 }
 
+// Reports whether the transaction holds an exclusive lock on the block
+func (cm *ConcurrencyManager) xLocked(block file.BlockID) bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.hasXLock(block)
+}
+
 // Checks if the transaction currently holds an
 // exclusive lock on the specified lock.
 func (cm *ConcurrencyManager) hasXLock(block file.BlockID) bool {
@@ -0,0 +1,158 @@
+package tx
+
+import (
+	"centauri/internal/app/file"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Returned when the state of the database is asked for at a point
+// that precedes how far back the history in the log may be read
+var ErrSnapshotTooOld = errors.New("snapshot is older than the history retention")
+
+// A past point in the history of the database. The snapshot at a point
+// holds the changes of the transactions committed by then: those whose
+// COMMIT record has an LSN up to LSN if it is set, and otherwise those
+// that committed at or before Time.
+type HistoryPoint struct {
+	LSN  int
+	Time time.Time
+}
+
+func (hp HistoryPoint) String() string {
+	if hp.LSN > 0 {
+		return fmt.Sprintf("lsn %d", hp.LSN)
+	}
+	return hp.Time.Format(time.RFC3339Nano)
+}
+
+// Changes whose previous value can be restored in a copy of their block
+type pageUndoer interface {
+	LogRecord
+	Block() *file.BlockID
+	UndoPage(p *file.Page)
+}
+
+// Appends the blocks of file src, as they were in the snapshot at the point,
+// to file dest, which should be the file of a temp table laid out like src.
+// The blocks are the current ones with the changes of the transactions that
+// hadn't committed by then undone, so changes made without logging, such as
+// bulk loads that aren't logged, are part of every snapshot.
+// Fails with ErrSnapshotTooOld if the point precedes the log's retention.
+func (tx *Transaction) CopyAsOf(src string, dest string, point HistoryPoint) error {
+	// Lock the blocks before reading the log, so that
+	// every change made to them is in the part that is read
+	size, err := tx.Size(src)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < size; i++ {
+		if err := tx.cm.SLock(*file.NewBlockID(src, i)); err != nil {
+			return err
+		}
+	}
+
+	undos, err := tx.historyUndos(src, point)
+	if err != nil {
+		return err
+	}
+
+	page := file.NewPage(tx.fm.FileBlockSize(src))
+	for i := 0; i < size; i++ {
+		block := file.NewBlockID(src, i)
+		if err := tx.myBuffers.Pin(*block); err != nil {
+			return err
+		}
+		buff, err := tx.myBuffers.GetBuffer(*block)
+		if err != nil {
+			return err
+		}
+		copy(page.Contents(), buff.Contents().Contents())
+		tx.Unpin(block)
+
+		// The changes are listed newest first, so each value ends up
+		// as it was before the earliest change that is undone
+		for _, u := range undos[i] {
+			u.UndoPage(page)
+		}
+
+		destBlock, err := tx.fm.Append(dest)
+		if err != nil {
+			return err
+		}
+		tx.AddTempSpace(int64(tx.fm.FileBlockSize(dest)))
+		if err := tx.fm.Write(destBlock, page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reads the log backwards and returns, by block number, the changes to
+// file src that aren't part of the snapshot at the point, newest first.
+// The changes of transactions that rolled back are left out, since the
+// rollback undid them in the blocks already.
+// Reading stops at a checkpoint preceding the point, since no transaction
+// was running then, or else at the start of the log.
+func (tx *Transaction) historyUndos(src string, point HistoryPoint) (map[int][]pageUndoer, error) {
+	var cutoff time.Time
+	if retention := tx.lm.Retention(); retention > 0 {
+		cutoff = time.Now().Add(-retention)
+		if point.LSN == 0 && point.Time.Before(cutoff) {
+			return nil, fmt.Errorf("%w: %v is more than %v ago", ErrSnapshotTooOld, point.Time, retention)
+		}
+	}
+
+	iter, err := tx.lm.Iterator()
+	if err != nil {
+		return nil, err
+	}
+
+	// The LSN of a point in time is the one of the last commit by then
+	pointLSN := point.LSN
+	committed := make(map[int]bool)  // whether each finished transaction is part of the snapshot
+	rolledBack := make(map[int]bool) // transactions whose changes are undone in the blocks already
+	undos := make(map[int][]pageUndoer)
+
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		record := CreateLogRecord(bytes)
+		if record == nil {
+			continue
+		}
+		lsn := iter.LSN()
+		reached := pointLSN > 0 && lsn <= pointLSN
+
+		switch record.Op() {
+		case CHECKPOINT:
+			if reached {
+				return undos, nil
+			}
+
+		case COMMIT:
+			commitTime := record.(*CommitRecord).Time()
+			if !reached && pointLSN == 0 && !commitTime.After(point.Time) {
+				pointLSN = lsn
+				reached = true
+			}
+			if !reached && !commitTime.IsZero() && commitTime.Before(cutoff) {
+				return nil, fmt.Errorf("%w: LSN %d precedes a commit more than %v ago", ErrSnapshotTooOld, point.LSN, tx.lm.Retention())
+			}
+			committed[record.TxNumber()] = reached
+
+		case ROLLBACK:
+			rolledBack[record.TxNumber()] = true
+
+		case SETINT, SETSTRING, SETLONG:
+			change := record.(pageUndoer)
+			if !committed[record.TxNumber()] && !rolledBack[record.TxNumber()] && change.Block().FileName() == src {
+				undos[change.Block().Number()] = append(undos[change.Block().Number()], change)
+			}
+		}
+	}
+	return undos, nil
+}
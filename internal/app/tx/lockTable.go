@@ -3,197 +3,346 @@ package tx
 import (
 	"centauri/internal/app/file"
 	"errors"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Indicates that a lock acquistion failed due to timeout
 var LockAbortError = errors.New("lock acquistion timed out")
 
-// Defines the maximum time to wait for a lock
+// Returned instead of waiting for a lock held by another transaction
+// when the lock timeout is NOWAIT
+var ErrLockNotAvailable = errors.New("lock not available")
+
+// Defines the default maximum time to wait for a lock
 const MaxWaitTime = 10 * time.Second
 
+// A lock timeout that fails with ErrLockNotAvailable right away rather than
+// wait, so that interactive sessions never hang on another's locks
+const NOWAIT time.Duration = 0
+
+// The number of parts the lock table is split into, each with its own mutex
+const LOCK_SHARDS = 16
+
+// The maximum time to wait for a lock, used by lock tables created afterwards
+var lockTimeout atomic.Int64
+
+func init() {
+	lockTimeout.Store(int64(MaxWaitTime))
+}
+
+// Sets how long transactions wait for a lock before aborting with LockAbortError,
+// for the databases whose lock tables are created afterwards. SetLockTimeoutFor
+// sets it for a database that is already open.
+func SetLockTimeout(timeout time.Duration) {
+	lockTimeout.Store(int64(timeout))
+}
+
 // Manages locks on blocks for concurrent transactions
 // - Negative values (-1) indicate an exclusive lock (XLock)
 // - Positive values (>0) indicate the number of shared locks (SLock)
 // - Zero indicates no locks
+//
+// Blocks are keyed by value, so any two BlockIDs naming the same block
+// share its lock. The blocks are spread over shards by their hash code, so
+// transactions locking different blocks seldom wait on the same mutex.
+//
+// The table knows which transactions hold and wait for each lock, and a
+// transaction whose wait would close a cycle in the wait-for graph fails
+// right away with DeadlockError instead of waiting until it times out.
+// The transaction asking for the lock is the victim. Locks taken through the
+// exported methods belong to no transaction and are left out of the graph.
 type LockTable struct {
-	locks map[*file.BlockID]int
-	mu    sync.Mutex // Protects the locks map and serves as mutex for the condition variable
-	cond  *sync.Cond // For wait/notify system
+	shards      [LOCK_SHARDS]*lockShard
+	graph       *waitForGraph // Which transactions wait for which others
+	maxWaitTime atomic.Int64  // Maximum time to wait for a lock, as a time.Duration
 }
 
-func NewLockTable() *LockTable {
-	lt := &LockTable{
-		locks: make(map[*file.BlockID]int),
-	}
-	lt.cond = sync.NewCond(&lt.mu)
-	return lt
+// Holds the locks of the blocks whose hash code falls in the shard.
+// Its mutex is taken before the wait-for graph's, never after.
+type lockShard struct {
+	mu       sync.Mutex                      // Protects the fields below
+	locks    map[file.BlockID]int            // The lock value of each locked block
+	holders  map[file.BlockID]map[int64]bool // The transactions holding a lock on each block
+	waiters  map[file.BlockID]map[int64]bool // The transactions waiting for a lock on each block, true if for an exclusive one
+	released chan struct{}                   // Closed, then replaced, whenever a block of the shard is unlocked
 }
 
-// Acquires a shared lock on the specified block. If an exclusive lock exists, the goroutine will wait until
-// the lock is released or MaxWaitTime is exceeded.
-func (lt *LockTable) SLock(block *file.BlockID) error {
-	// Acquire the lock table's mutex to ensure thread-safe access
-	lt.mu.Lock()
-	// Ensure mutex is released when function exits
-	defer lt.mu.Unlock()
+// The lock table of each database, keyed by the file manager of its files
+var lockTables sync.Map
 
-	startTime := time.Now()
+// Returns the lock table shared by the transactions of the database whose
+// files fm manages. Blocks are named relative to the database directory,
+// so each database needs a table of its own.
+func lockTableFor(fm *file.FileManager) *LockTable {
+	if lt, ok := lockTables.Load(fm); ok {
+		return lt.(*LockTable)
+	}
+	lt, _ := lockTables.LoadOrStore(fm, NewLockTable())
+	return lt.(*LockTable)
+}
 
-	// Wait if there's an exclusive lock on the block
-	for lt.hasXLock(block) {
-		// Check if we've waited too long
-		if time.Since(startTime) >= MaxWaitTime {
-			return LockAbortError
+// Sets how long the transactions of the database whose files fm manages wait
+// for a lock, unless they set a timeout of their own. Transactions already
+// running keep the timeout they started with.
+func SetLockTimeoutFor(fm *file.FileManager, timeout time.Duration) {
+	lockTableFor(fm).SetMaxWaitTime(timeout)
+}
+
+func NewLockTable() *LockTable {
+	lt := &LockTable{
+		graph: newWaitForGraph(),
+	}
+	lt.maxWaitTime.Store(lockTimeout.Load())
+	for i := range lt.shards {
+		lt.shards[i] = &lockShard{
+			locks:    make(map[file.BlockID]int),
+			holders:  make(map[file.BlockID]map[int64]bool),
+			waiters:  make(map[file.BlockID]map[int64]bool),
+			released: make(chan struct{}),
 		}
+	}
+	return lt
+}
 
-		// Set a timeout for this wait iteration
-		remainingTime := MaxWaitTime - time.Since(startTime)
-
-		// Create a channel to signal when the condition variable is notified
-		waitCh := make(chan struct{})
-
-		// Start a goroutine to wait on the condition variable
-		// This allows us to implement a timeout mechanism while waiting
-		go func() {
-			// Wait for notification from another theread releasing a lock
-			// This atomically releases the mutex and blocks until signaled
-			lt.cond.L.Lock()
-			lt.cond.Wait()
-			lt.cond.L.Unlock()
-			// Signal the main goroutine that we've been woken up
-			close(waitCh)
-		}()
+// Sets the maximum time the exported methods and the transactions created
+// afterwards wait for a lock. NOWAIT makes them fail right away instead.
+func (lt *LockTable) SetMaxWaitTime(timeout time.Duration) {
+	lt.maxWaitTime.Store(int64(timeout))
+}
 
-		// Temporarily release the mutex while waiting
-		// This allows other threads to acquire and release locks
-		lt.mu.Unlock()
+// Returns the maximum time to wait for a lock
+func (lt *LockTable) timeout() time.Duration {
+	return time.Duration(lt.maxWaitTime.Load())
+}
 
-		// Wait either for a signal from the condition variable or a timeout
-		select {
-		case <-waitCh:
-			// The condition variable was signaled, reacquire the mutex to check conditions again
-			// lt.mu.Lock()
-		case <-time.After(remainingTime):
-			// Max wait time exceeded, reacquire the mutex and return an error
-			lt.mu.Lock()
-			return LockAbortError
-		}
+// Returns the shard holding the lock of the block
+func (lt *LockTable) shard(block *file.BlockID) *lockShard {
+	return lt.shards[uint(block.HashCode())%LOCK_SHARDS]
+}
 
-		lt.mu.Lock()
-	}
+// Acquires a shared lock on the specified block. If an exclusive lock exists, the goroutine will wait until
+// the lock is released or MaxWaitTime is exceeded.
+func (lt *LockTable) SLock(block *file.BlockID) error {
+	return lt.sLock(block, 0, lt.timeout(), nil)
+}
 
-	// Check if we still have an XLock after waiting
-	if lt.hasXLock(block) {
-		return LockAbortError
+// Acquires a shared lock for the transaction txnum, waiting at most timeout for
+// an exclusive lock to be released. The wait ends with ErrQueryCancelled once
+// done is closed. A txnum of 0 is no transaction.
+func (lt *LockTable) sLock(block *file.BlockID, txnum int64, timeout time.Duration, done <-chan struct{}) error {
+	s := lt.shard(block)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Wait while there's an exclusive lock on the block
+	blocked := func() bool { return s.locks[*block] < 0 }
+	if err := lt.waitWhile(s, block, txnum, false, blocked, timeout, done); err != nil {
+		return err
 	}
 
 	// Grant the shared lock by incrementing the lock count
-	val := lt.getLockVal(block)
-	lt.locks[block] = val + 1
+	s.locks[*block]++
+	lt.granted(s, block, txnum, false)
 	return nil
 }
 
 func (lt *LockTable) XLock(block *file.BlockID) error {
-	lt.mu.Lock()
-	defer lt.mu.Unlock()
-
-	startTime := time.Now()
-
-	// Wait if there are multiple shared locks
-	for lt.hasAnyLock(block) && !lt.waitingTooLong(startTime) {
-		//  Wait with a timeout
-		waitCh := make(chan struct{})
+	return lt.xLock(block, 0, lt.timeout(), nil)
+}
 
-		go func() {
-			lt.cond.L.Lock()
-			lt.cond.Wait() // Atomically releases lock and blocks until signaled
-			lt.cond.L.Unlock()
-			close(waitCh)
-		}()
+// Acquires an exclusive lock for the transaction txnum, waiting at most timeout
+// for other locks to be released. The wait ends with ErrQueryCancelled once
+// done is closed.
+func (lt *LockTable) xLock(block *file.BlockID, txnum int64, timeout time.Duration, done <-chan struct{}) error {
+	s := lt.shard(block)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blocked := func() bool { return s.locks[*block] != 0 }
+	if err := lt.waitWhile(s, block, txnum, true, blocked, timeout, done); err != nil {
+		return err
+	}
 
-		// Temporarily release the mutex while waiting
-		lt.mu.Unlock()
+	s.locks[*block] = -1
+	lt.granted(s, block, txnum, true)
+	return nil
+}
 
-		// Wait for signal or timeout
-		select {
-		case <-waitCh:
-			lt.mu.Lock()
-		case <-time.After(MaxWaitTime - time.Since(startTime)):
-			// TImeout occured
-			lt.mu.Lock()
-			return LockAbortError
-		}
-	}
+// Upgrades the caller's shared lock on the block to an exclusive lock.
+// Unlike XLock, the caller's own shared lock doesn't conflict: it waits
+// only while other transactions hold shared locks on the block.
+func (lt *LockTable) Upgrade(block *file.BlockID) error {
+	return lt.upgrade(block, 0, lt.timeout(), nil)
+}
 
-	// Check if we still have other locks after waiting
-	if lt.hasOtherSLocks(block) {
-		return LockAbortError
+// Upgrades the shared lock of the transaction txnum, waiting at most timeout for
+// other shared locks to be released. The wait ends with ErrQueryCancelled once
+// done is closed.
+func (lt *LockTable) upgrade(block *file.BlockID, txnum int64, timeout time.Duration, done <-chan struct{}) error {
+	s := lt.shard(block)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blocked := func() bool { return s.locks[*block] > 1 }
+	if err := lt.waitWhile(s, block, txnum, true, blocked, timeout, done); err != nil {
+		return err
 	}
 
-	lt.locks[block] = -1
+	s.locks[*block] = -1
+	lt.granted(s, block, txnum, true)
 	return nil
 }
 
-// Releases a lock on the specified block and notifies waiting goroutines if this was the last lock on the block
+// Releases a lock on the specified block and notifies the waiting goroutines
 func (lt *LockTable) Unlock(block *file.BlockID) {
-	lt.mu.Lock()
-	defer lt.mu.Unlock()
+	lt.unlock(block, 0)
+}
+
+// Releases the lock of the transaction txnum on the block
+func (lt *LockTable) unlock(block *file.BlockID, txnum int64) {
+	s := lt.shard(block)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	val := lt.getLockVal(block)
+	if txnum != 0 {
+		delete(s.holders[*block], txnum)
+		if len(s.holders[*block]) == 0 {
+			delete(s.holders, *block)
+		}
+		for waiter := range s.waiters[*block] {
+			lt.graph.removeEdge(waiter, txnum)
+		}
+	}
+
+	val := s.locks[*block]
 
 	if val > 1 {
 		// Decrement the shared lock count
-		lt.locks[block] = val - 1
+		s.locks[*block] = val - 1
 	} else if val != 0 {
 		// Remove the lock entry entirely
-		delete(lt.locks, block)
-		// Notify all waiting goroutines
-		lt.cond.Broadcast()
+		delete(s.locks, *block)
+	} else {
+		return
 	}
-}
 
-// Checks if the block has an exclusive lock
-func (lt *LockTable) hasXLock(block *file.BlockID) bool {
-	return lt.getLockVal(block) < 0
+	// Wake up the goroutines waiting on the shard, an upgrade
+	// may be waiting for the last other shared lock to go
+	close(s.released)
+	s.released = make(chan struct{})
 }
 
-// Checks if the block has multiple shared locks
-func (lt *LockTable) hasOtherSLocks(block *file.BlockID) bool {
-	return lt.getLockVal(block) > 1
-}
+// Records that the transaction txnum was granted a lock on the block. The
+// transactions waiting for the block now wait for it too, unless both its
+// lock and the one they wait for are shared.
+func (lt *LockTable) granted(s *lockShard, block *file.BlockID, txnum int64, exclusive bool) {
+	if txnum == 0 {
+		return
+	}
+	if s.holders[*block] == nil {
+		s.holders[*block] = make(map[int64]bool)
+	}
+	s.holders[*block][txnum] = true
 
-func (lt *LockTable) hasAnyLock(block *file.BlockID) bool {
-	val, exists := lt.locks[block]
-	return exists && val != 0
+	for waiter, wantsExclusive := range s.waiters[*block] {
+		if waiter != txnum && (exclusive || wantsExclusive) {
+			lt.graph.addEdge(waiter, txnum)
+		}
+	}
 }
 
-func (lt *LockTable) waitingTooLong(startTime time.Time) bool {
-	return time.Since(startTime) > MaxWaitTime
-}
+// Waits while blocked reports true, for at most timeout. The shard's mutex
+// must be held; it is released during the wait and held again on return.
+// Returns LockAbortError once timeout passes, and ErrQueryCancelled once
+// done is closed. A timeout of NOWAIT returns ErrLockNotAvailable without
+// waiting. Unless txnum is 0, the transaction is recorded as waiting for the
+// other holders of the block, and DeadlockError is returned if they wait
+// for it in turn.
+func (lt *LockTable) waitWhile(s *lockShard, block *file.BlockID, txnum int64, exclusive bool, blocked func() bool, timeout time.Duration, done <-chan struct{}) error {
+	if !blocked() {
+		return nil
+	}
+	if timeout <= NOWAIT {
+		return fmt.Errorf("%w on block %v", ErrLockNotAvailable, block)
+	}
+
+	if txnum != 0 {
+		if s.waiters[*block] == nil {
+			s.waiters[*block] = make(map[int64]bool)
+		}
+		s.waiters[*block][txnum] = exclusive
+		defer func() {
+			delete(s.waiters[*block], txnum)
+			if len(s.waiters[*block]) == 0 {
+				delete(s.waiters, *block)
+			}
+			lt.graph.stopWaiting(txnum)
+		}()
+	}
 
-func (lt *LockTable) getLockVal(block *file.BlockID) int {
-	val, exists := lt.locks[block]
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
-	if !exists {
-		return 0
+	for blocked() {
+		if txnum != 0 {
+			// The holders may have changed since the last wait
+			if cycle := lt.graph.wait(txnum, s.otherHolders(block, txnum)); cycle != nil {
+				return fmt.Errorf("%w: transactions %v wait for each other", DeadlockError, cycle)
+			}
+		}
+		released := s.released
+
+		// Temporarily release the mutex while waiting
+		// This allows other goroutines to acquire and release locks
+		s.mu.Unlock()
+
+		select {
+		case <-released:
+			// A block of the shard was unlocked, check the conditions again
+			s.mu.Lock()
+		case <-timer.C:
+			s.mu.Lock()
+			return LockAbortError
+		case <-done:
+			s.mu.Lock()
+			return ErrQueryCancelled
+		}
 	}
+	return nil
+}
 
-	return val
+// Returns the transactions other than txnum holding a lock on the block
+func (s *lockShard) otherHolders(block *file.BlockID, txnum int64) []int64 {
+	var holders []int64
+	for holder := range s.holders[*block] {
+		if holder != txnum {
+			holders = append(holders, holder)
+		}
+	}
+	return holders
 }
 
 // Testing methods
 func (lt *LockTable) GetLockVal(block *file.BlockID) int {
-	val, exists := lt.locks[block]
-
-	if !exists {
-		return 0
-	}
+	s := lt.shard(block)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return val
+	return s.locks[*block]
 }
 
-func (lt *LockTable) GetLocks() map[*file.BlockID]int {
-	return lt.locks
+// Returns a copy of the lock values of all locked blocks
+func (lt *LockTable) GetLocks() map[file.BlockID]int {
+	locks := make(map[file.BlockID]int)
+	for _, s := range lt.shards {
+		s.mu.Lock()
+		for block, val := range s.locks {
+			locks[block] = val
+		}
+		s.mu.Unlock()
+	}
+	return locks
 }
@@ -0,0 +1,141 @@
+package tx
+
+import (
+	"centauri/internal/app/file"
+	"centauri/internal/app/log"
+	"errors"
+	"fmt"
+)
+
+// Returned when redoing a change logged without its new value,
+// i.e. one written by a version that only logged previous values
+var ErrCannotRedo = errors.New("log record can't be redone")
+
+// Records of changes to a block, which can be redone unless they were
+// written by a version that only logged previous values or no image
+type redoRecord interface {
+	LogRecord
+	Block() *file.BlockID
+	CanRedo() bool
+}
+
+// LogApplier replays the log of another database, e.g. one shipped from
+// a primary to a standby, so the changes of its committed transactions
+// are made to this database too. Changes are kept until their transaction
+// commits and dropped if it rolls back, so uncommitted changes are never
+// applied. Each committed transaction is applied in a transaction of this
+// database, without logging the changes again.
+type LogApplier struct {
+	reader  *log.LogReader
+	pending map[int][]redoRecord // changes of transactions that haven't finished yet
+	resume  log.LogPosition      // where reading can restart without losing pending changes
+}
+
+// Creates an applier that reads the log from the given position
+func NewLogApplier(reader *log.LogReader) *LogApplier {
+	return &LogApplier{
+		reader:  reader,
+		pending: make(map[int][]redoRecord),
+		resume:  reader.Position(),
+	}
+}
+
+// Returns the position reading should restart from after a restart.
+// It's the position after the last record at which no transaction was
+// in progress, so some committed transactions may be applied twice,
+// which leaves the same values behind.
+func (la *LogApplier) ResumePosition() log.LogPosition {
+	return la.resume
+}
+
+// Applies every transaction that committed in the log records written
+// since the last call, each in a transaction created by newTx, and returns
+// how many were applied
+func (la *LogApplier) Apply(newTx func() *Transaction) (int, error) {
+	applied := 0
+	for {
+		bytes, err := la.reader.Next()
+		if err != nil {
+			return applied, err
+		}
+		if bytes == nil {
+			return applied, nil
+		}
+
+		record := CreateLogRecord(bytes)
+		if record == nil {
+			return applied, fmt.Errorf("unknown log record at %v", la.reader.Position())
+		}
+
+		switch record.Op() {
+		case START:
+			la.pending[record.TxNumber()] = nil
+
+		case SETINT, SETSTRING, SETLONG, PAGEWRITE:
+			rec := record.(redoRecord)
+			if !rec.CanRedo() {
+				return applied, fmt.Errorf("%w: %v", ErrCannotRedo, record)
+			}
+			la.pending[record.TxNumber()] = append(la.pending[record.TxNumber()], rec)
+
+		case COMMIT:
+			if err := la.commit(record.TxNumber(), newTx); err != nil {
+				return applied, err
+			}
+			applied++
+
+		case ROLLBACK:
+			delete(la.pending, record.TxNumber())
+		}
+
+		if len(la.pending) == 0 {
+			la.resume = la.reader.Position()
+		}
+	}
+}
+
+// Writes the changes of a committed transaction
+func (la *LogApplier) commit(txnum int, newTx func() *Transaction) (err error) {
+	records := la.pending[txnum]
+	delete(la.pending, txnum)
+	if len(records) == 0 {
+		return nil
+	}
+
+	t := newTx()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+		if err != nil {
+			t.Rollback()
+			err = fmt.Errorf("failed to apply transaction %d: %w", txnum, err)
+		}
+	}()
+
+	for _, rec := range records {
+		if err := rec.Redo(t); err != nil {
+			return err
+		}
+	}
+	t.Commit()
+	return nil
+}
+
+// Appends blocks to the block's file until it exists. Blocks are
+// appended without logging, so a change being redone may be the
+// first the log says about its block.
+func extendTo(tx *Transaction, block *file.BlockID) error {
+	for {
+		size, err := tx.Size(block.FileName())
+		if err != nil {
+			return err
+		}
+		if block.Number() < size {
+			return nil
+		}
+		if _, err := tx.Append(block.FileName()); err != nil {
+			return err
+		}
+	}
+}
@@ -14,14 +14,30 @@ const (
 	ROLLBACK                 = 3
 	SETINT                   = 4
 	SETSTRING                = 5
+	SETLONG                  = 6
+	PAGEWRITE                = 7
 )
 
+// A record of the log. Undo and Redo change nothing for records that
+// change no values, such as those starting or ending a transaction.
 type LogRecord interface {
 	Op() LogRecordType
-	TxNumber() int
-	Undo(txnum *Transaction)
+	TxNumber() int              // The transaction that wrote the record, -1 if none did
+	Undo(tx *Transaction)       // Restores the values the record changed, without logging
+	Redo(tx *Transaction) error // Writes the values the record changed again, without logging
 }
 
+var (
+	_ LogRecord = (*CheckPointRecord)(nil)
+	_ LogRecord = (*StartRecord)(nil)
+	_ LogRecord = (*CommitRecord)(nil)
+	_ LogRecord = (*RollbackRecord)(nil)
+	_ LogRecord = (*SetIntRecord)(nil)
+	_ LogRecord = (*SetStringRecord)(nil)
+	_ LogRecord = (*SetLongRecord)(nil)
+	_ LogRecord = (*PageWriteRecord)(nil)
+)
+
 // Creates a new log record from bytes
 func CreateLogRecord(bytes []byte) LogRecord {
 	p := file.NewPageFromBytes(bytes)
@@ -40,6 +56,10 @@ func CreateLogRecord(bytes []byte) LogRecord {
 		return NewSetIntRecord(p)
 	case SETSTRING:
 		return NewSetStringRecord(p)
+	case SETLONG:
+		return NewSetLongRecord(p)
+	case PAGEWRITE:
+		return NewPageWriteRecord(p)
 	default:
 		return nil
 	}
@@ -0,0 +1,117 @@
+package tx
+
+import (
+	"centauri/internal/app/file"
+	"centauri/internal/app/log"
+	"fmt"
+)
+
+// Records that a committing transaction wrote a block to disk because it
+// changed it without logging. The block on disk holds every change logged
+// before the record, so recovery doesn't redo them. The records carry the
+// image of the block, split into chunks that fit in a log block, so that
+// a log applier can write the unlogged changes too.
+type PageWriteRecord struct {
+	txNum  int
+	block  *file.BlockID
+	offset int    // where the chunk of the image starts in the block
+	image  []byte // the chunk, nil in records written by older versions
+}
+
+func NewPageWriteRecord(p *file.Page) *PageWriteRecord {
+	tPos := 4
+	fPos := tPos + 4
+	fileName := p.GetString(fPos)
+	bPos := fPos + file.MaxLength(len(fileName))
+
+	// Records written by older versions end with the block number
+	pw := &PageWriteRecord{
+		txNum: int(p.GetInt(tPos)),
+		block: file.NewBlockID(fileName, int(p.GetInt(bPos))),
+	}
+	if oPos := bPos + 4; len(p.Contents()) > oPos {
+		pw.offset = int(p.GetInt(oPos))
+		pw.image = p.GetBytes(oPos + 4)
+	}
+	return pw
+}
+
+func (pw *PageWriteRecord) Op() LogRecordType {
+	return PAGEWRITE
+}
+
+func (pw *PageWriteRecord) TxNumber() int {
+	return pw.txNum
+}
+
+// Returns the block that was written
+func (pw *PageWriteRecord) Block() *file.BlockID {
+	return pw.block
+}
+
+// Reports whether the record carries a chunk of the block's image
+func (pw *PageWriteRecord) CanRedo() bool {
+	return pw.image != nil
+}
+
+// Does nothing because writing a block changes no values
+func (pw *PageWriteRecord) Undo(tx *Transaction) {}
+
+// Writes the chunk of the image to the block again, without logging it.
+// Records of older versions carry no image and do nothing.
+func (pw *PageWriteRecord) Redo(tx *Transaction) error {
+	if !pw.CanRedo() {
+		return nil
+	}
+	if err := extendTo(tx, pw.block); err != nil {
+		return err
+	}
+	tx.Pin(pw.block)
+	defer tx.Unpin(pw.block)
+	return tx.writeImage(*pw.block, pw.offset, pw.image)
+}
+
+func (pw *PageWriteRecord) String() string {
+	return fmt.Sprintf("<PAGEWRITE %d %v %d %d>", pw.txNum, pw.block, pw.offset, len(pw.image))
+}
+
+// Writes page write records holding the image of the block to the
+// transaction log, as many as it takes for each to fit in a log block.
+// Each record is laid out as:
+//   - 4 bytes: PAGEWRITE operation code
+//   - 4 bytes: Transaction number
+//   - Variable: File name of the block
+//   - 4 bytes: Block number
+//   - 4 bytes: Offset of the chunk in the block
+//   - Variable: The bytes of the chunk
+//
+// Returns:
+//   - LSN (Log sequence number) of the last record written
+func writeToLogPageWriteRecords(lm *log.LogManager, txNum int, block file.BlockID, image []byte) int {
+	tPos := 4
+	fPos := tPos + 4
+	bPos := fPos + file.MaxLength(len(block.FileName()))
+	oPos := bPos + 4
+	iPos := oPos + 4
+
+	// A log block starts with the position of its last record. The image
+	// may be of a table whose blocks are larger or smaller than log blocks.
+	chunkSize := lm.BlockSize() - 4 - log.RECORD_OVERHEAD - file.MaxLength(iPos)
+
+	lsn := -1
+	for offset := 0; offset == 0 || offset < len(image); offset += chunkSize {
+		chunk := image[offset:min(offset+chunkSize, len(image))]
+		rec := make([]byte, iPos+file.MaxLength(len(chunk)))
+
+		p := file.NewPageFromBytes(rec)
+		p.SetInt(0, PAGEWRITE)
+		p.SetInt(tPos, int32(txNum))
+		p.SetString(fPos, block.FileName())
+		p.SetInt(bPos, int32(block.Number()))
+		p.SetInt(oPos, int32(offset))
+		p.SetBytes(iPos, chunk)
+
+		lsn, _ = lm.Append(rec)
+	}
+	return lsn
+}
@@ -2,7 +2,9 @@ package tx
 
 import (
 	"centauri/internal/app/buffer"
+	"centauri/internal/app/file"
 	"centauri/internal/app/log"
+	"fmt"
 )
 
 type RecoveryManager struct {
@@ -10,6 +12,8 @@ type RecoveryManager struct {
 	bm          *buffer.BufferManager
 	transaction *Transaction
 	txnum       int
+	readOnly    bool                  // Whether the transaction logs nothing, see newReadOnlyRecoveryManager
+	unlogged    map[file.BlockID]bool // Blocks the transaction changed without logging, written at commit
 }
 
 func (rm *RecoveryManager) NewRecoveryManager(
@@ -30,97 +34,346 @@ func (rm *RecoveryManager) NewRecoveryManager(
 	return recoveryManager
 }
 
+// Creates the recovery manager of a read-only transaction. It writes no log
+// records, not even START, COMMIT or ROLLBACK ones: the transaction changes
+// only temp tables, which are never rolled back or recovered.
+func newReadOnlyRecoveryManager(tx *Transaction, txnum int, lm *log.LogManager, bm *buffer.BufferManager) *RecoveryManager {
+	return &RecoveryManager{
+		lm:          lm,
+		bm:          bm,
+		transaction: tx,
+		txnum:       txnum,
+		readOnly:    true,
+	}
+}
+
+// Commits the transaction by forcing its COMMIT record to the log. Its
+// modified buffers stay in the pool, since recovery redoes the committed
+// changes that weren't written, except for the blocks it changed without
+// logging: those are written, each followed by PAGEWRITE records holding
+// its image, so that recovery doesn't redo older changes over it and a
+// standby applying the log gets the unlogged changes too.
 func (rm *RecoveryManager) Commit() {
-	rm.bm.FlushAll(rm.txnum)
+	if rm.readOnly {
+		rm.bm.FlushAll(rm.txnum)
+		return
+	}
+	if len(rm.unlogged) > 0 {
+		rm.bm.FlushAll(rm.txnum)
+		for block := range rm.unlogged {
+			writeToLogPageWriteRecords(rm.lm, rm.txnum, block, rm.transaction.readImage(block))
+		}
+		rm.unlogged = nil
+	}
 	lsn := writeToLogCommitRecord(rm.lm, rm.txnum)
 	rm.lm.Flush(lsn)
 }
 
+// Records that the transaction changed the block without logging the
+// change. Temp files are never recovered, so their changes aren't recorded.
+func (rm *RecoveryManager) unloggedChange(block file.BlockID) {
+	if file.IsTemp(block.FileName()) {
+		return
+	}
+	if rm.unlogged == nil {
+		rm.unlogged = make(map[file.BlockID]bool)
+	}
+	rm.unlogged[block] = true
+}
+
 func (rm *RecoveryManager) Rollback() {
-	rm.doRollback()
+	if rm.readOnly {
+		rm.bm.FlushAll(rm.txnum)
+		return
+	}
+	// Without a ROLLBACK record, recovery undoes the transaction instead
+	if err := rm.doRollback(); err != nil {
+		fmt.Printf("transaction %d: rollback failed: %v\n", rm.txnum, err)
+		return
+	}
 	rm.bm.FlushAll(rm.txnum)
 	lsn := writeToLogRollbackRecord(rm.lm, rm.txnum)
 	rm.lm.Flush(lsn)
 }
 
-func (rm *RecoveryManager) Recover() {
-	rm.doRecover()
+// Repeats the changes logged after the LSN, see Transaction.Replay
+func (rm *RecoveryManager) Replay(fromLSN int) error {
+	if err := rm.doReplay(fromLSN); err != nil {
+		return err
+	}
+	rm.bm.FlushAll(rm.txnum)
+	rm.unlogged = nil
+	return nil
+}
+
+// Recovers the database after a crash, see doRecover, then writes
+// the recovered blocks and a checkpoint
+func (rm *RecoveryManager) Recover() error {
+	if err := rm.doRecover(); err != nil {
+		return err
+	}
 	rm.bm.FlushAll(rm.txnum)
+	rm.unlogged = nil
 	lsn := writeToLogCheckpointRecord(rm.lm, rm.txnum)
-	rm.lm.Flush(lsn)
+	return rm.lm.Flush(lsn)
+}
+
+// Writes a quiescent checkpoint: flushes every modified buffer and
+// appends a CHECKPOINT record, so that recovery doesn't need to look at
+// any earlier log records. No transactions may be running.
+func Checkpoint(lm *log.LogManager, bm *buffer.BufferManager) error {
+	bm.FlushAllBuffers()
+	lsn := writeToLogCheckpointRecord(lm, -1)
+	return lm.Flush(lsn)
+}
+
+// Returns the LSN of the last CHECKPOINT record of the log, or 0 if there
+// is none. Every change logged before it is on disk.
+func LastCheckpointLSN(lm *log.LogManager) (int, error) {
+	iter, err := lm.Iterator()
+	if err != nil {
+		return 0, err
+	}
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		if err != nil {
+			return 0, err
+		}
+		if rec := CreateLogRecord(bytes); rec != nil && rec.Op() == CHECKPOINT {
+			return iter.LSN(), nil
+		}
+	}
+	return 0, nil
 }
 
 func (rm *RecoveryManager) SetInt(buff *buffer.Buffer, offset int, newval int) int {
 	oldval := buff.Contents().GetInt(offset)
 	block := buff.Block()
 
-	return WriteToLogIntRecord(rm.lm, rm.txnum, block, offset, int(oldval))
+	return WriteToLogIntRecord(rm.lm, rm.txnum, block, offset, int(oldval), newval)
+}
+
+func (rm *RecoveryManager) SetLong(buff *buffer.Buffer, offset int, newval int64) int {
+	oldval := buff.Contents().GetLong(offset)
+	return WriteToLogLongRecord(rm.lm, rm.txnum, buff.Block(), offset, oldval, newval)
 }
 
 func (rm *RecoveryManager) SetString(buff *buffer.Buffer, offset int, newval string) int {
 	oldVal := buff.Contents().GetString(offset)
 	block := buff.Block()
-	val, _ := WriteToLog(rm.lm, rm.txnum, block, offset, oldVal)
+	val, _ := WriteToLog(rm.lm, rm.txnum, block, offset, oldVal, newval)
 	return val
 }
 
 // Performs a rollback operation for a specific transaction.
 // It scans the log backwards until it finds the START record for the transaction,
 // undoing all operations for that transaction along the way.
-func (rm *RecoveryManager) doRollback() {
+func (rm *RecoveryManager) doRollback() error {
 	// Get an iterator to scan through log records
-	iter, _ := rm.lm.Iterator()
+	iter, err := rm.lm.Iterator()
+	if err != nil {
+		return err
+	}
 
 	// Iterate through all log records
 	for iter.HasNext() {
-		bytes, _ := iter.Next()
+		bytes, err := iter.Next()
+		if err != nil {
+			return err
+		}
 		record := CreateLogRecord(bytes)
+		if record == nil {
+			return fmt.Errorf("unknown log record at LSN %d", iter.LSN())
+		}
 
 		// Only process records for this specific transaction
 		if record.TxNumber() == rm.txnum {
 			// If we find the START record, we`re done
 			// as we`ve undone all operations after the start
 			if record.Op() == START {
-				return
+				return nil
 			}
 			// Undo this operation
 			record.Undo(rm.transaction)
 		}
 	}
+	return nil
 }
 
-// Performs crash recovery using the UNDO-only recovery strategy.
-// It scans the log backwards, undoing all uncommitted transactions until
-// it reaches a CHECKPOINT record.
-func (rm *RecoveryManager) doRecover() {
-	// Map to track transactions that have completed (committed or rolled back)
-	// Using map[int]struct{} for memory efficiency as we only need to track existence
-	finishedTxns := make(map[int]struct{})
+// What the analysis pass of recovery finds in the log after the last checkpoint
+type recoveryAnalysis struct {
+	finished map[int]bool         // The transactions that committed or rolled back
+	dirty    map[file.BlockID]int // The dirty page table: the LSN of the first change each block may lack
+	undo     []LogRecord          // The changes of the transactions that never finished, newest first
+}
 
-	iter, _ := rm.lm.Iterator()
+// Returns the LSN redo starts at: the smallest in the dirty page table,
+// or -1 if no block needs redoing
+func (a *recoveryAnalysis) redoLSN() int {
+	redoLSN := -1
+	for _, lsn := range a.dirty {
+		if redoLSN < 0 || lsn < redoLSN {
+			redoLSN = lsn
+		}
+	}
+	return redoLSN
+}
 
+// Performs crash recovery in three passes, in the manner of ARIES:
+//   - analysis reads the log back to the last checkpoint, finding the
+//     finished transactions and the blocks whose logged changes may not
+//     all be on disk
+//   - redo reads the log forward and writes those changes again, so that
+//     committed changes that were still in the buffer pool aren't lost,
+//     and undoes again the changes of transactions that rolled back
+//   - undo undoes, newest first, the changes of transactions that never finished
+func (rm *RecoveryManager) doRecover() error {
+	a, err := rm.analyze()
+	if err != nil {
+		return err
+	}
+	if redoLSN := a.redoLSN(); redoLSN >= 0 {
+		if err := rm.redo(a, redoLSN); err != nil {
+			return err
+		}
+	}
+	for _, record := range a.undo {
+		record.Undo(rm.transaction)
+	}
+	return nil
+}
+
+// Reads the log backwards to the last checkpoint. A block is dirty from its
+// first change after the last PAGEWRITE record of it, since the changes
+// logged before that record are on disk. The COMMIT or ROLLBACK record of
+// a transaction comes after its changes, so a change whose transaction
+// isn't known to have finished yet is one of an unfinished transaction.
+func (rm *RecoveryManager) analyze() (*recoveryAnalysis, error) {
+	iter, err := rm.lm.Iterator()
+	if err != nil {
+		return nil, err
+	}
+
+	a := &recoveryAnalysis{
+		finished: make(map[int]bool),
+		dirty:    make(map[file.BlockID]int),
+	}
+	written := make(map[file.BlockID]bool) // blocks with a PAGEWRITE record after the current one
 	for iter.HasNext() {
-		bytes, _ := iter.Next()
+		bytes, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
 		record := CreateLogRecord(bytes)
+		if record == nil {
+			return nil, fmt.Errorf("unknown log record at LSN %d", iter.LSN())
+		}
+
+		switch record.Op() {
+		case CHECKPOINT:
+			return a, nil
+
+		case COMMIT, ROLLBACK:
+			a.finished[record.TxNumber()] = true
+
+		case PAGEWRITE:
+			written[*record.(*PageWriteRecord).Block()] = true
 
-		// If we hit a CHECKPOINT, recovery is complete
-		// as we dont need to process any records before the checkpoint
-		if record.Op() == CHECKPOINT {
-			return
-		}
-
-		// If record is COMMIT or ROLLBACK, mark transaction as finished
-		if record.Op() == COMMIT || record.Op() == ROLLBACK {
-			// Add transaction number to finished set using empty struct
-			finishedTxns[record.TxNumber()] = struct{}{}
-		} else {
-			// For all other operations,
-			// Check if this transaction was not finished (not in finishedTxs)
-			if _, exists := finishedTxns[record.TxNumber()]; !exists {
-				// If transaction was`nt finished, undo this operation
-				record.Undo(rm.transaction)
+		case SETINT, SETSTRING, SETLONG:
+			block := *record.(pageUndoer).Block()
+			if !written[block] {
+				a.dirty[block] = iter.LSN()
+			}
+			if !a.finished[record.TxNumber()] {
+				a.undo = append(a.undo, record)
 			}
 		}
+	}
+	return a, nil
+}
 
+// Reads the log forward from the LSN, redoing the changes that the dirty
+// page table says their blocks may lack
+func (rm *RecoveryManager) redo(a *recoveryAnalysis, fromLSN int) error {
+	iter, err := rm.lm.ForwardIterator(fromLSN)
+	if err != nil {
+		return err
+	}
+
+	changes := make(map[int][]LogRecord) // redone changes of each transaction that hasn't finished
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		record := CreateLogRecord(bytes)
+		if record == nil {
+			return fmt.Errorf("unknown log record at LSN %d", iter.LSN())
+		}
+
+		// Records without a new value were written when commits
+		// wrote their blocks, so their changes are on disk
+		if rec, ok := record.(redoRecord); ok {
+			recLSN, dirty := a.dirty[*rec.Block()]
+			if !dirty || iter.LSN() < recLSN || !rec.CanRedo() {
+				continue
+			}
+		}
+		if err := rm.repeat(record, changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Redoes the change of a record, keeping it among the changes of its
+// transaction, and undoes those changes again at its ROLLBACK record
+func (rm *RecoveryManager) repeat(record LogRecord, changes map[int][]LogRecord) error {
+	if err := record.Redo(rm.transaction); err != nil {
+		return err
+	}
+
+	switch record.Op() {
+	case SETINT, SETSTRING, SETLONG:
+		changes[record.TxNumber()] = append(changes[record.TxNumber()], record)
+
+	case COMMIT:
+		delete(changes, record.TxNumber())
+
+	case ROLLBACK:
+		undone := changes[record.TxNumber()]
+		for j := len(undone) - 1; j >= 0; j-- {
+			undone[j].Undo(rm.transaction)
+		}
+		delete(changes, record.TxNumber())
+	}
+	return nil
+}
+
+// Writes again every change logged after the LSN, oldest first, including
+// those of transactions that didn't commit. The changes a rollback undid
+// aren't logged, so they are undone again when its ROLLBACK record is
+// reached. Recovery then undoes the transactions that never finished.
+func (rm *RecoveryManager) doReplay(fromLSN int) error {
+	iter, err := rm.lm.ForwardIterator(fromLSN + 1)
+	if err != nil {
+		return err
+	}
+
+	changes := make(map[int][]LogRecord) // changes of each transaction that hasn't finished
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		record := CreateLogRecord(bytes)
+		if record == nil {
+			return fmt.Errorf("unknown log record at LSN %d", iter.LSN())
+		}
+
+		if err := rm.repeat(record, changes); err != nil {
+			return err
+		}
 	}
+	return nil
 }
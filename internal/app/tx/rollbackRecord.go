@@ -3,14 +3,12 @@ package tx
 import (
 	"centauri/internal/app/file"
 	"centauri/internal/app/log"
-	"encoding/binary"
 	"fmt"
 )
 
 // Represents the beginning of a transaction in the log.
 // It contains the transaction number and implements the LogRecord interface.
 type RollbackRecord struct {
-	LogRecord
 	txNum int
 }
 
@@ -33,10 +31,15 @@ func (rb *RollbackRecord) TxNumber() int {
 
 // Defines how to reverse a ROLLBACK operation
 // Does nothing because a rollback record contains no undo information.
-func (rb *RollbackRecord) undo(tx *Transaction) {}
+func (rb *RollbackRecord) Undo(tx *Transaction) {}
+
+// Does nothing because a rollback record changes no values
+func (rb *RollbackRecord) Redo(tx *Transaction) error {
+	return nil
+}
 
 func (rb *RollbackRecord) String() string {
-	return fmt.Sprintf("<START %t>", rb.txNum)
+	return fmt.Sprintf("<ROLLBACK %d>", rb.txNum)
 }
 
 // Writes a rollback record to the transaction log.
@@ -50,9 +53,10 @@ func writeToLogRollbackRecord(lm *log.LogManager, txNum int) int {
 	// Create a byte slice with capacity for two 32-bit integers
 	rec := make([]byte, 8)
 
-	// Convert integers to bytes and write them to the slice
-	binary.LittleEndian.PutUint32(rec[0:4], uint32(ROLLBACK))
-	binary.LittleEndian.PutUint32(rec[4:8], uint32(txNum))
+	// Write the integers in the same byte order the records are read with
+	p := file.NewPageFromBytes(rec)
+	p.SetInt(0, ROLLBACK)
+	p.SetInt(4, int32(txNum))
 
 	// Append to log and return position
 	lsn, _ := lm.Append(rec)
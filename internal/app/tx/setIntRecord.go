@@ -7,10 +7,11 @@ import (
 )
 
 type SetIntRecord struct {
-	LogRecord
 	txNum  int
 	offset int
-	val    int
+	val    int // value before the change, restored by Undo
+	newVal int // value after the change, written again by Redo
+	redo   bool
 	block  *file.BlockID
 }
 
@@ -38,10 +39,21 @@ func NewSetIntRecord(p *file.Page) *SetIntRecord {
 	vPos := oPos + 4
 	val := p.GetInt(vPos)
 
+	// Position for the new value (starts after the previous value).
+	// Records written by older versions end with the previous value.
+	nPos := vPos + 4
+	redo := len(p.Contents()) >= nPos+4
+	var newVal int32
+	if redo {
+		newVal = p.GetInt(nPos)
+	}
+
 	return &SetIntRecord{
 		txNum:  int(txNum),
 		offset: int(offset),
 		val:    int(val),
+		newVal: int(newVal),
+		redo:   redo,
 		block:  block,
 	}
 }
@@ -55,7 +67,12 @@ func (sir *SetIntRecord) TxNumber() int {
 }
 
 func (sir *SetIntRecord) String() string {
-	return fmt.Sprintf("<SETINT %t %b %o %v>", sir.txNum, sir.block, sir.offset, sir.val)
+	return fmt.Sprintf("<SETINT %d %v %d %v %v>", sir.txNum, sir.block, sir.offset, sir.val, sir.newVal)
+}
+
+// Returns false for records written without the new value, which can't be redone
+func (sir *SetIntRecord) CanRedo() bool {
+	return sir.redo
 }
 
 // Restores the previous value at the specified block and offset.
@@ -63,7 +80,7 @@ func (sir *SetIntRecord) String() string {
 // 1. Pins the block to ensure it stays in memory
 // 2. Sets the original value back without logging(to prevent infinite undo loops)
 // 3. Unpins the block to allow buffer manager to reuse it if needed
-func (sir *SetIntRecord) undo(tx *Transaction) {
+func (sir *SetIntRecord) Undo(tx *Transaction) {
 	// Pin the block to keep it in memory during the operation
 	tx.Pin(sir.block)
 	// Restore the original value
@@ -75,18 +92,45 @@ func (sir *SetIntRecord) undo(tx *Transaction) {
 	tx.Unpin(sir.block)
 }
 
+// Returns the block the record changed
+func (sir *SetIntRecord) Block() *file.BlockID {
+	return sir.block
+}
+
+// Restores the previous value in a copy of the block
+func (sir *SetIntRecord) UndoPage(p *file.Page) {
+	p.SetInt(sir.offset, int32(sir.val))
+}
+
+// Writes the new value at the specified block and offset again,
+// without logging it
+func (sir *SetIntRecord) Redo(tx *Transaction) error {
+	if !sir.redo {
+		return fmt.Errorf("%w: %v", ErrCannotRedo, sir)
+	}
+	if err := extendTo(tx, sir.block); err != nil {
+		return err
+	}
+	tx.Pin(sir.block)
+	defer tx.Unpin(sir.block)
+
+	return tx.SetInt(*sir.block, sir.offset, sir.newVal, false)
+}
+
 // Writes a SEtInt record to the log.
 // This log record contains the SETINT operator,
 // followed by the transaction id, the filename, number,
-// and offset of the modified block, and the previous integer value at that offset.
-func WriteToLogIntRecord(lm *log.LogManager, txNum int, block *file.BlockID, offset int, val int) int {
+// and offset of the modified block, the previous integer value at that offset
+// and the new one.
+func WriteToLogIntRecord(lm *log.LogManager, txNum int, block *file.BlockID, offset int, val int, newVal int) int {
 	tPos := 4
 	fPos := tPos + 4
 	bPos := fPos + file.MaxLength(len(block.FileName()))
 	oPos := bPos + 4
 	vPos := oPos + 4
+	nPos := vPos + 4
 
-	rec := make([]byte, vPos+4)
+	rec := make([]byte, nPos+4)
 	p := file.NewPageFromBytes(rec)
 
 	p.SetInt(0, SETINT)
@@ -95,6 +139,7 @@ func WriteToLogIntRecord(lm *log.LogManager, txNum int, block *file.BlockID, off
 	p.SetInt(bPos, int32(block.Number()))
 	p.SetInt(oPos, int32(offset))
 	p.SetInt(vPos, int32(val))
+	p.SetInt(nPos, int32(newVal))
 
 	lsn, _ := lm.Append(rec)
 	return lsn
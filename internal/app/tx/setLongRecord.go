@@ -0,0 +1,110 @@
+package tx
+
+import (
+	"centauri/internal/app/file"
+	"centauri/internal/app/log"
+	"fmt"
+)
+
+// Logs the change of a 64-bit integer, laid out like a SetIntRecord with
+// 8 bytes for each value
+type SetLongRecord struct {
+	txNum  int
+	offset int
+	val    int64 // value before the change, restored by Undo
+	newVal int64 // value after the change, written again by Redo
+	block  *file.BlockID
+}
+
+// Creates a new SetLongRecord by parsing a Page containing log record data
+func NewSetLongRecord(p *file.Page) *SetLongRecord {
+	tPos := 4
+	txNum := p.GetInt(tPos)
+	fPos := tPos + 4
+	fileName := p.GetString(fPos)
+	bPos := fPos + file.MaxLength(len(fileName))
+	blockNum := p.GetInt(bPos)
+	oPos := bPos + 4
+	offset := p.GetInt(oPos)
+	vPos := oPos + 4
+	nPos := vPos + 8
+
+	return &SetLongRecord{
+		txNum:  int(txNum),
+		offset: int(offset),
+		val:    p.GetLong(vPos),
+		newVal: p.GetLong(nPos),
+		block:  file.NewBlockID(fileName, int(blockNum)),
+	}
+}
+
+func (slr *SetLongRecord) Op() LogRecordType {
+	return SETLONG
+}
+
+func (slr *SetLongRecord) TxNumber() int {
+	return slr.txNum
+}
+
+func (slr *SetLongRecord) String() string {
+	return fmt.Sprintf("<SETLONG %d %v %d %v %v>", slr.txNum, slr.block, slr.offset, slr.val, slr.newVal)
+}
+
+// Long records have always been written with the new value
+func (slr *SetLongRecord) CanRedo() bool {
+	return true
+}
+
+// Restores the previous value at the block and offset, without logging it
+func (slr *SetLongRecord) Undo(tx *Transaction) {
+	tx.Pin(slr.block)
+	tx.SetLong(*slr.block, slr.offset, slr.val, false)
+	tx.Unpin(slr.block)
+}
+
+// Returns the block the record changed
+func (slr *SetLongRecord) Block() *file.BlockID {
+	return slr.block
+}
+
+// Restores the previous value in a copy of the block
+func (slr *SetLongRecord) UndoPage(p *file.Page) {
+	p.SetLong(slr.offset, slr.val)
+}
+
+// Writes the new value at the block and offset again, without logging it
+func (slr *SetLongRecord) Redo(tx *Transaction) error {
+	if err := extendTo(tx, slr.block); err != nil {
+		return err
+	}
+	tx.Pin(slr.block)
+	defer tx.Unpin(slr.block)
+
+	return tx.SetLong(*slr.block, slr.offset, slr.newVal, false)
+}
+
+// Writes a SETLONG record to the log: the SETLONG operator, followed by the
+// transaction id, the filename, number and offset of the modified block,
+// the previous 64-bit value at that offset and the new one.
+func WriteToLogLongRecord(lm *log.LogManager, txNum int, block *file.BlockID, offset int, val int64, newVal int64) int {
+	tPos := 4
+	fPos := tPos + 4
+	bPos := fPos + file.MaxLength(len(block.FileName()))
+	oPos := bPos + 4
+	vPos := oPos + 4
+	nPos := vPos + 8
+
+	rec := make([]byte, nPos+8)
+	p := file.NewPageFromBytes(rec)
+
+	p.SetInt(0, SETLONG)
+	p.SetInt(tPos, int32(txNum))
+	p.SetString(fPos, block.FileName())
+	p.SetInt(bPos, int32(block.Number()))
+	p.SetInt(oPos, int32(offset))
+	p.SetLong(vPos, val)
+	p.SetLong(nPos, newVal)
+
+	lsn, _ := lm.Append(rec)
+	return lsn
+}
@@ -9,16 +9,18 @@ import (
 // Represents a log record that stores information about a string modification
 // in a transaction.
 type SetStringRecord struct {
-	LogRecord
 	txnum  int           // Transaction identifier
 	offset int           // Position within the block
-	val    string        // The string value being set
+	val    string        // The string value before the change, restored by Undo
+	newVal string        // The string value after the change, written again by Redo
+	redo   bool          // false for records written without the new value
 	block  *file.BlockID // Reference to the modified block
 }
 
 // Creates a new log record from a page of bytes
 // The page layout is expected to be:
-// | RecordType(4) | TxNum(4) | Filename(var) | BlockNum(4) | Offset(4) | Value(var) |
+// | RecordType(4) | TxNum(4) | Filename(var) | BlockNum(4) | Offset(4) | Value(var) | NewValue(var) |
+// Records written by older versions end with the previous value.
 func NewSetStringRecord(p *file.Page) *SetStringRecord {
 	// Start at position 4 because first 4 bytes contain record type
 	tpos := 4
@@ -48,10 +50,20 @@ func NewSetStringRecord(p *file.Page) *SetStringRecord {
 	// Read the actual string value
 	val := p.GetString(vpos)
 
+	// Read the new value if the record holds one
+	npos := vpos + file.MaxLength(len(val))
+	redo := len(p.Contents()) > npos
+	newVal := ""
+	if redo {
+		newVal = p.GetString(npos)
+	}
+
 	return &SetStringRecord{
 		txnum:  int(txnum),
 		offset: int(offset),
 		val:    val,
+		newVal: newVal,
+		redo:   redo,
 		block:  block,
 	}
 }
@@ -60,25 +72,60 @@ func (r *SetStringRecord) Op() LogRecordType {
 	return SETSTRING
 }
 
-func (r *SetStringRecord) txNumber() int {
+func (r *SetStringRecord) TxNumber() int {
 	return r.txnum
 }
 
 // Returns a string representation of the record
 func (r *SetStringRecord) String() string {
-	return fmt.Sprintf("<SETSTRING %d %v %d %s", r.txnum, r.block, r.offset, r.val)
+	return fmt.Sprintf("<SETSTRING %d %v %d %s %s>", r.txnum, r.block, r.offset, r.val, r.newVal)
+}
+
+// Returns false for records written without the new value, which can't be redone
+func (r *SetStringRecord) CanRedo() bool {
+	return r.redo
+}
+
+// Returns the block the record changed
+func (r *SetStringRecord) Block() *file.BlockID {
+	return r.block
+}
+
+// Restores the previous value in a copy of the block
+func (r *SetStringRecord) UndoPage(p *file.Page) {
+	p.SetString(r.offset, r.val)
 }
 
-func (r *SetStringRecord) undo(tx Transaction) {
+func (r *SetStringRecord) Undo(tx *Transaction) {
 	tx.Pin(r.block)
 	tx.SetString(*r.block, r.offset, r.val, false) // dont`t log the undo
 	tx.Unpin(r.block)
 }
 
+// Writes the new value again, without logging it
+func (r *SetStringRecord) Redo(tx *Transaction) error {
+	if !r.redo {
+		return fmt.Errorf("%w: %v", ErrCannotRedo, r)
+	}
+	if err := extendTo(tx, r.block); err != nil {
+		return err
+	}
+	tx.Pin(r.block)
+	defer tx.Unpin(r.block)
+
+	return tx.SetString(*r.block, r.offset, r.newVal, false)
+}
+
+// Returns the size of the log record of a change of a string of the file
+// from one of length valLen to one of length newValLen
+func setStringRecordSize(filename string, valLen int, newValLen int) int {
+	return 4 + 4 + file.MaxLength(len(filename)) + 4 + 4 + file.MaxLength(valLen) + file.MaxLength(newValLen)
+}
+
 // Writes a string modification record to the log.
 // The function creates a byte record with the following layout:
-// | RecordType(4) | TxNum(4) | Filename(var) | BlockNum(4) | Offset(4) | Value(var) |
-func WriteToLog(lm *log.LogManager, txnum int, block *file.BlockID, offset int, val string) (int, error) {
+// | RecordType(4) | TxNum(4) | Filename(var) | BlockNum(4) | Offset(4) | Value(var) | NewValue(var) |
+func WriteToLog(lm *log.LogManager, txnum int, block *file.BlockID, offset int, val string, newVal string) (int, error) {
 	// Calculate positions for each fields in the record
 	tpos := 4        // Skip first 4 bytes (record type)
 	fpos := tpos + 4 // Position after txnum
@@ -86,9 +133,11 @@ func WriteToLog(lm *log.LogManager, txnum int, block *file.BlockID, offset int,
 		file.MaxLength(len(block.FileName()))
 	opos := bpos + 4 // Position after block number
 	vpos := opos + 4 // Position after offset
+	npos := vpos +   // Position after the previous value
+		file.MaxLength(len(val))
 
 	// Calculate total record length including variable-length strings
-	recordLen := vpos + file.MaxLength(len(val))
+	recordLen := npos + file.MaxLength(len(newVal))
 
 	// Create a new byte slice of calculate length
 	record := make([]byte, recordLen)
@@ -103,6 +152,7 @@ func WriteToLog(lm *log.LogManager, txnum int, block *file.BlockID, offset int,
 	p.SetInt(bpos, int32(block.Number())) // Write block number
 	p.SetInt(opos, int32(offset))         // Write offset
 	p.SetString(vpos, val)                // Write string value
+	p.SetString(npos, newVal)             // Write new string value
 
 	return lm.Append(record)
 }
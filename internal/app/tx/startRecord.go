@@ -3,14 +3,12 @@ package tx
 import (
 	"centauri/internal/app/file"
 	"centauri/internal/app/log"
-	"encoding/binary"
 	"fmt"
 )
 
 // Represents the beginning of a transaction in the log.
 // It contains the transaction number and implements the LogRecord interface.
 type StartRecord struct {
-	LogRecord
 	txNum int
 }
 
@@ -34,10 +32,15 @@ func (sr *StartRecord) TxNumber() int {
 
 // Defines how to reverse a START operation
 // Does nothing because a start record contains no undo information.
-func undo(tx *Transaction) {}
+func (sr *StartRecord) Undo(tx *Transaction) {}
+
+// Does nothing because a start record changes no values
+func (sr *StartRecord) Redo(tx *Transaction) error {
+	return nil
+}
 
 func (sr *StartRecord) String() string {
-	return fmt.Sprintf("<START %t>", sr.txNum)
+	return fmt.Sprintf("<START %d>", sr.txNum)
 }
 
 // Writes a start record to the transaction log.
@@ -51,9 +54,10 @@ func writeToLogStartRecord(lm *log.LogManager, txNum int) int {
 	// Create a byte slice with capacity for two 32-bit integers
 	rec := make([]byte, 8)
 
-	// Convert integers to bytes and write them to the slice
-	binary.LittleEndian.PutUint32(rec[0:4], uint32(START))
-	binary.LittleEndian.PutUint32(rec[4:8], uint32(txNum))
+	// Write the integers in the same byte order the records are read with
+	p := file.NewPageFromBytes(rec)
+	p.SetInt(0, START)
+	p.SetInt(4, int32(txNum))
 
 	// Append to log and return position
 	lsn, _ := lm.Append(rec)
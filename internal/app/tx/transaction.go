@@ -1,40 +1,91 @@
 package tx
 
 import (
+	"bytes"
 	"centauri/internal/app/buffer"
 	"centauri/internal/app/file"
 	"centauri/internal/app/log"
+	"errors"
 	"fmt"
 	"sync/atomic"
+	"time"
 )
 
+// Raised as a panic when a statement writes more to temp tables than its quota allows
+var ErrTempQuotaExceeded = errors.New("temp space quota exceeded")
+
+// Raised as a panic when a read-only transaction writes a file other than a temp table's
+var ErrReadOnlyTransaction = errors.New("cannot write in a read-only transaction")
+
 var nextTxNum atomic.Int64 // Global atomic counter for transaction numbers
 const EndOfFile = -1       // Represents the end of file marker for block operations
 
 // Represents an individual database transaction. It coordinates buffer management,
 // recovery, and concurrency control
 type Transaction struct {
-	rm        *RecoveryManager
-	cm        *ConcurrencyManager
-	bm        *buffer.BufferManager
-	fm        *file.FileManager
-	lm        *log.LogManager
-	txnum     int64
-	myBuffers *BufferList
+	rm           *RecoveryManager
+	cm           *ConcurrencyManager
+	bm           *buffer.BufferManager
+	fm           *file.FileManager
+	lm           *log.LogManager
+	txnum        int64
+	myBuffers    *BufferList
+	onFinish     []func()     // Called once the transaction commits or rolls back
+	onCommit     []func()     // Called once the transaction commits, before its locks are released
+	pins         atomic.Int64 // Number of times a block was pinned, for statistics
+	tempQuota    atomic.Int64 // Most bytes the temp tables of a statement may take, 0 for no limit
+	tempUsed     atomic.Int64 // Bytes written to temp tables by the running statement
+	lastInsertID int64        // First AUTO_INCREMENT value generated by the last insert that generated one
+	versions     *versionStore
+	snapshot     *txSnapshot                 // What the transaction sees at SNAPSHOT isolation, nil at the other levels
+	snapPages    map[file.BlockID]*file.Page // The blocks last read at SNAPSHOT isolation, as the snapshot sees them
+	snapOrder    []file.BlockID              // The blocks of snapPages, least recently read first
+	readOnly     bool                        // Whether the transaction may write only temp tables
 }
 
 func NewTransaction(fm *file.FileManager, lm *log.LogManager, bm *buffer.BufferManager) *Transaction {
-	txNum := nextTmNumber()
+	return NewTransactionWithIsolation(fm, lm, bm, SERIALIZABLE)
+}
+
+// Creates a transaction that runs at the given isolation level
+func NewTransactionWithIsolation(fm *file.FileManager, lm *log.LogManager, bm *buffer.BufferManager, level IsolationLevel) *Transaction {
+	return newTransaction(fm, lm, bm, level, false)
+}
+
+// Creates a transaction that only reads, such as one for a metadata query
+// or a report. It takes no exclusive locks and writes nothing to the log,
+// not even its START and COMMIT records. Writing a block or appending one
+// to a file panics with ErrReadOnlyTransaction, except for the files of
+// temp tables, which no other transaction sees.
+func NewReadOnlyTransaction(fm *file.FileManager, lm *log.LogManager, bm *buffer.BufferManager) *Transaction {
+	return NewReadOnlyTransactionWithIsolation(fm, lm, bm, SERIALIZABLE)
+}
+
+// Creates a read-only transaction that runs at the given isolation level
+func NewReadOnlyTransactionWithIsolation(fm *file.FileManager, lm *log.LogManager, bm *buffer.BufferManager, level IsolationLevel) *Transaction {
+	return newTransaction(fm, lm, bm, level, true)
+}
+
+func newTransaction(fm *file.FileManager, lm *log.LogManager, bm *buffer.BufferManager, level IsolationLevel, readOnly bool) *Transaction {
+	versions := versionStoreFor(fm)
+	txNum, snapshot := versions.begin(level)
 
 	tx := &Transaction{
-		fm:    fm,
-		bm:    bm,
-		txnum: txNum,
-		lm:    lm,
+		fm:       fm,
+		bm:       bm,
+		txnum:    txNum,
+		lm:       lm,
+		versions: versions,
+		snapshot: snapshot,
+		readOnly: readOnly,
 	}
 
-	tx.rm = tx.rm.NewRecoveryManager(tx, int(txNum), lm, bm)
-	tx.cm = NewConcurrencyManager(NewLockTable())
+	if readOnly {
+		tx.rm = newReadOnlyRecoveryManager(tx, int(txNum), lm, bm)
+	} else {
+		tx.rm = tx.rm.NewRecoveryManager(tx, int(txNum), lm, bm)
+	}
+	tx.cm = NewConcurrencyManager(lockTableFor(fm), txNum, level)
 	tx.myBuffers = NewBufferList(bm)
 
 	return tx
@@ -48,8 +99,15 @@ func NewTransaction(fm *file.FileManager, lm *log.LogManager, bm *buffer.BufferM
 func (tx *Transaction) Commit() {
 	tx.rm.Commit()
 	fmt.Printf("transaction %d committed\n", tx.txnum)
-	tx.cm.Release()
 	tx.myBuffers.UnpinAll()
+	fns := tx.onCommit
+	tx.onCommit = nil
+	for _, fn := range fns {
+		fn()
+	}
+	tx.versions.end(tx.txnum, true)
+	tx.cm.Release()
+	tx.finish()
 }
 
 // Aborts the current transaction, releasing all locks, unpinning buffers,
@@ -59,10 +117,34 @@ func (tx *Transaction) Commit() {
 // releases all locks held by the transaction through the concurrency manager,
 // and unpins any buffers used during the transaction.
 func (tx *Transaction) Rollback() {
+	tx.onCommit = nil
 	tx.rm.Rollback()
 	fmt.Printf("transaction %d rolled back\n", tx.txnum)
+	tx.versions.end(tx.txnum, false)
 	tx.cm.Release()
 	tx.myBuffers.UnpinAll()
+	tx.finish()
+}
+
+// Registers a function to call once the transaction commits or rolls back
+func (tx *Transaction) OnFinish(fn func()) {
+	tx.onFinish = append(tx.onFinish, fn)
+}
+
+// Registers a function to call once the transaction commits, while it still
+// holds its locks. It isn't called if the transaction rolls back, which makes
+// it the place for changes that can't be undone, such as removing files.
+func (tx *Transaction) OnCommit(fn func()) {
+	tx.onCommit = append(tx.onCommit, fn)
+}
+
+// Runs the functions registered with OnFinish, at most once
+func (tx *Transaction) finish() {
+	fns := tx.onFinish
+	tx.onFinish = nil
+	for _, fn := range fns {
+		fn()
+	}
 }
 
 // Performs a transaction recovery operation by first flushing all pending changes
@@ -71,66 +153,246 @@ func (tx *Transaction) Rollback() {
 // to restore the transaction to a consistent state.
 func (tx *Transaction) Recover() error {
 	tx.bm.FlushAll(int(tx.txnum))
-	tx.rm.Recover()
-	return nil
+	return tx.rm.Recover()
+}
+
+// Writes again every change logged after the LSN, so that data files
+// copied while the database was changing catch up with the log. Every
+// change logged before the LSN must be in the files, as is the case at a
+// checkpoint. The changes of transactions that didn't finish are written
+// too and must be undone by Recover afterwards.
+func (tx *Transaction) Replay(fromLSN int) error {
+	return tx.rm.Replay(fromLSN)
+}
+
+// Cancels the statement running in the transaction. Lock waits end
+// with ErrQueryCancelled right away, and scans stop at their next
+// record by panicking with ErrQueryCancelled. The transaction itself
+// stays open and must still be rolled back. Safe to call from any goroutine.
+func (tx *Transaction) Cancel() {
+	tx.cm.cancel.cancel()
+}
+
+// Returns true if the running statement has been cancelled
+func (tx *Transaction) Cancelled() bool {
+	return tx.cm.cancel.cancelled()
+}
+
+// Panics with ErrQueryCancelled if the running statement has been cancelled.
+// Called by scans and sorts as they move from record to record.
+func (tx *Transaction) CheckCancelled() {
+	if tx.Cancelled() {
+		panic(ErrQueryCancelled)
+	}
+}
+
+// Sets the most bytes the temp tables of a statement, such as the runs of a
+// sort or a materialized subquery, may take. 0 means no limit.
+func (tx *Transaction) SetTempQuota(bytes int64) {
+	tx.tempQuota.Store(bytes)
+}
+
+// Returns the number of bytes written to temp tables by the running statement
+func (tx *Transaction) TempSpaceUsed() int64 {
+	return tx.tempUsed.Load()
+}
+
+// Starts accounting temp space for a new statement
+func (tx *Transaction) ResetTempSpace() {
+	tx.tempUsed.Store(0)
+}
+
+// Accounts bytes written to a temp table by the running statement.
+// Panics with ErrTempQuotaExceeded once the statement takes more than
+// its quota, which stops it like a cancellation would.
+func (tx *Transaction) AddTempSpace(bytes int64) {
+	used := tx.tempUsed.Add(bytes)
+	if quota := tx.tempQuota.Load(); quota > 0 && used > quota {
+		panic(fmt.Errorf("%w: the statement needs more than %d bytes of temp space", ErrTempQuotaExceeded, quota))
+	}
+}
+
+// Records the first AUTO_INCREMENT value generated by an insert
+func (tx *Transaction) SetLastInsertID(id int64) {
+	tx.lastInsertID = id
+}
+
+// Returns the first AUTO_INCREMENT value generated by the last insert of the
+// transaction that generated one, or 0 if none did
+func (tx *Transaction) LastInsertID() int64 {
+	return tx.lastInsertID
+}
+
+// Clears a cancellation that arrived after the last statement finished,
+// so the next statement of the transaction can run
+func (tx *Transaction) ResetCancel() {
+	tx.cm.cancel.reset()
+}
+
+// Returns the isolation level the transaction runs at
+func (tx *Transaction) IsolationLevel() IsolationLevel {
+	return tx.cm.level
+}
+
+// Reports whether the transaction was created by NewReadOnlyTransaction
+func (tx *Transaction) ReadOnly() bool {
+	return tx.readOnly
+}
+
+// Reports whether the transaction may write the file: any file,
+// unless it is read-only, when only the files of temp tables
+func (tx *Transaction) Writable(filename string) bool {
+	return !tx.readOnly || file.IsTemp(filename)
+}
+
+// Sets how long the transaction waits for a lock before aborting with
+// LockAbortError. With NOWAIT it fails with ErrLockNotAvailable instead
+// of waiting for a lock another transaction holds.
+func (tx *Transaction) SetLockTimeout(timeout time.Duration) {
+	tx.cm.SetLockTimeout(timeout)
 }
 
 // Pins a block to prevent it from being discarded
 // Parameters:
 //   - block: The BlockID of the block to be unpinned
 func (tx *Transaction) Pin(block *file.BlockID) {
+	tx.pins.Add(1)
 	tx.myBuffers.Pin(*block)
 }
 
+// Returns the number of times the transaction has pinned a block,
+// a measure of how much work its statements did
+func (tx *Transaction) BlocksPinned() int {
+	return int(tx.pins.Load())
+}
+
 // Unpins indicates that a block is no longer needed
 func (tx *Transaction) Unpin(block *file.BlockID) {
 	tx.myBuffers.Unpin(*block)
 }
 
 // DATA ACCESS OPERATIONS
+// A lock the operations can't acquire is raised as a panic, see lockFailed
 
 // Retrieves an integer value from a specific block at the given offset.
 func (tx *Transaction) GetInt(block file.BlockID, offset int) (int32, error) {
-	// Acquire a SLock since this is a read operations
+	// Get the page to read the block from, locking it for reading
 	// Multiple transactions can read the same block simultaneously
-	if err := tx.cm.SLock(block); err != nil {
+	p, release, err := tx.readPage(block)
+	if err != nil {
 		return 0, err
 	}
+	defer release()
 
-	// Get the buffer containing the block data
-	// This pins the buffer, preventing it from being replaced
-	buff, err := tx.myBuffers.GetBuffer(block)
+	// Read and return the integer value at the specified offset
+	return p.GetInt(offset), nil
+}
+
+// Retrieves a 64-bit integer from a specific block at the given offset
+func (tx *Transaction) GetLong(block file.BlockID, offset int) (int64, error) {
+	p, release, err := tx.readPage(block)
 	if err != nil {
 		return 0, err
 	}
-
-	// Read and return the integer value at the specified offset
-	return buff.Contents().GetInt(offset), nil
+	defer release()
+	return p.GetLong(offset), nil
 }
 
 // Retrieves string values with shared locking
 func (tx *Transaction) GetString(block file.BlockID, offset int) (string, error) {
-	// Acquire shared locks for concurrent reads
-	if err := tx.cm.SLock(block); err != nil {
+	p, release, err := tx.readPage(block)
+	if err != nil {
 		return "", err
 	}
+	defer release()
+
+	return p.GetString(offset), nil
+}
 
-	// Get and pin buffer
+// Returns the page holding the contents of the pinned block as the
+// transaction sees them. At SNAPSHOT isolation it is a copy of the block
+// as it was when the transaction started, read without a lock, unless the
+// transaction changed the block itself. Otherwise it is the page of the
+// block's buffer, read under a shared lock. The returned function must be
+// called once the value is read, releasing the lock if it isn't held until
+// the transaction ends, see ConcurrencyManager.SLockRead.
+func (tx *Transaction) readPage(block file.BlockID) (*file.Page, func(), error) {
+	if tx.snapshot != nil && !tx.cm.xLocked(block) {
+		p, err := tx.snapshotPage(block)
+		return p, func() {}, err
+	}
+
+	release, err := tx.cm.SLockRead(block)
+	lockFailed(err)
 	buff, err := tx.myBuffers.GetBuffer(block)
 	if err != nil {
-		return "", err
+		release()
+		return nil, nil, err
+	}
+	return buff.Contents(), release, nil
+}
+
+// Returns the block as the transaction's snapshot sees it. Later changes by
+// other transactions are outside the snapshot, so the copies of the blocks
+// read last are kept and read again.
+func (tx *Transaction) snapshotPage(block file.BlockID) (*file.Page, error) {
+	if p, ok := tx.snapPages[block]; ok {
+		return p, nil
+	}
+
+	buff, err := tx.myBuffers.GetBuffer(block)
+	if err != nil {
+		return nil, err
 	}
+	p := file.NewPage(tx.fm.FileBlockSize(block.FileName()))
+	lockFailed(tx.versions.read(block, buff.Contents(), p, tx.snapshot))
 
-	return buff.Contents().GetString(offset), nil
+	if tx.snapPages == nil {
+		tx.snapPages = make(map[file.BlockID]*file.Page)
+	}
+	if len(tx.snapOrder) == SNAPSHOT_CACHE_BLOCKS {
+		delete(tx.snapPages, tx.snapOrder[0])
+		tx.snapOrder = tx.snapOrder[1:]
+	}
+	tx.snapPages[block] = p
+	tx.snapOrder = append(tx.snapOrder, block)
+	return p, nil
+}
+
+// Acquires the exclusive lock a write to the block needs. At SNAPSHOT
+// isolation, a block changed by a transaction the snapshot doesn't see
+// fails with ErrSerializationFailure, and the copy read from the
+// snapshot is dropped, since reads now go to the block itself.
+// A read-only transaction takes no lock, and panics with
+// ErrReadOnlyTransaction unless the block is a temp table's.
+func (tx *Transaction) writeLock(block file.BlockID) {
+	if tx.readOnly {
+		if !tx.Writable(block.FileName()) {
+			panic(fmt.Errorf("%w: %s", ErrReadOnlyTransaction, block.FileName()))
+		}
+		return
+	}
+	lockFailed(tx.cm.XLock(block))
+	if tx.snapshot == nil || block.Number() == EndOfFile {
+		return
+	}
+	lockFailed(tx.versions.checkWrite(block, tx.snapshot))
+	if _, ok := tx.snapPages[block]; ok {
+		delete(tx.snapPages, block)
+		for i, cached := range tx.snapOrder {
+			if cached == block {
+				tx.snapOrder = append(tx.snapOrder[:i], tx.snapOrder[i+1:]...)
+				break
+			}
+		}
+	}
 }
 
 // Writes integer value with exclusive locking
 func (tx *Transaction) SetInt(block file.BlockID, offset int, val int, okToLog bool) error {
 	// Axcquire exclusive lock for writing,
 	// Only one transaction can write to this block at a time
-	if err := tx.cm.XLock(block); err != nil {
-		return err
-	}
+	tx.writeLock(block)
 
 	// Get and pin buffer to prevent it from being replalced
 	// while we're modifying its contents
@@ -140,17 +402,21 @@ func (tx *Transaction) SetInt(block file.BlockID, offset int, val int, okToLog b
 	}
 
 	lsn := -1 // Log sequence number for recovery tracking
+	p := buff.Contents()
 
 	// If logging is enabled, create a recovery log entry
 	// This ensures durability in case of crashes
+	// The undo of the change is kept for the snapshots that don't see it
+	var undo pageUndoer
 	if okToLog {
+		undo = &SetIntRecord{txNum: int(tx.txnum), offset: offset, val: int(p.GetInt(offset)), block: &block}
 		lsn = tx.rm.SetInt(buff, offset, val)
+	} else if p.GetInt(offset) != int32(val) {
+		tx.rm.unloggedChange(block)
 	}
 
-	// Get the page contents and update the interger value
-	// at the specified offset
-	p := buff.Contents()
-	p.SetInt(offset, int32(val))
+	// Update the interger value at the specified offset
+	tx.versions.write(block, tx.txnum, undo, func() { p.SetInt(offset, int32(val)) })
 
 	// Mark the buffer as modified with this transaction's ID
 	// and the log sequence number for recovery purposes
@@ -158,12 +424,32 @@ func (tx *Transaction) SetInt(block file.BlockID, offset int, val int, okToLog b
 	return nil
 }
 
+// Writes a 64-bit integer with exclusive locking, logging the change if okToLog
+func (tx *Transaction) SetLong(block file.BlockID, offset int, val int64, okToLog bool) error {
+	tx.writeLock(block)
+	buff, err := tx.myBuffers.GetBuffer(block)
+	if err != nil {
+		return err
+	}
+
+	lsn := -1
+	p := buff.Contents()
+	var undo pageUndoer
+	if okToLog {
+		undo = &SetLongRecord{txNum: int(tx.txnum), offset: offset, val: p.GetLong(offset), block: &block}
+		lsn = tx.rm.SetLong(buff, offset, val)
+	} else if p.GetLong(offset) != val {
+		tx.rm.unloggedChange(block)
+	}
+	tx.versions.write(block, tx.txnum, undo, func() { p.SetLong(offset, val) })
+	buff.SetModified(int(tx.txnum), lsn)
+	return nil
+}
+
 // Writes a string value to a specific block location with exclusive locking
 func (tx *Transaction) SetString(block file.BlockID, offset int, val string, okToLog bool) error {
 	// Acquire exclusive lock for writing to prevent concurrent modifications
-	if err := tx.cm.XLock(block); err != nil {
-		return err
-	}
+	tx.writeLock(block)
 
 	// Get and pin the buffer containing our target block
 	buff, err := tx.myBuffers.GetBuffer(block)
@@ -173,13 +459,17 @@ func (tx *Transaction) SetString(block file.BlockID, offset int, val string, okT
 
 	// Track modifications for recovery if logging is enabled
 	lsn := -1
+	p := buff.Contents()
+	var undo pageUndoer
 	if okToLog {
+		undo = &SetStringRecord{txnum: int(tx.txnum), offset: offset, val: p.GetString(offset), block: &block}
 		lsn = tx.rm.SetString(buff, offset, val)
+	} else if !holdsString(p, offset, val) {
+		tx.rm.unloggedChange(block)
 	}
 
 	// Update the string value in the buffer's contents
-	p := buff.Contents()
-	p.SetString(offset, val)
+	tx.versions.write(block, tx.txnum, undo, func() { p.SetString(offset, val) })
 
 	// Mark buffer as modified for this transaction
 	buff.SetModified(int(tx.txnum), lsn)
@@ -187,6 +477,49 @@ func (tx *Transaction) SetString(block file.BlockID, offset int, val string, okT
 	return nil
 }
 
+// Returns the length of the longest string a string of length valLen in the
+// file can be changed to with the change logged, the log record holding both
+// values having to fit in a log block. The strings of a table whose blocks
+// are larger than the system's may be too long for it.
+func (tx *Transaction) MaxLoggedString(filename string, valLen int) int {
+	// A log block starts with the position of its last record
+	return tx.lm.BlockSize() - 4 - log.RECORD_OVERHEAD - setStringRecordSize(filename, valLen, 0)
+}
+
+// Returns a copy of the contents of the block
+func (tx *Transaction) readImage(block file.BlockID) []byte {
+	tx.Pin(&block)
+	defer tx.Unpin(&block)
+	buff, err := tx.myBuffers.GetBuffer(block)
+	if err != nil {
+		return nil
+	}
+	return bytes.Clone(buff.Contents().Contents())
+}
+
+// Writes the bytes of an image of the block at offset, without logging them
+func (tx *Transaction) writeImage(block file.BlockID, offset int, image []byte) error {
+	tx.writeLock(block)
+	buff, err := tx.myBuffers.GetBuffer(block)
+	if err != nil {
+		return err
+	}
+
+	contents := buff.Contents().Contents()
+	if !bytes.Equal(contents[offset:offset+len(image)], image) {
+		tx.rm.unloggedChange(block)
+	}
+	tx.versions.write(block, tx.txnum, nil, func() { copy(contents[offset:], image) })
+	buff.SetModified(int(tx.txnum), -1)
+	return nil
+}
+
+// Reports whether the page holds val at offset. The bytes there needn't be
+// a string, e.g. while a rollback restores strings that were moved.
+func holdsString(p *file.Page, offset int, val string) bool {
+	return int(p.GetInt(offset)) == len(val) && p.GetString(offset) == val
+}
+
 // Returns the number of blocks in a file, using shared locking
 func (tx *Transaction) Size(filename string) (int, error) {
 	// Create a dummy block for the end of the file
@@ -194,8 +527,12 @@ func (tx *Transaction) Size(filename string) (int, error) {
 	dummyBlock := file.NewBlockID(filename, EndOfFile)
 
 	// Acquire shared lock since we're only reading file metadata
-	if err := tx.cm.SLock(*dummyBlock); err != nil {
-		return 0, err
+	// A snapshot reads without it, the records of appended blocks
+	// being outside the snapshot
+	if tx.snapshot == nil {
+		release, err := tx.cm.SLockRead(*dummyBlock)
+		lockFailed(err)
+		defer release()
 	}
 
 	// Get the file length in blocks and return if no error
@@ -213,9 +550,7 @@ func (tx *Transaction) Append(filename string) (file.BlockID, error) {
 	dummyBlock := file.NewBlockID(filename, EndOfFile)
 
 	// Get exclusive lock since we're modifying file structure
-	if err := tx.cm.XLock(*dummyBlock); err != nil {
-		return file.BlockID{}, err
-	}
+	tx.writeLock(*dummyBlock)
 
 	// Append new block and returns its ID
 	block, err := tx.fm.Append(filename)
@@ -225,12 +560,27 @@ func (tx *Transaction) Append(filename string) (file.BlockID, error) {
 	return *block, nil
 }
 
+// Removes a file along with any of its blocks held in the buffer pool.
+// Its blocks must be unpinned. The removal can't be undone, so it is
+// meant for files whose changes are never logged, such as temp tables,
+// or for files removed once the transaction commits, see OnCommit.
+func (tx *Transaction) RemoveFile(filename string) error {
+	tx.bm.Discard(filename)
+	return tx.fm.Remove(filename)
+}
+
 // Returns the system's block size in bytes
 func (tx *Transaction) BlockSize() int {
 	// This is a constant value that does`nt need locking
 	return tx.fm.BlockSize()
 }
 
+// Returns the size of the blocks of a file, the system's block size unless
+// the file is of a table created with another, see file.SizedFileName
+func (tx *Transaction) FileBlockSize(filename string) int {
+	return tx.fm.FileBlockSize(filename)
+}
+
 // Returns the current number of free buffers in the pool
 func (tx *Transaction) AvailableBuffers() int {
 	// Get current count of available buffers
@@ -238,6 +588,16 @@ func (tx *Transaction) AvailableBuffers() int {
 	return tx.bm.Available()
 }
 
+// Panics with the error of a lock that couldn't be acquired: LockAbortError,
+// DeadlockError or ErrQueryCancelled. The pages reading and writing records
+// have no way to return it, so the statement fails the way a cancelled one
+// does, rather than going on without the read or write.
+func lockFailed(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
 // Generates the next transaction number automatically
 func nextTmNumber() int64 {
 	next := nextTxNum.Add(1)
@@ -0,0 +1,313 @@
+package tx
+
+import (
+	"centauri/internal/app/file"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Returned when a transaction running at SNAPSHOT isolation writes a block
+// that a transaction outside its snapshot changed: the first to write wins
+var ErrSerializationFailure = errors.New("could not serialize access due to a concurrent update")
+
+// The most blocks a snapshot transaction keeps its copies of
+const SNAPSHOT_CACHE_BLOCKS = 16
+
+// The most changes the version store of a database keeps by default
+const MAX_SNAPSHOT_VERSIONS = 1 << 18
+
+// Wraps ErrSnapshotTooOld for a snapshot whose changes were discarded
+var errSnapshotExpired = fmt.Errorf("%w: the versions it reads were discarded", ErrSnapshotTooOld)
+
+// Keeps the previous versions of the blocks the transactions of a database
+// change, as the undo of each logged change, so that a transaction reading
+// at SNAPSHOT isolation can rebuild a block as it was when it started
+// without waiting for the locks of the writers. The changes of a finished
+// transaction are dropped once every running snapshot holds them.
+//
+// Like CopyAsOf, changes made without logging aren't kept, so they are
+// part of every snapshot.
+//
+// The changes are kept in memory only. Snapshots end with the transactions
+// reading them, so none survives a restart, and recovery doesn't rebuild
+// the store. Once it holds more changes than its limit, the oldest
+// snapshots are expired until it is back within it, and the reads and
+// writes of an expired snapshot fail with ErrSnapshotTooOld.
+type versionStore struct {
+	mu        sync.Mutex            // Protects the fields below, taken before a shard's mutex, never after
+	active    map[int64]bool        // The transactions that haven't finished
+	finished  map[int64]bool        // The finished transactions whose changes are still kept
+	snapshots map[int64]*txSnapshot // The snapshot of each running SNAPSHOT transaction
+	unkept    map[int64]bool        // The running transactions whose changes are no longer kept
+	shards    [LOCK_SHARDS]*versionShard
+	kept      atomic.Int64 // The number of changes kept
+	limit     atomic.Int64 // The most changes kept before snapshots are expired
+	skipped   sync.Map     // The transactions of unkept, read without the mutex
+}
+
+// Holds the changes of the blocks whose hash code falls in the shard.
+// A block is changed under its mutex, so a copy taken under it
+// always matches the changes listed.
+type versionShard struct {
+	mu      sync.Mutex
+	changes map[file.BlockID][]version      // The kept changes of each block, oldest first
+	byTx    map[int64]map[file.BlockID]bool // The blocks each transaction changed
+}
+
+// A logged change of a block by a transaction
+type version struct {
+	txnum int64
+	undo  pageUndoer
+}
+
+// The transactions whose changes a SNAPSHOT transaction sees: its own, and
+// those of the transactions that finished before it started
+type txSnapshot struct {
+	txnum   int64
+	active  map[int64]bool // The transactions running when the snapshot was taken
+	expired atomic.Bool    // Whether changes the snapshot doesn't see were discarded
+}
+
+// Reports whether the changes of the transaction txnum are part of the snapshot
+func (s *txSnapshot) sees(txnum int64) bool {
+	return txnum == s.txnum || (txnum < s.txnum && !s.active[txnum])
+}
+
+// The version store of each database, keyed by the file manager of its files
+var versionStores sync.Map
+
+// Returns the version store shared by the transactions of the database
+// whose files fm manages
+func versionStoreFor(fm *file.FileManager) *versionStore {
+	if vs, ok := versionStores.Load(fm); ok {
+		return vs.(*versionStore)
+	}
+	vs, _ := versionStores.LoadOrStore(fm, newVersionStore())
+	return vs.(*versionStore)
+}
+
+// Sets the most changes the version store of the database whose files fm
+// manages keeps for its SNAPSHOT transactions, MAX_SNAPSHOT_VERSIONS by
+// default. Beyond it, the oldest snapshots fail with ErrSnapshotTooOld.
+func SetSnapshotVersionLimitFor(fm *file.FileManager, limit int) {
+	vs := versionStoreFor(fm)
+	vs.limit.Store(int64(max(limit, 0)))
+	vs.trim()
+}
+
+func newVersionStore() *versionStore {
+	vs := &versionStore{
+		active:    make(map[int64]bool),
+		finished:  make(map[int64]bool),
+		snapshots: make(map[int64]*txSnapshot),
+		unkept:    make(map[int64]bool),
+	}
+	vs.limit.Store(MAX_SNAPSHOT_VERSIONS)
+	for i := range vs.shards {
+		vs.shards[i] = &versionShard{
+			changes: make(map[file.BlockID][]version),
+			byTx:    make(map[int64]map[file.BlockID]bool),
+		}
+	}
+	return vs
+}
+
+// Returns the shard holding the changes of the block
+func (vs *versionStore) shard(block file.BlockID) *versionShard {
+	return vs.shards[uint(block.HashCode())%LOCK_SHARDS]
+}
+
+// Numbers a new transaction and records it as running. The number is taken
+// under the mutex, so every transaction missing from a snapshot's list of
+// running ones either finished before it or has a larger number. Returns
+// the snapshot of the transaction if it runs at SNAPSHOT isolation, which
+// is expired from the start if the changes of a running transaction are no
+// longer kept.
+func (vs *versionStore) begin(level IsolationLevel) (int64, *txSnapshot) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	txnum := nextTmNumber()
+	var snap *txSnapshot
+	if level == SNAPSHOT {
+		snap = &txSnapshot{txnum: txnum, active: make(map[int64]bool, len(vs.active))}
+		for running := range vs.active {
+			snap.active[running] = true
+		}
+		if len(vs.unkept) > 0 {
+			snap.expired.Store(true)
+		} else {
+			vs.snapshots[txnum] = snap
+		}
+	}
+	vs.active[txnum] = true
+	return txnum, snap
+}
+
+// Records that the transaction finished, and drops the changes no running
+// snapshot needs anymore. The changes of a transaction that rolled back are
+// undone in the blocks already, so they are dropped right away. It must be
+// called before the transaction releases its locks, so that the changes a
+// snapshot undoes are always newer than those it keeps.
+func (vs *versionStore) end(txnum int64, committed bool) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	delete(vs.active, txnum)
+	delete(vs.snapshots, txnum)
+	if vs.unkept[txnum] {
+		delete(vs.unkept, txnum)
+		vs.skipped.Delete(txnum)
+	}
+	if committed {
+		vs.finished[txnum] = true
+	} else {
+		vs.drop(txnum)
+	}
+	vs.collect()
+}
+
+// Drops the changes of the finished transactions every running snapshot sees
+func (vs *versionStore) collect() {
+	for done := range vs.finished {
+		if vs.seenByAll(done) {
+			vs.drop(done)
+			delete(vs.finished, done)
+		}
+	}
+}
+
+// Brings the number of kept changes back within the limit by expiring the
+// oldest snapshots, whose changes are dropped once no other snapshot needs
+// them. With no snapshot left, the changes kept are those of running
+// transactions, which stop being kept; snapshots taken before they finish
+// are expired from the start, as they would see their changes.
+func (vs *versionStore) trim() {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	for vs.kept.Load() > vs.limit.Load() && len(vs.snapshots) > 0 {
+		var oldest *txSnapshot
+		for _, snap := range vs.snapshots {
+			if oldest == nil || snap.txnum < oldest.txnum {
+				oldest = snap
+			}
+		}
+		oldest.expired.Store(true)
+		delete(vs.snapshots, oldest.txnum)
+		vs.collect()
+	}
+
+	if vs.kept.Load() > vs.limit.Load() {
+		for txnum := range vs.active {
+			vs.unkept[txnum] = true
+			vs.skipped.Store(txnum, true)
+			vs.drop(txnum)
+		}
+	}
+}
+
+// Reports whether every running snapshot sees the changes of the transaction
+func (vs *versionStore) seenByAll(txnum int64) bool {
+	for _, snap := range vs.snapshots {
+		if !snap.sees(txnum) {
+			return false
+		}
+	}
+	return true
+}
+
+// Drops the kept changes of the transaction
+func (vs *versionStore) drop(txnum int64) {
+	for _, s := range vs.shards {
+		s.mu.Lock()
+		for block := range s.byTx[txnum] {
+			kept := s.changes[block][:0]
+			for _, v := range s.changes[block] {
+				if v.txnum != txnum {
+					kept = append(kept, v)
+				}
+			}
+			vs.kept.Add(int64(len(kept) - len(s.changes[block])))
+			if len(kept) == 0 {
+				delete(s.changes, block)
+			} else {
+				s.changes[block] = kept
+			}
+		}
+		delete(s.byTx, txnum)
+		s.mu.Unlock()
+	}
+}
+
+// Changes the block by calling write, keeping undo as the change of the
+// transaction txnum unless it is nil or the transaction's changes are no
+// longer kept
+func (vs *versionStore) write(block file.BlockID, txnum int64, undo pageUndoer, write func()) {
+	if _, skipped := vs.skipped.Load(txnum); skipped {
+		undo = nil
+	}
+
+	s := vs.shard(block)
+	s.mu.Lock()
+	write()
+	if undo != nil {
+		if s.byTx[txnum] == nil {
+			s.byTx[txnum] = make(map[file.BlockID]bool)
+		}
+		s.byTx[txnum][block] = true
+		s.changes[block] = append(s.changes[block], version{txnum: txnum, undo: undo})
+	}
+	s.mu.Unlock()
+
+	if undo != nil && vs.kept.Add(1) > vs.limit.Load() {
+		vs.trim()
+	}
+}
+
+// Copies the contents of the block from current into p, with the changes
+// the snapshot doesn't see undone. Fails with ErrSnapshotTooOld if the
+// snapshot expired.
+func (vs *versionStore) read(block file.BlockID, current *file.Page, p *file.Page, snap *txSnapshot) error {
+	s := vs.shard(block)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Checked under the mutex, as the changes are dropped under it
+	if snap.expired.Load() {
+		return errSnapshotExpired
+	}
+	copy(p.Contents(), current.Contents())
+
+	// Undone newest first, so each value ends up as it
+	// was before the earliest change that isn't seen
+	changes := s.changes[block]
+	for i := len(changes) - 1; i >= 0; i-- {
+		if !snap.sees(changes[i].txnum) {
+			changes[i].undo.UndoPage(p)
+		}
+	}
+	return nil
+}
+
+// Fails with ErrSerializationFailure if a transaction the snapshot doesn't
+// see changed the block, or with ErrSnapshotTooOld if the snapshot expired
+// and no longer tells. The caller holds an exclusive lock on the block, so
+// no other transaction changes it afterwards.
+func (vs *versionStore) checkWrite(block file.BlockID, snap *txSnapshot) error {
+	s := vs.shard(block)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if snap.expired.Load() {
+		return errSnapshotExpired
+	}
+	for _, v := range s.changes[block] {
+		if !snap.sees(v.txnum) {
+			return ErrSerializationFailure
+		}
+	}
+	return nil
+}
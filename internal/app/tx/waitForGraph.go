@@ -0,0 +1,89 @@
+package tx
+
+import (
+	"errors"
+	"sync"
+)
+
+// Returned to a transaction whose lock wait would close a cycle of
+// transactions waiting for each other. The transaction should be rolled
+// back, which lets the others go on, and can then be tried again.
+var DeadlockError = errors.New("deadlock detected")
+
+// Records which transactions wait for which others to release a lock on a
+// block. A transaction waits for a single block at a time, so its edges
+// all come from that wait.
+type waitForGraph struct {
+	mu    sync.Mutex               // Protects the edges
+	edges map[int64]map[int64]bool // The transactions each waiting transaction waits for
+}
+
+func newWaitForGraph() *waitForGraph {
+	return &waitForGraph{edges: make(map[int64]map[int64]bool)}
+}
+
+// Records that the waiter waits for the holders, replacing what it waited
+// for before. If the wait closes a cycle, it isn't recorded and the cycle is
+// returned, from the waiter back to it; otherwise returns nil.
+func (g *waitForGraph) wait(waiter int64, holders []int64) []int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	edges := make(map[int64]bool, len(holders))
+	for _, holder := range holders {
+		edges[holder] = true
+	}
+	g.edges[waiter] = edges
+
+	if cycle := g.pathTo(waiter, waiter, make(map[int64]bool)); cycle != nil {
+		delete(g.edges, waiter)
+		return cycle
+	}
+	return nil
+}
+
+// Records that the waiter also waits for the holder, which was granted a
+// lock on the block the waiter waits for. A transaction that was just granted
+// a lock isn't waiting, so the edge can't close a cycle.
+func (g *waitForGraph) addEdge(waiter int64, holder int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if edges, ok := g.edges[waiter]; ok {
+		edges[holder] = true
+	}
+}
+
+// Records that the waiter no longer waits for the holder, which released
+// its lock on the block the waiter waits for
+func (g *waitForGraph) removeEdge(waiter int64, holder int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.edges[waiter], holder)
+}
+
+// Records that the waiter stopped waiting
+func (g *waitForGraph) stopWaiting(waiter int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.edges, waiter)
+}
+
+// Returns a path of edges from a transaction to the target, or nil if there
+// is none. Transactions in visited have already been searched.
+func (g *waitForGraph) pathTo(from int64, target int64, visited map[int64]bool) []int64 {
+	visited[from] = true
+	for next := range g.edges[from] {
+		if next == target {
+			return []int64{from, next}
+		}
+		if !visited[next] {
+			if path := g.pathTo(next, target, visited); path != nil {
+				return append([]int64{from}, path...)
+			}
+		}
+	}
+	return nil
+}
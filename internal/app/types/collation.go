@@ -0,0 +1,109 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Names of the built-in collations. Any other name is taken as a BCP 47
+// language tag, e.g. 'de' or 'sv-SE', and compares strings the way the
+// language sorts them.
+const (
+	COLLATION_BINARY = "binary" // compares the bytes of the strings, the default
+	COLLATION_NOCASE = "nocase" // ignores the case of letters
+)
+
+var ErrUnknownCollation = errors.New("unknown collation")
+
+// Defines how the strings of a VARCHAR column are compared.
+// Two strings compare like their sort keys, so strings with equal keys
+// are equal and hash alike.
+type Collation struct {
+	name string
+	key  func(s string) []byte // nil for the binary collation
+}
+
+// Collations are built once, the locale-aware ones are expensive to create
+var collations sync.Map
+
+// Returns the collation with the specified name.
+// Returns ErrUnknownCollation if the name is neither a built-in collation
+// nor a language tag.
+func LookupCollation(name string) (*Collation, error) {
+	name = strings.ToLower(name)
+	if c, ok := collations.Load(name); ok {
+		return c.(*Collation), nil
+	}
+
+	c := &Collation{name: name}
+	switch name {
+	case COLLATION_BINARY:
+	case COLLATION_NOCASE:
+		c.key = func(s string) []byte { return []byte(strings.ToLower(s)) }
+	default:
+		tag, err := language.Parse(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownCollation, name)
+		}
+		c.key = localeKey(collate.New(tag))
+	}
+
+	actual, _ := collations.LoadOrStore(name, c)
+	return actual.(*Collation), nil
+}
+
+// Returns the collation with the specified name, or nil for the binary
+// collation and names that aren't collations
+func CollationNamed(name string) *Collation {
+	if name == "" || name == COLLATION_BINARY {
+		return nil
+	}
+	c, err := LookupCollation(name)
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// Builds the sort keys of a language. A collator can't be shared
+// between goroutines, so its use is serialized.
+func localeKey(col *collate.Collator) func(s string) []byte {
+	var mu sync.Mutex
+	return func(s string) []byte {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var buf collate.Buffer
+		return bytes.Clone(col.KeyFromString(&buf, s))
+	}
+}
+
+// Returns the name of the collation
+func (c *Collation) Name() string {
+	if c == nil {
+		return COLLATION_BINARY
+	}
+	return c.name
+}
+
+// Returns the sort key of a string
+func (c *Collation) Key(s string) []byte {
+	if c == nil || c.key == nil {
+		return []byte(s)
+	}
+	return c.key(s)
+}
+
+// Compares two strings, returning -1, 0 or 1
+func (c *Collation) Compare(a string, b string) int {
+	if c == nil || c.key == nil {
+		return strings.Compare(a, b)
+	}
+	return bytes.Compare(c.key(a), c.key(b))
+}
@@ -3,16 +3,25 @@ package types
 import (
 	"fmt"
 	"hash/fnv"
-	"strings"
+	"math"
+	"strconv"
 
 	"golang.org/x/text/unicode/norm"
 )
 
-// Represents a value that can be either an integer or a string.
+// Represents a value that can be either an integer, a 64-bit integer,
+// a floating-point number, a date, a timestamp, a string, a boolean or NULL.
 // Implements comparable operations and string conversion.
 type Constant struct {
-	iVal *int
-	sVal *string
+	iVal  *int
+	lVal  *int64
+	unit  int64 // seconds per unit of a date or timestamp held in lVal, 0 for others
+	fVal  *float64
+	sVal  *string
+	bVal  *bool
+	coll  *Collation // how a string compares, nil for binary
+	null  bool
+	param *int // position of the parameter the constant stands for, see NewConstantParameter
 }
 
 func NewConstantInt(iVal int) *Constant {
@@ -21,42 +30,168 @@ func NewConstantInt(iVal int) *Constant {
 	}
 }
 
+func NewConstantLong(lVal int64) *Constant {
+	return &Constant{
+		lVal: &lVal,
+	}
+}
+
+// Creates an int constant, or a 64-bit integer constant if the value
+// doesn't fit in 32 bits, as for integers written in SQL
+func NewConstantInteger(n int64) *Constant {
+	if n < math.MinInt32 || n > math.MaxInt32 {
+		return NewConstantLong(n)
+	}
+	return NewConstantInt(int(n))
+}
+
+func NewConstantFloat(fVal float64) *Constant {
+	return &Constant{
+		fVal: &fVal,
+	}
+}
+
 func NewConstantString(sVal string) *Constant {
 	return &Constant{
 		sVal: &sVal,
 	}
 }
 
+// Creates a boolean constant, TRUE or FALSE
+func NewConstantBool(bVal bool) *Constant {
+	return &Constant{
+		bVal: &bVal,
+	}
+}
+
+// Creates the NULL constant, the value of a field that has none
+func NewConstantNull() *Constant {
+	return &Constant{
+		null: true,
+	}
+}
+
+// Creates a placeholder for the parameter at the position, from 0, of a
+// prepared statement. It has no value of its own and is replaced by the
+// value bound to the parameter before the statement runs.
+func NewConstantParameter(index int) *Constant {
+	return &Constant{
+		param: &index,
+	}
+}
+
+// Reports whether the constant is a parameter placeholder
+func (c *Constant) IsParameter() bool {
+	return c != nil && c.param != nil
+}
+
+// Returns the position of the parameter a placeholder stands for
+func (c *Constant) ParameterIndex() int {
+	return *c.param
+}
+
+// Creates a string constant that compares according to the collation
+func NewConstantStringWithCollation(sVal string, coll *Collation) *Constant {
+	return &Constant{
+		sVal: &sVal,
+		coll: coll,
+	}
+}
+
+// Reports whether the constant is NULL. A nil constant, the value of
+// an expression computing nothing, is NULL too.
+func (c *Constant) IsNull() bool {
+	return c == nil || c.null
+}
+
 // Returns the integer value
 func (c *Constant) AsInt() *int {
 	return c.iVal
 }
 
+// Returns the 64-bit integer value
+func (c *Constant) AsLong() *int64 {
+	return c.lVal
+}
+
+// Returns the floating-point value
+func (c *Constant) AsFloat() *float64 {
+	return c.fVal
+}
+
 // Returns the string value
 func (c *Constant) AsString() *string {
 	return c.sVal
 }
 
-// Compares this Constant with another value.
-func (c *Constant) Equals(obj interface{}) bool {
-	otherConst, ok := obj.(*Constant)
+// Returns the boolean value
+func (c *Constant) AsBool() *bool {
+	return c.bVal
+}
 
-	if !ok {
-		return false
+// Returns the collation of a string constant, nil for binary
+func (c *Constant) Collation() *Collation {
+	return c.coll
+}
+
+// Returns the constant compared according to the collation.
+// Constants other than strings are returned as they are.
+func (c *Constant) Collate(coll *Collation) *Constant {
+	if c.sVal == nil || c.coll == coll {
+		return c
 	}
+	return NewConstantStringWithCollation(*c.sVal, coll)
+}
 
-	if c.iVal != nil && otherConst.iVal != nil {
-		return *c.iVal == *otherConst.iVal
+// Constants of different kinds are never equal and are ordered by kind,
+// so every pair of constants can be compared. Numbers of any type are
+// compared by value, and no number ever equals a string, even one that
+// spells it: strings are not converted to numbers or the other way around.
+// Dates and timestamps compare by the time they stand for, and FALSE
+// comes before TRUE.
+const (
+	KIND_NULL   = iota // the NULL constant, ordered first
+	KIND_NUMBER        // int, 64-bit int and float constants
+	KIND_STRING        // string constants
+	KIND_TIME          // date and timestamp constants
+	KIND_BOOL          // boolean constants
+)
+
+// Returns the kind of the constant, which orders constants of different types
+func (c *Constant) Kind() int {
+	if c.null {
+		return KIND_NULL
+	}
+	if c.unit != 0 {
+		return KIND_TIME
 	}
+	if c.bVal != nil {
+		return KIND_BOOL
+	}
+	if c.isNumeric() {
+		return KIND_NUMBER
+	}
+	return KIND_STRING
+}
+
+// Compares this Constant with another value.
+// Constants are equal when CompareTo reports them as such.
+func (c *Constant) Equals(obj interface{}) bool {
+	otherConst, ok := obj.(*Constant)
 
-	if c.sVal != nil && otherConst.sVal != nil {
-		return *c.sVal == *otherConst.sVal
+	if !ok || otherConst == nil {
+		return false
 	}
 
-	return false
+	return c.CompareTo(otherConst) == 0
 }
 
-// Implements comparision between Constants
+// Implements comparision between Constants.
+// The order is total: NULL comes first and equals itself, so that sorting,
+// grouping and indexes keep the records without a value together, then
+// numbers and then strings. Numbers of any type are ordered by value with
+// NaN after every other number, and strings are ordered by their bytes.
+// Predicates don't compare NULL this way, see query.Term.
 func (c *Constant) CompareTo(other *Constant) int {
 	if c.iVal != nil && other.iVal != nil {
 		if *c.iVal < *other.iVal {
@@ -67,11 +202,37 @@ func (c *Constant) CompareTo(other *Constant) int {
 		return 0
 	}
 
-	if c.sVal != nil && other.sVal != nil {
-		return strings.Compare(*c.sVal, *other.sVal)
+	if kind, otherKind := c.Kind(), other.Kind(); kind != otherKind {
+		if kind < otherKind {
+			return -1
+		}
+		return 1
+	} else if kind == KIND_NULL {
+		return 0
+	} else if kind == KIND_TIME {
+		return compareInts(c.seconds(), other.seconds())
+	} else if kind == KIND_BOOL {
+		return compareInts(boolInt(*c.bVal), boolInt(*other.bVal))
+	}
+
+	// Strings compare according to the collation of the first
+	// operand that has one, usually the one read from a column
+	if c.sVal != nil || other.sVal != nil {
+		coll := c.coll
+		if coll == nil {
+			coll = other.coll
+		}
+		return coll.Compare(c.String(), other.String())
+	}
+
+	// Integers of either width compare by value
+	if a, ok := c.AsInteger(); ok {
+		if b, ok := other.AsInteger(); ok {
+			return compareInts(a, b)
+		}
 	}
 
-	panic("Cannot compare constants of different types")
+	return compareNumeric(c, other)
 }
 
 // Generates a Hash code for the constant.
@@ -79,10 +240,32 @@ func (c *Constant) CompareTo(other *Constant) int {
 func (c *Constant) HashCode() uint64 {
 	h := fnv.New64()
 
-	if c.iVal != nil {
+	if c.null {
+		// Every NULL hashes alike, as they are equal
+		h.Write([]byte{0})
+	} else if c.unit != 0 {
+		// Dates hash like the timestamps of their midnight, which they equal
+		h.Write([]byte(fmt.Sprintf("t%d", c.seconds())))
+	} else if c.bVal != nil {
+		h.Write([]byte(fmt.Sprintf("b%t", *c.bVal)))
+	} else if c.iVal != nil {
 		// For integer values, convert to string then to bytes
 		intBytes := []byte(fmt.Sprintf("%d", *c.iVal))
 		h.Write(intBytes)
+	} else if c.lVal != nil {
+		// Hashed like an int, so equal integers of either width hash alike
+		h.Write([]byte(fmt.Sprintf("%d", *c.lVal)))
+	} else if c.fVal != nil {
+		// Whole numbers are hashed like ints, which they equal
+		f := *c.fVal
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			h.Write([]byte(fmt.Sprintf("%d", int64(f))))
+		} else {
+			h.Write([]byte(strconv.FormatFloat(f, 'g', -1, 64)))
+		}
+	} else if c.sVal != nil && c.coll != nil {
+		// Strings that are equal under the collation share the sort key
+		h.Write(c.coll.Key(*c.sVal))
 	} else if c.sVal != nil {
 		// For string values, normalize Unicode and convert to bytes
 		normalized := norm.NFKC.String(*c.sVal)
@@ -94,9 +277,136 @@ func (c *Constant) HashCode() uint64 {
 
 // Returns a string representation of the constant
 func (c *Constant) String() string {
+	if c.null {
+		return "NULL"
+	}
+	if c.param != nil {
+		return "?"
+	}
+
 	if c.iVal != nil {
 		return fmt.Sprintf("%d", *c.iVal)
 	}
 
+	if c.IsDate() {
+		return c.Time().Format(DATE_LAYOUT)
+	}
+	if c.IsTimestamp() {
+		return c.Time().Format(TIMESTAMP_LAYOUT)
+	}
+	if c.lVal != nil {
+		return fmt.Sprintf("%d", *c.lVal)
+	}
+
+	if c.fVal != nil {
+		return strconv.FormatFloat(*c.fVal, 'g', -1, 64)
+	}
+
+	if c.bVal != nil {
+		return strconv.FormatBool(*c.bVal)
+	}
+
+	if c.sVal == nil {
+		return ""
+	}
 	return *c.sVal
 }
+
+// Reports whether the constant holds a number of any type. Dates and
+// timestamps hold 64-bit integers, but aren't numbers.
+func (c *Constant) isNumeric() bool {
+	return c.unit == 0 && (c.iVal != nil || c.lVal != nil || c.fVal != nil)
+}
+
+// Returns the value of an integer constant of either width, and whether
+// the constant is one. Dates and timestamps aren't integers.
+func (c *Constant) AsInteger() (int64, bool) {
+	if c.unit != 0 {
+		return 0, false
+	}
+	if c.iVal != nil {
+		return int64(*c.iVal), true
+	}
+	if c.lVal != nil {
+		return *c.lVal, true
+	}
+	return 0, false
+}
+
+// Returns the value of a number of any type as a float, and whether
+// the constant is one. Dates and timestamps aren't numbers.
+func (c *Constant) AsNumber() (float64, bool) {
+	if c.fVal != nil {
+		return *c.fVal, true
+	}
+	n, ok := c.AsInteger()
+	return float64(n), ok
+}
+
+// Compares two numeric constants, at least one of which is a float.
+// NaN is greater than every other number and equal to itself, so numbers
+// have a total order. Integers are compared exactly rather than converted to
+// floats, which can't represent every 64-bit integer.
+func compareNumeric(a *Constant, b *Constant) int {
+	if a.fVal == nil {
+		return -compareNumeric(b, a)
+	}
+
+	f := *a.fVal
+	if b.fVal != nil {
+		return compareFloats(f, *b.fVal)
+	}
+
+	i, _ := b.AsInteger()
+	switch {
+	case math.IsNaN(f):
+		return 1
+	case f < math.MinInt64:
+		return -1
+	case f >= math.MaxInt64:
+		return 1
+	}
+
+	// The float is in the range of int64, so its integer part is exact
+	whole := int64(f)
+	if whole != i {
+		if whole < i {
+			return -1
+		}
+		return 1
+	}
+	return compareFloats(f-math.Trunc(f), 0)
+}
+
+// Returns 1 for true and 0 for false
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func compareInts(a int64, b int64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a float64, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return 1
+	case math.IsNaN(b):
+		return -1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Number of bits of a value's hash that select its register in a sketch.
+// A sketch has 2^HLL_PRECISION registers, one byte each, and its estimates
+// have a standard error of about 1.04/sqrt(2^HLL_PRECISION), 0.8% here.
+const HLL_PRECISION = 14
+
+// A HyperLogLog sketch, which estimates the number of distinct values added
+// to it in constant space. Each value is hashed; the first bits of the hash
+// select a register, which keeps the longest run of leading zeros seen in
+// the rest. Values are hashed as Constant.HashCode hashes them, so values
+// that are equal count once.
+type HyperLogLog struct {
+	registers []uint8
+}
+
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{registers: make([]uint8, 1<<HLL_PRECISION)}
+}
+
+// Adds a value to the sketch
+func (h *HyperLogLog) Add(val *Constant) {
+	hash := mix(val.HashCode())
+	reg := hash >> (64 - HLL_PRECISION)
+	// The rank is the position of the first one bit after the register bits
+	rank := uint8(bits.LeadingZeros64(hash<<HLL_PRECISION|1<<(HLL_PRECISION-1)) + 1)
+	if rank > h.registers[reg] {
+		h.registers[reg] = rank
+	}
+}
+
+// Adds the values of another sketch to this one
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	for i, rank := range other.registers {
+		if rank > h.registers[i] {
+			h.registers[i] = rank
+		}
+	}
+}
+
+// Returns the estimated number of distinct values added to the sketch
+func (h *HyperLogLog) Estimate() int {
+	m := float64(len(h.registers))
+	sum := 0.0
+	zeros := 0
+	for _, rank := range h.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+
+	// Few values leave registers empty, and counting them is more accurate
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(math.Round(estimate))
+}
+
+// Spreads the bits of a hash, since the sketch relies on every bit being
+// random and FNV hashes of similar values differ in few of their high bits
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -38,5 +38,5 @@ func (rid *RID) Equals(object interface{}) bool {
 }
 
 func (rid *RID) toString() string {
-	return fmt.Sprintf("[%s, %d]", rid.blockNum, rid.slot)
+	return fmt.Sprintf("[%d, %d]", rid.blockNum, rid.slot)
 }
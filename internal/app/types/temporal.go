@@ -0,0 +1,128 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Returned when a string doesn't spell a date or a timestamp
+var ErrInvalidTime = errors.New("invalid date or time")
+
+// Layouts dates and timestamps are written in. Timestamps are in UTC, and
+// may be written as a date for its midnight or in RFC 3339 format.
+const (
+	DATE_LAYOUT      = "2006-01-02"
+	TIMESTAMP_LAYOUT = "2006-01-02 15:04:05"
+)
+
+// The number of seconds in the units dates and timestamps count
+const (
+	SECONDS_PER_DAY    = 24 * 60 * 60
+	SECONDS_PER_SECOND = 1
+)
+
+// Creates a date, counted in days from 1970-01-01
+func NewConstantDate(days int64) *Constant {
+	return &Constant{
+		lVal: &days,
+		unit: SECONDS_PER_DAY,
+	}
+}
+
+// Creates a timestamp, counted in seconds from 1970-01-01 00:00:00 UTC
+func NewConstantTimestamp(seconds int64) *Constant {
+	return &Constant{
+		lVal: &seconds,
+		unit: SECONDS_PER_SECOND,
+	}
+}
+
+// Creates the timestamp of a time, to the second
+func NewConstantTime(t time.Time) *Constant {
+	return NewConstantTimestamp(t.Unix())
+}
+
+// Parses a date written as "2006-01-02"
+func ParseDate(s string) (*Constant, error) {
+	t, err := time.Parse(DATE_LAYOUT, s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q is not a date", ErrInvalidTime, s)
+	}
+	return NewConstantDate(t.Unix() / SECONDS_PER_DAY), nil
+}
+
+// Parses a timestamp written as "2006-01-02 15:04:05", as a date or in RFC 3339 format
+func ParseTimestamp(s string) (*Constant, error) {
+	for _, layout := range []string{TIMESTAMP_LAYOUT, DATE_LAYOUT, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return NewConstantTime(t), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q is not a timestamp", ErrInvalidTime, s)
+}
+
+// Parses a date written as "2006-01-02", or else a timestamp
+func ParseTime(s string) (*Constant, error) {
+	if d, err := ParseDate(s); err == nil {
+		return d, nil
+	}
+	return ParseTimestamp(s)
+}
+
+// Reports whether the constant is a date
+func (c *Constant) IsDate() bool {
+	return c != nil && c.unit == SECONDS_PER_DAY
+}
+
+// Reports whether the constant is a timestamp
+func (c *Constant) IsTimestamp() bool {
+	return c != nil && c.unit == SECONDS_PER_SECOND
+}
+
+// Returns the time of a date or timestamp, in UTC
+func (c *Constant) Time() time.Time {
+	return time.Unix(c.seconds(), 0).UTC()
+}
+
+// Returns the seconds from the epoch of a date or timestamp
+func (c *Constant) seconds() int64 {
+	return *c.lVal * c.unit
+}
+
+// Converts a date, a timestamp or a string spelling either to a date.
+// A timestamp converts to the day it falls on.
+func (c *Constant) ToDate() (*Constant, error) {
+	switch {
+	case c.IsDate():
+		return c, nil
+	case c.IsTimestamp():
+		return NewConstantDate(floorDiv(c.seconds(), SECONDS_PER_DAY)), nil
+	case c != nil && c.sVal != nil:
+		return ParseDate(*c.sVal)
+	}
+	return nil, fmt.Errorf("%w: %v is not a date", ErrInvalidTime, c)
+}
+
+// Converts a date, a timestamp or a string spelling either to a timestamp.
+// A date converts to its midnight.
+func (c *Constant) ToTimestamp() (*Constant, error) {
+	switch {
+	case c.IsTimestamp():
+		return c, nil
+	case c.IsDate():
+		return NewConstantTimestamp(c.seconds()), nil
+	case c != nil && c.sVal != nil:
+		return ParseTimestamp(*c.sVal)
+	}
+	return nil, fmt.Errorf("%w: %v is not a timestamp", ErrInvalidTime, c)
+}
+
+// Divides rounding towards negative infinity, so days before 1970 start at their midnight
+func floorDiv(a int64, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
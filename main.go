@@ -1,14 +1,25 @@
 package main
 
 import (
+	"centauri/config"
 	"centauri/internal/app"
+	"flag"
 	"log"
+	"os"
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv(config.CONFIG_ENV), "path to the configuration file")
+	flag.Parse()
+
 	log.Println("Starting application...")
 
-	application := app.New()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+
+	application := app.New(cfg)
 
 	if err := application.Run(); err != nil {
 		log.Fatalf("Application error: %v", err)
@@ -0,0 +1,260 @@
+// Package migrate applies versioned SQL files to a database, so the
+// schema of an application's database can evolve along with its code.
+//
+// A migration is a file named <version>_<name>.sql, e.g.
+// 0001_create_students.sql, holding statements separated by ';'.
+// Text from -- to the end of a line is a comment. Migrations are applied in
+// version order, each in a transaction of its own, and the versions
+// applied are recorded in the schema_versions table so every migration
+// runs once.
+//
+//	d, err := db.Open("data", nil)
+//	if err != nil { ... }
+//	defer d.Close()
+//
+//	applied, err := migrate.Up(d, os.DirFS("migrations"), nil)
+package migrate
+
+import (
+	"centauri/db"
+	"centauri/dump"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The table recording the migrations applied to a database
+const VERSION_TABLE = "schema_versions"
+
+// Maximum length of the name of a migration
+const MAX_NAME = 64
+
+var (
+	// Returned when two migration files have the same version
+	ErrDuplicateVersion = errors.New("duplicate migration version")
+	// Returned when a pending migration is older than one already applied
+	ErrOutOfOrder = errors.New("migration is older than the latest applied")
+)
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.sql$`)
+
+// A migration read from a file
+type Migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// Configures how Up applies migrations
+type Options struct {
+	DryRun bool // only reports the migrations that would be applied
+}
+
+// Reads the migrations in the root directory of fsys, in version order.
+// Files that aren't named <version>_<name>.sql are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid version: %w", entry.Name(), err)
+		}
+		if len(match[2]) > MAX_NAME {
+			return nil, fmt.Errorf("%s: name is longer than %d characters", entry.Name(), MAX_NAME)
+		}
+
+		text, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		stmts, err := Statements(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: match[2], Statements: stmts})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateVersion, migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// Splits SQL text into its statements, leaving out comments and empty
+// statements. Fails if a string literal isn't closed.
+func Statements(text string) ([]string, error) {
+	var stmts []string
+	var stmt strings.Builder
+	inQuote := false
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\'':
+			inQuote = !inQuote
+		case !inQuote && c == '-' && strings.HasPrefix(text[i:], "--"):
+			// Skip to the end of the line, keeping the line break
+			for i+1 < len(text) && text[i+1] != '\n' {
+				i++
+			}
+			continue
+		case !inQuote && c == ';':
+			if s := strings.TrimSpace(stmt.String()); s != "" {
+				stmts = append(stmts, s)
+			}
+			stmt.Reset()
+			continue
+		}
+		stmt.WriteByte(c)
+	}
+
+	if inQuote {
+		return nil, fmt.Errorf("unterminated string literal")
+	}
+	if s := strings.TrimSpace(stmt.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+	return stmts, nil
+}
+
+// Returns the versions of the migrations applied to the database, in ascending order
+func Applied(d *db.DB) ([]int, error) {
+	exists, err := hasVersionTable(d)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	rows, err := d.Query("select version from " + VERSION_TABLE)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		versions = append(versions, rows.Row().Int("version"))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// Returns the migrations of fsys that haven't been applied to the database
+func Pending(d *db.DB, fsys fs.FS) ([]Migration, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := Applied(d)
+	if err != nil {
+		return nil, err
+	}
+
+	isApplied := make(map[int]bool, len(applied))
+	for _, version := range applied {
+		isApplied[version] = true
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if isApplied[m.Version] {
+			continue
+		}
+		if len(applied) > 0 && m.Version < applied[len(applied)-1] {
+			return nil, fmt.Errorf("%w: %d_%s, latest applied is %d", ErrOutOfOrder, m.Version, m.Name, applied[len(applied)-1])
+		}
+		pending = append(pending, m)
+	}
+	return pending, nil
+}
+
+// Applies the pending migrations of fsys in version order and returns them.
+// A migration that fails is rolled back and stops the ones after it;
+// the migrations before it stay applied. With DryRun set, the pending
+// migrations are returned without changing the database.
+func Up(d *db.DB, fsys fs.FS, opts *Options) ([]Migration, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	pending, err := Pending(d, fsys)
+	if err != nil || opts.DryRun || len(pending) == 0 {
+		return pending, err
+	}
+
+	if err := createVersionTable(d); err != nil {
+		return nil, err
+	}
+
+	for i, m := range pending {
+		if err := apply(d, m); err != nil {
+			return pending[:i], fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+	}
+	return pending, nil
+}
+
+// Runs the statements of a migration and records its version in one transaction
+func apply(d *db.DB, m Migration) error {
+	t, err := d.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Exec rolls the transaction back when a statement fails
+	for _, stmt := range m.Statements {
+		if _, err := t.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	record := fmt.Sprintf("insert into %s (version, name) values (%d, %s)", VERSION_TABLE, m.Version, dump.Quote(m.Name))
+	if _, err := t.Exec(record); err != nil {
+		return err
+	}
+	return t.Commit()
+}
+
+func hasVersionTable(d *db.DB) (bool, error) {
+	tables, err := d.ListTables()
+	if err != nil {
+		return false, err
+	}
+	for _, table := range tables {
+		if table.Name == VERSION_TABLE {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func createVersionTable(d *db.DB) error {
+	exists, err := hasVersionTable(d)
+	if err != nil || exists {
+		return err
+	}
+	_, err = d.Exec(fmt.Sprintf("create table %s (version int, name varchar(%d))", VERSION_TABLE, MAX_NAME))
+	return err
+}